@@ -0,0 +1,847 @@
+// Package annotation contains the schema of all pod annotations recognized by LXE
+package annotation // import "github.com/automaticserver/lxe/annotation"
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// Prefix of all annotations recognized by LXE
+	Prefix = "lxe.automaticserver.ch/"
+	// SchemaVersion is increased whenever keys are added, deprecated or removed
+	SchemaVersion = 14
+)
+
+var (
+	ErrInvalidPriority      = errors.New("invalid priority")
+	ErrInvalidSize          = errors.New("invalid size")
+	ErrInvalidBool          = errors.New("invalid boolean")
+	ErrInvalidEnforce       = errors.New("invalid memory enforcement")
+	ErrInvalidContainerMode = errors.New("invalid container mode")
+	ErrInvalidPidLimit      = errors.New("invalid pid limit")
+	ErrInvalidVLAN          = errors.New("invalid vlan id")
+	ErrInvalidVolume        = errors.New("invalid volume")
+	ErrInvalidNic           = errors.New("invalid nic")
+	ErrInvalidIP            = errors.New("invalid ip address")
+	ErrInvalidMAC           = errors.New("invalid mac address")
+	ErrInvalidCloudInit     = errors.New("invalid cloud-init data")
+	ErrInvalidUlimit        = errors.New("invalid ulimit")
+	ErrInvalidTimezone      = errors.New("invalid timezone")
+	ErrInvalidLocale        = errors.New("invalid locale")
+	ErrInvalidTimeOffset    = errors.New("invalid time offset")
+	ErrInvalidNUMANodes     = errors.New("invalid numa nodes")
+)
+
+// Type describes the format of the value
+type Type string
+
+// These are the value types of annotations
+const (
+	TypeBool   Type = "bool"
+	TypeInt    Type = "int"
+	TypeString Type = "string"
+	TypeList   Type = "list"
+)
+
+// Key is an annotation recognized by LXE
+type Key struct {
+	// Name of the annotation
+	Name string
+	// Type of the value
+	Type Type
+	// Description of the effect
+	Description string
+	// Since is the SchemaVersion this key was introduced
+	Since int
+	// Deprecated are former names of this key. They are still honored but a warning is emitted
+	Deprecated []string
+	// IsPrefix marks keys whose name is a prefix, every annotation starting with it belongs to this key
+	IsPrefix bool
+	// validate checks the value
+	validate func(string) error
+	// validateAll checks the values of a prefix key, indexed like GetAll returns them
+	validateAll func(map[string]string) error
+}
+
+// Get returns the value of the key. If only a deprecated name is set, its value is returned
+func (k *Key) Get(annotations map[string]string) (string, bool) {
+	if v, has := annotations[k.Name]; has {
+		return v, true
+	}
+
+	for _, d := range k.Deprecated {
+		if v, has := annotations[d]; has {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+// GetAll returns the values of all annotations starting with the name of a prefix key, indexed by the remainder of the
+// annotation name
+func (k *Key) GetAll(annotations map[string]string) map[string]string {
+	values := map[string]string{}
+
+	for name, v := range annotations {
+		if strings.HasPrefix(name, k.Name) && len(name) > len(k.Name) {
+			values[strings.TrimPrefix(name, k.Name)] = v
+		}
+	}
+
+	return values
+}
+
+// The annotations recognized by LXE
+var (
+	Adopt = &Key{
+		Name:        Prefix + "adopt",
+		Type:        TypeString,
+		Description: "Name of the LXD container to adopt as the pod's container of the same name instead of creating a new one. The container must be marked for this pod with `lxe adopt`",
+		Since:       2,
+	}
+	CloudInit = &Key{
+		Name:        Prefix + "cloud-init.",
+		Type:        TypeString,
+		Description: "Prefix of annotations which set the cloud-init user-data, vendor-data or meta-data of the pod's containers, e.g. " + Prefix + "cloud-init.user-data=#cloud-config... With the suffix -from, e.g. " + Prefix + "cloud-init.user-data-from=/etc/cloud/user-data, it's read from the file at that path of a configmap, secret, projected or downward api volume mounted into the container. The container environment variables user-data and meta-data have priority, vendor-data replaces the one setting the hostname",
+		Since:       11,
+		IsPrefix:    true,
+		validateAll: func(values map[string]string) error {
+			_, err := ParseCloudInit(values)
+			return err
+		},
+	}
+	Config = &Key{
+		Name:        Prefix + "config.",
+		Type:        TypeString,
+		Description: "Prefix of annotations which set the LXD config key following it, e.g. " + Prefix + "config.security.nesting=true. Only keys allowed by --config-allowlist and not denied by --config-denylist can be set",
+		Since:       3,
+		IsPrefix:    true,
+	}
+	Nics = &Key{
+		Name:        Prefix + "nic.",
+		Type:        TypeString,
+		Description: "Prefix of annotations which attach an additional host interface to the pod's containers, e.g. " + Prefix + "nic.eth1.nictype=macvlan. Options per interface are nictype (macvlan or sriov), parent, vlan and hwaddr",
+		Since:       9,
+		IsPrefix:    true,
+		validateAll: func(values map[string]string) error {
+			_, err := ParseNics(values)
+			return err
+		},
+	}
+	IP = &Key{
+		Name:        Prefix + "ip",
+		Type:        TypeString,
+		Description: "Static IP address or CIDR of the pod interface. Passed to the CNI plugins as ips capability and IP argument, the IPAM plugin must support it. The bridge network plugin assigns it if it's in the bridge subnet and not leased",
+		Since:       10,
+		validate: func(v string) error {
+			_, err := ParseIP(v)
+			return err
+		},
+	}
+	MAC = &Key{
+		Name:        Prefix + "mac",
+		Type:        TypeString,
+		Description: "Static MAC address of the pod interface. Passed to the CNI plugins as mac capability and MAC argument, a plugin like tuning must support it. The bridge network plugin sets it on the nic",
+		Since:       10,
+		validate: func(v string) error {
+			_, err := ParseMAC(v)
+			return err
+		},
+	}
+	MemoryEnforce = &Key{
+		Name:        Prefix + "memory-enforce",
+		Type:        TypeString,
+		Description: "How the memory limit of the pod's containers is enforced, hard or soft (only under host memory pressure), has priority over --memory-enforce",
+		Since:       6,
+		validate: func(v string) error {
+			_, err := ParseMemoryEnforce(v)
+			return err
+		},
+	}
+	MemorySwap = &Key{
+		Name:        Prefix + "memory-swap",
+		Type:        TypeBool,
+		Description: "Whether the pod's containers may swap, has priority over --memory-swap",
+		Since:       6,
+		validate: func(v string) error {
+			_, err := ParseBool(v)
+			return err
+		},
+	}
+	PidsLimit = &Key{
+		Name:        Prefix + "pids-limit",
+		Type:        TypeInt,
+		Description: "Maximum number of processes in each container of the pod, has priority over --pod-pids-limit",
+		Since:       7,
+		validate: func(v string) error {
+			_, err := ParsePidsLimit(v)
+			return err
+		},
+	}
+	NUMANodes = &Key{
+		Name:        Prefix + "numa-nodes",
+		Type:        TypeList,
+		Description: "NUMA nodes of the host to place the pod's containers on, e.g. 0 or 0,2-3. Containers without a cpuset from kubelet's cpu manager are pinned to the cpus of these nodes, containers without memory nodes to their memory",
+		Since:       14,
+		validate: func(v string) error {
+			_, err := ParseNUMANodes(v)
+			return err
+		},
+	}
+	StoragePool = &Key{
+		Name:        Prefix + "storage-pool",
+		Type:        TypeString,
+		Description: "LXD storage pool to create the root disks of the pod's containers on, has priority over --runtime-handler-pools",
+		Since:       4,
+	}
+	Locale = &Key{
+		Name:        Prefix + "locale",
+		Type:        TypeString,
+		Description: "Locale of the pod's containers, e.g. de_CH.UTF-8. Sets the LANG environment variable, the image must provide the locale",
+		Since:       13,
+		validate: func(v string) error {
+			_, err := ParseLocale(v)
+			return err
+		},
+	}
+	TargetMember = &Key{
+		Name:        Prefix + "target-member",
+		Type:        TypeString,
+		Description: "LXD cluster member to create the containers of the pod on, has priority over --lxd-target",
+		Since:       1,
+	}
+	TimeOffsets = &Key{
+		Name:        Prefix + "time-offset.",
+		Type:        TypeString,
+		Description: "Prefix of annotations which offset the boot or monotonic clock of the pod's containers by a duration, e.g. " + Prefix + "time-offset.boot=720h. The containers get their own time namespace, which requires Linux 5.6 and LXC 4.0",
+		Since:       13,
+		IsPrefix:    true,
+		validateAll: func(values map[string]string) error {
+			_, err := ParseTimeOffsets(values)
+			return err
+		},
+	}
+	Timezone = &Key{
+		Name:        Prefix + "timezone",
+		Type:        TypeString,
+		Description: "Timezone of the pod's containers, e.g. Europe/Zurich. Sets the TZ environment variable and mounts the zoneinfo file of the host at /etc/localtime",
+		Since:       13,
+		validate: func(v string) error {
+			_, err := ParseTimezone(v)
+			return err
+		},
+	}
+	Ulimits = &Key{
+		Name:        Prefix + "ulimit.",
+		Type:        TypeString,
+		Description: "Prefix of annotations which set a kernel resource limit (nofile, memlock or nproc) of the pod's containers as soft:hard or a single value for both, e.g. " + Prefix + "ulimit.nofile=65536 for all containers or " + Prefix + "ulimit.db.memlock=unlimited for the container db only, which has priority. Has priority over the runtime handler options ulimit-<resource>",
+		Since:       12,
+		IsPrefix:    true,
+		validateAll: func(values map[string]string) error {
+			_, err := ParseUlimits(values)
+			return err
+		},
+	}
+	VLAN = &Key{
+		Name:        Prefix + "vlan",
+		Type:        TypeInt,
+		Description: "VLAN ID between 1 and 4094 to tag the pod nic with when using the network plugin bridge, has priority over --bridge-vlans",
+		Since:       1,
+		validate: func(v string) error {
+			_, err := ParseVLAN(v)
+			return err
+		},
+	}
+	ContainerMode = &Key{
+		Name:        Prefix + "container-mode",
+		Type:        TypeString,
+		Description: "Whether the pod's containers boot the image's init (system) or run the container command as single process (application), has priority over --container-mode",
+		Since:       8,
+		validate: func(v string) error {
+			_, err := ParseContainerMode(v)
+			return err
+		},
+	}
+	EphemeralStorage = &Key{
+		Name:        Prefix + "ephemeral-storage",
+		Type:        TypeString,
+		Description: "Size of the root disk of each container of the pod as quantity, e.g. 10Gi. Set it to the ephemeral-storage limit of the pod, as kubelet doesn't pass it to the runtime. Requires a storage pool supporting quotas",
+		Since:       5,
+		validate: func(v string) error {
+			_, err := ParseSize(v)
+			return err
+		},
+	}
+	EvictionPriority = &Key{
+		Name:        Prefix + "eviction-priority",
+		Type:        TypeInt,
+		Description: "Priority of the pod when LXE evicts pods under host memory pressure, pods with the lowest priority are evicted first. Defaults to the QoS class: besteffort 0, burstable 1000, guaranteed 2000",
+		Since:       1,
+		validate: func(v string) error {
+			_, err := ParseEvictionPriority(v)
+			return err
+		},
+	}
+	Volumes = &Key{
+		Name:        Prefix + "volumes",
+		Type:        TypeList,
+		Description: "Comma separated list of [pool/]volume:path[:ro]. The LXD custom storage volume is created if missing and attached to the containers of the pod",
+		Since:       1,
+		validate: func(v string) error {
+			_, err := ParseVolumes(v)
+			return err
+		},
+	}
+)
+
+// All returns all recognized annotations sorted by name
+func All() []*Key {
+	keys := []*Key{Adopt, CloudInit, Config, ContainerMode, EphemeralStorage, EvictionPriority, IP, Locale, MAC, MemoryEnforce,
+		MemorySwap, Nics, NUMANodes, PidsLimit, StoragePool, TargetMember, TimeOffsets, Timezone, Ulimits, VLAN, Volumes}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+
+	return keys
+}
+
+// Validate checks the values of all recognized annotations. It returns a warning for every deprecated name in use and
+// for unknown annotations with the LXE prefix
+func Validate(annotations map[string]string) ([]string, error) {
+	return validate(All(), annotations)
+}
+
+func validate(keys []*Key, annotations map[string]string) ([]string, error) {
+	warnings := []string{}
+	known := map[string]bool{}
+	prefixes := []string{}
+
+	for _, k := range keys {
+		known[k.Name] = true
+
+		if k.IsPrefix {
+			prefixes = append(prefixes, k.Name)
+		}
+
+		for _, d := range k.Deprecated {
+			known[d] = true
+
+			if _, has := annotations[d]; has {
+				warnings = append(warnings, fmt.Sprintf("annotation %s is deprecated, use %s instead", d, k.Name))
+			}
+		}
+
+		if k.IsPrefix && k.validateAll != nil {
+			err := k.validateAll(k.GetAll(annotations))
+			if err != nil {
+				return warnings, fmt.Errorf("%s: %w", k.Name, err)
+			}
+		}
+
+		v, has := k.Get(annotations)
+		if !has || k.validate == nil {
+			continue
+		}
+
+		err := k.validate(v)
+		if err != nil {
+			return warnings, fmt.Errorf("%s: %w", k.Name, err)
+		}
+	}
+
+	names := make([]string, 0, len(annotations))
+	for name := range annotations {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if strings.HasPrefix(name, Prefix) && !known[name] && !hasAnyPrefix(name, prefixes) {
+			warnings = append(warnings, fmt.Sprintf("annotation %s is unknown and ignored", name))
+		}
+	}
+
+	return warnings, nil
+}
+
+func hasAnyPrefix(name string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseVLAN parses and validates a VLAN ID
+func ParseVLAN(str string) (int, error) {
+	vlan, err := strconv.Atoi(str)
+	if err != nil || vlan < 1 || vlan > 4094 {
+		return 0, fmt.Errorf("%w: %q must be between 1 and 4094", ErrInvalidVLAN, str)
+	}
+
+	return vlan, nil
+}
+
+// ParseIP parses an IP address, which can be in CIDR notation
+func ParseIP(str string) (net.IP, error) {
+	if ip, _, err := net.ParseCIDR(str); err == nil {
+		return ip, nil
+	}
+
+	ip := net.ParseIP(str)
+	if ip == nil {
+		return nil, fmt.Errorf("%w: %q must be an ip address or cidr", ErrInvalidIP, str)
+	}
+
+	return ip, nil
+}
+
+// ParseMAC parses a MAC address
+func ParseMAC(str string) (net.HardwareAddr, error) {
+	mac, err := net.ParseMAC(str)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidMAC, str)
+	}
+
+	return mac, nil
+}
+
+// ParseEvictionPriority parses the eviction priority
+func ParseEvictionPriority(str string) (int, error) {
+	prio, err := strconv.Atoi(str)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q must be an integer", ErrInvalidPriority, str)
+	}
+
+	return prio, nil
+}
+
+// ParseSize parses a quantity like 10Gi into bytes
+func ParseSize(str string) (int64, error) {
+	q, err := resource.ParseQuantity(str)
+	if err != nil || q.Sign() <= 0 {
+		return 0, fmt.Errorf("%w: %q must be a positive quantity", ErrInvalidSize, str)
+	}
+
+	return q.Value(), nil
+}
+
+// ParsePidsLimit parses a positive process count
+func ParsePidsLimit(str string) (int64, error) {
+	limit, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("%w: %q must be a positive integer", ErrInvalidPidLimit, str)
+	}
+
+	return limit, nil
+}
+
+// ParseBool parses true or false
+func ParseBool(str string) (bool, error) {
+	b, err := strconv.ParseBool(str)
+	if err != nil {
+		return false, fmt.Errorf("%w: %q must be true or false", ErrInvalidBool, str)
+	}
+
+	return b, nil
+}
+
+// These are the ways a memory limit is enforced
+const (
+	MemoryEnforceHard = "hard"
+	MemoryEnforceSoft = "soft"
+)
+
+// ParseMemoryEnforce parses the memory enforcement
+func ParseMemoryEnforce(str string) (string, error) {
+	if str != MemoryEnforceHard && str != MemoryEnforceSoft {
+		return "", fmt.Errorf("%w: %q must be %s or %s", ErrInvalidEnforce, str, MemoryEnforceHard, MemoryEnforceSoft)
+	}
+
+	return str, nil
+}
+
+// These are the modes of a container
+const (
+	ContainerModeSystem      = "system"
+	ContainerModeApplication = "application"
+)
+
+// ParseContainerMode parses the container mode
+func ParseContainerMode(str string) (string, error) {
+	if str != ContainerModeSystem && str != ContainerModeApplication {
+		return "", fmt.Errorf("%w: %q must be %s or %s", ErrInvalidContainerMode, str, ContainerModeSystem, ContainerModeApplication)
+	}
+
+	return str, nil
+}
+
+// DefaultVolumePool is used if a volume entry doesn't define a pool
+const DefaultVolumePool = "default"
+
+// Volume is a LXD custom storage volume entry
+type Volume struct {
+	Pool     string
+	Name     string
+	Path     string
+	Readonly bool
+}
+
+// ParseVolumes parses the comma separated volume entries in the form [pool/]volume:path[:ro]
+func ParseVolumes(raw string) ([]Volume, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	vols := []Volume{}
+
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		parts := strings.Split(entry, ":")
+
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || !path.IsAbs(parts[1]) {
+			return nil, fmt.Errorf("%w: entry %q must be in the form [pool/]volume:path[:ro]", ErrInvalidVolume, entry)
+		}
+
+		vol := Volume{
+			Pool: DefaultVolumePool,
+			Name: parts[0],
+			Path: path.Clean(parts[1]),
+		}
+
+		if i := strings.Index(parts[0], "/"); i >= 0 {
+			vol.Pool, vol.Name = parts[0][:i], parts[0][i+1:]
+		}
+
+		if vol.Pool == "" || vol.Name == "" || strings.Contains(vol.Name, "/") {
+			return nil, fmt.Errorf("%w: entry %q has an invalid pool or volume name", ErrInvalidVolume, entry)
+		}
+
+		if len(parts) == 3 {
+			if parts[2] != "ro" {
+				return nil, fmt.Errorf("%w: entry %q has unknown option %q", ErrInvalidVolume, entry, parts[2])
+			}
+
+			vol.Readonly = true
+		}
+
+		vols = append(vols, vol)
+	}
+
+	return vols, nil
+}
+
+// These are the types of additional nics
+const (
+	NicTypeMacvlan = "macvlan"
+	NicTypeSRIOV   = "sriov"
+)
+
+// maxInterfaceName is the longest name of a network interface the kernel accepts
+const maxInterfaceName = 15
+
+// Nic is an additional host interface attached to the pod's containers
+type Nic struct {
+	// Name of the interface in the container
+	Name    string
+	NicType string
+	Parent  string
+	Vlan    string
+	HWAddr  string
+}
+
+// ParseNics parses the nic annotations indexed by <interface>.<option> into the nics sorted by interface name. Every
+// nic needs a nictype and a parent
+func ParseNics(values map[string]string) ([]Nic, error) {
+	nics := map[string]*Nic{}
+
+	for key, v := range values {
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %q must be in the form <interface>.<option>", ErrInvalidNic, key)
+		}
+
+		name, option := key[:i], key[i+1:]
+		if name == "" || len(name) > maxInterfaceName || strings.ContainsAny(name, "/: \t") {
+			return nil, fmt.Errorf("%w: %q is not a valid interface name", ErrInvalidNic, name)
+		}
+
+		nic, has := nics[name]
+		if !has {
+			nic = &Nic{Name: name}
+			nics[name] = nic
+		}
+
+		switch option {
+		case "nictype":
+			if v != NicTypeMacvlan && v != NicTypeSRIOV {
+				return nil, fmt.Errorf("%w: %s nictype %q must be %s or %s", ErrInvalidNic, name, v, NicTypeMacvlan, NicTypeSRIOV)
+			}
+
+			nic.NicType = v
+		case "parent":
+			nic.Parent = v
+		case "vlan":
+			_, err := ParseVLAN(v)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidNic, name, err)
+			}
+
+			nic.Vlan = v
+		case "hwaddr":
+			_, err := net.ParseMAC(v)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s hwaddr %q must be a mac address", ErrInvalidNic, name, v)
+			}
+
+			nic.HWAddr = v
+		default:
+			return nil, fmt.Errorf("%w: %s has unknown option %q", ErrInvalidNic, name, option)
+		}
+	}
+
+	list := make([]Nic, 0, len(nics))
+
+	for _, nic := range nics {
+		if nic.NicType == "" || nic.Parent == "" {
+			return nil, fmt.Errorf("%w: %s needs a nictype and a parent", ErrInvalidNic, nic.Name)
+		}
+
+		list = append(list, *nic)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return list, nil
+}
+
+// These are the cloud-init data an annotation can set
+const (
+	CloudInitUserData   = "user-data"
+	CloudInitVendorData = "vendor-data"
+	CloudInitMetaData   = "meta-data"
+	// cloudInitFromSuffix marks a value as path of the file containing the data
+	cloudInitFromSuffix = "-from"
+)
+
+// CloudInitSource is the content of a cloud-init data, or the path of the file in the container it's read from
+type CloudInitSource struct {
+	Content string
+	Path    string
+}
+
+// ParseCloudInit parses the cloud-init annotations indexed by the data name, optionally with the suffix -from if the
+// value is the absolute path of a file, into their sources by data name. Each data can only be set once
+func ParseCloudInit(values map[string]string) (map[string]CloudInitSource, error) {
+	sources := map[string]CloudInitSource{}
+
+	for key, v := range values {
+		name := strings.TrimSuffix(key, cloudInitFromSuffix)
+		if name != CloudInitUserData && name != CloudInitVendorData && name != CloudInitMetaData {
+			return nil, fmt.Errorf("%w: %q must be %s, %s or %s, optionally with suffix %s", ErrInvalidCloudInit, key,
+				CloudInitUserData, CloudInitVendorData, CloudInitMetaData, cloudInitFromSuffix)
+		}
+
+		if _, has := sources[name]; has {
+			return nil, fmt.Errorf("%w: %s is set as content and as file", ErrInvalidCloudInit, name)
+		}
+
+		if name == key {
+			sources[name] = CloudInitSource{Content: v}
+			continue
+		}
+
+		if !path.IsAbs(v) {
+			return nil, fmt.Errorf("%w: %s path %q must be absolute", ErrInvalidCloudInit, name, v)
+		}
+
+		sources[name] = CloudInitSource{Path: path.Clean(v)}
+	}
+
+	return sources, nil
+}
+
+// UlimitResources are the kernel resource limits which can be set, LXD sets them as limits.kernel.<resource>
+var UlimitResources = []string{"nofile", "memlock", "nproc"}
+
+// UlimitUnlimited lifts the limit
+const UlimitUnlimited = "unlimited"
+
+// ParseUlimit parses a kernel resource limit in the form soft:hard or a single value for both, each a non-negative
+// integer or unlimited. It returns the limit as soft:hard
+func ParseUlimit(str string) (string, error) {
+	parts := strings.Split(str, ":")
+	if len(parts) > 2 {
+		return "", fmt.Errorf("%w: %q must be in the form soft:hard", ErrInvalidUlimit, str)
+	}
+
+	if len(parts) == 1 {
+		parts = append(parts, parts[0])
+	}
+
+	limits := make([]int64, 2)
+
+	for i, p := range parts {
+		if p == UlimitUnlimited {
+			limits[i] = -1
+			continue
+		}
+
+		v, err := strconv.ParseInt(p, 10, 64)
+		if err != nil || v < 0 {
+			return "", fmt.Errorf("%w: %q must be a non-negative integer or %s", ErrInvalidUlimit, p, UlimitUnlimited)
+		}
+
+		limits[i] = v
+	}
+
+	if limits[1] >= 0 && (limits[0] < 0 || limits[0] > limits[1]) {
+		return "", fmt.Errorf("%w: soft limit of %q exceeds the hard limit", ErrInvalidUlimit, str)
+	}
+
+	return parts[0] + ":" + parts[1], nil
+}
+
+// ParseUlimits parses the ulimit annotations indexed by <resource> or <container>.<resource> into the limits by
+// resource per container name, the limits for all containers have the empty name
+func ParseUlimits(values map[string]string) (map[string]map[string]string, error) {
+	ulimits := map[string]map[string]string{}
+
+	for key, v := range values {
+		container, resource := "", key
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			container, resource = key[:i], key[i+1:]
+
+			if container == "" {
+				return nil, fmt.Errorf("%w: %q must be in the form [<container>.]<resource>", ErrInvalidUlimit, key)
+			}
+		}
+
+		if !isUlimitResource(resource) {
+			return nil, fmt.Errorf("%w: %q must be one of %s", ErrInvalidUlimit, resource, strings.Join(UlimitResources, ", "))
+		}
+
+		limit, err := ParseUlimit(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+
+		if ulimits[container] == nil {
+			ulimits[container] = map[string]string{}
+		}
+
+		ulimits[container][resource] = limit
+	}
+
+	return ulimits, nil
+}
+
+func isUlimitResource(resource string) bool {
+	for _, r := range UlimitResources {
+		if r == resource {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	// timezonePattern matches the names of the zoneinfo database, like Europe/Zurich or Etc/GMT+1
+	timezonePattern = regexp.MustCompile(`^[A-Za-z0-9_+-]+(/[A-Za-z0-9_+-]+)*$`)
+	// localePattern matches locale names, like de_CH.UTF-8 or sr_RS@latin
+	localePattern = regexp.MustCompile(`^[A-Za-z0-9_.@-]+$`)
+)
+
+// ParseTimezone checks the name of a timezone of the zoneinfo database, it can't leave the database directory
+func ParseTimezone(str string) (string, error) {
+	if !timezonePattern.MatchString(str) {
+		return "", fmt.Errorf("%w: %q must be a name of the zoneinfo database like Europe/Zurich", ErrInvalidTimezone, str)
+	}
+
+	return str, nil
+}
+
+// ParseLocale checks the name of a locale
+func ParseLocale(str string) (string, error) {
+	if !localePattern.MatchString(str) {
+		return "", fmt.Errorf("%w: %q must be a locale name like de_CH.UTF-8", ErrInvalidLocale, str)
+	}
+
+	return str, nil
+}
+
+// These are the clocks of a time namespace which can be offset
+const (
+	ClockBoot      = "boot"
+	ClockMonotonic = "monotonic"
+)
+
+// ParseTimeOffsets parses the time offset annotations indexed by the clock into the offsets by clock
+func ParseTimeOffsets(values map[string]string) (map[string]time.Duration, error) {
+	offsets := map[string]time.Duration{}
+
+	for clock, v := range values {
+		if clock != ClockBoot && clock != ClockMonotonic {
+			return nil, fmt.Errorf("%w: clock %q must be %s or %s", ErrInvalidTimeOffset, clock, ClockBoot, ClockMonotonic)
+		}
+
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s offset %q must be a duration like 720h", ErrInvalidTimeOffset, clock, v)
+		}
+
+		offsets[clock] = d
+	}
+
+	return offsets, nil
+}
+
+// ParseNUMANodes parses a list of NUMA node ids and ranges like 0,2-3 into the sorted ids
+func ParseNUMANodes(str string) ([]uint64, error) {
+	set := map[uint64]bool{}
+
+	for _, part := range strings.Split(str, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2) // nolint: gomnd
+
+		first, err := strconv.ParseUint(bounds[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q must be a list of node ids and ranges like 0,2-3", ErrInvalidNUMANodes, str)
+		}
+
+		last := first
+
+		if len(bounds) == 2 { // nolint: gomnd
+			last, err = strconv.ParseUint(bounds[1], 10, 32)
+			if err != nil || last < first {
+				return nil, fmt.Errorf("%w: %q has an invalid range %s", ErrInvalidNUMANodes, str, part)
+			}
+		}
+
+		for n := first; n <= last; n++ {
+			set[n] = true
+		}
+	}
+
+	nodes := make([]uint64, 0, len(set))
+	for n := range set {
+		nodes = append(nodes, n)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
+
+	return nodes, nil
+}
@@ -0,0 +1,355 @@
+package annotation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey_Get(t *testing.T) {
+	t.Parallel()
+
+	k := &Key{Name: Prefix + "new", Deprecated: []string{Prefix + "old"}}
+
+	_, has := k.Get(nil)
+	assert.False(t, has)
+
+	v, has := k.Get(map[string]string{Prefix + "old": "foo"})
+	assert.True(t, has)
+	assert.Equal(t, "foo", v)
+
+	v, has = k.Get(map[string]string{Prefix + "old": "foo", Prefix + "new": "bar"})
+	assert.True(t, has)
+	assert.Equal(t, "bar", v)
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	warnings, err := Validate(map[string]string{
+		VLAN.Name:         "10",
+		Volumes.Name:      "data:/data",
+		Prefix + "foo":    "bar",
+		"some.other/anno": "baz",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"annotation lxe.automaticserver.ch/foo is unknown and ignored"}, warnings)
+
+	_, err = Validate(map[string]string{VLAN.Name: "0"})
+	assert.True(t, errors.Is(err, ErrInvalidVLAN))
+
+	_, err = Validate(map[string]string{EvictionPriority.Name: "high"})
+	assert.True(t, errors.Is(err, ErrInvalidPriority))
+
+	_, err = Validate(map[string]string{Volumes.Name: "data"})
+	assert.True(t, errors.Is(err, ErrInvalidVolume))
+}
+
+func Test_validate_Deprecated(t *testing.T) {
+	t.Parallel()
+
+	k := &Key{Name: Prefix + "new", Deprecated: []string{Prefix + "old"}, validate: func(v string) error {
+		_, err := ParseVLAN(v)
+		return err
+	}}
+
+	warnings, err := validate([]*Key{k}, map[string]string{Prefix + "old": "10"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"annotation lxe.automaticserver.ch/old is deprecated, use lxe.automaticserver.ch/new instead"}, warnings)
+
+	_, err = validate([]*Key{k}, map[string]string{Prefix + "old": "abc"})
+	assert.True(t, errors.Is(err, ErrInvalidVLAN))
+}
+
+func TestValidate_Nics(t *testing.T) {
+	t.Parallel()
+
+	_, err := Validate(map[string]string{Nics.Name + "eth1.nictype": "macvlan", Nics.Name + "eth1.parent": "enp3s0"})
+	assert.NoError(t, err)
+
+	_, err = Validate(map[string]string{Nics.Name + "eth1.nictype": "macvlan"})
+	assert.True(t, errors.Is(err, ErrInvalidNic))
+}
+
+func TestParseNics(t *testing.T) {
+	t.Parallel()
+
+	nics, err := ParseNics(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, nics)
+
+	nics, err = ParseNics(map[string]string{
+		"net2.nictype": "sriov",
+		"net2.parent":  "enp3s0f0",
+		"eth1.nictype": "macvlan",
+		"eth1.parent":  "enp3s0",
+		"eth1.vlan":    "100",
+		"eth1.hwaddr":  "00:16:3e:00:00:01",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Nic{
+		{Name: "eth1", NicType: NicTypeMacvlan, Parent: "enp3s0", Vlan: "100", HWAddr: "00:16:3e:00:00:01"},
+		{Name: "net2", NicType: NicTypeSRIOV, Parent: "enp3s0f0"},
+	}, nics)
+
+	for _, values := range []map[string]string{
+		{"eth1": "macvlan"},
+		{"eth1.nictype": "bridged", "eth1.parent": "br0"},
+		{"eth1.nictype": "macvlan", "eth1.parent": "enp3s0", "eth1.vlan": "0"},
+		{"eth1.nictype": "macvlan", "eth1.parent": "enp3s0", "eth1.hwaddr": "foo"},
+		{"eth1.nictype": "macvlan", "eth1.parent": "enp3s0", "eth1.mtu": "1400"},
+		{"averyveryverylongname.nictype": "macvlan", "averyveryverylongname.parent": "enp3s0"},
+		{"eth1.parent": "enp3s0"},
+	} {
+		_, err = ParseNics(values)
+		assert.True(t, errors.Is(err, ErrInvalidNic), values)
+	}
+}
+
+func TestParseIP(t *testing.T) {
+	t.Parallel()
+
+	ip, err := ParseIP("10.22.0.10")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.22.0.10", ip.String())
+
+	ip, err = ParseIP("10.22.0.10/16")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.22.0.10", ip.String())
+
+	_, err = ParseIP("10.22.0")
+	assert.True(t, errors.Is(err, ErrInvalidIP))
+
+	_, err = ParseMAC("00:16:3e:00:00:01")
+	assert.NoError(t, err)
+
+	_, err = ParseMAC("00:16:3e")
+	assert.True(t, errors.Is(err, ErrInvalidMAC))
+}
+
+func TestAll(t *testing.T) {
+	t.Parallel()
+
+	for _, k := range All() {
+		assert.Contains(t, k.Name, Prefix)
+		assert.NotEmpty(t, k.Description)
+		assert.LessOrEqual(t, k.Since, SchemaVersion)
+	}
+}
+
+func TestParseVolumes(t *testing.T) {
+	t.Parallel()
+
+	vols, err := ParseVolumes("data:/var/lib/data, fast/logs:/logs/:ro")
+	assert.NoError(t, err)
+	assert.Equal(t, []Volume{
+		{Pool: DefaultVolumePool, Name: "data", Path: "/var/lib/data"},
+		{Pool: "fast", Name: "logs", Path: "/logs", Readonly: true},
+	}, vols)
+
+	vols, err = ParseVolumes("")
+	assert.NoError(t, err)
+	assert.Empty(t, vols)
+}
+
+func TestParseVolumes_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{"data", "data:relative", ":/data", "/data:/data", "a/b/c:/data", "data:/data:rw", "data:/data:ro:x"} {
+		_, err := ParseVolumes(v)
+		assert.True(t, errors.Is(err, ErrInvalidVolume), v)
+	}
+}
+
+func TestKey_GetAll(t *testing.T) {
+	t.Parallel()
+
+	values := Config.GetAll(map[string]string{
+		Config.Name + "security.nesting": "true",
+		Config.Name:                      "empty",
+		VLAN.Name:                        "10",
+	})
+	assert.Equal(t, map[string]string{"security.nesting": "true"}, values)
+
+	warnings, err := Validate(map[string]string{Config.Name + "security.nesting": "true"})
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+}
+
+func TestParseSize(t *testing.T) {
+	t.Parallel()
+
+	size, err := ParseSize("10Gi")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10*1024*1024*1024), size)
+
+	size, err = ParseSize("500M")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(500*1000*1000), size)
+
+	for _, v := range []string{"", "big", "0", "-1Gi"} {
+		_, err = ParseSize(v)
+		assert.True(t, errors.Is(err, ErrInvalidSize), v)
+	}
+}
+
+func TestParseMemoryEnforce(t *testing.T) {
+	t.Parallel()
+
+	e, err := ParseMemoryEnforce("soft")
+	assert.NoError(t, err)
+	assert.Equal(t, MemoryEnforceSoft, e)
+
+	_, err = ParseMemoryEnforce("Hard")
+	assert.True(t, errors.Is(err, ErrInvalidEnforce))
+
+	_, err = Validate(map[string]string{MemorySwap.Name: "maybe"})
+	assert.True(t, errors.Is(err, ErrInvalidBool))
+}
+
+func TestParseContainerMode(t *testing.T) {
+	t.Parallel()
+
+	m, err := ParseContainerMode("application")
+	assert.NoError(t, err)
+	assert.Equal(t, ContainerModeApplication, m)
+
+	_, err = Validate(map[string]string{ContainerMode.Name: "app"})
+	assert.True(t, errors.Is(err, ErrInvalidContainerMode))
+}
+
+func TestParsePidsLimit(t *testing.T) {
+	t.Parallel()
+
+	limit, err := ParsePidsLimit("1024")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024), limit)
+
+	for _, v := range []string{"", "0", "-1", "many"} {
+		_, err = ParsePidsLimit(v)
+		assert.True(t, errors.Is(err, ErrInvalidPidLimit), v)
+	}
+}
+
+func TestParseCloudInit(t *testing.T) {
+	t.Parallel()
+
+	sources, err := ParseCloudInit(map[string]string{
+		"user-data":        "#cloud-config\npackages: [nginx]\n",
+		"vendor-data-from": "/etc/cloud/../cloud/vendor-data",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]CloudInitSource{
+		CloudInitUserData:   {Content: "#cloud-config\npackages: [nginx]\n"},
+		CloudInitVendorData: {Path: "/etc/cloud/vendor-data"},
+	}, sources)
+
+	for _, values := range []map[string]string{
+		{"network-config": "version: 1"},
+		{"user-data-from": "relative/user-data"},
+		{"meta-data": "instance-id: a", "meta-data-from": "/etc/meta-data"},
+	} {
+		_, err = ParseCloudInit(values)
+		assert.True(t, errors.Is(err, ErrInvalidCloudInit), values)
+	}
+}
+
+func TestParseUlimit(t *testing.T) {
+	t.Parallel()
+
+	for v, expected := range map[string]string{
+		"65536":           "65536:65536",
+		"1024:65536":      "1024:65536",
+		"unlimited":       "unlimited:unlimited",
+		"65536:unlimited": "65536:unlimited",
+		"0":               "0:0",
+	} {
+		limit, err := ParseUlimit(v)
+		assert.NoError(t, err, v)
+		assert.Equal(t, expected, limit, v)
+	}
+
+	for _, v := range []string{"", "-1", "lots", "1:2:3", "65536:1024", "unlimited:1024"} {
+		_, err := ParseUlimit(v)
+		assert.True(t, errors.Is(err, ErrInvalidUlimit), v)
+	}
+}
+
+func TestParseUlimits(t *testing.T) {
+	t.Parallel()
+
+	ulimits, err := ParseUlimits(map[string]string{
+		"nofile":     "65536",
+		"db.memlock": "unlimited",
+		"db.nofile":  "1048576",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{
+		"":   {"nofile": "65536:65536"},
+		"db": {"memlock": "unlimited:unlimited", "nofile": "1048576:1048576"},
+	}, ulimits)
+
+	for _, k := range []string{"stack", "db.stack", ".nofile"} {
+		_, err = ParseUlimits(map[string]string{k: "1024"})
+		assert.True(t, errors.Is(err, ErrInvalidUlimit), k)
+	}
+
+	_, err = Validate(map[string]string{Ulimits.Name + "nproc": "4096:1024"})
+	assert.True(t, errors.Is(err, ErrInvalidUlimit))
+}
+
+func TestParseTimezone(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{"Europe/Zurich", "Etc/GMT+1", "America/Port-au-Prince", "UTC"} {
+		_, err := ParseTimezone(v)
+		assert.NoError(t, err, v)
+	}
+
+	for _, v := range []string{"", "/etc/passwd", "../passwd", "Europe//Zurich", "Europe/"} {
+		_, err := ParseTimezone(v)
+		assert.True(t, errors.Is(err, ErrInvalidTimezone), v)
+	}
+}
+
+func TestParseLocale(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{"de_CH.UTF-8", "C.UTF-8", "sr_RS@latin"} {
+		_, err := ParseLocale(v)
+		assert.NoError(t, err, v)
+	}
+
+	for _, v := range []string{"", "de CH", "$(reboot)"} {
+		_, err := ParseLocale(v)
+		assert.True(t, errors.Is(err, ErrInvalidLocale), v)
+	}
+}
+
+func TestParseTimeOffsets(t *testing.T) {
+	t.Parallel()
+
+	offsets, err := ParseTimeOffsets(map[string]string{"boot": "720h", "monotonic": "-1h30m"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{ClockBoot: 720 * time.Hour, ClockMonotonic: -90 * time.Minute}, offsets)
+
+	for k, v := range map[string]string{"realtime": "1h", "boot": "a month"} {
+		_, err = ParseTimeOffsets(map[string]string{k: v})
+		assert.True(t, errors.Is(err, ErrInvalidTimeOffset), k)
+	}
+}
+
+func TestParseNUMANodes(t *testing.T) {
+	t.Parallel()
+
+	nodes, err := ParseNUMANodes("3, 0-1,1")
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{0, 1, 3}, nodes)
+
+	for _, v := range []string{"", "a", "1-", "2-1", "0,,1", "-1"} {
+		_, err := ParseNUMANodes(v)
+		assert.True(t, errors.Is(err, ErrInvalidNUMANodes), v)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/automaticserver/lxe/logging"
 	"github.com/sirupsen/logrus"
 )
 
@@ -16,17 +17,20 @@ const (
 	logTimestampFormatPretty = "01-02|15:04:05.000"
 )
 
-// WriterHook is a hook that writes logs of specified LogLevels to specified Writer
+// WriterHook is a hook that writes logs to specified Writer, if the log level of their subsystem allows it
 type WriterHook struct {
-	Writer      io.Writer
-	Formatter   logrus.Formatter
-	LogLevelMin logrus.Level
+	Writer    io.Writer
+	Formatter logrus.Formatter
 }
 
 // Fire will be called when some logging function is called with current hook
-// It will format log entry to string and write it to appropriate writer
+// It will add the log fields of the entry's context, format log entry to string and write it to appropriate writer
 func (hook *WriterHook) Fire(entry *logrus.Entry) error {
-	b, err := hook.Formatter.Format(entry)
+	if !logging.Enabled(entry) {
+		return nil
+	}
+
+	b, err := hook.Formatter.Format(logging.Decorate(entry))
 	if err != nil {
 		return err
 	}
@@ -36,9 +40,9 @@ func (hook *WriterHook) Fire(entry *logrus.Entry) error {
 	return err
 }
 
-// Levels define on which log levels this hook would trigger
+// Levels define on which log levels this hook would trigger, the levels of the subsystems can change at runtime
 func (hook *WriterHook) Levels() []logrus.Level {
-	return logrus.AllLevels[:hook.LogLevelMin+1]
+	return logrus.AllLevels
 }
 
 func setLoggingBasic() error {
@@ -54,6 +58,22 @@ func setLoggingBasic() error {
 	return nil
 }
 
+// setLoggingLevels applies the default logging level and the overrides of the subsystems. They're only filtered by the
+// hook, so they're applied after it's set up
+func setLoggingLevels() error {
+	level, err := logrus.ParseLevel(venom.GetString(fmt.Sprintf("log%vlevel", keyDelimiter)))
+	if err != nil {
+		return err
+	}
+
+	subsystems, err := logging.ParseLevels(venom.GetStringSlice(fmt.Sprintf("log%vsubsystem%vlevels", keyDelimiter, keyDelimiter)))
+	if err != nil {
+		return err
+	}
+
+	return logging.SetLevels(level, subsystems)
+}
+
 var ErrUnknownLogTarget = errors.New("unknown log target defined")
 var ErrLogFilePathRequired = errors.New("log file path required")
 
@@ -91,9 +111,8 @@ func setLoggingHook() error {
 		fallthrough
 	case "file":
 		logrus.AddHook(&WriterHook{
-			Writer:      writer,
-			Formatter:   formatter,
-			LogLevelMin: logrus.GetLevel(),
+			Writer:    writer,
+			Formatter: formatter,
 		})
 	default:
 		return fmt.Errorf("%w", ErrUnknownLogTarget)
@@ -102,12 +121,13 @@ func setLoggingHook() error {
 	// Send direct logs to nowhere, everything is handled by the hook now
 	logrus.SetOutput(ioutil.Discard)
 
-	return nil
+	return setLoggingLevels()
 }
 
 func initLog() {
 	pflags := rootCmd.PersistentFlags()
 	pflags.String(fmt.Sprintf("log%vlevel", keyDelimiter), logrus.WarnLevel.String(), "Define minimum log level, one of: "+strings.Join(logLevels(), ", ")+".")
+	pflags.StringSlice(fmt.Sprintf("log%vsubsystem%vlevels", keyDelimiter, keyDelimiter), []string{}, "Override the minimum log level of subsystems, e.g. network=debug. Format: subsystem=level.")
 	pflags.String(fmt.Sprintf("log%vtarget", keyDelimiter), "stderr", "Define log output target, one of: stdout, stderr, file.")
 	pflags.String(fmt.Sprintf("log%vformat", keyDelimiter), "pretty", "Define default log format, one of: json, keyvalue, pretty.")
 	pflags.String(fmt.Sprintf("log%vfile%vpath", keyDelimiter, keyDelimiter), "", fmt.Sprintf("Path to log file. Only required if --log%starget is set to file.", keyDelimiter))
@@ -1,6 +1,7 @@
 package cli // import "github.com/automaticserver/lxe/cli"
 
 import (
+	"errors"
 	"os"
 	"os/signal"
 	"path"
@@ -16,13 +17,31 @@ import (
 
 const (
 	AnnIsNonoperational = "nonoperational"
+	// ExitForced is the exit code if the shutdown wasn't graceful, as a shutdown hook gave up or a second signal was
+	// received
+	ExitForced = 2
 )
 
 var (
-	envReplacer  = strings.NewReplacer("-", "_")
-	keyDelimiter = "-"
+	envReplacer   = strings.NewReplacer("-", "_")
+	keyDelimiter  = "-"
+	reloadHooks   = []func() error{}
+	shutdownHooks = []func() error{}
+	// ErrForcedShutdown is returned by shutdown hooks which had to give up on stopping gracefully
+	ErrForcedShutdown = errors.New("forced shutdown")
 )
 
+// OnReload registers a function which is called after the configuration was reloaded on SIGHUP
+func OnReload(f func() error) {
+	reloadHooks = append(reloadHooks, f)
+}
+
+// OnShutdown registers a function which is called on SIGINT or SIGTERM before exiting. If it returns an error wrapping
+// ErrForcedShutdown the exit code is ExitForced
+func OnShutdown(f func() error) {
+	shutdownHooks = append(shutdownHooks, f)
+}
+
 var rootCmd = &cobra.Command{
 	DisableAutoGenTag: true,
 	SilenceUsage:      false,
@@ -130,20 +149,79 @@ func handleSignals(c *cobra.Command) {
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGINT)
 	signal.Notify(ch, syscall.SIGTERM)
+	signal.Notify(ch, syscall.SIGHUP)
+
+	stopping := false
 
 	for sig := range ch {
 		log.WithField("sig", sig).Info("received signal")
 
 		switch sig {
 		case syscall.SIGINT, syscall.SIGTERM:
-			err := gracefulShutdown(c)
+			if stopping {
+				log.Warnf("received another signal, forcing %v to stop", c.Name())
+				os.Exit(ExitForced)
+			}
+
+			stopping = true
+
+			// the signals are still handled while stopping
+			go func() {
+				os.Exit(shutdown(c))
+			}()
+		case syscall.SIGHUP:
+			err := reloadConfig()
 			if err != nil {
-				log.WithError(err).Fatalf("unable to stop %v", c.Name())
+				log.WithError(err).Error("unable to reload config")
 			}
+		}
+	}
+}
 
-			os.Exit(0)
+// reloadConfig reads the config file again, applies the logging levels and calls the registered reload hooks
+func reloadConfig() error {
+	if venom.ConfigFileUsed() != "" {
+		err := venom.ReadInConfig()
+		if err != nil {
+			return err
 		}
 	}
+
+	err := setLoggingLevels()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range reloadHooks {
+		err = f()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// shutdown runs the post run functions and the shutdown hooks and returns the exit code
+func shutdown(c *cobra.Command) int {
+	err := gracefulShutdown(c)
+	if err != nil {
+		log.WithError(err).Errorf("unable to stop %v", c.Name())
+		return 1
+	}
+
+	for _, f := range shutdownHooks {
+		err = f()
+		if errors.Is(err, ErrForcedShutdown) {
+			log.WithError(err).Warnf("%v stopped forcefully", c.Name())
+			return ExitForced
+		} else if err != nil {
+			log.WithError(err).Errorf("unable to stop %v", c.Name())
+			return 1
+		}
+	}
+
+	return 0
 }
 
 // gracefulShutdown is a copy of *cobra.Command.execute() with only the relevant post run functions
@@ -175,8 +253,10 @@ func gracefulShutdown(c *cobra.Command) error {
 	return nil
 }
 
+// markIsNonoperational marks the root command as nonoperational if the command is one of the builtin informational
+// commands or has the annotation AnnIsNonoperational itself
 func markIsNonoperational(c *cobra.Command) {
-	if c == confCmd || c == cmplCmd || c == versionCmd {
+	if c == confCmd || c == cmplCmd || c == versionCmd || c.Annotations[AnnIsNonoperational] != "" {
 		c.Root().Annotations[AnnIsNonoperational] = strconv.FormatBool(true)
 	}
 }
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_shutdown(t *testing.T) {
+	defer func() { shutdownHooks = []func() error{} }()
+
+	c := &cobra.Command{Use: "test"}
+	called := 0
+
+	OnShutdown(func() error {
+		called++
+		return nil
+	})
+
+	assert.Equal(t, 0, shutdown(c))
+	assert.Equal(t, 1, called)
+
+	OnShutdown(func() error {
+		return fmt.Errorf("%w: operations still running", ErrForcedShutdown)
+	})
+
+	assert.Equal(t, ExitForced, shutdown(c))
+
+	shutdownHooks = shutdownHooks[:1]
+	OnShutdown(func() error {
+		return errors.New("socket busy")
+	})
+
+	assert.Equal(t, 1, shutdown(c))
+
+	c.PostRunE = func(*cobra.Command, []string) error {
+		return errors.New("post run failed")
+	}
+	called = 0
+
+	assert.Equal(t, 1, shutdown(c))
+	assert.Equal(t, 0, called, "hooks aren't called if the post run failed")
+}
@@ -363,6 +363,8 @@ _prog_completion_bash()
     two_word_flags+=("--log-format")
     flags+=("--log-level=")
     two_word_flags+=("--log-level")
+    flags+=("--log-subsystem-levels=")
+    two_word_flags+=("--log-subsystem-levels")
     flags+=("--log-target=")
     two_word_flags+=("--log-target")
     flags+=("--remote-first=")
@@ -424,6 +426,8 @@ _prog_completion_powershell()
     two_word_flags+=("--log-format")
     flags+=("--log-level=")
     two_word_flags+=("--log-level")
+    flags+=("--log-subsystem-levels=")
+    two_word_flags+=("--log-subsystem-levels")
     flags+=("--log-target=")
     two_word_flags+=("--log-target")
     flags+=("--remote-first=")
@@ -485,6 +489,8 @@ _prog_completion_zsh()
     two_word_flags+=("--log-format")
     flags+=("--log-level=")
     two_word_flags+=("--log-level")
+    flags+=("--log-subsystem-levels=")
+    two_word_flags+=("--log-subsystem-levels")
     flags+=("--log-target=")
     two_word_flags+=("--log-target")
     flags+=("--remote-first=")
@@ -549,6 +555,8 @@ _prog_completion()
     two_word_flags+=("--log-format")
     flags+=("--log-level=")
     two_word_flags+=("--log-level")
+    flags+=("--log-subsystem-levels=")
+    two_word_flags+=("--log-subsystem-levels")
     flags+=("--log-target=")
     two_word_flags+=("--log-target")
     flags+=("--remote-first=")
@@ -610,6 +618,8 @@ _prog_config_show()
     two_word_flags+=("--log-format")
     flags+=("--log-level=")
     two_word_flags+=("--log-level")
+    flags+=("--log-subsystem-levels=")
+    two_word_flags+=("--log-subsystem-levels")
     flags+=("--log-target=")
     two_word_flags+=("--log-target")
     flags+=("--remote-first=")
@@ -683,6 +693,8 @@ _prog_config()
     two_word_flags+=("--log-format")
     flags+=("--log-level=")
     two_word_flags+=("--log-level")
+    flags+=("--log-subsystem-levels=")
+    two_word_flags+=("--log-subsystem-levels")
     flags+=("--log-target=")
     two_word_flags+=("--log-target")
     flags+=("--remote-first=")
@@ -744,6 +756,8 @@ _prog_version()
     two_word_flags+=("--log-format")
     flags+=("--log-level=")
     two_word_flags+=("--log-level")
+    flags+=("--log-subsystem-levels=")
+    two_word_flags+=("--log-subsystem-levels")
     flags+=("--log-target=")
     two_word_flags+=("--log-target")
     flags+=("--remote-first=")
@@ -816,6 +830,8 @@ _prog_root_command()
     two_word_flags+=("--log-format")
     flags+=("--log-level=")
     two_word_flags+=("--log-level")
+    flags+=("--log-subsystem-levels=")
+    two_word_flags+=("--log-subsystem-levels")
     flags+=("--log-target=")
     two_word_flags+=("--log-target")
     flags+=("--remote-first=")
@@ -30,6 +30,7 @@ Register-ArgumentCompleter -Native -CommandName 'prog' -ScriptBlock {
             [CompletionResult]::new('--log-file-path', 'log-file-path', [CompletionResultType]::ParameterName, 'Path to log file. Only required if --log-target is set to file.')
             [CompletionResult]::new('--log-format', 'log-format', [CompletionResultType]::ParameterName, 'Define default log format, one of: json, keyvalue, pretty.')
             [CompletionResult]::new('--log-level', 'log-level', [CompletionResultType]::ParameterName, 'Define minimum log level, one of: panic, fatal, error, warning, info, debug, trace.')
+            [CompletionResult]::new('--log-subsystem-levels', 'log-subsystem-levels', [CompletionResultType]::ParameterName, 'Override the minimum log level of subsystems, e.g. network=debug. Format: subsystem=level.')
             [CompletionResult]::new('--log-target', 'log-target', [CompletionResultType]::ParameterName, 'Define log output target, one of: stdout, stderr, file.')
             [CompletionResult]::new('--remote-first', 'remote-first', [CompletionResultType]::ParameterName, 'A flag which is in in a subtree')
             [CompletionResult]::new('--remote-second', 'remote-second', [CompletionResultType]::ParameterName, 'The other part of the subtree flag so we can see what this means')
@@ -60,6 +61,7 @@ Register-ArgumentCompleter -Native -CommandName 'prog' -ScriptBlock {
             [CompletionResult]::new('--log-file-path', 'log-file-path', [CompletionResultType]::ParameterName, 'Path to log file. Only required if --log-target is set to file.')
             [CompletionResult]::new('--log-format', 'log-format', [CompletionResultType]::ParameterName, 'Define default log format, one of: json, keyvalue, pretty.')
             [CompletionResult]::new('--log-level', 'log-level', [CompletionResultType]::ParameterName, 'Define minimum log level, one of: panic, fatal, error, warning, info, debug, trace.')
+            [CompletionResult]::new('--log-subsystem-levels', 'log-subsystem-levels', [CompletionResultType]::ParameterName, 'Override the minimum log level of subsystems, e.g. network=debug. Format: subsystem=level.')
             [CompletionResult]::new('--log-target', 'log-target', [CompletionResultType]::ParameterName, 'Define log output target, one of: stdout, stderr, file.')
             [CompletionResult]::new('--remote-first', 'remote-first', [CompletionResultType]::ParameterName, 'A flag which is in in a subtree')
             [CompletionResult]::new('--remote-second', 'remote-second', [CompletionResultType]::ParameterName, 'The other part of the subtree flag so we can see what this means')
@@ -91,6 +93,7 @@ Register-ArgumentCompleter -Native -CommandName 'prog' -ScriptBlock {
             [CompletionResult]::new('--log-file-path', 'log-file-path', [CompletionResultType]::ParameterName, 'Path to log file. Only required if --log-target is set to file.')
             [CompletionResult]::new('--log-format', 'log-format', [CompletionResultType]::ParameterName, 'Define default log format, one of: json, keyvalue, pretty.')
             [CompletionResult]::new('--log-level', 'log-level', [CompletionResultType]::ParameterName, 'Define minimum log level, one of: panic, fatal, error, warning, info, debug, trace.')
+            [CompletionResult]::new('--log-subsystem-levels', 'log-subsystem-levels', [CompletionResultType]::ParameterName, 'Override the minimum log level of subsystems, e.g. network=debug. Format: subsystem=level.')
             [CompletionResult]::new('--log-target', 'log-target', [CompletionResultType]::ParameterName, 'Define log output target, one of: stdout, stderr, file.')
             [CompletionResult]::new('--remote-first', 'remote-first', [CompletionResultType]::ParameterName, 'A flag which is in in a subtree')
             [CompletionResult]::new('--remote-second', 'remote-second', [CompletionResultType]::ParameterName, 'The other part of the subtree flag so we can see what this means')
@@ -119,6 +122,7 @@ Register-ArgumentCompleter -Native -CommandName 'prog' -ScriptBlock {
             [CompletionResult]::new('--log-file-path', 'log-file-path', [CompletionResultType]::ParameterName, 'Path to log file. Only required if --log-target is set to file.')
             [CompletionResult]::new('--log-format', 'log-format', [CompletionResultType]::ParameterName, 'Define default log format, one of: json, keyvalue, pretty.')
             [CompletionResult]::new('--log-level', 'log-level', [CompletionResultType]::ParameterName, 'Define minimum log level, one of: panic, fatal, error, warning, info, debug, trace.')
+            [CompletionResult]::new('--log-subsystem-levels', 'log-subsystem-levels', [CompletionResultType]::ParameterName, 'Override the minimum log level of subsystems, e.g. network=debug. Format: subsystem=level.')
             [CompletionResult]::new('--log-target', 'log-target', [CompletionResultType]::ParameterName, 'Define log output target, one of: stdout, stderr, file.')
             [CompletionResult]::new('--remote-first', 'remote-first', [CompletionResultType]::ParameterName, 'A flag which is in in a subtree')
             [CompletionResult]::new('--remote-second', 'remote-second', [CompletionResultType]::ParameterName, 'The other part of the subtree flag so we can see what this means')
@@ -147,6 +151,7 @@ Register-ArgumentCompleter -Native -CommandName 'prog' -ScriptBlock {
             [CompletionResult]::new('--log-file-path', 'log-file-path', [CompletionResultType]::ParameterName, 'Path to log file. Only required if --log-target is set to file.')
             [CompletionResult]::new('--log-format', 'log-format', [CompletionResultType]::ParameterName, 'Define default log format, one of: json, keyvalue, pretty.')
             [CompletionResult]::new('--log-level', 'log-level', [CompletionResultType]::ParameterName, 'Define minimum log level, one of: panic, fatal, error, warning, info, debug, trace.')
+            [CompletionResult]::new('--log-subsystem-levels', 'log-subsystem-levels', [CompletionResultType]::ParameterName, 'Override the minimum log level of subsystems, e.g. network=debug. Format: subsystem=level.')
             [CompletionResult]::new('--log-target', 'log-target', [CompletionResultType]::ParameterName, 'Define log output target, one of: stdout, stderr, file.')
             [CompletionResult]::new('--remote-first', 'remote-first', [CompletionResultType]::ParameterName, 'A flag which is in in a subtree')
             [CompletionResult]::new('--remote-second', 'remote-second', [CompletionResultType]::ParameterName, 'The other part of the subtree flag so we can see what this means')
@@ -173,6 +178,7 @@ Register-ArgumentCompleter -Native -CommandName 'prog' -ScriptBlock {
             [CompletionResult]::new('--log-file-path', 'log-file-path', [CompletionResultType]::ParameterName, 'Path to log file. Only required if --log-target is set to file.')
             [CompletionResult]::new('--log-format', 'log-format', [CompletionResultType]::ParameterName, 'Define default log format, one of: json, keyvalue, pretty.')
             [CompletionResult]::new('--log-level', 'log-level', [CompletionResultType]::ParameterName, 'Define minimum log level, one of: panic, fatal, error, warning, info, debug, trace.')
+            [CompletionResult]::new('--log-subsystem-levels', 'log-subsystem-levels', [CompletionResultType]::ParameterName, 'Override the minimum log level of subsystems, e.g. network=debug. Format: subsystem=level.')
             [CompletionResult]::new('--log-target', 'log-target', [CompletionResultType]::ParameterName, 'Define log output target, one of: stdout, stderr, file.')
             [CompletionResult]::new('--remote-first', 'remote-first', [CompletionResultType]::ParameterName, 'A flag which is in in a subtree')
             [CompletionResult]::new('--remote-second', 'remote-second', [CompletionResultType]::ParameterName, 'The other part of the subtree flag so we can see what this means')
@@ -200,6 +206,7 @@ Register-ArgumentCompleter -Native -CommandName 'prog' -ScriptBlock {
             [CompletionResult]::new('--log-file-path', 'log-file-path', [CompletionResultType]::ParameterName, 'Path to log file. Only required if --log-target is set to file.')
             [CompletionResult]::new('--log-format', 'log-format', [CompletionResultType]::ParameterName, 'Define default log format, one of: json, keyvalue, pretty.')
             [CompletionResult]::new('--log-level', 'log-level', [CompletionResultType]::ParameterName, 'Define minimum log level, one of: panic, fatal, error, warning, info, debug, trace.')
+            [CompletionResult]::new('--log-subsystem-levels', 'log-subsystem-levels', [CompletionResultType]::ParameterName, 'Override the minimum log level of subsystems, e.g. network=debug. Format: subsystem=level.')
             [CompletionResult]::new('--log-target', 'log-target', [CompletionResultType]::ParameterName, 'Define log output target, one of: stdout, stderr, file.')
             [CompletionResult]::new('--remote-first', 'remote-first', [CompletionResultType]::ParameterName, 'A flag which is in in a subtree')
             [CompletionResult]::new('--remote-second', 'remote-second', [CompletionResultType]::ParameterName, 'The other part of the subtree flag so we can see what this means')
@@ -226,6 +233,7 @@ Register-ArgumentCompleter -Native -CommandName 'prog' -ScriptBlock {
             [CompletionResult]::new('--log-file-path', 'log-file-path', [CompletionResultType]::ParameterName, 'Path to log file. Only required if --log-target is set to file.')
             [CompletionResult]::new('--log-format', 'log-format', [CompletionResultType]::ParameterName, 'Define default log format, one of: json, keyvalue, pretty.')
             [CompletionResult]::new('--log-level', 'log-level', [CompletionResultType]::ParameterName, 'Define minimum log level, one of: panic, fatal, error, warning, info, debug, trace.')
+            [CompletionResult]::new('--log-subsystem-levels', 'log-subsystem-levels', [CompletionResultType]::ParameterName, 'Override the minimum log level of subsystems, e.g. network=debug. Format: subsystem=level.')
             [CompletionResult]::new('--log-target', 'log-target', [CompletionResultType]::ParameterName, 'Define log output target, one of: stdout, stderr, file.')
             [CompletionResult]::new('--remote-first', 'remote-first', [CompletionResultType]::ParameterName, 'A flag which is in in a subtree')
             [CompletionResult]::new('--remote-second', 'remote-second', [CompletionResultType]::ParameterName, 'The other part of the subtree flag so we can see what this means')
@@ -252,6 +260,7 @@ Register-ArgumentCompleter -Native -CommandName 'prog' -ScriptBlock {
             [CompletionResult]::new('--log-file-path', 'log-file-path', [CompletionResultType]::ParameterName, 'Path to log file. Only required if --log-target is set to file.')
             [CompletionResult]::new('--log-format', 'log-format', [CompletionResultType]::ParameterName, 'Define default log format, one of: json, keyvalue, pretty.')
             [CompletionResult]::new('--log-level', 'log-level', [CompletionResultType]::ParameterName, 'Define minimum log level, one of: panic, fatal, error, warning, info, debug, trace.')
+            [CompletionResult]::new('--log-subsystem-levels', 'log-subsystem-levels', [CompletionResultType]::ParameterName, 'Override the minimum log level of subsystems, e.g. network=debug. Format: subsystem=level.')
             [CompletionResult]::new('--log-target', 'log-target', [CompletionResultType]::ParameterName, 'Define log output target, one of: stdout, stderr, file.')
             [CompletionResult]::new('--remote-first', 'remote-first', [CompletionResultType]::ParameterName, 'A flag which is in in a subtree')
             [CompletionResult]::new('--remote-second', 'remote-second', [CompletionResultType]::ParameterName, 'The other part of the subtree flag so we can see what this means')
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/automaticserver/lxe/cli"
+	"github.com/automaticserver/lxe/cri"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	adoptCmd.Flags().StringP("namespace", "", "default", "Kubernetes namespace of the pod")
+	adoptCmd.Flags().StringP("name", "", "", "Name of the pod, defaults to the name of the LXD container")
+	adoptCmd.Flags().StringP("manifest-dir", "", "", "Write the static pod manifest to this directory, usually kubelet's --pod-manifest-path. If empty, it's printed")
+
+	rootCmd.AddCommand(adoptCmd)
+}
+
+var adoptCmd = &cobra.Command{
+	Use:   "adopt LXD-CONTAINER",
+	Short: "Adopt an existing LXD container as pod managed by kubelet",
+	Long:  "Adopt marks an existing LXD container, which isn't managed by LXE, to be adopted by a pod and generates the static pod manifest for it. When kubelet creates the pod, LXE turns the container into the pod's container instead of creating a new one, keeping its profiles, devices and config. A running container is restarted once by that. The base image of the container must still exist in LXD.",
+	Args:  cobra.ExactArgs(1),
+	// don't start the signal handling of the daemon
+	Annotations: map[string]string{cli.AnnIsNonoperational: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+
+		namespace, err := flags.GetString("namespace")
+		if err != nil {
+			return err
+		}
+
+		name, err := flags.GetString("name")
+		if err != nil {
+			return err
+		}
+
+		if name == "" {
+			name = args[0]
+		}
+
+		dir, err := flags.GetString("manifest-dir")
+		if err != nil {
+			return err
+		}
+
+		rt, err := cri.NewAdminRuntimeServer(newConfig())
+		if err != nil {
+			return err
+		}
+
+		manifest, err := rt.AdoptPod(context.Background(), args[0], namespace, name)
+		if err != nil {
+			return err
+		}
+
+		if dir == "" {
+			_, err = cmd.OutOrStdout().Write(manifest)
+			return err
+		}
+
+		file := filepath.Join(dir, fmt.Sprintf("%s-%s.yaml", namespace, name))
+
+		err = ioutil.WriteFile(file, manifest, 0600)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "container %s is marked for adoption, wrote %s\n", args[0], file)
+
+		return nil
+	},
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/cli"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(annotationsCmd)
+}
+
+var annotationsCmd = &cobra.Command{
+	Use:         "annotations",
+	Short:       "List the pod annotations supported by LXE",
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{cli.AnnIsNonoperational: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+
+		fmt.Fprintf(w, "# schema version %d\n", annotation.SchemaVersion)
+		fmt.Fprintln(w, "NAME\tTYPE\tDEPRECATED NAMES\tDESCRIPTION")
+
+		for _, k := range annotation.All() {
+			name := k.Name
+			if k.IsPrefix {
+				name += "*"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, k.Type, strings.Join(k.Deprecated, ","), k.Description)
+		}
+
+		return w.Flush()
+	},
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/automaticserver/lxe/cli"
+	"github.com/automaticserver/lxe/cri"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(checkpointCmd)
+}
+
+var checkpointCmd = &cobra.Command{
+	Use:     "checkpoint [NAMESPACE/]POD/CONTAINER|CONTAINER-ID PATH",
+	Short:   "Checkpoint a running container of the running LXE into a tarball",
+	Long:    "Checkpoint saves the filesystem and the runtime state of the running container with a stateful snapshot, which requires CRIU, and writes it as LXD backup tarball to PATH, which must not exist yet. The container keeps running. The tarball is written by the running LXE, so PATH is on its host. It can be restored with lxc import. It requests the admin api, so the running LXE must have --admin-socket set.",
+	Example: `  lxe checkpoint default/nginx/nginx /var/lib/lxe/checkpoints/nginx.tar.gz`,
+	Args:    cobra.ExactArgs(2), // nolint: gomnd
+	// don't start the signal handling of the daemon
+	Annotations: map[string]string{cli.AnnIsNonoperational: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ref, err := commitRef(args[0])
+		if err != nil {
+			return err
+		}
+
+		location, err := filepath.Abs(args[1])
+		if err != nil {
+			return err
+		}
+
+		client, err := cri.NewAdminClient(newConfig().LXEAdminSocket)
+		if err != nil {
+			return err
+		}
+
+		err = client.CheckpointContainer(ref, location)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "Container %s checkpointed to %s\n", args[0], location)
+
+		return nil
+	},
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/automaticserver/lxe/cli"
+	"github.com/automaticserver/lxe/cri"
+	"github.com/spf13/cobra"
+)
+
+var ErrCommitTarget = errors.New("container must be given as [NAMESPACE/]POD/CONTAINER or its id")
+
+func init() {
+	commitCmd.Flags().StringToStringP("property", "p", nil, "Property of the image as key=value, can be repeated")
+	commitCmd.Flags().String("compression", "", "Compression algorithm of the image, e.g. gzip, xz, zstd or none (default of LXD if empty)")
+
+	rootCmd.AddCommand(commitCmd)
+}
+
+var commitCmd = &cobra.Command{
+	Use:   "commit [NAMESPACE/]POD/CONTAINER|CONTAINER-ID IMAGE",
+	Short: "Publish a container of the running LXE as image",
+	Long:  "Commit publishes the filesystem of the container as a new LXD image which pods can then use by the reference IMAGE, like an imported one. The container keeps running, the image is published from a temporary snapshot. It requests the admin api, so the running LXE must have --admin-socket set.",
+	Example: `  lxe commit default/nginx/nginx nginx-golden:1.0
+  lxe commit -p description="golden nginx" --compression zstd kube-system/my-pod/app app:golden`,
+	Args: cobra.ExactArgs(2), // nolint: gomnd
+	// don't start the signal handling of the daemon
+	Annotations: map[string]string{cli.AnnIsNonoperational: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		properties, err := cmd.Flags().GetStringToString("property")
+		if err != nil {
+			return err
+		}
+
+		compression, err := cmd.Flags().GetString("compression")
+		if err != nil {
+			return err
+		}
+
+		ref, err := commitRef(args[0])
+		if err != nil {
+			return err
+		}
+
+		client, err := cri.NewAdminClient(newConfig().LXEAdminSocket)
+		if err != nil {
+			return err
+		}
+
+		res, err := client.CommitContainer(ref, cri.AdminCommit{
+			Image:       args[1],
+			Properties:  properties,
+			Compression: compression,
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "Image %s committed from %s with fingerprint %s\n", res.Image, args[0], res.Fingerprint)
+
+		return nil
+	},
+}
+
+// commitRef returns the reference of the admin api for [namespace/]pod/container, anything without slash is passed as
+// is
+func commitRef(target string) (string, error) {
+	parts := strings.Split(target, "/")
+
+	switch len(parts) {
+	case 1:
+		return target, nil
+	case 2: // nolint: gomnd
+		parts = append([]string{defaultNamespace}, parts...)
+	case 3: // nolint: gomnd
+	default:
+		return "", ErrCommitTarget
+	}
+
+	for _, p := range parts {
+		if p == "" {
+			return "", ErrCommitTarget
+		}
+	}
+
+	return strings.Join(parts, "_"), nil
+}
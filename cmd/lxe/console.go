@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io"
+
+	"github.com/automaticserver/lxe/cli"
+	"github.com/automaticserver/lxe/cri"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	consoleCmd.Flags().IntP("lines", "n", 0, "Only print the last lines, all if 0")
+
+	rootCmd.AddCommand(consoleCmd)
+}
+
+var consoleCmd = &cobra.Command{
+	Use:   "console CONTAINER-ID|NAMESPACE_POD_CONTAINER",
+	Short: "Print the console log of a container of the running LXE",
+	Long:  "Console prints the console log LXD keeps of the container, e.g. the boot messages of its init. The log of a stopped container is kept until it's started again. It requests the admin api, so the running LXE must have --admin-socket set.",
+	Example: `  lxe console default_nginx_nginx
+  lxe console -n 20 abc`,
+	Args: cobra.ExactArgs(1),
+	// don't start the signal handling of the daemon
+	Annotations: map[string]string{cli.AnnIsNonoperational: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		lines, err := cmd.Flags().GetInt("lines")
+		if err != nil {
+			return err
+		}
+
+		client, err := cri.NewAdminClient(newConfig().LXEAdminSocket)
+		if err != nil {
+			return err
+		}
+
+		r, err := client.ReadConsole(args[0], lines)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		_, err = io.Copy(cmd.OutOrStdout(), r)
+
+		return err
+	},
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/automaticserver/lxe/cli"
+	"github.com/automaticserver/lxe/cri"
+	"github.com/spf13/cobra"
+)
+
+// defaultNamespace is assumed if a pod is given without namespace
+const defaultNamespace = "default"
+
+var (
+	ErrCopyUsage         = errors.New("exactly one of source and destination must be in a container")
+	ErrContainerNotFound = errors.New("container not found")
+	ErrAmbiguousPod      = errors.New("pod has several containers, choose one with --container")
+	ErrUnsafeEntry       = errors.New("archive entry is outside of the destination")
+)
+
+func init() {
+	cpCmd.Flags().StringP("container", "c", "", "Name of the container in the pod, required if it has several")
+
+	rootCmd.AddCommand(cpCmd)
+}
+
+var cpCmd = &cobra.Command{
+	Use:   "cp SOURCE DESTINATION",
+	Short: "Copy files and directories into or out of a container of the running LXE",
+	Long:  "Cp copies a file or directory with everything below it between this host and a container, like cp -r to a destination which doesn't exist yet: SOURCE is created as DESTINATION. The container side is [NAMESPACE/]POD:PATH or CONTAINER-ID:PATH with an absolute path. Unlike kubectl cp it uses the LXD file api, so the image doesn't need tar. Ownership and modes are kept. It requests the admin api, so the running LXE must have --admin-socket set.",
+	Example: `  lxe cp ./site default/nginx:/usr/share/nginx/html
+  lxe cp -c app kube-system/my-pod:/var/log/app.log app.log`,
+	Args: cobra.ExactArgs(2), // nolint: gomnd
+	// don't start the signal handling of the daemon
+	Annotations: map[string]string{cli.AnnIsNonoperational: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, err := cmd.Flags().GetString("container")
+		if err != nil {
+			return err
+		}
+
+		srcTarget, srcPath, srcRemote := parseCopySpec(args[0])
+		dstTarget, dstPath, dstRemote := parseCopySpec(args[1])
+
+		if srcRemote == dstRemote {
+			return ErrCopyUsage
+		}
+
+		client, err := cri.NewAdminClient(newConfig().LXEAdminSocket)
+		if err != nil {
+			return err
+		}
+
+		if srcRemote {
+			id, err := resolveContainer(client, srcTarget, name)
+			if err != nil {
+				return err
+			}
+
+			archive, err := client.ReadFiles(id, srcPath)
+			if err != nil {
+				return err
+			}
+			defer archive.Close()
+
+			return extractLocal(archive, dstPath)
+		}
+
+		id, err := resolveContainer(client, dstTarget, name)
+		if err != nil {
+			return err
+		}
+
+		r, w := io.Pipe()
+
+		go func() {
+			w.CloseWithError(tarLocal(w, srcPath))
+		}()
+
+		return client.WriteFiles(id, dstPath, r)
+	},
+}
+
+// parseCopySpec splits the argument into the container and the path if it's in the form target:path. Paths starting
+// with / or . are always local
+func parseCopySpec(spec string) (string, string, bool) {
+	i := strings.Index(spec, ":")
+	if i <= 0 || strings.HasPrefix(spec, "/") || strings.HasPrefix(spec, ".") {
+		return "", spec, false
+	}
+
+	return spec[:i], spec[i+1:], true
+}
+
+// resolveContainer returns the id of the container, target is a container id or a pod in the form [namespace/]pod
+// whose container is chosen by name, which may be empty if the pod has only one
+func resolveContainer(client *cri.AdminClient, target, name string) (string, error) {
+	cl, err := client.ListContainers()
+	if err != nil {
+		return "", err
+	}
+
+	for _, c := range cl {
+		if c.ID == target {
+			return c.ID, nil
+		}
+	}
+
+	sbs, err := client.ListSandboxes()
+	if err != nil {
+		return "", err
+	}
+
+	namespace, pod := defaultNamespace, target
+	if i := strings.Index(target, "/"); i >= 0 {
+		namespace, pod = target[:i], target[i+1:]
+	}
+
+	return chooseContainer(sbs, cl, namespace, pod, name)
+}
+
+// chooseContainer returns the id of the container of the pod with the name, or the only one if name is empty
+func chooseContainer(sbs []cri.AdminSandbox, cl []cri.AdminContainer, namespace, pod, name string) (string, error) {
+	sandboxID := ""
+
+	for _, sb := range sbs {
+		if sb.Namespace == namespace && sb.Name == pod {
+			sandboxID = sb.ID
+		}
+	}
+
+	if sandboxID == "" {
+		return "", fmt.Errorf("%w: pod %s/%s", ErrContainerNotFound, namespace, pod)
+	}
+
+	found := []string{}
+
+	for _, c := range cl {
+		if c.SandboxID == sandboxID && (name == "" || c.Name == name) {
+			found = append(found, c.ID)
+		}
+	}
+
+	switch {
+	case len(found) == 0:
+		return "", fmt.Errorf("%w: %q in pod %s/%s", ErrContainerNotFound, name, namespace, pod)
+	case len(found) > 1:
+		return "", fmt.Errorf("%w: %s/%s", ErrAmbiguousPod, namespace, pod)
+	}
+
+	return found[0], nil
+}
+
+// tarLocal writes the file or directory at src with everything below it as tarball, src itself is named "."
+func tarLocal(w io.Writer, src string) error {
+	tw := tar.NewWriter(w)
+
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err = os.Readlink(p)
+			if err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+
+		hdr.Name = filepath.ToSlash(name)
+
+		err = tw.WriteHeader(hdr)
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// extractLocal creates the files, symlinks and directories of the tarball at dst, the entry named "." is dst itself.
+// Ownership is only kept if permitted
+func extractLocal(r io.Reader, dst string) error {
+	dst = filepath.Clean(dst)
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		target, err := localTarget(dst, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := os.FileMode(hdr.Mode).Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, mode)
+		case tar.TypeSymlink:
+			err = os.Symlink(hdr.Linkname, target)
+		case tar.TypeReg:
+			err = writeLocalFile(target, mode, tr)
+		default:
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// like cp, the ownership is kept if permitted
+		_ = os.Lchown(target, hdr.Uid, hdr.Gid)
+	}
+}
+
+// localTarget returns where the entry is created below dst. The entry must neither leave dst nor pass a symlink, as
+// the archive is created from the files of the container
+func localTarget(dst, name string) (string, error) {
+	name = path.Clean(name)
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("%w: %s", ErrUnsafeEntry, name)
+	}
+
+	target := filepath.Join(dst, filepath.FromSlash(name))
+
+	for p := filepath.Dir(target); len(p) > len(dst); p = filepath.Dir(p) {
+		fi, err := os.Lstat(p)
+		if err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("%w: %s", ErrUnsafeEntry, name)
+		}
+	}
+
+	// an existing symlink is replaced instead of followed
+	if fi, err := os.Lstat(target); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		err = os.Remove(target)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return target, nil
+}
+
+func writeLocalFile(target string, mode os.FileMode, r io.Reader) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
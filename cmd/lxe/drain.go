@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/automaticserver/lxe/cli"
+	"github.com/automaticserver/lxe/cri"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	drainCmd.Flags().IntP("timeout", "", 30, "Seconds the containers have to shut down gracefully before they're killed") // nolint: gomnd
+	drainCmd.Flags().StringP("snapshot", "", "", "Take a stateful snapshot with this name of every running container before stopping it, requires CRIU. If empty, no snapshots are taken")
+
+	rootCmd.AddCommand(drainCmd)
+}
+
+var drainCmd = &cobra.Command{
+	Use:   "drain",
+	Short: "Stop all pods on this node for maintenance",
+	Long:  "Drain stops the containers of all pods located on the LXD (cluster member) LXE is connected to, marks the pods as not ready and tears down their networks, like kubelet does when it stops a pod. Use it for host maintenance when kubelet is already down, once it's back it recreates the pods. Use the same configuration as the running LXE.",
+	Args:  cobra.NoArgs,
+	// don't start the signal handling of the daemon
+	Annotations: map[string]string{cli.AnnIsNonoperational: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+
+		timeout, err := flags.GetInt("timeout")
+		if err != nil {
+			return err
+		}
+
+		snapshot, err := flags.GetString("snapshot")
+		if err != nil {
+			return err
+		}
+
+		rt, err := cri.NewAdminRuntimeServer(newConfig())
+		if err != nil {
+			return err
+		}
+
+		drained, err := rt.DrainNode(context.Background(), cri.DrainOptions{Timeout: timeout, Snapshot: snapshot})
+
+		fmt.Fprintf(cmd.OutOrStdout(), "drained %d pods\n", len(drained))
+
+		return err
+	},
+}
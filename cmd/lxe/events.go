@@ -0,0 +1,38 @@
+package main
+
+import (
+	"io"
+
+	"github.com/automaticserver/lxe/cli"
+	"github.com/automaticserver/lxe/cri"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(eventsCmd)
+}
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Follow the lifecycle events of the containers of the running LXE",
+	Long:  "Events prints the containers being created, started, stopped and deleted as JSON lines while they happen, like the GetContainerEvents RPC of newer CRI versions. A stopped event is also printed if the container exited by itself. If it can't keep up, LXE ends the stream, so list the containers again before following anew. It requests the admin api, so the running LXE must have --admin-socket set.",
+	Args:  cobra.NoArgs,
+	// don't start the signal handling of the daemon
+	Annotations: map[string]string{cli.AnnIsNonoperational: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := cri.NewAdminClient(newConfig().LXEAdminSocket)
+		if err != nil {
+			return err
+		}
+
+		r, err := client.WatchEvents()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		_, err = io.Copy(cmd.OutOrStdout(), r)
+
+		return err
+	},
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/automaticserver/lxe/cli"
+	"github.com/automaticserver/lxe/cri"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import IMAGE TARBALL|DIRECTORY [ROOTFS]",
+	Short: "Import an image from local files into the image store of the running LXE",
+	Long:  "Import creates the image from a unified LXD image tarball, the metadata and rootfs tarballs of a split image or a directory with metadata.yaml and rootfs of an unpacked image, without a remote image server. The image gets the alias of IMAGE, so pods find it by that image reference like a pulled one. The files are read by the running LXE, so they must be accessible on its host. It requests the admin api, so the running LXE must have --admin-socket set.",
+	Args:  cobra.RangeArgs(2, 3), // nolint: gomnd
+	// don't start the signal handling of the daemon
+	Annotations: map[string]string{cli.AnnIsNonoperational: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := cri.NewAdminClient(newConfig().LXEAdminSocket)
+		if err != nil {
+			return err
+		}
+
+		req := cri.AdminImageImport{Image: args[0]}
+
+		req.Path, err = filepath.Abs(args[1])
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 3 { // nolint: gomnd
+			req.Rootfs, err = filepath.Abs(args[2])
+			if err != nil {
+				return err
+			}
+		}
+
+		res, err := client.ImportImage(req)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "imported %s as %s\n", res.Image, res.Fingerprint)
+
+		return nil
+	},
+}
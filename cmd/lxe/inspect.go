@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/automaticserver/lxe/cli"
+	"github.com/automaticserver/lxe/cri"
+	"github.com/automaticserver/lxe/shared"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	inspectCmd.Flags().Bool("lxd", false, "Print the LXD container or profile as LXD returns it instead")
+
+	rootCmd.AddCommand(inspectCmd)
+}
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect CONTAINER-ID|POD-ID|NAMESPACE_POD_CONTAINER|NAMESPACE_POD",
+	Short: "Show the state of a container or pod of the running LXE",
+	Long:  "Inspect prints the state of the container or pod as LXE stores it in LXD as JSON: its profiles, storage pool, network data, the netns path of a running container and the last failed CRI call. With --lxd the full LXD container or profile is printed. It requests the admin api, so the running LXE must have --admin-socket set.",
+	Args:  cobra.ExactArgs(1),
+	// don't start the signal handling of the daemon
+	Annotations: map[string]string{cli.AnnIsNonoperational: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := cri.NewAdminClient(newConfig().LXEAdminSocket)
+		if err != nil {
+			return err
+		}
+
+		raw, err := cmd.Flags().GetBool("lxd")
+		if err != nil {
+			return err
+		}
+
+		var v interface{}
+
+		if raw {
+			v, err = client.GetLXDContainer(args[0])
+			if err != nil && shared.IsErrNotFound(err) {
+				v, err = client.GetLXDProfile(args[0])
+			}
+		} else {
+			v, err = client.GetContainer(args[0])
+			if err != nil && shared.IsErrNotFound(err) {
+				v, err = client.GetSandbox(args[0])
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(v)
+	},
+}
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/automaticserver/lxe/annotation"
 	"github.com/automaticserver/lxe/cli"
 	"github.com/automaticserver/lxe/cri"
+	"github.com/automaticserver/lxe/lxf/lxo"
 	"github.com/automaticserver/lxe/network"
 	"github.com/dionysius/errand"
 	"github.com/sirupsen/logrus"
@@ -28,46 +35,248 @@ func init() {
 
 	// application flags
 	pflags.StringP("socket", "s", "/run/lxe.sock", "Path of the socket where it should provide the runtime and image service to kubelet.")
+	pflags.StringP("socket-mode", "", "0660", "Octal file mode of the socket defined in --socket.")
+	pflags.StringP("socket-owner", "", "", "Owner of the socket defined in --socket in the form [user][:group]. User and group are names or ids. If empty, the owner is kept.")
+	pflags.StringP("tcp-bindaddr", "", "", "Additional listen address to provide the runtime and image service to remote kubelets over TCP. Requires --tcp-tls-cert, --tcp-tls-key and --tcp-tls-client-ca. If empty, only the socket is served. Format: [IP]:Port.")
+	pflags.StringP("tcp-tls-cert", "", "", "Path of the certificate to serve the TCP listener with.")
+	pflags.StringP("tcp-tls-key", "", "", "Path of the key to serve the TCP listener with.")
+	pflags.StringP("tcp-tls-client-ca", "", "", "Path of the CA certificates. Only clients of the TCP listener with a certificate signed by them are accepted.")
+	pflags.Uint32P("grpc-max-concurrent-streams", "", 0, "Limit the concurrent calls per client connection. If 0, they're not limited.")
+	pflags.DurationP("grpc-keepalive-min-time", "", 0, "Disconnect clients which send keepalive pings more often. If 0, the gRPC default of 5m is used.")
+	pflags.DurationP("grpc-keepalive-time", "", 0, "Ping clients after this idle duration and disconnect them if they don't answer. If 0, the gRPC default of 2h is used.")
+	pflags.IntP("grpc-max-concurrent-calls", "", 0, "Limit the concurrent calls per CRI method of all clients, so bursts of kubelet don't overwhelm LXD. Waiting calls of different pods take turns. Version and Status are never limited. If 0, they're not limited.")
+	pflags.StringSliceP("grpc-method-concurrency", "", []string{}, "Replace --grpc-max-concurrent-calls for single CRI methods. Format: method=limit, e.g. ListContainers=4. A limit of 0 doesn't limit the method.")
+	pflags.Float64P("grpc-rate-limit", "", 0, "Limit the CRI calls per second of all clients. Calls wait for their turn until their deadline. If 0, they're not limited.")
+	pflags.IntP("grpc-rate-burst", "", 10, "Number of CRI calls which may exceed --grpc-rate-limit at once.") // nolint: gomnd
 	pflags.StringP("lxd-socket", "l", "/var/lib/lxd/unix.socket", "Path of the socket where LXD provides it's API.")
 	pflags.StringP("lxd-remote-config", "r", "", "Path to the LXD remote config. (guessed by default)")
+	// the in-memory LXD is only in builds with the lxdfake build tag
+	if cri.LXDFakeSupported {
+		pflags.BoolP("lxd-fake", "", false, "Use an in-memory LXD instead of --lxd-socket, to run the CRI validation suite (critest) without LXD. Containers run no processes, exec only knows a few shell builtins and every image exists. Only for testing.")
+		_ = pflags.MarkHidden("lxd-fake")
+	}
 	pflags.StringP("lxd-image-remote", "", "local", "Use this remote if ImageSpec doesn't provide an explicit remote.")
+	pflags.StringP("image-cache-remote", "", "", "Remote of the LXD client config images are pulled from first, e.g. the simplestreams remote of another node's --image-cache-bindaddr. Images are found there by fingerprint after resolving them on their own remote, if it doesn't have them or fails, they're pulled from their own remote. If empty, images are always pulled from their own remote.")
+	pflags.StringP("image-cache-dir", "", "", "Directory pulled images are exported to, to serve them to other nodes with --image-cache-bindaddr. Images removed with RemoveImage are removed from it as well. If empty, images aren't exported.")
+	pflags.StringSliceP("image-cache-remotes", "", []string{}, "Remotes of the LXD client config whose pulled images are exported to --image-cache-dir, entries ending with * match as prefix. Everyone reaching --image-cache-bindaddr can download the exported images, so only list remotes with public images. If empty, no images are exported.")
+	pflags.StringP("image-cache-bindaddr", "", "", "Listen address to serve --image-cache-dir as simplestreams remote, which other nodes add to their LXD client config and use as --image-cache-remote. If empty, the image cache isn't served. Format: [IP]:Port.")
+	pflags.StringP("image-policy", "", "", "Path of a YAML file restricting which images may be pulled, with the allowed images in the form remote/alias as 'allow' (entries ending with * match as prefix) and 'requireDigest' to only allow pulls by digest. It's read again on reload. If empty, all images may be pulled.")
 	pflags.StringSliceP("lxd-profiles", "p", []string{"default"}, "Set these additional profiles when creating containers.")
+	pflags.StringP("lxd-project", "", "", "LXD project in which the pods, containers and images are created. It's created with its own images and profiles if it doesn't exist, its default profile is copied from the default project. If empty, the default project is used.")
+	pflags.StringSliceP("lxd-project-limits", "", []string{}, "Limits which are set on --lxd-project whenever LXE starts. Format: limits.<key>=value, e.g. limits.containers=100 or limits.memory=64GB.")
+	pflags.StringP("owner", "", "", "Name of this LXE instance, e.g. the node name, if several LXE instances front the same LXD (cluster). Pods are created with this owner and each instance only sees its own pods. If empty, all pods are seen, including those of other instances.")
+	pflags.StringP("name-prefix", "", "", "Prefix of the LXD names of created pods and containers, which are made of the CRI names shortened to the LXD name length and a hash. Must start with a lowercase letter and contain only lowercase letters, digits and hyphens, e.g. 'k8s-'.")
+	pflags.StringP("lxd-target", "", "", "If LXD is clustered, create containers on this cluster member. 'self' is the member LXE is connected to. If empty, LXD chooses. The pod annotation 'lxe.automaticserver.ch/target-member' has priority.")
+	pflags.IntP("lxd-operation-workers", "", lxo.DefaultWorkers, "How many LXD operations run concurrently when all containers of a pod are stopped or deleted, e.g. during node drains.")
+	pflags.DurationP("lxd-operation-timeout", "", 0, "Cancel a single LXD operation after this duration and report it as failed. Must be longer than the slowest expected operation, like an image download. If 0, operations are awaited till they're done.")
+	pflags.IntP("lxd-operation-retries", "", lxo.DefaultRetries, "How often a LXD operation is retried if it failed temporarily, e.g. because LXD was busy with the same container or the connection dropped. If 0, operations are not retried.")
+	pflags.DurationP("lxd-operation-retry-backoff", "", lxo.DefaultRetryBackoff, "Wait this long before the first retry of a LXD operation. It doubles with every further retry and is jittered.")
+	pflags.StringSliceP("runtime-handler-pools", "", []string{}, "Create the root disks of pods with a runtime handler on a LXD storage pool, so a RuntimeClass can select the pool. Format: handler=pool. The pod annotation 'lxe.automaticserver.ch/storage-pool' has priority. If neither is set, the root disk of the profiles is used.")
+	pflags.StringSliceP("runtime-handlers", "", []string{}, "Define presets for pods with a runtime handler, so a RuntimeClass selects them. Format: handler.option=value. Options: 'profiles' replaces --lxd-profiles and can be repeated to apply several profiles in order, 'pool' creates the root disks on this LXD storage pool and has priority over --runtime-handler-pools, 'privileged' and 'nesting' set security.privileged and security.nesting for all containers of the pod, 'overhead-cpu' and 'overhead-memory' raise the limits of the containers by the overhead of the RuntimeClass, e.g. 250m and 64Mi. 'ulimit-nofile', 'ulimit-memlock' and 'ulimit-nproc' are the default kernel resource limits of the containers as soft:hard, e.g. unlimited or 1024:65536. If set, pods with other runtime handlers are rejected.")
+	pflags.StringP("lxd-scratch-pool", "", "", "Place disk backed emptyDir volumes of pods as custom volumes on this LXD storage pool, e.g. a pool on fast local NVMe. The volumes are deleted with the pod. If empty, emptyDirs stay in the kubelet directory on the host.")
+	pflags.StringSliceP("lxd-volume-pools", "", []string{}, "LXD storage pools on which custom volumes may be requested with the pod annotation 'lxe.automaticserver.ch/volumes'. Entries ending with '*' match all pools with that prefix. If empty, no volumes can be requested. Pods requesting volumes on other pools are rejected.")
 	pflags.StringP("streaming-bindaddr", "", ":44124", "Listen address for the streaming service. Be careful from where this service can be accessed from as it allows to run exec commands on the containers! Format: [IP]:Port.")
 	pflags.StringP("streaming-baseurl", "", "", "Define which base address to use for constructing streaming URLs for a client to connect to. If this is set to empty, it will use the same host address and port from --streaming-bindaddr. If that has an empty host address, it will obtain the address of the interface to the default gateway. Format: [IP][:Port].")
+	pflags.StringP("streaming-tls-cert", "", "", "Path to the certificate to serve the streaming service with TLS, the streaming URLs use https then. It's loaded again when the file changes, so it can be rotated without restarting.")
+	pflags.StringP("streaming-tls-key", "", "", "Path to the key of --streaming-tls-cert.")
+	pflags.DurationP("streaming-creation-timeout", "", 30*time.Second, "How long a client may take to create the streams of an exec, attach or port forward session.")
+	pflags.DurationP("streaming-idle-timeout", "", 4*time.Hour, "End exec and port forward sessions if no data was transferred for this duration, e.g. because the client vanished without closing. If 0, sessions never idle out.")
+	pflags.DurationP("streaming-max-duration", "", 0, "End exec and port forward sessions after this duration. If 0, sessions are not limited.")
+	pflags.StringP("metrics-bindaddr", "", "", "Listen address for the prometheus metrics on /metrics. If empty, the metrics server is disabled. Format: [IP]:Port.")
+	pflags.StringP("metrics-tls-cert", "", "", "Path of the certificate to serve the metrics with TLS. Requires --metrics-tls-key.")
+	pflags.StringP("metrics-tls-key", "", "", "Path of the key to serve the metrics with TLS. Requires --metrics-tls-cert.")
+	pflags.StringP("health-bindaddr", "", "", "Listen address for the health endpoints: /healthz succeeds if LXD is reachable, /readyz additionally if the network plugin is ready, e.g. the CNI config is loaded, and the CRI socket is serving. If empty, the health server is disabled. Format: [IP]:Port.")
+	pflags.BoolP("health-pprof", "", false, "Serve the Go profiling endpoints on /debug/pprof/ of --health-bindaddr. Be careful from where they can be accessed from, as they expose the internals of the process!")
+	pflags.StringP("tracing-endpoint", "", "", "Export OpenTelemetry spans of the CRI calls, LXD operations and CNI invocations to this OTLP/HTTP collector. If empty, spans are not exported, but a trace context propagated by kubelet is still logged. Format: Host:Port.")
+	pflags.BoolP("tracing-insecure", "", false, "Export the spans to --tracing-endpoint without TLS.")
+	pflags.Float64P("tracing-sample-ratio", "", 1, "Fraction of the traces which are exported, between 0 and 1. Calls whose caller already sampled them are always exported.")
+	pflags.StringP("admin-socket", "", "", "Path of the socket where the admin api to manage container snapshots is provided. Everyone with access to it can modify all containers! If empty, the admin api is disabled.")
+	pflags.StringP("audit-log", "", "", "Path of the file the lifecycle actions on containers are logged to as JSON lines, with who requested them: the uid, gid and pid of the CRI client, the common name of its client certificate, or lxe itself. The last action of each kind is also recorded in the LXD config keys 'user.audit.<action>' of the container. If empty, only the config keys are recorded.")
+	pflags.IntP("audit-log-max-size", "", cri.DefaultAuditLogMaxSize, "Size in megabytes at which the --audit-log is rotated. If 0, it's never rotated.")
+	pflags.IntP("audit-log-max-backups", "", cri.DefaultAuditLogMaxBackups, "How many rotated audit logs are kept, as --audit-log with the suffix .1, .2 and so on.")
+	pflags.StringP("attest-socket", "", "", "Path of the socket where the workload attestation api is provided. It resolves which pod and container a process belongs to, e.g. for SPIRE workload attestors. If empty, the attestation api is disabled.")
 	// TODO: I was thinking, can't we just create a tmpfile with those contents when running lxe and remember that? Maybe, but it must be a persistent location, otherwise containers won't be able to start without that file existing.
 	pflags.StringP("hostnetwork-file", "", "", "EXPERIMENTAL! If host networking is defined in the PodSpec, this persisting file will be set as include in raw.lxc container config. (This process is required to workaround LXD, since it doesn't offer such option in the container or device config out of the box). The file must contain: 'lxc.net.0.type=none'.")
+	pflags.StringSliceP("sysctl-allowlist", "", cri.DefaultSysctlAllowlist, "Sysctls which are allowed to be set in the pod securityContext. Entries ending with '*' match all sysctls with that prefix. Pods requesting other sysctls are rejected.")
+	pflags.StringSliceP("config-allowlist", "", []string{}, "LXD config keys which are allowed to be set with the pod or container annotation 'lxe.automaticserver.ch/config.<key>', e.g. security.nesting. Entries ending with '*' match all keys with that prefix. If empty, no keys can be set. Pods requesting other keys are rejected.")
+	pflags.StringSliceP("config-denylist", "", cri.DefaultConfigDenylist, "LXD config keys which can never be set with annotations, even if they match --config-allowlist. Entries ending with '*' match all keys with that prefix.")
+	pflags.StringP("memory-swap", "", "", "Whether containers may swap, one of: true, false. The pod annotation 'lxe.automaticserver.ch/memory-swap' has priority. If empty, the LXD default is used.")
+	pflags.StringP("memory-enforce", "", "", "How the memory limits of containers are enforced, one of: hard, soft. 'soft' only enforces them under host memory pressure. The pod annotation 'lxe.automaticserver.ch/memory-enforce' has priority. If empty, the LXD default is used.")
+	pflags.Int64P("pod-pids-limit", "", 0, "Maximum number of processes in each container, set it to kubelet's podPidsLimit as kubelet can't enforce it on LXD containers. The pod annotation 'lxe.automaticserver.ch/pids-limit' has priority. If 0, the number of processes is not limited.")
+	pflags.StringSliceP("readonly-rootfs-tmpfs", "", cri.DefaultReadonlyRootfsTmpfs, "Paths a writable tmpfs is mounted on in containers with a readonly root filesystem, as most images expect them to be writable. Paths missing in the image are skipped.")
+	pflags.StringP("hooks-dir", "", "", "Directory of JSON files defining executables run on the host at lifecycle stages of pods and containers, with their state on stdin. Failing pre-create hooks reject the creation. The directory is read again on reload. If empty, no hooks are run.")
+	pflags.StringP("validation", "", cri.ValidationWarn, "What happens to pods and containers requesting settings LXE doesn't support, like SELinux options or custom AppArmor profiles, one of: strict, warn. 'strict' rejects them with the unsupported settings as error, 'warn' logs them and ignores the settings. Invalid settings LXD would fail on, like relative mount paths, are always rejected.")
+	pflags.StringP("shift-mode", "", cri.ShiftModeNever, "When to mount host paths with shift=true into unprivileged containers, one of: auto, always, never. 'auto' shifts if LXD reports shiftfs or idmapped mount support and otherwise warns once at startup.")
+	pflags.BoolP("shift-kubelet-volumes", "", false, "Mount configmap, secret, downwardAPI and projected volumes (e.g. the service account token) with shift=true into unprivileged containers, so they are readable despite the uid shifting. Requires shiftfs or idmapped mount support in LXD.")
+	pflags.StringP("cgroup-driver", "", "", "Place the cgroups of the containers below the pod cgroup kubelet manages, so pod level QoS, eviction and accounting of kubelet include them. Must match the --cgroup-driver of kubelet, one of: cgroupfs, systemd. If empty, LXD places the cgroups.")
+	pflags.StringP("container-mode", "", annotation.ContainerModeSystem, "Default mode of containers, one of: system, application. 'system' boots the image's init, or runs the container command instead of it. 'application' runs the container command as single process, stops the container when it exits and reports its exit code. The pod annotation 'lxe.automaticserver.ch/container-mode' has priority.")
+	pflags.BoolP("namespace-sharing", "", false, "EXPERIMENTAL! Share the pid and ipc namespaces between the containers of a pod as requested by kubelet, e.g. for shareProcessNamespace or ephemeral debug containers targeting a container. Containers sharing them with the pod join the running container which started first. If it stops, the containers sharing its pid namespace are killed. Unprivileged containers also join its user namespace, so they must have the same idmap (security.idmap.isolated=false). If disabled, every container has its own namespaces.")
+	pflags.StringP("kubernetes-service-env", "", "", "Address (host:port) of the kubernetes service. If set, the environment variables of the service like KUBERNETES_SERVICE_HOST are added to every container if kubelet didn't set them, e.g. because it runs standalone or its pods start before the service is known.")
+	pflags.Float64P("eviction-psi-threshold", "", 0, "EXPERIMENTAL! Host memory pressure in percent (full avg10 of /proc/pressure/memory) above which the pod with the lowest priority is evicted before the kernel OOM killer picks critical processes. The priority is taken from the pod annotation 'lxe.automaticserver.ch/eviction-priority' or the QoS class. If 0, the eviction guard is disabled.")
+	pflags.StringP("eviction-action", "", cri.EvictionActionStop, "What happens to the containers of an evicted pod, one of: freeze, stop. Frozen pods are thawed once the memory pressure is below the threshold again.")
+	pflags.DurationP("eviction-interval", "", 10*time.Second, "How often the host memory pressure is checked. At most one pod is evicted per interval.")
+	pflags.DurationP("orphan-grace-period", "", 10*time.Minute, "How long a container whose sandbox is missing, e.g. after a crash while removing its pod, is kept before its network is torn down and it's deleted. If 0, orphaned containers are not deleted.")
+	pflags.DurationP("orphan-interval", "", time.Minute, "How often LXE looks for orphaned containers, it also looks once at startup.")
+	pflags.StringP("state-file", "", "", "File the in-memory state, like when orphaned containers and network namespaces were first seen, is saved to on shutdown and restored from at startup, so a restart doesn't reset their grace period. If empty, the state isn't saved.")
+	pflags.StringP("journal-file", "", "", "File the CRI calls creating, starting, stopping and removing pods and containers are journaled to before they change anything. At startup, the calls interrupted by a crash are resolved before the CRI is served: pods and containers being created are rolled back, stopping and removing is done again and the LXD operations still running are waited for. If empty, the calls aren't journaled.")
+	pflags.DurationP("shutdown-timeout", "", 30*time.Second, "How long LXE waits on SIGTERM or SIGINT for the running CRI calls, LXD operations and background tasks before it stops forcefully and exits with code 2. A second signal forces it right away.") // nolint: gomnd
+	pflags.DurationP("quota-interval", "", time.Minute, "How often LXE sums the cpu requests and the cpu and memory limits of its containers and compares them with the node allocatable. The result is exposed as metrics and in the verbose runtime status, a warning is logged if the node is overcommitted in a way kubelet doesn't account for. If 0, it's disabled.")
+	pflags.StringP("node-allocatable-cpu", "", "", "Allocatable cpu of the node as kubelet reports it, e.g. 7500m, to compare the containers with. If empty, the cpus of the LXD server are used.")
+	pflags.StringP("node-allocatable-memory", "", "", "Allocatable memory of the node as kubelet reports it, e.g. 30Gi, to compare the containers with. If empty, the memory of the LXD server is used.")
 	pflags.StringP("network-plugin", "n", "bridge", "The network plugin to use. 'bridge' manages the lxd bridge defined in --bridge-name. 'cni' uses kubernetes cni tools to attach interfaces using configuration defined in --cni-conf-dir.")
+	pflags.IntP("network-mtu", "", 0, "MTU of the pod interface, e.g. to make room for encapsulation of nested workloads. If 0, the default of the network plugin is used.")
+	pflags.BoolP("network-disable-tx-checksum", "", false, "Disable tx checksum offloading on the pod interface, a common fix for nested docker or vpn inside containers. Requires nsenter and ethtool on the host.")
 	pflags.StringP("bridge-name", "", network.DefaultLXDBridge, "Which bridge to create and use when using --network-plugin 'bridge'.")
 	pflags.StringP("bridge-dhcp-range", "", "", "Which DHCP range to configure the lxd bridge when using --network-plugin 'bridge'. If empty, uses random range provided by lxd. Not needed, if kubernetes will publish the range using CRI UpdateRuntimeconfig.")
+	pflags.StringSliceP("bridge-vlans", "", []string{}, "Tag the pod nics of a kubernetes namespace with a VLAN ID when using --network-plugin 'bridge'. Format: namespace=vlan. The pod annotation 'lxe.automaticserver.ch/vlan' has priority.")
+	pflags.StringP("bridge-dns-domain", "", "", "Enable the dns of the bridge when using --network-plugin 'bridge' and register the pods as <name>.<namespace>.<domain>. Point the kubelet --cluster-dns to the bridge address and --cluster-domain to this domain.")
 	pflags.StringP("cni-conf-dir", "", network.DefaultCNIconfPath, "Dir in which to search for CNI configuration files when using --network-plugin 'cni'.")
 	pflags.StringP("cni-bin-dir", "", network.DefaultCNIbinPath, "Dir in which to search for CNI plugin binaries when using --network-plugin 'cni'.")
+	pflags.StringP("cni-netns-path", "", network.DefaultCNInetnsPath, "Dir in which the network namespaces are created when using --network-plugin 'cni'.")
 	pflags.StringP("cni-output-target", "", "stderr", "Where to forward the cni command output, one of: stdout, stderr, file.")
 	pflags.StringP("cni-output-file-path", "", "stderr", "Path to output file. Only required if --cni-output-target is set to file.")
+	pflags.BoolP("cni-pod-netns", "", false, "EXPERIMENTAL! Set up the network of a pod once in a network namespace pinned in --cni-netns-path, which all containers of the pod join, like the pause container of other runtimes. The pod ip then stays the same when containers restart and is released when the pod is stopped. Otherwise the network is set up in the first started container of the pod.")
+	pflags.StringP("cni-pod-cidr-bridge", "", "", "If kubelet provides the pod CIDR of the node, write a CNI config with a bridge of this name and host-local ipam of the pod CIDR to --cni-conf-dir as "+network.PodCIDRConfFile+" when using --network-plugin 'cni'. If empty, the pod CIDR is ignored and the CNI config is left to the network addon.")
+	pflags.BoolP("cni-watch-conf-dir", "", true, "Watch --cni-conf-dir and reload the CNI config when it changes, instead of loading it for every pod when using --network-plugin 'cni'. The active config is logged and exposed as metric.")
+	pflags.BoolP("cni-default-conf", "", false, "If there's no CNI config in --cni-conf-dir, write a default config with a bridge named "+network.DefaultCNIBridge+", host-local ipam of the pod CIDR kubelet provides, portmap and loopback as "+network.DefaultConfFile+" when using --network-plugin 'cni'. For single node setups without a network addon.")
+	pflags.DurationP("cni-gc-interval", "", 10*time.Minute, "How often the CNI plugins are called with GC and the pods which still exist, so they release the resources of removed pods, like leaked ip allocations, when using --network-plugin 'cni'. Only plugins of CNI spec 1.1.0 and later support it. If 0, no garbage collection is done.")
+	pflags.BoolP("cni-reclaim-on-exhaustion", "", false, "If the ip pool of the CNI plugin is exhausted when starting a container, release the network of all pods without a running container and retry once before failing.")
 
 	rootCmd.RunE = rootCmdRunE
 }
 
-func rootCmdRunE(cmd *cobra.Command, args []string) error {
-	conf := &cri.Config{
-		UnixSocket:           venom.GetString("socket"),
-		LXDSocket:            venom.GetString("lxd-socket"),
-		LXDRemoteConfig:      venom.GetString("lxd-remote-config"),
-		LXDImageRemote:       venom.GetString("lxd-image-remote"),
-		LXDProfiles:          venom.GetStringSlice("lxd-profiles"),
-		LXEStreamingBindAddr: venom.GetString("streaming-bindaddr"),
-		LXEStreamingBaseURL:  venom.GetString("streaming-baseurl"),
-		LXEHostnetworkFile:   venom.GetString("hostnetwork-file"),
-		LXENetworkPlugin:     venom.GetString("network-plugin"),
-		LXEBridgeName:        venom.GetString("bridge-name"),
-		LXEBridgeDHCPRange:   venom.GetString("bridge-dhcp-range"),
-		CNIConfDir:           venom.GetString("cni-conf-dir"),
-		CNIBinDir:            venom.GetString("cni-bin-dir"),
-		CNIOutputTarget:      venom.GetString("cni-output-target"),
-		CNIOutputFile:        venom.GetString("cni-output-file-path"),
+// newConfig creates the cri config from the currently loaded settings
+func newConfig() *cri.Config {
+	return &cri.Config{
+		UnixSocket:                  venom.GetString("socket"),
+		UnixSocketMode:              venom.GetString("socket-mode"),
+		UnixSocketOwner:             venom.GetString("socket-owner"),
+		LXETCPBindAddr:              venom.GetString("tcp-bindaddr"),
+		LXETCPTLSCert:               venom.GetString("tcp-tls-cert"),
+		LXETCPTLSKey:                venom.GetString("tcp-tls-key"),
+		LXETCPTLSClientCA:           venom.GetString("tcp-tls-client-ca"),
+		LXEGRPCMaxConcurrentStreams: venom.GetUint32("grpc-max-concurrent-streams"),
+		LXEGRPCKeepaliveMinTime:     venom.GetDuration("grpc-keepalive-min-time"),
+		LXEGRPCKeepaliveTime:        venom.GetDuration("grpc-keepalive-time"),
+		LXEGRPCMaxConcurrentCalls:   venom.GetInt("grpc-max-concurrent-calls"),
+		LXEGRPCMethodConcurrency:    venom.GetStringSlice("grpc-method-concurrency"),
+		LXEGRPCRateLimit:            venom.GetFloat64("grpc-rate-limit"),
+		LXEGRPCRateBurst:            venom.GetInt("grpc-rate-burst"),
+		LXDSocket:                   venom.GetString("lxd-socket"),
+		LXDRemoteConfig:             venom.GetString("lxd-remote-config"),
+		LXDFake:                     venom.GetBool("lxd-fake"),
+		LXDImageRemote:              venom.GetString("lxd-image-remote"),
+		LXEImagePolicy:              venom.GetString("image-policy"),
+		LXEImageCacheRemote:         venom.GetString("image-cache-remote"),
+		LXEImageCacheDir:            venom.GetString("image-cache-dir"),
+		LXEImageCacheRemotes:        venom.GetStringSlice("image-cache-remotes"),
+		LXEImageCacheBindAddr:       venom.GetString("image-cache-bindaddr"),
+		LXDProfiles:                 venom.GetStringSlice("lxd-profiles"),
+		LXDProject:                  venom.GetString("lxd-project"),
+		LXDProjectLimits:            venom.GetStringSlice("lxd-project-limits"),
+		LXDTarget:                   venom.GetString("lxd-target"),
+		LXEOwner:                    venom.GetString("owner"),
+		LXENamePrefix:               venom.GetString("name-prefix"),
+		LXDOperationWorkers:         venom.GetInt("lxd-operation-workers"),
+		LXDOperationTimeout:         venom.GetDuration("lxd-operation-timeout"),
+		LXDOperationRetries:         venom.GetInt("lxd-operation-retries"),
+		LXDOperationRetryBackoff:    venom.GetDuration("lxd-operation-retry-backoff"),
+		LXDScratchPool:              venom.GetString("lxd-scratch-pool"),
+		LXDVolumePools:              venom.GetStringSlice("lxd-volume-pools"),
+		LXERuntimeHandlerPools:      venom.GetStringSlice("runtime-handler-pools"),
+		LXERuntimeHandlers:          venom.GetStringSlice("runtime-handlers"),
+		LXEStreamingBindAddr:        venom.GetString("streaming-bindaddr"),
+		LXEStreamingBaseURL:         venom.GetString("streaming-baseurl"),
+		LXEStreamingTLSCert:         venom.GetString("streaming-tls-cert"),
+		LXEStreamingTLSKey:          venom.GetString("streaming-tls-key"),
+		LXEStreamingCreationTimeout: venom.GetDuration("streaming-creation-timeout"),
+		LXEStreamingIdleTimeout:     venom.GetDuration("streaming-idle-timeout"),
+		LXEStreamingMaxDuration:     venom.GetDuration("streaming-max-duration"),
+		LXEMetricsBindAddr:          venom.GetString("metrics-bindaddr"),
+		LXEMetricsTLSCert:           venom.GetString("metrics-tls-cert"),
+		LXEMetricsTLSKey:            venom.GetString("metrics-tls-key"),
+		LXEHealthBindAddr:           venom.GetString("health-bindaddr"),
+		LXEHealthPprof:              venom.GetBool("health-pprof"),
+		LXETracingEndpoint:          venom.GetString("tracing-endpoint"),
+		LXETracingInsecure:          venom.GetBool("tracing-insecure"),
+		LXETracingSampleRatio:       venom.GetFloat64("tracing-sample-ratio"),
+		LXEAdminSocket:              venom.GetString("admin-socket"),
+		LXEAttestSocket:             venom.GetString("attest-socket"),
+		LXEAuditLog:                 venom.GetString("audit-log"),
+		LXEAuditLogMaxSize:          venom.GetInt("audit-log-max-size"),
+		LXEAuditLogMaxBackups:       venom.GetInt("audit-log-max-backups"),
+		LXEHostnetworkFile:          venom.GetString("hostnetwork-file"),
+		LXESysctlAllowlist:          venom.GetStringSlice("sysctl-allowlist"),
+		LXEConfigAllowlist:          venom.GetStringSlice("config-allowlist"),
+		LXEConfigDenylist:           venom.GetStringSlice("config-denylist"),
+		LXEMemorySwap:               venom.GetString("memory-swap"),
+		LXEMemoryEnforce:            venom.GetString("memory-enforce"),
+		LXEPodPidsLimit:             venom.GetInt64("pod-pids-limit"),
+		LXEReadonlyRootfsTmpfs:      venom.GetStringSlice("readonly-rootfs-tmpfs"),
+		LXEValidation:               venom.GetString("validation"),
+		LXEHooksDir:                 venom.GetString("hooks-dir"),
+		LXEShiftMode:                venom.GetString("shift-mode"),
+		LXEShiftKubeletVolumes:      venom.GetBool("shift-kubelet-volumes"),
+		LXENamespaceSharing:         venom.GetBool("namespace-sharing"),
+		LXECgroupDriver:             venom.GetString("cgroup-driver"),
+		LXEContainerMode:            venom.GetString("container-mode"),
+		LXEKubernetesServiceEnv:     venom.GetString("kubernetes-service-env"),
+		LXEEvictionPSIThreshold:     venom.GetFloat64("eviction-psi-threshold"),
+		LXEEvictionAction:           venom.GetString("eviction-action"),
+		LXEEvictionInterval:         venom.GetDuration("eviction-interval"),
+		LXEOrphanGracePeriod:        venom.GetDuration("orphan-grace-period"),
+		LXEOrphanInterval:           venom.GetDuration("orphan-interval"),
+		LXEStateFile:                venom.GetString("state-file"),
+		LXEJournalFile:              venom.GetString("journal-file"),
+		LXEShutdownTimeout:          venom.GetDuration("shutdown-timeout"),
+		LXEQuotaInterval:            venom.GetDuration("quota-interval"),
+		LXENodeAllocatableCPU:       venom.GetString("node-allocatable-cpu"),
+		LXENodeAllocatableMemory:    venom.GetString("node-allocatable-memory"),
+		LXENetworkPlugin:            venom.GetString("network-plugin"),
+		LXENetworkMTU:               venom.GetInt("network-mtu"),
+		LXENetworkDisableTxChecksum: venom.GetBool("network-disable-tx-checksum"),
+		LXEBridgeName:               venom.GetString("bridge-name"),
+		LXEBridgeDHCPRange:          venom.GetString("bridge-dhcp-range"),
+		LXEBridgeVLANs:              venom.GetStringSlice("bridge-vlans"),
+		LXEBridgeDNSDomain:          venom.GetString("bridge-dns-domain"),
+		CNIConfDir:                  venom.GetString("cni-conf-dir"),
+		CNIBinDir:                   venom.GetString("cni-bin-dir"),
+		CNINetnsPath:                venom.GetString("cni-netns-path"),
+		CNIOutputTarget:             venom.GetString("cni-output-target"),
+		CNIOutputFile:               venom.GetString("cni-output-file-path"),
+		CNIPodNetns:                 venom.GetBool("cni-pod-netns"),
+		CNIPodCIDRBridge:            venom.GetString("cni-pod-cidr-bridge"),
+		CNIWatchConfDir:             venom.GetBool("cni-watch-conf-dir"),
+		CNIDefaultConf:              venom.GetBool("cni-default-conf"),
+		CNIReclaimOnExhaustion:      venom.GetBool("cni-reclaim-on-exhaustion"),
+		CNIGCInterval:               venom.GetDuration("cni-gc-interval"),
 	}
+}
+
+func rootCmdRunE(cmd *cobra.Command, args []string) error {
+	conf := newConfig()
 
 	criServer := cri.NewServer(conf)
 
+	// new pods use the reloaded network configuration, existing pods keep their previous one. The reloadable settings
+	// apply to the following CRI calls
+	cli.OnReload(func() error {
+		return criServer.Reload(newConfig())
+	})
+
+	// the running calls and operations are waited for, so pods aren't left half created
+	cli.OnShutdown(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), conf.LXEShutdownTimeout)
+		defer cancel()
+
+		err := criServer.Shutdown(ctx)
+		if errors.Is(err, cri.ErrForcedShutdown) {
+			return fmt.Errorf("%w: %v", cli.ErrForcedShutdown, err)
+		}
+
+		return err
+	})
+
 	go func() {
 		err := errand.Append(nil, criServer.Serve())
 		if err != nil {
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+
+	"github.com/automaticserver/lxe/cli"
+	"github.com/automaticserver/lxe/cri"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate POD-ID MEMBER",
+	Short: "Migrate the containers of a pod to another LXD cluster member",
+	Long:  "Migrate moves all containers of the pod to another member of the LXD cluster. Running containers are migrated live, which requires CRIU on both members. The network of the pod is set up again on the target member using the network plugin of the loaded configuration, so use the same configuration as the running LXE. Pods in the CNI network can't be migrated.",
+	Args:  cobra.ExactArgs(2), // nolint: gomnd
+	// don't start the signal handling of the daemon
+	Annotations: map[string]string{cli.AnnIsNonoperational: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rt, err := cri.NewAdminRuntimeServer(newConfig())
+		if err != nil {
+			return err
+		}
+
+		return rt.MigratePod(context.Background(), args[0], args[1])
+	},
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/automaticserver/lxe/cli"
+	"github.com/automaticserver/lxe/cri"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	psCmd.Flags().BoolP("pods", "", false, "List the pods instead of the containers")
+
+	rootCmd.AddCommand(psCmd)
+}
+
+var psCmd = &cobra.Command{
+	Use:   "ps",
+	Short: "List the containers or pods of the running LXE",
+	Long:  "Ps lists the containers, or the pods with --pods, of the running LXE with LXD specific fields and the last failed CRI call of each. It requests the admin api, so the running LXE must have --admin-socket set.",
+	Args:  cobra.NoArgs,
+	// don't start the signal handling of the daemon
+	Annotations: map[string]string{cli.AnnIsNonoperational: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pods, err := cmd.Flags().GetBool("pods")
+		if err != nil {
+			return err
+		}
+
+		client, err := cri.NewAdminClient(newConfig().LXEAdminSocket)
+		if err != nil {
+			return err
+		}
+
+		sbs, err := client.ListSandboxes()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0) // nolint: gomnd
+		defer w.Flush()
+
+		if pods {
+			fmt.Fprintln(w, "POD ID\tNAMESPACE\tNAME\tSTATE\tNETWORK\tCREATED\tLAST ERROR")
+
+			for _, sb := range sbs {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", sb.ID, sb.Namespace, sb.Name, sb.State, sb.NetworkMode,
+					since(sb.CreatedAt), lastError(sb.LastError))
+			}
+
+			return nil
+		}
+
+		cl, err := client.ListContainers()
+		if err != nil {
+			return err
+		}
+
+		podNames := make(map[string]string, len(sbs))
+		for _, sb := range sbs {
+			podNames[sb.ID] = sb.Namespace + "/" + sb.Name
+		}
+
+		fmt.Fprintln(w, "CONTAINER ID\tNAME\tSTATE\tPOD\tIMAGE\tPOOL\tLOCATION\tCREATED\tLAST ERROR")
+
+		for _, c := range cl {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", c.ID, c.Name, c.State, podNames[c.SandboxID], c.Image,
+				c.StoragePool, c.Location, since(c.CreatedAt), lastError(c.LastError))
+		}
+
+		return nil
+	},
+}
+
+// since formats the time as rounded duration till now
+func since(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return time.Since(t).Round(time.Second).String() + " ago"
+}
+
+// lastError formats the last failed call in a single line
+func lastError(e *cri.AdminLastError) string {
+	if e == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%s %s ago: %s", e.Method, time.Since(e.At).Round(time.Second), e.Error)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/automaticserver/lxe/cli"
+	"github.com/automaticserver/lxe/cri"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	snapshotCmd.Flags().Bool("stateful", false, "Also save the running state of the container")
+
+	rootCmd.AddCommand(snapshotCmd)
+}
+
+var snapshotCmd = &cobra.Command{
+	Use:     "snapshot CONTAINER-ID|NAMESPACE_POD_CONTAINER NAME",
+	Short:   "Take a snapshot of a container of the running LXE",
+	Long:    "Snapshot takes an LXD snapshot of the container with the name. The snapshots can be listed, restored and deleted through the admin api. It requests the admin api, so the running LXE must have --admin-socket set.",
+	Example: `  lxe snapshot default_db_postgres before-upgrade`,
+	Args:    cobra.ExactArgs(2), // nolint: gomnd
+	// don't start the signal handling of the daemon
+	Annotations: map[string]string{cli.AnnIsNonoperational: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stateful, err := cmd.Flags().GetBool("stateful")
+		if err != nil {
+			return err
+		}
+
+		client, err := cri.NewAdminClient(newConfig().LXEAdminSocket)
+		if err != nil {
+			return err
+		}
+
+		err = client.CreateSnapshot(args[0], args[1], stateful)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "Snapshot %s of %s taken\n", args[1], args[0])
+
+		return nil
+	},
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/automaticserver/lxe/cli"
+	"github.com/automaticserver/lxe/cri"
+	"github.com/lxc/lxd/shared/units"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(topologyCmd)
+}
+
+var topologyCmd = &cobra.Command{
+	Use:   "topology",
+	Short: "List the NUMA nodes of the host",
+	Long:  "Topology lists the NUMA nodes of the LXD host with their online cpus and memory, as the pod annotation lxe.automaticserver.ch/numa-nodes refers to them. It requests the admin api, so the running LXE must have --admin-socket set.",
+	Args:  cobra.NoArgs,
+	// don't start the signal handling of the daemon
+	Annotations: map[string]string{cli.AnnIsNonoperational: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := cri.NewAdminClient(newConfig().LXEAdminSocket)
+		if err != nil {
+			return err
+		}
+
+		nodes, err := client.GetTopology()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0) // nolint: gomnd
+		defer w.Flush()
+
+		fmt.Fprintln(w, "NODE\tCPUS\tMEMORY USED\tMEMORY TOTAL")
+
+		for _, n := range nodes {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", n.ID, n.CPUs, units.GetByteSizeString(int64(n.MemoryUsed), 1),
+				units.GetByteSizeString(int64(n.MemoryTotal), 1))
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,340 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/automaticserver/lxe/shared"
+)
+
+var (
+	ErrAdminRoute = errors.New("unknown admin route")
+)
+
+// adminService serves LXE specific administrative endpoints as REST API on a unix socket. They are not part of the
+// CRI, so kubelet doesn't know about them and they are only accessible to the local operator. Sandboxes can also be
+// referenced by namespace_pod and containers by namespace_pod_container instead of their id:
+//
+//	GET    /sandboxes                                  list the sandboxes
+//	GET    /sandboxes/{id}                             get the sandbox
+//	GET    /sandboxes/{id}/lxd                         get the LXD profile of the sandbox as LXD returns it
+//	GET    /containers                                 list the containers
+//	GET    /containers/{id}                            get the container, with its pid and netns path if it's running
+//	GET    /containers/{id}/lxd                        get the LXD container as LXD returns it
+//	GET    /containers/{id}/console?lines=N            get the console log, the last N lines if set
+//	GET    /containers/{id}/snapshots                  list the snapshots of the container
+//	POST   /containers/{id}/snapshots                  take a snapshot, body: {"name": "...", "stateful": false}
+//	POST   /containers/{id}/snapshots/{name}/restore   restore the container to the snapshot, body: {"stateful": false}
+//	DELETE /containers/{id}/snapshots/{name}           delete the snapshot
+//	GET    /containers/{id}/files?path=/...            get the file or directory at the path as tarball, named "." for the path
+//	PUT    /containers/{id}/files?path=/...            create the files of the tarball in the body at the path, "." being the path
+//	POST   /containers/{id}/commit                     publish the container as image, body: {"image": "...", "properties": {}, "compression": "..."}
+//	POST   /containers/{id}/checkpoint                 write the running container with its state as tarball on this host, body: {"location": "/..."}
+//	POST   /images                                     import an image from files on this host, body: {"image": "...", "path": "/...", "rootfs": "/..."}
+//	GET    /events                                     stream the lifecycle events of the containers as JSON lines
+//	GET    /log                                        get the log level and the levels of the subsystems
+//	PUT    /log                                        set them till restart or reload, body: {"level": "info", "subsystems": {"network": "debug"}}
+//	GET    /topology                                   get the NUMA nodes of the host with their cpus and memory
+type adminService struct {
+	runtimeServer *RuntimeServer
+	socket        string
+	sock          net.Listener
+	server        *http.Server
+}
+
+// adminSnapshotRequest is the body of snapshot and restore requests
+type adminSnapshotRequest struct {
+	Name     string `json:"name"`
+	Stateful bool   `json:"stateful"`
+}
+
+// adminSnapshot is a snapshot in responses
+type adminSnapshot struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Stateful  bool      `json:"stateful"`
+}
+
+// AdminCheckpoint is the body of checkpoint requests
+type AdminCheckpoint struct {
+	// Location is the absolute path on the host of LXE the tarball is written to, it must not exist yet
+	Location string `json:"location"`
+}
+
+// adminError is the body of failed requests
+type adminError struct {
+	Error string `json:"error"`
+}
+
+func newAdminService(criConfig *Config, runtime *RuntimeServer) *adminService {
+	a := &adminService{
+		runtimeServer: runtime,
+		socket:        criConfig.LXEAdminSocket,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sandboxes", a.handleSandboxes)
+	mux.HandleFunc("/sandboxes/", a.handleSandboxes)
+	mux.HandleFunc("/containers", a.handleContainers)
+	mux.HandleFunc("/containers/", a.handleContainers)
+	mux.HandleFunc("/images", a.handleImages)
+	mux.HandleFunc("/events", a.handleEvents)
+	mux.HandleFunc("/log", a.handleLog)
+	mux.HandleFunc("/topology", a.handleTopology)
+
+	a.server = &http.Server{Handler: mux}
+
+	return a
+}
+
+// serve creates the admin socket and serves the REST API
+func (a *adminService) serve() error {
+	var err error
+
+	log := log.WithField("socket", a.socket)
+
+	if _, err = os.Stat(a.socket); err == nil {
+		log.Debug("cleaning up stale admin socket")
+
+		err = os.Remove(a.socket)
+		if err != nil {
+			return err
+		}
+	}
+
+	a.sock, err = net.Listen("unix", a.socket)
+	if err != nil {
+		return err
+	}
+
+	// the admin api allows to modify all containers, restrict it to the owner
+	err = os.Chmod(a.socket, 0600)
+	if err != nil {
+		return err
+	}
+
+	log.Info("started admin service")
+
+	err = a.server.Serve(a.sock)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// stop closes the admin socket
+func (a *adminService) stop() error {
+	err := a.server.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(a.socket)
+}
+
+// handleSandboxes routes the requests below /sandboxes
+func (a *adminService) handleSandboxes(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/sandboxes"), "/"), "/")
+
+	if parts[0] != "" {
+		var err error
+
+		parts[0], err = a.resolveSandbox(parts[0])
+		if err != nil {
+			writeAdminLXFError(w, err)
+			return
+		}
+	}
+
+	switch {
+	case len(parts) == 1 && parts[0] == "" && r.Method == http.MethodGet:
+		a.listSandboxes(w)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		a.getSandbox(w, parts[0])
+	case len(parts) == 2 && parts[1] == "lxd" && r.Method == http.MethodGet:
+		a.getLXDProfile(w, parts[0])
+	default:
+		writeAdminError(w, http.StatusNotFound, ErrAdminRoute)
+	}
+}
+
+// handleContainers routes the requests below /containers
+func (a *adminService) handleContainers(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/containers"), "/"), "/")
+
+	if parts[0] != "" {
+		var err error
+
+		parts[0], err = a.resolveContainer(parts[0])
+		if err != nil {
+			writeAdminLXFError(w, err)
+			return
+		}
+	}
+
+	switch {
+	case len(parts) == 1 && parts[0] == "" && r.Method == http.MethodGet:
+		a.listContainers(w)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		a.getContainer(w, parts[0])
+	case len(parts) == 2 && parts[1] == "lxd" && r.Method == http.MethodGet:
+		a.getLXDContainer(w, parts[0])
+	case len(parts) == 2 && parts[1] == "console" && r.Method == http.MethodGet:
+		a.readConsole(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "snapshots" && r.Method == http.MethodGet:
+		a.listSnapshots(w, parts[0])
+	case len(parts) == 2 && parts[1] == "snapshots" && r.Method == http.MethodPost:
+		a.createSnapshot(w, r, parts[0])
+	case len(parts) == 4 && parts[1] == "snapshots" && parts[3] == "restore" && r.Method == http.MethodPost:
+		a.restoreSnapshot(w, r, parts[0], parts[2])
+	case len(parts) == 3 && parts[1] == "snapshots" && r.Method == http.MethodDelete:
+		a.deleteSnapshot(w, r, parts[0], parts[2])
+	case len(parts) == 2 && parts[1] == "files" && r.Method == http.MethodGet:
+		a.readFiles(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "files" && r.Method == http.MethodPut:
+		a.writeFiles(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "commit" && r.Method == http.MethodPost:
+		a.commitContainer(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "checkpoint" && r.Method == http.MethodPost:
+		a.checkpointContainer(w, r, parts[0])
+	default:
+		writeAdminError(w, http.StatusNotFound, ErrAdminRoute)
+	}
+}
+
+func (a *adminService) listSnapshots(w http.ResponseWriter, id string) {
+	snaps, err := a.runtimeServer.lxf.ListSnapshots(id)
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	res := make([]adminSnapshot, 0, len(snaps))
+	for _, s := range snaps {
+		res = append(res, adminSnapshot(s))
+	}
+
+	writeAdminJSON(w, http.StatusOK, res)
+}
+
+func (a *adminService) createSnapshot(w http.ResponseWriter, r *http.Request, id string) {
+	req := adminSnapshotRequest{}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Name == "" || strings.Contains(req.Name, "/") {
+		writeAdminError(w, http.StatusBadRequest, errors.New("body must contain a snapshot name without slashes"))
+		return
+	}
+
+	log.WithField("containerid", id).WithField("snapshot", req.Name).Info("create snapshot")
+
+	err = a.runtimeServer.lxf.CreateSnapshot(r.Context(), id, req.Name, req.Stateful)
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	writeAdminJSON(w, http.StatusCreated, adminSnapshot{Name: req.Name, CreatedAt: time.Now(), Stateful: req.Stateful})
+}
+
+func (a *adminService) restoreSnapshot(w http.ResponseWriter, r *http.Request, id, name string) {
+	req := adminSnapshotRequest{}
+
+	// the body is optional
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	log.WithField("containerid", id).WithField("snapshot", name).Info("restore snapshot")
+
+	err := a.runtimeServer.lxf.RestoreSnapshot(r.Context(), id, name, req.Stateful)
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *adminService) deleteSnapshot(w http.ResponseWriter, r *http.Request, id, name string) {
+	log.WithField("containerid", id).WithField("snapshot", name).Info("delete snapshot")
+
+	err := a.runtimeServer.lxf.DeleteSnapshot(r.Context(), id, name)
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkpointContainer writes the running container with its runtime state as tarball to the location, it keeps running
+func (a *adminService) checkpointContainer(w http.ResponseWriter, r *http.Request, id string) {
+	req := AdminCheckpoint{}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || !filepath.IsAbs(req.Location) {
+		writeAdminError(w, http.StatusBadRequest, errors.New("body must contain an absolute location"))
+		return
+	}
+
+	log.WithField("containerid", id).WithField("location", req.Location).Info("checkpoint container")
+
+	f, err := os.OpenFile(req.Location, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600) // nolint: gomnd
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = a.runtimeServer.lxf.CheckpointContainer(r.Context(), id, f)
+
+	cerr := f.Close()
+	if err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		_ = os.Remove(req.Location)
+
+		writeAdminLXFError(w, err)
+
+		return
+	}
+
+	writeAdminJSON(w, http.StatusCreated, req)
+}
+
+// writeAdminLXFError maps not found errors to 404, everything else is an internal error
+func writeAdminLXFError(w http.ResponseWriter, err error) {
+	if shared.IsErrNotFound(err) {
+		writeAdminError(w, http.StatusNotFound, err)
+		return
+	}
+
+	writeAdminError(w, http.StatusInternalServerError, err)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, err error) {
+	writeAdminJSON(w, status, adminError{Error: err.Error()})
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.WithError(err).Warn("unable to write admin response")
+	}
+}
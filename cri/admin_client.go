@@ -0,0 +1,272 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/automaticserver/lxe/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var (
+	ErrAdminSocketMissing = errors.New("the admin socket is not configured")
+	ErrAdminResponse      = errors.New("admin api error")
+)
+
+// AdminClient requests the admin api of a running LXE over its socket
+type AdminClient struct {
+	client *http.Client
+}
+
+// NewAdminClient creates a client for the admin api on socket
+func NewAdminClient(socket string) (*AdminClient, error) {
+	if socket == "" {
+		return nil, ErrAdminSocketMissing
+	}
+
+	return &AdminClient{
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}, nil
+}
+
+// ListSandboxes returns all sandboxes
+func (c *AdminClient) ListSandboxes() ([]AdminSandbox, error) {
+	res := []AdminSandbox{}
+
+	err := c.get("/sandboxes", &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// GetSandbox returns the sandbox identified by id
+func (c *AdminClient) GetSandbox(id string) (*AdminSandbox, error) {
+	res := &AdminSandbox{}
+
+	err := c.get("/sandboxes/"+url.PathEscape(id), res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// ListContainers returns all containers
+func (c *AdminClient) ListContainers() ([]AdminContainer, error) {
+	res := []AdminContainer{}
+
+	err := c.get("/containers", &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// GetContainer returns the container identified by id
+func (c *AdminClient) GetContainer(id string) (*AdminContainer, error) {
+	res := &AdminContainer{}
+
+	err := c.get("/containers/"+url.PathEscape(id), res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// GetLXDContainer returns the LXD container of the container identified by id or namespace_pod_container
+func (c *AdminClient) GetLXDContainer(ref string) (*api.Container, error) {
+	res := &api.Container{}
+
+	err := c.get("/containers/"+url.PathEscape(ref)+"/lxd", res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// GetLXDProfile returns the LXD profile of the sandbox identified by id or namespace_pod
+func (c *AdminClient) GetLXDProfile(ref string) (*api.Profile, error) {
+	res := &api.Profile{}
+
+	err := c.get("/sandboxes/"+url.PathEscape(ref)+"/lxd", res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// ReadConsole returns the console log of the container, the last lines if not 0. The caller must close it
+func (c *AdminClient) ReadConsole(ref string, lines int) (io.ReadCloser, error) {
+	resp, err := c.request(http.MethodGet, "/containers/"+url.PathEscape(ref)+"/console?"+
+		url.Values{"lines": {strconv.Itoa(lines)}}.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// CreateSnapshot takes a snapshot of the container with the name
+func (c *AdminClient) CreateSnapshot(ref, name string, stateful bool) error {
+	return c.do(http.MethodPost, "/containers/"+url.PathEscape(ref)+"/snapshots",
+		adminSnapshotRequest{Name: name, Stateful: stateful}, &adminSnapshot{})
+}
+
+// ImportImage imports the image files, the paths must be accessible to the running LXE
+func (c *AdminClient) ImportImage(req AdminImageImport) (*AdminImageImport, error) {
+	res := &AdminImageImport{}
+
+	err := c.do(http.MethodPost, "/images", req, res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// CommitContainer publishes the container as image with the alias of the image reference
+func (c *AdminClient) CommitContainer(ref string, req AdminCommit) (*AdminCommit, error) {
+	res := &AdminCommit{}
+
+	err := c.do(http.MethodPost, "/containers/"+url.PathEscape(ref)+"/commit", req, res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// CheckpointContainer writes the running container with its runtime state as tarball to the location on the host of
+// LXE
+func (c *AdminClient) CheckpointContainer(ref, location string) error {
+	return c.do(http.MethodPost, "/containers/"+url.PathEscape(ref)+"/checkpoint",
+		AdminCheckpoint{Location: location}, &AdminCheckpoint{})
+}
+
+// WatchEvents returns the stream of the container events as JSON lines, it ends if the client lags too far behind
+func (c *AdminClient) WatchEvents() (io.ReadCloser, error) {
+	resp, err := c.request(http.MethodGet, "/events", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// GetTopology returns the NUMA nodes of the host
+func (c *AdminClient) GetTopology() ([]AdminNUMANode, error) {
+	res := []AdminNUMANode{}
+
+	err := c.get("/topology", &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// ReadFiles returns the file or directory at the path of the container as tarball, the caller must close it
+func (c *AdminClient) ReadFiles(id, p string) (io.ReadCloser, error) {
+	resp, err := c.request(http.MethodGet, filesPath(id, p), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// WriteFiles creates the files of the tarball at the path of the container
+func (c *AdminClient) WriteFiles(id, p string, archive io.Reader) error {
+	resp, err := c.request(http.MethodPut, filesPath(id, p), archive)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// filesPath returns the files route of the container for the path
+func filesPath(id, p string) string {
+	return "/containers/" + url.PathEscape(id) + "/files?" + url.Values{"path": {p}}.Encode()
+}
+
+// get decodes the response of the path into v. A 404 is returned as not found error
+func (c *AdminClient) get(path string, v interface{}) error {
+	return c.do(http.MethodGet, path, nil, v)
+}
+
+// do sends body as json, if any, and decodes the response of the path into v. A 404 is returned as not found error
+func (c *AdminClient) do(method, path string, body, v interface{}) error {
+	var reqBody io.Reader
+
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reqBody = bytes.NewReader(b)
+	}
+
+	resp, err := c.request(method, path, reqBody)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// request sends the body to the path and returns the response if it's successful, the caller must close its body. A
+// 404 is returned as not found error
+func (c *AdminClient) request(method, path string, body io.Reader) (*http.Response, error) {
+	// the host is ignored as the socket is dialed
+	req, err := http.NewRequest(method, "http://lxe"+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+
+	e := adminError{}
+
+	err = json.NewDecoder(resp.Body).Decode(&e)
+	if err != nil {
+		e.Error = resp.Status
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", shared.NewErrNotFound(), e.Error)
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrAdminResponse, e.Error)
+}
@@ -0,0 +1,50 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// handleEvents streams the container events as JSON lines till the client disconnects, LXE stops or the client lags
+// too far behind
+func (a *adminService) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAdminError(w, http.StatusNotFound, ErrAdminRoute)
+		return
+	}
+
+	events, unsubscribe := a.runtimeServer.events.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	enc := json.NewEncoder(w)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-a.runtimeServer.stopping:
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+
+			err := enc.Encode(ev)
+			if err != nil {
+				return
+			}
+
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"net/http"
+	"path"
+
+	"github.com/automaticserver/lxe/lxf"
+)
+
+// tarContentType is the content type of the file archives
+const tarContentType = "application/x-tar"
+
+var ErrFilePath = errors.New("path must be absolute")
+
+// tarResponse writes the status and content type of a successful archive with the first write, so errors before
+// anything is written can still be returned as error response
+type tarResponse struct {
+	w       http.ResponseWriter
+	written bool
+}
+
+func (t *tarResponse) Write(p []byte) (int, error) {
+	if !t.written {
+		t.written = true
+		t.w.Header().Set("Content-Type", tarContentType)
+		t.w.WriteHeader(http.StatusOK)
+	}
+
+	return t.w.Write(p)
+}
+
+// readFiles responds with the file or directory at the path query of the container as tarball
+func (a *adminService) readFiles(w http.ResponseWriter, r *http.Request, id string) {
+	p := r.URL.Query().Get("path")
+	if !path.IsAbs(p) {
+		writeAdminError(w, http.StatusBadRequest, ErrFilePath)
+		return
+	}
+
+	c, err := a.runtimeServer.lxf.GetContainer(id)
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	log.WithField("containerid", id).WithField("path", p).Info("read files")
+
+	res := &tarResponse{w: w}
+
+	err = c.ReadFiles(p, res)
+	if err != nil {
+		if !res.written {
+			writeAdminLXFError(w, err)
+			return
+		}
+
+		// the client sees a truncated archive
+		log.WithError(err).WithField("containerid", id).WithField("path", p).Warn("unable to read files")
+	}
+}
+
+// writeFiles creates the files of the tarball in the request body at the path query of the container
+func (a *adminService) writeFiles(w http.ResponseWriter, r *http.Request, id string) {
+	p := r.URL.Query().Get("path")
+	if !path.IsAbs(p) {
+		writeAdminError(w, http.StatusBadRequest, ErrFilePath)
+		return
+	}
+
+	c, err := a.runtimeServer.lxf.GetContainer(id)
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	log.WithField("containerid", id).WithField("path", p).Info("write files")
+
+	err = c.WriteFiles(p, r.Body)
+	if errors.Is(err, lxf.ErrInvalidArchive) {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,47 @@
+package cri
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/automaticserver/lxe/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminService_Files_InvalidPath(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		rec := httptest.NewRecorder()
+		a.server.Handler.ServeHTTP(rec, httptest.NewRequest(method, "/containers/abc/files?path=etc", nil))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, method)
+	}
+
+	assert.Equal(t, 0, fake.GetContainerCallCount())
+}
+
+func TestAdminService_Files_NotFound(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	fake.GetContainerReturns(nil, shared.NewErrNotFound())
+
+	rec := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/containers/abc/files?path=/etc", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "abc", fake.GetContainerArgsForCall(0))
+}
+
+func Test_filesPath(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "/containers/abc/files?path=%2Fetc%2Fmy+app", filesPath("abc", "/etc/my app"))
+}
@@ -0,0 +1,109 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+
+	"github.com/automaticserver/lxe/lxf"
+)
+
+// AdminImageImport is the body of image import requests and their response
+type AdminImageImport struct {
+	// Image is the image reference the imported image is found by, like in a pod spec
+	Image string `json:"image"`
+	// Path is the unified tarball, the metadata tarball of a split image or a directory of an unpacked unified image
+	Path string `json:"path"`
+	// Rootfs is the rootfs tarball of a split image
+	Rootfs string `json:"rootfs,omitempty"`
+	// Fingerprint is the hash of the imported image
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// AdminCommit is the body of commit requests and their response
+type AdminCommit struct {
+	// Image is the image reference the committed image is found by, like in a pod spec
+	Image string `json:"image"`
+	// Properties of the image, e.g. description or os
+	Properties map[string]string `json:"properties,omitempty"`
+	// Compression is the algorithm the image is compressed with, e.g. gzip, xz, zstd or none. Empty uses the default of
+	// LXD
+	Compression string `json:"compression,omitempty"`
+	// Fingerprint is the hash of the committed image
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// handleImages routes the requests to /images
+func (a *adminService) handleImages(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		a.importImage(w, r)
+	default:
+		writeAdminError(w, http.StatusNotFound, ErrAdminRoute)
+	}
+}
+
+// importImage imports the image files from the paths on this host into the image store of LXD
+func (a *adminService) importImage(w http.ResponseWriter, r *http.Request) {
+	req := AdminImageImport{}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Image == "" || !filepath.IsAbs(req.Path) || (req.Rootfs != "" && !filepath.IsAbs(req.Rootfs)) {
+		writeAdminError(w, http.StatusBadRequest, errors.New("body must contain the image and absolute paths"))
+		return
+	}
+
+	meta, err := lxf.OpenImageSource(req.Path)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+	defer meta.Close()
+
+	var rootfs io.ReadCloser
+
+	if req.Rootfs != "" {
+		rootfs, err = lxf.OpenImageSource(req.Rootfs)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+		defer rootfs.Close()
+	}
+
+	log.WithField("image", req.Image).WithField("path", req.Path).Info("import image")
+
+	req.Fingerprint, err = a.runtimeServer.lxf.ImportImage(r.Context(), req.Image, meta, rootfs)
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	writeAdminJSON(w, http.StatusCreated, req)
+}
+
+// commitContainer publishes the container as image, it keeps running
+func (a *adminService) commitContainer(w http.ResponseWriter, r *http.Request, id string) {
+	req := AdminCommit{}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Image == "" {
+		writeAdminError(w, http.StatusBadRequest, errors.New("body must contain the image"))
+		return
+	}
+
+	log.WithField("containerid", id).WithField("image", req.Image).Info("commit container")
+
+	req.Fingerprint, err = a.runtimeServer.lxf.CommitContainer(r.Context(), id, req.Image, lxf.CommitOptions{
+		Properties:  req.Properties,
+		Compression: req.Compression,
+	})
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	writeAdminJSON(w, http.StatusCreated, req)
+}
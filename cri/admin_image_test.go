@@ -0,0 +1,160 @@
+package cri
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminService_ImportImage(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	dir := t.TempDir()
+	meta := filepath.Join(dir, "meta.tar.xz")
+	rootfs := filepath.Join(dir, "rootfs.squashfs")
+	assert.NoError(t, os.WriteFile(meta, []byte("meta"), 0644))
+	assert.NoError(t, os.WriteFile(rootfs, []byte("rootfs"), 0644))
+
+	fake.ImportImageStub = func(_ context.Context, _ string, m, r io.Reader) (string, error) {
+		mb, _ := io.ReadAll(m)
+		rb, _ := io.ReadAll(r)
+
+		return string(mb) + "+" + string(rb), nil
+	}
+
+	body := fmt.Sprintf(`{"image":"ubuntu:20.04","path":%q,"rootfs":%q}`, meta, rootfs)
+	rec := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/images", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	_, image, _, _ := fake.ImportImageArgsForCall(0)
+	assert.Equal(t, "ubuntu:20.04", image)
+
+	res := AdminImageImport{}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+	assert.Equal(t, "meta+rootfs", res.Fingerprint)
+}
+
+func TestAdminService_ImportImage_Invalid(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	for _, body := range []string{
+		`{"path":"/tmp/image.tar.gz"}`,
+		`{"image":"ubuntu","path":"image.tar.gz"}`,
+		`{"image":"ubuntu","path":"/does/not/exist.tar.gz"}`,
+	} {
+		rec := httptest.NewRecorder()
+		a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/images", strings.NewReader(body)))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+
+	assert.Equal(t, 0, fake.ImportImageCallCount())
+}
+
+func TestAdminService_CommitContainer(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	fake.CommitContainerReturns("abc123", nil)
+
+	body := `{"image":"nginx:golden","properties":{"description":"golden"},"compression":"zstd"}`
+	rec := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/containers/ct1/commit", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	_, id, image, opts := fake.CommitContainerArgsForCall(0)
+	assert.Equal(t, "ct1", id)
+	assert.Equal(t, "nginx:golden", image)
+	assert.Equal(t, "zstd", opts.Compression)
+	assert.Equal(t, map[string]string{"description": "golden"}, opts.Properties)
+
+	res := AdminCommit{}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+	assert.Equal(t, "abc123", res.Fingerprint)
+
+	rec = httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/containers/ct1/commit", strings.NewReader(`{}`)))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, 1, fake.CommitContainerCallCount())
+}
+
+func TestAdminService_CheckpointContainer(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	fake.CheckpointContainerStub = func(_ context.Context, _ string, w io.WriteSeeker) error {
+		_, err := w.Write([]byte("backup"))
+		return err
+	}
+
+	location := filepath.Join(t.TempDir(), "checkpoint.tar.gz")
+	body := fmt.Sprintf(`{"location":%q}`, location)
+	rec := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/containers/ct1/checkpoint", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	_, id, _ := fake.CheckpointContainerArgsForCall(0)
+	assert.Equal(t, "ct1", id)
+
+	b, err := os.ReadFile(location)
+	assert.NoError(t, err)
+	assert.Equal(t, "backup", string(b))
+
+	// an existing file is never overwritten
+	rec = httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/containers/ct1/checkpoint", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, 1, fake.CheckpointContainerCallCount())
+}
+
+func TestAdminService_CheckpointContainer_Failed(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	fake.CheckpointContainerReturns(errors.New("CRIU isn't available"))
+
+	location := filepath.Join(t.TempDir(), "checkpoint.tar.gz")
+	rec := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/containers/ct1/checkpoint",
+		strings.NewReader(fmt.Sprintf(`{"location":%q}`, location))))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	_, err := os.Stat(location)
+	assert.True(t, os.IsNotExist(err))
+
+	rec = httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/containers/ct1/checkpoint",
+		strings.NewReader(`{"location":"checkpoint.tar.gz"}`)))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, 1, fake.CheckpointContainerCallCount())
+}
@@ -0,0 +1,76 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/automaticserver/lxe/logging"
+	"github.com/sirupsen/logrus"
+)
+
+// adminLogLevels are the log levels in requests and responses, the subsystems override the default level
+type adminLogLevels struct {
+	Level      string            `json:"level"`
+	Subsystems map[string]string `json:"subsystems"`
+}
+
+// handleLog routes the requests to /log
+func (a *adminService) handleLog(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.getLogLevels(w)
+	case http.MethodPut:
+		a.setLogLevels(w, r)
+	default:
+		writeAdminError(w, http.StatusNotFound, ErrAdminRoute)
+	}
+}
+
+func (a *adminService) getLogLevels(w http.ResponseWriter) {
+	def, subsystems := logging.Levels()
+
+	res := adminLogLevels{Level: def.String(), Subsystems: map[string]string{}}
+	for name, level := range subsystems {
+		res.Subsystems[name] = level.String()
+	}
+
+	writeAdminJSON(w, http.StatusOK, res)
+}
+
+// setLogLevels replaces the log levels till LXE restarts or reloads its config
+func (a *adminService) setLogLevels(w http.ResponseWriter, r *http.Request) {
+	req := adminLogLevels{}
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	def, err := logrus.ParseLevel(req.Level)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	entries := make([]string, 0, len(req.Subsystems))
+	for name, level := range req.Subsystems {
+		entries = append(entries, name+"="+level)
+	}
+
+	subsystems, err := logging.ParseLevels(entries)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = logging.SetLevels(def, subsystems)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	log.WithField("level", req.Level).WithField("subsystems", req.Subsystems).Info("changed log levels")
+
+	a.getLogLevels(w)
+}
@@ -0,0 +1,55 @@
+package cri
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/automaticserver/lxe/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+// not parallel, as the log levels are global
+func TestAdminService_LogLevels(t *testing.T) {
+	def, subsystems := logging.Levels()
+	defer func() { assert.NoError(t, logging.SetLevels(def, subsystems)) }()
+
+	s, _, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	rec := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/log", strings.NewReader(`{"level":"warning","subsystems":{"cri":"debug"}}`)))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	res := adminLogLevels{}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+	assert.Equal(t, adminLogLevels{Level: "warning", Subsystems: map[string]string{"cri": "debug"}}, res)
+	assert.Equal(t, logrus.DebugLevel, logrus.GetLevel())
+
+	rec = httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/log", strings.NewReader(`{"level":"info","subsystems":{"unknown":"debug"}}`)))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/log", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+	assert.Equal(t, "warning", res.Level, "failed request keeps the levels")
+}
+
+func Test_callFields(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, logrus.Fields{"method": "Version"}, callFields("Version", nil))
+	assert.Equal(t, logrus.Fields{"method": "StopContainer", "containerid": "c1"},
+		callFields("StopContainer", &rtApi.StopContainerRequest{ContainerId: "c1"}))
+	assert.Equal(t, logrus.Fields{"method": "CreateContainer", "podid": "p1"},
+		callFields("CreateContainer", &rtApi.CreateContainerRequest{PodSandboxId: "p1"}))
+}
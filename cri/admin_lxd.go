@@ -0,0 +1,156 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/shared"
+)
+
+// adminRefSeparator separates the namespace, pod and container names in references to sandboxes and containers. It
+// can't be part of kubernetes names nor LXD names, so references and ids can't be confused
+const adminRefSeparator = "_"
+
+// resolveSandbox returns the id of the sandbox referenced either by its id or by namespace_pod. Of several sandboxes of
+// the pod, the most recently created is returned
+func (a *adminService) resolveSandbox(ref string) (string, error) {
+	parts := strings.Split(ref, adminRefSeparator)
+	if len(parts) != 2 { // nolint: gomnd
+		return ref, nil
+	}
+
+	sb, err := a.latestSandbox(parts[0], parts[1])
+	if err != nil {
+		return "", err
+	}
+
+	return sb.ID, nil
+}
+
+// resolveContainer returns the id of the container referenced either by its id or by namespace_pod_container. Of
+// several containers of that name, the most recently created of the most recent sandbox is returned
+func (a *adminService) resolveContainer(ref string) (string, error) {
+	parts := strings.Split(ref, adminRefSeparator)
+	if len(parts) != 3 { // nolint: gomnd
+		return ref, nil
+	}
+
+	sb, err := a.latestSandbox(parts[0], parts[1])
+	if err != nil {
+		return "", err
+	}
+
+	cl, err := a.runtimeServer.lxf.FilterContainers(lxf.ContainerFilter{SandboxID: sb.ID})
+	if err != nil {
+		return "", err
+	}
+
+	var latest *lxf.Container
+
+	for _, c := range cl {
+		if c.Metadata.Name == parts[2] && (latest == nil || c.CreatedAt.After(latest.CreatedAt)) {
+			latest = c
+		}
+	}
+
+	if latest == nil {
+		return "", fmt.Errorf("container %w: %s", shared.NewErrNotFound(), ref)
+	}
+
+	return latest.ID, nil
+}
+
+func (a *adminService) latestSandbox(namespace, name string) (*lxf.Sandbox, error) {
+	sbs, err := a.runtimeServer.lxf.ListSandboxes()
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *lxf.Sandbox
+
+	for _, sb := range sbs {
+		if sb.Metadata.Namespace == namespace && sb.Metadata.Name == name && (latest == nil || sb.CreatedAt.After(latest.CreatedAt)) {
+			latest = sb
+		}
+	}
+
+	if latest == nil {
+		return nil, fmt.Errorf("pod %w: %s/%s", shared.NewErrNotFound(), namespace, name)
+	}
+
+	return latest, nil
+}
+
+// getLXDContainer writes the LXD container as LXD returns it, with its ETag. Only containers of LXE are returned
+func (a *adminService) getLXDContainer(w http.ResponseWriter, id string) {
+	_, err := a.runtimeServer.lxf.GetContainer(id)
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	ct, etag, err := a.runtimeServer.lxf.GetServer().GetContainer(id)
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	writeAdminJSON(w, http.StatusOK, ct)
+}
+
+// getLXDProfile writes the LXD profile of the sandbox as LXD returns it, with its ETag
+func (a *adminService) getLXDProfile(w http.ResponseWriter, id string) {
+	_, err := a.runtimeServer.lxf.GetSandbox(id)
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	p, etag, err := a.runtimeServer.lxf.GetServer().GetProfile(id)
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	writeAdminJSON(w, http.StatusOK, p)
+}
+
+// readConsole writes the console log of the container as text, the last lines if requested
+func (a *adminService) readConsole(w http.ResponseWriter, r *http.Request, id string) {
+	lines := 0
+
+	if q := r.URL.Query().Get("lines"); q != "" {
+		var err error
+
+		lines, err = strconv.Atoi(q)
+		if err != nil || lines < 0 {
+			writeAdminError(w, http.StatusBadRequest, errors.New("lines must be a positive number"))
+			return
+		}
+	}
+
+	c, err := a.runtimeServer.lxf.GetContainer(id)
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	b, err := c.ConsoleLogTail(lines, maxConsoleLog)
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	_, err = w.Write(b)
+	if err != nil {
+		log.WithError(err).Warn("unable to write admin response")
+	}
+}
@@ -0,0 +1,94 @@
+package cri
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminService_resolveContainer(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	now := time.Now()
+	old := &lxf.Sandbox{}
+	old.ID = "old"
+	old.Metadata = lxf.SandboxMetadata{Namespace: "default", Name: "nginx"}
+	old.CreatedAt = now.Add(-time.Hour)
+	current := &lxf.Sandbox{}
+	current.ID = "current"
+	current.Metadata = lxf.SandboxMetadata{Namespace: "default", Name: "nginx"}
+	current.CreatedAt = now
+	fake.ListSandboxesReturns([]*lxf.Sandbox{old, current}, nil)
+
+	first := &lxf.Container{}
+	first.ID = "first"
+	first.Metadata.Name = "nginx"
+	first.CreatedAt = now
+	restarted := &lxf.Container{}
+	restarted.ID = "restarted"
+	restarted.Metadata.Name = "nginx"
+	restarted.CreatedAt = now.Add(time.Minute)
+	fake.FilterContainersReturns([]*lxf.Container{first, restarted}, nil)
+
+	id, err := a.resolveContainer("default_nginx_nginx")
+	assert.NoError(t, err)
+	assert.Equal(t, "restarted", id)
+	assert.Equal(t, lxf.ContainerFilter{SandboxID: "current"}, fake.FilterContainersArgsForCall(0))
+
+	id, err = a.resolveSandbox("default_nginx")
+	assert.NoError(t, err)
+	assert.Equal(t, "current", id)
+
+	// ids are kept
+	id, err = a.resolveContainer("abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", id)
+
+	_, err = a.resolveContainer("default_nginx_sidecar")
+	assert.Error(t, err)
+
+	_, err = a.resolveSandbox("default_other")
+	assert.Error(t, err)
+}
+
+func TestAdminService_GetLXDContainer(t *testing.T) {
+	t.Parallel()
+
+	s, fake, fakeServer := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	fake.GetContainerReturns(&lxf.Container{}, nil)
+	fakeServer.GetContainerReturns(&api.Container{Name: "abc", Status: "Running"}, "etag", nil)
+
+	rec := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/containers/abc/lxd", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "etag", rec.Header().Get("ETag"))
+
+	ct := api.Container{}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&ct))
+	assert.Equal(t, "abc", ct.Name)
+	assert.Equal(t, "Running", ct.Status)
+	assert.Equal(t, "abc", fake.GetContainerArgsForCall(0), "only containers of LXE")
+}
+
+func TestAdminService_ReadConsole_Lines(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	rec := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/containers/abc/console?lines=-1", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, 0, fake.GetContainerCallCount())
+}
@@ -0,0 +1,243 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// AdminSandbox is the state of a sandbox as LXE stores it in LXD
+type AdminSandbox struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	UID       string    `json:"uid"`
+	Attempt   uint32    `json:"attempt"`
+	State     string    `json:"state"`
+	CreatedAt time.Time `json:"created_at"`
+	// Profile is the LXD profile the sandbox is stored in
+	Profile     string            `json:"profile"`
+	NetworkMode string            `json:"network_mode"`
+	NetworkData map[string]string `json:"network_data,omitempty"`
+	LastError   *AdminLastError   `json:"last_error,omitempty"`
+}
+
+// AdminContainer is the state of a container as LXE stores it in LXD
+type AdminContainer struct {
+	// ID is also the name of the LXD container
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Attempt    uint32    `json:"attempt"`
+	SandboxID  string    `json:"sandbox_id"`
+	State      string    `json:"state"`
+	Image      string    `json:"image"`
+	Profiles   []string  `json:"profiles"`
+	Privileged bool      `json:"privileged"`
+	Location   string    `json:"location,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	ExitCode   int32     `json:"exit_code"`
+	// Restarts is how often LXD started the container again by itself
+	Restarts int `json:"restarts,omitempty"`
+	// StoragePool of the root disk, which might be inherited from a profile
+	StoragePool    string `json:"storage_pool,omitempty"`
+	EvictionReason string `json:"eviction_reason,omitempty"`
+	// Pid, NetnsPath and Processes are only set when a single running container is requested
+	Pid       int64           `json:"pid,omitempty"`
+	NetnsPath string          `json:"netns_path,omitempty"`
+	Processes uint64          `json:"processes,omitempty"`
+	LastError *AdminLastError `json:"last_error,omitempty"`
+}
+
+// AdminLastError is the most recent failed CRI call concerning a sandbox or container
+type AdminLastError struct {
+	Method string    `json:"method"`
+	Error  string    `json:"error"`
+	At     time.Time `json:"at"`
+}
+
+// callErrors remembers the most recent failed CRI call per sandbox and container id for debugging
+type callErrors struct {
+	mu   sync.Mutex
+	last map[string]AdminLastError
+}
+
+var lastCallErrors = &callErrors{last: map[string]AdminLastError{}}
+
+// record remembers the error of a failed call for the sandbox or container of the request. A successful removal forgets
+// the error of the removed sandbox or container
+func (e *callErrors) record(method string, req interface{}, err error) {
+	id := callObjectID(req)
+	if id == "" {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err != nil {
+		e.last[id] = AdminLastError{Method: method, Error: err.Error(), At: time.Now()}
+	} else if method == "RemoveContainer" || method == "RemovePodSandbox" {
+		delete(e.last, id)
+	}
+}
+
+func (e *callErrors) get(id string) *AdminLastError {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if le, has := e.last[id]; has {
+		return &le
+	}
+
+	return nil
+}
+
+// callObjectID returns the container or otherwise the sandbox id the CRI request concerns, empty if none
+func callObjectID(req interface{}) string {
+	if r, ok := req.(interface{ GetContainerId() string }); ok && r.GetContainerId() != "" {
+		return r.GetContainerId()
+	}
+
+	if r, ok := req.(interface{ GetPodSandboxId() string }); ok {
+		return r.GetPodSandboxId()
+	}
+
+	return ""
+}
+
+func toAdminSandbox(sb *lxf.Sandbox) AdminSandbox {
+	return AdminSandbox{
+		ID:          sb.ID,
+		Name:        sb.Metadata.Name,
+		Namespace:   sb.Metadata.Namespace,
+		UID:         sb.Metadata.UID,
+		Attempt:     sb.Metadata.Attempt,
+		State:       sb.State.String(),
+		CreatedAt:   sb.CreatedAt,
+		Profile:     sb.ID,
+		NetworkMode: string(sb.NetworkConfig.Mode),
+		NetworkData: sb.NetworkConfig.ModeData,
+		LastError:   lastCallErrors.get(sb.ID),
+	}
+}
+
+func toAdminContainer(c *lxf.Container, pool string) AdminContainer {
+	return AdminContainer{
+		ID:             c.ID,
+		Name:           c.Metadata.Name,
+		Attempt:        c.Metadata.Attempt,
+		SandboxID:      c.SandboxID(),
+		State:          string(c.StateName),
+		Image:          c.Image,
+		Profiles:       c.Profiles,
+		Privileged:     c.Privileged,
+		Location:       c.Location,
+		CreatedAt:      c.CreatedAt,
+		StartedAt:      c.StartedAt,
+		FinishedAt:     c.FinishedAt,
+		ExitCode:       c.ExitCode,
+		Restarts:       c.RestartCount,
+		StoragePool:    pool,
+		EvictionReason: c.EvictionReason,
+		LastError:      lastCallErrors.get(c.ID),
+	}
+}
+
+// rootPool returns the storage pool of the root disk in the expanded devices of the LXD container
+func rootPool(ct *api.Container) string {
+	for _, d := range ct.ExpandedDevices {
+		if d["type"] == "disk" && d["path"] == "/" {
+			return d["pool"]
+		}
+	}
+
+	return ""
+}
+
+func (a *adminService) listSandboxes(w http.ResponseWriter) {
+	sbs, err := a.runtimeServer.lxf.ListSandboxes()
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	res := make([]AdminSandbox, 0, len(sbs))
+	for _, sb := range sbs {
+		res = append(res, toAdminSandbox(sb))
+	}
+
+	writeAdminJSON(w, http.StatusOK, res)
+}
+
+func (a *adminService) getSandbox(w http.ResponseWriter, id string) {
+	sb, err := a.runtimeServer.lxf.GetSandbox(id)
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, toAdminSandbox(sb))
+}
+
+func (a *adminService) listContainers(w http.ResponseWriter) {
+	cl, err := a.runtimeServer.lxf.ListContainers()
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	// the pools are only known from the expanded devices, which are fetched at once
+	cts, err := a.runtimeServer.lxf.GetServer().GetContainers()
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	pools := make(map[string]string, len(cts))
+	for i := range cts {
+		pools[cts[i].Name] = rootPool(&cts[i])
+	}
+
+	res := make([]AdminContainer, 0, len(cl))
+	for _, c := range cl {
+		res = append(res, toAdminContainer(c, pools[c.ID]))
+	}
+
+	writeAdminJSON(w, http.StatusOK, res)
+}
+
+func (a *adminService) getContainer(w http.ResponseWriter, id string) {
+	c, err := a.runtimeServer.lxf.GetContainer(id)
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	ct, _, err := a.runtimeServer.lxf.GetServer().GetContainer(id)
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	res := toAdminContainer(c, rootPool(ct))
+
+	if c.StateName == lxf.ContainerStateRunning {
+		st, err := c.State()
+		if err != nil {
+			writeAdminLXFError(w, err)
+			return
+		}
+
+		res.Pid = st.Pid
+		res.NetnsPath = fmt.Sprintf("/proc/%d/ns/net", st.Pid)
+		res.Processes = st.Stats.Processes
+	}
+
+	writeAdminJSON(w, http.StatusOK, res)
+}
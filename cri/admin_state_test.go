@@ -0,0 +1,116 @@
+package cri
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func Test_callErrors(t *testing.T) {
+	t.Parallel()
+
+	e := &callErrors{last: map[string]AdminLastError{}}
+
+	e.record("StartContainer", &rtApi.StartContainerRequest{ContainerId: "abc"}, errors.New("failed"))
+	e.record("RunPodSandbox", &rtApi.RunPodSandboxRequest{}, errors.New("no id"))
+	e.record("StopPodSandbox", &rtApi.StopPodSandboxRequest{PodSandboxId: "sb"}, errors.New("sandbox failed"))
+	e.record("StopPodSandbox", &rtApi.StopPodSandboxRequest{PodSandboxId: "sb"}, nil)
+
+	assert.Len(t, e.last, 2)
+	assert.Equal(t, "StartContainer", e.get("abc").Method)
+	assert.Equal(t, "failed", e.get("abc").Error)
+	assert.Equal(t, "sandbox failed", e.get("sb").Error, "a later success doesn't forget the error")
+
+	e.record("RemoveContainer", &rtApi.RemoveContainerRequest{ContainerId: "abc"}, nil)
+	assert.Nil(t, e.get("abc"))
+}
+
+func TestAdminService_ListContainers(t *testing.T) {
+	t.Parallel()
+
+	s, fake, fakeServer := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	c := &lxf.Container{}
+	c.ID = "admin-list"
+	c.Profiles = []string{"default", "sb"}
+	c.StateName = lxf.ContainerStateRunning
+	fake.ListContainersReturns([]*lxf.Container{c}, nil)
+	fakeServer.GetContainersReturns([]api.Container{{
+		Name:            "admin-list",
+		ExpandedDevices: map[string]map[string]string{"root": {"type": "disk", "path": "/", "pool": "fast"}},
+	}}, nil)
+
+	lastCallErrors.record("StartContainer", &rtApi.StartContainerRequest{ContainerId: "admin-list"}, errors.New("failed"))
+
+	rec := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/containers", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	res := []AdminContainer{}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+	assert.Len(t, res, 1)
+	assert.Equal(t, "sb", res[0].SandboxID)
+	assert.Equal(t, "fast", res[0].StoragePool)
+	assert.Equal(t, "StartContainer", res[0].LastError.Method)
+}
+
+func TestAdminClient(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	dir, err := ioutil.TempDir("", "lxe-admin")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "admin.sock")
+	sock, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+
+	go a.server.Serve(sock) // nolint: errcheck
+	defer a.server.Close()
+
+	sb := &lxf.Sandbox{}
+	sb.ID = "sb"
+	sb.Metadata = lxf.SandboxMetadata{Name: "web", Namespace: "infra"}
+	sb.State = lxf.SandboxReady
+	sb.NetworkConfig = lxf.NetworkConfig{Mode: lxf.NetworkBridged, ModeData: map[string]string{"interface-address": "10.0.0.2"}}
+	fake.ListSandboxesReturns([]*lxf.Sandbox{sb}, nil)
+	fake.GetSandboxReturns(nil, shared.NewErrNotFound())
+
+	client, err := NewAdminClient(socket)
+	assert.NoError(t, err)
+
+	sbs, err := client.ListSandboxes()
+	assert.NoError(t, err)
+	assert.Len(t, sbs, 1)
+	assert.Equal(t, "infra", sbs[0].Namespace)
+	assert.Equal(t, "sb", sbs[0].Profile)
+	assert.Equal(t, "10.0.0.2", sbs[0].NetworkData["interface-address"])
+
+	_, err = client.GetSandbox("missing")
+	assert.True(t, shared.IsErrNotFound(err))
+}
+
+func TestNewAdminClient_Missing(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewAdminClient("")
+	assert.True(t, errors.Is(err, ErrAdminSocketMissing))
+}
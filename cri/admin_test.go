@@ -0,0 +1,108 @@
+package cri
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminService_ListSnapshots(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	fake.ListSnapshotsReturns([]lxf.Snapshot{{Name: "before-upgrade", CreatedAt: created, Stateful: true}}, nil)
+
+	rec := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/containers/abc/snapshots", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "abc", fake.ListSnapshotsArgsForCall(0))
+
+	res := []adminSnapshot{}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+	assert.Equal(t, []adminSnapshot{{Name: "before-upgrade", CreatedAt: created, Stateful: true}}, res)
+}
+
+func TestAdminService_CreateSnapshot(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	rec := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/containers/abc/snapshots", strings.NewReader(`{"name":"snap0","stateful":true}`)))
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, 1, fake.CreateSnapshotCallCount())
+
+	_, id, name, stateful := fake.CreateSnapshotArgsForCall(0)
+	assert.Equal(t, "abc", id)
+	assert.Equal(t, "snap0", name)
+	assert.True(t, stateful)
+}
+
+func TestAdminService_CreateSnapshot_InvalidName(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	rec := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/containers/abc/snapshots", strings.NewReader(`{"name":"a/b"}`)))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, 0, fake.CreateSnapshotCallCount())
+}
+
+func TestAdminService_RestoreSnapshot(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	rec := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/containers/abc/snapshots/snap0/restore", nil))
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	_, id, name, stateful := fake.RestoreSnapshotArgsForCall(0)
+	assert.Equal(t, "abc", id)
+	assert.Equal(t, "snap0", name)
+	assert.False(t, stateful)
+}
+
+func TestAdminService_DeleteSnapshot_NotFound(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	fake.DeleteSnapshotReturns(shared.NewErrNotFound())
+
+	rec := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/containers/abc/snapshots/snap0", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdminService_UnknownRoute(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	rec := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/containers/abc/snapshots", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,21 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"net/http"
+)
+
+// handleTopology routes the requests to /topology
+func (a *adminService) handleTopology(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAdminError(w, http.StatusNotFound, ErrAdminRoute)
+		return
+	}
+
+	topology, err := a.runtimeServer.numaTopology()
+	if err != nil {
+		writeAdminLXFError(w, err)
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, topology)
+}
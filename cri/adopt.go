@@ -0,0 +1,88 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+var (
+	ErrInvalidAdoptName = errors.New("invalid name")
+)
+
+// AdoptPod marks the LXD container, which isn't managed by LXE, to be adopted by the pod namespace/name and returns a
+// static pod manifest for kubelet. When kubelet creates the pod, the container is adopted as its container instead of
+// creating a new one
+func (s RuntimeServer) AdoptPod(ctx context.Context, id, namespace, name string) ([]byte, error) {
+	for _, n := range []string{id, namespace, name} {
+		if errs := validation.IsDNS1123Label(n); len(errs) > 0 {
+			return nil, fmt.Errorf("%w %q: %s", ErrInvalidAdoptName, n, strings.Join(errs, ", "))
+		}
+	}
+
+	c, err := s.lxf.MarkAdoption(ctx, id, adoptPodName(namespace, name))
+	if err != nil {
+		return nil, err
+	}
+
+	privileged := c.Privileged
+
+	pod := corev1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: map[string]string{annotation.Adopt.Name: id},
+		},
+		Spec: corev1.PodSpec{
+			// a restarted container would be created from the image, the adopted one is only kept once
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{
+					Name: id,
+					// the digest pins the base image of the container, regardless of the alias
+					Image:           fmt.Sprintf("%s@sha256:%s", id, c.Image),
+					ImagePullPolicy: corev1.PullNever,
+					SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+				},
+			},
+		},
+	}
+
+	return yaml.Marshal(pod)
+}
+
+// adoptContainer returns the LXD container to adopt if the pod requests it for this container. False if a new
+// container must be created
+func (s RuntimeServer) adoptContainer(ctx context.Context, req *rtApi.CreateContainerRequest) (*lxf.Container, bool, error) {
+	id, has := annotation.Adopt.Get(req.GetSandboxConfig().GetAnnotations())
+	meta := req.GetConfig().GetMetadata()
+
+	// only the first attempt adopts, a restarted container is a new one
+	if !has || id != meta.GetName() || meta.GetAttempt() > 0 {
+		return nil, false, nil
+	}
+
+	sbMeta := req.GetSandboxConfig().GetMetadata()
+
+	c, err := s.lxf.AdoptContainer(ctx, id, adoptPodName(sbMeta.GetNamespace(), sbMeta.GetName()), req.GetPodSandboxId())
+	if err != nil {
+		return nil, false, err
+	}
+
+	return c, true, nil
+}
+
+// adoptPodName returns the name of the pod a container is marked for adoption with
+func adoptPodName(namespace, name string) string {
+	return namespace + "/" + name
+}
@@ -0,0 +1,89 @@
+package cri
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func TestRuntimeServer_AdoptPod(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+
+	c := &lxf.Container{Image: "abc", Privileged: true}
+	fake.MarkAdoptionReturns(c, nil)
+
+	manifest, err := s.AdoptPod(ctx, "web", "infra", "web-pod")
+	assert.NoError(t, err)
+
+	_, id, pod := fake.MarkAdoptionArgsForCall(0)
+	assert.Equal(t, "web", id)
+	assert.Equal(t, "infra/web-pod", pod)
+
+	p := corev1.Pod{}
+	assert.NoError(t, yaml.Unmarshal(manifest, &p))
+	assert.Equal(t, "web-pod", p.Name)
+	assert.Equal(t, "infra", p.Namespace)
+	assert.Equal(t, "web", p.Annotations[annotation.Adopt.Name])
+	assert.Equal(t, "web", p.Spec.Containers[0].Name)
+	assert.Equal(t, "web@sha256:abc", p.Spec.Containers[0].Image)
+	assert.Equal(t, corev1.PullNever, p.Spec.Containers[0].ImagePullPolicy)
+	assert.True(t, *p.Spec.Containers[0].SecurityContext.Privileged)
+}
+
+func TestRuntimeServer_AdoptPod_InvalidName(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+
+	_, err := s.AdoptPod(ctx, "Web_1", "default", "web")
+	assert.True(t, errors.Is(err, ErrInvalidAdoptName))
+	assert.Equal(t, 0, fake.MarkAdoptionCallCount())
+}
+
+func TestRuntimeServer_adoptContainer(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	fake.AdoptContainerReturns(&lxf.Container{}, nil)
+
+	req := func(name string, attempt uint32, annotations map[string]string) *rtApi.CreateContainerRequest {
+		return &rtApi.CreateContainerRequest{
+			PodSandboxId: "sb",
+			Config:       &rtApi.ContainerConfig{Metadata: &rtApi.ContainerMetadata{Name: name, Attempt: attempt}},
+			SandboxConfig: &rtApi.PodSandboxConfig{
+				Metadata:    &rtApi.PodSandboxMetadata{Name: "web-pod", Namespace: "infra"},
+				Annotations: annotations,
+			},
+		}
+	}
+	adopt := map[string]string{annotation.Adopt.Name: "web"}
+
+	for _, r := range []*rtApi.CreateContainerRequest{
+		req("web", 0, nil),
+		req("sidecar", 0, adopt),
+		req("web", 1, adopt),
+	} {
+		_, adopted, err := s.adoptContainer(ctx, r)
+		assert.NoError(t, err)
+		assert.False(t, adopted)
+	}
+
+	assert.Equal(t, 0, fake.AdoptContainerCallCount())
+
+	_, adopted, err := s.adoptContainer(ctx, req("web", 0, adopt))
+	assert.NoError(t, err)
+	assert.True(t, adopted)
+
+	_, id, pod, sandboxID := fake.AdoptContainerArgsForCall(0)
+	assert.Equal(t, "web", id)
+	assert.Equal(t, "infra/web-pod", pod)
+	assert.Equal(t, "sb", sandboxID)
+}
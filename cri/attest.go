@@ -0,0 +1,311 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/automaticserver/lxe/shared"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// lxcPayloadPrefix is the cgroup of the container processes of LXC 4 and later, followed by the container name
+	lxcPayloadPrefix = "lxc.payload."
+	// lxcLegacyCgroup is the cgroup of the container processes of older LXC versions, followed by the container name
+	lxcLegacyCgroup = "lxc"
+	// procRoot is where the process information is read from
+	procRoot = "/proc"
+)
+
+var (
+	ErrAttestNoWorkload = errors.New("process is not a workload of LXE")
+	ErrAttestNoPeer     = errors.New("unable to get the peer credentials")
+	ErrAttestForbidden  = errors.New("only the owner of LXE can attest other processes")
+	ErrAttestInvalidPid = errors.New("invalid pid")
+	ErrAttestRoute      = errors.New("unknown attest route")
+)
+
+// peerCredKey is the context key of the peer credentials of the connection
+type peerCredKey struct{}
+
+// attestService resolves which pod and container a process belongs to, so workload attestors (like the one of SPIRE)
+// can identify workloads running in LXE's containers. It is served as REST API on a unix socket:
+//
+//	GET /workload             attest the connecting process using its peer credentials
+//	GET /workload?pid={pid}   attest the process with the pid, only allowed for the owner of LXE
+type attestService struct {
+	runtimeServer *RuntimeServer
+	socket        string
+	sock          net.Listener
+	server        *http.Server
+	// proc is where the process information is read from
+	proc string
+}
+
+// attestWorkload is the response of an attestation
+type attestWorkload struct {
+	Pid       int32                `json:"pid"`
+	Container attestWorkloadObject `json:"container"`
+	Pod       attestWorkloadObject `json:"pod"`
+}
+
+// attestWorkloadObject identifies the container or pod of the workload
+type attestWorkloadObject struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace,omitempty"`
+	UID       string            `json:"uid,omitempty"`
+	Labels    map[string]string `json:"labels"`
+}
+
+func newAttestService(criConfig *Config, runtime *RuntimeServer) *attestService {
+	a := &attestService{
+		runtimeServer: runtime,
+		socket:        criConfig.LXEAttestSocket,
+		proc:          procRoot,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/workload", a.handleWorkload)
+
+	a.server = &http.Server{
+		Handler:     mux,
+		ConnContext: withPeerCred,
+	}
+
+	return a
+}
+
+// serve creates the attest socket and serves the REST API
+func (a *attestService) serve() error {
+	var err error
+
+	log := log.WithField("socket", a.socket)
+
+	if _, err = os.Stat(a.socket); err == nil {
+		log.Debug("cleaning up stale attest socket")
+
+		err = os.Remove(a.socket)
+		if err != nil {
+			return err
+		}
+	}
+
+	a.sock, err = net.Listen("unix", a.socket)
+	if err != nil {
+		return err
+	}
+
+	// every process may attest itself, attesting other processes is checked per request
+	err = os.Chmod(a.socket, 0666) // nolint: gosec
+	if err != nil {
+		return err
+	}
+
+	log.Info("started attest service")
+
+	err = a.server.Serve(a.sock)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// stop closes the attest socket
+func (a *attestService) stop() error {
+	err := a.server.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(a.socket)
+}
+
+// withPeerCred adds the peer credentials of unix connections to the request context
+func withPeerCred(ctx context.Context, c net.Conn) context.Context {
+	uc, is := c.(*net.UnixConn)
+	if !is {
+		return ctx
+	}
+
+	cred, err := peerCred(uc)
+	if err != nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, peerCredKey{}, cred)
+}
+
+// peerCred returns the credentials of the process which connected
+func peerCred(uc *net.UnixConn) (*unix.Ucred, error) {
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		cred    *unix.Ucred
+		credErr error
+	)
+
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cred, credErr
+}
+
+func (a *attestService) handleWorkload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAdminError(w, http.StatusNotFound, ErrAttestRoute)
+		return
+	}
+
+	cred, has := r.Context().Value(peerCredKey{}).(*unix.Ucred)
+	if !has {
+		writeAdminError(w, http.StatusInternalServerError, ErrAttestNoPeer)
+		return
+	}
+
+	pid := cred.Pid
+
+	if q := r.URL.Query().Get("pid"); q != "" {
+		if int(cred.Uid) != os.Getuid() {
+			writeAdminError(w, http.StatusForbidden, ErrAttestForbidden)
+			return
+		}
+
+		// the uid of a process in a container can map to the same uid on the host, so only processes on the host may
+		// attest other processes
+		_, err := containerOfPid(a.proc, cred.Pid)
+		if !errors.Is(err, ErrAttestNoWorkload) {
+			writeAdminError(w, http.StatusForbidden, ErrAttestForbidden)
+			return
+		}
+
+		p, err := strconv.ParseInt(q, 10, 32)
+		if err != nil || p <= 0 {
+			writeAdminError(w, http.StatusBadRequest, fmt.Errorf("%w: %s", ErrAttestInvalidPid, q))
+			return
+		}
+
+		pid = int32(p)
+	}
+
+	workload, err := a.attest(pid)
+	if err != nil {
+		if errors.Is(err, ErrAttestNoWorkload) {
+			writeAdminError(w, http.StatusNotFound, err)
+			return
+		}
+
+		writeAdminLXFError(w, err)
+
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, workload)
+}
+
+// attest resolves the container and pod of the process
+func (a *attestService) attest(pid int32) (*attestWorkload, error) {
+	name, err := containerOfPid(a.proc, pid)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := a.runtimeServer.lxf.GetContainer(name)
+	if err != nil {
+		// containers not managed by LXE are no workloads
+		if shared.IsErrNotFound(err) {
+			return nil, fmt.Errorf("%w: pid %d", ErrAttestNoWorkload, pid)
+		}
+
+		return nil, err
+	} else if len(c.Profiles) == 0 {
+		return nil, fmt.Errorf("%w: container %s has no sandbox", ErrAttestNoWorkload, name)
+	}
+
+	sb, err := a.runtimeServer.lxf.GetSandbox(c.SandboxID())
+	if err != nil {
+		return nil, err
+	}
+
+	return &attestWorkload{
+		Pid: pid,
+		Container: attestWorkloadObject{
+			ID:     c.ID,
+			Name:   c.Metadata.Name,
+			Labels: c.Labels,
+		},
+		Pod: attestWorkloadObject{
+			ID:        sb.ID,
+			Name:      sb.Metadata.Name,
+			Namespace: sb.Metadata.Namespace,
+			UID:       sb.Metadata.UID,
+			Labels:    sb.Labels,
+		},
+	}, nil
+}
+
+// containerOfPid returns the name of the LXD container the process runs in by looking at its cgroups
+func containerOfPid(proc string, pid int32) (string, error) {
+	f, err := os.Open(filepath.Join(proc, strconv.Itoa(int(pid)), "cgroup"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("%w: pid %d doesn't exist", ErrAttestNoWorkload, pid)
+		}
+
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		if name := containerOfCgroup(parts[2]); name != "" {
+			return name, nil
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("%w: pid %d", ErrAttestNoWorkload, pid)
+}
+
+// containerOfCgroup returns the container name of the cgroup path, empty if it's not the cgroup of a container
+func containerOfCgroup(path string) string {
+	segments := strings.Split(path, "/")
+
+	for i, s := range segments {
+		if strings.HasPrefix(s, lxcPayloadPrefix) {
+			return strings.TrimPrefix(s, lxcPayloadPrefix)
+		}
+
+		if s == lxcLegacyCgroup && i+1 < len(segments) {
+			return segments[i+1]
+		}
+	}
+
+	return ""
+}
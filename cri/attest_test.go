@@ -0,0 +1,148 @@
+package cri
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/automaticserver/lxe/cri/crifakes"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/shared"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func testAttestService(t *testing.T, cgroups map[string]string) (*attestService, *crifakes.FakeClient) {
+	s, fake, _ := testRuntimeServer()
+	a := newAttestService(s.criConfig, s)
+
+	dir, err := ioutil.TempDir("", "lxe-proc")
+	assert.NoError(t, err)
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	a.proc = dir
+
+	for pid, cgroup := range cgroups {
+		assert.NoError(t, os.MkdirAll(filepath.Join(a.proc, pid), 0755))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(a.proc, pid, "cgroup"), []byte(cgroup), 0644))
+	}
+
+	return a, fake
+}
+
+func testAttestRequest(a *attestService, target string, cred *unix.Ucred) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req = req.WithContext(context.WithValue(req.Context(), peerCredKey{}, cred))
+
+	a.server.Handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func Test_containerOfCgroup(t *testing.T) {
+	t.Parallel()
+
+	for path, exp := range map[string]string{
+		"/lxc.payload.abc":            "abc",
+		"/lxc.payload.abc/init.scope": "abc",
+		"/lxc/abc":                    "abc",
+		"/lxc/abc/system.slice":       "abc",
+		"/lxc.monitor.abc":            "",
+		"/user.slice/user-1000.slice": "",
+		"/":                           "",
+	} {
+		assert.Equal(t, exp, containerOfCgroup(path), path)
+	}
+}
+
+func TestAttestService_Self(t *testing.T) {
+	t.Parallel()
+
+	a, fake := testAttestService(t, map[string]string{
+		"42": "12:pids:/lxc.payload.abc/init.scope\n0::/lxc.payload.abc/init.scope\n",
+	})
+
+	c := &lxf.Container{Profiles: []string{"sb"}}
+	c.ID = "abc"
+	c.Metadata.Name = "web"
+	c.Labels = map[string]string{"app": "web"}
+	fake.GetContainerReturns(c, nil)
+
+	sb := &lxf.Sandbox{}
+	sb.ID = "sb"
+	sb.Metadata = lxf.SandboxMetadata{Name: "web-0", Namespace: "default", UID: "uid-1"}
+	fake.GetSandboxReturns(sb, nil)
+
+	rec := testAttestRequest(a, "/workload", &unix.Ucred{Pid: 42, Uid: 1000})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "abc", fake.GetContainerArgsForCall(0))
+	assert.Equal(t, "sb", fake.GetSandboxArgsForCall(0))
+
+	res := attestWorkload{}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+	assert.Equal(t, attestWorkload{
+		Pid:       42,
+		Container: attestWorkloadObject{ID: "abc", Name: "web", Labels: map[string]string{"app": "web"}},
+		Pod:       attestWorkloadObject{ID: "sb", Name: "web-0", Namespace: "default", UID: "uid-1"},
+	}, res)
+}
+
+func TestAttestService_Pid(t *testing.T) {
+	t.Parallel()
+
+	a, fake := testAttestService(t, map[string]string{
+		"42": "0::/lxc/abc\n",
+		"43": "0::/lxc.payload.def/init.scope\n",
+	})
+	fake.GetContainerReturns(&lxf.Container{Profiles: []string{"sb"}}, nil)
+	fake.GetSandboxReturns(&lxf.Sandbox{}, nil)
+
+	rec := testAttestRequest(a, "/workload?pid=42", &unix.Ucred{Pid: 1, Uid: uint32(os.Getuid())})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "abc", fake.GetContainerArgsForCall(0))
+
+	rec = testAttestRequest(a, "/workload?pid=42", &unix.Ucred{Pid: 1, Uid: uint32(os.Getuid()) + 1})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = testAttestRequest(a, "/workload?pid=foo", &unix.Ucred{Pid: 1, Uid: uint32(os.Getuid())})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// a process in a container with the same uid can't attest others
+	rec = testAttestRequest(a, "/workload?pid=42", &unix.Ucred{Pid: 43, Uid: uint32(os.Getuid())})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, 1, fake.GetContainerCallCount())
+}
+
+func TestAttestService_NoWorkload(t *testing.T) {
+	t.Parallel()
+
+	a, fake := testAttestService(t, map[string]string{
+		"42": "0::/user.slice/user-1000.slice\n",
+		"43": "0::/lxc.payload.other\n",
+	})
+	fake.GetContainerReturns(nil, shared.NewErrNotFound())
+
+	for _, pid := range []int32{42, 43, 44} {
+		rec := testAttestRequest(a, "/workload", &unix.Ucred{Pid: pid})
+		assert.Equal(t, http.StatusNotFound, rec.Code, pid)
+	}
+
+	assert.Equal(t, 1, fake.GetContainerCallCount())
+}
+
+func TestAttestService_NoPeer(t *testing.T) {
+	t.Parallel()
+
+	a, _ := testAttestService(t, nil)
+
+	rec := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/workload", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
@@ -0,0 +1,271 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf"
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc/peer"
+)
+
+// The lifecycle actions which are audited
+const (
+	auditCreated = "created"
+	auditStarted = "started"
+	auditStopped = "stopped"
+	auditKilled  = "killed"
+	auditFrozen  = "frozen"
+	auditThawed  = "thawed"
+	auditRemoved = "removed"
+)
+
+// The defaults of the audit log rotation
+const (
+	DefaultAuditLogMaxSize    = 100
+	DefaultAuditLogMaxBackups = 3
+)
+
+// auditSelf is the identity of actions LXE does by itself, like evictions
+const auditSelf = "lxe"
+
+// kubelet labels every container with its pod
+const (
+	labelPodNamespace = "io.kubernetes.pod.namespace"
+	labelPodName      = "io.kubernetes.pod.name"
+)
+
+// auditEntry is a lifecycle action on a container. In the container config only the time, identity, reason and error
+// are kept, as the key names the action
+type auditEntry struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action,omitempty"`
+	Container string    `json:"container,omitempty"`
+	Pod       string    `json:"pod,omitempty"`
+	// By is who requested the action, the peer credentials or client certificate of the CRI client or lxe
+	By     string `json:"by"`
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// audit records the action about to be done on the container in its config, it's persisted with the action. The
+// entry is written to the audit log with auditDone
+func (s RuntimeServer) audit(ctx context.Context, c *lxf.Container, action, reason string) *auditEntry {
+	e := &auditEntry{
+		Time:   time.Now().UTC(),
+		Action: action,
+		By:     callerIdentity(ctx),
+		Reason: reason,
+	}
+
+	if c.Labels[labelPodName] != "" {
+		e.Pod = c.Labels[labelPodNamespace] + "/" + c.Labels[labelPodName]
+	}
+
+	b, err := json.Marshal(auditEntry{Time: e.Time, By: e.By, Reason: e.Reason})
+	if err == nil {
+		if c.Audit == nil {
+			c.Audit = map[string]string{}
+		}
+
+		c.Audit[action] = string(b)
+	}
+
+	return e
+}
+
+// auditDone writes the entry with the result of its action on the container to the audit log, if it's enabled
+func (s RuntimeServer) auditDone(e *auditEntry, c *lxf.Container, err error) {
+	if s.auditLog == nil {
+		return
+	}
+
+	// a created container has its id only now
+	e.Container = c.ID
+
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	werr := s.auditLog.write(e)
+	if werr != nil {
+		log.WithError(werr).WithField("containerid", e.Container).Error("unable to write audit log")
+	}
+}
+
+// callerIdentity returns who called the CRI, lxe if the context isn't a CRI call
+func callerIdentity(ctx context.Context) string {
+	p, has := peer.FromContext(ctx)
+	if !has || p.Addr == nil {
+		return auditSelf
+	}
+
+	return p.Addr.String()
+}
+
+// auditLog writes the audit entries as JSON lines to a file, which is rotated once it exceeds the maximum size
+type auditLog struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	// backups is how many rotated files are kept as path.1, path.2 and so on
+	backups int
+	f       *os.File
+	size    int64
+}
+
+// newAuditLog opens the audit log at path for appending, maxSize 0 doesn't rotate it
+func newAuditLog(path string, maxSize int64, backups int) (*auditLog, error) {
+	a := &auditLog{path: path, maxSize: maxSize, backups: backups}
+
+	err := a.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *auditLog) open() error {
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600) // nolint: gomnd
+	if err != nil {
+		return err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	a.f = f
+	a.size = fi.Size()
+
+	return nil
+}
+
+func (a *auditLog) write(e *auditEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	b = append(b, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.maxSize > 0 && a.size > 0 && a.size+int64(len(b)) > a.maxSize {
+		err = a.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := a.f.Write(b)
+	a.size += int64(n)
+
+	return err
+}
+
+// close flushes and closes the file, e.g. on shutdown
+func (a *auditLog) close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	err := a.f.Sync()
+	if err != nil {
+		a.f.Close()
+		return err
+	}
+
+	return a.f.Close()
+}
+
+// rotate moves the current file to path.1, shifting the older ones, and opens a new one. Without backups the file is
+// truncated
+func (a *auditLog) rotate() error {
+	err := a.f.Close()
+	if err != nil {
+		return err
+	}
+
+	if a.backups == 0 {
+		err = os.Remove(a.path)
+	} else {
+		for i := a.backups - 1; i > 0; i-- {
+			err = os.Rename(fmt.Sprintf("%s.%d", a.path, i), fmt.Sprintf("%s.%d", a.path, i+1))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+
+		err = os.Rename(a.path, a.path+".1")
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return a.open()
+}
+
+// identifyingListener accepts connections whose remote address identifies the client: the peer credentials of unix
+// connections or the common name of the client certificate of tls connections
+type identifyingListener struct {
+	net.Listener
+}
+
+func (l identifyingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	addr := &callerAddr{Addr: c.RemoteAddr(), conn: c}
+
+	// the peer credentials are of the process which connected, so they're read right away
+	if uc, is := c.(*net.UnixConn); is {
+		addr.cred, _ = peerCred(uc)
+	}
+
+	return &identifiedConn{Conn: c, addr: addr}, nil
+}
+
+// identifiedConn reports the identifying address as remote address, which grpc passes as peer address of the calls
+type identifiedConn struct {
+	net.Conn
+	addr *callerAddr
+}
+
+func (c *identifiedConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// callerAddr is the address of a CRI client with its identity
+type callerAddr struct {
+	net.Addr
+	conn net.Conn
+	cred *unix.Ucred
+}
+
+func (a *callerAddr) String() string {
+	if a.cred != nil {
+		return fmt.Sprintf("uid=%d,gid=%d,pid=%d", a.cred.Uid, a.cred.Gid, a.cred.Pid)
+	}
+
+	// the handshake is done once a call is received
+	if tc, is := a.conn.(*tls.Conn); is {
+		if certs := tc.ConnectionState().PeerCertificates; len(certs) > 0 {
+			return fmt.Sprintf("cn=%s,addr=%s", certs[0].Subject.CommonName, a.Addr)
+		}
+	}
+
+	return a.Addr.String()
+}
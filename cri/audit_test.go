@@ -0,0 +1,111 @@
+package cri
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc/peer"
+)
+
+func TestRuntimeServer_audit(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-audit-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s := RuntimeServer{}
+	s.auditLog, err = newAuditLog(filepath.Join(dir, "audit.log"), 0, 0)
+	assert.NoError(t, err)
+
+	c := &lxf.Container{}
+	c.ID = "abc"
+	c.Labels = map[string]string{labelPodNamespace: "default", labelPodName: "nginx"}
+
+	addr := &callerAddr{Addr: &net.UnixAddr{Net: "unix"}, cred: &unix.Ucred{Pid: 42, Uid: 0, Gid: 0}}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+
+	e := s.audit(ctx, c, auditKilled, "StopContainer with timeout 0s")
+	s.auditDone(e, c, nil)
+
+	rec := auditEntry{}
+	assert.NoError(t, json.Unmarshal([]byte(c.Audit[auditKilled]), &rec))
+	assert.Equal(t, "uid=0,gid=0,pid=42", rec.By)
+	assert.Equal(t, "StopContainer with timeout 0s", rec.Reason)
+	assert.Empty(t, rec.Action, "the key names the action")
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "audit.log"))
+	assert.NoError(t, err)
+
+	rec = auditEntry{}
+	assert.NoError(t, json.Unmarshal(b, &rec))
+	assert.Equal(t, auditKilled, rec.Action)
+	assert.Equal(t, "abc", rec.Container)
+	assert.Equal(t, "default/nginx", rec.Pod)
+
+	// actions of lxe itself have no caller
+	e = s.audit(context.Background(), c, auditFrozen, ReasonMemoryPressureFrozen)
+	assert.Equal(t, auditSelf, e.By)
+}
+
+func Test_auditLog_Rotate(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-audit-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "audit.log")
+
+	a, err := newAuditLog(p, 100, 2)
+	assert.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, a.write(&auditEntry{Action: auditStarted, Container: fmt.Sprint(i), By: strings.Repeat("x", 40)}))
+	}
+
+	// every entry exceeds the size together with the previous one, and only two backups are kept
+	for file, container := range map[string]string{p: "3", p + ".1": "2", p + ".2": "1"} {
+		b, err := ioutil.ReadFile(file)
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), `"container":"`+container+`"`)
+	}
+
+	_, err = os.Stat(p + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_identifyingListener(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-audit-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l, err := net.Listen("unix", filepath.Join(dir, "sock"))
+	assert.NoError(t, err)
+	defer l.Close()
+
+	go func() {
+		c, err := net.Dial("unix", filepath.Join(dir, "sock"))
+		if err == nil {
+			defer c.Close()
+		}
+	}()
+
+	c, err := identifyingListener{l}.Accept()
+	assert.NoError(t, err)
+	defer c.Close()
+
+	assert.Equal(t, fmt.Sprintf("uid=%d,gid=%d,pid=%d", os.Getuid(), os.Getgid(), os.Getpid()), c.RemoteAddr().String())
+}
@@ -0,0 +1,137 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/automaticserver/lxe/lxf"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+var ErrUnknownCapability = errors.New("unknown capability")
+
+// knownCapabilities are the capabilities of Linux, by their names without the CAP_ prefix
+var knownCapabilities = map[string]bool{
+	"AUDIT_CONTROL": true, "AUDIT_READ": true, "AUDIT_WRITE": true, "BLOCK_SUSPEND": true, "BPF": true,
+	"CHECKPOINT_RESTORE": true, "CHOWN": true, "DAC_OVERRIDE": true, "DAC_READ_SEARCH": true, "FOWNER": true,
+	"FSETID": true, "IPC_LOCK": true, "IPC_OWNER": true, "KILL": true, "LEASE": true, "LINUX_IMMUTABLE": true,
+	"MAC_ADMIN": true, "MAC_OVERRIDE": true, "MKNOD": true, "NET_ADMIN": true, "NET_BIND_SERVICE": true,
+	"NET_BROADCAST": true, "NET_RAW": true, "PERFMON": true, "SETFCAP": true, "SETGID": true, "SETPCAP": true,
+	"SETUID": true, "SYS_ADMIN": true, "SYS_BOOT": true, "SYS_CHROOT": true, "SYS_MODULE": true, "SYS_NICE": true,
+	"SYS_PACCT": true, "SYS_PTRACE": true, "SYS_RAWIO": true, "SYS_RESOURCE": true, "SYS_TIME": true,
+	"SYS_TTY_CONFIG": true, "SYSLOG": true, "WAKE_ALARM": true,
+}
+
+// lxdDroppedCapabilities are the capabilities LXD drops from every unprivileged container
+var lxdDroppedCapabilities = []string{"MAC_ADMIN", "MAC_OVERRIDE", "SYS_MODULE", "SYS_RAWIO", "SYS_TIME"}
+
+// toContainerCapabilities normalizes the capabilities kubelet requests to add and drop, like cap_net_admin to
+// NET_ADMIN. Returns nil if there are none
+func toContainerCapabilities(caps *rtApi.Capability) (*lxf.ContainerCapabilities, error) {
+	add, err := normalizeCapabilities(caps.GetAddCapabilities())
+	if err != nil {
+		return nil, err
+	}
+
+	drop, err := normalizeCapabilities(caps.GetDropCapabilities())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(add) == 0 && len(drop) == 0 {
+		return nil, nil
+	}
+
+	return &lxf.ContainerCapabilities{Add: add, Drop: drop}, nil
+}
+
+// normalizeCapabilities returns the sorted capabilities without duplicates. ALL drops every other name
+func normalizeCapabilities(names []string) ([]string, error) {
+	set := map[string]bool{}
+
+	for _, name := range names {
+		n := strings.TrimPrefix(strings.ToUpper(name), "CAP_")
+
+		if n == lxf.CapabilityAll {
+			return []string{lxf.CapabilityAll}, nil
+		}
+
+		if !knownCapabilities[n] {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownCapability, name)
+		}
+
+		set[n] = true
+	}
+
+	normalized := make([]string, 0, len(set))
+	for n := range set {
+		normalized = append(normalized, n)
+	}
+
+	sort.Strings(normalized)
+
+	return normalized, nil
+}
+
+// capabilitiesRawLXC returns the raw.lxc entries adding and dropping the capabilities of the container. As LXC can only
+// extend its list of dropped capabilities, the list of LXD is cleared and set again without the added ones. Dropping
+// all keeps only the added ones. Adding one LXD doesn't drop changes nothing, the container has it already
+func capabilitiesRawLXC(c *lxf.Container) []string {
+	caps := c.Capabilities
+	if caps == nil || c.Privileged {
+		return nil
+	}
+
+	if len(caps.Drop) == 1 && caps.Drop[0] == lxf.CapabilityAll {
+		if len(caps.Add) == 1 && caps.Add[0] == lxf.CapabilityAll {
+			// added all again, only LXD's drop list applies
+			return nil
+		}
+
+		keep := "none"
+		if len(caps.Add) > 0 {
+			keep = lxcCapabilities(caps.Add)
+		}
+
+		// lxc.cap.drop and lxc.cap.keep are mutually exclusive
+		return []string{"lxc.cap.drop =", "lxc.cap.keep = " + keep}
+	}
+
+	added := map[string]bool{}
+	for _, a := range caps.Add {
+		added[a] = true
+	}
+
+	drop := []string{}
+
+	for _, d := range lxdDroppedCapabilities {
+		if !added[d] && !added[lxf.CapabilityAll] {
+			drop = append(drop, d)
+		}
+	}
+
+	if len(drop) == len(lxdDroppedCapabilities) {
+		// nothing of LXD's list is added, extending it is enough
+		if len(caps.Drop) == 0 {
+			return nil
+		}
+
+		return []string{"lxc.cap.drop = " + lxcCapabilities(caps.Drop)}
+	}
+
+	entries := []string{"lxc.cap.drop ="}
+
+	drop = append(drop, caps.Drop...)
+	if len(drop) > 0 {
+		entries = append(entries, "lxc.cap.drop = "+lxcCapabilities(drop))
+	}
+
+	return entries
+}
+
+// lxcCapabilities formats the capabilities for LXC, lower case and separated by spaces
+func lxcCapabilities(caps []string) string {
+	return strings.ToLower(strings.Join(caps, " "))
+}
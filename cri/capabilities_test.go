@@ -0,0 +1,60 @@
+package cri
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/stretchr/testify/assert"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func Test_toContainerCapabilities(t *testing.T) {
+	t.Parallel()
+
+	caps, err := toContainerCapabilities(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, caps)
+
+	caps, err = toContainerCapabilities(&rtApi.Capability{
+		AddCapabilities:  []string{"net_raw", "CAP_NET_ADMIN", "NET_RAW"},
+		DropCapabilities: []string{"CHOWN", "all"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &lxf.ContainerCapabilities{Add: []string{"NET_ADMIN", "NET_RAW"}, Drop: []string{lxf.CapabilityAll}}, caps)
+
+	_, err = toContainerCapabilities(&rtApi.Capability{AddCapabilities: []string{"NET_TEA"}})
+	assert.True(t, errors.Is(err, ErrUnknownCapability))
+}
+
+func Test_capabilitiesRawLXC(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		caps *lxf.ContainerCapabilities
+		exp  []string
+	}{
+		{nil, nil},
+		// the container has it already
+		{&lxf.ContainerCapabilities{Add: []string{"NET_ADMIN"}}, nil},
+		{&lxf.ContainerCapabilities{Drop: []string{"NET_RAW", "SETUID"}}, []string{"lxc.cap.drop = net_raw setuid"}},
+		{&lxf.ContainerCapabilities{Add: []string{"SYS_TIME"}, Drop: []string{"NET_RAW"}}, []string{
+			"lxc.cap.drop =", "lxc.cap.drop = mac_admin mac_override sys_module sys_rawio net_raw",
+		}},
+		{&lxf.ContainerCapabilities{Add: []string{lxf.CapabilityAll}}, []string{"lxc.cap.drop ="}},
+		{&lxf.ContainerCapabilities{Drop: []string{lxf.CapabilityAll}}, []string{"lxc.cap.drop =", "lxc.cap.keep = none"}},
+		{&lxf.ContainerCapabilities{Add: []string{"CHOWN", "NET_BIND_SERVICE"}, Drop: []string{lxf.CapabilityAll}}, []string{
+			"lxc.cap.drop =", "lxc.cap.keep = chown net_bind_service",
+		}},
+		{&lxf.ContainerCapabilities{Add: []string{lxf.CapabilityAll}, Drop: []string{lxf.CapabilityAll}}, nil},
+	}
+
+	for _, tt := range tests {
+		c := &lxf.Container{Capabilities: tt.caps}
+		assert.Equal(t, tt.exp, capabilitiesRawLXC(c), "%+v", tt.caps)
+	}
+
+	// privileged containers have all capabilities
+	c := &lxf.Container{Privileged: true, Capabilities: &lxf.ContainerCapabilities{Drop: []string{"NET_RAW"}}}
+	assert.Nil(t, capabilitiesRawLXC(c))
+}
@@ -0,0 +1,81 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader provides the certificate of a key pair and loads it again once one of the files changed, so rotated
+// certificates are used for new connections without restarting LXE
+type certReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	// modTime is the latest modification time of the files when the certificate was loaded
+	modTime time.Time
+}
+
+// newCertReloader loads the key pair, it fails if it's invalid
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+
+	_, err := r.GetCertificate(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate returns the certificate for a tls handshake, it's loaded again if a file changed. If that fails, e.g.
+// because only one of the files is rotated yet, the previous certificate is kept till the next handshake
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+	if err == nil && r.cert != nil && !modTime.After(r.modTime) {
+		return r.cert, nil
+	}
+
+	if err == nil {
+		var cert tls.Certificate
+
+		cert, err = tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err == nil {
+			r.cert, r.modTime = &cert, modTime
+			log.WithField("cert", r.certFile).Info("loaded tls certificate")
+
+			return r.cert, nil
+		}
+	}
+
+	if r.cert == nil {
+		return nil, err
+	}
+
+	log.WithError(err).WithField("cert", r.certFile).Warn("unable to reload tls certificate, keeping the previous one")
+
+	return r.cert, nil
+}
+
+// latestModTime returns the latest modification time of the files
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
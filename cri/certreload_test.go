@@ -0,0 +1,90 @@
+package cri
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeKeyPair writes a self signed certificate for the name and its key, with the modification time set
+func writeKeyPair(t *testing.T, certFile, keyFile, name string, modTime time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	assert.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	assert.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+}
+
+func commonName(t *testing.T, r *certReloader) string {
+	t.Helper()
+
+	cert, err := r.GetCertificate(nil)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if !assert.NoError(t, err) {
+		return ""
+	}
+
+	return leaf.Subject.CommonName
+}
+
+func Test_certReloader(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	now := time.Now()
+
+	writeKeyPair(t, certFile, keyFile, "first", now.Add(-time.Minute))
+
+	r, err := newCertReloader(certFile, keyFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "first", commonName(t, r))
+
+	writeKeyPair(t, certFile, keyFile, "rotated", now)
+	assert.Equal(t, "rotated", commonName(t, r))
+
+	assert.NoError(t, os.WriteFile(keyFile, []byte("invalid"), 0600))
+	assert.NoError(t, os.Chtimes(keyFile, now.Add(time.Minute), now.Add(time.Minute)))
+	assert.Equal(t, "rotated", commonName(t, r), "the previous certificate is kept")
+}
+
+func Test_newCertReloader_Invalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := newCertReloader(filepath.Join(t.TempDir(), "missing.crt"), filepath.Join(t.TempDir(), "missing.key"))
+	assert.Error(t, err)
+}
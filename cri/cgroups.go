@@ -0,0 +1,220 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/automaticserver/lxe/lxf"
+	"golang.org/x/sys/unix"
+)
+
+// These are the cgroup drivers of kubelet, which define the format of the cgroup parent
+const (
+	CgroupDriverCgroupfs = "cgroupfs"
+	CgroupDriverSystemd  = "systemd"
+)
+
+// These are the cgroup modes of the host: only cgroup v1, cgroup v1 with the unified hierarchy mounted besides it, or
+// only cgroup v2
+const (
+	CgroupModeLegacy  = "legacy"
+	CgroupModeHybrid  = "hybrid"
+	CgroupModeUnified = "unified"
+)
+
+const (
+	// lxcCgroupDir is the raw.lxc key prefix to place the cgroups of the container and its monitor
+	lxcCgroupDir = "lxc.cgroup.dir."
+	// lxcMonitorPrefix is the cgroup of the monitor of LXC 4 and later, followed by the container name
+	lxcMonitorPrefix = "lxc.monitor."
+	// systemdSliceSuffix ends the name of every systemd slice
+	systemdSliceSuffix = ".slice"
+	// cgroupRoot is where the cgroup hierarchies are mounted
+	cgroupRoot = "/sys/fs/cgroup"
+	// cgroupUnifiedDir is where the unified hierarchy is mounted in the hybrid mode
+	cgroupUnifiedDir = "unified"
+	// cpuShares* and cpuWeight* are the ranges of cpu.shares of cgroup v1 and cpu.weight of cgroup v2
+	cpuSharesMin = 2
+	cpuSharesMax = 262144
+	cpuWeightMin = 1
+	cpuWeightMax = 10000
+)
+
+var (
+	ErrUnknownCgroupDriver = errors.New("unknown cgroup driver")
+	ErrInvalidCgroupParent = errors.New("invalid cgroup parent")
+)
+
+// validateCgroupDriver checks the cgroup driver, empty leaves the cgroups to LXD
+func validateCgroupDriver(driver string) error {
+	switch driver {
+	case "", CgroupDriverCgroupfs, CgroupDriverSystemd:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownCgroupDriver, driver)
+	}
+}
+
+// expandSlice returns the cgroup path of a systemd slice, the parents are part of its name, e.g.
+// kubepods-burstable.slice is located at kubepods.slice/kubepods-burstable.slice
+func expandSlice(slice string) (string, error) {
+	if !strings.HasSuffix(slice, systemdSliceSuffix) || strings.Contains(slice, "/") {
+		return "", fmt.Errorf("%w: %q is not a systemd slice", ErrInvalidCgroupParent, slice)
+	}
+
+	name := strings.TrimSuffix(slice, systemdSliceSuffix)
+	// the root slice
+	if name == "-" {
+		return "/", nil
+	}
+
+	if name == "" || strings.HasPrefix(name, "-") || strings.HasSuffix(name, "-") || strings.Contains(name, "--") {
+		return "", fmt.Errorf("%w: %q is not a systemd slice", ErrInvalidCgroupParent, slice)
+	}
+
+	dir := "/"
+	prefix := ""
+
+	for _, part := range strings.Split(name, "-") {
+		dir = path.Join(dir, prefix+part+systemdSliceSuffix)
+		prefix += part + "-"
+	}
+
+	return dir, nil
+}
+
+// cgroupParentDir returns the cgroup path of the cgroup parent kubelet provided for the pod in the format of the
+// cgroup driver. Empty if the cgroups are left to LXD
+func (s RuntimeServer) cgroupParentDir(parent string) (string, error) {
+	if parent == "" {
+		return "", nil
+	}
+
+	switch s.criConfig.LXECgroupDriver {
+	case CgroupDriverCgroupfs:
+		if !path.IsAbs(parent) {
+			return "", fmt.Errorf("%w: %q is not an absolute path", ErrInvalidCgroupParent, parent)
+		}
+
+		return path.Clean(parent), nil
+	case CgroupDriverSystemd:
+		return expandSlice(parent)
+	default:
+		return "", nil
+	}
+}
+
+// isCgroupDir checks if the raw.lxc line places the cgroups, as managed by applyCgroupParent
+func isCgroupDir(line string) bool {
+	return strings.HasPrefix(line, lxcCgroupDir)
+}
+
+// applyCgroupParent places the cgroups of the container and its monitor below the pod cgroup kubelet manages, so the
+// pod limits and accounting of kubelet include the container. As the cgroup names contain the container name, they are
+// rendered into its raw.lxc right before it starts. Nothing is done unless --cgroup-driver is set
+func (s RuntimeServer) applyCgroupParent(ctx context.Context, c *lxf.Container) error {
+	if s.criConfig.LXECgroupDriver == "" {
+		return nil
+	}
+
+	sb, err := c.Sandbox()
+	if err != nil {
+		return err
+	}
+
+	dir, err := s.cgroupParentDir(sb.Config[cfgCgroupParent])
+	if err != nil || dir == "" {
+		return err
+	}
+
+	current := c.Config[cfgRawLXC]
+	if _, has := c.Config[cfgRawLXC]; !has {
+		current = sb.Config[cfgRawLXC]
+	}
+
+	raw := strings.Join(append(withoutCgroupDirs(current), cgroupDirRawLXC(dir, c.ID)...), "\n")
+	if raw == c.Config[cfgRawLXC] {
+		return nil
+	}
+
+	c.Config[cfgRawLXC] = raw
+
+	return c.Apply(ctx)
+}
+
+// withoutCgroupDirs returns the lines of the raw.lxc without the ones placing the cgroups
+func withoutCgroupDirs(raw string) []string {
+	lines := []string{}
+
+	for _, line := range strings.Split(raw, "\n") {
+		if line != "" && !isCgroupDir(line) {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+// cgroupDirRawLXC returns the raw.lxc entries placing the cgroups of the container and its monitor in the directory.
+// They keep the names LXC uses by default
+func cgroupDirRawLXC(dir, id string) []string {
+	// the paths are relative to the cgroup root
+	dir = strings.TrimPrefix(dir, "/")
+
+	return []string{
+		lxcCgroupDir + "container = " + path.Join(dir, lxcPayloadPrefix+id),
+		lxcCgroupDir + "monitor = " + path.Join(dir, lxcMonitorPrefix+id),
+	}
+}
+
+// detectCgroupMode returns the cgroup mode of the host by the file systems mounted at the cgroup root
+func detectCgroupMode(root string) (string, error) {
+	var st unix.Statfs_t
+
+	err := unix.Statfs(root, &st)
+	if err != nil {
+		return "", err
+	}
+
+	if st.Type == unix.CGROUP2_SUPER_MAGIC {
+		return CgroupModeUnified, nil
+	}
+
+	err = unix.Statfs(filepath.Join(root, cgroupUnifiedDir), &st)
+	if err == nil && st.Type == unix.CGROUP2_SUPER_MAGIC {
+		return CgroupModeHybrid, nil
+	}
+
+	return CgroupModeLegacy, nil
+}
+
+// cgroupUnified returns if the controllers are only available in the unified hierarchy of cgroup v2. If the cgroup
+// mode of the host is unknown, e.g. as LXD runs on another host, LXD is asked if LXC supports cgroup v2
+func (s RuntimeServer) cgroupUnified() (bool, error) {
+	if s.cgroupMode != "" {
+		return s.cgroupMode == CgroupModeUnified, nil
+	}
+
+	server, _, err := s.lxf.GetServer().GetServer()
+	if err != nil {
+		return false, err
+	}
+
+	return server.Environment.LXCFeatures[lxcFeatureCgroup2] == "true", nil
+}
+
+// cpuSharesToWeight converts the cpu.shares of cgroup v1, which kubelet provides, to the cpu.weight of cgroup v2. It's
+// the same conversion other runtimes use, so pods get the same share of cpu time
+func cpuSharesToWeight(shares uint64) uint64 {
+	if shares < cpuSharesMin {
+		shares = cpuSharesMin
+	} else if shares > cpuSharesMax {
+		shares = cpuSharesMax
+	}
+
+	return cpuWeightMin + ((shares-cpuSharesMin)*(cpuWeightMax-cpuWeightMin))/(cpuSharesMax-cpuSharesMin)
+}
@@ -0,0 +1,143 @@
+package cri
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf"
+	opencontainers "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_validateCgroupDriver(t *testing.T) {
+	t.Parallel()
+
+	for _, d := range []string{"", CgroupDriverCgroupfs, CgroupDriverSystemd} {
+		assert.NoError(t, validateCgroupDriver(d), d)
+	}
+
+	assert.True(t, errors.Is(validateCgroupDriver("cgroupv3"), ErrUnknownCgroupDriver))
+}
+
+func Test_expandSlice(t *testing.T) {
+	t.Parallel()
+
+	for slice, exp := range map[string]string{
+		"-.slice":                        "/",
+		"kubepods.slice":                 "/kubepods.slice",
+		"kubepods-burstable.slice":       "/kubepods.slice/kubepods-burstable.slice",
+		"kubepods-besteffort-pod1.slice": "/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod1.slice",
+	} {
+		dir, err := expandSlice(slice)
+		assert.NoError(t, err, slice)
+		assert.Equal(t, exp, dir, slice)
+	}
+
+	for _, slice := range []string{"kubepods", "/kubepods.slice", "-kubepods.slice", "kubepods-.slice", "kube--pods.slice", ".slice"} {
+		_, err := expandSlice(slice)
+		assert.True(t, errors.Is(err, ErrInvalidCgroupParent), slice)
+	}
+}
+
+func TestRuntimeServer_cgroupParentDir(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+
+	dir, err := s.cgroupParentDir("/kubepods/burstable/pod1")
+	assert.NoError(t, err)
+	assert.Empty(t, dir, "left to LXD without driver")
+
+	s.criConfig.LXECgroupDriver = CgroupDriverCgroupfs
+
+	dir, err = s.cgroupParentDir("/kubepods/burstable/pod1/")
+	assert.NoError(t, err)
+	assert.Equal(t, "/kubepods/burstable/pod1", dir)
+
+	dir, err = s.cgroupParentDir("")
+	assert.NoError(t, err)
+	assert.Empty(t, dir)
+
+	_, err = s.cgroupParentDir("kubepods-burstable-pod1.slice")
+	assert.True(t, errors.Is(err, ErrInvalidCgroupParent))
+
+	s.criConfig.LXECgroupDriver = CgroupDriverSystemd
+
+	dir, err = s.cgroupParentDir("kubepods-pod1.slice")
+	assert.NoError(t, err)
+	assert.Equal(t, "/kubepods.slice/kubepods-pod1.slice", dir)
+
+	_, err = s.cgroupParentDir("/kubepods/burstable/pod1")
+	assert.True(t, errors.Is(err, ErrInvalidCgroupParent))
+}
+
+func Test_cgroupDirRawLXC(t *testing.T) {
+	t.Parallel()
+
+	entries := cgroupDirRawLXC("/kubepods/pod1", "abc")
+	assert.Equal(t, []string{
+		"lxc.cgroup.dir.container = kubepods/pod1/lxc.payload.abc",
+		"lxc.cgroup.dir.monitor = kubepods/pod1/lxc.monitor.abc",
+	}, entries)
+	assert.Equal(t, "abc", containerOfCgroup("/kubepods/pod1/lxc.payload.abc"), "attestation still finds the container")
+
+	lines := withoutCgroupDirs("lxc.apparmor.profile = unconfined\n" + entries[0] + "\n" + entries[1])
+	assert.Equal(t, []string{"lxc.apparmor.profile = unconfined"}, lines)
+}
+
+func TestRuntimeServer_applyCgroupParent_Disabled(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+
+	c := &lxf.Container{}
+	err := s.applyCgroupParent(context.TODO(), c)
+	assert.NoError(t, err)
+}
+
+func Test_detectCgroupMode(t *testing.T) {
+	t.Parallel()
+
+	mode, err := detectCgroupMode(t.TempDir())
+	assert.NoError(t, err)
+	assert.Equal(t, CgroupModeLegacy, mode)
+
+	_, err = detectCgroupMode("/nonexistent")
+	assert.Error(t, err)
+}
+
+func Test_cpuSharesToWeight(t *testing.T) {
+	t.Parallel()
+
+	for shares, exp := range map[uint64]uint64{
+		0:       1,
+		2:       1,
+		1024:    39,
+		262144:  10000,
+		1000000: 10000,
+	} {
+		assert.Equal(t, exp, cpuSharesToWeight(shares), shares)
+	}
+}
+
+func TestRuntimeServer_cgroupRawLXC(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	shares := uint64(1024)
+
+	c := &lxf.Container{}
+	c.Resources = &opencontainers.LinuxResources{CPU: &opencontainers.LinuxCPU{Shares: &shares, Mems: "0"}}
+
+	s.cgroupMode = CgroupModeUnified
+	entries, err := s.cgroupRawLXC(c)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"lxc.cgroup2.cpu.weight = 39", "lxc.cgroup2.cpuset.mems = 0"}, entries)
+
+	s.cgroupMode = CgroupModeHybrid
+	entries, err = s.cgroupRawLXC(c)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"lxc.cgroup.cpu.shares = 1024", "lxc.cgroup.cpuset.mems = 0"}, entries)
+	assert.Zero(t, fakeServer.GetServerCallCount(), "known cgroup mode doesn't ask LXD")
+}
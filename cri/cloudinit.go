@@ -0,0 +1,108 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+// maxCloudInitFile is the largest file cloud-init data is read from, like the size limit of a configmap
+const maxCloudInitFile = 1 << 20
+
+var ErrCloudInitFile = errors.New("unable to read cloud-init data")
+
+// applyCloudInit sets the cloud-init data of the cloud-init annotations of the pod, unless the container environment
+// already set it. Data from a file is read from the kubelet volume mounted at that path
+func applyCloudInit(c *lxf.Container, annotations map[string]string, mounts []*rtApi.Mount) error {
+	sources, err := annotation.ParseCloudInit(annotation.CloudInit.GetAll(annotations))
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]*string{
+		annotation.CloudInitUserData:   &c.CloudInitUserData,
+		annotation.CloudInitVendorData: &c.CloudInitVendorData,
+		annotation.CloudInitMetaData:   &c.CloudInitMetaData,
+	}
+
+	for name, src := range sources {
+		field := fields[name]
+		if *field != "" {
+			continue
+		}
+
+		if src.Path == "" {
+			*field = src.Content
+			continue
+		}
+
+		*field, err = readMountedFile(mounts, src.Path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// readMountedFile reads the file at the path in the container from the kubelet volume mounted there, like a configmap
+// or secret. The file must not resolve outside of the volume
+func readMountedFile(mounts []*rtApi.Mount, containerPath string) (string, error) {
+	var mnt *rtApi.Mount
+
+	for _, m := range mounts {
+		cp := path.Clean(m.GetContainerPath())
+		if !isKubeletVolume(m.GetHostPath()) || (containerPath != cp && !strings.HasPrefix(containerPath, cp+"/")) {
+			continue
+		}
+
+		// the most specific mount hides the others
+		if mnt == nil || len(cp) > len(path.Clean(mnt.GetContainerPath())) {
+			mnt = m
+		}
+	}
+
+	if mnt == nil {
+		return "", fmt.Errorf("%w: %s is not in a configmap, secret, projected or downward api volume", ErrCloudInitFile, containerPath)
+	}
+
+	root, err := filepath.EvalSymlinks(mnt.GetHostPath())
+	if err != nil {
+		return "", err
+	}
+
+	file, err := filepath.EvalSymlinks(filepath.Join(mnt.GetHostPath(), strings.TrimPrefix(containerPath, path.Clean(mnt.GetContainerPath()))))
+	if err != nil {
+		return "", err
+	}
+
+	if file != root && !strings.HasPrefix(file, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s resolves outside of its volume", ErrCloudInitFile, containerPath)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(io.LimitReader(f, maxCloudInitFile+1))
+	if err != nil {
+		return "", err
+	}
+
+	if len(b) > maxCloudInitFile {
+		return "", fmt.Errorf("%w: %s is larger than %d bytes", ErrCloudInitFile, containerPath, maxCloudInitFile)
+	}
+
+	return string(b), nil
+}
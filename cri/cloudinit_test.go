@@ -0,0 +1,70 @@
+package cri
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/stretchr/testify/assert"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+// testConfigMapVolume creates a configmap volume the way kubelet writes it, with the keys linked to a data dir
+func testConfigMapVolume(t *testing.T, files map[string]string) string {
+	vol := filepath.Join(t.TempDir(), "volumes", "kubernetes.io~configmap", "cloud-init")
+	data := filepath.Join(vol, "..2020_01_02")
+
+	assert.NoError(t, os.MkdirAll(data, 0755))
+	assert.NoError(t, os.Symlink("..2020_01_02", filepath.Join(vol, "..data")))
+
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(data, name), []byte(content), 0644))
+		assert.NoError(t, os.Symlink(filepath.Join("..data", name), filepath.Join(vol, name)))
+	}
+
+	return vol
+}
+
+func Test_applyCloudInit(t *testing.T) {
+	t.Parallel()
+
+	vol := testConfigMapVolume(t, map[string]string{"user-data": "#cloud-config\npackages: [nginx]\n"})
+	mounts := []*rtApi.Mount{{ContainerPath: "/etc/cloud/pod", HostPath: vol}}
+
+	c := &lxf.Container{CloudInitMetaData: "instance-id: from-env"}
+
+	err := applyCloudInit(c, map[string]string{
+		annotation.CloudInit.Name + "user-data-from": "/etc/cloud/pod/user-data",
+		annotation.CloudInit.Name + "vendor-data":    "#cloud-config\ntimezone: UTC\n",
+		annotation.CloudInit.Name + "meta-data":      "instance-id: from-annotation",
+	}, mounts)
+	assert.NoError(t, err)
+	assert.Equal(t, "#cloud-config\npackages: [nginx]\n", c.CloudInitUserData)
+	assert.Equal(t, "#cloud-config\ntimezone: UTC\n", c.CloudInitVendorData)
+	assert.Equal(t, "instance-id: from-env", c.CloudInitMetaData, "the environment has priority")
+}
+
+func Test_applyCloudInit_InvalidFile(t *testing.T) {
+	t.Parallel()
+
+	vol := testConfigMapVolume(t, map[string]string{"user-data": "#cloud-config\n"})
+	outside := filepath.Join(t.TempDir(), "secret")
+	assert.NoError(t, os.WriteFile(outside, []byte("secret"), 0600))
+	assert.NoError(t, os.Symlink(outside, filepath.Join(vol, "escape")))
+
+	mounts := []*rtApi.Mount{
+		{ContainerPath: "/etc/cloud/pod", HostPath: vol},
+		{ContainerPath: "/host", HostPath: filepath.Dir(outside)},
+	}
+
+	for _, p := range []string{"/etc/cloud/pod/escape", "/host/secret", "/etc/other/user-data"} {
+		err := applyCloudInit(&lxf.Container{}, map[string]string{annotation.CloudInit.Name + "user-data-from": p}, mounts)
+		assert.True(t, errors.Is(err, ErrCloudInitFile), p)
+	}
+
+	err := applyCloudInit(&lxf.Container{}, map[string]string{annotation.CloudInit.Name + "user-data-from": "/etc/cloud/pod/missing"}, mounts)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
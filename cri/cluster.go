@@ -0,0 +1,45 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"github.com/automaticserver/lxe/annotation"
+)
+
+// LXDTargetSelf places containers on the LXD cluster member LXE is connected to
+const LXDTargetSelf = "self"
+
+// clusterTarget determines the LXD cluster member to create a container of the pod on. The containers of a pod share
+// their network and volumes, so once a container of the pod is placed, all others follow it. Otherwise the pod
+// annotation has priority over the --lxd-target policy. Returns empty if LXD is not clustered or LXD should choose.
+// LXD forwards all later requests for the container to the member it is located on
+func (s RuntimeServer) clusterTarget(sandboxID string, annotations map[string]string) (string, error) {
+	server := s.lxf.GetServer()
+	if !server.IsClustered() {
+		return "", nil
+	}
+
+	cl, err := s.lxf.ListContainers()
+	if err != nil {
+		return "", err
+	}
+
+	for _, c := range cl {
+		if c.SandboxID() == sandboxID && c.Location != "" {
+			return c.Location, nil
+		}
+	}
+
+	if target, has := annotation.TargetMember.Get(annotations); has && target != "" {
+		return target, nil
+	}
+
+	if target := s.config().LXDTarget; target != LXDTargetSelf {
+		return target, nil
+	}
+
+	info, _, err := server.GetServer()
+	if err != nil {
+		return "", err
+	}
+
+	return info.Environment.ServerName, nil
+}
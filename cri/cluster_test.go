@@ -0,0 +1,77 @@
+package cri
+
+import (
+	"testing"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeServer_clusterTarget_NotClustered(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	s.criConfig.LXDTarget = "member1"
+
+	target, err := s.clusterTarget("sb", map[string]string{annotation.TargetMember.Name: "member2"})
+	assert.NoError(t, err)
+	assert.Empty(t, target)
+	assert.Equal(t, 0, fake.ListContainersCallCount())
+}
+
+func TestRuntimeServer_clusterTarget_Policy(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	fakeServer.IsClusteredReturns(true)
+
+	target, err := s.clusterTarget("sb", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, target)
+
+	s.criConfig.LXDTarget = "member1"
+	target, err = s.clusterTarget("sb", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "member1", target)
+
+	target, err = s.clusterTarget("sb", map[string]string{annotation.TargetMember.Name: "member2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "member2", target)
+
+	s.criConfig.LXDTarget = LXDTargetSelf
+	fakeServer.GetServerReturns(&api.Server{Environment: api.ServerEnvironment{ServerName: "member3"}}, "", nil)
+	target, err = s.clusterTarget("sb", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "member3", target)
+}
+
+func TestRuntimeServer_clusterTarget_FollowPod(t *testing.T) {
+	t.Parallel()
+
+	s, fake, fakeServer := testRuntimeServer()
+	fakeServer.IsClusteredReturns(true)
+	s.criConfig.LXDTarget = "member1"
+
+	other := &lxf.Container{Profiles: []string{"other"}, Location: "member2"}
+	sibling := &lxf.Container{Profiles: []string{"sb"}, Location: "member3"}
+	fake.ListContainersReturns([]*lxf.Container{other, sibling}, nil)
+
+	target, err := s.clusterTarget("sb", map[string]string{annotation.TargetMember.Name: "member4"})
+	assert.NoError(t, err)
+	assert.Equal(t, "member3", target)
+}
+
+func Test_toCriStatusResponse_Location(t *testing.T) {
+	t.Parallel()
+
+	c := &lxf.Container{Location: "member1"}
+
+	resp := toCriStatusResponse(c)
+	assert.Equal(t, "member1", resp.Info["location"])
+
+	c.Location = ""
+	resp = toCriStatusResponse(c)
+	assert.Empty(t, resp.Info)
+}
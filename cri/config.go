@@ -1,5 +1,7 @@
 package cri // import "github.com/automaticserver/lxe/cri"
 
+import "time"
+
 // Domain of the daemon
 const Domain = "lxe"
 
@@ -7,32 +9,231 @@ const Domain = "lxe"
 type Config struct {
 	// UnixSocket this LXE will be reachable under
 	UnixSocket string
+	// UnixSocketMode is the octal file mode of UnixSocket
+	UnixSocketMode string
+	// UnixSocketOwner is the owner of UnixSocket in the form [user][:group], empty keeps the owner
+	UnixSocketOwner string
 	// LXDSocket where LXD is reachable under
 	LXDSocket string
 	// LXDRemoteConfig file path where lxd remote settings are stored
 	LXDRemoteConfig string
+	// LXDFake uses an in-memory LXD instead of LXDSocket, to run the CRI validation suite without LXD
+	LXDFake bool
 	// LXDImageRemote to use by default when ImageSpec doesn't provide an explicit remote
 	LXDImageRemote string
+	// LXEImagePolicy is the path of the image policy file restricting which images may be pulled, empty allows all
+	LXEImagePolicy string
+	// LXEImageCacheRemote is the remote images are pulled from first if it has them, e.g. the image cache of another
+	// node. Empty disables it
+	LXEImageCacheRemote string
+	// LXEImageCacheDir is the directory pulled images are exported to, to serve them to other nodes. Empty disables it
+	LXEImageCacheDir string
+	// LXEImageCacheRemotes are the remotes whose pulled images are exported to LXEImageCacheDir, entries ending with *
+	// match as prefix. Images of other remotes, like private registries or local imports, aren't exported
+	LXEImageCacheRemotes []string
+	// LXEImageCacheBindAddr is the listen address LXEImageCacheDir is served at as simplestreams remote
+	LXEImageCacheBindAddr string
 	// LXDProfiles which all cri containers inherit
 	LXDProfiles []string
+	// LXDProject is the LXD project the pods, containers and images are created in. It's created if it doesn't exist,
+	// empty uses the default project
+	LXDProject string
+	// LXDProjectLimits are limits.<key>=value entries which are set on LXDProject
+	LXDProjectLimits []string
+	// LXDTarget is the LXD cluster member to create containers on, "self" for the member LXE is connected to or empty to
+	// let LXD choose
+	LXDTarget string
+	// LXEOwner is the name of this LXE instance, e.g. its node name, if several LXE instances share LXD. Each only sees
+	// the pods it created, empty sees all pods
+	LXEOwner string
+	// LXENamePrefix starts the LXD names of the created pods and containers, e.g. to tell them apart from other LXD
+	// containers
+	LXENamePrefix string
+	// LXDOperationWorkers is the number of LXD operations run concurrently on batches like stopping all containers of a
+	// pod
+	LXDOperationWorkers int
+	// LXDOperationTimeout cancels a single LXD operation after this duration, 0 waits till it's done
+	LXDOperationTimeout time.Duration
+	// LXDOperationRetries is how often a LXD operation failing with a temporary error is retried, 0 disables it
+	LXDOperationRetries int
+	// LXDOperationRetryBackoff is the backoff before the first retry, it doubles with every further retry
+	LXDOperationRetryBackoff time.Duration
+	// LXDScratchPool is the storage pool to place disk backed emptyDirs of pods on, empty keeps them on the host
+	LXDScratchPool string
+	// LXDVolumePools contains the storage pools custom volumes may be requested on with the volumes annotation. Entries
+	// ending with * match as prefix, empty allows none
+	LXDVolumePools []string
+	// LXERuntimeHandlerPools are handler=pool entries to create the root disks of pods with that runtime handler on the
+	// storage pool
+	LXERuntimeHandlerPools []string
+	// LXERuntimeHandlers are handler.option=value entries defining the presets of runtime handlers, options are profiles,
+	// pool, privileged, nesting, overhead-cpu, overhead-memory and ulimit-<resource>
+	LXERuntimeHandlers []string
 	// LXEStreamingBindAddr contains the listen address for the streaming server
 	LXEStreamingBindAddr string
 	// LXEStreamingBaseURL is the base address for constructing streaming URLs
 	LXEStreamingBaseURL string
+	// LXEStreamingTLSCert and LXEStreamingTLSKey are the paths of the certificate and key to serve the streams with tls,
+	// they're loaded again when they change
+	LXEStreamingTLSCert string
+	LXEStreamingTLSKey  string
+	// LXEStreamingCreationTimeout is how long a client may take to create the streams of a session, 0 keeps the default
+	LXEStreamingCreationTimeout time.Duration
+	// LXETCPBindAddr is the additional tcp listen address of the CRI services for remote kubelets, empty disables it
+	LXETCPBindAddr string
+	// LXETCPTLSCert, LXETCPTLSKey are the certificate and key to serve the tcp listener with, only clients with a
+	// certificate signed by LXETCPTLSClientCA are accepted
+	LXETCPTLSCert     string
+	LXETCPTLSKey      string
+	LXETCPTLSClientCA string
+	// LXEGRPCMaxConcurrentStreams limits the concurrent calls per client connection, 0 doesn't limit them
+	LXEGRPCMaxConcurrentStreams uint32
+	// LXEGRPCKeepaliveMinTime is the minimum interval clients may send keepalive pings, 0 keeps the grpc default
+	LXEGRPCKeepaliveMinTime time.Duration
+	// LXEGRPCKeepaliveTime is after how long idle clients are pinged, 0 keeps the grpc default
+	LXEGRPCKeepaliveTime time.Duration
+	// LXEGRPCMaxConcurrentCalls limits the concurrent calls per CRI method of all clients, 0 doesn't limit them
+	LXEGRPCMaxConcurrentCalls int
+	// LXEGRPCMethodConcurrency are method=limit entries which replace LXEGRPCMaxConcurrentCalls for the method, a limit
+	// of 0 doesn't limit it
+	LXEGRPCMethodConcurrency []string
+	// LXEGRPCRateLimit is the rate of CRI calls per second of all clients, 0 doesn't limit it
+	LXEGRPCRateLimit float64
+	// LXEGRPCRateBurst is how many calls may exceed LXEGRPCRateLimit at once
+	LXEGRPCRateBurst int
+	// LXEMetricsBindAddr is the listen address of the prometheus metrics server, empty disables it
+	LXEMetricsBindAddr string
+	// LXEMetricsTLSCert and LXEMetricsTLSKey are the paths of the certificate and key to serve the metrics with tls
+	LXEMetricsTLSCert string
+	LXEMetricsTLSKey  string
+	// LXEHealthBindAddr is the listen address of the /healthz and /readyz endpoints, empty disables them
+	LXEHealthBindAddr string
+	// LXEHealthPprof additionally serves the net/http/pprof endpoints on the health listen address
+	LXEHealthPprof bool
+	// LXETracingEndpoint is the host:port of the OTLP/HTTP collector the spans are exported to, empty disables it
+	LXETracingEndpoint string
+	// LXETracingInsecure exports the spans without tls
+	LXETracingInsecure bool
+	// LXETracingSampleRatio is the fraction of the traces which are exported
+	LXETracingSampleRatio float64
+	// LXEAdminSocket is the path of the socket for the admin api, empty disables it
+	LXEAdminSocket string
+	// LXEAttestSocket is the path of the socket for the workload attestation api, empty disables it
+	LXEAttestSocket string
+	// LXEAuditLog is the path of the file the lifecycle actions on containers are logged to, empty disables it
+	LXEAuditLog string
+	// LXEAuditLogMaxSize is the size in megabytes the audit log is rotated at, 0 never rotates it
+	LXEAuditLogMaxSize int
+	// LXEAuditLogMaxBackups is how many rotated audit logs are kept
+	LXEAuditLogMaxBackups int
+	// LXEStreamingIdleTimeout ends exec and port forward sessions without any transferred data, 0 disables it
+	LXEStreamingIdleTimeout time.Duration
+	// LXEStreamingMaxDuration ends exec and port forward sessions after this duration, 0 disables it
+	LXEStreamingMaxDuration time.Duration
 	// LXEHostnetworkFile file path to use for lxc's raw.include
 	LXEHostnetworkFile string
+	// LXESysctlAllowlist contains the sysctls a pod is allowed to set. Entries ending with * match as prefix
+	LXESysctlAllowlist []string
+	// LXEConfigAllowlist contains the LXD config keys pods may set with annotations. Entries ending with * match as prefix
+	LXEConfigAllowlist []string
+	// LXEConfigDenylist contains the LXD config keys pods may never set with annotations, it has priority over the
+	// allowlist. Entries ending with * match as prefix
+	LXEConfigDenylist []string
+	// LXEMemorySwap defines if containers may swap, true or false. Empty keeps the LXD default
+	LXEMemorySwap string
+	// LXEMemoryEnforce defines how memory limits are enforced, hard or soft. Empty keeps the LXD default
+	LXEMemoryEnforce string
+	// LXEPodPidsLimit is the maximum number of processes in each container, 0 doesn't limit it
+	LXEPodPidsLimit int64
+	// LXEReadonlyRootfsTmpfs are the paths a tmpfs is mounted on in containers with a readonly root filesystem
+	LXEReadonlyRootfsTmpfs []string
+	// LXEValidation defines what happens to pods and containers requesting settings LXE doesn't support, one of strict,
+	// warn
+	LXEValidation string
+	// LXEHooksDir is the directory of the JSON files defining the executables run on the host at lifecycle stages of
+	// sandboxes and containers, empty runs none
+	LXEHooksDir string
+	// LXEShiftMode defines when host path mounts of unprivileged containers are shifted, one of auto, always, never
+	LXEShiftMode string
+	// LXEShiftKubeletVolumes mounts configmap, secret, downward api and projected volumes with shift=true into
+	// unprivileged containers
+	LXEShiftKubeletVolumes bool
+	// LXECgroupDriver is the cgroup driver of kubelet to place the containers below the pod cgroup, one of cgroupfs,
+	// systemd. Empty leaves the cgroups to LXD
+	LXECgroupDriver string
+	// LXEContainerMode is the default mode of containers, one of system, application
+	LXEContainerMode string
+	// LXENamespaceSharing shares the pid and ipc namespaces between containers of a pod as the CRI namespace options
+	// request, otherwise every container has its own
+	LXENamespaceSharing bool
+	// LXEKubernetesServiceEnv is the host:port of the kubernetes service whose environment variables are added to every
+	// container if kubelet didn't set them, empty disables it
+	LXEKubernetesServiceEnv string
+	// LXEEvictionPSIThreshold is the host memory pressure in percent (full avg10) above which the pod with the lowest
+	// priority is evicted, 0 disables the eviction guard
+	LXEEvictionPSIThreshold float64
+	// LXEEvictionAction is what happens to the evicted pod, one of freeze, stop
+	LXEEvictionAction string
+	// LXEEvictionInterval is how often the memory pressure is checked and at most one pod is evicted
+	LXEEvictionInterval time.Duration
+	// LXEOrphanGracePeriod is how long a container whose sandbox is missing is kept before it's deleted, 0 disables the
+	// deletion of orphaned containers
+	LXEOrphanGracePeriod time.Duration
+	// LXEOrphanInterval is how often LXE looks for orphaned containers
+	LXEOrphanInterval time.Duration
+	// LXEStateFile is where the in-memory state, e.g. when orphans were first seen, is saved on shutdown and restored
+	// from at startup. Empty doesn't save it
+	LXEStateFile string
+	// LXEJournalFile is the write-ahead journal of the CRI calls changing pods and containers, the calls interrupted by a
+	// crash are rolled back or done again at startup. Empty disables it
+	LXEJournalFile string
+	// LXEShutdownTimeout is how long a shutdown waits for the running CRI calls, LXD operations and background loops
+	// before it's forced
+	LXEShutdownTimeout time.Duration
+	// LXEQuotaInterval is how often the resources of the containers are compared with the node allocatable, 0 disables it
+	LXEQuotaInterval time.Duration
+	// LXENodeAllocatableCPU and LXENodeAllocatableMemory are the quantities kubelet admits pods against, the capacity of
+	// the LXD server if empty
+	LXENodeAllocatableCPU    string
+	LXENodeAllocatableMemory string
 	// Which LXENetworkPlugin to use
 	LXENetworkPlugin string
+	// LXENetworkMTU is the MTU of the pod interface, 0 keeps the default
+	LXENetworkMTU int
+	// LXENetworkDisableTxChecksum disables tx checksum offloading on the pod interface
+	LXENetworkDisableTxChecksum bool
 	// LXEBridgeName is the name of the bridge to create and use
 	LXEBridgeName string
 	// LXEBridgeDHCPRange to configure for lxebr0 if NetworkPlugin is default
 	LXEBridgeDHCPRange string
+	// LXEBridgeVLANs are namespace=vlan entries to tag the pod nics of a namespace if NetworkPlugin is default
+	LXEBridgeVLANs []string
+	// LXEBridgeDNSDomain enables the dns of lxebr0 and registers the pods as <name>.<namespace>.<domain> if NetworkPlugin
+	// is default
+	LXEBridgeDNSDomain string
 	// CNIConfDir is the path where the cni configuration files are
 	CNIConfDir string
 	// CNIBinDir is the path where the cni plugins are
 	CNIBinDir string
+	// CNINetnsPath is the path where the network namespaces are created
+	CNINetnsPath string
 	// CNIOutputWriter is the writer for CNI call outputs
 	CNIOutputTarget string
 	// CNIOutputFile is the path to a file
 	CNIOutputFile string
+	// CNIPodNetns sets up the network once per pod in a network namespace pinned in CNINetnsPath, which the containers
+	// of the pod join, so the pod ip stays the same when containers restart
+	CNIPodNetns bool
+	// CNIPodCIDRBridge is the name of the bridge of the conflist written to CNIConfDir when kubelet provides the pod CIDR.
+	// If empty, the pod CIDR is ignored
+	CNIPodCIDRBridge string
+	// CNIWatchConfDir watches CNIConfDir and reloads the cni config when it changes
+	CNIWatchConfDir bool
+	// CNIDefaultConf writes a default bridge conflist for the pod CIDR kubelet provides if CNIConfDir has no other config
+	CNIDefaultConf bool
+	// CNIReclaimOnExhaustion releases the network of not running containers and retries once if the ip pool is exhausted
+	CNIReclaimOnExhaustion bool
+	// CNIGCInterval is how often the cni plugins are asked to release the resources of pods which no longer exist, 0
+	// disables it
+	CNIGCInterval time.Duration
 }
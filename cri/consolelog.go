@@ -0,0 +1,93 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf"
+)
+
+// maxConsoleLog is how much of the end of the console log is written to the CRI log of a container
+const maxConsoleLog = 1 << 20
+
+// recordConsoleLog appends the console log of the stopped container to its CRI log, so the output of its boot is shown
+// by kubectl logs. Each run of the container is only written once
+func recordConsoleLog(ctx context.Context, sb *lxf.Sandbox, c *lxf.Container) error {
+	if sb.LogDirectory == "" || c.LogPath == "" || c.StartedAt.IsZero() || c.ConsoleLoggedAt.Equal(c.StartedAt) {
+		return nil
+	}
+
+	b, err := c.ConsoleLogTail(0, maxConsoleLog)
+	if err != nil {
+		return err
+	}
+
+	at := c.FinishedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	err = appendCRILog(filepath.Join(sb.LogDirectory, c.LogPath), at, b)
+	if err != nil {
+		return err
+	}
+
+	c.ConsoleLoggedAt = c.StartedAt
+
+	return c.Apply(ctx)
+}
+
+// appendCRILog appends the lines of content to the log file in the CRI log format kubelet reads, as full lines of
+// stdout at that time, as the console has no timestamps
+func appendCRILog(p string, at time.Time, content []byte) error {
+	if len(content) == 0 {
+		return nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(p), 0755) // nolint: gomnd
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640) // nolint: gomnd
+	if err != nil {
+		return err
+	}
+
+	err = writeCRILog(f, at, content)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func writeCRILog(w io.Writer, at time.Time, content []byte) error {
+	bw := bufio.NewWriter(w)
+	prefix := at.Format(time.RFC3339Nano) + " stdout F "
+
+	for _, line := range bytes.Split(bytes.TrimSuffix(content, []byte("\n")), []byte("\n")) {
+		_, err := bw.WriteString(prefix)
+		if err != nil {
+			return err
+		}
+
+		_, err = bw.Write(bytes.TrimSuffix(line, []byte("\r")))
+		if err != nil {
+			return err
+		}
+
+		err = bw.WriteByte('\n')
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,59 @@
+package cri
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_writeCRILog(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	buf := &bytes.Buffer{}
+
+	assert.NoError(t, writeCRILog(buf, at, []byte("Welcome\r\n\r\nlogin: \n")))
+	assert.Equal(t, "2020-01-02T03:04:05.000000006Z stdout F Welcome\n"+
+		"2020-01-02T03:04:05.000000006Z stdout F \n"+
+		"2020-01-02T03:04:05.000000006Z stdout F login: \n", buf.String())
+}
+
+func Test_appendCRILog(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-log-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "app", "0.log")
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	assert.NoError(t, appendCRILog(p, at, []byte("first\n")))
+	assert.NoError(t, appendCRILog(p, at, []byte("second")))
+	assert.NoError(t, appendCRILog(p, at, nil))
+
+	b, err := ioutil.ReadFile(p)
+	assert.NoError(t, err)
+	assert.Equal(t, "2020-01-02T03:04:05Z stdout F first\n2020-01-02T03:04:05Z stdout F second\n", string(b))
+}
+
+func Test_recordConsoleLog_Once(t *testing.T) {
+	t.Parallel()
+
+	sb := &lxf.Sandbox{LogDirectory: "/var/log/pods/default_app"}
+	c := &lxf.Container{LogPath: "app/0.log"}
+
+	// nothing to do without ever having started, or if the run is already written, so the container isn't accessed
+	assert.NoError(t, recordConsoleLog(context.Background(), sb, c))
+
+	c.StartedAt = time.Now()
+	c.ConsoleLoggedAt = c.StartedAt
+	assert.NoError(t, recordConsoleLog(context.Background(), sb, c))
+}
@@ -2,26 +2,117 @@
 package crifakes // import "github.com/automaticserver/lxe/cri/crifakes"
 
 import (
+	"context"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/lxo"
 	lxd "github.com/lxc/lxd/client"
 	"k8s.io/client-go/tools/remotecommand"
 )
 
 type FakeClient struct {
-	ExecStub        func(string, []string, io.ReadCloser, io.WriteCloser, io.WriteCloser, bool, bool, int64, <-chan remotecommand.TerminalSize) (int32, error)
+	AdoptContainerStub        func(context.Context, string, string, string) (*lxf.Container, error)
+	adoptContainerMutex       sync.RWMutex
+	adoptContainerArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 string
+	}
+	adoptContainerReturns struct {
+		result1 *lxf.Container
+		result2 error
+	}
+	adoptContainerReturnsOnCall map[int]struct {
+		result1 *lxf.Container
+		result2 error
+	}
+	BatchStub        func(int, func(i int) error) error
+	batchMutex       sync.RWMutex
+	batchArgsForCall []struct {
+		arg1 int
+		arg2 func(i int) error
+	}
+	batchReturns struct {
+		result1 error
+	}
+	batchReturnsOnCall map[int]struct {
+		result1 error
+	}
+	CheckpointContainerStub        func(context.Context, string, io.WriteSeeker) error
+	checkpointContainerMutex       sync.RWMutex
+	checkpointContainerArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 io.WriteSeeker
+	}
+	checkpointContainerReturns struct {
+		result1 error
+	}
+	checkpointContainerReturnsOnCall map[int]struct {
+		result1 error
+	}
+	CloseStub        func()
+	closeMutex       sync.RWMutex
+	closeArgsForCall []struct {
+	}
+	CommitContainerStub        func(context.Context, string, string, lxf.CommitOptions) (string, error)
+	commitContainerMutex       sync.RWMutex
+	commitContainerArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 lxf.CommitOptions
+	}
+	commitContainerReturns struct {
+		result1 string
+		result2 error
+	}
+	commitContainerReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
+	CreateSnapshotStub        func(context.Context, string, string, bool) error
+	createSnapshotMutex       sync.RWMutex
+	createSnapshotArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 bool
+	}
+	createSnapshotReturns struct {
+		result1 error
+	}
+	createSnapshotReturnsOnCall map[int]struct {
+		result1 error
+	}
+	DeleteSnapshotStub        func(context.Context, string, string) error
+	deleteSnapshotMutex       sync.RWMutex
+	deleteSnapshotArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+	}
+	deleteSnapshotReturns struct {
+		result1 error
+	}
+	deleteSnapshotReturnsOnCall map[int]struct {
+		result1 error
+	}
+	ExecStub        func(context.Context, string, []string, io.ReadCloser, io.WriteCloser, io.WriteCloser, bool, bool, <-chan remotecommand.TerminalSize) (int32, error)
 	execMutex       sync.RWMutex
 	execArgsForCall []struct {
-		arg1 string
-		arg2 []string
-		arg3 io.ReadCloser
-		arg4 io.WriteCloser
+		arg1 context.Context
+		arg2 string
+		arg3 []string
+		arg4 io.ReadCloser
 		arg5 io.WriteCloser
-		arg6 bool
+		arg6 io.WriteCloser
 		arg7 bool
-		arg8 int64
+		arg8 bool
 		arg9 <-chan remotecommand.TerminalSize
 	}
 	execReturns struct {
@@ -32,6 +123,48 @@ type FakeClient struct {
 		result1 int32
 		result2 error
 	}
+	ExecSyncStub        func(context.Context, string, []string, time.Duration) (*lxo.ExecSyncResult, error)
+	execSyncMutex       sync.RWMutex
+	execSyncArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 []string
+		arg4 time.Duration
+	}
+	execSyncReturns struct {
+		result1 *lxo.ExecSyncResult
+		result2 error
+	}
+	execSyncReturnsOnCall map[int]struct {
+		result1 *lxo.ExecSyncResult
+		result2 error
+	}
+	FilterContainersStub        func(lxf.ContainerFilter) ([]*lxf.Container, error)
+	filterContainersMutex       sync.RWMutex
+	filterContainersArgsForCall []struct {
+		arg1 lxf.ContainerFilter
+	}
+	filterContainersReturns struct {
+		result1 []*lxf.Container
+		result2 error
+	}
+	filterContainersReturnsOnCall map[int]struct {
+		result1 []*lxf.Container
+		result2 error
+	}
+	FilterSandboxesStub        func(lxf.SandboxFilter) ([]*lxf.Sandbox, error)
+	filterSandboxesMutex       sync.RWMutex
+	filterSandboxesArgsForCall []struct {
+		arg1 lxf.SandboxFilter
+	}
+	filterSandboxesReturns struct {
+		result1 []*lxf.Sandbox
+		result2 error
+	}
+	filterSandboxesReturnsOnCall map[int]struct {
+		result1 []*lxf.Sandbox
+		result2 error
+	}
 	GetContainerStub        func(string) (*lxf.Container, error)
 	getContainerMutex       sync.RWMutex
 	getContainerArgsForCall []struct {
@@ -105,6 +238,35 @@ type FakeClient struct {
 	getServerReturnsOnCall map[int]struct {
 		result1 lxd.ContainerServer
 	}
+	ImageRemoteStub        func(string) (string, error)
+	imageRemoteMutex       sync.RWMutex
+	imageRemoteArgsForCall []struct {
+		arg1 string
+	}
+	imageRemoteReturns struct {
+		result1 string
+		result2 error
+	}
+	imageRemoteReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
+	ImportImageStub        func(context.Context, string, io.Reader, io.Reader) (string, error)
+	importImageMutex       sync.RWMutex
+	importImageArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 io.Reader
+		arg4 io.Reader
+	}
+	importImageReturns struct {
+		result1 string
+		result2 error
+	}
+	importImageReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
 	ListContainersStub        func() ([]*lxf.Container, error)
 	listContainersMutex       sync.RWMutex
 	listContainersArgsForCall []struct {
@@ -117,6 +279,18 @@ type FakeClient struct {
 		result1 []*lxf.Container
 		result2 error
 	}
+	ListForeignSandboxIDsStub        func() ([]string, error)
+	listForeignSandboxIDsMutex       sync.RWMutex
+	listForeignSandboxIDsArgsForCall []struct {
+	}
+	listForeignSandboxIDsReturns struct {
+		result1 []string
+		result2 error
+	}
+	listForeignSandboxIDsReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
+	}
 	ListImagesStub        func(string) ([]lxf.Image, error)
 	listImagesMutex       sync.RWMutex
 	listImagesArgsForCall []struct {
@@ -142,6 +316,34 @@ type FakeClient struct {
 		result1 []*lxf.Sandbox
 		result2 error
 	}
+	ListSnapshotsStub        func(string) ([]lxf.Snapshot, error)
+	listSnapshotsMutex       sync.RWMutex
+	listSnapshotsArgsForCall []struct {
+		arg1 string
+	}
+	listSnapshotsReturns struct {
+		result1 []lxf.Snapshot
+		result2 error
+	}
+	listSnapshotsReturnsOnCall map[int]struct {
+		result1 []lxf.Snapshot
+		result2 error
+	}
+	MarkAdoptionStub        func(context.Context, string, string) (*lxf.Container, error)
+	markAdoptionMutex       sync.RWMutex
+	markAdoptionArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+	}
+	markAdoptionReturns struct {
+		result1 *lxf.Container
+		result2 error
+	}
+	markAdoptionReturnsOnCall map[int]struct {
+		result1 *lxf.Container
+		result2 error
+	}
 	NewContainerStub        func(string, ...string) *lxf.Container
 	newContainerMutex       sync.RWMutex
 	newContainerArgsForCall []struct {
@@ -164,10 +366,11 @@ type FakeClient struct {
 	newSandboxReturnsOnCall map[int]struct {
 		result1 *lxf.Sandbox
 	}
-	PullImageStub        func(string) (string, error)
+	PullImageStub        func(context.Context, string) (string, error)
 	pullImageMutex       sync.RWMutex
 	pullImageArgsForCall []struct {
-		arg1 string
+		arg1 context.Context
+		arg2 string
 	}
 	pullImageReturns struct {
 		result1 string
@@ -177,10 +380,11 @@ type FakeClient struct {
 		result1 string
 		result2 error
 	}
-	RemoveImageStub        func(string) error
+	RemoveImageStub        func(context.Context, string) error
 	removeImageMutex       sync.RWMutex
 	removeImageArgsForCall []struct {
-		arg1 string
+		arg1 context.Context
+		arg2 string
 	}
 	removeImageReturns struct {
 		result1 error
@@ -188,87 +392,716 @@ type FakeClient struct {
 	removeImageReturnsOnCall map[int]struct {
 		result1 error
 	}
+	RestoreSnapshotStub        func(context.Context, string, string, bool) error
+	restoreSnapshotMutex       sync.RWMutex
+	restoreSnapshotArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 bool
+	}
+	restoreSnapshotReturns struct {
+		result1 error
+	}
+	restoreSnapshotReturnsOnCall map[int]struct {
+		result1 error
+	}
 	SetEventHandlerStub        func(lxf.EventHandler)
 	setEventHandlerMutex       sync.RWMutex
 	setEventHandlerArgsForCall []struct {
 		arg1 lxf.EventHandler
 	}
+	SetImageCacheStub        func(string)
+	setImageCacheMutex       sync.RWMutex
+	setImageCacheArgsForCall []struct {
+		arg1 string
+	}
+	SetImagePolicyStub        func(*lxf.ImagePolicy)
+	setImagePolicyMutex       sync.RWMutex
+	setImagePolicyArgsForCall []struct {
+		arg1 *lxf.ImagePolicy
+	}
+	SetNamePrefixStub        func(string)
+	setNamePrefixMutex       sync.RWMutex
+	setNamePrefixArgsForCall []struct {
+		arg1 string
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeClient) Exec(arg1 string, arg2 []string, arg3 io.ReadCloser, arg4 io.WriteCloser, arg5 io.WriteCloser, arg6 bool, arg7 bool, arg8 int64, arg9 <-chan remotecommand.TerminalSize) (int32, error) {
-	var arg2Copy []string
-	if arg2 != nil {
-		arg2Copy = make([]string, len(arg2))
-		copy(arg2Copy, arg2)
+func (fake *FakeClient) AdoptContainer(arg1 context.Context, arg2 string, arg3 string, arg4 string) (*lxf.Container, error) {
+	fake.adoptContainerMutex.Lock()
+	ret, specificReturn := fake.adoptContainerReturnsOnCall[len(fake.adoptContainerArgsForCall)]
+	fake.adoptContainerArgsForCall = append(fake.adoptContainerArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 string
+	}{arg1, arg2, arg3, arg4})
+	fake.recordInvocation("AdoptContainer", []interface{}{arg1, arg2, arg3, arg4})
+	fake.adoptContainerMutex.Unlock()
+	if fake.AdoptContainerStub != nil {
+		return fake.AdoptContainerStub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.adoptContainerReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) AdoptContainerCallCount() int {
+	fake.adoptContainerMutex.RLock()
+	defer fake.adoptContainerMutex.RUnlock()
+	return len(fake.adoptContainerArgsForCall)
+}
+
+func (fake *FakeClient) AdoptContainerCalls(stub func(context.Context, string, string, string) (*lxf.Container, error)) {
+	fake.adoptContainerMutex.Lock()
+	defer fake.adoptContainerMutex.Unlock()
+	fake.AdoptContainerStub = stub
+}
+
+func (fake *FakeClient) AdoptContainerArgsForCall(i int) (context.Context, string, string, string) {
+	fake.adoptContainerMutex.RLock()
+	defer fake.adoptContainerMutex.RUnlock()
+	argsForCall := fake.adoptContainerArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeClient) AdoptContainerReturns(result1 *lxf.Container, result2 error) {
+	fake.adoptContainerMutex.Lock()
+	defer fake.adoptContainerMutex.Unlock()
+	fake.AdoptContainerStub = nil
+	fake.adoptContainerReturns = struct {
+		result1 *lxf.Container
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) AdoptContainerReturnsOnCall(i int, result1 *lxf.Container, result2 error) {
+	fake.adoptContainerMutex.Lock()
+	defer fake.adoptContainerMutex.Unlock()
+	fake.AdoptContainerStub = nil
+	if fake.adoptContainerReturnsOnCall == nil {
+		fake.adoptContainerReturnsOnCall = make(map[int]struct {
+			result1 *lxf.Container
+			result2 error
+		})
+	}
+	fake.adoptContainerReturnsOnCall[i] = struct {
+		result1 *lxf.Container
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) Batch(arg1 int, arg2 func(i int) error) error {
+	fake.batchMutex.Lock()
+	ret, specificReturn := fake.batchReturnsOnCall[len(fake.batchArgsForCall)]
+	fake.batchArgsForCall = append(fake.batchArgsForCall, struct {
+		arg1 int
+		arg2 func(i int) error
+	}{arg1, arg2})
+	fake.recordInvocation("Batch", []interface{}{arg1, arg2})
+	fake.batchMutex.Unlock()
+	if fake.BatchStub != nil {
+		return fake.BatchStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.batchReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeClient) BatchCallCount() int {
+	fake.batchMutex.RLock()
+	defer fake.batchMutex.RUnlock()
+	return len(fake.batchArgsForCall)
+}
+
+func (fake *FakeClient) BatchCalls(stub func(int, func(i int) error) error) {
+	fake.batchMutex.Lock()
+	defer fake.batchMutex.Unlock()
+	fake.BatchStub = stub
+}
+
+func (fake *FakeClient) BatchArgsForCall(i int) (int, func(i int) error) {
+	fake.batchMutex.RLock()
+	defer fake.batchMutex.RUnlock()
+	argsForCall := fake.batchArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeClient) BatchReturns(result1 error) {
+	fake.batchMutex.Lock()
+	defer fake.batchMutex.Unlock()
+	fake.BatchStub = nil
+	fake.batchReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) BatchReturnsOnCall(i int, result1 error) {
+	fake.batchMutex.Lock()
+	defer fake.batchMutex.Unlock()
+	fake.BatchStub = nil
+	if fake.batchReturnsOnCall == nil {
+		fake.batchReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.batchReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) CheckpointContainer(arg1 context.Context, arg2 string, arg3 io.WriteSeeker) error {
+	fake.checkpointContainerMutex.Lock()
+	ret, specificReturn := fake.checkpointContainerReturnsOnCall[len(fake.checkpointContainerArgsForCall)]
+	fake.checkpointContainerArgsForCall = append(fake.checkpointContainerArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 io.WriteSeeker
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("CheckpointContainer", []interface{}{arg1, arg2, arg3})
+	fake.checkpointContainerMutex.Unlock()
+	if fake.CheckpointContainerStub != nil {
+		return fake.CheckpointContainerStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.checkpointContainerReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeClient) CheckpointContainerCallCount() int {
+	fake.checkpointContainerMutex.RLock()
+	defer fake.checkpointContainerMutex.RUnlock()
+	return len(fake.checkpointContainerArgsForCall)
+}
+
+func (fake *FakeClient) CheckpointContainerCalls(stub func(context.Context, string, io.WriteSeeker) error) {
+	fake.checkpointContainerMutex.Lock()
+	defer fake.checkpointContainerMutex.Unlock()
+	fake.CheckpointContainerStub = stub
+}
+
+func (fake *FakeClient) CheckpointContainerArgsForCall(i int) (context.Context, string, io.WriteSeeker) {
+	fake.checkpointContainerMutex.RLock()
+	defer fake.checkpointContainerMutex.RUnlock()
+	argsForCall := fake.checkpointContainerArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeClient) CheckpointContainerReturns(result1 error) {
+	fake.checkpointContainerMutex.Lock()
+	defer fake.checkpointContainerMutex.Unlock()
+	fake.CheckpointContainerStub = nil
+	fake.checkpointContainerReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) CheckpointContainerReturnsOnCall(i int, result1 error) {
+	fake.checkpointContainerMutex.Lock()
+	defer fake.checkpointContainerMutex.Unlock()
+	fake.CheckpointContainerStub = nil
+	if fake.checkpointContainerReturnsOnCall == nil {
+		fake.checkpointContainerReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.checkpointContainerReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) Close() {
+	fake.closeMutex.Lock()
+	fake.closeArgsForCall = append(fake.closeArgsForCall, struct {
+	}{})
+	fake.recordInvocation("Close", []interface{}{})
+	fake.closeMutex.Unlock()
+	if fake.CloseStub != nil {
+		fake.CloseStub()
+	}
+}
+
+func (fake *FakeClient) CloseCallCount() int {
+	fake.closeMutex.RLock()
+	defer fake.closeMutex.RUnlock()
+	return len(fake.closeArgsForCall)
+}
+
+func (fake *FakeClient) CloseCalls(stub func()) {
+	fake.closeMutex.Lock()
+	defer fake.closeMutex.Unlock()
+	fake.CloseStub = stub
+}
+
+func (fake *FakeClient) CommitContainer(arg1 context.Context, arg2 string, arg3 string, arg4 lxf.CommitOptions) (string, error) {
+	fake.commitContainerMutex.Lock()
+	ret, specificReturn := fake.commitContainerReturnsOnCall[len(fake.commitContainerArgsForCall)]
+	fake.commitContainerArgsForCall = append(fake.commitContainerArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 lxf.CommitOptions
+	}{arg1, arg2, arg3, arg4})
+	fake.recordInvocation("CommitContainer", []interface{}{arg1, arg2, arg3, arg4})
+	fake.commitContainerMutex.Unlock()
+	if fake.CommitContainerStub != nil {
+		return fake.CommitContainerStub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.commitContainerReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) CommitContainerCallCount() int {
+	fake.commitContainerMutex.RLock()
+	defer fake.commitContainerMutex.RUnlock()
+	return len(fake.commitContainerArgsForCall)
+}
+
+func (fake *FakeClient) CommitContainerCalls(stub func(context.Context, string, string, lxf.CommitOptions) (string, error)) {
+	fake.commitContainerMutex.Lock()
+	defer fake.commitContainerMutex.Unlock()
+	fake.CommitContainerStub = stub
+}
+
+func (fake *FakeClient) CommitContainerArgsForCall(i int) (context.Context, string, string, lxf.CommitOptions) {
+	fake.commitContainerMutex.RLock()
+	defer fake.commitContainerMutex.RUnlock()
+	argsForCall := fake.commitContainerArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeClient) CommitContainerReturns(result1 string, result2 error) {
+	fake.commitContainerMutex.Lock()
+	defer fake.commitContainerMutex.Unlock()
+	fake.CommitContainerStub = nil
+	fake.commitContainerReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) CommitContainerReturnsOnCall(i int, result1 string, result2 error) {
+	fake.commitContainerMutex.Lock()
+	defer fake.commitContainerMutex.Unlock()
+	fake.CommitContainerStub = nil
+	if fake.commitContainerReturnsOnCall == nil {
+		fake.commitContainerReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.commitContainerReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) CreateSnapshot(arg1 context.Context, arg2 string, arg3 string, arg4 bool) error {
+	fake.createSnapshotMutex.Lock()
+	ret, specificReturn := fake.createSnapshotReturnsOnCall[len(fake.createSnapshotArgsForCall)]
+	fake.createSnapshotArgsForCall = append(fake.createSnapshotArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 bool
+	}{arg1, arg2, arg3, arg4})
+	fake.recordInvocation("CreateSnapshot", []interface{}{arg1, arg2, arg3, arg4})
+	fake.createSnapshotMutex.Unlock()
+	if fake.CreateSnapshotStub != nil {
+		return fake.CreateSnapshotStub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.createSnapshotReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeClient) CreateSnapshotCallCount() int {
+	fake.createSnapshotMutex.RLock()
+	defer fake.createSnapshotMutex.RUnlock()
+	return len(fake.createSnapshotArgsForCall)
+}
+
+func (fake *FakeClient) CreateSnapshotCalls(stub func(context.Context, string, string, bool) error) {
+	fake.createSnapshotMutex.Lock()
+	defer fake.createSnapshotMutex.Unlock()
+	fake.CreateSnapshotStub = stub
+}
+
+func (fake *FakeClient) CreateSnapshotArgsForCall(i int) (context.Context, string, string, bool) {
+	fake.createSnapshotMutex.RLock()
+	defer fake.createSnapshotMutex.RUnlock()
+	argsForCall := fake.createSnapshotArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeClient) CreateSnapshotReturns(result1 error) {
+	fake.createSnapshotMutex.Lock()
+	defer fake.createSnapshotMutex.Unlock()
+	fake.CreateSnapshotStub = nil
+	fake.createSnapshotReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) CreateSnapshotReturnsOnCall(i int, result1 error) {
+	fake.createSnapshotMutex.Lock()
+	defer fake.createSnapshotMutex.Unlock()
+	fake.CreateSnapshotStub = nil
+	if fake.createSnapshotReturnsOnCall == nil {
+		fake.createSnapshotReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.createSnapshotReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) DeleteSnapshot(arg1 context.Context, arg2 string, arg3 string) error {
+	fake.deleteSnapshotMutex.Lock()
+	ret, specificReturn := fake.deleteSnapshotReturnsOnCall[len(fake.deleteSnapshotArgsForCall)]
+	fake.deleteSnapshotArgsForCall = append(fake.deleteSnapshotArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("DeleteSnapshot", []interface{}{arg1, arg2, arg3})
+	fake.deleteSnapshotMutex.Unlock()
+	if fake.DeleteSnapshotStub != nil {
+		return fake.DeleteSnapshotStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.deleteSnapshotReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeClient) DeleteSnapshotCallCount() int {
+	fake.deleteSnapshotMutex.RLock()
+	defer fake.deleteSnapshotMutex.RUnlock()
+	return len(fake.deleteSnapshotArgsForCall)
+}
+
+func (fake *FakeClient) DeleteSnapshotCalls(stub func(context.Context, string, string) error) {
+	fake.deleteSnapshotMutex.Lock()
+	defer fake.deleteSnapshotMutex.Unlock()
+	fake.DeleteSnapshotStub = stub
+}
+
+func (fake *FakeClient) DeleteSnapshotArgsForCall(i int) (context.Context, string, string) {
+	fake.deleteSnapshotMutex.RLock()
+	defer fake.deleteSnapshotMutex.RUnlock()
+	argsForCall := fake.deleteSnapshotArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeClient) DeleteSnapshotReturns(result1 error) {
+	fake.deleteSnapshotMutex.Lock()
+	defer fake.deleteSnapshotMutex.Unlock()
+	fake.DeleteSnapshotStub = nil
+	fake.deleteSnapshotReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) DeleteSnapshotReturnsOnCall(i int, result1 error) {
+	fake.deleteSnapshotMutex.Lock()
+	defer fake.deleteSnapshotMutex.Unlock()
+	fake.DeleteSnapshotStub = nil
+	if fake.deleteSnapshotReturnsOnCall == nil {
+		fake.deleteSnapshotReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.deleteSnapshotReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) Exec(arg1 context.Context, arg2 string, arg3 []string, arg4 io.ReadCloser, arg5 io.WriteCloser, arg6 io.WriteCloser, arg7 bool, arg8 bool, arg9 <-chan remotecommand.TerminalSize) (int32, error) {
+	var arg3Copy []string
+	if arg3 != nil {
+		arg3Copy = make([]string, len(arg3))
+		copy(arg3Copy, arg3)
+	}
+	fake.execMutex.Lock()
+	ret, specificReturn := fake.execReturnsOnCall[len(fake.execArgsForCall)]
+	fake.execArgsForCall = append(fake.execArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 []string
+		arg4 io.ReadCloser
+		arg5 io.WriteCloser
+		arg6 io.WriteCloser
+		arg7 bool
+		arg8 bool
+		arg9 <-chan remotecommand.TerminalSize
+	}{arg1, arg2, arg3Copy, arg4, arg5, arg6, arg7, arg8, arg9})
+	fake.recordInvocation("Exec", []interface{}{arg1, arg2, arg3Copy, arg4, arg5, arg6, arg7, arg8, arg9})
+	fake.execMutex.Unlock()
+	if fake.ExecStub != nil {
+		return fake.ExecStub(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.execReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) ExecCallCount() int {
+	fake.execMutex.RLock()
+	defer fake.execMutex.RUnlock()
+	return len(fake.execArgsForCall)
+}
+
+func (fake *FakeClient) ExecCalls(stub func(context.Context, string, []string, io.ReadCloser, io.WriteCloser, io.WriteCloser, bool, bool, <-chan remotecommand.TerminalSize) (int32, error)) {
+	fake.execMutex.Lock()
+	defer fake.execMutex.Unlock()
+	fake.ExecStub = stub
+}
+
+func (fake *FakeClient) ExecArgsForCall(i int) (context.Context, string, []string, io.ReadCloser, io.WriteCloser, io.WriteCloser, bool, bool, <-chan remotecommand.TerminalSize) {
+	fake.execMutex.RLock()
+	defer fake.execMutex.RUnlock()
+	argsForCall := fake.execArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5, argsForCall.arg6, argsForCall.arg7, argsForCall.arg8, argsForCall.arg9
+}
+
+func (fake *FakeClient) ExecReturns(result1 int32, result2 error) {
+	fake.execMutex.Lock()
+	defer fake.execMutex.Unlock()
+	fake.ExecStub = nil
+	fake.execReturns = struct {
+		result1 int32
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) ExecReturnsOnCall(i int, result1 int32, result2 error) {
+	fake.execMutex.Lock()
+	defer fake.execMutex.Unlock()
+	fake.ExecStub = nil
+	if fake.execReturnsOnCall == nil {
+		fake.execReturnsOnCall = make(map[int]struct {
+			result1 int32
+			result2 error
+		})
+	}
+	fake.execReturnsOnCall[i] = struct {
+		result1 int32
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) ExecSync(arg1 context.Context, arg2 string, arg3 []string, arg4 time.Duration) (*lxo.ExecSyncResult, error) {
+	var arg3Copy []string
+	if arg3 != nil {
+		arg3Copy = make([]string, len(arg3))
+		copy(arg3Copy, arg3)
+	}
+	fake.execSyncMutex.Lock()
+	ret, specificReturn := fake.execSyncReturnsOnCall[len(fake.execSyncArgsForCall)]
+	fake.execSyncArgsForCall = append(fake.execSyncArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 []string
+		arg4 time.Duration
+	}{arg1, arg2, arg3Copy, arg4})
+	fake.recordInvocation("ExecSync", []interface{}{arg1, arg2, arg3Copy, arg4})
+	fake.execSyncMutex.Unlock()
+	if fake.ExecSyncStub != nil {
+		return fake.ExecSyncStub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.execSyncReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) ExecSyncCallCount() int {
+	fake.execSyncMutex.RLock()
+	defer fake.execSyncMutex.RUnlock()
+	return len(fake.execSyncArgsForCall)
+}
+
+func (fake *FakeClient) ExecSyncCalls(stub func(context.Context, string, []string, time.Duration) (*lxo.ExecSyncResult, error)) {
+	fake.execSyncMutex.Lock()
+	defer fake.execSyncMutex.Unlock()
+	fake.ExecSyncStub = stub
+}
+
+func (fake *FakeClient) ExecSyncArgsForCall(i int) (context.Context, string, []string, time.Duration) {
+	fake.execSyncMutex.RLock()
+	defer fake.execSyncMutex.RUnlock()
+	argsForCall := fake.execSyncArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeClient) ExecSyncReturns(result1 *lxo.ExecSyncResult, result2 error) {
+	fake.execSyncMutex.Lock()
+	defer fake.execSyncMutex.Unlock()
+	fake.ExecSyncStub = nil
+	fake.execSyncReturns = struct {
+		result1 *lxo.ExecSyncResult
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) ExecSyncReturnsOnCall(i int, result1 *lxo.ExecSyncResult, result2 error) {
+	fake.execSyncMutex.Lock()
+	defer fake.execSyncMutex.Unlock()
+	fake.ExecSyncStub = nil
+	if fake.execSyncReturnsOnCall == nil {
+		fake.execSyncReturnsOnCall = make(map[int]struct {
+			result1 *lxo.ExecSyncResult
+			result2 error
+		})
+	}
+	fake.execSyncReturnsOnCall[i] = struct {
+		result1 *lxo.ExecSyncResult
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) FilterContainers(arg1 lxf.ContainerFilter) ([]*lxf.Container, error) {
+	fake.filterContainersMutex.Lock()
+	ret, specificReturn := fake.filterContainersReturnsOnCall[len(fake.filterContainersArgsForCall)]
+	fake.filterContainersArgsForCall = append(fake.filterContainersArgsForCall, struct {
+		arg1 lxf.ContainerFilter
+	}{arg1})
+	fake.recordInvocation("FilterContainers", []interface{}{arg1})
+	fake.filterContainersMutex.Unlock()
+	if fake.FilterContainersStub != nil {
+		return fake.FilterContainersStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.filterContainersReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) FilterContainersCallCount() int {
+	fake.filterContainersMutex.RLock()
+	defer fake.filterContainersMutex.RUnlock()
+	return len(fake.filterContainersArgsForCall)
+}
+
+func (fake *FakeClient) FilterContainersCalls(stub func(lxf.ContainerFilter) ([]*lxf.Container, error)) {
+	fake.filterContainersMutex.Lock()
+	defer fake.filterContainersMutex.Unlock()
+	fake.FilterContainersStub = stub
+}
+
+func (fake *FakeClient) FilterContainersArgsForCall(i int) lxf.ContainerFilter {
+	fake.filterContainersMutex.RLock()
+	defer fake.filterContainersMutex.RUnlock()
+	argsForCall := fake.filterContainersArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeClient) FilterContainersReturns(result1 []*lxf.Container, result2 error) {
+	fake.filterContainersMutex.Lock()
+	defer fake.filterContainersMutex.Unlock()
+	fake.FilterContainersStub = nil
+	fake.filterContainersReturns = struct {
+		result1 []*lxf.Container
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) FilterContainersReturnsOnCall(i int, result1 []*lxf.Container, result2 error) {
+	fake.filterContainersMutex.Lock()
+	defer fake.filterContainersMutex.Unlock()
+	fake.FilterContainersStub = nil
+	if fake.filterContainersReturnsOnCall == nil {
+		fake.filterContainersReturnsOnCall = make(map[int]struct {
+			result1 []*lxf.Container
+			result2 error
+		})
 	}
-	fake.execMutex.Lock()
-	ret, specificReturn := fake.execReturnsOnCall[len(fake.execArgsForCall)]
-	fake.execArgsForCall = append(fake.execArgsForCall, struct {
-		arg1 string
-		arg2 []string
-		arg3 io.ReadCloser
-		arg4 io.WriteCloser
-		arg5 io.WriteCloser
-		arg6 bool
-		arg7 bool
-		arg8 int64
-		arg9 <-chan remotecommand.TerminalSize
-	}{arg1, arg2Copy, arg3, arg4, arg5, arg6, arg7, arg8, arg9})
-	fake.recordInvocation("Exec", []interface{}{arg1, arg2Copy, arg3, arg4, arg5, arg6, arg7, arg8, arg9})
-	fake.execMutex.Unlock()
-	if fake.ExecStub != nil {
-		return fake.ExecStub(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9)
+	fake.filterContainersReturnsOnCall[i] = struct {
+		result1 []*lxf.Container
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) FilterSandboxes(arg1 lxf.SandboxFilter) ([]*lxf.Sandbox, error) {
+	fake.filterSandboxesMutex.Lock()
+	ret, specificReturn := fake.filterSandboxesReturnsOnCall[len(fake.filterSandboxesArgsForCall)]
+	fake.filterSandboxesArgsForCall = append(fake.filterSandboxesArgsForCall, struct {
+		arg1 lxf.SandboxFilter
+	}{arg1})
+	fake.recordInvocation("FilterSandboxes", []interface{}{arg1})
+	fake.filterSandboxesMutex.Unlock()
+	if fake.FilterSandboxesStub != nil {
+		return fake.FilterSandboxesStub(arg1)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	fakeReturns := fake.execReturns
+	fakeReturns := fake.filterSandboxesReturns
 	return fakeReturns.result1, fakeReturns.result2
 }
 
-func (fake *FakeClient) ExecCallCount() int {
-	fake.execMutex.RLock()
-	defer fake.execMutex.RUnlock()
-	return len(fake.execArgsForCall)
+func (fake *FakeClient) FilterSandboxesCallCount() int {
+	fake.filterSandboxesMutex.RLock()
+	defer fake.filterSandboxesMutex.RUnlock()
+	return len(fake.filterSandboxesArgsForCall)
 }
 
-func (fake *FakeClient) ExecCalls(stub func(string, []string, io.ReadCloser, io.WriteCloser, io.WriteCloser, bool, bool, int64, <-chan remotecommand.TerminalSize) (int32, error)) {
-	fake.execMutex.Lock()
-	defer fake.execMutex.Unlock()
-	fake.ExecStub = stub
+func (fake *FakeClient) FilterSandboxesCalls(stub func(lxf.SandboxFilter) ([]*lxf.Sandbox, error)) {
+	fake.filterSandboxesMutex.Lock()
+	defer fake.filterSandboxesMutex.Unlock()
+	fake.FilterSandboxesStub = stub
 }
 
-func (fake *FakeClient) ExecArgsForCall(i int) (string, []string, io.ReadCloser, io.WriteCloser, io.WriteCloser, bool, bool, int64, <-chan remotecommand.TerminalSize) {
-	fake.execMutex.RLock()
-	defer fake.execMutex.RUnlock()
-	argsForCall := fake.execArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5, argsForCall.arg6, argsForCall.arg7, argsForCall.arg8, argsForCall.arg9
+func (fake *FakeClient) FilterSandboxesArgsForCall(i int) lxf.SandboxFilter {
+	fake.filterSandboxesMutex.RLock()
+	defer fake.filterSandboxesMutex.RUnlock()
+	argsForCall := fake.filterSandboxesArgsForCall[i]
+	return argsForCall.arg1
 }
 
-func (fake *FakeClient) ExecReturns(result1 int32, result2 error) {
-	fake.execMutex.Lock()
-	defer fake.execMutex.Unlock()
-	fake.ExecStub = nil
-	fake.execReturns = struct {
-		result1 int32
+func (fake *FakeClient) FilterSandboxesReturns(result1 []*lxf.Sandbox, result2 error) {
+	fake.filterSandboxesMutex.Lock()
+	defer fake.filterSandboxesMutex.Unlock()
+	fake.FilterSandboxesStub = nil
+	fake.filterSandboxesReturns = struct {
+		result1 []*lxf.Sandbox
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeClient) ExecReturnsOnCall(i int, result1 int32, result2 error) {
-	fake.execMutex.Lock()
-	defer fake.execMutex.Unlock()
-	fake.ExecStub = nil
-	if fake.execReturnsOnCall == nil {
-		fake.execReturnsOnCall = make(map[int]struct {
-			result1 int32
+func (fake *FakeClient) FilterSandboxesReturnsOnCall(i int, result1 []*lxf.Sandbox, result2 error) {
+	fake.filterSandboxesMutex.Lock()
+	defer fake.filterSandboxesMutex.Unlock()
+	fake.FilterSandboxesStub = nil
+	if fake.filterSandboxesReturnsOnCall == nil {
+		fake.filterSandboxesReturnsOnCall = make(map[int]struct {
+			result1 []*lxf.Sandbox
 			result2 error
 		})
 	}
-	fake.execReturnsOnCall[i] = struct {
-		result1 int32
+	fake.filterSandboxesReturnsOnCall[i] = struct {
+		result1 []*lxf.Sandbox
 		result2 error
 	}{result1, result2}
 }
@@ -624,6 +1457,135 @@ func (fake *FakeClient) GetServerReturnsOnCall(i int, result1 lxd.ContainerServe
 	}{result1}
 }
 
+func (fake *FakeClient) ImageRemote(arg1 string) (string, error) {
+	fake.imageRemoteMutex.Lock()
+	ret, specificReturn := fake.imageRemoteReturnsOnCall[len(fake.imageRemoteArgsForCall)]
+	fake.imageRemoteArgsForCall = append(fake.imageRemoteArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("ImageRemote", []interface{}{arg1})
+	fake.imageRemoteMutex.Unlock()
+	if fake.ImageRemoteStub != nil {
+		return fake.ImageRemoteStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.imageRemoteReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) ImageRemoteCallCount() int {
+	fake.imageRemoteMutex.RLock()
+	defer fake.imageRemoteMutex.RUnlock()
+	return len(fake.imageRemoteArgsForCall)
+}
+
+func (fake *FakeClient) ImageRemoteCalls(stub func(string) (string, error)) {
+	fake.imageRemoteMutex.Lock()
+	defer fake.imageRemoteMutex.Unlock()
+	fake.ImageRemoteStub = stub
+}
+
+func (fake *FakeClient) ImageRemoteArgsForCall(i int) string {
+	fake.imageRemoteMutex.RLock()
+	defer fake.imageRemoteMutex.RUnlock()
+	argsForCall := fake.imageRemoteArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeClient) ImageRemoteReturns(result1 string, result2 error) {
+	fake.imageRemoteMutex.Lock()
+	defer fake.imageRemoteMutex.Unlock()
+	fake.ImageRemoteStub = nil
+	fake.imageRemoteReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) ImageRemoteReturnsOnCall(i int, result1 string, result2 error) {
+	fake.imageRemoteMutex.Lock()
+	defer fake.imageRemoteMutex.Unlock()
+	fake.ImageRemoteStub = nil
+	if fake.imageRemoteReturnsOnCall == nil {
+		fake.imageRemoteReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.imageRemoteReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) ImportImage(arg1 context.Context, arg2 string, arg3 io.Reader, arg4 io.Reader) (string, error) {
+	fake.importImageMutex.Lock()
+	ret, specificReturn := fake.importImageReturnsOnCall[len(fake.importImageArgsForCall)]
+	fake.importImageArgsForCall = append(fake.importImageArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 io.Reader
+		arg4 io.Reader
+	}{arg1, arg2, arg3, arg4})
+	fake.recordInvocation("ImportImage", []interface{}{arg1, arg2, arg3, arg4})
+	fake.importImageMutex.Unlock()
+	if fake.ImportImageStub != nil {
+		return fake.ImportImageStub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.importImageReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) ImportImageCallCount() int {
+	fake.importImageMutex.RLock()
+	defer fake.importImageMutex.RUnlock()
+	return len(fake.importImageArgsForCall)
+}
+
+func (fake *FakeClient) ImportImageCalls(stub func(context.Context, string, io.Reader, io.Reader) (string, error)) {
+	fake.importImageMutex.Lock()
+	defer fake.importImageMutex.Unlock()
+	fake.ImportImageStub = stub
+}
+
+func (fake *FakeClient) ImportImageArgsForCall(i int) (context.Context, string, io.Reader, io.Reader) {
+	fake.importImageMutex.RLock()
+	defer fake.importImageMutex.RUnlock()
+	argsForCall := fake.importImageArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeClient) ImportImageReturns(result1 string, result2 error) {
+	fake.importImageMutex.Lock()
+	defer fake.importImageMutex.Unlock()
+	fake.ImportImageStub = nil
+	fake.importImageReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) ImportImageReturnsOnCall(i int, result1 string, result2 error) {
+	fake.importImageMutex.Lock()
+	defer fake.importImageMutex.Unlock()
+	fake.ImportImageStub = nil
+	if fake.importImageReturnsOnCall == nil {
+		fake.importImageReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.importImageReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeClient) ListContainers() ([]*lxf.Container, error) {
 	fake.listContainersMutex.Lock()
 	ret, specificReturn := fake.listContainersReturnsOnCall[len(fake.listContainersArgsForCall)]
@@ -679,6 +1641,61 @@ func (fake *FakeClient) ListContainersReturnsOnCall(i int, result1 []*lxf.Contai
 	}{result1, result2}
 }
 
+func (fake *FakeClient) ListForeignSandboxIDs() ([]string, error) {
+	fake.listForeignSandboxIDsMutex.Lock()
+	ret, specificReturn := fake.listForeignSandboxIDsReturnsOnCall[len(fake.listForeignSandboxIDsArgsForCall)]
+	fake.listForeignSandboxIDsArgsForCall = append(fake.listForeignSandboxIDsArgsForCall, struct {
+	}{})
+	fake.recordInvocation("ListForeignSandboxIDs", []interface{}{})
+	fake.listForeignSandboxIDsMutex.Unlock()
+	if fake.ListForeignSandboxIDsStub != nil {
+		return fake.ListForeignSandboxIDsStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.listForeignSandboxIDsReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) ListForeignSandboxIDsCallCount() int {
+	fake.listForeignSandboxIDsMutex.RLock()
+	defer fake.listForeignSandboxIDsMutex.RUnlock()
+	return len(fake.listForeignSandboxIDsArgsForCall)
+}
+
+func (fake *FakeClient) ListForeignSandboxIDsCalls(stub func() ([]string, error)) {
+	fake.listForeignSandboxIDsMutex.Lock()
+	defer fake.listForeignSandboxIDsMutex.Unlock()
+	fake.ListForeignSandboxIDsStub = stub
+}
+
+func (fake *FakeClient) ListForeignSandboxIDsReturns(result1 []string, result2 error) {
+	fake.listForeignSandboxIDsMutex.Lock()
+	defer fake.listForeignSandboxIDsMutex.Unlock()
+	fake.ListForeignSandboxIDsStub = nil
+	fake.listForeignSandboxIDsReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) ListForeignSandboxIDsReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.listForeignSandboxIDsMutex.Lock()
+	defer fake.listForeignSandboxIDsMutex.Unlock()
+	fake.ListForeignSandboxIDsStub = nil
+	if fake.listForeignSandboxIDsReturnsOnCall == nil {
+		fake.listForeignSandboxIDsReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.listForeignSandboxIDsReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeClient) ListImages(arg1 string) ([]lxf.Image, error) {
 	fake.listImagesMutex.Lock()
 	ret, specificReturn := fake.listImagesReturnsOnCall[len(fake.listImagesArgsForCall)]
@@ -797,6 +1814,134 @@ func (fake *FakeClient) ListSandboxesReturnsOnCall(i int, result1 []*lxf.Sandbox
 	}{result1, result2}
 }
 
+func (fake *FakeClient) ListSnapshots(arg1 string) ([]lxf.Snapshot, error) {
+	fake.listSnapshotsMutex.Lock()
+	ret, specificReturn := fake.listSnapshotsReturnsOnCall[len(fake.listSnapshotsArgsForCall)]
+	fake.listSnapshotsArgsForCall = append(fake.listSnapshotsArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("ListSnapshots", []interface{}{arg1})
+	fake.listSnapshotsMutex.Unlock()
+	if fake.ListSnapshotsStub != nil {
+		return fake.ListSnapshotsStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.listSnapshotsReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) ListSnapshotsCallCount() int {
+	fake.listSnapshotsMutex.RLock()
+	defer fake.listSnapshotsMutex.RUnlock()
+	return len(fake.listSnapshotsArgsForCall)
+}
+
+func (fake *FakeClient) ListSnapshotsCalls(stub func(string) ([]lxf.Snapshot, error)) {
+	fake.listSnapshotsMutex.Lock()
+	defer fake.listSnapshotsMutex.Unlock()
+	fake.ListSnapshotsStub = stub
+}
+
+func (fake *FakeClient) ListSnapshotsArgsForCall(i int) string {
+	fake.listSnapshotsMutex.RLock()
+	defer fake.listSnapshotsMutex.RUnlock()
+	argsForCall := fake.listSnapshotsArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeClient) ListSnapshotsReturns(result1 []lxf.Snapshot, result2 error) {
+	fake.listSnapshotsMutex.Lock()
+	defer fake.listSnapshotsMutex.Unlock()
+	fake.ListSnapshotsStub = nil
+	fake.listSnapshotsReturns = struct {
+		result1 []lxf.Snapshot
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) ListSnapshotsReturnsOnCall(i int, result1 []lxf.Snapshot, result2 error) {
+	fake.listSnapshotsMutex.Lock()
+	defer fake.listSnapshotsMutex.Unlock()
+	fake.ListSnapshotsStub = nil
+	if fake.listSnapshotsReturnsOnCall == nil {
+		fake.listSnapshotsReturnsOnCall = make(map[int]struct {
+			result1 []lxf.Snapshot
+			result2 error
+		})
+	}
+	fake.listSnapshotsReturnsOnCall[i] = struct {
+		result1 []lxf.Snapshot
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) MarkAdoption(arg1 context.Context, arg2 string, arg3 string) (*lxf.Container, error) {
+	fake.markAdoptionMutex.Lock()
+	ret, specificReturn := fake.markAdoptionReturnsOnCall[len(fake.markAdoptionArgsForCall)]
+	fake.markAdoptionArgsForCall = append(fake.markAdoptionArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("MarkAdoption", []interface{}{arg1, arg2, arg3})
+	fake.markAdoptionMutex.Unlock()
+	if fake.MarkAdoptionStub != nil {
+		return fake.MarkAdoptionStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.markAdoptionReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) MarkAdoptionCallCount() int {
+	fake.markAdoptionMutex.RLock()
+	defer fake.markAdoptionMutex.RUnlock()
+	return len(fake.markAdoptionArgsForCall)
+}
+
+func (fake *FakeClient) MarkAdoptionCalls(stub func(context.Context, string, string) (*lxf.Container, error)) {
+	fake.markAdoptionMutex.Lock()
+	defer fake.markAdoptionMutex.Unlock()
+	fake.MarkAdoptionStub = stub
+}
+
+func (fake *FakeClient) MarkAdoptionArgsForCall(i int) (context.Context, string, string) {
+	fake.markAdoptionMutex.RLock()
+	defer fake.markAdoptionMutex.RUnlock()
+	argsForCall := fake.markAdoptionArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeClient) MarkAdoptionReturns(result1 *lxf.Container, result2 error) {
+	fake.markAdoptionMutex.Lock()
+	defer fake.markAdoptionMutex.Unlock()
+	fake.MarkAdoptionStub = nil
+	fake.markAdoptionReturns = struct {
+		result1 *lxf.Container
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) MarkAdoptionReturnsOnCall(i int, result1 *lxf.Container, result2 error) {
+	fake.markAdoptionMutex.Lock()
+	defer fake.markAdoptionMutex.Unlock()
+	fake.MarkAdoptionStub = nil
+	if fake.markAdoptionReturnsOnCall == nil {
+		fake.markAdoptionReturnsOnCall = make(map[int]struct {
+			result1 *lxf.Container
+			result2 error
+		})
+	}
+	fake.markAdoptionReturnsOnCall[i] = struct {
+		result1 *lxf.Container
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeClient) NewContainer(arg1 string, arg2 ...string) *lxf.Container {
 	fake.newContainerMutex.Lock()
 	ret, specificReturn := fake.newContainerReturnsOnCall[len(fake.newContainerArgsForCall)]
@@ -910,16 +2055,17 @@ func (fake *FakeClient) NewSandboxReturnsOnCall(i int, result1 *lxf.Sandbox) {
 	}{result1}
 }
 
-func (fake *FakeClient) PullImage(arg1 string) (string, error) {
+func (fake *FakeClient) PullImage(arg1 context.Context, arg2 string) (string, error) {
 	fake.pullImageMutex.Lock()
 	ret, specificReturn := fake.pullImageReturnsOnCall[len(fake.pullImageArgsForCall)]
 	fake.pullImageArgsForCall = append(fake.pullImageArgsForCall, struct {
-		arg1 string
-	}{arg1})
-	fake.recordInvocation("PullImage", []interface{}{arg1})
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("PullImage", []interface{}{arg1, arg2})
 	fake.pullImageMutex.Unlock()
 	if fake.PullImageStub != nil {
-		return fake.PullImageStub(arg1)
+		return fake.PullImageStub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -934,17 +2080,17 @@ func (fake *FakeClient) PullImageCallCount() int {
 	return len(fake.pullImageArgsForCall)
 }
 
-func (fake *FakeClient) PullImageCalls(stub func(string) (string, error)) {
+func (fake *FakeClient) PullImageCalls(stub func(context.Context, string) (string, error)) {
 	fake.pullImageMutex.Lock()
 	defer fake.pullImageMutex.Unlock()
 	fake.PullImageStub = stub
 }
 
-func (fake *FakeClient) PullImageArgsForCall(i int) string {
+func (fake *FakeClient) PullImageArgsForCall(i int) (context.Context, string) {
 	fake.pullImageMutex.RLock()
 	defer fake.pullImageMutex.RUnlock()
 	argsForCall := fake.pullImageArgsForCall[i]
-	return argsForCall.arg1
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *FakeClient) PullImageReturns(result1 string, result2 error) {
@@ -973,16 +2119,17 @@ func (fake *FakeClient) PullImageReturnsOnCall(i int, result1 string, result2 er
 	}{result1, result2}
 }
 
-func (fake *FakeClient) RemoveImage(arg1 string) error {
+func (fake *FakeClient) RemoveImage(arg1 context.Context, arg2 string) error {
 	fake.removeImageMutex.Lock()
 	ret, specificReturn := fake.removeImageReturnsOnCall[len(fake.removeImageArgsForCall)]
 	fake.removeImageArgsForCall = append(fake.removeImageArgsForCall, struct {
-		arg1 string
-	}{arg1})
-	fake.recordInvocation("RemoveImage", []interface{}{arg1})
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("RemoveImage", []interface{}{arg1, arg2})
 	fake.removeImageMutex.Unlock()
 	if fake.RemoveImageStub != nil {
-		return fake.RemoveImageStub(arg1)
+		return fake.RemoveImageStub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1
@@ -997,17 +2144,17 @@ func (fake *FakeClient) RemoveImageCallCount() int {
 	return len(fake.removeImageArgsForCall)
 }
 
-func (fake *FakeClient) RemoveImageCalls(stub func(string) error) {
+func (fake *FakeClient) RemoveImageCalls(stub func(context.Context, string) error) {
 	fake.removeImageMutex.Lock()
 	defer fake.removeImageMutex.Unlock()
 	fake.RemoveImageStub = stub
 }
 
-func (fake *FakeClient) RemoveImageArgsForCall(i int) string {
+func (fake *FakeClient) RemoveImageArgsForCall(i int) (context.Context, string) {
 	fake.removeImageMutex.RLock()
 	defer fake.removeImageMutex.RUnlock()
 	argsForCall := fake.removeImageArgsForCall[i]
-	return argsForCall.arg1
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *FakeClient) RemoveImageReturns(result1 error) {
@@ -1033,6 +2180,69 @@ func (fake *FakeClient) RemoveImageReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeClient) RestoreSnapshot(arg1 context.Context, arg2 string, arg3 string, arg4 bool) error {
+	fake.restoreSnapshotMutex.Lock()
+	ret, specificReturn := fake.restoreSnapshotReturnsOnCall[len(fake.restoreSnapshotArgsForCall)]
+	fake.restoreSnapshotArgsForCall = append(fake.restoreSnapshotArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 bool
+	}{arg1, arg2, arg3, arg4})
+	fake.recordInvocation("RestoreSnapshot", []interface{}{arg1, arg2, arg3, arg4})
+	fake.restoreSnapshotMutex.Unlock()
+	if fake.RestoreSnapshotStub != nil {
+		return fake.RestoreSnapshotStub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.restoreSnapshotReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeClient) RestoreSnapshotCallCount() int {
+	fake.restoreSnapshotMutex.RLock()
+	defer fake.restoreSnapshotMutex.RUnlock()
+	return len(fake.restoreSnapshotArgsForCall)
+}
+
+func (fake *FakeClient) RestoreSnapshotCalls(stub func(context.Context, string, string, bool) error) {
+	fake.restoreSnapshotMutex.Lock()
+	defer fake.restoreSnapshotMutex.Unlock()
+	fake.RestoreSnapshotStub = stub
+}
+
+func (fake *FakeClient) RestoreSnapshotArgsForCall(i int) (context.Context, string, string, bool) {
+	fake.restoreSnapshotMutex.RLock()
+	defer fake.restoreSnapshotMutex.RUnlock()
+	argsForCall := fake.restoreSnapshotArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeClient) RestoreSnapshotReturns(result1 error) {
+	fake.restoreSnapshotMutex.Lock()
+	defer fake.restoreSnapshotMutex.Unlock()
+	fake.RestoreSnapshotStub = nil
+	fake.restoreSnapshotReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) RestoreSnapshotReturnsOnCall(i int, result1 error) {
+	fake.restoreSnapshotMutex.Lock()
+	defer fake.restoreSnapshotMutex.Unlock()
+	fake.RestoreSnapshotStub = nil
+	if fake.restoreSnapshotReturnsOnCall == nil {
+		fake.restoreSnapshotReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.restoreSnapshotReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeClient) SetEventHandler(arg1 lxf.EventHandler) {
 	fake.setEventHandlerMutex.Lock()
 	fake.setEventHandlerArgsForCall = append(fake.setEventHandlerArgsForCall, struct {
@@ -1064,11 +2274,124 @@ func (fake *FakeClient) SetEventHandlerArgsForCall(i int) lxf.EventHandler {
 	return argsForCall.arg1
 }
 
+func (fake *FakeClient) SetImageCache(arg1 string) {
+	fake.setImageCacheMutex.Lock()
+	fake.setImageCacheArgsForCall = append(fake.setImageCacheArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("SetImageCache", []interface{}{arg1})
+	fake.setImageCacheMutex.Unlock()
+	if fake.SetImageCacheStub != nil {
+		fake.SetImageCacheStub(arg1)
+	}
+}
+
+func (fake *FakeClient) SetImageCacheCallCount() int {
+	fake.setImageCacheMutex.RLock()
+	defer fake.setImageCacheMutex.RUnlock()
+	return len(fake.setImageCacheArgsForCall)
+}
+
+func (fake *FakeClient) SetImageCacheCalls(stub func(string)) {
+	fake.setImageCacheMutex.Lock()
+	defer fake.setImageCacheMutex.Unlock()
+	fake.SetImageCacheStub = stub
+}
+
+func (fake *FakeClient) SetImageCacheArgsForCall(i int) string {
+	fake.setImageCacheMutex.RLock()
+	defer fake.setImageCacheMutex.RUnlock()
+	argsForCall := fake.setImageCacheArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeClient) SetImagePolicy(arg1 *lxf.ImagePolicy) {
+	fake.setImagePolicyMutex.Lock()
+	fake.setImagePolicyArgsForCall = append(fake.setImagePolicyArgsForCall, struct {
+		arg1 *lxf.ImagePolicy
+	}{arg1})
+	fake.recordInvocation("SetImagePolicy", []interface{}{arg1})
+	fake.setImagePolicyMutex.Unlock()
+	if fake.SetImagePolicyStub != nil {
+		fake.SetImagePolicyStub(arg1)
+	}
+}
+
+func (fake *FakeClient) SetImagePolicyCallCount() int {
+	fake.setImagePolicyMutex.RLock()
+	defer fake.setImagePolicyMutex.RUnlock()
+	return len(fake.setImagePolicyArgsForCall)
+}
+
+func (fake *FakeClient) SetImagePolicyCalls(stub func(*lxf.ImagePolicy)) {
+	fake.setImagePolicyMutex.Lock()
+	defer fake.setImagePolicyMutex.Unlock()
+	fake.SetImagePolicyStub = stub
+}
+
+func (fake *FakeClient) SetImagePolicyArgsForCall(i int) *lxf.ImagePolicy {
+	fake.setImagePolicyMutex.RLock()
+	defer fake.setImagePolicyMutex.RUnlock()
+	argsForCall := fake.setImagePolicyArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeClient) SetNamePrefix(arg1 string) {
+	fake.setNamePrefixMutex.Lock()
+	fake.setNamePrefixArgsForCall = append(fake.setNamePrefixArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("SetNamePrefix", []interface{}{arg1})
+	fake.setNamePrefixMutex.Unlock()
+	if fake.SetNamePrefixStub != nil {
+		fake.SetNamePrefixStub(arg1)
+	}
+}
+
+func (fake *FakeClient) SetNamePrefixCallCount() int {
+	fake.setNamePrefixMutex.RLock()
+	defer fake.setNamePrefixMutex.RUnlock()
+	return len(fake.setNamePrefixArgsForCall)
+}
+
+func (fake *FakeClient) SetNamePrefixCalls(stub func(string)) {
+	fake.setNamePrefixMutex.Lock()
+	defer fake.setNamePrefixMutex.Unlock()
+	fake.SetNamePrefixStub = stub
+}
+
+func (fake *FakeClient) SetNamePrefixArgsForCall(i int) string {
+	fake.setNamePrefixMutex.RLock()
+	defer fake.setNamePrefixMutex.RUnlock()
+	argsForCall := fake.setNamePrefixArgsForCall[i]
+	return argsForCall.arg1
+}
+
 func (fake *FakeClient) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.adoptContainerMutex.RLock()
+	defer fake.adoptContainerMutex.RUnlock()
+	fake.batchMutex.RLock()
+	defer fake.batchMutex.RUnlock()
+	fake.checkpointContainerMutex.RLock()
+	defer fake.checkpointContainerMutex.RUnlock()
+	fake.closeMutex.RLock()
+	defer fake.closeMutex.RUnlock()
+	fake.commitContainerMutex.RLock()
+	defer fake.commitContainerMutex.RUnlock()
+	fake.createSnapshotMutex.RLock()
+	defer fake.createSnapshotMutex.RUnlock()
+	fake.deleteSnapshotMutex.RLock()
+	defer fake.deleteSnapshotMutex.RUnlock()
 	fake.execMutex.RLock()
 	defer fake.execMutex.RUnlock()
+	fake.execSyncMutex.RLock()
+	defer fake.execSyncMutex.RUnlock()
+	fake.filterContainersMutex.RLock()
+	defer fake.filterContainersMutex.RUnlock()
+	fake.filterSandboxesMutex.RLock()
+	defer fake.filterSandboxesMutex.RUnlock()
 	fake.getContainerMutex.RLock()
 	defer fake.getContainerMutex.RUnlock()
 	fake.getFSPoolUsageMutex.RLock()
@@ -1081,12 +2404,22 @@ func (fake *FakeClient) Invocations() map[string][][]interface{} {
 	defer fake.getSandboxMutex.RUnlock()
 	fake.getServerMutex.RLock()
 	defer fake.getServerMutex.RUnlock()
+	fake.imageRemoteMutex.RLock()
+	defer fake.imageRemoteMutex.RUnlock()
+	fake.importImageMutex.RLock()
+	defer fake.importImageMutex.RUnlock()
 	fake.listContainersMutex.RLock()
 	defer fake.listContainersMutex.RUnlock()
+	fake.listForeignSandboxIDsMutex.RLock()
+	defer fake.listForeignSandboxIDsMutex.RUnlock()
 	fake.listImagesMutex.RLock()
 	defer fake.listImagesMutex.RUnlock()
 	fake.listSandboxesMutex.RLock()
 	defer fake.listSandboxesMutex.RUnlock()
+	fake.listSnapshotsMutex.RLock()
+	defer fake.listSnapshotsMutex.RUnlock()
+	fake.markAdoptionMutex.RLock()
+	defer fake.markAdoptionMutex.RUnlock()
 	fake.newContainerMutex.RLock()
 	defer fake.newContainerMutex.RUnlock()
 	fake.newSandboxMutex.RLock()
@@ -1095,8 +2428,16 @@ func (fake *FakeClient) Invocations() map[string][][]interface{} {
 	defer fake.pullImageMutex.RUnlock()
 	fake.removeImageMutex.RLock()
 	defer fake.removeImageMutex.RUnlock()
+	fake.restoreSnapshotMutex.RLock()
+	defer fake.restoreSnapshotMutex.RUnlock()
 	fake.setEventHandlerMutex.RLock()
 	defer fake.setEventHandlerMutex.RUnlock()
+	fake.setImageCacheMutex.RLock()
+	defer fake.setImageCacheMutex.RUnlock()
+	fake.setImagePolicyMutex.RLock()
+	defer fake.setImagePolicyMutex.RUnlock()
+	fake.setNamePrefixMutex.RLock()
+	defer fake.setNamePrefixMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
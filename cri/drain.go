@@ -0,0 +1,154 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/network"
+	"github.com/sirupsen/logrus"
+)
+
+// DrainOptions define how DrainNode stops the pods
+type DrainOptions struct {
+	// Timeout is how many seconds the containers have to shut down before they're killed, 0 kills them right away
+	Timeout int
+	// Snapshot is the name of a stateful snapshot taken of every running container before it's stopped, so its state can
+	// be restored after the maintenance. If empty, no snapshots are taken
+	Snapshot string
+}
+
+// DrainNode stops all pods located on the LXD (cluster member) LXE is connected to, like kubelet would with
+// StopPodSandbox, for host maintenance when kubelet is already down. The containers are stopped, the pods are marked as
+// not ready and their networks are torn down, so kubelet recreates them when it's back. Returns the IDs of the drained
+// pods. A failing pod doesn't stop the others, the first error is returned
+func (s RuntimeServer) DrainNode(ctx context.Context, opts DrainOptions) ([]string, error) {
+	member, err := s.localMember()
+	if err != nil {
+		return nil, err
+	}
+
+	sbs, err := s.lxf.ListSandboxes()
+	if err != nil {
+		return nil, err
+	}
+
+	pods := []*lxf.Sandbox{}
+	containers := map[string][]*lxf.Container{}
+
+	for _, sb := range sbs {
+		cl, err := sb.Containers()
+		if err != nil {
+			return nil, err
+		}
+
+		local := []*lxf.Container{}
+
+		for _, c := range cl {
+			if member == "" || c.Location == member {
+				local = append(local, c)
+			}
+		}
+
+		// in a cluster only the pods with containers on this member are drained
+		if member != "" && len(local) == 0 {
+			continue
+		}
+
+		pods = append(pods, sb)
+		containers[sb.ID] = local
+	}
+
+	drained := make([]bool, len(pods))
+
+	err = s.lxf.Batch(len(pods), func(i int) error {
+		err := s.drainPod(ctx, pods[i], containers[pods[i].ID], opts)
+		if err != nil {
+			return fmt.Errorf("unable to drain pod %s: %w", pods[i].ID, err)
+		}
+
+		drained[i] = true
+
+		return nil
+	})
+
+	ids := []string{}
+
+	for i, sb := range pods {
+		if drained[i] {
+			ids = append(ids, sb.ID)
+		}
+	}
+
+	return ids, err
+}
+
+// localMember returns the name of the LXD cluster member LXE is connected to, empty if LXD is not clustered
+func (s RuntimeServer) localMember() (string, error) {
+	server := s.lxf.GetServer()
+	if !server.IsClustered() {
+		return "", nil
+	}
+
+	info, _, err := server.GetServer()
+	if err != nil {
+		return "", err
+	}
+
+	return info.Environment.ServerName, nil
+}
+
+// drainPod snapshots and stops the running containers of the pod, tears down their networks and stops the pod
+func (s RuntimeServer) drainPod(ctx context.Context, sb *lxf.Sandbox, cl []*lxf.Container, opts DrainOptions) error {
+	log := log.WithContext(ctx).WithFields(logrus.Fields{
+		"podid":   sb.ID,
+		"podname": sb.Metadata.Namespace + "/" + sb.Metadata.Name,
+	})
+
+	for _, c := range cl {
+		if c.StateName != lxf.ContainerStateRunning {
+			continue
+		}
+
+		if opts.Snapshot != "" {
+			err := s.lxf.CreateSnapshot(ctx, c.ID, opts.Snapshot, true)
+			if err != nil {
+				return fmt.Errorf("unable to snapshot container %s: %w", c.ID, err)
+			}
+		}
+
+		audit := s.audit(ctx, c, auditStopped, "drain")
+
+		err := s.stopContainer(ctx, c, opts.Timeout)
+		s.auditDone(audit, c, err)
+
+		if err != nil {
+			return fmt.Errorf("unable to stop container %s: %w", c.ID, err)
+		}
+
+		// LXE might not be running to handle the stop event
+		err = s.ContainerStopped(ctx, c)
+		if err != nil {
+			log.WithError(err).WithField("containerid", c.ID).Warn("unable to tear down container network")
+		}
+	}
+
+	err := sb.Stop()
+	if err != nil {
+		return err
+	}
+
+	if sb.NetworkConfig.Mode != lxf.NetworkHost {
+		podNet, err := s.podNetwork(sb)
+		if err == nil { // force cleanup, we don't care about error, but only enter if there's no error
+			err = podNet.WhenStopped(ctx, &network.Properties{Data: sb.NetworkConfig.ModeData})
+			if err != nil {
+				log.WithError(err).Warn("unable to tear down pod network")
+			}
+		}
+	}
+
+	log.Info("drained pod")
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package cri
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeServer_localMember(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+
+	member, err := s.localMember()
+	assert.NoError(t, err)
+	assert.Empty(t, member)
+	assert.Equal(t, 0, fakeServer.GetServerCallCount())
+
+	fakeServer.IsClusteredReturns(true)
+	fakeServer.GetServerReturns(&api.Server{Environment: api.ServerEnvironment{ServerName: "member1"}}, "", nil)
+
+	member, err = s.localMember()
+	assert.NoError(t, err)
+	assert.Equal(t, "member1", member)
+}
+
+func TestRuntimeServer_DrainNode_ListError(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	errList := errors.New("list failed")
+	fake.ListSandboxesReturns(nil, errList)
+
+	drained, err := s.DrainNode(context.Background(), DrainOptions{Timeout: 30})
+	assert.True(t, errors.Is(err, errList))
+	assert.Empty(t, drained)
+	assert.Equal(t, 0, fake.BatchCallCount())
+}
+
+func TestRuntimeServer_DrainNode_NoPods(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+
+	drained, err := s.DrainNode(context.Background(), DrainOptions{Timeout: 30, Snapshot: "maintenance"})
+	assert.NoError(t, err)
+	assert.Empty(t, drained)
+	assert.Equal(t, 0, fake.CreateSnapshotCallCount())
+}
@@ -0,0 +1,86 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidEnv        = errors.New("invalid environment variable")
+	ErrInvalidServiceEnv = errors.New("invalid kubernetes service address")
+)
+
+// envValueEscaper escapes the line breaks of multi-line values. LXD writes every variable as a line of the LXC config,
+// where a line break would end the value and the rest would be parsed as another, invalid config line
+var envValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+// validateEnvKey checks if the key can be set as LXD environment config key
+func validateEnvKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: empty name", ErrInvalidEnv)
+	}
+
+	if strings.ContainsAny(key, "= \t\n\r\x00") {
+		return fmt.Errorf("%w: name %q must not contain '=', whitespace or null characters", ErrInvalidEnv, key)
+	}
+
+	return nil
+}
+
+// escapeEnvValue returns the value as it can be set as LXD environment config value. Values without line breaks are
+// kept as they are, so only multi-line values have their backslashes and line breaks escaped
+func escapeEnvValue(key, value string) (string, error) {
+	if strings.ContainsRune(value, 0) {
+		return "", fmt.Errorf("%w: value of %s must not contain null characters", ErrInvalidEnv, key)
+	}
+
+	if !strings.ContainsAny(value, "\n\r") {
+		return value, nil
+	}
+
+	return envValueEscaper.Replace(value), nil
+}
+
+// serviceEnv returns the environment variables kubelet sets for the kubernetes service at address (host:port), for
+// images which expect them even if kubelet doesn't inject them. Returns nil if address is empty
+func serviceEnv(address string) (map[string]string, error) {
+	if address == "" {
+		return nil, nil
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidServiceEnv, err)
+	}
+
+	_, err = strconv.ParseUint(port, 10, 16)
+	if host == "" || err != nil {
+		return nil, fmt.Errorf("%w: %s must be in the form host:port", ErrInvalidServiceEnv, address)
+	}
+
+	url := "tcp://" + net.JoinHostPort(host, port)
+	prefix := "KUBERNETES_PORT_" + port + "_TCP"
+
+	return map[string]string{
+		"KUBERNETES_SERVICE_HOST":       host,
+		"KUBERNETES_SERVICE_PORT":       port,
+		"KUBERNETES_SERVICE_PORT_HTTPS": port,
+		"KUBERNETES_PORT":               url,
+		prefix:                          url,
+		prefix + "_PROTO":               "tcp",
+		prefix + "_PORT":                port,
+		prefix + "_ADDR":                host,
+	}, nil
+}
+
+// applyServiceEnv adds the kubernetes service environment variables to env, variables already set by kubelet are kept
+func (s RuntimeServer) applyServiceEnv(env map[string]string) {
+	for k, v := range s.serviceEnv {
+		if _, has := env[k]; !has {
+			env[k] = v
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package cri
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_validateEnvKey(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, validateEnvKey("HOME"))
+	assert.NoError(t, validateEnvKey("my.dotted-name"))
+
+	for _, key := range []string{"", "A=B", "A B", "A\nB", "A\x00"} {
+		assert.True(t, errors.Is(validateEnvKey(key), ErrInvalidEnv), key)
+	}
+}
+
+func Test_escapeEnvValue(t *testing.T) {
+	t.Parallel()
+
+	for in, out := range map[string]string{
+		"":                  "",
+		`C:\path "quoted"`:  `C:\path "quoted"`,
+		"line1\nline2":      `line1\nline2`,
+		"a\\b\r\nc":         `a\\b\r\nc`,
+		"-----BEGIN-----\n": `-----BEGIN-----\n`,
+	} {
+		v, err := escapeEnvValue("KEY", in)
+		assert.NoError(t, err)
+		assert.Equal(t, out, v)
+	}
+
+	_, err := escapeEnvValue("KEY", "a\x00b")
+	assert.True(t, errors.Is(err, ErrInvalidEnv))
+}
+
+func Test_serviceEnv(t *testing.T) {
+	t.Parallel()
+
+	env, err := serviceEnv("")
+	assert.NoError(t, err)
+	assert.Nil(t, env)
+
+	env, err = serviceEnv("10.96.0.1:443")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"KUBERNETES_SERVICE_HOST":       "10.96.0.1",
+		"KUBERNETES_SERVICE_PORT":       "443",
+		"KUBERNETES_SERVICE_PORT_HTTPS": "443",
+		"KUBERNETES_PORT":               "tcp://10.96.0.1:443",
+		"KUBERNETES_PORT_443_TCP":       "tcp://10.96.0.1:443",
+		"KUBERNETES_PORT_443_TCP_PROTO": "tcp",
+		"KUBERNETES_PORT_443_TCP_PORT":  "443",
+		"KUBERNETES_PORT_443_TCP_ADDR":  "10.96.0.1",
+	}, env)
+
+	env, err = serviceEnv("[fd00::1]:6443")
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp://[fd00::1]:6443", env["KUBERNETES_PORT"])
+
+	for _, address := range []string{"10.96.0.1", ":443", "10.96.0.1:https", "10.96.0.1:70000"} {
+		_, err = serviceEnv(address)
+		assert.True(t, errors.Is(err, ErrInvalidServiceEnv), address)
+	}
+}
+
+func TestRuntimeServer_applyServiceEnv(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+	s.serviceEnv = map[string]string{"KUBERNETES_SERVICE_HOST": "10.96.0.1", "KUBERNETES_SERVICE_PORT": "443"}
+
+	env := map[string]string{"KUBERNETES_SERVICE_HOST": "kubelet", "FOO": "bar"}
+	s.applyServiceEnv(env)
+
+	assert.Equal(t, map[string]string{
+		"KUBERNETES_SERVICE_HOST": "kubelet",
+		"KUBERNETES_SERVICE_PORT": "443",
+		"FOO":                     "bar",
+	}, env)
+}
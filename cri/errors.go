@@ -1,9 +1,19 @@
 package cri // import "github.com/automaticserver/lxe/cri"
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/lxo"
+	"github.com/automaticserver/lxe/network"
+	"github.com/automaticserver/lxe/shared"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Provide possibility to annotate errors for logging. The grpc CallTracer will try to match the returned error and log accordingly.
@@ -21,6 +31,11 @@ func (e AnnotatedError) String() string {
 	return fmt.Sprintf("%s: %v", e.Err, e.Log.Data)
 }
 
+// Unwrap returns the annotated error, so a grpc status of it is returned to the caller
+func (e AnnotatedError) Unwrap() error {
+	return e.Err
+}
+
 func AnnErr(log *logrus.Entry, err error, msg string) error {
 	return AnnotatedError{log, err, msg}
 }
@@ -33,3 +48,99 @@ type SilentError struct {
 func SilErr(log *logrus.Entry, err error, msg string) error {
 	return SilentError{AnnotatedError{log, err, msg}}
 }
+
+// The messages of LXD errors, which are only returned as text, by the grpc code they're translated to
+var (
+	lxdAlreadyExists      = []string{"already exists"}
+	lxdResourceExhausted  = []string{"quota exceeded", "no space left on device", "reached maximum number of"}
+	lxdFailedPrecondition = []string{"storage pool", "storage volume", "is not running", "is already running", "is busy"}
+)
+
+// grpcError is the error returned to CRI clients with the grpc code it's translated to. The error itself is kept, so
+// it can still be matched
+type grpcError struct {
+	code codes.Code
+	err  error
+}
+
+func (e *grpcError) Error() string {
+	return e.err.Error()
+}
+
+func (e *grpcError) Unwrap() error {
+	return e.err
+}
+
+func (e *grpcError) GRPCStatus() *status.Status {
+	return status.New(e.code, e.err.Error())
+}
+
+// toGRPCError translates the error to the grpc code kubelet expects, as it decides by the code whether and how to
+// retry. Errors already having a code other than unknown keep it
+func toGRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if s, has := status.FromError(err); has && s.Code() != codes.Unknown {
+		return err
+	}
+
+	code := grpcCode(err)
+	if code == codes.Unknown {
+		return err
+	}
+
+	return &grpcError{code: code, err: err}
+}
+
+// grpcCode returns the grpc code of known errors of LXE and LXD, unknown for all others
+func grpcCode(err error) codes.Code { // nolint: cyclop
+	switch {
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, lxo.ErrOperationTimeout), errors.Is(err, ErrTimeout):
+		return codes.DeadlineExceeded
+	case errors.Is(err, lxo.ErrOperationLost), errors.Is(err, lxf.ErrClientClosed):
+		return codes.Unavailable
+	case errors.Is(err, ErrNotImplemented), errors.Is(err, network.ErrNotImplemented):
+		return codes.Unimplemented
+	case shared.IsErrNotFound(err):
+		return codes.NotFound
+	case errors.Is(err, network.ErrIPPoolExhausted), errors.Is(err, ErrStoragePoolTooSmall):
+		return codes.ResourceExhausted
+	case errors.Is(err, ErrStoragePoolUnavailable), errors.Is(err, network.ErrNetworkNotReady),
+		errors.Is(err, ErrTimeNamespaceUnsupported):
+		return codes.FailedPrecondition
+	case errors.Is(err, ErrUnknownRuntimeHandler), errors.Is(err, ErrInvalidRuntimeHandler), errors.Is(err, ErrSysctlNotAllowed),
+		errors.Is(err, ErrConfigNotAllowed), errors.Is(err, ErrNicNotAllowed), errors.Is(err, ErrInvalidEnv),
+		errors.Is(err, annotation.ErrInvalidUlimit), errors.Is(err, ErrUnknownTimezone),
+		errors.Is(err, ErrUnknownCapability), errors.Is(err, ErrUnknownNUMANode):
+		return codes.InvalidArgument
+	}
+
+	msg := strings.ToLower(err.Error())
+
+	switch {
+	case containsAny(msg, lxdAlreadyExists):
+		return codes.AlreadyExists
+	case containsAny(msg, lxdResourceExhausted):
+		return codes.ResourceExhausted
+	case strings.Contains(msg, "not found"):
+		return codes.NotFound
+	case containsAny(msg, lxdFailedPrecondition):
+		return codes.FailedPrecondition
+	}
+
+	return codes.Unknown
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,47 @@
+package cri
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf/lxo"
+	"github.com/automaticserver/lxe/network"
+	"github.com/automaticserver/lxe/shared"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_toGRPCError(t *testing.T) {
+	t.Parallel()
+
+	log := logrus.NewEntry(logrus.New())
+
+	for _, tc := range []struct {
+		err  error
+		code codes.Code
+	}{
+		{fmt.Errorf("container abc: %w", shared.NewErrNotFound()), codes.NotFound},
+		{AnnErr(log, errors.New("not found"), "unable to find container"), codes.NotFound},
+		{errors.New("Container 'abc' already exists"), codes.AlreadyExists},
+		{errors.New("Failed to create volume: No space left on device"), codes.ResourceExhausted},
+		{fmt.Errorf("%w: default", network.ErrIPPoolExhausted), codes.ResourceExhausted},
+		{fmt.Errorf("%w: default is Errored", ErrStoragePoolUnavailable), codes.FailedPrecondition},
+		{errors.New("The instance is already running"), codes.FailedPrecondition},
+		{SilErr(log, ErrNotImplemented, ""), codes.Unimplemented},
+		{fmt.Errorf("exec: %w", context.DeadlineExceeded), codes.DeadlineExceeded},
+		{fmt.Errorf("%w: abc: websocket: close 1006", lxo.ErrOperationLost), codes.Unavailable},
+		{status.Error(codes.InvalidArgument, "invalid"), codes.InvalidArgument},
+		{errors.New("something went wrong"), codes.Unknown},
+	} {
+		err := toGRPCError(tc.err)
+		assert.Equal(t, tc.code, status.Code(err), tc.err.Error())
+		assert.True(t, errors.Is(err, tc.err))
+		assert.Equal(t, tc.err.Error(), err.Error())
+	}
+
+	assert.NoError(t, toGRPCError(nil))
+}
@@ -0,0 +1,94 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"sync"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf"
+)
+
+// The types of container events, named like the ContainerEventType of newer CRI versions
+const (
+	ContainerCreatedEvent = "CONTAINER_CREATED_EVENT"
+	ContainerStartedEvent = "CONTAINER_STARTED_EVENT"
+	ContainerStoppedEvent = "CONTAINER_STOPPED_EVENT"
+	ContainerDeletedEvent = "CONTAINER_DELETED_EVENT"
+)
+
+// eventBuffer is how many events a subscriber may lag behind, a subscriber lagging further is dropped
+const eventBuffer = 64
+
+// AdminContainerEvent is a lifecycle event of a container, like the ContainerEventResponse of the GetContainerEvents
+// RPC in newer CRI versions
+type AdminContainerEvent struct {
+	ContainerID  string    `json:"container_id"`
+	Type         string    `json:"type"`
+	PodSandboxID string    `json:"pod_sandbox_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// containerEvents passes the container events to all subscribers
+type containerEvents struct {
+	mu   sync.Mutex
+	subs map[chan AdminContainerEvent]struct{}
+}
+
+func newContainerEvents() *containerEvents {
+	return &containerEvents{subs: map[chan AdminContainerEvent]struct{}{}}
+}
+
+// subscribe returns the channel the events are sent to and the function to unsubscribe. The channel is closed if the
+// subscriber lagged too far behind, so it can start over with a relist instead of missing events silently
+func (e *containerEvents) subscribe() (<-chan AdminContainerEvent, func()) {
+	ch := make(chan AdminContainerEvent, eventBuffer)
+
+	e.mu.Lock()
+	e.subs[ch] = struct{}{}
+	e.mu.Unlock()
+
+	return ch, func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		if _, has := e.subs[ch]; has {
+			delete(e.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish sends the event to all subscribers without blocking, it's a noop if e is nil
+func (e *containerEvents) publish(ev AdminContainerEvent) {
+	if e == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for ch := range e.subs {
+		select {
+		case ch <- ev:
+		default:
+			log.WithField("event", ev.Type).WithField("containerid", ev.ContainerID).
+				Warn("dropping container event subscriber lagging behind")
+			delete(e.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// publishEvent publishes the event of the container
+func (s RuntimeServer) publishEvent(c *lxf.Container, typ string) {
+	ev := AdminContainerEvent{
+		ContainerID: c.ID,
+		Type:        typ,
+		CreatedAt:   time.Now(),
+	}
+
+	if len(c.Profiles) > 0 {
+		ev.PodSandboxID = c.SandboxID()
+	}
+
+	s.events.publish(ev)
+}
@@ -0,0 +1,112 @@
+package cri
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_containerEvents(t *testing.T) {
+	t.Parallel()
+
+	e := newContainerEvents()
+	events, unsubscribe := e.subscribe()
+
+	e.publish(AdminContainerEvent{ContainerID: "abc", Type: ContainerStartedEvent})
+
+	ev := <-events
+	assert.Equal(t, "abc", ev.ContainerID)
+	assert.Equal(t, ContainerStartedEvent, ev.Type)
+
+	unsubscribe()
+	unsubscribe()
+
+	_, ok := <-events
+	assert.False(t, ok)
+
+	// nothing is subscribed anymore
+	e.publish(AdminContainerEvent{ContainerID: "abc", Type: ContainerStoppedEvent})
+}
+
+func Test_containerEvents_Lagging(t *testing.T) {
+	t.Parallel()
+
+	e := newContainerEvents()
+	events, unsubscribe := e.subscribe()
+
+	defer unsubscribe()
+
+	for i := 0; i <= eventBuffer; i++ {
+		e.publish(AdminContainerEvent{ContainerID: "abc", Type: ContainerStartedEvent})
+	}
+
+	n := 0
+	for range events {
+		n++
+	}
+
+	// the lagging subscriber gets the buffered events and is dropped then
+	assert.Equal(t, eventBuffer, n)
+}
+
+func TestRuntimeServer_publishEvent(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+	events, unsubscribe := s.events.subscribe()
+
+	defer unsubscribe()
+
+	c := &lxf.Container{Profiles: []string{"default", "sb"}}
+	c.ID = "abc"
+	s.publishEvent(c, ContainerCreatedEvent)
+
+	c = &lxf.Container{}
+	c.ID = "def"
+	s.publishEvent(c, ContainerDeletedEvent)
+
+	ev := <-events
+	assert.Equal(t, "abc", ev.ContainerID)
+	assert.Equal(t, "sb", ev.PodSandboxID)
+	assert.Equal(t, ContainerCreatedEvent, ev.Type)
+	assert.False(t, ev.CreatedAt.IsZero())
+
+	ev = <-events
+	assert.Equal(t, "def", ev.ContainerID)
+	assert.Equal(t, "", ev.PodSandboxID)
+}
+
+func TestAdminService_Events(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+	a := newAdminService(s.criConfig, s)
+
+	srv := httptest.NewServer(a.server.Handler)
+	defer srv.Close()
+
+	// the subscription is made before the response starts
+	resp, err := http.Get(srv.URL + "/events")
+	assert.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	s.events.publish(AdminContainerEvent{ContainerID: "abc", Type: ContainerStoppedEvent, PodSandboxID: "sb"})
+
+	ev := AdminContainerEvent{}
+	assert.NoError(t, json.NewDecoder(bufio.NewReader(resp.Body)).Decode(&ev))
+	assert.Equal(t, AdminContainerEvent{ContainerID: "abc", Type: ContainerStoppedEvent, PodSandboxID: "sb"}, ev)
+
+	// the stream ends with LXE
+	close(s.stopping)
+
+	_, err = resp.Body.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
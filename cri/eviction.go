@@ -0,0 +1,322 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/sirupsen/logrus"
+)
+
+// EvictionAction defines what happens to the containers of the evicted pod.
+// EvictionActionFreeze freezes the processes, they keep their memory but the pressure caused by reclaim stops.
+// EvictionActionStop stops the containers and frees their memory
+const (
+	EvictionActionFreeze = "freeze"
+	EvictionActionStop   = "stop"
+)
+
+// Reasons reported in the container status of evicted containers
+const (
+	ReasonMemoryPressureFrozen  = "MemoryPressureFrozen"
+	ReasonMemoryPressureStopped = "MemoryPressureStopped"
+)
+
+const (
+	// evictionStopTimeout is short as the host is already under severe memory pressure
+	evictionStopTimeout = 5
+	cfgCgroupParent     = "user.linux.cgroup_parent"
+)
+
+var (
+	ErrUnknownEvictionAction = errors.New("unknown eviction action")
+	ErrPSIParse              = errors.New("unable to parse pressure stall information")
+	// memoryPressureFile is where the kernel reports the memory pressure stall information
+	memoryPressureFile = "/proc/pressure/memory"
+	// qosPriorities are the default eviction priorities of the QoS classes, as found in the cgroup parent of the pod
+	qosPriorities = map[string]int{
+		"besteffort": 0,
+		"burstable":  1000,
+	}
+	qosPriorityGuaranteed = 2000
+)
+
+// readMemoryPressure returns the avg10 value of the "full" line, which is the percentage of time in the last 10 seconds
+// all non-idle tasks were stalled on memory at the same time
+func readMemoryPressure(file string) (float64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "full" {
+			continue
+		}
+
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "avg10=") {
+				continue
+			}
+
+			avg, err := strconv.ParseFloat(strings.TrimPrefix(field, "avg10="), 64)
+			if err != nil {
+				return 0, fmt.Errorf("%w: %v", ErrPSIParse, err)
+			}
+
+			return avg, nil
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	return 0, fmt.Errorf("%w: no full avg10 value in %s", ErrPSIParse, file)
+}
+
+// podPriority returns the eviction priority of the pod. The annotation has priority over the QoS class
+func podPriority(sb *lxf.Sandbox) int {
+	if str, has := annotation.EvictionPriority.Get(sb.Annotations); has {
+		prio, err := annotation.ParseEvictionPriority(str)
+		if err == nil {
+			return prio
+		}
+	}
+
+	parent := strings.ToLower(sb.Config[cfgCgroupParent])
+	for qos, prio := range qosPriorities {
+		if strings.Contains(parent, qos) {
+			return prio
+		}
+	}
+
+	// guaranteed pods are placed directly below the kubepods cgroup
+	return qosPriorityGuaranteed
+}
+
+// evictionOrder returns the pods of the containers matching the filter in the order they're evicted, the lowest
+// priority first and of the same priority the youngest first, with their matching containers
+func (s RuntimeServer) evictionOrder(filter func(c *lxf.Container) bool) ([]*lxf.Sandbox, map[string][]*lxf.Container, error) {
+	cl, err := s.lxf.ListContainers()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pods := map[string][]*lxf.Container{}
+	sandboxes := []*lxf.Sandbox{}
+
+	for _, c := range cl {
+		if !filter(c) {
+			continue
+		}
+
+		if _, has := pods[c.SandboxID()]; !has {
+			sb, err := s.lxf.GetSandbox(c.SandboxID())
+			if err != nil {
+				return nil, nil, err
+			}
+
+			sandboxes = append(sandboxes, sb)
+		}
+
+		pods[c.SandboxID()] = append(pods[c.SandboxID()], c)
+	}
+
+	sort.SliceStable(sandboxes, func(i, j int) bool {
+		pi, pj := podPriority(sandboxes[i]), podPriority(sandboxes[j])
+		if pi != pj {
+			return pi < pj
+		}
+
+		return sandboxes[i].CreatedAt.After(sandboxes[j].CreatedAt)
+	})
+
+	return sandboxes, pods, nil
+}
+
+// evictionCandidate returns the running and not yet evicted containers of the pod with the lowest priority. If several
+// pods have the same priority, the youngest is chosen
+func (s RuntimeServer) evictionCandidate() (*lxf.Sandbox, []*lxf.Container, error) {
+	sandboxes, pods, err := s.evictionOrder(func(c *lxf.Container) bool {
+		return c.StateName == lxf.ContainerStateRunning && c.EvictionReason == ""
+	})
+	if err != nil || len(sandboxes) == 0 {
+		return nil, nil, err
+	}
+
+	return sandboxes[0], pods[sandboxes[0].ID], nil
+}
+
+// thawCandidate returns the frozen containers of the pod which was evicted last, which has the highest priority. If
+// several pods have the same priority, the oldest is chosen
+func (s RuntimeServer) thawCandidate() (*lxf.Sandbox, []*lxf.Container, error) {
+	sandboxes, pods, err := s.evictionOrder(func(c *lxf.Container) bool {
+		return c.EvictionReason == ReasonMemoryPressureFrozen
+	})
+	if err != nil || len(sandboxes) == 0 {
+		return nil, nil, err
+	}
+
+	last := sandboxes[len(sandboxes)-1]
+
+	return last, pods[last.ID], nil
+}
+
+// thaw unfreezes the containers of one frozen pod, the reverse of the eviction order. Returns false if no pod is frozen
+func (s RuntimeServer) thaw(ctx context.Context, pressure float64) (bool, error) {
+	sb, cl, err := s.thawCandidate()
+	if err != nil || sb == nil {
+		return false, err
+	}
+
+	log.WithFields(logrus.Fields{
+		"podname":   sb.Metadata.Name,
+		"namespace": sb.Metadata.Namespace,
+		"priority":  podPriority(sb),
+		"pressure":  pressure,
+	}).Info("thaw pod as host memory pressure is below threshold")
+
+	return true, s.lxf.Batch(len(cl), func(i int) error {
+		c := cl[i]
+
+		audit := s.audit(ctx, c, auditThawed, ReasonMemoryPressureFrozen)
+		err := c.Unfreeze(ctx)
+		s.auditDone(audit, c, err)
+
+		if err != nil {
+			return fmt.Errorf("unable to thaw container %s: %w", c.ID, err)
+		}
+
+		return nil
+	})
+}
+
+// evict freezes or stops the containers of the pod with the lowest priority and records the reason in the containers
+func (s RuntimeServer) evict(ctx context.Context, pressure float64) error {
+	sb, cl, err := s.evictionCandidate()
+	if err != nil {
+		return err
+	}
+
+	if sb == nil {
+		log.WithField("pressure", pressure).Warn("memory pressure is above threshold, but no pod left to evict")
+		return nil
+	}
+
+	log := log.WithFields(logrus.Fields{
+		"podname":   sb.Metadata.Name,
+		"namespace": sb.Metadata.Namespace,
+		"priority":  podPriority(sb),
+		"pressure":  pressure,
+		"action":    s.criConfig.LXEEvictionAction,
+	})
+	log.Warn("evict pod due to host memory pressure")
+
+	message := fmt.Sprintf("host memory pressure %.2f%% exceeded threshold %.2f%%", pressure, s.criConfig.LXEEvictionPSIThreshold)
+
+	switch s.criConfig.LXEEvictionAction {
+	case EvictionActionFreeze, EvictionActionStop:
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownEvictionAction, s.criConfig.LXEEvictionAction)
+	}
+
+	return s.lxf.Batch(len(cl), func(i int) error {
+		c := cl[i]
+		c.EvictionMessage = message
+
+		var err error
+
+		if s.criConfig.LXEEvictionAction == EvictionActionFreeze {
+			c.EvictionReason = ReasonMemoryPressureFrozen
+			audit := s.audit(ctx, c, auditFrozen, c.EvictionReason)
+			err = c.Freeze(ctx)
+			s.auditDone(audit, c, err)
+		} else {
+			c.EvictionReason = ReasonMemoryPressureStopped
+			audit := s.audit(ctx, c, auditStopped, c.EvictionReason)
+			err = s.stopContainer(ctx, c, evictionStopTimeout)
+			s.auditDone(audit, c, err)
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to evict container %s: %w", c.ID, err)
+		}
+
+		return nil
+	})
+}
+
+// evictionGuard periodically checks the host memory pressure and evicts one pod per interval while the pressure is
+// above the threshold. This is meant to act before the kernel OOM killer picks a critical process. Once the pressure is
+// below the threshold again, one frozen pod per interval is thawed. If the pressure can't be read, it's tried again on
+// the next interval, the error is only logged once till it can be read again
+func (s RuntimeServer) evictionGuard() {
+	ticker := time.NewTicker(s.criConfig.LXEEvictionInterval)
+	defer ticker.Stop()
+
+	failing := false
+
+	for {
+		select {
+		case <-s.stopping:
+			return
+		case <-ticker.C:
+		}
+
+		err := s.guardPressure(memoryPressureFile)
+		if err != nil {
+			if !failing {
+				log.WithError(err).Error("unable to read memory pressure, trying again on the next interval")
+			}
+
+			failing = true
+
+			continue
+		}
+
+		if failing {
+			log.Info("memory pressure can be read again")
+		}
+
+		failing = false
+	}
+}
+
+// guardPressure reads the host memory pressure from the file and evicts or thaws a pod. Only the error of reading the
+// pressure is returned, failed evictions and thaws are logged
+func (s RuntimeServer) guardPressure(file string) error {
+	pressure, err := readMemoryPressure(file)
+	if err != nil {
+		return err
+	}
+
+	// the eviction isn't bound to a request
+	if pressure < s.criConfig.LXEEvictionPSIThreshold {
+		_, err = s.thaw(context.Background(), pressure)
+		if err != nil {
+			log.WithError(err).Error("unable to thaw pod")
+		}
+
+		return nil
+	}
+
+	err = s.evict(context.Background(), pressure)
+	if err != nil {
+		log.WithError(err).Error("unable to evict pod")
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package cri
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_readMemoryPressure(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-psi")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "memory")
+	err = ioutil.WriteFile(file, []byte("some avg10=12.50 avg60=3.00 avg300=1.00 total=1234\nfull avg10=7.25 avg60=1.50 avg300=0.50 total=567\n"), 0600)
+	assert.NoError(t, err)
+
+	pressure, err := readMemoryPressure(file)
+	assert.NoError(t, err)
+	assert.Equal(t, 7.25, pressure)
+
+	err = ioutil.WriteFile(file, []byte("some avg10=12.50 avg60=3.00 avg300=1.00 total=1234\n"), 0600)
+	assert.NoError(t, err)
+
+	_, err = readMemoryPressure(file)
+	assert.True(t, errors.Is(err, ErrPSIParse))
+
+	_, err = readMemoryPressure(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func testEvictionSandbox(id, cgroupParent string, annotations map[string]string, createdAt time.Time) *lxf.Sandbox {
+	sb := &lxf.Sandbox{}
+	sb.ID = id
+	sb.Config = map[string]string{cfgCgroupParent: cgroupParent}
+	sb.Annotations = annotations
+	sb.CreatedAt = createdAt
+
+	return sb
+}
+
+func Test_podPriority(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 0, podPriority(testEvictionSandbox("a", "/kubepods/besteffort/poduid", nil, time.Time{})))
+	assert.Equal(t, 1000, podPriority(testEvictionSandbox("a", "/kubepods/burstable/poduid", nil, time.Time{})))
+	assert.Equal(t, 2000, podPriority(testEvictionSandbox("a", "/kubepods/poduid", nil, time.Time{})))
+	assert.Equal(t, -5, podPriority(testEvictionSandbox("a", "/kubepods/poduid", map[string]string{annotation.EvictionPriority.Name: "-5"}, time.Time{})))
+}
+
+func TestRuntimeServer_evictionCandidate(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+
+	newContainer := func(id, sandboxID string, state lxf.ContainerStateName) *lxf.Container {
+		c := &lxf.Container{}
+		c.ID = id
+		c.Profiles = []string{sandboxID}
+		c.StateName = state
+
+		return c
+	}
+
+	evicted := newContainer("evicted", "besteffort-old", lxf.ContainerStateRunning)
+	evicted.EvictionReason = ReasonMemoryPressureFrozen
+
+	fake.ListContainersReturns([]*lxf.Container{
+		newContainer("guaranteed", "guaranteed", lxf.ContainerStateRunning),
+		evicted,
+		newContainer("besteffort-1", "besteffort", lxf.ContainerStateRunning),
+		newContainer("besteffort-2", "besteffort", lxf.ContainerStateRunning),
+		newContainer("besteffort-exited", "besteffort", lxf.ContainerStateExited),
+		newContainer("besteffort-young", "besteffort-young", lxf.ContainerStateRunning),
+	}, nil)
+
+	now := time.Now()
+	sandboxes := map[string]*lxf.Sandbox{
+		"guaranteed":       testEvictionSandbox("guaranteed", "/kubepods/pod1", nil, now),
+		"besteffort-old":   testEvictionSandbox("besteffort-old", "/kubepods/besteffort/pod2", nil, now.Add(-time.Hour)),
+		"besteffort":       testEvictionSandbox("besteffort", "/kubepods/besteffort/pod3", nil, now.Add(-time.Minute)),
+		"besteffort-young": testEvictionSandbox("besteffort-young", "/kubepods/besteffort/pod4", nil, now),
+	}
+	fake.GetSandboxStub = func(id string) (*lxf.Sandbox, error) {
+		return sandboxes[id], nil
+	}
+
+	sb, cl, err := s.evictionCandidate()
+	assert.NoError(t, err)
+	assert.Equal(t, "besteffort-young", sb.ID)
+	assert.Len(t, cl, 1)
+
+	delete(sandboxes, "besteffort-young")
+	fake.ListContainersReturns([]*lxf.Container{
+		newContainer("besteffort-1", "besteffort", lxf.ContainerStateRunning),
+		newContainer("besteffort-2", "besteffort", lxf.ContainerStateRunning),
+		newContainer("guaranteed", "guaranteed", lxf.ContainerStateRunning),
+	}, nil)
+
+	sb, cl, err = s.evictionCandidate()
+	assert.NoError(t, err)
+	assert.Equal(t, "besteffort", sb.ID)
+	assert.Len(t, cl, 2)
+}
+
+func TestRuntimeServer_evictionCandidate_None(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+
+	sb, cl, err := s.evictionCandidate()
+	assert.NoError(t, err)
+	assert.Nil(t, sb)
+	assert.Nil(t, cl)
+}
+
+func TestRuntimeServer_thawCandidate(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+
+	newContainer := func(id, sandboxID, reason string) *lxf.Container {
+		c := &lxf.Container{}
+		c.ID = id
+		c.Profiles = []string{sandboxID}
+		c.StateName = lxf.ContainerStateRunning
+		c.EvictionReason = reason
+
+		return c
+	}
+
+	fake.ListContainersReturns([]*lxf.Container{
+		newContainer("running", "guaranteed", ""),
+		newContainer("stopped", "burstable-stopped", ReasonMemoryPressureStopped),
+		newContainer("besteffort", "besteffort", ReasonMemoryPressureFrozen),
+		newContainer("burstable-young", "burstable-young", ReasonMemoryPressureFrozen),
+		newContainer("burstable-old-1", "burstable-old", ReasonMemoryPressureFrozen),
+		newContainer("burstable-old-2", "burstable-old", ReasonMemoryPressureFrozen),
+	}, nil)
+
+	now := time.Now()
+	sandboxes := map[string]*lxf.Sandbox{
+		"burstable-stopped": testEvictionSandbox("burstable-stopped", "/kubepods/burstable/pod1", nil, now.Add(-2*time.Hour)),
+		"besteffort":        testEvictionSandbox("besteffort", "/kubepods/besteffort/pod2", nil, now.Add(-2*time.Hour)),
+		"burstable-young":   testEvictionSandbox("burstable-young", "/kubepods/burstable/pod3", nil, now),
+		"burstable-old":     testEvictionSandbox("burstable-old", "/kubepods/burstable/pod4", nil, now.Add(-time.Hour)),
+	}
+	fake.GetSandboxStub = func(id string) (*lxf.Sandbox, error) {
+		return sandboxes[id], nil
+	}
+
+	// the frozen pod with the highest priority, which was evicted last, is thawed first
+	sb, cl, err := s.thawCandidate()
+	assert.NoError(t, err)
+	assert.Equal(t, "burstable-old", sb.ID)
+	assert.Len(t, cl, 2)
+
+	fake.ListContainersReturns([]*lxf.Container{newContainer("running", "guaranteed", "")}, nil)
+
+	thawed, err := s.thaw(ctx, 0)
+	assert.NoError(t, err)
+	assert.False(t, thawed)
+}
+
+func TestRuntimeServer_evict_UnknownAction(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	s.criConfig.LXEEvictionAction = "pause"
+
+	c := &lxf.Container{}
+	c.ID = "besteffort-1"
+	c.Profiles = []string{"besteffort"}
+	c.StateName = lxf.ContainerStateRunning
+
+	fake.ListContainersReturns([]*lxf.Container{c}, nil)
+	fake.GetSandboxReturns(testEvictionSandbox("besteffort", "/kubepods/besteffort/pod1", nil, time.Now()), nil)
+
+	err := s.evict(ctx, 90)
+	assert.True(t, errors.Is(err, ErrUnknownEvictionAction))
+	assert.Equal(t, 0, fake.BatchCallCount())
+	assert.Empty(t, c.EvictionReason)
+}
+
+func TestRuntimeServer_guardPressure(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	s.criConfig.LXEEvictionPSIThreshold = 10
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "memory")
+
+	// a missing or unparsable file is reported, so it's tried again on the next interval
+	assert.Error(t, s.guardPressure(file))
+	assert.Equal(t, 0, fake.ListContainersCallCount())
+
+	err := ioutil.WriteFile(file, []byte("full avg10=1.00 avg60=1.00 avg300=1.00 total=1\n"), 0600)
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.guardPressure(file))
+	assert.Equal(t, 1, fake.ListContainersCallCount(), "looks for a frozen pod to thaw")
+}
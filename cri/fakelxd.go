@@ -0,0 +1,30 @@
+//go:build lxdfake
+// +build lxdfake
+
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/lxo"
+	"github.com/automaticserver/lxe/lxf/memlxd"
+	lxd "github.com/lxc/lxd/client"
+)
+
+// LXDFakeSupported is true if LXE is built with the lxdfake build tag, which adds the in-memory LXD
+const LXDFakeSupported = true
+
+// newFakeClient returns the client of an in-memory LXD, for running the CRI validation suite without LXD. Every remote
+// of the lxc config serves an image for every alias
+func newFakeClient(criConfig *Config, configPath string, opconf lxo.Conf, project lxf.Project) (lxf.Client, error) {
+	conf, err := loadLXDConfig(criConfig, configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := memlxd.NewRegistry()
+	images := func(remote string) (lxd.ImageServer, error) {
+		return registry, nil
+	}
+
+	return lxf.NewClientWithServer(memlxd.New(), images, conf, opconf, criConfig.LXEOwner, project)
+}
@@ -0,0 +1,281 @@
+//go:build lxdfake
+// +build lxdfake
+
+package cri
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/lxo"
+	"github.com/automaticserver/lxe/network"
+	"github.com/automaticserver/lxe/shared"
+	"github.com/stretchr/testify/assert"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func TestFakeClient_PodLifecycle(t *testing.T) {
+	t.Parallel()
+
+	criConfig := &Config{
+		LXDFake:            true,
+		LXDRemoteConfig:    "/nonexistent/config.yml",
+		LXDImageRemote:     "local",
+		LXDProfiles:        []string{"default"},
+		LXENetworkPlugin:   NetworkPluginBridge,
+		LXEBridgeName:      network.DefaultLXDBridge,
+		LXESysctlAllowlist: DefaultSysctlAllowlist,
+	}
+
+	client, err := newFakeClient(criConfig, criConfig.LXDRemoteConfig, lxo.Conf{}, lxf.Project{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, lxf.NewMigrationWorkspace(client).Ensure())
+
+	netPlugin, err := initNetworkPlugin(criConfig, client, newCNIOutputFiles())
+	assert.NoError(t, err)
+
+	s, err := NewRuntimeServer(criConfig, client, netPlugin)
+	assert.NoError(t, err)
+
+	client.SetEventHandler(s)
+
+	images, err := NewImageServer(s, client)
+	assert.NoError(t, err)
+
+	pulled, err := images.PullImage(ctx, &rtApi.PullImageRequest{Image: &rtApi.ImageSpec{Image: "busybox:1.28"}})
+	assert.NoError(t, err)
+
+	sbConfig := &rtApi.PodSandboxConfig{
+		Metadata: &rtApi.PodSandboxMetadata{Name: "pod", Namespace: "default", Uid: "uid"},
+	}
+
+	sb, err := s.RunPodSandbox(ctx, &rtApi.RunPodSandboxRequest{Config: sbConfig})
+	assert.NoError(t, err)
+
+	ct, err := s.CreateContainer(ctx, &rtApi.CreateContainerRequest{
+		PodSandboxId: sb.PodSandboxId,
+		Config: &rtApi.ContainerConfig{
+			Metadata: &rtApi.ContainerMetadata{Name: "ct"},
+			Image:    &rtApi.ImageSpec{Image: pulled.ImageRef},
+			Envs:     []*rtApi.KeyValue{{Key: "GREETING", Value: "hello"}},
+		},
+		SandboxConfig: sbConfig,
+	})
+	assert.NoError(t, err)
+
+	_, err = s.StartContainer(ctx, &rtApi.StartContainerRequest{ContainerId: ct.ContainerId})
+	assert.NoError(t, err)
+
+	status, err := s.ContainerStatus(ctx, &rtApi.ContainerStatusRequest{ContainerId: ct.ContainerId})
+	assert.NoError(t, err)
+	assert.Equal(t, rtApi.ContainerState_CONTAINER_RUNNING, status.GetStatus().GetState())
+
+	exec, err := s.ExecSync(ctx, &rtApi.ExecSyncRequest{
+		ContainerId: ct.ContainerId,
+		Cmd:         []string{"sh", "-c", "echo $GREETING > /tmp/out && cat /tmp/out; missing"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(exec.Stdout))
+	assert.Contains(t, string(exec.Stderr), "missing: not found")
+	assert.Equal(t, int32(127), exec.ExitCode)
+
+	_, err = s.StopPodSandbox(ctx, &rtApi.StopPodSandboxRequest{PodSandboxId: sb.PodSandboxId})
+	assert.NoError(t, err)
+
+	status, err = s.ContainerStatus(ctx, &rtApi.ContainerStatusRequest{ContainerId: ct.ContainerId})
+	assert.NoError(t, err)
+	assert.Equal(t, rtApi.ContainerState_CONTAINER_EXITED, status.GetStatus().GetState())
+
+	_, err = s.RemovePodSandbox(ctx, &rtApi.RemovePodSandboxRequest{PodSandboxId: sb.PodSandboxId})
+	assert.NoError(t, err)
+
+	list, err := s.ListPodSandbox(ctx, &rtApi.ListPodSandboxRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, list.Items)
+}
+
+func TestRuntimeServer_evict_Thaw(t *testing.T) {
+	t.Parallel()
+
+	criConfig := &Config{
+		LXDFake:                 true,
+		LXDRemoteConfig:         "/nonexistent/config.yml",
+		LXDImageRemote:          "local",
+		LXDProfiles:             []string{"default"},
+		LXENetworkPlugin:        NetworkPluginBridge,
+		LXEBridgeName:           network.DefaultLXDBridge,
+		LXESysctlAllowlist:      DefaultSysctlAllowlist,
+		LXEEvictionPSIThreshold: 10,
+		LXEEvictionAction:       EvictionActionFreeze,
+	}
+
+	client, err := newFakeClient(criConfig, criConfig.LXDRemoteConfig, lxo.Conf{}, lxf.Project{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, lxf.NewMigrationWorkspace(client).Ensure())
+
+	netPlugin, err := initNetworkPlugin(criConfig, client, newCNIOutputFiles())
+	assert.NoError(t, err)
+
+	s, err := NewRuntimeServer(criConfig, client, netPlugin)
+	assert.NoError(t, err)
+
+	client.SetEventHandler(s)
+
+	images, err := NewImageServer(s, client)
+	assert.NoError(t, err)
+
+	pulled, err := images.PullImage(ctx, &rtApi.PullImageRequest{Image: &rtApi.ImageSpec{Image: "busybox:1.28"}})
+	assert.NoError(t, err)
+
+	sbConfig := &rtApi.PodSandboxConfig{
+		Metadata: &rtApi.PodSandboxMetadata{Name: "pod", Namespace: "default", Uid: "uid"},
+	}
+
+	sb, err := s.RunPodSandbox(ctx, &rtApi.RunPodSandboxRequest{Config: sbConfig})
+	assert.NoError(t, err)
+
+	ct, err := s.CreateContainer(ctx, &rtApi.CreateContainerRequest{
+		PodSandboxId: sb.PodSandboxId,
+		Config: &rtApi.ContainerConfig{
+			Metadata: &rtApi.ContainerMetadata{Name: "ct"},
+			Image:    &rtApi.ImageSpec{Image: pulled.ImageRef},
+		},
+		SandboxConfig: sbConfig,
+	})
+	assert.NoError(t, err)
+
+	_, err = s.StartContainer(ctx, &rtApi.StartContainerRequest{ContainerId: ct.ContainerId})
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.evict(ctx, 50))
+
+	status, err := s.ContainerStatus(ctx, &rtApi.ContainerStatusRequest{ContainerId: ct.ContainerId})
+	assert.NoError(t, err)
+	assert.Equal(t, ReasonMemoryPressureFrozen, status.GetStatus().GetReason())
+
+	// the pressure is below the threshold again
+	thawed, err := s.thaw(ctx, 1)
+	assert.NoError(t, err)
+	assert.True(t, thawed)
+
+	status, err = s.ContainerStatus(ctx, &rtApi.ContainerStatusRequest{ContainerId: ct.ContainerId})
+	assert.NoError(t, err)
+	assert.Equal(t, rtApi.ContainerState_CONTAINER_RUNNING, status.GetStatus().GetState())
+	assert.Empty(t, status.GetStatus().GetReason())
+
+	thawed, err = s.thaw(ctx, 1)
+	assert.NoError(t, err)
+	assert.False(t, thawed)
+
+	// a thawed container can be frozen again
+	assert.NoError(t, s.evict(ctx, 50))
+
+	status, err = s.ContainerStatus(ctx, &rtApi.ContainerStatusRequest{ContainerId: ct.ContainerId})
+	assert.NoError(t, err)
+	assert.Equal(t, ReasonMemoryPressureFrozen, status.GetStatus().GetReason())
+}
+
+func TestRuntimeServer_recoverJournal(t *testing.T) {
+	t.Parallel()
+
+	criConfig := &Config{
+		LXDFake:            true,
+		LXDRemoteConfig:    "/nonexistent/config.yml",
+		LXDImageRemote:     "local",
+		LXDProfiles:        []string{"default"},
+		LXENetworkPlugin:   NetworkPluginBridge,
+		LXEBridgeName:      network.DefaultLXDBridge,
+		LXESysctlAllowlist: DefaultSysctlAllowlist,
+		LXEJournalFile:     filepath.Join(t.TempDir(), "journal"),
+	}
+
+	client, err := newFakeClient(criConfig, criConfig.LXDRemoteConfig, lxo.Conf{}, lxf.Project{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, lxf.NewMigrationWorkspace(client).Ensure())
+
+	netPlugin, err := initNetworkPlugin(criConfig, client, newCNIOutputFiles())
+	assert.NoError(t, err)
+
+	s, err := NewRuntimeServer(criConfig, client, netPlugin)
+	assert.NoError(t, err)
+
+	images, err := NewImageServer(s, client)
+	assert.NoError(t, err)
+
+	pulled, err := images.PullImage(ctx, &rtApi.PullImageRequest{Image: &rtApi.ImageSpec{Image: "busybox:1.28"}})
+	assert.NoError(t, err)
+
+	runPod := func(name string) (string, *rtApi.PodSandboxConfig) {
+		sbConfig := &rtApi.PodSandboxConfig{
+			Metadata: &rtApi.PodSandboxMetadata{Name: name, Namespace: "default", Uid: name},
+		}
+
+		sb, err := s.RunPodSandbox(ctx, &rtApi.RunPodSandboxRequest{Config: sbConfig})
+		assert.NoError(t, err)
+
+		return sb.PodSandboxId, sbConfig
+	}
+
+	stopped, _ := runPod("stopped")
+	created, sbConfig := runPod("created")
+
+	ct, err := s.CreateContainer(ctx, &rtApi.CreateContainerRequest{
+		PodSandboxId: created,
+		Config: &rtApi.ContainerConfig{
+			Metadata: &rtApi.ContainerMetadata{Name: "ct"},
+			Image:    &rtApi.ImageSpec{Image: pulled.ImageRef},
+		},
+		SandboxConfig: sbConfig,
+	})
+	assert.NoError(t, err)
+
+	// the calls of the crash: the creation of the container was interrupted before kubelet got its id, the pod was
+	// being stopped and the creation of a pod was interrupted before its id was journaled
+	_, err = s.journal.begin(&journalEntry{Call: journalCreateContainer, Object: ct.ContainerId})
+	assert.NoError(t, err)
+
+	_, err = s.journal.begin(&journalEntry{Call: journalStopPod, Object: stopped})
+	assert.NoError(t, err)
+
+	_, err = s.journal.begin(&journalEntry{
+		Call: journalRunPod,
+		Pod:  &lxf.SandboxMetadata{Name: "interrupted", Namespace: "default", UID: "interrupted"},
+	})
+	assert.NoError(t, err)
+
+	interrupted, _ := runPod("interrupted")
+
+	assert.NoError(t, s.journal.close())
+
+	// the restart
+	s, err = NewRuntimeServer(criConfig, client, netPlugin)
+	assert.NoError(t, err)
+	assert.Len(t, s.journal.interrupted, 3)
+
+	s.recoverJournal(ctx)
+
+	_, err = client.GetContainer(ct.ContainerId)
+	assert.True(t, shared.IsErrNotFound(err), fmt.Sprint(err))
+
+	sb, err := client.GetSandbox(stopped)
+	assert.NoError(t, err)
+	assert.Equal(t, lxf.SandboxNotReady, sb.State)
+
+	_, err = client.GetSandbox(interrupted)
+	assert.True(t, shared.IsErrNotFound(err), fmt.Sprint(err))
+
+	sb, err = client.GetSandbox(created)
+	assert.NoError(t, err)
+	assert.Equal(t, lxf.SandboxReady, sb.State)
+
+	assert.NoError(t, s.journal.close())
+
+	j, err := openJournal(criConfig.LXEJournalFile)
+	assert.NoError(t, err)
+	assert.Empty(t, j.interrupted)
+}
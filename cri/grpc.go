@@ -0,0 +1,177 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+var (
+	ErrTCPTLSIncomplete   = errors.New("the tcp listener requires a tls certificate, key and client ca")
+	ErrInvalidSocketMode  = errors.New("invalid socket mode")
+	ErrInvalidSocketOwner = errors.New("invalid socket owner")
+	ErrInvalidClientCA    = errors.New("no certificate found in client ca")
+)
+
+// newGRPCServer creates the grpc server for the CRI services with the configured limits
+func newGRPCServer(criConfig *Config) (*grpc.Server, error) {
+	interceptors := []grpc.UnaryServerInterceptor{callSpan, callMetrics}
+
+	limiter, err := newCallLimiter(criConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if limiter != nil {
+		interceptors = append(interceptors, limiter.intercept)
+	}
+
+	opts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(append(interceptors, callTracing)...)}
+
+	if criConfig.LXEGRPCMaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(criConfig.LXEGRPCMaxConcurrentStreams))
+	}
+
+	if criConfig.LXEGRPCKeepaliveMinTime > 0 {
+		// clients pinging more often are disconnected
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             criConfig.LXEGRPCKeepaliveMinTime,
+			PermitWithoutStream: true,
+		}))
+	}
+
+	if criConfig.LXEGRPCKeepaliveTime > 0 {
+		// idle clients are pinged and disconnected if they don't answer, e.g. a remote kubelet which vanished
+		opts = append(opts, grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time: criConfig.LXEGRPCKeepaliveTime,
+		}))
+	}
+
+	return grpc.NewServer(opts...), nil
+}
+
+// socketPermissions contains the mode and owner to set on the CRI socket
+type socketPermissions struct {
+	mode os.FileMode
+	// uid and gid are -1 if they are kept
+	uid int
+	gid int
+}
+
+// newSocketPermissions parses the octal mode and the owner in the form [user][:group] of the CRI socket. User and group
+// are names or ids, an empty one is kept
+func newSocketPermissions(mode, owner string) (*socketPermissions, error) {
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || m > 0777 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSocketMode, mode)
+	}
+
+	p := &socketPermissions{mode: os.FileMode(m), uid: -1, gid: -1}
+
+	if owner == "" {
+		return p, nil
+	}
+
+	parts := strings.SplitN(owner, ":", 2)
+
+	if parts[0] != "" {
+		p.uid, err = lookupID(parts[0], func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+
+			return u.Uid, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidSocketOwner, owner, err)
+		}
+	}
+
+	if len(parts) > 1 && parts[1] != "" {
+		p.gid, err = lookupID(parts[1], func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+
+			return g.Gid, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidSocketOwner, owner, err)
+		}
+	}
+
+	return p, nil
+}
+
+// lookupID returns the numeric id of a user or group, which is either the id itself or looked up by name
+func lookupID(v string, lookup func(name string) (string, error)) (int, error) {
+	id, err := strconv.Atoi(v)
+	if err == nil {
+		return id, nil
+	}
+
+	s, err := lookup(v)
+	if err != nil {
+		return -1, err
+	}
+
+	return strconv.Atoi(s)
+}
+
+// apply sets the mode and owner of the socket
+func (p *socketPermissions) apply(sock string) error {
+	err := os.Chmod(sock, p.mode)
+	if err != nil {
+		return err
+	}
+
+	if p.uid == -1 && p.gid == -1 {
+		return nil
+	}
+
+	return os.Chown(sock, p.uid, p.gid)
+}
+
+// newTCPListener listens on the tcp bind address with tls and only accepts clients with a certificate signed by the
+// client ca, as the CRI gives full control over the containers
+func newTCPListener(criConfig *Config) (net.Listener, error) {
+	if criConfig.LXETCPTLSCert == "" || criConfig.LXETCPTLSKey == "" || criConfig.LXETCPTLSClientCA == "" {
+		return nil, ErrTCPTLSIncomplete
+	}
+
+	cert, err := tls.LoadX509KeyPair(criConfig.LXETCPTLSCert, criConfig.LXETCPTLSKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ca, err := ioutil.ReadFile(criConfig.LXETCPTLSClientCA)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidClientCA, criConfig.LXETCPTLSClientCA)
+	}
+
+	return tls.Listen("tcp", criConfig.LXETCPBindAddr, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+		// grpc clients require http2 to be negotiated
+		NextProtos: []string{"h2"},
+	})
+}
@@ -0,0 +1,110 @@
+package cri
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_newSocketPermissions(t *testing.T) {
+	t.Parallel()
+
+	p, err := newSocketPermissions("0660", "")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0660), p.mode)
+	assert.Equal(t, -1, p.uid)
+	assert.Equal(t, -1, p.gid)
+
+	p, err = newSocketPermissions("600", "0:123")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), p.mode)
+	assert.Equal(t, 0, p.uid)
+	assert.Equal(t, 123, p.gid)
+
+	p, err = newSocketPermissions("0600", ":5")
+	assert.NoError(t, err)
+	assert.Equal(t, -1, p.uid)
+	assert.Equal(t, 5, p.gid)
+
+	p, err = newSocketPermissions("0600", "root")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, p.uid)
+	assert.Equal(t, -1, p.gid)
+}
+
+func Test_newSocketPermissions_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, mode := range []string{"", "rw", "0999", "01777"} {
+		_, err := newSocketPermissions(mode, "")
+		assert.True(t, errors.Is(err, ErrInvalidSocketMode), mode)
+	}
+
+	_, err := newSocketPermissions("0660", "lxe-no-such-user")
+	assert.True(t, errors.Is(err, ErrInvalidSocketOwner))
+
+	_, err = newSocketPermissions("0660", ":lxe-no-such-group")
+	assert.True(t, errors.Is(err, ErrInvalidSocketOwner))
+}
+
+func Test_socketPermissions_apply(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-sock")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "lxe.sock")
+	err = ioutil.WriteFile(file, nil, 0666) // nolint: gosec
+	assert.NoError(t, err)
+
+	p, err := newSocketPermissions("0640", "")
+	assert.NoError(t, err)
+
+	err = p.apply(file)
+	assert.NoError(t, err)
+
+	info, err := os.Stat(file)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}
+
+func Test_newTCPListener_Incomplete(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []*Config{
+		{LXETCPBindAddr: ":0"},
+		{LXETCPBindAddr: ":0", LXETCPTLSCert: "cert", LXETCPTLSKey: "key"},
+		{LXETCPBindAddr: ":0", LXETCPTLSCert: "cert", LXETCPTLSClientCA: "ca"},
+	} {
+		_, err := newTCPListener(c)
+		assert.True(t, errors.Is(err, ErrTCPTLSIncomplete))
+	}
+}
+
+func Test_newGRPCServer(t *testing.T) {
+	t.Parallel()
+
+	s, err := newGRPCServer(&Config{})
+	assert.NoError(t, err)
+	assert.NotNil(t, s)
+
+	s, err = newGRPCServer(&Config{
+		LXEGRPCMaxConcurrentStreams: 10,
+		LXEGRPCKeepaliveMinTime:     1,
+		LXEGRPCKeepaliveTime:        1,
+		LXEGRPCMaxConcurrentCalls:   4,
+		LXEGRPCMethodConcurrency:    []string{"ListContainers=2"},
+		LXEGRPCRateLimit:            50,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, s)
+
+	_, err = newGRPCServer(&Config{LXEGRPCMethodConcurrency: []string{"ListContainers"}})
+	assert.True(t, errors.Is(err, ErrInvalidMethodConcurrency))
+}
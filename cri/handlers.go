@@ -0,0 +1,139 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/automaticserver/lxe/lxf"
+)
+
+const (
+	cfgSecurityPrivileged = "security.privileged"
+	cfgSecurityNesting    = "security.nesting"
+)
+
+var (
+	ErrInvalidRuntimeHandler = errors.New("invalid runtime handler")
+	ErrUnknownRuntimeHandler = errors.New("unknown runtime handler")
+)
+
+// runtimeHandler is a preset for the pods of a runtime handler, which is selected by the runtime class of the pod
+type runtimeHandler struct {
+	// Profiles replace LXDProfiles for the containers of the pod if set
+	Profiles []string
+	// Pool is the storage pool of the root disks, has priority over LXERuntimeHandlerPools
+	Pool string
+	// Privileged runs all containers of the pod privileged
+	Privileged bool
+	// Nesting allows the containers of the pod to run containers themselves
+	Nesting bool
+	// Overhead is the resource overhead of the pods, like the overhead of the RuntimeClass
+	Overhead podOverhead
+	// Ulimits are the default kernel resource limits of the containers by resource, the pod annotations have priority
+	Ulimits map[string]string
+}
+
+// parseRuntimeHandlers parses a list of handler.option=value entries. The profiles option can be repeated, the profiles
+// are applied in that order
+func parseRuntimeHandlers(entries []string) (map[string]*runtimeHandler, error) {
+	handlers := map[string]*runtimeHandler{}
+
+	for _, e := range entries {
+		parts := strings.SplitN(e, "=", 2)
+		i := strings.LastIndex(parts[0], ".")
+
+		if len(parts) != 2 || i < 1 || parts[1] == "" {
+			return nil, fmt.Errorf("%w: entry %q must be in the form handler.option=value", ErrInvalidRuntimeHandler, e)
+		}
+
+		name, option, value := parts[0][:i], parts[0][i+1:], parts[1]
+
+		h, has := handlers[name]
+		if !has {
+			h = &runtimeHandler{}
+			handlers[name] = h
+		}
+
+		var err error
+
+		switch option {
+		case "profiles":
+			h.Profiles = append(h.Profiles, value)
+		case "pool":
+			h.Pool = value
+		case "privileged":
+			h.Privileged, err = strconv.ParseBool(value)
+		case "nesting":
+			h.Nesting, err = strconv.ParseBool(value)
+		case "overhead-cpu", "overhead-memory":
+			err = h.Overhead.set(option, value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: entry %q: %v", ErrInvalidRuntimeHandler, e, err)
+			}
+		case "ulimit-nofile", "ulimit-memlock", "ulimit-nproc":
+			err = h.setUlimit(strings.TrimPrefix(option, "ulimit-"), value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: entry %q: %v", ErrInvalidRuntimeHandler, e, err)
+			}
+		default:
+			return nil, fmt.Errorf("%w: entry %q has unknown option %q", ErrInvalidRuntimeHandler, e, option)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: entry %q must be true or false", ErrInvalidRuntimeHandler, e)
+		}
+	}
+
+	return handlers, nil
+}
+
+// runtimeHandler returns the preset of the runtime handler. Pods without runtime class have no preset. If presets are
+// configured, other runtime handlers are rejected
+func (s RuntimeServer) runtimeHandler(name string) (*runtimeHandler, error) {
+	if h, has := s.runtimeHandlers[name]; has {
+		return h, nil
+	}
+
+	if name != "" && len(s.runtimeHandlers) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownRuntimeHandler, name)
+	}
+
+	return &runtimeHandler{}, nil
+}
+
+// handlerProfiles returns the profiles of the runtime handler's containers
+func (s RuntimeServer) handlerProfiles(name string) []string {
+	if h, has := s.runtimeHandlers[name]; has && len(h.Profiles) > 0 {
+		return h.Profiles
+	}
+
+	return s.config().LXDProfiles
+}
+
+// sandboxProfiles returns the profiles of the containers of the sandbox, according to its runtime handler
+func (s RuntimeServer) sandboxProfiles(id string) ([]string, error) {
+	if len(s.runtimeHandlers) == 0 {
+		return s.config().LXDProfiles, nil
+	}
+
+	sb, err := s.lxf.GetSandbox(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.handlerProfiles(sb.RuntimeHandler), nil
+}
+
+// apply sets the security defaults of the preset in the sandbox profile. They are only ever enabled, so a pod can still
+// request what the preset doesn't enable
+func (h *runtimeHandler) apply(sb *lxf.Sandbox) {
+	if h.Privileged {
+		sb.Config[cfgSecurityPrivileged] = "true"
+	}
+
+	if h.Nesting {
+		sb.Config[cfgSecurityNesting] = "true"
+	}
+}
@@ -0,0 +1,100 @@
+package cri
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseRuntimeHandlers(t *testing.T) {
+	t.Parallel()
+
+	handlers, err := parseRuntimeHandlers([]string{
+		"nested.profiles=default",
+		"nested.profiles=nesting",
+		"nested.nesting=true",
+		"privileged.privileged=true",
+		"privileged.pool=nvme",
+		"kata.overhead-cpu=250m",
+		"kata.overhead-memory=64Mi",
+		"kata.ulimit-nofile=1024:65536",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]*runtimeHandler{
+		"nested":     {Profiles: []string{"default", "nesting"}, Nesting: true},
+		"privileged": {Pool: "nvme", Privileged: true},
+		"kata":       {Overhead: podOverhead{CPU: 250, Memory: 64 << 20}, Ulimits: map[string]string{"nofile": "1024:65536"}},
+	}, handlers)
+
+	for _, e := range []string{"nested", "nested.profiles", "nested.profiles=", ".pool=nvme", "nested=true", "nested.nesting=yes please", "nested.foo=bar", "kata.overhead-cpu=lots", "kata.ulimit-nofile=lots", "kata.ulimit-stack=1024"} {
+		_, err = parseRuntimeHandlers([]string{e})
+		assert.True(t, errors.Is(err, ErrInvalidRuntimeHandler), e)
+	}
+}
+
+func TestRuntimeServer_runtimeHandler(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+
+	h, err := s.runtimeHandler("unknown")
+	assert.NoError(t, err, "without presets every handler is accepted")
+	assert.Equal(t, &runtimeHandler{}, h)
+
+	nested := &runtimeHandler{Nesting: true}
+	s.runtimeHandlers = map[string]*runtimeHandler{"nested": nested}
+
+	h, err = s.runtimeHandler("nested")
+	assert.NoError(t, err)
+	assert.Same(t, nested, h)
+
+	h, err = s.runtimeHandler("")
+	assert.NoError(t, err)
+	assert.Equal(t, &runtimeHandler{}, h)
+
+	_, err = s.runtimeHandler("unknown")
+	assert.True(t, errors.Is(err, ErrUnknownRuntimeHandler))
+}
+
+func TestRuntimeServer_sandboxProfiles(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	s.criConfig.LXDProfiles = []string{"default"}
+
+	profiles, err := s.sandboxProfiles("pod")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"default"}, profiles)
+	assert.Equal(t, 0, fake.GetSandboxCallCount())
+
+	s.runtimeHandlers = map[string]*runtimeHandler{"nested": {Profiles: []string{"default", "nesting"}}}
+
+	sb := &lxf.Sandbox{RuntimeHandler: "nested"}
+	fake.GetSandboxReturns(sb, nil)
+
+	profiles, err = s.sandboxProfiles("pod")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"default", "nesting"}, profiles)
+
+	sb.RuntimeHandler = ""
+
+	profiles, err = s.sandboxProfiles("pod")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"default"}, profiles)
+}
+
+func Test_runtimeHandler_apply(t *testing.T) {
+	t.Parallel()
+
+	sb := &lxf.Sandbox{}
+	sb.Config = map[string]string{cfgSecurityPrivileged: "true"}
+
+	(&runtimeHandler{}).apply(sb)
+	assert.Equal(t, map[string]string{cfgSecurityPrivileged: "true"}, sb.Config, "the pod can still request more")
+
+	sb.Config = map[string]string{}
+	(&runtimeHandler{Privileged: true, Nesting: true}).apply(sb)
+	assert.Equal(t, map[string]string{cfgSecurityPrivileged: "true", cfgSecurityNesting: "true"}, sb.Config)
+}
@@ -0,0 +1,130 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+var ErrNotServing = errors.New("the CRI socket is not serving")
+
+// healthCheck is a named check of the health or readiness
+type healthCheck struct {
+	name  string
+	check func() error
+}
+
+// healthService serves the liveness on /healthz, the readiness on /readyz and optionally the profiling endpoints of
+// net/http/pprof on /debug/pprof/
+type healthService struct {
+	bindAddr string
+	pprof    bool
+	server   *http.Server
+}
+
+func newHealthService(criConfig *Config, runtime *RuntimeServer, serving func() bool) *healthService {
+	lxd := healthCheck{name: "lxd", check: runtime.lxdReachable}
+	live := []healthCheck{lxd}
+	ready := []healthCheck{
+		lxd,
+		{name: "network", check: func() error {
+			_, plugin := runtime.networks.Current()
+			return plugin.Status()
+		}},
+		{name: "socket", check: func() error {
+			if !serving() {
+				return ErrNotServing
+			}
+
+			return nil
+		}},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", healthHandler(live))
+	mux.HandleFunc("/readyz", healthHandler(ready))
+
+	if criConfig.LXEHealthPprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+
+	return &healthService{
+		bindAddr: criConfig.LXEHealthBindAddr,
+		pprof:    criConfig.LXEHealthPprof,
+		server:   &http.Server{Handler: mux},
+	}
+}
+
+// lxdReachable checks if LXD answers requests
+func (s RuntimeServer) lxdReachable() error {
+	_, _, err := s.lxf.GetServer().GetServer()
+	return err
+}
+
+// healthHandler runs all checks and lists their results like the kubernetes components do. It responds with 200 if
+// all succeeded, otherwise 503
+func healthHandler(checks []healthCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var b strings.Builder
+
+		status := http.StatusOK
+
+		for _, c := range checks {
+			err := c.check()
+			if err != nil {
+				status = http.StatusServiceUnavailable
+
+				fmt.Fprintf(&b, "[-]%s failed: %v\n", c.name, err)
+
+				continue
+			}
+
+			fmt.Fprintf(&b, "[+]%s ok\n", c.name)
+		}
+
+		if status == http.StatusOK {
+			b.WriteString("ok\n")
+		} else {
+			log.WithField("path", r.URL.Path).Debug("health check failed")
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+
+		_, err := w.Write([]byte(b.String()))
+		if err != nil {
+			log.WithError(err).Warn("unable to write health response")
+		}
+	}
+}
+
+// serve listens on the bind address and serves the health endpoints
+func (h *healthService) serve() error {
+	sock, err := net.Listen("tcp", h.bindAddr)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(logrus.Fields{"endpoint": h.bindAddr, "pprof": h.pprof}).Info("started health server")
+
+	err = h.server.Serve(sock)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// stop closes the health listener
+func (h *healthService) stop() error {
+	return h.server.Close()
+}
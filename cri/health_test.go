@@ -0,0 +1,58 @@
+package cri
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthService(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	plugin := &statusPlugin{}
+	s.networks = newNetworkPlugins("gen", plugin)
+	serving := false
+
+	h := newHealthService(&Config{}, s, func() bool { return serving })
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return rec
+	}
+
+	fakeServer.GetServerReturns(&api.Server{}, "", nil)
+
+	rec := get("/healthz")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "[+]lxd ok\nok\n", rec.Body.String())
+
+	rec = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "[+]lxd ok\n[+]network ok\n[-]socket failed: the CRI socket is not serving\n", rec.Body.String())
+
+	serving = true
+	plugin.err = errors.New("no cni config")
+
+	rec = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "[-]network failed: no cni config\n")
+
+	plugin.err = nil
+	fakeServer.GetServerReturns(nil, "", errors.New("connection refused"))
+
+	rec = get("/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "[-]lxd failed: connection refused\n", rec.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, get("/debug/pprof/").Code, "pprof is disabled")
+
+	h = newHealthService(&Config{LXEHealthPprof: true}, s, func() bool { return serving })
+	assert.Equal(t, http.StatusOK, get("/debug/pprof/").Code)
+}
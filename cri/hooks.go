@@ -0,0 +1,352 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+// The lifecycle stages of sandboxes and containers hooks can run at
+const (
+	HookSandboxPreCreate   = "sandbox-pre-create"
+	HookSandboxPostStart   = "sandbox-post-start"
+	HookSandboxPreStop     = "sandbox-pre-stop"
+	HookContainerPreCreate = "container-pre-create"
+	HookContainerPostStart = "container-post-start"
+	HookContainerPreStop   = "container-pre-stop"
+)
+
+// HookVersion is the version of the hook files and of the state passed to hooks
+const HookVersion = "1"
+
+// DefaultHookTimeout is how long a hook may run if its file doesn't set a timeout
+const DefaultHookTimeout = 10 * time.Second
+
+// maxHookOutput is how much of the output of a failed hook is kept in its error
+const maxHookOutput = 1024
+
+var (
+	ErrInvalidHook = errors.New("invalid hook")
+	ErrHookFailed  = errors.New("hook failed")
+)
+
+var hookStages = map[string]bool{
+	HookSandboxPreCreate:   true,
+	HookSandboxPostStart:   true,
+	HookSandboxPreStop:     true,
+	HookContainerPreCreate: true,
+	HookContainerPostStart: true,
+	HookContainerPreStop:   true,
+}
+
+// hookFile is a hook as defined by a JSON file in the hooks directory, similar to OCI hooks:
+//
+//	{
+//	  "version": "1",
+//	  "hook": {"path": "/usr/local/bin/setup-dev", "args": ["setup-dev", "--verbose"], "env": ["A=b"], "timeout": 5, "adjust": true},
+//	  "when": {"always": false, "annotations": {"^example\\.com/dev$": "^true$"}},
+//	  "stages": ["container-pre-create"]
+//	}
+type hookFile struct {
+	Version string `json:"version"`
+	Hook    struct {
+		// Path is the absolute path of the executable
+		Path string `json:"path"`
+		// Args are the arguments including argv[0], the path if empty
+		Args []string `json:"args"`
+		Env  []string `json:"env"`
+		// Timeout in seconds, DefaultHookTimeout if 0
+		Timeout int `json:"timeout"`
+		// Adjust reads the output of the hook as HookAdjustment, only at container-pre-create
+		Adjust bool `json:"adjust"`
+	} `json:"hook"`
+	When struct {
+		// Always runs the hook regardless of the annotations
+		Always bool `json:"always"`
+		// Annotations maps regular expressions of annotation keys to regular expressions of their values, the hook runs
+		// if one annotation matches one pair
+		Annotations map[string]string `json:"annotations"`
+	} `json:"when"`
+	Stages []string `json:"stages"`
+}
+
+// hook is a loaded hook file
+type hook struct {
+	name        string
+	path        string
+	args        []string
+	env         []string
+	timeout     time.Duration
+	adjust      bool
+	always      bool
+	annotations map[*regexp.Regexp]*regexp.Regexp
+	stages      map[string]bool
+}
+
+// HookState is passed as JSON on stdin to the hooks. The CRI configs are only known at the pre-create stages
+type HookState struct {
+	Version string `json:"version"`
+	Stage   string `json:"stage"`
+	// ID is the id of the sandbox or container, empty before it's created
+	ID        string `json:"id,omitempty"`
+	SandboxID string `json:"sandboxId,omitempty"`
+	// Pid is the pid of the init of the started container
+	Pid             int64                   `json:"pid,omitempty"`
+	Labels          map[string]string       `json:"labels,omitempty"`
+	Annotations     map[string]string       `json:"annotations,omitempty"`
+	SandboxConfig   *rtApi.PodSandboxConfig `json:"sandboxConfig,omitempty"`
+	ContainerConfig *rtApi.ContainerConfig  `json:"containerConfig,omitempty"`
+}
+
+// loadHooks reads the hook files with the extension .json in dir, ordered by their names. None if dir is empty
+func loadHooks(dir string) ([]*hook, error) {
+	if dir == "" {
+		return nil, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+
+	hooks := make([]*hook, 0, len(files))
+
+	for _, f := range files {
+		h, err := loadHook(f)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %v", ErrInvalidHook, f, err)
+		}
+
+		hooks = append(hooks, h)
+	}
+
+	return hooks, nil
+}
+
+func loadHook(file string) (*hook, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	hf := &hookFile{}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(hf)
+	if err != nil {
+		return nil, err
+	}
+
+	if hf.Version != HookVersion {
+		return nil, fmt.Errorf("unknown version %q", hf.Version)
+	}
+
+	if !filepath.IsAbs(hf.Hook.Path) {
+		return nil, fmt.Errorf("path %q must be absolute", hf.Hook.Path)
+	}
+
+	h := &hook{
+		name:        filepath.Base(file),
+		path:        hf.Hook.Path,
+		args:        hf.Hook.Args,
+		env:         hf.Hook.Env,
+		timeout:     time.Duration(hf.Hook.Timeout) * time.Second,
+		adjust:      hf.Hook.Adjust,
+		always:      hf.When.Always,
+		annotations: map[*regexp.Regexp]*regexp.Regexp{},
+		stages:      map[string]bool{},
+	}
+
+	if len(h.args) == 0 {
+		h.args = []string{h.path}
+	}
+
+	if h.timeout <= 0 {
+		h.timeout = DefaultHookTimeout
+	}
+
+	for k, v := range hf.When.Annotations {
+		kr, err := regexp.Compile(k)
+		if err != nil {
+			return nil, err
+		}
+
+		vr, err := regexp.Compile(v)
+		if err != nil {
+			return nil, err
+		}
+
+		h.annotations[kr] = vr
+	}
+
+	if !h.always && len(h.annotations) == 0 {
+		return nil, errors.New("must either run always or on annotations")
+	}
+
+	if len(hf.Stages) == 0 {
+		return nil, errors.New("no stages")
+	}
+
+	for _, st := range hf.Stages {
+		if !hookStages[st] {
+			return nil, fmt.Errorf("unknown stage %q", st)
+		}
+
+		h.stages[st] = true
+	}
+
+	if h.adjust && (len(h.stages) != 1 || !h.stages[HookContainerPreCreate]) {
+		return nil, fmt.Errorf("adjust is only supported at %s", HookContainerPreCreate)
+	}
+
+	return h, nil
+}
+
+// matches returns true if the hook runs at the stage for a sandbox or container with the annotations
+func (h *hook) matches(stage string, annotations map[string]string) bool {
+	if !h.stages[stage] {
+		return false
+	}
+
+	if h.always {
+		return true
+	}
+
+	for kr, vr := range h.annotations {
+		for k, v := range annotations {
+			if kr.MatchString(k) && vr.MatchString(v) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// run executes the hook with the state on stdin. If the hook adjusts, its adjustment is returned
+func (h *hook) run(ctx context.Context, state *HookState) (*HookAdjustment, error) {
+	in, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.path) // nolint: gosec
+	cmd.Args = h.args
+	// hooks don't inherit the environment of LXE
+	cmd.Env = append([]string{}, h.env...)
+	cmd.Stdin = bytes.NewReader(in)
+
+	// the output of adjusting hooks is the adjustment, only their stderr is kept for errors
+	var stdout, out bytes.Buffer
+
+	cmd.Stderr = &out
+	cmd.Stdout = &out
+
+	if h.adjust {
+		cmd.Stdout = &stdout
+	}
+
+	err = cmd.Run()
+	if err != nil {
+		b := out.Bytes()
+		if len(b) > maxHookOutput {
+			b = b[len(b)-maxHookOutput:]
+		}
+
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(b)))
+	}
+
+	if !h.adjust || len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return nil, nil
+	}
+
+	adj := &HookAdjustment{}
+
+	dec := json.NewDecoder(&stdout)
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(adj)
+	if err != nil {
+		return nil, fmt.Errorf("invalid adjustment: %v", err)
+	}
+
+	return adj, nil
+}
+
+// hooks returns the loaded hooks, they're replaced on reload
+func (s RuntimeServer) hooks() []*hook {
+	if s.loadedHooks == nil {
+		return nil
+	}
+
+	hooks, _ := s.loadedHooks.Load().([]*hook)
+
+	return hooks
+}
+
+// hasHooks returns true if any hook runs at the stage for the annotations, so the state only needs to be gathered then
+func (s RuntimeServer) hasHooks(stage string, annotations map[string]string) bool {
+	for _, h := range s.hooks() {
+		if h.matches(stage, annotations) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runHooks runs the hooks matching the stage and annotations of the state one after another and returns the
+// adjustments of the adjusting ones in that order. The first failing hook stops the following ones
+func (s RuntimeServer) runHooks(ctx context.Context, log *logrus.Entry, stage string, state *HookState) ([]*HookAdjustment, error) {
+	state.Version = HookVersion
+	state.Stage = stage
+
+	adjs := []*HookAdjustment{}
+
+	for _, h := range s.hooks() {
+		if !h.matches(stage, state.Annotations) {
+			continue
+		}
+
+		log.WithField("hook", h.name).WithField("stage", stage).Debug("running hook")
+
+		adj, err := h.run(ctx, state)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s at %s: %v", ErrHookFailed, h.name, stage, err)
+		}
+
+		if adj != nil {
+			adj.hook = h.name
+			adjs = append(adjs, adj)
+		}
+	}
+
+	return adjs, nil
+}
+
+// runHooksLogged runs the hooks of a stage whose failure doesn't fail the CRI call, it's only logged
+func (s RuntimeServer) runHooksLogged(ctx context.Context, log *logrus.Entry, stage string, state *HookState) {
+	_, err := s.runHooks(ctx, log, stage, state)
+	if err != nil {
+		log.WithError(err).Warn("hook failed")
+	}
+}
@@ -0,0 +1,72 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"fmt"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/sirupsen/logrus"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+// HookAdjustment is printed as JSON by adjusting container-pre-create hooks to change the container before it's
+// created, like the adjustments of NRI plugins. Resource manager plugins can so place containers or add devices:
+//
+//	{"env": {"A": "b"}, "mounts": [{"container_path": "/dev/dri", "host_path": "/dev/dri"}],
+//	 "resources": {"cpuset_cpus": "2-3"}, "config": {"limits.processes": "100"}}
+type HookAdjustment struct {
+	// Env adds or replaces environment variables
+	Env map[string]string `json:"env,omitempty"`
+	// Mounts adds or replaces mounts by their container path
+	Mounts []*rtApi.Mount `json:"mounts,omitempty"`
+	// Resources replaces the resources which are set, the cpu manager way
+	Resources *rtApi.LinuxContainerResources `json:"resources,omitempty"`
+	// Config sets LXD config keys, which must be allowed like the config annotations
+	Config map[string]string `json:"config,omitempty"`
+
+	hook string
+}
+
+// applyHookAdjustments changes the container by the adjustments in their order, so later hooks win
+func (s RuntimeServer) applyHookAdjustments(log *logrus.Entry, c *lxf.Container, adjs []*HookAdjustment) error {
+	for _, adj := range adjs {
+		err := s.applyHookAdjustment(log.WithField("hook", adj.hook), c, adj)
+		if err != nil {
+			return fmt.Errorf("adjustment of %s: %w", adj.hook, err)
+		}
+	}
+
+	return nil
+}
+
+func (s RuntimeServer) applyHookAdjustment(log *logrus.Entry, c *lxf.Container, adj *HookAdjustment) error {
+	for key, value := range adj.Env {
+		err := validateEnvKey(key)
+		if err != nil {
+			return err
+		}
+
+		c.Environment[key], err = escapeEnvValue(key, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, mnt := range adj.Mounts {
+		disk := toLXDDisk(mnt)
+		s.applyShift(disk, c.Privileged)
+		c.Devices.Upsert(disk)
+	}
+
+	if adj.Resources != nil {
+		c.Resources = mergeResources(c.Resources, toResources(adj.Resources))
+	}
+
+	err := s.applyConfig(c.Config, adj.Config)
+	if err != nil {
+		return err
+	}
+
+	log.Debug("applied hook adjustment")
+
+	return nil
+}
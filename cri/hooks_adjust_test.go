@@ -0,0 +1,95 @@
+package cri
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/device"
+	opencontainers "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeServer_applyHookAdjustments(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "hooks")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	writeHookFile(t, dir, "10-devices.json", `{"version": "1", "hook": {"path": "/bin/sh", "args": ["sh", "-c", "echo '`+
+		`{\"env\": {\"A\": \"b\"}, \"mounts\": [{\"container_path\": \"/dev/dri\", \"host_path\": \"/dev/dri\"}], `+
+		`\"resources\": {\"cpuset_cpus\": \"2-3\"}, \"config\": {\"limits.kernel.nofile\": \"100\"}}'"], "adjust": true}, `+
+		`"when": {"always": true}, "stages": ["container-pre-create"]}`)
+	writeHookFile(t, dir, "20-env.json", `{"version": "1", "hook": {"path": "/bin/sh", "args": ["sh", "-c", "echo '{\"env\": {\"A\": \"c\"}}'"], "adjust": true}, `+
+		`"when": {"always": true}, "stages": ["container-pre-create"]}`)
+	writeHookFile(t, dir, "30-silent.json", `{"version": "1", "hook": {"path": "/bin/true", "adjust": true}, `+
+		`"when": {"always": true}, "stages": ["container-pre-create"]}`)
+
+	hooks, err := loadHooks(dir)
+	assert.NoError(t, err)
+
+	s, _, _ := testRuntimeServer()
+	s.loadedHooks.Store(hooks)
+	s.criConfig.LXEConfigAllowlist = []string{"limits.kernel.*"}
+	s.criConfig.LXEConfigDenylist = DefaultConfigDenylist
+
+	log := logrus.NewEntry(logrus.New())
+
+	adjs, err := s.runHooks(context.Background(), log, HookContainerPreCreate, &HookState{})
+	assert.NoError(t, err)
+	assert.Len(t, adjs, 2)
+
+	shares := uint64(512)
+	c := &lxf.Container{
+		Environment: map[string]string{"A": "a", "B": "b"},
+		Privileged:  true,
+		Resources:   &opencontainers.LinuxResources{CPU: &opencontainers.LinuxCPU{Shares: &shares, Cpus: "0-3"}},
+	}
+	c.Config = map[string]string{}
+
+	err = s.applyHookAdjustments(log, c, adjs)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"A": "c", "B": "b"}, c.Environment)
+	assert.Equal(t, map[string]string{"limits.kernel.nofile": "100"}, c.Config)
+	assert.Equal(t, "2-3", c.Resources.CPU.Cpus)
+	assert.Equal(t, shares, *c.Resources.CPU.Shares)
+	assert.Len(t, c.Devices, 1)
+	assert.Equal(t, "/dev/dri", c.Devices[0].(*device.Disk).Path)
+
+	err = s.applyHookAdjustments(log, c, []*HookAdjustment{{Config: map[string]string{"raw.lxc": "x"}, hook: "raw.json"}})
+	assert.True(t, errors.Is(err, ErrConfigNotAllowed))
+	assert.Contains(t, err.Error(), "raw.json")
+
+	err = s.applyHookAdjustments(log, c, []*HookAdjustment{{Env: map[string]string{"A=B": "x"}}})
+	assert.True(t, errors.Is(err, ErrInvalidEnv))
+}
+
+func Test_loadHooks_Adjust(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "hooks")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	writeHookFile(t, dir, "hook.json", `{"version": "1", "hook": {"path": "/bin/true", "adjust": true}, `+
+		`"when": {"always": true}, "stages": ["container-pre-create", "container-post-start"]}`)
+
+	_, err = loadHooks(dir)
+	assert.True(t, errors.Is(err, ErrInvalidHook))
+}
+
+func Test_hook_run_InvalidAdjustment(t *testing.T) {
+	t.Parallel()
+
+	h := &hook{path: "/bin/echo", args: []string{"echo", "not json"}, timeout: DefaultHookTimeout, adjust: true}
+
+	_, err := h.run(context.Background(), &HookState{})
+	assert.Error(t, err)
+}
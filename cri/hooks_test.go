@@ -0,0 +1,152 @@
+package cri
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeHookFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+	assert.NoError(t, err)
+}
+
+func Test_loadHooks(t *testing.T) {
+	t.Parallel()
+
+	hooks, err := loadHooks("")
+	assert.NoError(t, err)
+	assert.Empty(t, hooks)
+
+	dir, err := ioutil.TempDir("", "hooks")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	writeHookFile(t, dir, "20-b.json", `{"version": "1", "hook": {"path": "/bin/true"}, "when": {"always": true}, "stages": ["sandbox-post-start"]}`)
+	writeHookFile(t, dir, "10-a.json", `{"version": "1", "hook": {"path": "/bin/true", "timeout": 3}, "when": {"annotations": {"^a$": "^b$"}}, "stages": ["container-pre-create"]}`)
+	writeHookFile(t, dir, "README", `not a hook`)
+
+	hooks, err = loadHooks(dir)
+	assert.NoError(t, err)
+	assert.Len(t, hooks, 2)
+	assert.Equal(t, "10-a.json", hooks[0].name)
+	assert.Equal(t, []string{"/bin/true"}, hooks[0].args)
+	assert.Equal(t, "3s", hooks[0].timeout.String())
+	assert.Equal(t, "20-b.json", hooks[1].name)
+	assert.Equal(t, DefaultHookTimeout, hooks[1].timeout)
+}
+
+func Test_loadHooks_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for name, content := range map[string]string{
+		"version":     `{"version": "2", "hook": {"path": "/bin/true"}, "when": {"always": true}, "stages": ["sandbox-post-start"]}`,
+		"relative":    `{"version": "1", "hook": {"path": "true"}, "when": {"always": true}, "stages": ["sandbox-post-start"]}`,
+		"stage":       `{"version": "1", "hook": {"path": "/bin/true"}, "when": {"always": true}, "stages": ["prestart"]}`,
+		"no stages":   `{"version": "1", "hook": {"path": "/bin/true"}, "when": {"always": true}}`,
+		"no when":     `{"version": "1", "hook": {"path": "/bin/true"}, "stages": ["sandbox-post-start"]}`,
+		"regexp":      `{"version": "1", "hook": {"path": "/bin/true"}, "when": {"annotations": {"(": ""}}, "stages": ["sandbox-post-start"]}`,
+		"unknown key": `{"version": "1", "hook": {"path": "/bin/true"}, "when": {"always": true}, "stages": ["sandbox-post-start"], "foo": 1}`,
+		"json":        `{`,
+	} {
+		dir, err := ioutil.TempDir("", "hooks")
+		assert.NoError(t, err)
+
+		writeHookFile(t, dir, "hook.json", content)
+
+		_, err = loadHooks(dir)
+		assert.True(t, errors.Is(err, ErrInvalidHook), name)
+
+		os.RemoveAll(dir)
+	}
+}
+
+func Test_hook_matches(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "hooks")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	writeHookFile(t, dir, "a.json", `{"version": "1", "hook": {"path": "/bin/true"}, "when": {"always": true}, "stages": ["sandbox-pre-stop"]}`)
+	writeHookFile(t, dir, "b.json", `{"version": "1", "hook": {"path": "/bin/true"}, "when": {"annotations": {"^example\\.com/": "^(yes|true)$"}}, "stages": ["container-pre-create"]}`)
+
+	hooks, err := loadHooks(dir)
+	assert.NoError(t, err)
+
+	always, onAnnotation := hooks[0], hooks[1]
+
+	assert.True(t, always.matches(HookSandboxPreStop, nil))
+	assert.False(t, always.matches(HookContainerPreStop, nil))
+
+	assert.True(t, onAnnotation.matches(HookContainerPreCreate, map[string]string{"x": "y", "example.com/dev": "true"}))
+	assert.False(t, onAnnotation.matches(HookContainerPreCreate, map[string]string{"example.com/dev": "false"}))
+	assert.False(t, onAnnotation.matches(HookContainerPreCreate, map[string]string{"other.com/dev": "true"}))
+	assert.False(t, onAnnotation.matches(HookSandboxPreCreate, map[string]string{"example.com/dev": "true"}))
+}
+
+func TestRuntimeServer_runHooks(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "hooks")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "state")
+
+	writeHookFile(t, dir, "10-write.json", `{"version": "1", "hook": {"path": "/bin/sh", "args": ["sh", "-c", "cat > `+out+`"]}, `+
+		`"when": {"always": true}, "stages": ["container-pre-create", "container-pre-stop"]}`)
+	writeHookFile(t, dir, "20-fail.json", `{"version": "1", "hook": {"path": "/bin/sh", "args": ["sh", "-c", "echo denied; exit 1"]}, `+
+		`"when": {"annotations": {"^deny$": ""}}, "stages": ["container-pre-create"]}`)
+
+	hooks, err := loadHooks(dir)
+	assert.NoError(t, err)
+
+	s, _, _ := testRuntimeServer()
+	s.loadedHooks.Store(hooks)
+
+	log := logrus.NewEntry(logrus.New())
+
+	assert.True(t, s.hasHooks(HookContainerPreCreate, nil))
+	assert.False(t, s.hasHooks(HookContainerPostStart, nil))
+
+	_, err = s.runHooks(context.Background(), log, HookContainerPreCreate, &HookState{SandboxID: "sb", Labels: map[string]string{"a": "b"}})
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+
+	state := &HookState{}
+	assert.NoError(t, json.Unmarshal(b, state))
+	assert.Equal(t, HookVersion, state.Version)
+	assert.Equal(t, HookContainerPreCreate, state.Stage)
+	assert.Equal(t, "sb", state.SandboxID)
+	assert.Equal(t, map[string]string{"a": "b"}, state.Labels)
+
+	_, err = s.runHooks(context.Background(), log, HookContainerPreCreate, &HookState{Annotations: map[string]string{"deny": "1"}})
+	assert.True(t, errors.Is(err, ErrHookFailed))
+	assert.Contains(t, err.Error(), "20-fail.json")
+	assert.Contains(t, err.Error(), "denied")
+}
+
+func Test_hook_run_Timeout(t *testing.T) {
+	t.Parallel()
+
+	h := &hook{path: "/bin/sleep", args: []string{"sleep", "5"}, timeout: 50 * time.Millisecond}
+
+	_, err := h.run(context.Background(), &HookState{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,58 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+
+	"github.com/automaticserver/lxe/lxf"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+// isHostsMount checks if the mount is the hosts file kubelet generated for the pod
+func isHostsMount(mnt *rtApi.Mount) bool {
+	return mnt.GetContainerPath() == lxf.HostsPath
+}
+
+// readHostAliases returns the host alias entries of the hosts file kubelet generated for the pod
+func readHostAliases(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	aliases := []string{}
+	found := false
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == lxf.HostAliasesHeader:
+			found = true
+		case found && line != "" && !strings.HasPrefix(line, "#"):
+			aliases = append(aliases, line)
+		}
+	}
+
+	return aliases, scanner.Err()
+}
+
+// writeHosts writes the hosts file of the pod into the stopped container. Like kubelet it contains the pod ip, the
+// hostname and the host aliases, but it's written as regular file, so it also works when kubelet doesn't know the pod
+// ip yet and can't be replaced by a symlink of the image. Pods in the host network keep kubelet's mount
+func (s RuntimeServer) writeHosts(ctx context.Context, c *lxf.Container) error {
+	sb, err := c.Sandbox()
+	if err != nil {
+		return err
+	}
+
+	if sb.NetworkConfig.Mode == lxf.NetworkHost {
+		return nil
+	}
+
+	return c.WriteFile(lxf.HostsPath, lxf.HostsFile(s.getInetAddress(ctx, sb), sb.Hostname, c.HostAliases))
+}
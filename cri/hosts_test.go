@@ -0,0 +1,50 @@
+package cri
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func Test_isHostsMount(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, isHostsMount(&rtApi.Mount{ContainerPath: "/etc/hosts", HostPath: "/var/lib/kubelet/pods/uid/etc-hosts"}))
+	assert.False(t, isHostsMount(&rtApi.Mount{ContainerPath: "/etc/hosts.d"}))
+}
+
+func Test_readHostAliases(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-hosts")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "etc-hosts")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`# Kubernetes-managed hosts file.
+127.0.0.1	localhost
+10.0.0.5	mypod
+
+# Entries added by HostAliases.
+10.1.2.3	foo.local	bar.local
+10.1.2.4	baz.local
+`), 0600))
+
+	aliases, err := readHostAliases(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.1.2.3\tfoo.local\tbar.local", "10.1.2.4\tbaz.local"}, aliases)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("127.0.0.1\tlocalhost\n"), 0600))
+
+	aliases, err = readHostAliases(path)
+	assert.NoError(t, err)
+	assert.Empty(t, aliases)
+
+	_, err = readHostAliases(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
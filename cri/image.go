@@ -1,9 +1,12 @@
 package cri // import "github.com/automaticserver/lxe/cri"
 
 import (
+	"sync"
 	"time"
 
+	"github.com/automaticserver/lxe/imagecache"
 	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/metrics"
 	"github.com/automaticserver/lxe/shared"
 	"github.com/lxc/lxd/lxc/config"
 	sharedLXD "github.com/lxc/lxd/shared"
@@ -18,6 +21,10 @@ type ImageServer struct {
 	criConfig     *Config
 	runtimeRemote string
 	lxf           lxf.Client
+	// imageCache is where pulled images are exported to, nil if they aren't
+	imageCache *imagecache.Store
+	// loops are the background tasks of the runtime, a shutdown waits for the exports to the image cache with them
+	loops *sync.WaitGroup
 }
 
 // NewImageServer returns a new ImageServer backed by LXD
@@ -27,6 +34,7 @@ func NewImageServer(s *RuntimeServer, lxf lxf.Client) (*ImageServer, error) {
 		lxdConfig: s.lxdConfig,
 		criConfig: s.criConfig,
 		lxf:       lxf,
+		loops:     s.loops,
 	}
 	// apply default image remote
 	i.runtimeRemote = i.lxdConfig.DefaultRemote
@@ -36,7 +44,7 @@ func NewImageServer(s *RuntimeServer, lxf lxf.Client) (*ImageServer, error) {
 		return nil, err
 	}
 
-	i.lxdConfig, err = config.LoadConfig(configPath)
+	i.lxdConfig, err = loadLXDConfig(i.criConfig, configPath)
 	if err != nil {
 		return nil, err
 	}
@@ -59,12 +67,10 @@ func (s ImageServer) ListImages(ctx context.Context, req *rtApi.ListImagesReques
 
 	for _, imgInfo := range imglist {
 		rspImage := &rtApi.Image{
-			Id:    imgInfo.Hash,
-			Size_: uint64(imgInfo.Size),
-			RepoDigests: []string{
-				imgInfo.Hash,
-			},
-			RepoTags: imgInfo.Aliases,
+			Id:          imgInfo.Hash,
+			Size_:       uint64(imgInfo.Size),
+			RepoDigests: repoDigests(imgInfo),
+			RepoTags:    imgInfo.Aliases,
 		}
 		response.Images = append(response.Images, rspImage)
 	}
@@ -89,17 +95,32 @@ func (s ImageServer) ImageStatus(ctx context.Context, req *rtApi.ImageStatusRequ
 	}
 
 	response := &rtApi.ImageStatusResponse{Image: &rtApi.Image{
-		Id:    img.Hash,
-		Size_: uint64(img.Size),
-		RepoDigests: []string{
-			img.Hash,
-		},
-		RepoTags: img.Aliases,
+		Id:          img.Hash,
+		Size_:       uint64(img.Size),
+		RepoDigests: repoDigests(*img),
+		RepoTags:    img.Aliases,
 	}}
 
 	return response, nil
 }
 
+// repoDigests returns the fingerprint and the canonical digest references of all tags of the image, so references by
+// digest compare equal to the pulled image
+func repoDigests(img lxf.Image) []string {
+	digests := []string{img.Hash}
+
+	for _, tag := range img.Aliases {
+		ref, err := lxf.ParseImageRef(tag)
+		if err != nil {
+			continue
+		}
+
+		digests = append(digests, ref.Name()+"@sha256:"+img.Hash)
+	}
+
+	return digests
+}
+
 // TODO
 // 1. not impl: auth
 // 1b. Authentication is provided in the pull request
@@ -108,11 +129,19 @@ func (s ImageServer) ImageStatus(ctx context.Context, req *rtApi.ImageStatusRequ
 func (s ImageServer) PullImage(ctx context.Context, req *rtApi.PullImageRequest) (*rtApi.PullImageResponse, error) {
 	log := log.WithContext(ctx).WithField("image", req.GetImage().GetImage())
 
-	hash, err := s.lxf.PullImage(req.GetImage().GetImage())
+	start := time.Now()
+	hash, err := s.lxf.PullImage(ctx, req.GetImage().GetImage())
+
+	metrics.ImagePullDuration.WithLabelValues(metrics.Result(err)).Observe(metrics.Since(start))
+
 	if err != nil {
 		return nil, AnnErr(log, err, "failed to pull image")
 	}
 
+	if s.imageCache != nil {
+		s.cacheImage(req.GetImage().GetImage(), hash)
+	}
+
 	response := &rtApi.PullImageResponse{
 		ImageRef: hash,
 	}
@@ -126,11 +155,27 @@ func (s ImageServer) PullImage(ctx context.Context, req *rtApi.PullImageRequest)
 func (s ImageServer) RemoveImage(ctx context.Context, req *rtApi.RemoveImageRequest) (*rtApi.RemoveImageResponse, error) {
 	log := log.WithContext(ctx).WithField("image", req.GetImage().GetImage())
 
-	err := s.lxf.RemoveImage(req.GetImage().GetImage())
+	// the image cache holds the image by fingerprint, which is gone after the removal
+	var img *lxf.Image
+
+	if s.imageCache != nil {
+		var err error
+
+		img, err = s.lxf.GetImage(req.GetImage().GetImage())
+		if err != nil && !shared.IsErrNotFound(err) {
+			return nil, AnnErr(log, err, "failed to remove image")
+		}
+	}
+
+	err := s.lxf.RemoveImage(ctx, req.GetImage().GetImage())
 	if err != nil {
 		return nil, AnnErr(log, err, "failed to remove image")
 	}
 
+	if img != nil {
+		s.uncacheImage(img.Hash)
+	}
+
 	return &rtApi.RemoveImageResponse{}, nil
 }
 
@@ -163,3 +208,12 @@ func (s ImageServer) ImageFsInfo(ctx context.Context, req *rtApi.ImageFsInfoRequ
 
 	return response, nil
 }
+
+// loadImagePolicy loads the image policy file, without one all images are allowed
+func loadImagePolicy(criConfig *Config) (*lxf.ImagePolicy, error) {
+	if criConfig.LXEImagePolicy == "" {
+		return nil, nil
+	}
+
+	return lxf.LoadImagePolicy(criConfig.LXEImagePolicy)
+}
@@ -2,9 +2,11 @@ package cri
 
 import (
 	"context"
+	"sync"
 	"testing"
 
 	"github.com/automaticserver/lxe/cri/crifakes"
+	"github.com/automaticserver/lxe/lxf"
 	"github.com/stretchr/testify/assert"
 	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
 )
@@ -17,7 +19,9 @@ func testImageServer() (*ImageServer, *crifakes.FakeClient) {
 	fake := &crifakes.FakeClient{}
 
 	return &ImageServer{
-		lxf: fake,
+		lxf:       fake,
+		criConfig: &Config{},
+		loops:     &sync.WaitGroup{},
 	}, fake
 }
 
@@ -34,6 +38,26 @@ func TestImageServer_PullImage(t *testing.T) {
 
 	assert.NoError(t, err)
 	assert.Equal(t, 1, fake.PullImageCallCount())
-	assert.Equal(t, "an/image", fake.PullImageArgsForCall(0))
+	_, image := fake.PullImageArgsForCall(0)
+	assert.Equal(t, "an/image", image)
 	assert.Equal(t, "something", resp.ImageRef)
 }
+
+func TestImageServer_ImageStatus_RepoDigests(t *testing.T) {
+	s, fake := testImageServer()
+
+	fake.GetImageReturns(&lxf.Image{
+		Hash:    "abc",
+		Aliases: []string{"registry/path/name:latest"},
+	}, nil)
+
+	resp, err := s.ImageStatus(ctx, &rtApi.ImageStatusRequest{
+		Image: &rtApi.ImageSpec{
+			Image: "registry:5000/path/name@sha256:abc",
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"abc", "registry/path/name@sha256:abc"}, resp.Image.RepoDigests)
+	assert.Equal(t, []string{"registry/path/name:latest"}, resp.Image.RepoTags)
+}
@@ -0,0 +1,84 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/automaticserver/lxe/imagecache"
+)
+
+var ErrImageCacheDirMissing = errors.New("the image cache can only be served with an image cache directory")
+
+// imageCacheService serves the images exported to the image cache directory as simplestreams remote
+type imageCacheService struct {
+	bindAddr string
+	server   *http.Server
+}
+
+func newImageCacheService(criConfig *Config, store *imagecache.Store) *imageCacheService {
+	return &imageCacheService{
+		bindAddr: criConfig.LXEImageCacheBindAddr,
+		server:   &http.Server{Handler: store},
+	}
+}
+
+// serve listens on the bind address and serves the image cache
+func (c *imageCacheService) serve() error {
+	sock, err := net.Listen("tcp", c.bindAddr)
+	if err != nil {
+		return err
+	}
+
+	log.WithField("endpoint", c.bindAddr).Info("started image cache server")
+
+	err = c.server.Serve(sock)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// stop closes the image cache listener
+func (c *imageCacheService) stop() error {
+	return c.server.Close()
+}
+
+// cacheImage exports the image pulled as name to the image cache in the background, if it was pulled from one of the
+// remotes whose images are exported. A failure only affects the nodes pulling from it
+func (s ImageServer) cacheImage(name, fingerprint string) {
+	log := log.WithField("fingerprint", fingerprint)
+
+	remote, err := s.lxf.ImageRemote(name)
+	if err != nil {
+		log.WithError(err).Warn("unable to add image to image cache")
+		return
+	}
+
+	if !matchesAny(s.criConfig.LXEImageCacheRemotes, remote) {
+		return
+	}
+
+	s.loops.Add(1)
+
+	go func() {
+		defer s.loops.Done()
+
+		err := s.imageCache.Add(s.lxf.GetServer(), fingerprint)
+		if err != nil {
+			log.WithError(err).Warn("unable to add image to image cache")
+			return
+		}
+
+		log.Debug("image in image cache")
+	}()
+}
+
+// uncacheImage removes the removed image from the image cache, so it isn't served anymore
+func (s ImageServer) uncacheImage(fingerprint string) {
+	err := s.imageCache.Remove(fingerprint)
+	if err != nil {
+		log.WithError(err).WithField("fingerprint", fingerprint).Warn("unable to remove image from image cache")
+	}
+}
@@ -0,0 +1,119 @@
+package cri
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/automaticserver/lxe/imagecache"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func TestImageServer_cacheImage(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-imagecache")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	s, fake := testImageServer()
+	s.imageCache, err = imagecache.New(dir)
+	assert.NoError(t, err)
+
+	fakeServer := &lxdfakes.FakeContainerServer{}
+	fake.GetServerReturns(fakeServer)
+	fakeServer.GetImageReturns(&api.Image{Fingerprint: "abc", Architecture: "x86_64"}, "", nil)
+	fakeServer.GetImageFileReturns(nil, errors.New("export failed"))
+	fake.ImageRemoteReturns("images", nil)
+	s.criConfig.LXEImageCacheRemotes = []string{"images"}
+
+	// a failed export is only logged
+	s.cacheImage("images:abc", "abc")
+	s.loops.Wait()
+	assert.False(t, s.imageCache.Has("abc"))
+
+	fakeServer.GetImageFileStub = func(fp string, req lxd.ImageFileRequest) (*lxd.ImageFileResponse, error) {
+		_, err := req.MetaFile.Write([]byte("unified"))
+		return &lxd.ImageFileResponse{MetaName: fp + ".tar.gz"}, err
+	}
+
+	s.cacheImage("images:abc", "abc")
+	s.loops.Wait()
+	assert.True(t, s.imageCache.Has("abc"))
+	assert.Equal(t, "images:abc", fake.ImageRemoteArgsForCall(0))
+
+	svc := newImageCacheService(&Config{LXEImageCacheBindAddr: "127.0.0.1:0"}, s.imageCache)
+	srv := httptest.NewServer(svc.server.Handler)
+
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/images/abc/meta.tar.gz")
+	assert.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestImageServer_cacheImage_NotAllowed(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-imagecache")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	s, fake := testImageServer()
+	s.imageCache, err = imagecache.New(dir)
+	assert.NoError(t, err)
+
+	fake.ImageRemoteReturns("registry", nil)
+	s.criConfig.LXEImageCacheRemotes = []string{"images", "ubuntu*"}
+
+	// images of remotes which aren't listed, like private registries, are never exported
+	s.cacheImage("registry/private/image", "abc")
+	s.loops.Wait()
+	assert.Equal(t, 0, fake.GetServerCallCount())
+	assert.False(t, s.imageCache.Has("abc"))
+}
+
+func TestImageServer_RemoveImage_ImageCache(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-imagecache")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	s, fake := testImageServer()
+	s.imageCache, err = imagecache.New(dir)
+	assert.NoError(t, err)
+
+	fakeServer := &lxdfakes.FakeContainerServer{}
+	fake.GetServerReturns(fakeServer)
+	fakeServer.GetImageReturns(&api.Image{Fingerprint: "abc", Architecture: "x86_64"}, "", nil)
+	fakeServer.GetImageFileStub = func(fp string, req lxd.ImageFileRequest) (*lxd.ImageFileResponse, error) {
+		_, err := req.MetaFile.Write([]byte("unified"))
+		return &lxd.ImageFileResponse{MetaName: fp + ".tar.gz"}, err
+	}
+
+	err = s.imageCache.Add(fakeServer, "abc")
+	assert.NoError(t, err)
+	assert.True(t, s.imageCache.Has("abc"))
+
+	fake.GetImageReturns(&lxf.Image{Hash: "abc"}, nil)
+
+	_, err = s.RemoveImage(ctx, &rtApi.RemoveImageRequest{Image: &rtApi.ImageSpec{Image: "images:abc"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.RemoveImageCallCount())
+	assert.False(t, s.imageCache.Has("abc"))
+}
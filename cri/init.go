@@ -0,0 +1,171 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+const (
+	// initCmdEnv contains the shell quoted command line the init shim runs
+	initCmdEnv = "LXE_INIT_CMD"
+	// initShim evaluates the command line of initCmdEnv. LXC splits lxc.init.cmd at spaces without any quoting, so
+	// arguments containing whitespace need the shell. ${IFS} separates the words as a space can't be used
+	initShim = `/bin/sh -c eval${IFS}"$` + initCmdEnv + `"`
+)
+
+var (
+	ErrInvalidCommand  = errors.New("invalid command")
+	ErrCommandRequired = errors.New("application containers require a command")
+)
+
+// toContainerInit returns the init process replacing the image's init if the container config sets a command or args.
+// Images of LXD have no entrypoint, so the args are appended to the command like docker appends the cmd to the
+// entrypoint. Returns nil if neither is set
+func toContainerInit(config *rtApi.ContainerConfig) (*lxf.ContainerInit, error) {
+	command := append(append([]string{}, config.GetCommand()...), config.GetArgs()...)
+	if len(command) == 0 {
+		return nil, nil
+	}
+
+	for _, arg := range command {
+		if strings.ContainsAny(arg, "\n\r\x00") {
+			return nil, fmt.Errorf("%w: argument %q must not contain line breaks or null characters", ErrInvalidCommand, arg)
+		}
+	}
+
+	if command[0] == "" {
+		return nil, fmt.Errorf("%w: empty command", ErrInvalidCommand)
+	}
+
+	init := &lxf.ContainerInit{
+		Command:    command,
+		WorkingDir: config.GetWorkingDir(),
+	}
+
+	sc := config.GetLinux().GetSecurityContext()
+
+	if sc.GetRunAsUser() != nil {
+		uid := sc.GetRunAsUser().GetValue()
+		init.UID = &uid
+	} else if sc.GetRunAsUsername() != "" {
+		// LXE can't look up the user in the image before the container exists
+		log.WithField("username", sc.GetRunAsUsername()).Warn("runAsUsername is not supported, running the command as root")
+	}
+
+	if sc.GetRunAsGroup() != nil {
+		gid := sc.GetRunAsGroup().GetValue()
+		init.GID = &gid
+	}
+
+	return init, nil
+}
+
+// needsInitShim returns true if LXC can't run the command by itself: LXC splits at spaces and doesn't search the PATH
+func needsInitShim(command []string) bool {
+	if !path.IsAbs(command[0]) {
+		return true
+	}
+
+	for _, arg := range command {
+		if arg == "" || strings.ContainsAny(arg, " \t") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// shellQuote quotes the arguments for the shell, single quotes are the only character which needs escaping
+func shellQuote(command []string) string {
+	quoted := make([]string, 0, len(command))
+	for _, arg := range command {
+		quoted = append(quoted, "'"+strings.ReplaceAll(arg, "'", `'\''`)+"'")
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// containerMode returns the mode of the pod's containers, the pod annotation has priority over --container-mode
+func (s RuntimeServer) containerMode(annotations map[string]string) (string, error) {
+	if raw, has := annotation.ContainerMode.Get(annotations); has {
+		return annotation.ParseContainerMode(raw)
+	}
+
+	if mode := s.config().LXEContainerMode; mode != "" {
+		return mode, nil
+	}
+
+	return annotation.ContainerModeSystem, nil
+}
+
+// applicationShim returns the script which runs the command of an application container. The shell stays pid 1 to
+// forward the termination signal LXD sends on stop (lxc.signal.halt) and to record the exit code of the command in
+// lxf.ExitCodeFile, as LXD doesn't report it
+func applicationShim(command []string) string {
+	return "rm -f " + lxf.ExitCodeFile + "; " +
+		"trap 'kill -TERM $p 2>/dev/null' TERM INT; " +
+		shellQuote(command) + " & p=$!; " +
+		// wait returns early when the trap runs, so wait again till the command is gone. The last wait returns the exit
+		// code the shell kept for the reaped command
+		"while kill -0 $p 2>/dev/null; do wait $p; done; wait $p; c=$?; " +
+		"{ echo $c >" + lxf.ExitCodeFile + "; } 2>/dev/null; exit $c"
+}
+
+// applyInitEnv sets the command line for the init shim if it's needed
+func applyInitEnv(c *lxf.Container) {
+	if c.Init == nil {
+		return
+	}
+
+	if c.Init.Application {
+		c.Environment[initCmdEnv] = applicationShim(c.Init.Command)
+		return
+	}
+
+	if !needsInitShim(c.Init.Command) {
+		return
+	}
+
+	// exec replaces the shell, so the command becomes pid 1 and receives the stop signal
+	c.Environment[initCmdEnv] = "exec " + shellQuote(c.Init.Command)
+}
+
+// initRawLXC returns the raw.lxc entries to start the init process of the container instead of the image's init
+func initRawLXC(init *lxf.ContainerInit) []string {
+	if init == nil {
+		return nil
+	}
+
+	cmd := strings.Join(init.Command, " ")
+	if init.Application || needsInitShim(init.Command) {
+		cmd = initShim
+	}
+
+	entries := []string{"lxc.init.cmd = " + cmd}
+
+	if init.Application {
+		// LXD stops containers with SIGPWR, which applications don't expect
+		entries = append(entries, "lxc.signal.halt = SIGTERM")
+	}
+
+	if init.WorkingDir != "" {
+		entries = append(entries, "lxc.init.cwd = "+init.WorkingDir)
+	}
+
+	if init.UID != nil {
+		entries = append(entries, "lxc.init.uid = "+strconv.FormatInt(*init.UID, 10))
+	}
+
+	if init.GID != nil {
+		entries = append(entries, "lxc.init.gid = "+strconv.FormatInt(*init.GID, 10))
+	}
+
+	return entries
+}
@@ -0,0 +1,160 @@
+package cri
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/stretchr/testify/assert"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func Test_toContainerInit(t *testing.T) {
+	t.Parallel()
+
+	init, err := toContainerInit(&rtApi.ContainerConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, init)
+
+	init, err = toContainerInit(&rtApi.ContainerConfig{
+		Command:    []string{"/usr/bin/nginx"},
+		Args:       []string{"-g", "daemon off;"},
+		WorkingDir: "/srv",
+		Linux: &rtApi.LinuxContainerConfig{SecurityContext: &rtApi.LinuxContainerSecurityContext{
+			RunAsUser:  &rtApi.Int64Value{Value: 33},
+			RunAsGroup: &rtApi.Int64Value{Value: 34},
+		}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/usr/bin/nginx", "-g", "daemon off;"}, init.Command)
+	assert.Equal(t, "/srv", init.WorkingDir)
+	assert.Equal(t, int64(33), *init.UID)
+	assert.Equal(t, int64(34), *init.GID)
+
+	init, err = toContainerInit(&rtApi.ContainerConfig{Args: []string{"/bin/sleep", "infinity"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/bin/sleep", "infinity"}, init.Command, "args without command are the command")
+	assert.Nil(t, init.UID)
+
+	_, err = toContainerInit(&rtApi.ContainerConfig{Command: []string{"/bin/echo", "a\nb"}})
+	assert.True(t, errors.Is(err, ErrInvalidCommand))
+
+	_, err = toContainerInit(&rtApi.ContainerConfig{Command: []string{""}})
+	assert.True(t, errors.Is(err, ErrInvalidCommand))
+}
+
+func Test_needsInitShim(t *testing.T) {
+	t.Parallel()
+
+	assert.False(t, needsInitShim([]string{"/bin/sleep", "infinity"}))
+	assert.True(t, needsInitShim([]string{"sleep", "infinity"}))
+	assert.True(t, needsInitShim([]string{"/bin/sh", "-c", "echo hi"}))
+	assert.True(t, needsInitShim([]string{"/bin/echo", ""}))
+}
+
+func Test_shellQuote(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, `'/bin/sh' '-c' 'echo '\''hi'\'' $HOME'`, shellQuote([]string{"/bin/sh", "-c", "echo 'hi' $HOME"}))
+}
+
+func Test_applyInitEnv(t *testing.T) {
+	t.Parallel()
+
+	c := &lxf.Container{}
+	c.Environment = map[string]string{}
+
+	applyInitEnv(c)
+	assert.Empty(t, c.Environment)
+
+	c.Init = &lxf.ContainerInit{Command: []string{"/bin/sleep", "infinity"}}
+	applyInitEnv(c)
+	assert.Empty(t, c.Environment, "lxc runs it without shim")
+
+	c.Init = &lxf.ContainerInit{Command: []string{"nginx", "-g", "daemon off;"}}
+	applyInitEnv(c)
+	assert.Equal(t, `exec 'nginx' '-g' 'daemon off;'`, c.Environment[initCmdEnv])
+}
+
+func Test_initRawLXC(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, initRawLXC(nil))
+
+	var uid int64 = 1000
+
+	assert.Equal(t, []string{
+		"lxc.init.cmd = /bin/sleep infinity",
+		"lxc.init.cwd = /srv",
+		"lxc.init.uid = 1000",
+	}, initRawLXC(&lxf.ContainerInit{Command: []string{"/bin/sleep", "infinity"}, WorkingDir: "/srv", UID: &uid}))
+
+	assert.Equal(t, []string{
+		`lxc.init.cmd = /bin/sh -c eval${IFS}"$LXE_INIT_CMD"`,
+	}, initRawLXC(&lxf.ContainerInit{Command: []string{"sleep", "infinity"}}))
+}
+
+func TestRuntimeServer_containerMode(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+
+	mode, err := s.containerMode(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, annotation.ContainerModeSystem, mode)
+
+	s.criConfig.LXEContainerMode = annotation.ContainerModeApplication
+
+	mode, err = s.containerMode(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, annotation.ContainerModeApplication, mode)
+
+	mode, err = s.containerMode(map[string]string{annotation.ContainerMode.Name: "system"})
+	assert.NoError(t, err)
+	assert.Equal(t, annotation.ContainerModeSystem, mode)
+
+	_, err = s.containerMode(map[string]string{annotation.ContainerMode.Name: "oci"})
+	assert.True(t, errors.Is(err, annotation.ErrInvalidContainerMode))
+}
+
+func Test_applyInitEnv_Application(t *testing.T) {
+	t.Parallel()
+
+	c := &lxf.Container{}
+	c.Environment = map[string]string{}
+	c.Init = &lxf.ContainerInit{Command: []string{"/bin/sleep", "infinity"}, Application: true}
+
+	applyInitEnv(c)
+	assert.Equal(t, applicationShim(c.Init.Command), c.Environment[initCmdEnv], "the shim is always used")
+	assert.Contains(t, c.Environment[initCmdEnv], `'/bin/sleep' 'infinity' & p=$!`)
+	assert.Contains(t, c.Environment[initCmdEnv], ">"+lxf.ExitCodeFile)
+
+	assert.Equal(t, []string{
+		`lxc.init.cmd = /bin/sh -c eval${IFS}"$LXE_INIT_CMD"`,
+		"lxc.signal.halt = SIGTERM",
+	}, initRawLXC(c.Init))
+}
+
+func Test_toCriStatusResponse_ExitCode(t *testing.T) {
+	t.Parallel()
+
+	c := &lxf.Container{}
+	c.StateName = lxf.ContainerStateRunning
+	c.ExitCode = 3
+
+	assert.Equal(t, int32(0), toCriStatusResponse(c).Status.ExitCode, "only reported once exited")
+
+	c.StateName = lxf.ContainerStateExited
+	assert.Equal(t, int32(3), toCriStatusResponse(c).Status.ExitCode)
+	assert.Equal(t, "Error", toCriStatusResponse(c).Status.Reason)
+
+	c.ExitCode = 0
+	c.RestartCount = 2
+	resp := toCriStatusResponse(c)
+	assert.Equal(t, "Completed", resp.Status.Reason)
+	assert.Equal(t, "2", resp.Info["restarts"])
+
+	c.EvictionReason = "Evicted"
+	assert.Equal(t, "Evicted", toCriStatusResponse(c).Status.Reason)
+}
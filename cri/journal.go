@@ -0,0 +1,513 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/lxo"
+	"github.com/automaticserver/lxe/shared"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+// The CRI calls which are journaled, they change pods or containers
+const (
+	journalRunPod          = "RunPodSandbox"
+	journalStopPod         = "StopPodSandbox"
+	journalRemovePod       = "RemovePodSandbox"
+	journalCreateContainer = "CreateContainer"
+	journalStartContainer  = "StartContainer"
+	journalStopContainer   = "StopContainer"
+	journalRemoveContainer = "RemoveContainer"
+)
+
+// journalCompactSize is the size in bytes above which the journal is truncated as soon as no call is in progress
+const journalCompactSize = 1 << 20
+
+// journalPollTimeout is how long LXD is asked to block for an operation of an interrupted call, it has to stay below
+// the timeout of the http client
+const journalPollTimeout = 5
+
+// journalWaitTimeout is how long the operations of an interrupted call are waited for, so an operation hanging in LXD
+// doesn't block the start. The call is then tried again at the next start
+const journalWaitTimeout = 2 * time.Minute
+
+var ErrUnknownJournalCall = errors.New("unknown journaled call")
+
+// journalRecord is a line of the journal. The first record of a call has the call, the following ones add its object
+// or LXD operations as they become known and the last one ends it
+type journalRecord struct {
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	Call string    `json:"call,omitempty"`
+	// Object is the id of the pod or container the call changes
+	Object string `json:"object,omitempty"`
+	// Pod is the metadata of the pod being created, it has no id before it's created
+	Pod *lxf.SandboxMetadata `json:"pod,omitempty"`
+	// Timeout is the timeout of a stop in seconds
+	Timeout   int64  `json:"timeout,omitempty"`
+	Operation string `json:"operation,omitempty"`
+	End       bool   `json:"end,omitempty"`
+}
+
+// journalEntry is a call in progress with everything known about it
+type journalEntry struct {
+	Seq        uint64
+	Time       time.Time
+	Call       string
+	Object     string
+	Pod        *lxf.SandboxMetadata
+	Timeout    int64
+	Operations []string
+}
+
+// add merges the record of the call into the entry
+func (e *journalEntry) add(r *journalRecord) {
+	if r.Object != "" {
+		e.Object = r.Object
+	}
+
+	if r.Operation != "" {
+		e.Operations = append(e.Operations, r.Operation)
+	}
+}
+
+// record returns the entry as the first record of its call
+func (e *journalEntry) record() *journalRecord {
+	return &journalRecord{Seq: e.Seq, Time: e.Time, Call: e.Call, Object: e.Object, Pod: e.Pod, Timeout: e.Timeout}
+}
+
+// journal is a write-ahead log of the CRI calls changing pods and containers, written as JSON lines. Every record is
+// synced before the call continues, so the calls interrupted by a crash are known at the next start
+type journal struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	size    int64
+	seq     uint64
+	pending map[uint64]*journalEntry
+	// interrupted are the calls which were in progress when the journal was opened
+	interrupted []*journalEntry
+}
+
+// openJournal reads the calls left in progress from the journal at path and opens it for appending. The journal is
+// compacted to these calls
+func openJournal(path string) (*journal, error) {
+	j := &journal{path: path, pending: map[uint64]*journalEntry{}}
+
+	err := j.replay()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range j.pending {
+		j.interrupted = append(j.interrupted, e)
+	}
+
+	sort.Slice(j.interrupted, func(a, b int) bool { return j.interrupted[a].Seq < j.interrupted[b].Seq })
+
+	err = j.compact()
+	if err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// replay reads the records of the journal into the pending calls. A truncated last line, written while crashing, is
+// ignored
+func (j *journal) replay() error {
+	f, err := os.Open(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		r := &journalRecord{}
+
+		err = json.Unmarshal(scanner.Bytes(), r)
+		if err != nil {
+			log.WithError(err).WithField("path", j.path).Warn("skipping corrupt journal record")
+			continue
+		}
+
+		if r.Seq > j.seq {
+			j.seq = r.Seq
+		}
+
+		e, has := j.pending[r.Seq]
+
+		switch {
+		case r.End:
+			delete(j.pending, r.Seq)
+		case !has && r.Call != "":
+			j.pending[r.Seq] = &journalEntry{
+				Seq: r.Seq, Time: r.Time, Call: r.Call, Object: r.Object, Pod: r.Pod, Timeout: r.Timeout,
+			}
+		case has:
+			e.add(r)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// compact rewrites the journal with only the pending calls, j.mu must be held if it's in use. It's written to a
+// temporary file first, so an interrupted compaction keeps the previous journal
+func (j *journal) compact() error {
+	err := os.MkdirAll(filepath.Dir(j.path), 0700) // nolint: gomnd
+	if err != nil {
+		return err
+	}
+
+	tmp := j.path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600) // nolint: gomnd
+	if err != nil {
+		return err
+	}
+
+	size := int64(0)
+
+	for _, e := range j.pending {
+		records := []*journalRecord{e.record()}
+		for _, op := range e.Operations {
+			records = append(records, &journalRecord{Seq: e.Seq, Time: e.Time, Operation: op})
+		}
+
+		for _, r := range records {
+			n, err := writeRecord(f, r)
+			if err != nil {
+				f.Close()
+				return err
+			}
+
+			size += int64(n)
+		}
+	}
+
+	err = f.Sync()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = os.Rename(tmp, j.path)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	if j.f != nil {
+		j.f.Close()
+	}
+
+	j.f = f
+	j.size = size
+
+	return nil
+}
+
+func writeRecord(f *os.File, r *journalRecord) (int, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return 0, err
+	}
+
+	return f.Write(append(b, '\n'))
+}
+
+// write appends the record and syncs it to disk, j.mu must be held
+func (j *journal) write(r *journalRecord) error {
+	n, err := writeRecord(j.f, r)
+	if err != nil {
+		return err
+	}
+
+	j.size += int64(n)
+
+	return j.f.Sync()
+}
+
+// begin journals the call before it changes anything
+func (j *journal) begin(e *journalEntry) (*journalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.seq++
+	e.Seq = j.seq
+	e.Time = time.Now().UTC()
+	j.pending[e.Seq] = e
+
+	return e, j.write(e.record())
+}
+
+// add journals the object or an LXD operation of the call
+func (j *journal) add(seq uint64, r *journalRecord) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	e, has := j.pending[seq]
+	if !has {
+		return nil
+	}
+
+	r.Seq = seq
+	r.Time = time.Now().UTC()
+	e.add(r)
+
+	return j.write(r)
+}
+
+// end journals that the call returned. Once no call is in progress anymore, a large journal is truncated
+func (j *journal) end(seq uint64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, has := j.pending[seq]; !has {
+		return nil
+	}
+
+	delete(j.pending, seq)
+
+	err := j.write(&journalRecord{Seq: seq, Time: time.Now().UTC(), End: true})
+	if err != nil {
+		return err
+	}
+
+	if len(j.pending) == 0 && j.size > journalCompactSize {
+		return j.compact()
+	}
+
+	return nil
+}
+
+func (j *journal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.f.Close()
+}
+
+// journalCall is a journaled call in progress, nil if the journal is disabled
+type journalCall struct {
+	j   *journal
+	seq uint64
+}
+
+// journalBegin journals the call before it changes anything. The LXD operations run with the returned context are
+// journaled with it, so they can be waited for after a crash
+func (s RuntimeServer) journalBegin(ctx context.Context, e *journalEntry) (context.Context, *journalCall) {
+	if s.journal == nil {
+		return ctx, nil
+	}
+
+	e, err := s.journal.begin(e)
+	if err != nil {
+		log.WithError(err).WithField("call", e.Call).Error("unable to write journal")
+	}
+
+	call := &journalCall{j: s.journal, seq: e.Seq}
+
+	return lxo.WithOperationObserver(ctx, call.operation), call
+}
+
+// setObject journals the id of the pod or container once it's created
+func (c *journalCall) setObject(id string) {
+	if c == nil {
+		return
+	}
+
+	err := c.j.add(c.seq, &journalRecord{Object: id})
+	if err != nil {
+		log.WithError(err).WithField("object", id).Error("unable to write journal")
+	}
+}
+
+// operation journals the LXD operation of the call
+func (c *journalCall) operation(id string) {
+	if c == nil {
+		return
+	}
+
+	err := c.j.add(c.seq, &journalRecord{Operation: id})
+	if err != nil {
+		log.WithError(err).WithField("operation", id).Error("unable to write journal")
+	}
+}
+
+// end journals that the call returned, successful or not. kubelet knows the outcome then
+func (c *journalCall) end() {
+	if c == nil {
+		return
+	}
+
+	err := c.j.end(c.seq)
+	if err != nil {
+		log.WithError(err).Error("unable to write journal")
+	}
+}
+
+// recoverJournal resolves the calls interrupted by a crash, before the CRI is served. Their LXD operations still
+// running are waited for first, at most journalWaitTimeout. Calls creating a pod or container are rolled back, as
+// kubelet never got the id and creates them again. Stopping and removing is done again, as it's idempotent. A start is
+// done once its operation is. Calls which can't be resolved stay in the journal and are tried again at the next start
+func (s RuntimeServer) recoverJournal(ctx context.Context) {
+	if s.journal == nil {
+		return
+	}
+
+	for _, e := range s.journal.interrupted {
+		log := log.WithField("call", e.Call).WithField("object", e.Object).WithField("started", e.Time)
+		log.Warn("resolving call interrupted by a crash")
+
+		wctx, cancel := context.WithTimeout(ctx, journalWaitTimeout)
+		err := s.waitOperations(wctx, e.Operations)
+
+		cancel()
+
+		if err == nil {
+			err = s.resolveCall(ctx, e)
+		}
+
+		if err != nil {
+			log.WithError(err).Error("unable to resolve interrupted call, trying again at the next start")
+			continue
+		}
+
+		err = s.journal.end(e.Seq)
+		if err != nil {
+			log.WithError(err).Error("unable to write journal")
+		}
+
+		log.Info("resolved interrupted call")
+	}
+}
+
+// waitOperations waits till the LXD operations are done. Operations LXD doesn't know anymore are done, either long ago
+// or LXD restarted too
+func (s RuntimeServer) waitOperations(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		for {
+			op, _, err := s.lxf.GetServer().GetOperationWait(id, journalPollTimeout)
+			if err != nil {
+				if shared.IsErrNotFound(err) {
+					break
+				}
+
+				return fmt.Errorf("operation %s: %w", id, err)
+			}
+
+			if op.StatusCode.IsFinal() {
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveCall rolls the interrupted call back or does it again
+func (s RuntimeServer) resolveCall(ctx context.Context, e *journalEntry) error {
+	switch e.Call {
+	case journalRunPod:
+		ids, err := s.interruptedSandboxes(e)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			_, err = s.RemovePodSandbox(ctx, &rtApi.RemovePodSandboxRequest{PodSandboxId: id})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case journalStopPod:
+		_, err := s.StopPodSandbox(ctx, &rtApi.StopPodSandboxRequest{PodSandboxId: e.Object})
+		return err
+	case journalRemovePod:
+		_, err := s.RemovePodSandbox(ctx, &rtApi.RemovePodSandboxRequest{PodSandboxId: e.Object})
+		return err
+	case journalStartContainer:
+		return nil
+	case journalCreateContainer, journalStopContainer, journalRemoveContainer:
+		return s.resolveContainerCall(ctx, e)
+	}
+
+	return fmt.Errorf("%w: %s", ErrUnknownJournalCall, e.Call)
+}
+
+// resolveContainerCall rolls the interrupted creation of a container back or stops or removes it again
+func (s RuntimeServer) resolveContainerCall(ctx context.Context, e *journalEntry) error {
+	c, err := s.lxf.GetContainer(e.Object)
+	if err != nil {
+		if shared.IsErrNotFound(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	switch e.Call {
+	case journalCreateContainer:
+		// a started container was found by a previous creation and isn't the one of this call
+		if !c.StartedAt.IsZero() {
+			return nil
+		}
+
+		return s.deleteContainer(ctx, c)
+	case journalStopContainer:
+		return s.stopContainer(ctx, c, int(e.Timeout))
+	default:
+		return s.deleteContainer(ctx, c)
+	}
+}
+
+// interruptedSandboxes returns the ids of the sandboxes an interrupted RunPodSandbox created. The id is only journaled
+// once it's created, otherwise it's the sandbox of the pod created since the call began
+func (s RuntimeServer) interruptedSandboxes(e *journalEntry) ([]string, error) {
+	if e.Object != "" {
+		return []string{e.Object}, nil
+	}
+
+	if e.Pod == nil {
+		return nil, nil
+	}
+
+	sbs, err := s.lxf.ListSandboxes()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+
+	for _, sb := range sbs {
+		if sb.Metadata == *e.Pod && !sb.CreatedAt.Before(e.Time) {
+			ids = append(ids, sb.ID)
+		}
+	}
+
+	return ids, nil
+}
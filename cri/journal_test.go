@@ -0,0 +1,106 @@
+package cri
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournal_Interrupted(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "journal")
+
+	j, err := openJournal(path)
+	assert.NoError(t, err)
+	assert.Empty(t, j.interrupted)
+
+	done, err := j.begin(&journalEntry{Call: journalStopContainer, Object: "a"})
+	assert.NoError(t, err)
+
+	running, err := j.begin(&journalEntry{Call: journalRunPod, Pod: &lxf.SandboxMetadata{Name: "pod"}})
+	assert.NoError(t, err)
+
+	call := &journalCall{j: j, seq: running.Seq}
+	call.setObject("pod-1")
+	call.operation("op1")
+
+	assert.NoError(t, j.end(done.Seq))
+	assert.NoError(t, j.close())
+
+	// a record cut off by the crash
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"seq":3,"call":"Remo`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	j, err = openJournal(path)
+	assert.NoError(t, err)
+
+	assert.Len(t, j.interrupted, 1)
+	assert.Equal(t, journalRunPod, j.interrupted[0].Call)
+	assert.Equal(t, "pod-1", j.interrupted[0].Object)
+	assert.Equal(t, "pod", j.interrupted[0].Pod.Name)
+	assert.Equal(t, []string{"op1"}, j.interrupted[0].Operations)
+
+	// the journal is compacted to the interrupted call, new calls continue its sequence
+	next, err := j.begin(&journalEntry{Call: journalRemoveContainer, Object: "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), next.Seq)
+
+	assert.NoError(t, j.end(next.Seq))
+	assert.NoError(t, j.end(running.Seq))
+	assert.NoError(t, j.close())
+
+	j, err = openJournal(path)
+	assert.NoError(t, err)
+	assert.Empty(t, j.interrupted)
+
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Empty(t, b)
+}
+
+func TestJournal_Disabled(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+
+	jctx, call := s.journalBegin(ctx, &journalEntry{Call: journalStartContainer, Object: "a"})
+	assert.Equal(t, ctx, jctx)
+	assert.Nil(t, call)
+
+	// a disabled journal ignores the call
+	call.setObject("a")
+	call.operation("op1")
+	call.end()
+	s.recoverJournal(ctx)
+}
+
+func TestRuntimeServer_waitOperations_Timeout(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	fakeServer.GetOperationWaitReturns(&api.Operation{StatusCode: api.Running}, "", nil)
+
+	wctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	// an operation hanging in LXD doesn't block the recovery
+	err := s.waitOperations(wctx, []string{"op1"})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	fakeServer.GetOperationWaitReturns(&api.Operation{StatusCode: api.Success}, "", nil)
+
+	err = s.waitOperations(ctx, []string{"op1"})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,47 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/automaticserver/lxe/annotation"
+)
+
+var (
+	ErrConfigNotAllowed = errors.New("lxd config key not allowed")
+
+	// DefaultConfigDenylist contains the LXD config keys which can't be set by annotations even if allowed, as they
+	// would break out of the container, bypass other checks or overwrite the state LXE keeps
+	DefaultConfigDenylist = []string{
+		"linux.kernel_modules",
+		"linux.sysctl.*",
+		"raw.*",
+		"security.idmap.*",
+		"security.privileged",
+		"user.*",
+		"volatile.*",
+	}
+)
+
+// applyConfigAnnotations sets the LXD config keys of the config annotations. Every key must match the allowlist and
+// must not match the denylist
+func (s RuntimeServer) applyConfigAnnotations(config map[string]string, annotations map[string]string) error {
+	return s.applyConfig(config, annotation.Config.GetAll(annotations))
+}
+
+// applyConfig sets the LXD config keys if all of them are allowed
+func (s RuntimeServer) applyConfig(config map[string]string, values map[string]string) error {
+	conf := s.config()
+
+	for key := range values {
+		if !matchesAny(conf.LXEConfigAllowlist, key) || matchesAny(conf.LXEConfigDenylist, key) {
+			return fmt.Errorf("%w: %s", ErrConfigNotAllowed, key)
+		}
+	}
+
+	for key, value := range values {
+		config[key] = value
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package cri
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeServer_applyConfigAnnotations(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+	s.criConfig.LXEConfigAllowlist = []string{"security.nesting", "limits.kernel.*"}
+	s.criConfig.LXEConfigDenylist = DefaultConfigDenylist
+
+	config := map[string]string{"limits.memory": "1GB"}
+
+	err := s.applyConfigAnnotations(config, map[string]string{
+		annotation.Config.Name + "security.nesting":     "true",
+		annotation.Config.Name + "limits.kernel.nofile": "65536",
+		annotation.VLAN.Name:                            "10",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"limits.memory":        "1GB",
+		"security.nesting":     "true",
+		"limits.kernel.nofile": "65536",
+	}, config)
+}
+
+func TestRuntimeServer_applyConfigAnnotations_NotAllowed(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+	s.criConfig.LXEConfigAllowlist = []string{"security.*", "raw.*"}
+	s.criConfig.LXEConfigDenylist = DefaultConfigDenylist
+
+	for _, key := range []string{"limits.cpu", "security.privileged", "raw.lxc"} {
+		config := map[string]string{}
+
+		err := s.applyConfigAnnotations(config, map[string]string{annotation.Config.Name + key: "x"})
+		assert.True(t, errors.Is(err, ErrConfigNotAllowed), key)
+		assert.Empty(t, config, "nothing is set if a key is rejected")
+	}
+}
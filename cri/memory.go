@@ -0,0 +1,54 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"strconv"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+)
+
+const (
+	cfgLimitMemorySwap    = "limits.memory.swap"
+	cfgLimitMemoryEnforce = "limits.memory.enforce"
+)
+
+// validateMemoryConfig checks the daemon-wide memory enforcement options, empty ones keep the LXD defaults
+func validateMemoryConfig(criConfig *Config) error {
+	if criConfig.LXEMemorySwap != "" {
+		_, err := annotation.ParseBool(criConfig.LXEMemorySwap)
+		if err != nil {
+			return err
+		}
+	}
+
+	if criConfig.LXEMemoryEnforce != "" {
+		_, err := annotation.ParseMemoryEnforce(criConfig.LXEMemoryEnforce)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyMemoryEnforcement sets how the memory limits of the pod's containers are enforced in the sandbox profile. The
+// pod annotations have priority over the daemon-wide options
+func (s RuntimeServer) applyMemoryEnforcement(sb *lxf.Sandbox, annotations map[string]string) {
+	swap, has := annotation.MemorySwap.Get(annotations)
+	if !has {
+		swap = s.config().LXEMemorySwap
+	}
+
+	enforce, has := annotation.MemoryEnforce.Get(annotations)
+	if !has {
+		enforce = s.config().LXEMemoryEnforce
+	}
+
+	if swap != "" {
+		// already validated, LXD only accepts the canonical form
+		b, _ := annotation.ParseBool(swap)
+		sb.Config[cfgLimitMemorySwap] = strconv.FormatBool(b)
+	}
+
+	lxf.SetIfSet(&sb.Config, cfgLimitMemoryEnforce, enforce)
+}
@@ -0,0 +1,37 @@
+package cri
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_validateMemoryConfig(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, validateMemoryConfig(&Config{}))
+	assert.NoError(t, validateMemoryConfig(&Config{LXEMemorySwap: "false", LXEMemoryEnforce: "soft"}))
+	assert.True(t, errors.Is(validateMemoryConfig(&Config{LXEMemorySwap: "no"}), annotation.ErrInvalidBool))
+	assert.True(t, errors.Is(validateMemoryConfig(&Config{LXEMemoryEnforce: "strict"}), annotation.ErrInvalidEnforce))
+}
+
+func TestRuntimeServer_applyMemoryEnforcement(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+
+	sb := &lxf.Sandbox{}
+	sb.Config = map[string]string{}
+
+	s.applyMemoryEnforcement(sb, nil)
+	assert.Empty(t, sb.Config, "the LXD defaults are kept")
+
+	s.criConfig.LXEMemorySwap = "false"
+	s.criConfig.LXEMemoryEnforce = "hard"
+
+	s.applyMemoryEnforcement(sb, map[string]string{annotation.MemorySwap.Name: "TRUE"})
+	assert.Equal(t, map[string]string{cfgLimitMemorySwap: "true", cfgLimitMemoryEnforce: "hard"}, sb.Config)
+}
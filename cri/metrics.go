@@ -0,0 +1,159 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/metrics"
+	"github.com/automaticserver/lxe/network"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+var (
+	ErrMetricsTLSIncomplete = errors.New("both metrics tls certificate and key are required")
+
+	sandboxesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, "", "sandboxes"),
+		"Number of pod sandboxes by state.",
+		[]string{"state"}, nil,
+	)
+	containersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, "", "containers"),
+		"Number of containers by state.",
+		[]string{"state"}, nil,
+	)
+	ipPoolExhaustedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, "cni", "ip_pool_exhausted_total"),
+		"Number of pod network setups which failed due to an exhausted ip pool.",
+		nil, nil,
+	)
+)
+
+// callMetrics observes the latency and errors of the CRI calls
+func callMetrics(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	method := path.Base(info.FullMethod)
+	start := time.Now()
+
+	resp, err := handler(ctx, req)
+
+	metrics.CRIRequestDuration.WithLabelValues(method).Observe(metrics.Since(start))
+
+	if err != nil {
+		metrics.CRIRequestErrors.WithLabelValues(method).Inc()
+	}
+
+	return resp, err
+}
+
+// stateCollector collects the number of sandboxes and containers by state when the metrics are scraped
+type stateCollector struct {
+	lxf lxf.Client
+}
+
+// Describe implements prometheus.Collector
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sandboxesDesc
+	ch <- containersDesc
+	ch <- ipPoolExhaustedDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(ipPoolExhaustedDesc, prometheus.CounterValue, float64(network.IPPoolExhaustedTotal()))
+
+	sbs, err := c.lxf.ListSandboxes()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(sandboxesDesc, err)
+	} else {
+		counts := map[string]int{lxf.SandboxReady.String(): 0, lxf.SandboxNotReady.String(): 0}
+		for _, sb := range sbs {
+			counts[sb.State.String()]++
+		}
+
+		for state, count := range counts {
+			ch <- prometheus.MustNewConstMetric(sandboxesDesc, prometheus.GaugeValue, float64(count), state)
+		}
+	}
+
+	cl, err := c.lxf.ListContainers()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(containersDesc, err)
+	} else {
+		counts := map[string]int{}
+		for _, state := range []lxf.ContainerStateName{lxf.ContainerStateCreated, lxf.ContainerStateRunning, lxf.ContainerStateExited, lxf.ContainerStateUnknown} {
+			counts[string(state)] = 0
+		}
+
+		for _, c := range cl {
+			counts[string(c.StateName)]++
+		}
+
+		for state, count := range counts {
+			ch <- prometheus.MustNewConstMetric(containersDesc, prometheus.GaugeValue, float64(count), state)
+		}
+	}
+}
+
+// metricsService serves the prometheus metrics on /metrics
+type metricsService struct {
+	bindAddr string
+	tlsCert  string
+	tlsKey   string
+	server   *http.Server
+}
+
+func newMetricsService(criConfig *Config, client lxf.Client) (*metricsService, error) {
+	if (criConfig.LXEMetricsTLSCert == "") != (criConfig.LXEMetricsTLSKey == "") {
+		return nil, ErrMetricsTLSIncomplete
+	}
+
+	err := metrics.Registry.Register(&stateCollector{lxf: client})
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+
+	return &metricsService{
+		bindAddr: criConfig.LXEMetricsBindAddr,
+		tlsCert:  criConfig.LXEMetricsTLSCert,
+		tlsKey:   criConfig.LXEMetricsTLSKey,
+		server:   &http.Server{Handler: mux},
+	}, nil
+}
+
+// serve listens on the bind address and serves the metrics, with tls if a certificate is configured
+func (m *metricsService) serve() error {
+	sock, err := net.Listen("tcp", m.bindAddr)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(logrus.Fields{"endpoint": m.bindAddr, "tls": m.tlsCert != ""}).Info("started metrics server")
+
+	if m.tlsCert != "" {
+		err = m.server.ServeTLS(sock, m.tlsCert, m.tlsKey)
+	} else {
+		err = m.server.Serve(sock)
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// stop closes the metrics listener
+func (m *metricsService) stop() error {
+	return m.server.Close()
+}
@@ -0,0 +1,60 @@
+package cri
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func Test_callMetrics(t *testing.T) {
+	t.Parallel()
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/runtime.v1alpha2.RuntimeService/TestMetricsCall"}
+	failing := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.New("failed")
+	}
+
+	before := testutil.ToFloat64(metrics.CRIRequestErrors.WithLabelValues("TestMetricsCall"))
+
+	_, err := callMetrics(ctx, nil, info, failing)
+	assert.Error(t, err)
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.CRIRequestErrors.WithLabelValues("TestMetricsCall")))
+}
+
+func Test_stateCollector(t *testing.T) {
+	t.Parallel()
+
+	_, fake, _ := testRuntimeServer()
+	fake.ListSandboxesReturns([]*lxf.Sandbox{{State: lxf.SandboxReady}, {State: lxf.SandboxReady}}, nil)
+	fake.ListContainersReturns([]*lxf.Container{{StateName: lxf.ContainerStateRunning}, {StateName: lxf.ContainerStateExited}}, nil)
+
+	exp := `
+# HELP lxe_containers Number of containers by state.
+# TYPE lxe_containers gauge
+lxe_containers{state="created"} 0
+lxe_containers{state="exited"} 1
+lxe_containers{state="running"} 1
+lxe_containers{state="unknown"} 0
+# HELP lxe_sandboxes Number of pod sandboxes by state.
+# TYPE lxe_sandboxes gauge
+lxe_sandboxes{state="notready"} 0
+lxe_sandboxes{state="ready"} 2
+`
+
+	err := testutil.CollectAndCompare(&stateCollector{lxf: fake}, strings.NewReader(exp), "lxe_containers", "lxe_sandboxes")
+	assert.NoError(t, err)
+}
+
+func Test_newMetricsService_TLSIncomplete(t *testing.T) {
+	t.Parallel()
+
+	_, err := newMetricsService(&Config{LXEMetricsBindAddr: ":9100", LXEMetricsTLSCert: "cert.pem"}, nil)
+	assert.True(t, errors.Is(err, ErrMetricsTLSIncomplete))
+}
@@ -0,0 +1,135 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/lxo"
+	"github.com/automaticserver/lxe/network"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrNotClustered = errors.New("LXD is not clustered")
+	ErrCNIPod       = errors.New("pods in the CNI network can't be migrated")
+)
+
+// NewAdminRuntimeServer connects to LXD and initializes the network plugin like NewServer, but doesn't serve the CRI.
+// It is meant for administrative commands operating on the pods of a running LXE with the same configuration
+func NewAdminRuntimeServer(criConfig *Config) (*RuntimeServer, error) {
+	configPath, err := getLXDConfigPath(criConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	project, err := lxdProject(criConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := lxf.NewClient(criConfig.LXDSocket, configPath, lxo.Conf{
+		Workers:      criConfig.LXDOperationWorkers,
+		Timeout:      criConfig.LXDOperationTimeout,
+		Retries:      criConfig.LXDOperationRetries,
+		RetryBackoff: criConfig.LXDOperationRetryBackoff,
+	}, criConfig.LXEOwner, project)
+	if err != nil {
+		return nil, err
+	}
+
+	client.SetNamePrefix(criConfig.LXENamePrefix)
+
+	netPlugin, err := initNetworkPlugin(criConfig, client, newCNIOutputFiles())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRuntimeServer(criConfig, client, netPlugin)
+}
+
+// MigratePod moves all containers of the pod to the LXD cluster member target. Running containers are migrated live.
+// Their network is torn down before and set up again on the target, so the network plugin can attach the interface
+// on the new member. Pods in the CNI network are rejected
+func (s RuntimeServer) MigratePod(ctx context.Context, podID, target string) error {
+	if !s.lxf.GetServer().IsClustered() {
+		return ErrNotClustered
+	}
+
+	sb, err := s.lxf.GetSandbox(podID)
+	if err != nil {
+		return err
+	}
+
+	// the CNI plugins set up the interface and their state on the host of the container, which can't be moved along.
+	// The same applies to the pinned network namespace of a pod
+	if sb.NetworkConfig.Mode == lxf.NetworkCNI {
+		return ErrCNIPod
+	}
+
+	cl, err := s.lxf.ListContainers()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range cl {
+		if c.SandboxID() != sb.ID {
+			continue
+		}
+
+		err = s.migrateContainer(ctx, sb, c, target)
+		if err != nil {
+			return fmt.Errorf("unable to migrate container %s: %w", c.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// migrateContainer moves the container to the LXD cluster member target and reattaches its network
+func (s RuntimeServer) migrateContainer(ctx context.Context, sb *lxf.Sandbox, c *lxf.Container, target string) error {
+	log := log.WithContext(ctx).WithFields(logrus.Fields{
+		"containerid": c.ID,
+		"from":        c.Location,
+		"to":          target,
+	})
+
+	if c.Location == target {
+		log.Info("container is already located on target")
+		return nil
+	}
+
+	running := c.StateName == lxf.ContainerStateRunning
+
+	if running && sb.NetworkConfig.Mode != lxf.NetworkHost {
+		podNet, err := s.podNetwork(sb)
+		if err != nil {
+			return fmt.Errorf("can't enter pod network context: %w", err)
+		}
+
+		contNet, err := podNet.ContainerNetwork(c.ID, c.Annotations)
+		if err != nil {
+			return fmt.Errorf("can't enter container network context: %w", err)
+		}
+
+		err = contNet.WhenStopped(ctx, &network.Properties{Data: sb.NetworkConfig.ModeData})
+		if err != nil {
+			log.WithError(err).Warn("unable to tear down container network on source")
+		}
+	}
+
+	err := c.Migrate(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	log.Info("migrated container")
+
+	if !running {
+		return nil
+	}
+
+	// the live migrated container doesn't emit a start event, so set up the network on the target explicitly
+	return s.startContainerNetwork(ctx, c)
+}
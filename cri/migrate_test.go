@@ -0,0 +1,54 @@
+package cri
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeServer_MigratePod_NotClustered(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+
+	err := s.MigratePod(context.Background(), "sb", "member1")
+	assert.True(t, errors.Is(err, ErrNotClustered))
+	assert.Equal(t, 0, fake.GetSandboxCallCount())
+}
+
+func TestRuntimeServer_MigratePod_AlreadyOnTarget(t *testing.T) {
+	t.Parallel()
+
+	s, fake, fakeServer := testRuntimeServer()
+	fakeServer.IsClusteredReturns(true)
+
+	sb := &lxf.Sandbox{}
+	sb.ID = "sb"
+	fake.GetSandboxReturns(sb, nil)
+	fake.ListContainersReturns([]*lxf.Container{
+		{Profiles: []string{"sb"}, Location: "member1", StateName: lxf.ContainerStateRunning},
+	}, nil)
+
+	err := s.MigratePod(context.Background(), "sb", "member1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, fakeServer.UseTargetCallCount())
+}
+
+func TestRuntimeServer_MigratePod_CNI(t *testing.T) {
+	t.Parallel()
+
+	s, fake, fakeServer := testRuntimeServer()
+	fakeServer.IsClusteredReturns(true)
+
+	sb := &lxf.Sandbox{}
+	sb.ID = "sb"
+	sb.NetworkConfig.Mode = lxf.NetworkCNI
+	fake.GetSandboxReturns(sb, nil)
+
+	err := s.MigratePod(context.Background(), "sb", "member1")
+	assert.True(t, errors.Is(err, ErrCNIPod))
+	assert.Equal(t, 0, fake.ListContainersCallCount())
+}
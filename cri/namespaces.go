@@ -0,0 +1,211 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/network"
+	"github.com/automaticserver/lxe/network/netns"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+const (
+	// lxcNamespaceShare is the raw.lxc key prefix to join a namespace of another container or process on start
+	lxcNamespaceShare = "lxc.namespace.share."
+	// namespacePID and namespaceIPC are the namespaces which can be shared between containers. The network is shared
+	// through the sandbox and this CRI version has no option for the uts namespace, the hostname is set per pod anyway
+	namespacePID = "pid"
+	namespaceIPC = "ipc"
+	// namespaceUser is joined together with the shared namespaces of unprivileged containers, as a namespace can only be
+	// joined from the user namespace owning it
+	namespaceUser = "user"
+	// podNetnsTimeout is how long a starting container waits for the network namespace of its pod
+	podNetnsTimeout = 5 * time.Second
+)
+
+// netnsPath returns the directory the network namespaces of the pods are pinned in. With an owner it's a directory of
+// its own, so LXE instances sharing a host never take the namespaces of the others as stale
+func (c *Config) netnsPath() string {
+	path := c.CNINetnsPath
+	if path == "" {
+		path = network.DefaultCNInetnsPath
+	}
+
+	if c.LXEOwner == "" {
+		return path
+	}
+
+	return filepath.Join(path, c.LXEOwner)
+}
+
+// toNamespaces returns which namespaces the container shares according to the CRI namespace options
+func toNamespaces(nso *rtApi.NamespaceOption) map[string]string {
+	if nso == nil {
+		return nil
+	}
+
+	namespaces := map[string]string{}
+
+	for ns, mode := range map[string]rtApi.NamespaceMode{namespacePID: nso.GetPid(), namespaceIPC: nso.GetIpc()} {
+		switch mode {
+		case rtApi.NamespaceMode_POD:
+			namespaces[ns] = lxf.NamespacePod
+		case rtApi.NamespaceMode_NODE:
+			namespaces[ns] = lxf.NamespaceNode
+		case rtApi.NamespaceMode_CONTAINER:
+			// the container keeps its own namespace
+		}
+	}
+
+	return namespaces
+}
+
+// podAnchor returns the ID of the container whose namespace the containers of the pod share: the running container
+// which started first, as all later started ones joined it. Returns empty if there's none, then c becomes the anchor
+func podAnchor(c *lxf.Container, others []*lxf.Container, ns string) string {
+	var anchor *lxf.Container
+
+	for _, o := range others {
+		if o.ID == c.ID || o.StateName != lxf.ContainerStateRunning || o.Namespaces[ns] != lxf.NamespacePod {
+			continue
+		}
+
+		if anchor == nil || o.StartedAt.Before(anchor.StartedAt) {
+			anchor = o
+		}
+	}
+
+	if anchor == nil {
+		return ""
+	}
+
+	return anchor.ID
+}
+
+// namespaceRawLXC returns the raw.lxc entries to join the shared namespaces of the container, others are the containers
+// of its pod
+func namespaceRawLXC(c *lxf.Container, others []*lxf.Container) []string {
+	entries := []string{}
+	userns := ""
+
+	for _, ns := range []string{namespacePID, namespaceIPC} {
+		with := c.Namespaces[ns]
+
+		switch with {
+		case "":
+			continue
+		case lxf.NamespaceNode:
+			if !c.Privileged {
+				log.WithField("containerid", c.ID).WithField("namespace", ns).
+					Warn("only privileged containers can share the namespace of the node, keeping its own")
+				continue
+			}
+
+			entries = append(entries, lxcNamespaceShare+ns+" = /proc/1/ns/"+ns)
+
+			continue
+		case lxf.NamespacePod:
+			with = podAnchor(c, others, ns)
+			if with == "" {
+				continue
+			}
+		}
+
+		if !c.Privileged {
+			if userns != "" && userns != with {
+				log.WithField("containerid", c.ID).WithField("namespace", ns).
+					Warn("unprivileged containers can only share namespaces of one container, keeping its own")
+				continue
+			}
+
+			userns = with
+		}
+
+		entries = append(entries, lxcNamespaceShare+ns+" = "+with)
+	}
+
+	if userns != "" {
+		entries = append(entries, lxcNamespaceShare+namespaceUser+" = "+userns)
+	}
+
+	return entries
+}
+
+// isNamespaceShare checks if the raw.lxc line shares one of the namespaces managed by applyNamespaces
+func isNamespaceShare(line string) bool {
+	for _, ns := range []string{namespacePID, namespaceIPC, namespaceUser} {
+		if strings.HasPrefix(line, lxcNamespaceShare+ns+" ") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyNamespaces renders the shared namespaces of the container into its raw.lxc right before it starts, as the
+// container to join in the pod depends on which ones are running. Nothing is done unless --namespace-sharing is set
+func (s RuntimeServer) applyNamespaces(ctx context.Context, c *lxf.Container) error {
+	if !s.criConfig.LXENamespaceSharing || len(c.Namespaces) == 0 {
+		return nil
+	}
+
+	sb, err := c.Sandbox()
+	if err != nil {
+		return err
+	}
+
+	others, err := sb.Containers()
+	if err != nil {
+		return err
+	}
+
+	// the raw.lxc of the container replaces the one of the sandbox profile, so start with that if it has none yet
+	current, has := c.Config[cfgRawLXC]
+	if !has {
+		current = sb.Config[cfgRawLXC]
+	}
+
+	lines := []string{}
+
+	for _, line := range strings.Split(current, "\n") {
+		if line != "" && !isNamespaceShare(line) {
+			lines = append(lines, line)
+		}
+	}
+
+	entries := namespaceRawLXC(c, others)
+	if !has && len(entries) == 0 {
+		return nil
+	}
+
+	raw := strings.Join(append(lines, entries...), "\n")
+	if has && raw == current {
+		return nil
+	}
+
+	c.Config[cfgRawLXC] = raw
+
+	return c.Apply(ctx)
+}
+
+// waitPodNetns waits till the network namespace of the pod the container joins is pinned, so the container doesn't
+// start in a missing namespace. Containers of pods without one don't wait
+func waitPodNetns(ctx context.Context, c *lxf.Container) error {
+	sb, err := c.Sandbox()
+	if err != nil {
+		return err
+	}
+
+	path := network.PodNetns(sb.NetworkConfig.ModeData)
+	if path == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, podNetnsTimeout)
+	defer cancel()
+
+	return netns.WaitReady(ctx, path)
+}
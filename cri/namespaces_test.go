@@ -0,0 +1,98 @@
+package cri
+
+import (
+	"testing"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/stretchr/testify/assert"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func Test_toNamespaces(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, toNamespaces(nil))
+	assert.Equal(t, map[string]string{"ipc": lxf.NamespacePod}, toNamespaces(&rtApi.NamespaceOption{
+		Pid: rtApi.NamespaceMode_CONTAINER,
+		Ipc: rtApi.NamespaceMode_POD,
+	}))
+	assert.Equal(t, map[string]string{"pid": lxf.NamespaceNode, "ipc": lxf.NamespacePod}, toNamespaces(&rtApi.NamespaceOption{
+		Pid: rtApi.NamespaceMode_NODE,
+	}))
+}
+
+func testNamespaceContainer(id string, running bool, started time.Time, namespaces map[string]string) *lxf.Container {
+	c := &lxf.Container{}
+	c.ID = id
+	c.StartedAt = started
+	c.Namespaces = namespaces
+	c.StateName = lxf.ContainerStateExited
+
+	if running {
+		c.StateName = lxf.ContainerStateRunning
+	}
+
+	return c
+}
+
+func Test_podAnchor(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	pod := map[string]string{"pid": lxf.NamespacePod}
+
+	c := testNamespaceContainer("c", false, time.Time{}, pod)
+	first := testNamespaceContainer("first", true, now, pod)
+	second := testNamespaceContainer("second", true, now.Add(time.Minute), pod)
+	exited := testNamespaceContainer("exited", false, now.Add(-time.Minute), pod)
+	own := testNamespaceContainer("own", true, now.Add(-time.Hour), nil)
+
+	assert.Equal(t, "first", podAnchor(c, []*lxf.Container{c, second, exited, own, first}, "pid"))
+	assert.Equal(t, "", podAnchor(c, []*lxf.Container{c, exited, own}, "pid"))
+	assert.Equal(t, "", podAnchor(c, []*lxf.Container{first}, "ipc"))
+}
+
+func Test_namespaceRawLXC(t *testing.T) {
+	t.Parallel()
+
+	anchor := testNamespaceContainer("anchor", true, time.Now(), map[string]string{"pid": lxf.NamespacePod, "ipc": lxf.NamespacePod})
+
+	c := testNamespaceContainer("c", false, time.Time{}, map[string]string{"pid": lxf.NamespacePod, "ipc": lxf.NamespacePod})
+	assert.Equal(t, []string{
+		"lxc.namespace.share.pid = anchor",
+		"lxc.namespace.share.ipc = anchor",
+		"lxc.namespace.share.user = anchor",
+	}, namespaceRawLXC(c, []*lxf.Container{anchor, c}))
+
+	c.Privileged = true
+	assert.Equal(t, []string{
+		"lxc.namespace.share.pid = anchor",
+		"lxc.namespace.share.ipc = anchor",
+	}, namespaceRawLXC(c, []*lxf.Container{anchor, c}))
+
+	// the first container of the pod is the anchor itself
+	assert.Empty(t, namespaceRawLXC(c, []*lxf.Container{c}))
+
+	c.Namespaces = map[string]string{"pid": lxf.NamespaceNode}
+	assert.Equal(t, []string{"lxc.namespace.share.pid = /proc/1/ns/pid"}, namespaceRawLXC(c, nil))
+
+	c.Privileged = false
+	assert.Empty(t, namespaceRawLXC(c, nil))
+
+	// unprivileged containers can only join the user namespace of one container
+	c.Namespaces = map[string]string{"pid": "target", "ipc": lxf.NamespacePod}
+	assert.Equal(t, []string{
+		"lxc.namespace.share.pid = target",
+		"lxc.namespace.share.user = target",
+	}, namespaceRawLXC(c, []*lxf.Container{anchor}))
+}
+
+func Test_isNamespaceShare(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, isNamespaceShare("lxc.namespace.share.pid = anchor"))
+	assert.True(t, isNamespaceShare("lxc.namespace.share.user = anchor"))
+	assert.False(t, isNamespaceShare("lxc.namespace.share.net = /run/netns/sb"), "the pod network namespace is kept")
+	assert.False(t, isNamespaceShare("lxc.proc.oom_score_adj = 1000"))
+}
@@ -0,0 +1,154 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/automaticserver/lxe/lxf"
+)
+
+const (
+	// netDevFields is the number of counters of an interface in /proc/net/dev, receive and transmit have 8 each
+	netDevFields = 16
+	// loopbackInterface is left out of the statistics
+	loopbackInterface = "lo"
+)
+
+var ErrInvalidNetDev = errors.New("invalid net dev statistics")
+
+// interfaceStats are the counters of a network interface
+type interfaceStats struct {
+	RxBytes   uint64 `json:"rx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	RxErrors  uint64 `json:"rx_errors"`
+	RxDropped uint64 `json:"rx_dropped"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	TxPackets uint64 `json:"tx_packets"`
+	TxErrors  uint64 `json:"tx_errors"`
+	TxDropped uint64 `json:"tx_dropped"`
+}
+
+// parseNetDev parses the interface counters in the format of /proc/net/dev, the loopback interface is left out
+func parseNetDev(r io.Reader) (map[string]interfaceStats, error) {
+	stats := map[string]interfaceStats{}
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		// the two header lines have no colon
+		if len(parts) != 2 {
+			continue
+		}
+
+		name := strings.TrimSpace(parts[0])
+		if name == loopbackInterface {
+			continue
+		}
+
+		fields := strings.Fields(parts[1])
+		if len(fields) != netDevFields {
+			return nil, fmt.Errorf("%w: interface %s has %d fields", ErrInvalidNetDev, name, len(fields))
+		}
+
+		values := make([]uint64, netDevFields)
+
+		for i, f := range fields {
+			v, err := strconv.ParseUint(f, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: interface %s: %v", ErrInvalidNetDev, name, err)
+			}
+
+			values[i] = v
+		}
+
+		stats[name] = interfaceStats{
+			RxBytes:   values[0],
+			RxPackets: values[1],
+			RxErrors:  values[2],
+			RxDropped: values[3],
+			TxBytes:   values[8],
+			TxPackets: values[9],
+			TxErrors:  values[10],
+			TxDropped: values[11],
+		}
+	}
+
+	return stats, scanner.Err()
+}
+
+// readNetDev returns the interface counters of the network namespace of the process
+func readNetDev(proc string, pid int64) (map[string]interfaceStats, error) {
+	f, err := os.Open(filepath.Join(proc, strconv.FormatInt(pid, 10), "net", "dev"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseNetDev(f)
+}
+
+// podNetworkStats returns the interface counters of the running containers of the pod by container ID. Containers
+// sharing a network namespace, like with a pod network namespace, are only reported once. Pods in the host network
+// have none
+func (s RuntimeServer) podNetworkStats(sb *lxf.Sandbox) (map[string]map[string]interfaceStats, error) {
+	if sb.NetworkConfig.Mode == lxf.NetworkHost {
+		return nil, nil
+	}
+
+	cl, err := sb.Containers()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[string]map[string]interfaceStats{}
+	seen := map[string]bool{}
+
+	for _, c := range cl {
+		if c.StateName != lxf.ContainerStateRunning {
+			continue
+		}
+
+		st, err := c.State()
+		if err != nil {
+			return nil, err
+		}
+
+		netns, err := os.Readlink(filepath.Join(procRoot, strconv.FormatInt(st.Pid, 10), "ns", "net"))
+		if err != nil || seen[netns] {
+			continue
+		}
+
+		seen[netns] = true
+
+		ifaces, err := readNetDev(procRoot, st.Pid)
+		if err != nil {
+			return nil, err
+		}
+
+		stats[c.ID] = ifaces
+	}
+
+	return stats, nil
+}
+
+// podNetworkInfo returns the verbose info of the pod with its interface counters as json
+func (s RuntimeServer) podNetworkInfo(sb *lxf.Sandbox) (map[string]string, error) {
+	stats, err := s.podNetworkStats(sb)
+	if err != nil || len(stats) == 0 {
+		return nil, err
+	}
+
+	b, err := json.Marshal(stats)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{"network": string(b)}, nil
+}
@@ -0,0 +1,79 @@
+package cri
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/stretchr/testify/assert"
+)
+
+const testNetDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:    1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0
+  eth0: 5242880    4096    1    2    0     0          0         3  1048576    2048    4    5    0     0       0          0
+`
+
+func Test_parseNetDev(t *testing.T) {
+	t.Parallel()
+
+	stats, err := parseNetDev(strings.NewReader(testNetDev))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interfaceStats{
+		"eth0": {
+			RxBytes:   5242880,
+			RxPackets: 4096,
+			RxErrors:  1,
+			RxDropped: 2,
+			TxBytes:   1048576,
+			TxPackets: 2048,
+			TxErrors:  4,
+			TxDropped: 5,
+		},
+	}, stats)
+
+	_, err = parseNetDev(strings.NewReader("  eth0: 1 2 3\n"))
+	assert.True(t, errors.Is(err, ErrInvalidNetDev))
+
+	_, err = parseNetDev(strings.NewReader("  eth0: 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 x\n"))
+	assert.True(t, errors.Is(err, ErrInvalidNetDev))
+}
+
+func Test_readNetDev(t *testing.T) {
+	t.Parallel()
+
+	proc, err := ioutil.TempDir("", "proc")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(proc)
+
+	err = os.MkdirAll(filepath.Join(proc, "42", "net"), 0755)
+	assert.NoError(t, err)
+
+	err = ioutil.WriteFile(filepath.Join(proc, "42", "net", "dev"), []byte(testNetDev), 0600)
+	assert.NoError(t, err)
+
+	stats, err := readNetDev(proc, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5242880), stats["eth0"].RxBytes)
+
+	_, err = readNetDev(proc, 43)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRuntimeServer_podNetworkInfo_HostNetwork(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+
+	sb := &lxf.Sandbox{}
+	sb.NetworkConfig.Mode = lxf.NetworkHost
+
+	info, err := s.podNetworkInfo(sb)
+	assert.NoError(t, err)
+	assert.Nil(t, info)
+}
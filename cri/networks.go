@@ -0,0 +1,269 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/network"
+)
+
+var (
+	ErrNetworkPluginChange = errors.New("can't change the network plugin at runtime")
+)
+
+// networkPlugins tracks the network plugin instances by the generation of their configuration. New pods use the
+// current generation, while existing pods keep the generation saved in their sandbox until they are deleted. This
+// allows to cut over to a new configuration without breaking the teardown of existing pods
+type networkPlugins struct {
+	mu      sync.RWMutex
+	current string
+	plugins map[string]network.Plugin
+}
+
+// newNetworkPlugins creates the tracking with the plugin as current generation
+func newNetworkPlugins(generation string, plugin network.Plugin) *networkPlugins {
+	return &networkPlugins{
+		current: generation,
+		plugins: map[string]network.Plugin{generation: plugin},
+	}
+}
+
+// Current returns the current generation and its plugin
+func (n *networkPlugins) Current() (string, network.Plugin) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.current, n.plugins[n.current]
+}
+
+// Get returns the plugin of the generation. If the generation is unknown, e.g. the pod was created before LXE was
+// restarted with another configuration, the current plugin is returned
+func (n *networkPlugins) Get(generation string) network.Plugin {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if p, has := n.plugins[generation]; has {
+		return p
+	}
+
+	return n.plugins[n.current]
+}
+
+// Add adds the plugin and makes its generation the current one
+func (n *networkPlugins) Add(generation string, plugin network.Plugin) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.plugins[generation] = plugin
+	n.current = generation
+}
+
+// Prune removes and closes all generations which are neither current nor in use
+func (n *networkPlugins) Prune(inUse map[string]bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for g, p := range n.plugins {
+		if g != n.current && !inUse[g] {
+			delete(n.plugins, g)
+
+			err := p.Close()
+			if err != nil {
+				log.WithError(err).WithField("generation", g).Warn("unable to close network plugin")
+			}
+		}
+	}
+}
+
+// Generations returns all tracked generations
+func (n *networkPlugins) Generations() []string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	gens := make([]string, 0, len(n.plugins))
+	for g := range n.plugins {
+		gens = append(gens, g)
+	}
+
+	return gens
+}
+
+// cniOutputFiles are the opened cni output files by path, so a reload reuses the file instead of opening it again. A
+// file whose path was changed stays open, as the pods set up with the former generation still write to it
+type cniOutputFiles struct {
+	mu    sync.Mutex
+	files map[string]*os.File
+}
+
+func newCNIOutputFiles() *cniOutputFiles {
+	return &cniOutputFiles{files: map[string]*os.File{}}
+}
+
+// open returns the file of the path, it's only opened the first time
+func (o *cniOutputFiles) open(path string) (*os.File, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if f, has := o.files[path]; has {
+		return f, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0660)
+	if err != nil {
+		return nil, err
+	}
+
+	o.files[path] = f
+
+	return f, nil
+}
+
+// close closes all opened files
+func (o *cniOutputFiles) close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var err error
+
+	for path, f := range o.files {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+
+		delete(o.files, path)
+	}
+
+	return err
+}
+
+// networkGeneration derives the generation from all config options relevant to the network plugin
+func networkGeneration(c *Config) string {
+	h := sha256.New()
+	fmt.Fprintln(h, c.LXENetworkPlugin, c.LXENetworkMTU, c.LXENetworkDisableTxChecksum)
+
+	switch c.LXENetworkPlugin {
+	case NetworkPluginCNI:
+		fmt.Fprintln(h, c.CNIConfDir, c.CNIBinDir, c.CNINetnsPath, c.CNIOutputTarget, c.CNIOutputFile)
+
+		// only added if set, so the generation of existing configs stays the same
+		if c.CNIPodNetns {
+			fmt.Fprintln(h, "podnetns")
+		}
+
+		if c.CNIPodCIDRBridge != "" {
+			fmt.Fprintln(h, "podcidrbridge", c.CNIPodCIDRBridge)
+		}
+
+		if c.CNIDefaultConf {
+			fmt.Fprintln(h, "defaultconf")
+		}
+
+		if !c.CNIWatchConfDir {
+			fmt.Fprintln(h, "nowatch")
+		}
+	case NetworkPluginBridge:
+		fmt.Fprintln(h, c.LXEBridgeName, c.LXEBridgeDHCPRange, strings.Join(c.LXEBridgeVLANs, ","), c.LXEBridgeDNSDomain)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// podNetwork enters the pod network context using the plugin generation the sandbox was set up with
+func (s RuntimeServer) podNetwork(sb *lxf.Sandbox) (network.PodNetwork, error) {
+	return s.networks.Get(sb.NetworkConfig.Generation).PodNetwork(sb.ID, sb.Annotations)
+}
+
+// ReloadNetwork initializes the network plugin with the new configuration and uses it for new pods. Existing pods
+// keep using the plugin they were set up with. Changing the type of the network plugin is not supported
+func (s *Server) ReloadNetwork(criConfig *Config) error {
+	if criConfig.LXENetworkPlugin != s.criConfig.LXENetworkPlugin {
+		return fmt.Errorf("%w: from %s to %s", ErrNetworkPluginChange, s.criConfig.LXENetworkPlugin, criConfig.LXENetworkPlugin)
+	}
+
+	generation := networkGeneration(criConfig)
+	if current, _ := s.networks.Current(); current == generation {
+		log.WithField("generation", generation).Info("network configuration unchanged")
+		return nil
+	}
+
+	plugin, err := initNetworkPlugin(criConfig, s.client, s.cniOutputs)
+	if err != nil {
+		return err
+	}
+
+	// only keep generations which are still used by existing pods
+	sbs, err := s.client.ListSandboxes()
+	if err != nil {
+		return err
+	}
+
+	inUse := map[string]bool{}
+	for _, sb := range sbs {
+		inUse[sb.NetworkConfig.Generation] = true
+	}
+
+	s.networks.Add(generation, plugin)
+	s.networks.Prune(inUse)
+
+	log.WithField("generation", generation).WithField("generations", len(s.networks.Generations())).Info("network configuration reloaded")
+
+	return nil
+}
+
+// networkPodIDs returns the IDs of all pods which have a pod network, their attachments are valid. The cni plugins don't
+// know which LXE instance set up an attachment, so those of the pods of other instances sharing LXD are valid as well
+func (s RuntimeServer) networkPodIDs() ([]string, error) {
+	sbs, err := s.lxf.ListSandboxes()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+
+	for _, sb := range sbs {
+		if sb.NetworkConfig.Mode != lxf.NetworkHost {
+			ids = append(ids, sb.ID)
+		}
+	}
+
+	foreign, err := s.lxf.ListForeignSandboxIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ids, foreign...), nil
+}
+
+// gcNetwork lets the current network plugin release the resources of pods which no longer exist
+func (s RuntimeServer) gcNetwork(ctx context.Context) {
+	generation, plugin := s.networks.Current()
+
+	err := plugin.GC(ctx, s.networkPodIDs)
+	if err != nil {
+		log.WithError(err).WithField("generation", generation).Warn("unable to garbage collect the network")
+	}
+}
+
+// networkGC garbage collects the network periodically, e.g. the ip allocations of pods whose teardown failed
+func (s RuntimeServer) networkGC() {
+	ticker := time.NewTicker(s.criConfig.CNIGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopping:
+			return
+		case <-ticker.C:
+			// the garbage collection isn't bound to a request
+			s.gcNetwork(context.Background())
+		}
+	}
+}
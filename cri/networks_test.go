@@ -0,0 +1,164 @@
+package cri
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/automaticserver/lxe/cri/crifakes"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	"github.com/automaticserver/lxe/network"
+	"github.com/automaticserver/lxe/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_networkPlugins(t *testing.T) {
+	t.Parallel()
+
+	first, _ := network.InitPluginNoop()
+	second, _ := network.InitPluginNoop()
+
+	n := newNetworkPlugins("first", first)
+	gen, p := n.Current()
+	assert.Equal(t, "first", gen)
+	assert.Same(t, first, p)
+
+	n.Add("second", second)
+	gen, p = n.Current()
+	assert.Equal(t, "second", gen)
+	assert.Same(t, second, p)
+
+	assert.Same(t, first, n.Get("first"))
+	assert.Same(t, second, n.Get("unknown"), "unknown generations use the current plugin")
+
+	n.Prune(map[string]bool{"first": true})
+	assert.Len(t, n.Generations(), 2)
+
+	n.Prune(map[string]bool{})
+	assert.Equal(t, []string{"second"}, n.Generations())
+}
+
+func Test_networkGeneration(t *testing.T) {
+	t.Parallel()
+
+	c := &Config{LXENetworkPlugin: NetworkPluginCNI, CNIBinDir: "/opt/cni/bin"}
+	gen := networkGeneration(c)
+	assert.Len(t, gen, 12)
+	assert.Equal(t, gen, networkGeneration(c))
+
+	c.CNIBinDir = "/usr/lib/cni"
+	assert.NotEqual(t, gen, networkGeneration(c))
+}
+
+func testServer(conf *Config) (*Server, *crifakes.FakeClient, *lxdfakes.FakeContainerServer) {
+	fake := &crifakes.FakeClient{}
+	fakeServer := &lxdfakes.FakeContainerServer{}
+	fake.GetServerReturns(fakeServer)
+
+	plugin, _ := network.InitPluginNoop()
+
+	return &Server{
+		criConfig:  conf,
+		client:     fake,
+		networks:   newNetworkPlugins(networkGeneration(conf), plugin),
+		cniOutputs: newCNIOutputFiles(),
+	}, fake, fakeServer
+}
+
+func TestServer_ReloadNetwork_Unchanged(t *testing.T) {
+	t.Parallel()
+
+	conf := &Config{LXENetworkPlugin: NetworkPluginBridge}
+	s, fake, _ := testServer(conf)
+
+	err := s.ReloadNetwork(&Config{LXENetworkPlugin: NetworkPluginBridge})
+	assert.NoError(t, err)
+	assert.Len(t, s.networks.Generations(), 1)
+	assert.Equal(t, 0, fake.ListSandboxesCallCount())
+}
+
+func TestServer_ReloadNetwork_PluginChange(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testServer(&Config{LXENetworkPlugin: NetworkPluginBridge})
+
+	err := s.ReloadNetwork(&Config{LXENetworkPlugin: NetworkPluginCNI})
+	assert.True(t, errors.Is(err, ErrNetworkPluginChange))
+}
+
+func TestServer_ReloadNetwork_Cutover(t *testing.T) {
+	t.Parallel()
+
+	conf := &Config{LXENetworkPlugin: NetworkPluginBridge}
+	s, fake, fakeServer := testServer(conf)
+	oldGen, oldPlugin := s.networks.Current()
+
+	sb := &lxf.Sandbox{}
+	sb.NetworkConfig.Generation = oldGen
+	fake.ListSandboxesReturns([]*lxf.Sandbox{sb}, nil)
+	fakeServer.GetNetworkReturns(nil, "", shared.NewErrNotFound())
+
+	err := s.ReloadNetwork(&Config{LXENetworkPlugin: NetworkPluginBridge, LXEBridgeName: "otherbr0"})
+	assert.NoError(t, err)
+
+	newGen, newPlugin := s.networks.Current()
+	assert.NotEqual(t, oldGen, newGen)
+	assert.NotSame(t, oldPlugin, newPlugin)
+	assert.Same(t, oldPlugin, s.networks.Get(oldGen), "existing pods keep their plugin")
+	assert.Equal(t, "otherbr0", fakeServer.CreateNetworkArgsForCall(0).Name)
+}
+
+func Test_cniOutputFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	o := newCNIOutputFiles()
+
+	f, err := o.open(filepath.Join(dir, "cni.log"))
+	assert.NoError(t, err)
+
+	// a reload with the same path reuses the file
+	again, err := o.open(filepath.Join(dir, "cni.log"))
+	assert.NoError(t, err)
+	assert.Same(t, f, again)
+
+	other, err := o.open(filepath.Join(dir, "other.log"))
+	assert.NoError(t, err)
+	assert.NotSame(t, f, other)
+	assert.Len(t, o.files, 2)
+
+	assert.NoError(t, o.close())
+	assert.Empty(t, o.files)
+	assert.True(t, errors.Is(f.Close(), os.ErrClosed))
+}
+
+func TestRuntimeServer_networkPodIDs(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+
+	pod := &lxf.Sandbox{}
+	pod.ID = "pod"
+	host := &lxf.Sandbox{}
+	host.ID = "host"
+	host.NetworkConfig.Mode = lxf.NetworkHost
+	fake.ListSandboxesReturns([]*lxf.Sandbox{pod, host}, nil)
+
+	ids, err := s.networkPodIDs()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pod"}, ids)
+
+	// the pods of other LXE instances sharing LXD keep their attachments
+	fake.ListForeignSandboxIDsReturns([]string{"other"}, nil)
+
+	ids, err = s.networkPodIDs()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pod", "other"}, ids)
+
+	fake.ListSandboxesReturns(nil, errors.New("failed"))
+
+	_, err = s.networkPodIDs()
+	assert.Error(t, err)
+}
@@ -0,0 +1,47 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/device"
+	"github.com/automaticserver/lxe/network"
+)
+
+var ErrNicNotAllowed = errors.New("additional nic not allowed")
+
+// applyNicAnnotations adds the additional host interfaces of the nic annotations to the sandbox profile, alongside the
+// primary interface managed by the network plugin. Pods in the host network and pods sharing a pod network namespace
+// can't have them, as their containers don't have their own network namespace to move the interfaces into
+func (s RuntimeServer) applyNicAnnotations(sb *lxf.Sandbox) error {
+	// already validated
+	nics, _ := annotation.ParseNics(annotation.Nics.GetAll(sb.Annotations))
+	if len(nics) == 0 {
+		return nil
+	}
+
+	switch {
+	case sb.NetworkConfig.Mode == lxf.NetworkHost:
+		return fmt.Errorf("%w: pod is in the host network", ErrNicNotAllowed)
+	case sb.NetworkConfig.Mode == lxf.NetworkCNI && s.criConfig.CNIPodNetns:
+		return fmt.Errorf("%w: pod shares a pod network namespace", ErrNicNotAllowed)
+	}
+
+	for _, nic := range nics {
+		if nic.Name == network.DefaultInterface {
+			return fmt.Errorf("%w: %s is the primary interface", ErrNicNotAllowed, nic.Name)
+		}
+
+		sb.Devices.Upsert(&device.Nic{
+			Name:    nic.Name,
+			NicType: nic.NicType,
+			Parent:  nic.Parent,
+			Vlan:    nic.Vlan,
+			HWAddr:  nic.HWAddr,
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package cri
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/device"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeServer_applyNicAnnotations(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+
+	sb := &lxf.Sandbox{}
+	sb.NetworkConfig.Mode = lxf.NetworkCNI
+	sb.Annotations = map[string]string{
+		annotation.Nics.Name + "eth1.nictype": "macvlan",
+		annotation.Nics.Name + "eth1.parent":  "enp3s0",
+		annotation.Nics.Name + "eth1.vlan":    "100",
+	}
+
+	err := s.applyNicAnnotations(sb)
+	assert.NoError(t, err)
+	assert.Equal(t, device.Devices{&device.Nic{Name: "eth1", NicType: "macvlan", Parent: "enp3s0", Vlan: "100"}}, sb.Devices)
+
+	s.criConfig.CNIPodNetns = true
+
+	err = s.applyNicAnnotations(sb)
+	assert.True(t, errors.Is(err, ErrNicNotAllowed))
+
+	sb.NetworkConfig.Mode = lxf.NetworkHost
+
+	err = s.applyNicAnnotations(sb)
+	assert.True(t, errors.Is(err, ErrNicNotAllowed))
+}
+
+func TestRuntimeServer_applyNicAnnotations_Primary(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+
+	sb := &lxf.Sandbox{}
+	sb.NetworkConfig.Mode = lxf.NetworkBridged
+	sb.Annotations = map[string]string{
+		annotation.Nics.Name + "eth0.nictype": "sriov",
+		annotation.Nics.Name + "eth0.parent":  "enp3s0f0",
+	}
+
+	err := s.applyNicAnnotations(sb)
+	assert.True(t, errors.Is(err, ErrNicNotAllowed))
+	assert.Empty(t, sb.Devices)
+}
@@ -0,0 +1,21 @@
+//go:build !lxdfake
+// +build !lxdfake
+
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/lxo"
+)
+
+// LXDFakeSupported is true if LXE is built with the lxdfake build tag, which adds the in-memory LXD
+const LXDFakeSupported = false
+
+var ErrLXDFakeUnsupported = errors.New("the in-memory LXD is only available if LXE is built with the lxdfake build tag")
+
+// newFakeClient fails, release builds don't contain the in-memory LXD
+func newFakeClient(criConfig *Config, configPath string, opconf lxo.Conf, project lxf.Project) (lxf.Client, error) {
+	return nil, ErrLXDFakeUnsupported
+}
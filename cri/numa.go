@@ -0,0 +1,147 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	opencontainers "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+var ErrUnknownNUMANode = errors.New("unknown numa node")
+
+// AdminNUMANode is a NUMA node of the host with its online cpus and its memory
+type AdminNUMANode struct {
+	ID          uint64 `json:"id"`
+	CPUs        string `json:"cpus"`
+	MemoryTotal uint64 `json:"memory_total"`
+	MemoryUsed  uint64 `json:"memory_used"`
+
+	cpus []uint64
+}
+
+// numaTopology returns the NUMA nodes of the LXD host sorted by id. A host without NUMA reports a single node 0
+func (s RuntimeServer) numaTopology() ([]*AdminNUMANode, error) {
+	res, err := s.lxf.GetServer().GetServerResources()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := map[uint64]*AdminNUMANode{}
+
+	node := func(id uint64) *AdminNUMANode {
+		if _, has := nodes[id]; !has {
+			nodes[id] = &AdminNUMANode{ID: id}
+		}
+
+		return nodes[id]
+	}
+
+	for _, socket := range res.CPU.Sockets {
+		for _, core := range socket.Cores {
+			for _, thread := range core.Threads {
+				if thread.Online {
+					n := node(thread.NUMANode)
+					n.cpus = append(n.cpus, uint64(thread.ID))
+				}
+			}
+		}
+	}
+
+	for _, mem := range res.Memory.Nodes {
+		n := node(mem.NUMANode)
+		n.MemoryTotal = mem.Total
+		n.MemoryUsed = mem.Used
+	}
+
+	topology := make([]*AdminNUMANode, 0, len(nodes))
+
+	for _, n := range nodes {
+		sort.Slice(n.cpus, func(i, j int) bool { return n.cpus[i] < n.cpus[j] })
+		n.CPUs = formatIDList(n.cpus)
+		topology = append(topology, n)
+	}
+
+	sort.Slice(topology, func(i, j int) bool { return topology[i].ID < topology[j].ID })
+
+	return topology, nil
+}
+
+// applyNUMANodes places the container on the NUMA nodes of the pod annotation. The cpuset and memory nodes of kubelet's
+// cpu and memory manager have priority, they are aligned by its topology manager already
+func (s RuntimeServer) applyNUMANodes(c *lxf.Container, annotations map[string]string) error {
+	raw, has := annotation.NUMANodes.Get(annotations)
+	if !has {
+		return nil
+	}
+
+	ids, err := annotation.ParseNUMANodes(raw)
+	if err != nil {
+		return err
+	}
+
+	topology, err := s.numaTopology()
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[uint64]*AdminNUMANode, len(topology))
+	for _, n := range topology {
+		byID[n.ID] = n
+	}
+
+	cpus := []uint64{}
+
+	for _, id := range ids {
+		n, has := byID[id]
+		if !has {
+			return fmt.Errorf("%w: %d, the host has %d nodes", ErrUnknownNUMANode, id, len(topology))
+		}
+
+		cpus = append(cpus, n.cpus...)
+	}
+
+	sort.Slice(cpus, func(i, j int) bool { return cpus[i] < cpus[j] })
+
+	if c.Resources == nil {
+		c.Resources = &opencontainers.LinuxResources{}
+	}
+
+	if c.Resources.CPU == nil {
+		c.Resources.CPU = &opencontainers.LinuxCPU{}
+	}
+
+	if c.Resources.CPU.Cpus == "" && len(cpus) > 0 {
+		c.Resources.CPU.Cpus = formatIDList(cpus)
+	}
+
+	if c.Resources.CPU.Mems == "" {
+		c.Resources.CPU.Mems = formatIDList(ids)
+	}
+
+	return nil
+}
+
+// formatIDList formats the sorted ids as list with ranges like the kernel does, e.g. 0-3,8
+func formatIDList(ids []uint64) string {
+	parts := []string{}
+
+	for i := 0; i < len(ids); i++ {
+		first := ids[i]
+		for i+1 < len(ids) && ids[i+1] == ids[i]+1 {
+			i++
+		}
+
+		if first == ids[i] {
+			parts = append(parts, strconv.FormatUint(first, 10))
+		} else {
+			parts = append(parts, strconv.FormatUint(first, 10)+"-"+strconv.FormatUint(ids[i], 10))
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
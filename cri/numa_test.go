@@ -0,0 +1,96 @@
+package cri
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/lxc/lxd/shared/api"
+	opencontainers "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// twoNodeResources has the cpus 0-1 and 4 on node 0, 2-3 on node 1 and cpu 5 offline
+func twoNodeResources() *api.Resources {
+	thread := func(id int64, node uint64) api.ResourcesCPUThread {
+		return api.ResourcesCPUThread{ID: id, NUMANode: node, Online: true}
+	}
+
+	return &api.Resources{
+		CPU: api.ResourcesCPU{Sockets: []api.ResourcesCPUSocket{
+			{Cores: []api.ResourcesCPUCore{
+				{Threads: []api.ResourcesCPUThread{thread(4, 0), thread(0, 0)}},
+				{Threads: []api.ResourcesCPUThread{thread(1, 0), {ID: 5, NUMANode: 0}}},
+			}},
+			{Cores: []api.ResourcesCPUCore{
+				{Threads: []api.ResourcesCPUThread{thread(2, 1), thread(3, 1)}},
+			}},
+		}},
+		Memory: api.ResourcesMemory{Nodes: []api.ResourcesMemoryNode{
+			{NUMANode: 0, Total: 16 << 30, Used: 4 << 30},
+			{NUMANode: 1, Total: 16 << 30, Used: 1 << 30},
+		}},
+	}
+}
+
+func TestRuntimeServer_numaTopology(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	fakeServer.GetServerResourcesReturns(twoNodeResources(), nil)
+
+	topology, err := s.numaTopology()
+	assert.NoError(t, err)
+	assert.Len(t, topology, 2)
+	assert.Equal(t, "0-1,4", topology[0].CPUs)
+	assert.Equal(t, uint64(4<<30), topology[0].MemoryUsed)
+	assert.Equal(t, "2-3", topology[1].CPUs)
+
+	a := newAdminService(s.criConfig, s)
+	rec := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/topology", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	res := []AdminNUMANode{}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+	assert.Equal(t, AdminNUMANode{ID: 1, CPUs: "2-3", MemoryTotal: 16 << 30, MemoryUsed: 1 << 30}, res[1])
+}
+
+func TestRuntimeServer_applyNUMANodes(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	fakeServer.GetServerResourcesReturns(twoNodeResources(), nil)
+
+	c := &lxf.Container{}
+	assert.NoError(t, s.applyNUMANodes(c, map[string]string{}))
+	assert.Nil(t, c.Resources)
+	assert.Zero(t, fakeServer.GetServerResourcesCallCount())
+
+	err := s.applyNUMANodes(c, map[string]string{annotation.NUMANodes.Name: "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "2-3", c.Resources.CPU.Cpus)
+	assert.Equal(t, "1", c.Resources.CPU.Mems)
+
+	// the cpuset of kubelet's cpu manager has priority
+	c.Resources = &opencontainers.LinuxResources{CPU: &opencontainers.LinuxCPU{Cpus: "3"}}
+	err = s.applyNUMANodes(c, map[string]string{annotation.NUMANodes.Name: "0-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "3", c.Resources.CPU.Cpus)
+	assert.Equal(t, "0-1", c.Resources.CPU.Mems)
+
+	err = s.applyNUMANodes(c, map[string]string{annotation.NUMANodes.Name: "0,2"})
+	assert.True(t, errors.Is(err, ErrUnknownNUMANode))
+}
+
+func Test_formatIDList(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "", formatIDList(nil))
+	assert.Equal(t, "0", formatIDList([]uint64{0}))
+	assert.Equal(t, "0-3,8,10-11", formatIDList([]uint64{0, 1, 2, 3, 8, 10, 11}))
+}
@@ -0,0 +1,241 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/network"
+	"github.com/automaticserver/lxe/network/netns"
+)
+
+// orphanStopTimeout is short as nothing can use an orphaned container anymore
+const orphanStopTimeout = 5
+
+// orphanTracker remembers when each orphaned container was found first. It's only deleted after it stayed orphaned
+// for the grace period, so a container whose sandbox is created or removed concurrently isn't deleted by mistake
+type orphanTracker struct {
+	grace time.Duration
+	seen  map[string]time.Time
+	// seenNetns are the stale network namespaces by path, they're tracked like the containers, as a pod being created
+	// has its namespace before it's saved
+	seenNetns map[string]time.Time
+}
+
+func newOrphanTracker(grace time.Duration) *orphanTracker {
+	return &orphanTracker{
+		grace:     grace,
+		seen:      map[string]time.Time{},
+		seenNetns: map[string]time.Time{},
+	}
+}
+
+// due returns the orphans whose grace period is over. Containers which aren't orphaned anymore are forgotten
+func (t *orphanTracker) due(orphans []*lxf.Container, now time.Time) []*lxf.Container {
+	seen := make(map[string]time.Time, len(orphans))
+	due := []*lxf.Container{}
+
+	for _, c := range orphans {
+		first, has := t.seen[c.ID]
+		if !has {
+			first = now
+
+			log.WithField("containerid", c.ID).WithField("sandboxid", c.SandboxID()).
+				Warn("found orphaned container, deleting it after the grace period")
+		}
+
+		seen[c.ID] = first
+
+		if now.Sub(first) >= t.grace {
+			due = append(due, c)
+		}
+	}
+
+	t.seen = seen
+
+	return due
+}
+
+// dueNetns returns the stale network namespaces whose grace period is over. Namespaces which aren't stale anymore are
+// forgotten
+func (t *orphanTracker) dueNetns(paths []string, now time.Time) []string {
+	seen := make(map[string]time.Time, len(paths))
+	due := []string{}
+
+	for _, path := range paths {
+		first, has := t.seenNetns[path]
+		if !has {
+			first = now
+		}
+
+		seen[path] = first
+
+		if now.Sub(first) >= t.grace {
+			due = append(due, path)
+		}
+	}
+
+	t.seenNetns = seen
+
+	return due
+}
+
+// forget removes the container, e.g. after it's deleted
+func (t *orphanTracker) forget(id string) {
+	delete(t.seen, id)
+}
+
+// findOrphans returns the LXE containers whose sandbox is missing. Kubelet only knows containers through their
+// sandbox, so it will never remove them
+func (s RuntimeServer) findOrphans() ([]*lxf.Container, error) {
+	sbs, err := s.lxf.ListSandboxes()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(sbs))
+	for _, sb := range sbs {
+		known[sb.ID] = true
+	}
+
+	cl, err := s.lxf.ListContainers()
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := []*lxf.Container{}
+
+	for _, c := range cl {
+		if len(c.Profiles) == 0 || !known[c.SandboxID()] {
+			orphans = append(orphans, c)
+		}
+	}
+
+	return orphans, nil
+}
+
+// deleteOrphan stops and deletes the orphaned container. As the network config was stored in the missing sandbox, the
+// network is torn down with the current plugin as good as possible
+func (s RuntimeServer) deleteOrphan(ctx context.Context, c *lxf.Container) error {
+	err := s.stopContainer(ctx, c, orphanStopTimeout)
+	if err != nil {
+		return err
+	}
+
+	if len(c.Profiles) > 0 {
+		_, plugin := s.networks.Current()
+
+		podNet, err := plugin.PodNetwork(c.SandboxID(), c.Annotations)
+		if err == nil { // force cleanup, we don't care about error, but only enter if there's no error
+			contNet, err := podNet.ContainerNetwork(c.ID, c.Annotations)
+			if err == nil { // dito
+				_ = contNet.WhenDeleted(ctx, &network.Properties{})
+			}
+		}
+
+		if s.criConfig.LXENetworkPlugin == NetworkPluginCNI && s.criConfig.CNINetnsPath != "" {
+			err = netns.RemoveAll(s.criConfig.netnsPath(), c.SandboxID())
+			if err != nil {
+				log.WithError(err).WithField("sandboxid", c.SandboxID()).Warn("unable to remove network namespace files")
+			}
+		}
+	}
+
+	return c.Delete(ctx)
+}
+
+// findStaleNetns returns the network namespaces in the netns path which no pod of this LXE uses. Those of other name
+// prefixes are left to their LXE, other owners have a netns path of their own
+func (s RuntimeServer) findStaleNetns() ([]string, error) {
+	if s.criConfig.LXENetworkPlugin != NetworkPluginCNI || s.criConfig.CNINetnsPath == "" {
+		return nil, nil
+	}
+
+	sbs, err := s.lxf.ListSandboxes()
+	if err != nil {
+		return nil, err
+	}
+
+	inUse := make(map[string]bool, len(sbs))
+	for _, sb := range sbs {
+		inUse[network.PodNetns(sb.NetworkConfig.ModeData)] = true
+	}
+
+	dir := s.criConfig.netnsPath()
+	prefix := filepath.Join(dir, s.criConfig.LXENamePrefix)
+
+	return netns.Stale(dir, func(path string) bool {
+		return inUse[path] || !strings.HasPrefix(path, prefix)
+	})
+}
+
+// reconcileStaleNetns removes the stale network namespaces whose grace period is over
+func (s RuntimeServer) reconcileStaleNetns(t *orphanTracker, now time.Time) {
+	stale, err := s.findStaleNetns()
+	if err != nil {
+		log.WithError(err).Warn("unable to find stale network namespaces")
+		return
+	}
+
+	for _, path := range t.dueNetns(stale, now) {
+		err = netns.Remove(path)
+		if err != nil {
+			log.WithError(err).WithField("path", path).Warn("unable to remove stale network namespace")
+			continue
+		}
+
+		log.WithField("path", path).Info("removed stale network namespace")
+	}
+}
+
+// reconcileOrphans deletes the orphaned containers and stale network namespaces whose grace period is over
+func (s RuntimeServer) reconcileOrphans(ctx context.Context, t *orphanTracker, now time.Time) {
+	s.reconcileStaleNetns(t, now)
+
+	orphans, err := s.findOrphans()
+	if err != nil {
+		log.WithError(err).Warn("unable to find orphaned containers")
+		return
+	}
+
+	for _, c := range t.due(orphans, now) {
+		audit := s.audit(ctx, c, auditRemoved, "orphaned")
+
+		err = s.deleteOrphan(ctx, c)
+		s.auditDone(audit, c, err)
+
+		if err != nil {
+			log.WithError(err).WithField("containerid", c.ID).Warn("unable to delete orphaned container")
+			continue
+		}
+
+		t.forget(c.ID)
+		s.publishEvent(c, ContainerDeletedEvent)
+		log.WithField("containerid", c.ID).Info("deleted orphaned container")
+	}
+}
+
+// orphanReconciler looks for orphaned containers at startup and then periodically. They are left behind when LXE or
+// LXD crash while removing a pod
+func (s RuntimeServer) orphanReconciler() {
+	t := newOrphanTracker(s.criConfig.LXEOrphanGracePeriod)
+	s.restoreOrphans(t)
+
+	ticker := time.NewTicker(s.criConfig.LXEOrphanInterval)
+	defer ticker.Stop()
+
+	// the reconciliation isn't bound to a request
+	s.reconcileOrphans(context.Background(), t, time.Now())
+
+	for {
+		select {
+		case <-s.stopping:
+			s.saveOrphans(t)
+			return
+		case now := <-ticker.C:
+			s.reconcileOrphans(context.Background(), t, now)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package cri
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeServer_findOrphans(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+
+	sb := &lxf.Sandbox{}
+	sb.ID = "sb"
+	fake.ListSandboxesReturns([]*lxf.Sandbox{sb}, nil)
+
+	known := &lxf.Container{}
+	known.ID = "known"
+	known.Profiles = []string{"default", "sb"}
+
+	orphan := &lxf.Container{}
+	orphan.ID = "orphan"
+	orphan.Profiles = []string{"default", "gone"}
+
+	noProfiles := &lxf.Container{}
+	noProfiles.ID = "noprofiles"
+
+	fake.ListContainersReturns([]*lxf.Container{known, orphan, noProfiles}, nil)
+
+	orphans, err := s.findOrphans()
+	assert.NoError(t, err)
+	assert.Equal(t, []*lxf.Container{orphan, noProfiles}, orphans)
+}
+
+func Test_orphanTracker_due(t *testing.T) {
+	t.Parallel()
+
+	tr := newOrphanTracker(time.Minute)
+	start := time.Now()
+
+	a := &lxf.Container{}
+	a.ID = "a"
+	a.Profiles = []string{"default", "gone"}
+
+	b := &lxf.Container{}
+	b.ID = "b"
+	b.Profiles = []string{"default", "gone"}
+
+	assert.Empty(t, tr.due([]*lxf.Container{a}, start))
+	assert.Empty(t, tr.due([]*lxf.Container{a, b}, start.Add(30*time.Second)))
+	assert.Equal(t, []*lxf.Container{a}, tr.due([]*lxf.Container{a, b}, start.Add(time.Minute)))
+
+	// a isn't orphaned anymore, so its grace period starts anew
+	assert.Equal(t, []*lxf.Container{b}, tr.due([]*lxf.Container{b}, start.Add(2*time.Minute)))
+	assert.Equal(t, []*lxf.Container{b}, tr.due([]*lxf.Container{a, b}, start.Add(150*time.Second)))
+	assert.Len(t, tr.seen, 2)
+
+	tr.forget("b")
+	assert.Len(t, tr.seen, 1)
+}
+
+func Test_orphanTracker_dueNetns(t *testing.T) {
+	t.Parallel()
+
+	tr := newOrphanTracker(time.Minute)
+	start := time.Now()
+
+	assert.Empty(t, tr.dueNetns([]string{"/run/netns/a_00000000"}, start))
+	assert.Equal(t, []string{"/run/netns/a_00000000"}, tr.dueNetns([]string{"/run/netns/a_00000000", "/run/netns/b_00000000"}, start.Add(time.Minute)))
+	assert.Empty(t, tr.dueNetns(nil, start.Add(2*time.Minute)))
+	assert.Empty(t, tr.seenNetns)
+}
+
+func TestRuntimeServer_findStaleNetns(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-netns")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"k8s-used_0123abcd", "k8s-gone_0123abcd", "other-gone_0123abcd", "k8s-legacy", "k8s-gone_x"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0600))
+	}
+
+	s, fake, _ := testRuntimeServer()
+	s.criConfig.LXENetworkPlugin = NetworkPluginCNI
+	s.criConfig.CNINetnsPath = dir
+	s.criConfig.LXENamePrefix = "k8s-"
+
+	sb := &lxf.Sandbox{}
+	sb.NetworkConfig.ModeData = map[string]string{"netns": filepath.Join(dir, "k8s-used_0123abcd")}
+	fake.ListSandboxesReturns([]*lxf.Sandbox{sb}, nil)
+
+	stale, err := s.findStaleNetns()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "k8s-gone_0123abcd")}, stale)
+
+	s.criConfig.LXENetworkPlugin = NetworkPluginBridge
+	stale, err = s.findStaleNetns()
+	assert.NoError(t, err)
+	assert.Empty(t, stale)
+}
+
+func TestRuntimeServer_findStaleNetns_Owner(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	// the namespaces of the instance without owner and of the other owners are left alone
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "node1"), 0700))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "node2"), 0700))
+
+	for _, name := range []string{"k8s-gone_0123abcd", "node1/k8s-gone_0123abcd", "node2/k8s-gone_0123abcd"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0600))
+	}
+
+	s, fake, _ := testRuntimeServer()
+	s.criConfig.LXENetworkPlugin = NetworkPluginCNI
+	s.criConfig.CNINetnsPath = dir
+	s.criConfig.LXEOwner = "node1"
+	fake.ListSandboxesReturns(nil, nil)
+
+	stale, err := s.findStaleNetns()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "node1", "k8s-gone_0123abcd")}, stale)
+}
@@ -0,0 +1,98 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"encoding/json"
+
+	"github.com/automaticserver/lxe/lxf"
+	opencontainers "github.com/opencontainers/runtime-spec/specs-go"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// sharesPerCPU are the cpu shares kubelet sets for one cpu
+	sharesPerCPU = 1024
+	// milliCPU are the millicores of one cpu
+	milliCPU = 1000
+)
+
+// podOverhead is the resource overhead of a pod, e.g. of the init system of its system containers. The CRI doesn't pass
+// the overhead of the RuntimeClass, so it's configured with the runtime handler as well
+type podOverhead struct {
+	// CPU in millicores
+	CPU int64 `json:"cpu,omitempty"`
+	// Memory in bytes
+	Memory int64 `json:"memory,omitempty"`
+}
+
+// set parses the quantity of the runtime handler option overhead-cpu or overhead-memory
+func (o *podOverhead) set(option, value string) error {
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return err
+	}
+
+	if option == "overhead-cpu" {
+		o.CPU = q.MilliValue()
+	} else {
+		o.Memory = q.Value()
+	}
+
+	return nil
+}
+
+// isZero checks if there is no overhead
+func (o podOverhead) isZero() bool {
+	return o.CPU == 0 && o.Memory == 0
+}
+
+// withOverhead returns the resources kubelet requested for a container raised by the overhead of the pod's runtime
+// handler. Only requested limits are raised, so an unlimited container stays unlimited. Each container gets the whole
+// overhead, kubelet's pod cgroup, which includes the overhead once, limits all containers together
+func withOverhead(res *opencontainers.LinuxResources, o podOverhead) *opencontainers.LinuxResources {
+	if res == nil || o.isZero() {
+		return res
+	}
+
+	if cpu := res.CPU; cpu != nil && o.CPU > 0 {
+		if cpu.Shares != nil && *cpu.Shares > 0 {
+			shares := *cpu.Shares + uint64(o.CPU)*sharesPerCPU/milliCPU
+			cpu.Shares = &shares
+		}
+
+		if cpu.Quota != nil && *cpu.Quota > 0 && cpu.Period != nil && *cpu.Period > 0 {
+			quota := *cpu.Quota + o.CPU*int64(*cpu.Period)/milliCPU
+			cpu.Quota = &quota
+		}
+	}
+
+	if mem := res.Memory; mem != nil && o.Memory > 0 && mem.Limit != nil && *mem.Limit > 0 {
+		limit := *mem.Limit + o.Memory
+		mem.Limit = &limit
+	}
+
+	return res
+}
+
+// podOverhead returns the overhead of the sandbox's runtime handler
+func (s RuntimeServer) podOverhead(sb *lxf.Sandbox) podOverhead {
+	if h, has := s.runtimeHandlers[sb.RuntimeHandler]; has {
+		return h.Overhead
+	}
+
+	return podOverhead{}
+}
+
+// podOverheadInfo returns the verbose info of the pod with the overhead of its runtime handler as json
+func (s RuntimeServer) podOverheadInfo(sb *lxf.Sandbox) (map[string]string, error) {
+	o := s.podOverhead(sb)
+	if o.isZero() {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{"overhead": string(b)}, nil
+}
@@ -0,0 +1,55 @@
+package cri
+
+import (
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf"
+	opencontainers "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_withOverhead(t *testing.T) {
+	t.Parallel()
+
+	shares, quota, period, limit := uint64(512), int64(50000), uint64(100000), int64(128<<20)
+	res := &opencontainers.LinuxResources{
+		CPU:    &opencontainers.LinuxCPU{Shares: &shares, Quota: &quota, Period: &period},
+		Memory: &opencontainers.LinuxMemory{Limit: &limit},
+	}
+
+	res = withOverhead(res, podOverhead{CPU: 250, Memory: 64 << 20})
+	assert.Equal(t, uint64(768), *res.CPU.Shares)
+	assert.Equal(t, int64(75000), *res.CPU.Quota)
+	assert.Equal(t, int64(192<<20), *res.Memory.Limit)
+
+	unlimited, zero := int64(-1), int64(0)
+	res = &opencontainers.LinuxResources{
+		CPU:    &opencontainers.LinuxCPU{Quota: &unlimited, Period: &period},
+		Memory: &opencontainers.LinuxMemory{Limit: &zero},
+	}
+
+	res = withOverhead(res, podOverhead{CPU: 250, Memory: 64 << 20})
+	assert.Equal(t, int64(-1), *res.CPU.Quota, "unlimited containers stay unlimited")
+	assert.Equal(t, int64(0), *res.Memory.Limit)
+
+	assert.Nil(t, withOverhead(nil, podOverhead{CPU: 250}))
+}
+
+func TestRuntimeServer_podOverheadInfo(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+	s.runtimeHandlers = map[string]*runtimeHandler{"kata": {Overhead: podOverhead{CPU: 250, Memory: 64 << 20}}}
+
+	sb := &lxf.Sandbox{}
+
+	info, err := s.podOverheadInfo(sb)
+	assert.NoError(t, err)
+	assert.Nil(t, info)
+
+	sb.RuntimeHandler = "kata"
+
+	info, err = s.podOverheadInfo(sb)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"overhead": `{"cpu":250,"memory":67108864}`}, info)
+}
@@ -0,0 +1,27 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"strconv"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+)
+
+const (
+	cfgLimitProcesses = "limits.processes"
+)
+
+// applyPidsLimit limits the number of processes of the pod's containers in the sandbox profile. The pod annotation has
+// priority over the daemon-wide limit. LXD limits every container on its own, not the pod as a whole
+func (s RuntimeServer) applyPidsLimit(sb *lxf.Sandbox, annotations map[string]string) {
+	limit := s.config().LXEPodPidsLimit
+
+	if raw, has := annotation.PidsLimit.Get(annotations); has {
+		// already validated
+		limit, _ = annotation.ParsePidsLimit(raw)
+	}
+
+	if limit > 0 {
+		sb.Config[cfgLimitProcesses] = strconv.FormatInt(limit, 10)
+	}
+}
@@ -0,0 +1,29 @@
+package cri
+
+import (
+	"testing"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeServer_applyPidsLimit(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+
+	sb := &lxf.Sandbox{}
+	sb.Config = map[string]string{}
+
+	s.applyPidsLimit(sb, nil)
+	assert.Empty(t, sb.Config, "unlimited by default")
+
+	s.criConfig.LXEPodPidsLimit = 4096
+
+	s.applyPidsLimit(sb, nil)
+	assert.Equal(t, "4096", sb.Config[cfgLimitProcesses])
+
+	s.applyPidsLimit(sb, map[string]string{annotation.PidsLimit.Name: "100"})
+	assert.Equal(t, "100", sb.Config[cfgLimitProcesses])
+}
@@ -0,0 +1,257 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/metrics"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// minShares are the cpu shares kubelet sets for containers without cpu request
+const minShares = 2
+
+// quotaReport sums the resources LXD enforces for the containers of LXE and compares them with the node allocatable,
+// which kubelet admits pods against. Kubelet only knows the requests of its pods, LXD only the limits of the containers
+type quotaReport struct {
+	Time       time.Time `json:"time"`
+	Containers int       `json:"containers"`
+	// CPURequests are derived from the cpu shares, in millicores
+	CPURequests int64 `json:"cpuRequests"`
+	// CPULimits are derived from the cpu quota, in millicores
+	CPULimits int64 `json:"cpuLimits"`
+	// MemoryLimits in bytes, the CRI doesn't pass memory requests
+	MemoryLimits int64 `json:"memoryLimits"`
+	// UnlimitedCPU and UnlimitedMemory count the containers without limit, which can use the whole node
+	UnlimitedCPU    int `json:"unlimitedCpu"`
+	UnlimitedMemory int `json:"unlimitedMemory"`
+	// AllocatableCPU in millicores and AllocatableMemory in bytes
+	AllocatableCPU    int64 `json:"allocatableCpu"`
+	AllocatableMemory int64 `json:"allocatableMemory"`
+	// Warnings describe by resource where LXD's view diverges from kubelet's
+	Warnings map[string]string `json:"warnings,omitempty"`
+}
+
+// parseAllocatable parses the allocatable quantities, empty ones are 0
+func parseAllocatable(cpu, memory string) (int64, int64, error) {
+	var c, m int64
+
+	if cpu != "" {
+		q, err := resource.ParseQuantity(cpu)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid allocatable cpu %q: %w", cpu, err)
+		}
+
+		c = q.MilliValue()
+	}
+
+	if memory != "" {
+		q, err := resource.ParseQuantity(memory)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid allocatable memory %q: %w", memory, err)
+		}
+
+		m = q.Value()
+	}
+
+	return c, m, nil
+}
+
+// nodeAllocatable returns the allocatable cpu in millicores and memory in bytes. Quantities which aren't configured are
+// the capacity of the LXD server, as if nothing was reserved
+func (s RuntimeServer) nodeAllocatable() (int64, int64, error) {
+	conf := s.config()
+
+	cpu, memory, err := parseAllocatable(conf.LXENodeAllocatableCPU, conf.LXENodeAllocatableMemory)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if cpu > 0 && memory > 0 {
+		return cpu, memory, nil
+	}
+
+	res, err := s.lxf.GetServer().GetServerResources()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if cpu == 0 {
+		cpu = int64(res.CPU.Total) * milliCPU
+	}
+
+	if memory == 0 {
+		memory = int64(res.Memory.Total)
+	}
+
+	return cpu, memory, nil
+}
+
+// sumResources adds the requests and limits of the containers which aren't exited to the report
+func (r *quotaReport) sumResources(cl []*lxf.Container) {
+	for _, c := range cl {
+		if c.StateName == lxf.ContainerStateExited {
+			continue
+		}
+
+		r.Containers++
+
+		var (
+			cpu *int64
+			mem *int64
+		)
+
+		if res := c.Resources; res != nil {
+			if res.CPU != nil {
+				if res.CPU.Shares != nil && *res.CPU.Shares > minShares {
+					r.CPURequests += int64(*res.CPU.Shares) * milliCPU / sharesPerCPU
+				}
+
+				if res.CPU.Quota != nil && *res.CPU.Quota > 0 && res.CPU.Period != nil && *res.CPU.Period > 0 {
+					limit := *res.CPU.Quota * milliCPU / int64(*res.CPU.Period)
+					cpu = &limit
+				}
+			}
+
+			if res.Memory != nil && res.Memory.Limit != nil && *res.Memory.Limit > 0 {
+				mem = res.Memory.Limit
+			}
+		}
+
+		if cpu != nil {
+			r.CPULimits += *cpu
+		} else {
+			r.UnlimitedCPU++
+		}
+
+		if mem != nil {
+			r.MemoryLimits += *mem
+		} else {
+			r.UnlimitedMemory++
+		}
+	}
+}
+
+// compare adds the warnings where LXD's view diverges from kubelet's. Kubelet never admits more requests than
+// allocatable, so exceeding requests are containers kubelet doesn't account for, e.g. adopted ones or the overhead of
+// the runtime handlers. Memory limits are all LXD enforces, beyond allocatable the node can run out of memory before
+// kubelet evicts
+func (r *quotaReport) compare() {
+	r.Warnings = map[string]string{}
+
+	if r.AllocatableCPU > 0 && r.CPURequests > r.AllocatableCPU {
+		r.Warnings["cpu"] = fmt.Sprintf("cpu requests of %dm exceed the allocatable %dm", r.CPURequests, r.AllocatableCPU)
+	}
+
+	if r.AllocatableMemory > 0 && r.MemoryLimits > r.AllocatableMemory {
+		r.Warnings["memory"] = fmt.Sprintf("memory limits of %d bytes exceed the allocatable %d bytes", r.MemoryLimits, r.AllocatableMemory)
+	}
+}
+
+// overcommitted returns 1 if there's a warning for the resource, as metric value
+func (r *quotaReport) overcommitted(res string) float64 {
+	if _, has := r.Warnings[res]; has {
+		return 1
+	}
+
+	return 0
+}
+
+// collectQuota sums the resources of the containers and compares them with the node allocatable
+func (s RuntimeServer) collectQuota(now time.Time) (*quotaReport, error) {
+	cl, err := s.lxf.ListContainers()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &quotaReport{Time: now}
+	r.sumResources(cl)
+
+	r.AllocatableCPU, r.AllocatableMemory, err = s.nodeAllocatable()
+	if err != nil {
+		return nil, err
+	}
+
+	r.compare()
+
+	return r, nil
+}
+
+// observeQuota exposes the report as metrics
+func observeQuota(r *quotaReport) {
+	metrics.QuotaResources.WithLabelValues("cpu", "requests").Set(float64(r.CPURequests) / milliCPU)
+	metrics.QuotaResources.WithLabelValues("cpu", "limits").Set(float64(r.CPULimits) / milliCPU)
+	metrics.QuotaResources.WithLabelValues("cpu", "allocatable").Set(float64(r.AllocatableCPU) / milliCPU)
+	metrics.QuotaResources.WithLabelValues("memory", "limits").Set(float64(r.MemoryLimits))
+	metrics.QuotaResources.WithLabelValues("memory", "allocatable").Set(float64(r.AllocatableMemory))
+	metrics.QuotaUnlimited.WithLabelValues("cpu").Set(float64(r.UnlimitedCPU))
+	metrics.QuotaUnlimited.WithLabelValues("memory").Set(float64(r.UnlimitedMemory))
+	metrics.QuotaOvercommitted.WithLabelValues("cpu").Set(r.overcommitted("cpu"))
+	metrics.QuotaOvercommitted.WithLabelValues("memory").Set(r.overcommitted("memory"))
+}
+
+// lastQuotaReport returns the latest report, nil if there's none yet
+func (s RuntimeServer) lastQuotaReport() *quotaReport {
+	if s.quota == nil {
+		return nil
+	}
+
+	r, _ := s.quota.Load().(*quotaReport)
+
+	return r
+}
+
+// quotaInfo returns the latest report as JSON for the verbose runtime status, empty if there's none yet
+func (s RuntimeServer) quotaInfo() string {
+	r := s.lastQuotaReport()
+	if r == nil {
+		return ""
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return ""
+	}
+
+	return string(b)
+}
+
+// reportQuota creates the report, exposes it and logs new warnings. Warnings which were already logged are only logged
+// again after they were resolved
+func (s RuntimeServer) reportQuota(now time.Time) {
+	r, err := s.collectQuota(now)
+	if err != nil {
+		log.WithError(err).Warn("unable to report quota")
+		return
+	}
+
+	prev := s.lastQuotaReport()
+
+	for res, w := range r.Warnings {
+		if prev == nil || prev.Warnings[res] == "" {
+			log.WithField("containers", r.Containers).WithField("resource", res).Warn("node overcommitted: " + w)
+		}
+	}
+
+	observeQuota(r)
+	s.quota.Store(r)
+}
+
+// quotaReporter reports the quota at startup and then periodically
+func (s RuntimeServer) quotaReporter() {
+	ticker := time.NewTicker(s.criConfig.LXEQuotaInterval)
+	defer ticker.Stop()
+
+	s.reportQuota(time.Now())
+
+	for {
+		select {
+		case <-s.stopping:
+			return
+		case now := <-ticker.C:
+			s.reportQuota(now)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package cri
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/lxc/lxd/shared/api"
+	opencontainers "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func quotaContainer(shares uint64, quota int64, memory int64) *lxf.Container {
+	c := &lxf.Container{}
+	c.StateName = lxf.ContainerStateRunning
+	c.Resources = &opencontainers.LinuxResources{}
+
+	if shares > 0 || quota > 0 {
+		period := uint64(100000)
+		c.Resources.CPU = &opencontainers.LinuxCPU{Shares: &shares, Quota: &quota, Period: &period}
+	}
+
+	if memory > 0 {
+		c.Resources.Memory = &opencontainers.LinuxMemory{Limit: &memory}
+	}
+
+	return c
+}
+
+func Test_parseAllocatable(t *testing.T) {
+	t.Parallel()
+
+	cpu, memory, err := parseAllocatable("7500m", "1Gi")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7500), cpu)
+	assert.Equal(t, int64(1<<30), memory)
+
+	cpu, memory, err = parseAllocatable("", "")
+	assert.NoError(t, err)
+	assert.Zero(t, cpu)
+	assert.Zero(t, memory)
+
+	_, _, err = parseAllocatable("lots", "")
+	assert.Error(t, err)
+}
+
+func Test_quotaReport_sumResources(t *testing.T) {
+	t.Parallel()
+
+	exited := quotaContainer(1024, 100000, 1<<30)
+	exited.StateName = lxf.ContainerStateExited
+
+	r := &quotaReport{}
+	r.sumResources([]*lxf.Container{
+		quotaContainer(512, 100000, 1<<30),
+		// best effort, the minimum shares aren't a request
+		quotaContainer(minShares, 0, 0),
+		exited,
+	})
+
+	assert.Equal(t, 2, r.Containers)
+	assert.Equal(t, int64(500), r.CPURequests)
+	assert.Equal(t, int64(1000), r.CPULimits)
+	assert.Equal(t, int64(1<<30), r.MemoryLimits)
+	assert.Equal(t, 1, r.UnlimitedCPU)
+	assert.Equal(t, 1, r.UnlimitedMemory)
+}
+
+func Test_quotaReport_compare(t *testing.T) {
+	t.Parallel()
+
+	r := &quotaReport{CPURequests: 2000, CPULimits: 8000, MemoryLimits: 2 << 30, AllocatableCPU: 4000, AllocatableMemory: 1 << 30}
+	r.compare()
+	assert.Contains(t, r.Warnings, "memory")
+	assert.NotContains(t, r.Warnings, "cpu")
+	assert.Equal(t, float64(1), r.overcommitted("memory"))
+	assert.Equal(t, float64(0), r.overcommitted("cpu"))
+
+	r.CPURequests = 5000
+	r.compare()
+	assert.Contains(t, r.Warnings, "cpu")
+}
+
+func TestRuntimeServer_nodeAllocatable(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+
+	fakeServer.GetServerResourcesReturns(&api.Resources{
+		CPU:    api.ResourcesCPU{Total: 8},
+		Memory: api.ResourcesMemory{Total: 32 << 30},
+	}, nil)
+
+	cpu, memory, err := s.nodeAllocatable()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8000), cpu)
+	assert.Equal(t, int64(32<<30), memory)
+
+	// configured quantities take precedence, LXD is only asked for the missing ones
+	s.criConfig.LXENodeAllocatableCPU = "7500m"
+
+	cpu, memory, err = s.nodeAllocatable()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7500), cpu)
+	assert.Equal(t, int64(32<<30), memory)
+	assert.Equal(t, 2, fakeServer.GetServerResourcesCallCount())
+
+	s.criConfig.LXENodeAllocatableMemory = "30Gi"
+
+	_, _, err = s.nodeAllocatable()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fakeServer.GetServerResourcesCallCount())
+}
+
+func TestRuntimeServer_reportQuota(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	s.criConfig.LXENodeAllocatableCPU = "1"
+	s.criConfig.LXENodeAllocatableMemory = "1Gi"
+
+	assert.Empty(t, s.quotaInfo())
+
+	fake.ListContainersReturns([]*lxf.Container{quotaContainer(2048, 0, 2<<30)}, nil)
+
+	now := time.Now()
+	s.reportQuota(now)
+
+	r := s.lastQuotaReport()
+	assert.NotNil(t, r)
+	assert.Equal(t, now, r.Time)
+	assert.Contains(t, r.Warnings, "cpu")
+	assert.Contains(t, r.Warnings, "memory")
+	assert.Contains(t, s.quotaInfo(), `"cpuRequests":2000`)
+
+	// a failing report keeps the previous one
+	fake.ListContainersReturns(nil, errors.New("lxd gone"))
+	s.reportQuota(now.Add(time.Minute))
+	assert.Equal(t, now, s.lastQuotaReport().Time)
+}
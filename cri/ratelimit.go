@@ -0,0 +1,296 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/automaticserver/lxe/metrics"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+var ErrInvalidMethodConcurrency = errors.New("invalid method concurrency")
+
+// unlimitedMethods are never limited, kubelet checks the health of the runtime with them
+var unlimitedMethods = map[string]bool{
+	"Version": true,
+	"Status":  true,
+}
+
+// parseMethodConcurrency parses the method=limit entries of the concurrent calls per CRI method
+func parseMethodConcurrency(entries []string) (map[string]int, error) {
+	limits := make(map[string]int, len(entries))
+
+	for _, e := range entries {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("%w: entry %q must be in the form method=limit", ErrInvalidMethodConcurrency, e)
+		}
+
+		limit, err := strconv.Atoi(parts[1])
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("%w: entry %q must have a limit of 0 or more", ErrInvalidMethodConcurrency, e)
+		}
+
+		limits[parts[0]] = limit
+	}
+
+	return limits, nil
+}
+
+// callLimiter limits the concurrent CRI calls per method and the rate of all calls, so bursts of kubelet don't
+// overwhelm LXD, e.g. when it relists hundreds of pods after a node reboot
+type callLimiter struct {
+	rate *rate.Limiter
+	// limit is the concurrent calls of methods without own limit, 0 doesn't limit them
+	limit   int
+	methods map[string]int
+
+	mu     sync.Mutex
+	queues map[string]*fairQueue
+}
+
+// newCallLimiter returns the limiter of the configured limits, nil if nothing is limited
+func newCallLimiter(criConfig *Config) (*callLimiter, error) {
+	methods, err := parseMethodConcurrency(criConfig.LXEGRPCMethodConcurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	if criConfig.LXEGRPCRateLimit <= 0 && criConfig.LXEGRPCMaxConcurrentCalls <= 0 && len(methods) == 0 {
+		return nil, nil
+	}
+
+	l := &callLimiter{
+		limit:   criConfig.LXEGRPCMaxConcurrentCalls,
+		methods: methods,
+		queues:  map[string]*fairQueue{},
+	}
+
+	if criConfig.LXEGRPCRateLimit > 0 {
+		burst := criConfig.LXEGRPCRateBurst
+		if burst < 1 {
+			burst = 1
+		}
+
+		l.rate = rate.NewLimiter(rate.Limit(criConfig.LXEGRPCRateLimit), burst)
+	}
+
+	return l, nil
+}
+
+// queue returns the queue of the method, nil if its calls aren't limited
+func (l *callLimiter) queue(method string) *fairQueue {
+	limit, has := l.methods[method]
+	if !has {
+		limit = l.limit
+	}
+
+	if limit <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	q, has := l.queues[method]
+	if !has {
+		q = newFairQueue(limit)
+		l.queues[method] = q
+	}
+
+	return q
+}
+
+// intercept waits for a free call of the method and a token of the rate limit before the call is handled. A call which
+// is cancelled while waiting returns ResourceExhausted, so kubelet retries it later
+func (l *callLimiter) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	method := path.Base(info.FullMethod)
+	if unlimitedMethods[method] {
+		return handler(ctx, req)
+	}
+
+	q := l.queue(method)
+	if q == nil && l.rate == nil {
+		return handler(ctx, req)
+	}
+
+	start := time.Now()
+
+	metrics.CRIQueueDepth.WithLabelValues(method).Inc()
+	err := l.wait(ctx, q, callPod(req))
+	metrics.CRIQueueDepth.WithLabelValues(method).Dec()
+	metrics.CRIQueueWait.WithLabelValues(method).Observe(metrics.Since(start))
+
+	if err != nil {
+		return nil, status.Errorf(codes.ResourceExhausted, "%s: too many calls, waited %s: %v", method, time.Since(start).Round(time.Millisecond), err)
+	}
+
+	if q != nil {
+		defer q.release()
+	}
+
+	return handler(ctx, req)
+}
+
+// wait acquires a call of the queue if the method is limited and then a token of the rate limit. The call is given
+// back if the rate limit isn't reached in time
+func (l *callLimiter) wait(ctx context.Context, q *fairQueue, pod string) error {
+	if q != nil {
+		err := q.acquire(ctx, pod)
+		if err != nil {
+			return err
+		}
+	}
+
+	if l.rate == nil {
+		return nil
+	}
+
+	err := l.rate.Wait(ctx)
+	if err != nil && q != nil {
+		q.release()
+	}
+
+	return err
+}
+
+// callPod returns what the call is queued by: the pod sandbox, the pod to be created or the container. Calls without
+// any of them, e.g. listing, share one queue
+func callPod(req interface{}) string {
+	if r, ok := req.(*rtApi.RunPodSandboxRequest); ok {
+		m := r.GetConfig().GetMetadata()
+		return m.GetNamespace() + "/" + m.GetName()
+	}
+
+	if r, ok := req.(interface{ GetPodSandboxId() string }); ok && r.GetPodSandboxId() != "" {
+		return r.GetPodSandboxId()
+	}
+
+	if r, ok := req.(interface{ GetContainerId() string }); ok && r.GetContainerId() != "" {
+		return r.GetContainerId()
+	}
+
+	return ""
+}
+
+// fairQueue limits the concurrent calls. Waiting calls are queued by pod and the pods take turns, so a pod with many
+// calls doesn't hold up the calls of the other pods
+type fairQueue struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	// waiting are the calls per pod in the order they arrived, order are the pods with waiting calls in turn
+	waiting map[string][]chan struct{}
+	order   []string
+}
+
+func newFairQueue(limit int) *fairQueue {
+	return &fairQueue{limit: limit, waiting: map[string][]chan struct{}{}}
+}
+
+// acquire returns when the call of the pod may run or with the error of the context if it's done before
+func (q *fairQueue) acquire(ctx context.Context, pod string) error {
+	q.mu.Lock()
+
+	if q.running < q.limit && len(q.order) == 0 {
+		q.running++
+		q.mu.Unlock()
+
+		return nil
+	}
+
+	ready := make(chan struct{})
+
+	if len(q.waiting[pod]) == 0 {
+		q.order = append(q.order, pod)
+	}
+
+	q.waiting[pod] = append(q.waiting[pod], ready)
+	q.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.remove(pod, ready) {
+		// it was its turn in the meantime, so pass it on
+		q.running--
+		q.next()
+	}
+
+	return ctx.Err()
+}
+
+// release ends a call and lets the next one run
+func (q *fairQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.running--
+	q.next()
+}
+
+// next lets the waiting calls run while there are free calls, the first call of the pod in turn goes first. A pod
+// with further calls queues up again behind the others
+func (q *fairQueue) next() {
+	for q.running < q.limit && len(q.order) > 0 {
+		pod := q.order[0]
+		q.order = q.order[1:]
+
+		calls := q.waiting[pod]
+		if len(calls) > 1 {
+			q.waiting[pod] = calls[1:]
+			q.order = append(q.order, pod)
+		} else {
+			delete(q.waiting, pod)
+		}
+
+		q.running++
+		close(calls[0])
+	}
+}
+
+// remove removes the waiting call of the pod, false if it isn't waiting anymore
+func (q *fairQueue) remove(pod string, ready chan struct{}) bool {
+	calls := q.waiting[pod]
+
+	for i, c := range calls {
+		if c != ready {
+			continue
+		}
+
+		calls = append(calls[:i], calls[i+1:]...)
+		if len(calls) > 0 {
+			q.waiting[pod] = calls
+			return true
+		}
+
+		delete(q.waiting, pod)
+
+		for j, p := range q.order {
+			if p == pod {
+				q.order = append(q.order[:j], q.order[j+1:]...)
+				break
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,194 @@
+package cri
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func Test_parseMethodConcurrency(t *testing.T) {
+	t.Parallel()
+
+	limits, err := parseMethodConcurrency([]string{"ListContainers=4", "RunPodSandbox=0"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"ListContainers": 4, "RunPodSandbox": 0}, limits)
+
+	for _, e := range []string{"ListContainers", "=4", "ListContainers=-1", "ListContainers=many"} {
+		_, err = parseMethodConcurrency([]string{e})
+		assert.True(t, errors.Is(err, ErrInvalidMethodConcurrency), e)
+	}
+}
+
+func Test_newCallLimiter(t *testing.T) {
+	t.Parallel()
+
+	l, err := newCallLimiter(&Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, l)
+
+	l, err = newCallLimiter(&Config{LXEGRPCMaxConcurrentCalls: 2, LXEGRPCMethodConcurrency: []string{"ListContainers=0"}})
+	assert.NoError(t, err)
+	assert.Nil(t, l.rate)
+	assert.Nil(t, l.queue("ListContainers"))
+	assert.Equal(t, 2, l.queue("StopContainer").limit)
+	assert.Same(t, l.queue("StopContainer"), l.queue("StopContainer"))
+}
+
+func Test_callPod(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "default/nginx", callPod(&rtApi.RunPodSandboxRequest{
+		Config: &rtApi.PodSandboxConfig{Metadata: &rtApi.PodSandboxMetadata{Namespace: "default", Name: "nginx"}},
+	}))
+	assert.Equal(t, "pod", callPod(&rtApi.CreateContainerRequest{PodSandboxId: "pod"}))
+	assert.Equal(t, "ct", callPod(&rtApi.StopContainerRequest{ContainerId: "ct"}))
+	assert.Equal(t, "", callPod(&rtApi.ListContainersRequest{}))
+}
+
+func Test_fairQueue(t *testing.T) {
+	t.Parallel()
+
+	q := newFairQueue(1)
+	assert.NoError(t, q.acquire(ctx, "running"))
+
+	// pod a queues three calls before b queues one, b takes its turn after the first call of a
+	order := make(chan string, 4)
+
+	for i, pod := range []string{"a", "a", "a", "b"} {
+		pod := pod
+		go func() {
+			assert.NoError(t, q.acquire(ctx, pod))
+			order <- pod
+			q.release()
+		}()
+
+		assert.Eventually(t, func() bool {
+			_, waiting := queuedCalls(q)
+			return waiting == i+1
+		}, time.Second, time.Millisecond)
+	}
+
+	q.release()
+
+	got := []string{}
+	for i := 0; i < 4; i++ {
+		got = append(got, <-order)
+	}
+
+	assert.Equal(t, []string{"a", "b", "a", "a"}, got)
+
+	assert.Eventually(t, func() bool {
+		running, _ := queuedCalls(q)
+		return running == 0
+	}, time.Second, time.Millisecond)
+}
+
+func Test_fairQueue_Cancelled(t *testing.T) {
+	t.Parallel()
+
+	q := newFairQueue(1)
+	assert.NoError(t, q.acquire(ctx, "running"))
+
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	assert.True(t, errors.Is(q.acquire(cctx, "a"), context.DeadlineExceeded))
+	running, waiting := queuedCalls(q)
+	assert.Equal(t, 1, running)
+	assert.Equal(t, 0, waiting)
+	assert.Empty(t, q.order)
+
+	q.release()
+	assert.NoError(t, q.acquire(ctx, "b"))
+
+	running, _ = queuedCalls(q)
+	assert.Equal(t, 1, running)
+}
+
+func TestCallLimiter_intercept(t *testing.T) {
+	t.Parallel()
+
+	l, err := newCallLimiter(&Config{LXEGRPCMaxConcurrentCalls: 1})
+	assert.NoError(t, err)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/runtime.v1alpha2.RuntimeService/StopContainer"}
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	go func() {
+		_, err := l.intercept(ctx, &rtApi.StopContainerRequest{ContainerId: "a"}, info, func(context.Context, interface{}) (interface{}, error) {
+			close(started)
+			<-release
+
+			return nil, nil
+		})
+		assert.NoError(t, err)
+	}()
+
+	<-started
+
+	handled := false
+	handler := func(context.Context, interface{}) (interface{}, error) {
+		handled = true
+		return nil, nil
+	}
+
+	// the other call of the method waits till its deadline
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	_, err = l.intercept(cctx, &rtApi.StopContainerRequest{ContainerId: "b"}, info, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.False(t, handled)
+
+	// health checks are never limited
+	_, err = l.intercept(cctx, &rtApi.StatusRequest{}, &grpc.UnaryServerInfo{FullMethod: "/runtime.v1alpha2.RuntimeService/Status"}, handler)
+	assert.NoError(t, err)
+	assert.True(t, handled)
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		running, waiting := queuedCalls(l.queue("StopContainer"))
+		return running == 0 && waiting == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestCallLimiter_intercept_Rate(t *testing.T) {
+	t.Parallel()
+
+	l, err := newCallLimiter(&Config{LXEGRPCRateLimit: 1, LXEGRPCRateBurst: 1})
+	assert.NoError(t, err)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/runtime.v1alpha2.RuntimeService/ListContainers"}
+	handler := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+
+	_, err = l.intercept(ctx, &rtApi.ListContainersRequest{}, info, handler)
+	assert.NoError(t, err)
+
+	// the next token is only available after a second
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	_, err = l.intercept(cctx, &rtApi.ListContainersRequest{}, info, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+// queuedCalls returns the running and the waiting calls of the queue
+func queuedCalls(q *fairQueue) (int, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	waiting := 0
+	for _, calls := range q.waiting {
+		waiting += len(calls)
+	}
+
+	return q.running, waiting
+}
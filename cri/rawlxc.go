@@ -0,0 +1,89 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"fmt"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/network"
+)
+
+const (
+	// lxcFeatureCgroup2 is reported by LXD if the host uses the unified cgroup hierarchy
+	lxcFeatureCgroup2 = "cgroup2"
+)
+
+// cgroupRawLXC returns the raw.lxc entries of the cpu resources of the container, in the cgroup version of the host
+func (s RuntimeServer) cgroupRawLXC(c *lxf.Container) ([]string, error) {
+	entries := []string{}
+
+	if c.Resources == nil || c.Resources.CPU == nil {
+		return entries, nil
+	}
+
+	cpu := c.Resources.CPU
+	hasShares := cpu.Shares != nil && *cpu.Shares > 0
+
+	if !hasShares && cpu.Mems == "" {
+		return entries, nil
+	}
+
+	unified, err := s.cgroupUnified()
+	if err != nil {
+		return nil, err
+	}
+
+	if hasShares {
+		if unified {
+			entries = append(entries, fmt.Sprintf("lxc.cgroup2.cpu.weight = %d", cpuSharesToWeight(*cpu.Shares)))
+		} else {
+			entries = append(entries, fmt.Sprintf("lxc.cgroup.cpu.shares = %d", *cpu.Shares))
+		}
+	}
+
+	if cpu.Mems != "" {
+		key := "lxc.cgroup.cpuset.mems"
+		if unified {
+			key = "lxc.cgroup2.cpuset.mems"
+		}
+
+		entries = append(entries, key+" = "+cpu.Mems)
+	}
+
+	return entries, nil
+}
+
+// applyContainerRawLXC renders the settings LXD has no config key for into the raw.lxc of the container: the cpu
+// shares, the memory nodes of the cpuset, the oom score adjustment, the pod network namespace, the init process, the
+// tmpfs mounts of a readonly rootfs and the capabilities.
+// The raw.lxc of the container replaces the one of the sandbox profile, so the entries of the profile are repeated
+func (s RuntimeServer) applyContainerRawLXC(c *lxf.Container, sb *lxf.Sandbox) error {
+	entries, err := s.cgroupRawLXC(c)
+	if err != nil {
+		return err
+	}
+
+	if c.OOMScoreAdj != 0 {
+		entries = append(entries, fmt.Sprintf("lxc.proc.oom_score_adj = %d", c.OOMScoreAdj))
+	}
+
+	// the containers of a pod with its own network namespace join it instead of getting their own
+	if netns := network.PodNetns(sb.NetworkConfig.ModeData); netns != "" {
+		entries = append(entries, lxcNamespaceShare+"net = "+netns)
+	}
+
+	entries = append(entries, initRawLXC(c.Init)...)
+	entries = append(entries, s.readonlyRootfsRawLXC(c)...)
+	entries = append(entries, capabilitiesRawLXC(c)...)
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	c.Config[cfgRawLXC] = sb.Config[cfgRawLXC]
+
+	for _, e := range entries {
+		lxf.AppendIfSet(&c.Config, cfgRawLXC, e)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package cri
+
+import (
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/lxc/lxd/shared/api"
+	opencontainers "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeServer_applyContainerRawLXC(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+
+	srv := &api.Server{}
+	srv.Environment.LXCFeatures = map[string]string{lxcFeatureCgroup2: "true"}
+	fakeServer.GetServerReturns(srv, "", nil)
+
+	sb := &lxf.Sandbox{}
+	sb.Config = map[string]string{cfgRawLXC: "lxc.sysctl.net.core.somaxconn = 1024"}
+
+	c := &lxf.Container{}
+	c.Config = map[string]string{}
+
+	err := s.applyContainerRawLXC(c, sb)
+	assert.NoError(t, err)
+	assert.Empty(t, c.Config, "nothing to pin")
+
+	c.Resources = &opencontainers.LinuxResources{CPU: &opencontainers.LinuxCPU{Mems: "0-1"}}
+
+	err = s.applyContainerRawLXC(c, sb)
+	assert.NoError(t, err)
+	assert.Equal(t, "lxc.sysctl.net.core.somaxconn = 1024\nlxc.cgroup2.cpuset.mems = 0-1", c.Config[cfgRawLXC])
+
+	// applying again replaces the previous entry
+	c.Resources.CPU.Mems = "1"
+	srv.Environment.LXCFeatures = nil
+
+	err = s.applyContainerRawLXC(c, sb)
+	assert.NoError(t, err)
+	assert.Equal(t, "lxc.sysctl.net.core.somaxconn = 1024\nlxc.cgroup.cpuset.mems = 1", c.Config[cfgRawLXC])
+
+	c.OOMScoreAdj = 1000
+
+	err = s.applyContainerRawLXC(c, sb)
+	assert.NoError(t, err)
+	assert.Equal(t, "lxc.sysctl.net.core.somaxconn = 1024\nlxc.cgroup.cpuset.mems = 1\nlxc.proc.oom_score_adj = 1000", c.Config[cfgRawLXC])
+}
+
+func TestRuntimeServer_applyContainerRawLXC_PodNetns(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+
+	sb := &lxf.Sandbox{}
+	sb.Config = map[string]string{}
+	sb.NetworkConfig.ModeData = map[string]string{"netns": "/run/netns/sb"}
+
+	c := &lxf.Container{}
+	c.Config = map[string]string{}
+
+	err := s.applyContainerRawLXC(c, sb)
+	assert.NoError(t, err)
+	assert.Equal(t, "lxc.namespace.share.net = /run/netns/sb", c.Config[cfgRawLXC])
+}
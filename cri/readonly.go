@@ -0,0 +1,70 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/device"
+)
+
+var (
+	ErrInvalidReadonlyTmpfs = errors.New("invalid readonly rootfs tmpfs path")
+	// DefaultReadonlyRootfsTmpfs are the paths most images expect to be writable
+	DefaultReadonlyRootfsTmpfs = []string{"/run", "/tmp", "/var/tmp"}
+)
+
+// validateReadonlyTmpfs checks the tmpfs paths of containers with a readonly rootfs
+func validateReadonlyTmpfs(paths []string) error {
+	for _, p := range paths {
+		if !path.IsAbs(p) || path.Clean(p) == "/" || strings.ContainsAny(p, " \t\n") {
+			return fmt.Errorf("%w: %q must be an absolute path below / without whitespace", ErrInvalidReadonlyTmpfs, p)
+		}
+	}
+
+	return nil
+}
+
+// applyReadonlyRootfs mounts the root disk of the container readonly. The container gets its own root disk device on
+// the pool the pod's root disks are on
+func (s RuntimeServer) applyReadonlyRootfs(c *lxf.Container, sb *lxf.Sandbox, annotations map[string]string) error {
+	disk, err := s.rootDisk(sb.RuntimeHandler, annotations, true)
+	if err != nil {
+		return err
+	}
+
+	c.Devices.Upsert(disk)
+
+	return nil
+}
+
+// isReadonlyRootfs checks if the container has its own readonly root disk
+func isReadonlyRootfs(c *lxf.Container) bool {
+	for _, d := range c.Devices {
+		if dd, is := d.(*device.Disk); is && dd.KeyName == rootDiskName {
+			return dd.Readonly
+		}
+	}
+
+	return false
+}
+
+// readonlyRootfsRawLXC returns the raw.lxc entries mounting a tmpfs on every path of a container with a readonly root
+// disk. Paths missing in the image are skipped, they can't be created on the readonly rootfs
+func (s RuntimeServer) readonlyRootfsRawLXC(c *lxf.Container) []string {
+	if !isReadonlyRootfs(c) {
+		return nil
+	}
+
+	entries := []string{}
+
+	for _, p := range s.config().LXEReadonlyRootfsTmpfs {
+		// the target of a mount entry is relative to the rootfs
+		target := strings.TrimPrefix(path.Clean(p), "/")
+		entries = append(entries, fmt.Sprintf("lxc.mount.entry = tmpfs %s tmpfs rw,nosuid,nodev,mode=1777,optional 0 0", target))
+	}
+
+	return entries
+}
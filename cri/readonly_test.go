@@ -0,0 +1,49 @@
+package cri
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/device"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_validateReadonlyTmpfs(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, validateReadonlyTmpfs(DefaultReadonlyRootfsTmpfs))
+
+	for _, p := range []string{"tmp", "/", "/var/../", "/my dir"} {
+		err := validateReadonlyTmpfs([]string{p})
+		assert.True(t, errors.Is(err, ErrInvalidReadonlyTmpfs), p)
+	}
+}
+
+func TestRuntimeServer_applyReadonlyRootfs(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+	s.criConfig.LXEReadonlyRootfsTmpfs = []string{"/tmp", "/run/"}
+
+	sb := &lxf.Sandbox{}
+	sb.Config = map[string]string{}
+
+	c := &lxf.Container{}
+	c.Config = map[string]string{}
+
+	assert.False(t, isReadonlyRootfs(c))
+	assert.Empty(t, s.readonlyRootfsRawLXC(c))
+
+	err := s.applyReadonlyRootfs(c, sb, map[string]string{annotation.StoragePool.Name: "fast"})
+	assert.NoError(t, err)
+	assert.Equal(t, device.Devices{&device.Disk{KeyName: rootDiskName, Path: "/", Pool: "fast", Readonly: true}}, c.Devices)
+	assert.True(t, isReadonlyRootfs(c))
+	assert.Equal(t, "true", toCriStatusResponse(c).Info["readonlyRootfs"])
+
+	err = s.applyContainerRawLXC(c, sb)
+	assert.NoError(t, err)
+	assert.Equal(t, "lxc.mount.entry = tmpfs tmp tmpfs rw,nosuid,nodev,mode=1777,optional 0 0\n"+
+		"lxc.mount.entry = tmpfs run tmpfs rw,nosuid,nodev,mode=1777,optional 0 0", c.Config[cfgRawLXC])
+}
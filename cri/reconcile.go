@@ -0,0 +1,54 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/shared"
+	"github.com/sirupsen/logrus"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+// containerConfigHash returns the hash of everything a container is created from: its config and the annotations of
+// its pod
+func containerConfigHash(req *rtApi.CreateContainerRequest) (string, error) {
+	b, err := json.Marshal(struct {
+		Config      *rtApi.ContainerConfig
+		Annotations map[string]string
+	}{req.GetConfig(), req.GetSandboxConfig().GetAnnotations()})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// reconcileContainer looks for the container left by an interrupted or failed creation of the same container, which
+// has the same id. It's returned to be reused if it was created from the same config and never started, otherwise
+// it's deleted so the container is created again. Nil if there is none to reuse
+func (s RuntimeServer) reconcileContainer(ctx context.Context, log *logrus.Entry, c *lxf.Container) (*lxf.Container, error) {
+	prev, err := s.lxf.GetContainer(c.CreateID())
+	if err != nil {
+		if shared.IsErrNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	log = log.WithField("containerid", prev.ID)
+
+	if prev.ConfigHash == c.ConfigHash && prev.StartedAt.IsZero() {
+		log.Info("reusing container of a previous creation")
+		return prev, nil
+	}
+
+	log.Info("deleting container of a previous creation with a different config")
+
+	return nil, s.deleteContainer(ctx, prev)
+}
@@ -0,0 +1,59 @@
+package cri
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/shared"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func Test_containerConfigHash(t *testing.T) {
+	t.Parallel()
+
+	req := &rtApi.CreateContainerRequest{
+		Config:        &rtApi.ContainerConfig{Metadata: &rtApi.ContainerMetadata{Name: "app"}, Labels: map[string]string{"a": "b", "c": "d"}},
+		SandboxConfig: &rtApi.PodSandboxConfig{Annotations: map[string]string{"x": "y"}},
+	}
+
+	hash, err := containerConfigHash(req)
+	assert.NoError(t, err)
+
+	again, err := containerConfigHash(req)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, again)
+
+	req.SandboxConfig.Annotations["x"] = "z"
+	changed, err := containerConfigHash(req)
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, changed)
+}
+
+func TestRuntimeServer_reconcileContainer(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	log := logrus.NewEntry(logrus.New())
+
+	c := &lxf.Container{ConfigHash: "hash"}
+	c.Profiles = []string{"sandbox"}
+	c.Metadata.Name = "app"
+
+	fake.GetContainerReturns(nil, fmt.Errorf("container %w: %s", shared.NewErrNotFound(), c.CreateID()))
+	prev, err := s.reconcileContainer(context.Background(), log, c)
+	assert.NoError(t, err)
+	assert.Nil(t, prev)
+
+	left := &lxf.Container{ConfigHash: "hash"}
+	left.ID = c.CreateID()
+	fake.GetContainerReturns(left, nil)
+
+	prev, err = s.reconcileContainer(context.Background(), log, c)
+	assert.NoError(t, err)
+	assert.Equal(t, left, prev)
+	assert.Equal(t, c.CreateID(), fake.GetContainerArgsForCall(1))
+}
@@ -0,0 +1,259 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/network"
+	"github.com/automaticserver/lxe/tracing"
+	"github.com/coreos/go-systemd/v22/daemon"
+)
+
+var (
+	ErrInvalidConfig = errors.New("invalid configuration")
+	ErrInvalidOwner  = errors.New("invalid owner")
+)
+
+// reloadableFields are the settings which are applied to the following CRI calls when the config is reloaded
+var reloadableFields = map[string]bool{
+	"LXDProfiles":              true,
+	"LXDTarget":                true,
+	"LXDVolumePools":           true,
+	"LXESysctlAllowlist":       true,
+	"LXEConfigAllowlist":       true,
+	"LXEConfigDenylist":        true,
+	"LXEMemorySwap":            true,
+	"LXEMemoryEnforce":         true,
+	"LXEPodPidsLimit":          true,
+	"LXEReadonlyRootfsTmpfs":   true,
+	"LXEContainerMode":         true,
+	"LXEShiftKubeletVolumes":   true,
+	"LXEImagePolicy":           true,
+	"LXEImageCacheRemote":      true,
+	"LXEValidation":            true,
+	"LXEHooksDir":              true,
+	"LXENodeAllocatableCPU":    true,
+	"LXENodeAllocatableMemory": true,
+}
+
+// networkFields are the settings of the network plugin, which ReloadNetwork applies to new pods
+var networkFields = map[string]bool{
+	"LXENetworkMTU":               true,
+	"LXENetworkDisableTxChecksum": true,
+	"LXEBridgeName":               true,
+	"LXEBridgeDHCPRange":          true,
+	"LXEBridgeVLANs":              true,
+	"LXEBridgeDNSDomain":          true,
+	"CNIConfDir":                  true,
+	"CNIBinDir":                   true,
+	"CNINetnsPath":                true,
+	"CNIOutputTarget":             true,
+	"CNIOutputFile":               true,
+	"CNIPodNetns":                 true,
+	"CNIPodCIDRBridge":            true,
+	"CNIDefaultConf":              true,
+	"CNIWatchConfDir":             true,
+}
+
+// Validate checks the settings which can be checked without LXD, so an invalid config is rejected at startup before
+// anything is set up, and on reload before anything is applied
+func (c *Config) Validate() error {
+	err := c.validate()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+
+	return nil
+}
+
+func (c *Config) validate() error {
+	_, err := newSocketPermissions(c.UnixSocketMode, c.UnixSocketOwner)
+	if err != nil {
+		return err
+	}
+
+	if c.LXENetworkPlugin != NetworkPluginBridge && c.LXENetworkPlugin != NetworkPluginCNI {
+		return fmt.Errorf("%w: %s", ErrUnknownNetworkPlugin, c.LXENetworkPlugin)
+	}
+
+	_, err = network.ParseVLANs(c.LXEBridgeVLANs)
+	if err != nil {
+		return err
+	}
+
+	_, err = parseHandlerPools(c.LXERuntimeHandlerPools)
+	if err != nil {
+		return err
+	}
+
+	_, err = parseMethodConcurrency(c.LXEGRPCMethodConcurrency)
+	if err != nil {
+		return err
+	}
+
+	_, err = lxf.ParseProjectLimits(c.LXDProjectLimits)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = parseAllocatable(c.LXENodeAllocatableCPU, c.LXENodeAllocatableMemory)
+	if err != nil {
+		return err
+	}
+
+	_, err = parseRuntimeHandlers(c.LXERuntimeHandlers)
+	if err != nil {
+		return err
+	}
+
+	err = validateReadonlyTmpfs(c.LXEReadonlyRootfsTmpfs)
+	if err != nil {
+		return err
+	}
+
+	_, err = serviceEnv(c.LXEKubernetesServiceEnv)
+	if err != nil {
+		return err
+	}
+
+	if c.LXEContainerMode != "" {
+		_, err = annotation.ParseContainerMode(c.LXEContainerMode)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = lxf.ValidateNamePrefix(c.LXENamePrefix)
+	if err != nil {
+		return err
+	}
+
+	// the owner names the directory of the network namespaces
+	if strings.Contains(c.LXEOwner, "/") || c.LXEOwner == "." || c.LXEOwner == ".." {
+		return fmt.Errorf("%w: %s must be usable as file name", ErrInvalidOwner, c.LXEOwner)
+	}
+
+	if c.LXEValidation != "" && c.LXEValidation != ValidationStrict && c.LXEValidation != ValidationWarn {
+		return fmt.Errorf("%w: %s", ErrUnknownValidation, c.LXEValidation)
+	}
+
+	if c.LXEShiftMode != "" && c.LXEShiftMode != ShiftModeAuto && c.LXEShiftMode != ShiftModeAlways && c.LXEShiftMode != ShiftModeNever {
+		return fmt.Errorf("%w: %s", ErrUnknownShiftMode, c.LXEShiftMode)
+	}
+
+	if c.LXEEvictionPSIThreshold > 0 && c.LXEEvictionAction != EvictionActionFreeze && c.LXEEvictionAction != EvictionActionStop {
+		return fmt.Errorf("%w: %s", ErrUnknownEvictionAction, c.LXEEvictionAction)
+	}
+
+	if c.LXEImageCacheBindAddr != "" && c.LXEImageCacheDir == "" {
+		return ErrImageCacheDirMissing
+	}
+
+	if (c.LXEMetricsTLSCert == "") != (c.LXEMetricsTLSKey == "") {
+		return ErrMetricsTLSIncomplete
+	}
+
+	if (c.LXEStreamingTLSCert == "") != (c.LXEStreamingTLSKey == "") {
+		return ErrStreamingTLSIncomplete
+	}
+
+	if c.LXETracingEndpoint != "" && (c.LXETracingSampleRatio < 0 || c.LXETracingSampleRatio > 1) {
+		return fmt.Errorf("%w: %v", tracing.ErrInvalidSampleRatio, c.LXETracingSampleRatio)
+	}
+
+	err = validateMemoryConfig(c)
+	if err != nil {
+		return err
+	}
+
+	return validateCgroupDriver(c.LXECgroupDriver)
+}
+
+// mergeReloaded returns the current config with the reloadable settings of the reloaded one, and the names of the
+// settings which changed but require a restart
+func mergeReloaded(current, reloaded *Config) (*Config, []string) {
+	next := *current
+	restart := []string{}
+
+	cur := reflect.ValueOf(current).Elem()
+	rel := reflect.ValueOf(reloaded).Elem()
+	nxt := reflect.ValueOf(&next).Elem()
+
+	for i := 0; i < cur.NumField(); i++ {
+		if reflect.DeepEqual(cur.Field(i).Interface(), rel.Field(i).Interface()) {
+			continue
+		}
+
+		name := cur.Type().Field(i).Name
+
+		switch {
+		case reloadableFields[name]:
+			nxt.Field(i).Set(rel.Field(i))
+		case networkFields[name]:
+			// applied to new pods by ReloadNetwork
+		default:
+			restart = append(restart, name)
+		}
+	}
+
+	sort.Strings(restart)
+
+	return &next, restart
+}
+
+// config returns the current config, its reloadable settings are replaced when LXE reloads its config
+func (s RuntimeServer) config() *Config {
+	if s.reloaded == nil {
+		return s.criConfig
+	}
+
+	return s.reloaded.Load().(*Config) // nolint: forcetypeassert
+}
+
+// Reload validates the reloaded config and applies it: the network plugin is reloaded for new pods and the reloadable
+// settings, like the profiles, allowlists and resource policies, apply to the following CRI calls. Changed settings
+// which require a restart are logged and otherwise ignored. Nothing is applied if the config is invalid
+func (s *Server) Reload(criConfig *Config) error {
+	notify(daemon.SdNotifyReloading)
+	defer notify(daemon.SdNotifyReady)
+
+	err := criConfig.Validate()
+	if err != nil {
+		return err
+	}
+
+	policy, err := loadImagePolicy(criConfig)
+	if err != nil {
+		return err
+	}
+
+	hooks, err := loadHooks(criConfig.LXEHooksDir)
+	if err != nil {
+		return err
+	}
+
+	err = s.ReloadNetwork(criConfig)
+	if err != nil {
+		return err
+	}
+
+	s.runtime.lxf.SetImagePolicy(policy)
+	s.runtime.lxf.SetImageCache(criConfig.LXEImageCacheRemote)
+	s.runtime.loadedHooks.Store(hooks)
+
+	next, restart := mergeReloaded(s.runtime.config(), criConfig)
+	s.runtime.reloaded.Store(next)
+
+	if len(restart) > 0 {
+		log.WithField("settings", restart).Warn("changed settings are only applied after a restart")
+	}
+
+	log.Info("configuration reloaded")
+
+	return nil
+}
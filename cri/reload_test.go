@@ -0,0 +1,131 @@
+package cri
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/tracing"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func validConfig() *Config {
+	return &Config{
+		UnixSocketMode:        "0660",
+		LXENetworkPlugin:      NetworkPluginBridge,
+		LXEShiftMode:          ShiftModeAuto,
+		LXETracingSampleRatio: 1,
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, validConfig().Validate())
+
+	for name, tc := range map[string]struct {
+		change func(c *Config)
+		err    error
+	}{
+		"socket mode":    {func(c *Config) { c.UnixSocketMode = "999" }, ErrInvalidSocketMode},
+		"network plugin": {func(c *Config) { c.LXENetworkPlugin = "none" }, ErrUnknownNetworkPlugin},
+		"shift mode":     {func(c *Config) { c.LXEShiftMode = "sometimes" }, ErrUnknownShiftMode},
+		"validation":     {func(c *Config) { c.LXEValidation = "lenient" }, ErrUnknownValidation},
+		"name prefix":    {func(c *Config) { c.LXENamePrefix = "K8s_" }, lxf.ErrInvalidNamePrefix},
+		"owner":          {func(c *Config) { c.LXEOwner = "../node1" }, ErrInvalidOwner},
+		"project limits": {func(c *Config) { c.LXDProjectLimits = []string{"containers=10"} }, lxf.ErrInvalidProjectLimit},
+		"eviction":       {func(c *Config) { c.LXEEvictionPSIThreshold = 50; c.LXEEvictionAction = "kill" }, ErrUnknownEvictionAction},
+		"metrics tls":    {func(c *Config) { c.LXEMetricsTLSCert = "cert.pem" }, ErrMetricsTLSIncomplete},
+		"sample ratio":   {func(c *Config) { c.LXETracingEndpoint = "localhost:4318"; c.LXETracingSampleRatio = 2 }, tracing.ErrInvalidSampleRatio},
+		"allocatable":    {func(c *Config) { c.LXENodeAllocatableMemory = "lots" }, resource.ErrFormatWrong},
+		"image cache":    {func(c *Config) { c.LXEImageCacheBindAddr = ":9102" }, ErrImageCacheDirMissing},
+	} {
+		c := validConfig()
+		tc.change(c)
+
+		err := c.Validate()
+		assert.True(t, errors.Is(err, ErrInvalidConfig), name)
+		assert.True(t, errors.Is(err, tc.err), name)
+	}
+}
+
+func Test_mergeReloaded(t *testing.T) {
+	t.Parallel()
+
+	current := validConfig()
+	reloaded := validConfig()
+	reloaded.LXDProfiles = []string{"default", "gpu"}
+	reloaded.LXEPodPidsLimit = 512
+	reloaded.LXEBridgeName = "otherbr0"
+	reloaded.LXDSocket = "/run/lxd.socket"
+	reloaded.LXEStreamingBindAddr = ":44125"
+
+	next, restart := mergeReloaded(current, reloaded)
+	assert.Equal(t, []string{"default", "gpu"}, next.LXDProfiles)
+	assert.Equal(t, int64(512), next.LXEPodPidsLimit)
+	assert.Empty(t, next.LXEBridgeName, "the network plugin is reloaded separately")
+	assert.Empty(t, next.LXDSocket)
+	assert.Equal(t, []string{"LXDSocket", "LXEStreamingBindAddr"}, restart)
+	assert.Empty(t, current.LXDProfiles, "the current config is unchanged")
+}
+
+func TestServer_Reload(t *testing.T) {
+	t.Parallel()
+
+	conf := validConfig()
+	s, _, _ := testServer(conf)
+	rt, _, _ := testRuntimeServer()
+	rt.reloaded = &atomic.Value{}
+	rt.reloaded.Store(conf)
+	s.runtime = rt
+
+	reloaded := validConfig()
+	reloaded.LXEContainerMode = annotation.ContainerModeApplication
+
+	err := s.Reload(reloaded)
+	assert.NoError(t, err)
+
+	mode, err := rt.containerMode(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, annotation.ContainerModeApplication, mode)
+
+	reloaded = validConfig()
+	reloaded.LXEShiftMode = "sometimes"
+
+	err = s.Reload(reloaded)
+	assert.True(t, errors.Is(err, ErrInvalidConfig))
+	assert.Equal(t, annotation.ContainerModeApplication, rt.config().LXEContainerMode, "an invalid config isn't applied")
+}
+
+func TestServer_Reload_ImagePolicy(t *testing.T) {
+	t.Parallel()
+
+	conf := validConfig()
+	s, _, _ := testServer(conf)
+	rt, fake, _ := testRuntimeServer()
+	rt.reloaded = &atomic.Value{}
+	rt.reloaded.Store(conf)
+	s.runtime = rt
+
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("allow:\n- images/*\n"), 0644))
+
+	reloaded := validConfig()
+	reloaded.LXEImagePolicy = path
+
+	err := s.Reload(reloaded)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.SetImagePolicyCallCount())
+	assert.Equal(t, &lxf.ImagePolicy{Allow: []string{"images/*"}}, fake.SetImagePolicyArgsForCall(0))
+
+	assert.NoError(t, os.WriteFile(path, []byte("allow: images\n"), 0644))
+
+	err = s.Reload(reloaded)
+	assert.Error(t, err)
+	assert.Equal(t, 1, fake.SetImagePolicyCallCount(), "an invalid policy isn't applied")
+}
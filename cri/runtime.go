@@ -1,25 +1,27 @@
 package cri // import "github.com/automaticserver/lxe/cri"
 
 import (
-	"bytes"
 	"errors"
-	"io/ioutil"
-	"path"
+	"fmt"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/automaticserver/lxe/annotation"
 	"github.com/automaticserver/lxe/cli/version"
 	"github.com/automaticserver/lxe/lxf"
 	"github.com/automaticserver/lxe/lxf/device"
+	"github.com/automaticserver/lxe/lxf/lxo"
 	"github.com/automaticserver/lxe/network"
+	"github.com/automaticserver/lxe/network/netns"
 	"github.com/automaticserver/lxe/shared"
 	"github.com/lxc/lxd/lxc/config"
-	opencontainers "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 	utilNet "k8s.io/apimachinery/pkg/util/net"
 	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
-	"k8s.io/kubernetes/pkg/kubelet/util/ioutils"
 )
 
 const (
@@ -38,7 +40,33 @@ type RuntimeServer struct {
 	stream    *streamService
 	lxdConfig *config.Config
 	criConfig *Config
-	network   network.Plugin
+	networks  *networkPlugins
+	// shiftSupported is true if LXD supports shifting of disk devices
+	shiftSupported bool
+	// handlerPools maps runtime handlers to the storage pool of their root disks
+	handlerPools map[string]string
+	// runtimeHandlers are the presets of the runtime handlers by name
+	runtimeHandlers map[string]*runtimeHandler
+	// cgroupMode is the cgroup mode of the host, empty if unknown
+	cgroupMode string
+	// serviceEnv are the environment variables of the kubernetes service added to every container
+	serviceEnv map[string]string
+	// reloaded holds the current *Config, which has the reloadable settings replaced on reload
+	reloaded *atomic.Value
+	// auditLog is where the lifecycle actions are logged to, nil if disabled
+	auditLog *auditLog
+	// journal is where the calls changing pods and containers are journaled to, nil if disabled
+	journal *journal
+	// loadedHooks holds the []*hook run at the lifecycle stages, they're replaced on reload
+	loadedHooks *atomic.Value
+	// quota holds the latest *quotaReport
+	quota *atomic.Value
+	// events passes the container lifecycle events to the subscribers of the admin api
+	events *containerEvents
+	// stopping is closed on shutdown, the background loops return then
+	stopping chan struct{}
+	// loops are the running background loops
+	loops *sync.WaitGroup
 }
 
 // NewRuntimeServer returns a new RuntimeServer backed by LXD
@@ -46,22 +74,109 @@ func NewRuntimeServer(criConfig *Config, lxf lxf.Client, network network.Plugin)
 	var err error
 
 	runtime := RuntimeServer{
-		criConfig: criConfig,
-		network:   network,
+		criConfig:   criConfig,
+		networks:    newNetworkPlugins(networkGeneration(criConfig), network),
+		reloaded:    &atomic.Value{},
+		loadedHooks: &atomic.Value{},
+		quota:       &atomic.Value{},
+		events:      newContainerEvents(),
+		stopping:    make(chan struct{}),
+		loops:       &sync.WaitGroup{},
 	}
 
+	runtime.reloaded.Store(criConfig)
+
 	configPath, err := getLXDConfigPath(criConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	runtime.lxdConfig, err = config.LoadConfig(configPath)
+	runtime.lxdConfig, err = loadLXDConfig(criConfig, configPath)
 	if err != nil {
 		return nil, err
 	}
 
 	runtime.lxf = lxf
 
+	runtime.handlerPools, err = parseHandlerPools(criConfig.LXERuntimeHandlerPools)
+	if err != nil {
+		return nil, err
+	}
+
+	runtime.runtimeHandlers, err = parseRuntimeHandlers(criConfig.LXERuntimeHandlers)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, h := range runtime.runtimeHandlers {
+		if h.Pool != "" {
+			runtime.handlerPools[name] = h.Pool
+		}
+	}
+
+	runtime.serviceEnv, err = serviceEnv(criConfig.LXEKubernetesServiceEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	if criConfig.LXEContainerMode != "" {
+		_, err = annotation.ParseContainerMode(criConfig.LXEContainerMode)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if criConfig.LXEEvictionPSIThreshold > 0 && criConfig.LXEEvictionAction != EvictionActionFreeze && criConfig.LXEEvictionAction != EvictionActionStop {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEvictionAction, criConfig.LXEEvictionAction)
+	}
+
+	err = validateMemoryConfig(criConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateCgroupDriver(criConfig.LXECgroupDriver)
+	if err != nil {
+		return nil, err
+	}
+
+	runtime.cgroupMode, err = detectCgroupMode(cgroupRoot)
+	if err != nil {
+		log.WithError(err).Warn("unable to detect the cgroup mode, asking LXD instead")
+	} else {
+		log.WithField("mode", runtime.cgroupMode).Info("detected cgroup mode")
+	}
+
+	if criConfig.LXEAuditLog != "" {
+		runtime.auditLog, err = newAuditLog(criConfig.LXEAuditLog, int64(criConfig.LXEAuditLogMaxSize)<<20, criConfig.LXEAuditLogMaxBackups)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if criConfig.LXEJournalFile != "" {
+		runtime.journal, err = openJournal(criConfig.LXEJournalFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hooks, err := loadHooks(criConfig.LXEHooksDir)
+	if err != nil {
+		return nil, err
+	}
+
+	runtime.loadedHooks.Store(hooks)
+
+	if criConfig.LXEShiftMode == ShiftModeAuto {
+		runtime.shiftSupported = runtime.detectShift()
+		if runtime.shiftSupported {
+			log.Info("detected shiftfs or idmapped mount support")
+		} else {
+			log.Warn("unable to shift mounts of unprivileged containers, LXD reports neither shiftfs nor idmapped mount support, files owned by host users might not be accessible")
+		}
+	}
+
 	return &runtime, nil
 }
 
@@ -96,7 +211,19 @@ func (s RuntimeServer) RunPodSandbox(ctx context.Context, req *rtApi.RunPodSandb
 	})
 	log.Info("run pod")
 
-	var err error
+	warnings, err := annotation.Validate(req.GetConfig().GetAnnotations())
+	if err != nil {
+		return nil, AnnErr(log, err, "invalid annotations")
+	}
+
+	for _, w := range warnings {
+		log.Warn(w)
+	}
+
+	err = validatePodConfig(req.GetConfig()).result(log, s.config().LXEValidation)
+	if err != nil {
+		return nil, AnnErr(log, err, "invalid pod config")
+	}
 
 	sb := s.lxf.NewSandbox()
 
@@ -115,6 +242,7 @@ func (s RuntimeServer) RunPodSandbox(ctx context.Context, req *rtApi.RunPodSandb
 	if req.GetConfig().GetDnsConfig() != nil {
 		sb.NetworkConfig.Nameservers = req.GetConfig().GetDnsConfig().GetServers()
 		sb.NetworkConfig.Searches = req.GetConfig().GetDnsConfig().GetSearches()
+		sb.NetworkConfig.Options = req.GetConfig().GetDnsConfig().GetOptions()
 	}
 
 	// Find out which network mode should be used
@@ -181,12 +309,27 @@ func (s RuntimeServer) RunPodSandbox(ctx context.Context, req *rtApi.RunPodSandb
 		}
 	}
 
+	handler, err := s.runtimeHandler(req.GetRuntimeHandler())
+	if err != nil {
+		return nil, AnnErr(log, err, "invalid runtime handler")
+	}
+
+	sb.RuntimeHandler = req.GetRuntimeHandler()
+
+	readonlyRootfs := false
+
 	// TODO: Refactor...
 	if req.Config.Linux != nil { // nolint: nestif
 		lxf.SetIfSet(&sb.Config, "user.linux.cgroup_parent", req.Config.Linux.CgroupParent)
 
-		for key, value := range req.Config.Linux.Sysctls {
-			sb.Config["user.linux.sysctls."+key] = value
+		_, err = s.cgroupParentDir(req.Config.Linux.CgroupParent)
+		if err != nil {
+			return nil, AnnErr(log, err, "invalid cgroup parent")
+		}
+
+		err = s.applySysctls(sb, req.Config.Linux.Sysctls)
+		if err != nil {
+			return nil, AnnErr(log, err, "invalid sysctls")
 		}
 
 		if req.Config.Linux.SecurityContext != nil {
@@ -203,14 +346,7 @@ func (s RuntimeServer) RunPodSandbox(ctx context.Context, req *rtApi.RunPodSandb
 				sb.Config[nsi+".pid"] = nameSpaceOptionToString(nso.Pid)
 			}
 
-			if req.Config.Linux.SecurityContext.ReadonlyRootfs {
-				sb.Devices.Upsert(&device.Disk{
-					Path:     "/",
-					Readonly: true,
-					// TODO magic constant, and also, is it always default?
-					Pool: "default",
-				})
-			}
+			readonlyRootfs = req.Config.Linux.SecurityContext.ReadonlyRootfs
 
 			if req.Config.Linux.SecurityContext.RunAsUser != nil {
 				sb.Config["user.linux.security_context.run_as_user"] =
@@ -231,21 +367,69 @@ func (s RuntimeServer) RunPodSandbox(ctx context.Context, req *rtApi.RunPodSandb
 		}
 	}
 
+	handler.apply(sb)
+
+	rootfs, err := s.rootDisk(req.GetRuntimeHandler(), req.GetConfig().GetAnnotations(), readonlyRootfs)
+	if err != nil {
+		return nil, AnnErr(log, err, "invalid root disk")
+	}
+
+	if rootfs != nil {
+		sb.Devices.Upsert(rootfs)
+	}
+
+	s.applyMemoryEnforcement(sb, req.GetConfig().GetAnnotations())
+	s.applyPidsLimit(sb, req.GetConfig().GetAnnotations())
+
+	err = s.applyTimeAnnotations(sb, req.GetConfig().GetAnnotations())
+	if err != nil {
+		return nil, AnnErr(log, err, "invalid time annotations")
+	}
+
+	err = s.applyNicAnnotations(sb)
+	if err != nil {
+		return nil, AnnErr(log, err, "invalid nic annotations")
+	}
+
+	// applied last, so the allowlist decides if annotations may overwrite what was derived from the pod spec
+	err = s.applyConfigAnnotations(sb.Config, sb.Annotations)
+	if err != nil {
+		return nil, AnnErr(log, err, "invalid config annotations")
+	}
+
+	if sb.NetworkConfig.Mode != lxf.NetworkHost {
+		sb.NetworkConfig.Generation, _ = s.networks.Current()
+	}
+
+	_, err = s.runHooks(ctx, log, HookSandboxPreCreate, &HookState{
+		Labels:        sb.Labels,
+		Annotations:   sb.Annotations,
+		SandboxConfig: req.GetConfig(),
+	})
+	if err != nil {
+		return nil, AnnErr(log, err, "pod rejected by hook")
+	}
+
+	ctx, call := s.journalBegin(ctx, &journalEntry{Call: journalRunPod, Pod: &sb.Metadata})
+	defer call.end()
+
 	err = sb.Apply()
 	if err != nil {
 		return nil, AnnErr(log, err, "failed to create pod")
 	}
 
+	call.setObject(sb.ID)
+
 	log = log.WithField("podid", sb.ID)
 
 	// create network
 	if sb.NetworkConfig.Mode != lxf.NetworkHost { // nolint: nestif
-		podNet, err := s.network.PodNetwork(sb.ID, sb.Annotations)
+		podNet, err := s.podNetwork(sb)
 		if err != nil {
 			return nil, AnnErr(log, err, "can't enter pod network context")
 		}
 
-		res, err := podNet.WhenCreated(ctx, &network.Properties{})
+		res, err := podNet.WhenCreated(ctx, &network.Properties{Namespace: sb.Metadata.Namespace, Name: sb.Metadata.Name})
 		if err != nil {
 			return nil, AnnErr(log, err, "can't create pod network")
 		}
@@ -272,6 +456,13 @@ func (s RuntimeServer) RunPodSandbox(ctx context.Context, req *rtApi.RunPodSandb
 		}
 	}
 
+	s.runHooksLogged(ctx, log, HookSandboxPostStart, &HookState{
+		ID:          sb.ID,
+		SandboxID:   sb.ID,
+		Labels:      sb.Labels,
+		Annotations: sb.Annotations,
+	})
+
 	log.Info("run pod successful")
 
 	return &rtApi.RunPodSandboxResponse{PodSandboxId: sb.ID}, nil
@@ -296,7 +487,20 @@ func (s RuntimeServer) StopPodSandbox(ctx context.Context, req *rtApi.StopPodSan
 		return nil, AnnErr(log, err, "unable to get pod")
 	}
 
-	err = s.stopContainers(sb)
+	ctx, call := s.journalBegin(ctx, &journalEntry{Call: journalStopPod, Object: sb.ID})
+	defer call.end()
+
+	// kubelet stops pods repeatedly, the hooks only run when the pod is stopped
+	if sb.State == lxf.SandboxReady {
+		s.runHooksLogged(ctx, log, HookSandboxPreStop, &HookState{
+			ID:          sb.ID,
+			SandboxID:   sb.ID,
+			Labels:      sb.Labels,
+			Annotations: sb.Annotations,
+		})
+	}
+
+	err = s.stopContainers(ctx, sb)
 	if err != nil {
 		return nil, AnnErr(log, err, "unable to stop containers")
 	}
@@ -308,7 +512,7 @@ func (s RuntimeServer) StopPodSandbox(ctx context.Context, req *rtApi.StopPodSan
 
 	// Stop networking
 	if sb.NetworkConfig.Mode != lxf.NetworkHost {
-		netw, err := s.network.PodNetwork(sb.ID, sb.Annotations)
+		netw, err := s.podNetwork(sb)
 		if err == nil { // force cleanup, we don't care about error, but only enter if there's no error
 			_ = netw.WhenStopped(ctx, &network.Properties{Data: sb.NetworkConfig.ModeData})
 		}
@@ -335,7 +539,10 @@ func (s RuntimeServer) RemovePodSandbox(ctx context.Context, req *rtApi.RemovePo
 		return nil, AnnErr(log, err, "unable to get pod")
 	}
 
-	err = s.stopContainers(sb)
+	ctx, call := s.journalBegin(ctx, &journalEntry{Call: journalRemovePod, Object: sb.ID})
+	defer call.end()
+
+	err = s.stopContainers(ctx, sb)
 	if err != nil {
 		return nil, AnnErr(log, err, "unable to stop containers")
 	}
@@ -350,11 +557,28 @@ func (s RuntimeServer) RemovePodSandbox(ctx context.Context, req *rtApi.RemovePo
 		return nil, AnnErr(log, err, "unable to delete pod")
 	}
 
+	err = s.deleteScratchVolumes(sb.ID)
+	if err != nil {
+		return nil, AnnErr(log, err, "unable to delete scratch volumes")
+	}
+
 	// Delete networking
 	if sb.NetworkConfig.Mode != lxf.NetworkHost {
-		netw, err := s.network.PodNetwork(sb.ID, sb.Annotations)
+		netw, err := s.podNetwork(sb)
 		if err == nil { // we don't care about error, but only enter if there's no error
-			_ = netw.WhenDeleted(ctx, &network.Properties{Data: sb.NetworkConfig.ModeData})
+			err = netw.WhenDeleted(ctx, &network.Properties{Data: sb.NetworkConfig.ModeData})
+		}
+
+		if err != nil {
+			log.WithError(err).Warn("unable to tear down pod network")
+		}
+
+		// the pod network namespace must not leak, even if the network plugin isn't usable anymore
+		if path := network.PodNetns(sb.NetworkConfig.ModeData); path != "" {
+			err = netns.Remove(path)
+			if err != nil {
+				log.WithError(err).Warn("unable to remove pod network namespace")
+			}
 		}
 	}
 
@@ -363,7 +587,8 @@ func (s RuntimeServer) RemovePodSandbox(ctx context.Context, req *rtApi.RemovePo
 	return &rtApi.RemovePodSandboxResponse{}, nil
 }
 
-// PodSandboxStatus returns the status of the PodSandbox. If the PodSandbox is not present, returns an error.
+// PodSandboxStatus returns the status of the PodSandbox. If the PodSandbox is not present, returns an error. The verbose
+// response contains the counters of the pod's network interfaces
 func (s RuntimeServer) PodSandboxStatus(ctx context.Context, req *rtApi.PodSandboxStatusRequest) (*rtApi.PodSandboxStatusResponse, error) {
 	log := log.WithContext(ctx).WithField("podid", req.GetPodSandboxId())
 
@@ -381,11 +606,12 @@ func (s RuntimeServer) PodSandboxStatus(ctx context.Context, req *rtApi.PodSandb
 				Namespace: sb.Metadata.Namespace,
 				Uid:       sb.Metadata.UID,
 			},
-			Linux:       &rtApi.LinuxPodSandboxStatus{},
-			Labels:      sb.Labels,
-			Annotations: sb.Annotations,
-			CreatedAt:   sb.CreatedAt.UnixNano(),
-			State:       stateSandboxAsCri(sb.State),
+			Linux:          &rtApi.LinuxPodSandboxStatus{},
+			Labels:         sb.Labels,
+			Annotations:    sb.Annotations,
+			CreatedAt:      lxf.UnixNano(sb.CreatedAt),
+			State:          stateSandboxAsCri(sb.State),
+			RuntimeHandler: sb.RuntimeHandler,
 			Network: &rtApi.PodSandboxNetworkStatus{
 				Ip: "",
 			},
@@ -416,6 +642,26 @@ func (s RuntimeServer) PodSandboxStatus(ctx context.Context, req *rtApi.PodSandb
 		response.Status.Network.Ip = ip
 	}
 
+	if req.GetVerbose() {
+		response.Info, err = s.podNetworkInfo(sb)
+		if err != nil {
+			log.WithError(err).Warn("unable to get network statistics")
+		}
+
+		overhead, err := s.podOverheadInfo(sb)
+		if err != nil {
+			log.WithError(err).Warn("unable to get pod overhead")
+		}
+
+		for k, v := range overhead {
+			if response.Info == nil {
+				response.Info = map[string]string{}
+			}
+
+			response.Info[k] = v
+		}
+	}
+
 	return response, nil
 }
 
@@ -437,7 +683,7 @@ func (s RuntimeServer) getInetAddress(ctx context.Context, sb *lxf.Sandbox) stri
 	case lxf.NetworkBridged:
 		fallthrough
 	case lxf.NetworkCNI:
-		podNet, err := s.network.PodNetwork(sb.ID, sb.Annotations)
+		podNet, err := s.podNetwork(sb)
 		if err != nil {
 			log.WithError(err).Error("Couldn't get cni pod network")
 			return ""
@@ -482,7 +728,12 @@ func (s RuntimeServer) getInetAddress(ctx context.Context, sb *lxf.Sandbox) stri
 func (s RuntimeServer) ListPodSandbox(ctx context.Context, req *rtApi.ListPodSandboxRequest) (*rtApi.ListPodSandboxResponse, error) {
 	log := log.WithContext(ctx).WithField("filter", req.GetFilter().String())
 
-	sandboxes, err := s.lxf.ListSandboxes()
+	filter := req.GetFilter()
+
+	sandboxes, err := s.lxf.FilterSandboxes(lxf.SandboxFilter{
+		ID:     filter.GetId(),
+		Labels: filter.GetLabelSelector(),
+	})
 	if err != nil {
 		return nil, AnnErr(log, err, "unable to list pods")
 	}
@@ -490,34 +741,25 @@ func (s RuntimeServer) ListPodSandbox(ctx context.Context, req *rtApi.ListPodSan
 	response := &rtApi.ListPodSandboxResponse{}
 
 	for _, sb := range sandboxes {
-		if req.GetFilter() != nil {
-			filter := req.GetFilter()
-			if filter.GetId() != "" && filter.GetId() != sb.ID {
-				continue
-			}
-
-			if filter.GetState() != nil && filter.GetState().GetState() != stateSandboxAsCri(sb.State) {
-				continue
-			}
-
-			if !CompareFilterMap(sb.Labels, filter.GetLabelSelector()) {
-				continue
-			}
+		// the state is only known once converted
+		if filter.GetState() != nil && filter.GetState().GetState() != stateSandboxAsCri(sb.State) {
+			continue
 		}
 
 		// TODO: toSandboxCRI()
 		pod := rtApi.PodSandbox{
 			Id:        sb.ID,
-			CreatedAt: sb.CreatedAt.UnixNano(),
+			CreatedAt: lxf.UnixNano(sb.CreatedAt),
 			Metadata: &rtApi.PodSandboxMetadata{
 				Attempt:   sb.Metadata.Attempt,
 				Name:      sb.Metadata.Name,
 				Namespace: sb.Metadata.Namespace,
 				Uid:       sb.Metadata.UID,
 			},
-			State:       stateSandboxAsCri(sb.State),
-			Labels:      sb.Labels,
-			Annotations: sb.Annotations,
+			State:          stateSandboxAsCri(sb.State),
+			Labels:         sb.Labels,
+			Annotations:    sb.Annotations,
+			RuntimeHandler: sb.RuntimeHandler,
 		}
 		response.Items = append(response.Items, &pod)
 	}
@@ -534,9 +776,27 @@ func (s RuntimeServer) CreateContainer(ctx context.Context, req *rtApi.CreateCon
 	})
 	log.Info("create container")
 
-	var err error
+	err := validateContainerConfig(req.GetConfig()).result(log, s.config().LXEValidation)
+	if err != nil {
+		return nil, AnnErr(log, err, "invalid container config")
+	}
+
+	c, adopted, err := s.adoptContainer(ctx, req)
+	if err != nil {
+		return nil, AnnErr(log, err, "unable to adopt container")
+	}
 
-	c := s.lxf.NewContainer(req.GetPodSandboxId(), s.criConfig.LXDProfiles...)
+	if adopted {
+		log.WithField("containerid", c.ID).Info("adopting container")
+	} else {
+		profiles, err := s.sandboxProfiles(req.GetPodSandboxId())
+		if err != nil {
+			return nil, AnnErr(log, err, "unable to find sandbox")
+		}
+
+		c = s.lxf.NewContainer(req.GetPodSandboxId(), profiles...)
+		c.Image = req.GetConfig().GetImage().GetImage()
+	}
 
 	c.Labels = req.GetConfig().GetLabels()
 	c.Annotations = req.GetConfig().GetAnnotations()
@@ -546,26 +806,61 @@ func (s RuntimeServer) CreateContainer(ctx context.Context, req *rtApi.CreateCon
 		Name:    meta.GetName(),
 	}
 	c.LogPath = req.GetConfig().GetLogPath()
-	c.Image = req.GetConfig().GetImage().GetImage()
+
+	privileged := req.GetConfig().GetLinux().GetSecurityContext().GetPrivileged()
+
+	sb, err := c.Sandbox()
+	if err != nil {
+		return nil, AnnErr(log, err, "unable to find sandbox")
+	}
+
+	if h, has := s.runtimeHandlers[sb.RuntimeHandler]; has && h.Privileged {
+		privileged = true
+	}
 
 	for _, mnt := range req.GetConfig().GetMounts() {
-		hostPath := mnt.GetHostPath()
-		containerPath := mnt.GetContainerPath()
-		// cannot use /var/run as most distros symlink that to /run and lxd doesn't like mounts there because of that
-		if strings.HasPrefix(containerPath, "/var/run") {
-			containerPath = path.Join("/run", strings.TrimPrefix(containerPath, "/var/run"))
-		}
-		// cannot use /run as most distros mount a tmpfs on top of that so mounts from lxd are not visible in the container
-		if strings.HasPrefix(containerPath, "/run") {
-			containerPath = path.Join("/mnt", strings.TrimPrefix(containerPath, "/run"))
-		}
-
-		c.Devices.Upsert(&device.Disk{
-			Path:     containerPath,
-			Source:   hostPath,
-			Readonly: mnt.GetReadonly(),
-			Optional: false,
-		})
+		// LXE writes the hosts file of pods with their own network when the container starts, only keep the host aliases
+		if isHostsMount(mnt) && sb.NetworkConfig.Mode != lxf.NetworkHost {
+			c.HostAliases, err = readHostAliases(mnt.GetHostPath())
+			if err != nil {
+				log.WithError(err).WithField("hostpath", mnt.GetHostPath()).Warn("unable to read host aliases")
+			}
+
+			continue
+		}
+
+		if mnt.GetSelinuxRelabel() {
+			// LXD has no option to relabel the source, as most hosts running LXD use AppArmor just ignore that request
+			log.WithField("hostpath", mnt.GetHostPath()).Debug("selinux relabel requested but not supported, ignoring")
+		}
+
+		var (
+			disk    *device.Disk
+			scratch bool
+		)
+
+		disk, scratch, err = s.scratchDisk(req.GetPodSandboxId(), req.GetSandboxConfig().GetMetadata().GetNamespace(), mnt)
+		if err != nil {
+			return nil, AnnErr(log, err, "unable to place emptyDir on scratch pool")
+		}
+
+		if !scratch {
+			disk = toLXDDisk(mnt)
+
+			// unprivileged containers can't read files owned by host users without shifting
+			s.applyShift(disk, privileged)
+		}
+
+		c.Devices.Upsert(disk)
+	}
+
+	volumes, err := s.attachVolumes(req.GetSandboxConfig().GetMetadata().GetNamespace(), req.GetSandboxConfig().GetAnnotations())
+	if err != nil {
+		return nil, AnnErr(log, err, "unable to attach volumes")
+	}
+
+	for _, vol := range volumes {
+		c.Devices.Upsert(vol)
 	}
 
 	for _, dev := range req.GetConfig().GetDevices() {
@@ -575,7 +870,26 @@ func (s RuntimeServer) CreateContainer(ctx context.Context, req *rtApi.CreateCon
 		})
 	}
 
-	c.Privileged = req.GetConfig().GetLinux().GetSecurityContext().GetPrivileged()
+	c.Privileged = privileged
+
+	if req.GetConfig().GetLinux().GetSecurityContext().GetReadonlyRootfs() {
+		err = s.applyReadonlyRootfs(c, sb, req.GetSandboxConfig().GetAnnotations())
+		if err != nil {
+			return nil, AnnErr(log, err, "invalid root disk")
+		}
+	}
+
+	c.Capabilities, err = toContainerCapabilities(req.GetConfig().GetLinux().GetSecurityContext().GetCapabilities())
+	if err != nil {
+		return nil, AnnErr(log, err, "invalid capabilities")
+	}
+
+	c.Namespaces = toNamespaces(req.GetConfig().GetLinux().GetSecurityContext().GetNamespaceOptions())
+
+	c.Target, err = s.clusterTarget(req.GetPodSandboxId(), req.GetSandboxConfig().GetAnnotations())
+	if err != nil {
+		return nil, AnnErr(log, err, "unable to determine cluster member")
+	}
 
 	// get metadata & cloud-init if defined
 	for _, env := range req.GetConfig().GetEnvs() {
@@ -587,42 +901,128 @@ func (s RuntimeServer) CreateContainer(ctx context.Context, req *rtApi.CreateCon
 		case env.GetKey() == "network-config":
 			c.CloudInitNetworkConfig = env.GetValue()
 		default:
-			c.Environment[env.GetKey()] = env.GetValue()
+			err = validateEnvKey(env.GetKey())
+			if err != nil {
+				return nil, AnnErr(log, err, "invalid environment variable")
+			}
+
+			c.Environment[env.GetKey()], err = escapeEnvValue(env.GetKey(), env.GetValue())
+			if err != nil {
+				return nil, AnnErr(log, err, "invalid environment variable")
+			}
 		}
 	}
 
+	err = applyCloudInit(c, req.GetSandboxConfig().GetAnnotations(), req.GetConfig().GetMounts())
+	if err != nil {
+		return nil, AnnErr(log, err, "invalid cloud-init annotations")
+	}
+
+	s.applyServiceEnv(c.Environment)
+
+	c.Init, err = toContainerInit(req.GetConfig())
+	if err != nil {
+		return nil, AnnErr(log, err, "invalid command")
+	}
+
+	mode, err := s.containerMode(req.GetSandboxConfig().GetAnnotations())
+	if err != nil {
+		return nil, AnnErr(log, err, "invalid container mode")
+	}
+
+	if mode == annotation.ContainerModeApplication {
+		if c.Init == nil {
+			return nil, AnnErr(log, ErrCommandRequired, "invalid container mode")
+		}
+
+		c.Init.Application = true
+	}
+
+	applyInitEnv(c)
+
 	// append other envs below metadata
 	if c.CloudInitMetaData != "" && len(c.Environment) > 0 {
 		c.CloudInitMetaData += "\n"
 	}
 
 	// process limits
-	resrc := req.GetConfig().GetLinux().GetResources()
-	if resrc != nil {
-		c.Resources = &opencontainers.LinuxResources{}
-		c.Resources.CPU = &opencontainers.LinuxCPU{}
-		c.Resources.Memory = &opencontainers.LinuxMemory{}
-		shares := uint64(resrc.CpuShares)
-		c.Resources.CPU.Shares = &shares
-		c.Resources.CPU.Quota = &resrc.CpuQuota
-		period := uint64(resrc.CpuPeriod)
-		c.Resources.CPU.Period = &period
-		c.Resources.Memory.Limit = &resrc.MemoryLimitInBytes
+	c.Resources = withOverhead(toResources(req.GetConfig().GetLinux().GetResources()), s.podOverhead(sb))
+	c.OOMScoreAdj = req.GetConfig().GetLinux().GetResources().GetOomScoreAdj()
+
+	err = s.applyNUMANodes(c, req.GetSandboxConfig().GetAnnotations())
+	if err != nil {
+		return nil, AnnErr(log, err, "unable to place container on numa nodes")
 	}
 
-	err = c.Apply()
+	err = s.applyContainerRawLXC(c, sb)
 	if err != nil {
-		return nil, AnnErr(log, err, "unable to create container")
+		return nil, AnnErr(log, err, "unable to render raw.lxc")
 	}
 
-	sb, err := c.Sandbox()
+	err = s.applyUlimits(c, sb, req.GetSandboxConfig().GetAnnotations())
 	if err != nil {
-		return nil, AnnErr(log, err, "unable to find sandbox")
+		return nil, AnnErr(log, err, "invalid ulimit annotations")
+	}
+
+	err = s.applyConfigAnnotations(c.Config, c.Annotations)
+	if err != nil {
+		return nil, AnnErr(log, err, "invalid config annotations")
+	}
+
+	var prev *lxf.Container
+
+	// an adopted container existed before, it's never rolled back
+	if !adopted {
+		var call *journalCall
+
+		ctx, call = s.journalBegin(ctx, &journalEntry{Call: journalCreateContainer, Object: c.CreateID()})
+		defer call.end()
+
+		c.ConfigHash, err = containerConfigHash(req)
+		if err != nil {
+			return nil, AnnErr(log, err, "unable to hash container config")
+		}
+
+		prev, err = s.reconcileContainer(ctx, log, c)
+		if err != nil {
+			return nil, AnnErr(log, err, "unable to reconcile container of a previous creation")
+		}
+	}
+
+	if prev != nil {
+		c = prev
+	} else {
+		adjs, err := s.runHooks(ctx, log, HookContainerPreCreate, &HookState{
+			SandboxID:       req.GetPodSandboxId(),
+			Labels:          c.Labels,
+			Annotations:     c.Annotations,
+			SandboxConfig:   req.GetSandboxConfig(),
+			ContainerConfig: req.GetConfig(),
+		})
+		if err != nil {
+			return nil, AnnErr(log, err, "container rejected by hook")
+		}
+
+		err = s.applyHookAdjustments(log, c, adjs)
+		if err != nil {
+			return nil, AnnErr(log, err, "invalid hook adjustment")
+		}
+
+		audit := s.audit(ctx, c, auditCreated, "CreateContainer")
+
+		err = c.Apply(ctx)
+		s.auditDone(audit, c, err)
+
+		if err != nil {
+			return nil, AnnErr(log, err, "unable to create container")
+		}
+
+		s.publishEvent(c, ContainerCreatedEvent)
 	}
 
 	// create network
 	if sb.NetworkConfig.Mode != lxf.NetworkHost {
-		podNet, err := s.network.PodNetwork(sb.ID, sb.Annotations)
+		podNet, err := s.podNetwork(sb)
 		if err != nil {
 			return nil, AnnErr(log, err, "can't enter pod network context")
 		}
@@ -643,7 +1043,7 @@ func (s RuntimeServer) CreateContainer(ctx context.Context, req *rtApi.CreateCon
 		}
 	}
 
-	log.Info("create container successful")
+	log.WithField("location", c.Target).Info("create container successful")
 
 	return &rtApi.CreateContainerResponse{ContainerId: c.ID}, nil
 }
@@ -659,11 +1059,56 @@ func (s RuntimeServer) StartContainer(ctx context.Context, req *rtApi.StartConta
 		return nil, AnnErr(log, err, "unable to get container")
 	}
 
-	err = c.Start()
+	ctx, call := s.journalBegin(ctx, &journalEntry{Call: journalStartContainer, Object: c.ID})
+	defer call.end()
+
+	err = s.applyNamespaces(ctx, c)
+	if err != nil {
+		return nil, AnnErr(log, err, "unable to share namespaces")
+	}
+
+	err = s.applyCgroupParent(ctx, c)
+	if err != nil {
+		return nil, AnnErr(log, err, "unable to place container in pod cgroup")
+	}
+
+	err = waitPodNetns(ctx, c)
+	if err != nil {
+		return nil, AnnErr(log, err, "pod network namespace not ready")
+	}
+
+	err = s.writeHosts(ctx, c)
+	if err != nil {
+		return nil, AnnErr(log, err, "unable to write hosts file")
+	}
+
+	audit := s.audit(ctx, c, auditStarted, "StartContainer")
+
+	err = c.Start(ctx)
+	s.auditDone(audit, c, err)
+
 	if err != nil {
 		return nil, AnnErr(log, err, "unable to start container")
 	}
 
+	if s.hasHooks(HookContainerPostStart, c.Annotations) {
+		state := &HookState{
+			ID:          c.ID,
+			SandboxID:   c.SandboxID(),
+			Labels:      c.Labels,
+			Annotations: c.Annotations,
+		}
+
+		st, err := c.State()
+		if err != nil {
+			log.WithError(err).Warn("unable to get pid of container for hooks")
+		} else {
+			state.Pid = st.Pid
+		}
+
+		s.runHooksLogged(ctx, log, HookContainerPostStart, state)
+	}
+
 	log.Info("start container successful")
 
 	return &rtApi.StartContainerResponse{}, nil
@@ -684,7 +1129,28 @@ func (s RuntimeServer) StopContainer(ctx context.Context, req *rtApi.StopContain
 		return nil, AnnErr(log, err, "unable to get container")
 	}
 
-	err = s.stopContainer(c, int(req.Timeout))
+	ctx, call := s.journalBegin(ctx, &journalEntry{Call: journalStopContainer, Object: c.ID, Timeout: req.Timeout})
+	defer call.end()
+
+	if c.StateName == lxf.ContainerStateRunning {
+		s.runHooksLogged(ctx, log, HookContainerPreStop, &HookState{
+			ID:          c.ID,
+			SandboxID:   c.SandboxID(),
+			Labels:      c.Labels,
+			Annotations: c.Annotations,
+		})
+	}
+
+	action := auditStopped
+	if req.Timeout == 0 {
+		action = auditKilled
+	}
+
+	audit := s.audit(ctx, c, action, fmt.Sprintf("StopContainer with timeout %ds", req.Timeout))
+
+	err = s.stopContainer(ctx, c, int(req.Timeout))
+	s.auditDone(audit, c, err)
+
 	if err != nil {
 		return nil, AnnErr(log, err, "unable to stop container")
 	}
@@ -709,11 +1175,20 @@ func (s RuntimeServer) RemoveContainer(ctx context.Context, req *rtApi.RemoveCon
 		return nil, AnnErr(log, err, "unable to get container")
 	}
 
+	ctx, call := s.journalBegin(ctx, &journalEntry{Call: journalRemoveContainer, Object: c.ID})
+	defer call.end()
+
+	audit := s.audit(ctx, c, auditRemoved, "RemoveContainer")
+
 	err = s.deleteContainer(ctx, c)
+	s.auditDone(audit, c, err)
+
 	if err != nil {
 		return nil, AnnErr(log, err, "unable to remove container")
 	}
 
+	s.publishEvent(c, ContainerDeletedEvent)
+
 	log.Info("remove container successful")
 
 	return &rtApi.RemoveContainerResponse{}, nil
@@ -724,30 +1199,17 @@ func (s RuntimeServer) ListContainers(ctx context.Context, req *rtApi.ListContai
 	log := log.WithContext(ctx).WithField("filter", req.GetFilter().String())
 
 	response := &rtApi.ListContainersResponse{}
+	filter := req.GetFilter()
 
-	cl, err := s.lxf.ListContainers()
+	cl, err := s.lxf.FilterContainers(toContainerFilter(filter))
 	if err != nil {
 		return nil, AnnErr(log, err, "unable to get container list")
 	}
 
 	for _, c := range cl {
-		if req.GetFilter() != nil {
-			filter := req.GetFilter()
-			if filter.GetId() != "" && filter.GetId() != c.ID {
-				continue
-			}
-
-			if filter.GetState() != nil && filter.GetState().GetState() != stateContainerAsCri(c.StateName) {
-				continue
-			}
-
-			if filter.GetPodSandboxId() != "" && filter.GetPodSandboxId() != c.SandboxID() {
-				continue
-			}
-
-			if !CompareFilterMap(c.Labels, filter.GetLabelSelector()) {
-				continue
-			}
+		// the state is only known once converted
+		if filter.GetState() != nil && filter.GetState().GetState() != stateContainerAsCri(c.StateName) {
+			continue
 		}
 
 		response.Containers = append(response.Containers, toCriContainer(c))
@@ -767,12 +1229,66 @@ func (s RuntimeServer) ContainerStatus(ctx context.Context, req *rtApi.Container
 
 	response := toCriStatusResponse(ct)
 
+	// the CRI stats have no field for the process count, so it's reported here
+	if req.GetVerbose() && ct.StateName == lxf.ContainerStateRunning {
+		st, err := ct.State()
+		if err != nil {
+			return nil, AnnErr(log, err, "unable to get container state")
+		}
+
+		response.Info["processes"] = strconv.FormatUint(st.Stats.Processes, 10)
+	}
+
 	return response, nil
 }
 
 // UpdateContainerResources updates ContainerConfig of the container.
 func (s RuntimeServer) UpdateContainerResources(ctx context.Context, req *rtApi.UpdateContainerResourcesRequest) (*rtApi.UpdateContainerResourcesResponse, error) {
-	return nil, SilErr(log, ErrNotImplemented, "")
+	log := log.WithContext(ctx).WithField("containerid", req.GetContainerId())
+	log.Info("update container resources")
+
+	c, err := s.lxf.GetContainer(req.GetContainerId())
+	if err != nil {
+		return nil, AnnErr(log, err, "unable to get container")
+	}
+
+	sb, err := c.Sandbox()
+	if err != nil {
+		return nil, AnnErr(log, err, "unable to find sandbox")
+	}
+
+	// kubelet doesn't pass the ephemeral storage, so the size is taken from the pod annotation again
+	resized, err := s.resizeRootDisk(sb)
+	if err != nil {
+		return nil, AnnErr(log, err, "invalid root disk")
+	}
+
+	if resized {
+		err = sb.Apply()
+		if err != nil {
+			return nil, AnnErr(log, err, "unable to resize root disk")
+		}
+	}
+
+	c.Resources = mergeResources(c.Resources, withOverhead(toResources(req.GetLinux()), s.podOverhead(sb)))
+
+	if req.GetLinux().GetOomScoreAdj() != 0 {
+		c.OOMScoreAdj = req.GetLinux().GetOomScoreAdj()
+	}
+
+	err = s.applyContainerRawLXC(c, sb)
+	if err != nil {
+		return nil, AnnErr(log, err, "unable to render raw.lxc")
+	}
+
+	err = c.Apply(ctx)
+	if err != nil {
+		return nil, AnnErr(log, err, "unable to update container")
+	}
+
+	log.Info("update container resources successful")
+
+	return &rtApi.UpdateContainerResourcesResponse{}, nil
 }
 
 // ReopenContainerLog asks runtime to reopen the stdout/stderr log file for the container. This is often called after
@@ -789,26 +1305,28 @@ func (s RuntimeServer) ExecSync(ctx context.Context, req *rtApi.ExecSyncRequest)
 		"cmd":         req.GetCmd(),
 	})
 
-	stdin := bytes.NewReader(nil)
-	stdinR := ioutil.NopCloser(stdin)
-	stdout := bytes.NewBuffer(nil)
-	stdoutW := ioutils.WriteCloserWrapper(stdout)
-	stderr := bytes.NewBuffer(nil)
-	stderrW := ioutils.WriteCloserWrapper(stderr)
-
-	code, err := s.lxf.Exec(req.GetContainerId(), req.GetCmd(), stdinR, stdoutW, stderrW, false, false, req.GetTimeout(), nil)
+	res, err := s.lxf.ExecSync(ctx, req.GetContainerId(), req.GetCmd(), time.Duration(req.GetTimeout())*time.Second)
 	if err != nil {
+		if errors.Is(err, lxo.ErrExecTimeout) {
+			return nil, AnnErr(log, err, "exec timed out")
+		}
+
 		return nil, AnnErr(log, err, "unable to exec")
 	}
 
-	log = log.WithField("exit", code)
+	log = log.WithField("exit", res.ExitCode)
+
+	if res.Truncated {
+		log.Warn("exec output exceeded size cap and was truncated")
+	}
+
 	log.Debug("exec finished")
 
 	return &rtApi.ExecSyncResponse{
-		Stdout:   stdout.Bytes(),
-		Stderr:   stderr.Bytes(),
-		ExitCode: code,
-	}, err
+		Stdout:   res.Stdout,
+		Stderr:   res.Stderr,
+		ExitCode: res.ExitCode,
+	}, nil
 }
 
 // Exec prepares a streaming endpoint to execute a command in the container.
@@ -869,25 +1387,7 @@ func (s RuntimeServer) ListContainerStats(ctx context.Context, req *rtApi.ListCo
 
 	response := &rtApi.ListContainerStatsResponse{}
 
-	if req.GetFilter() != nil && req.GetFilter().GetId() != "" {
-		log = log.WithField("containerid", req.GetFilter().GetId())
-
-		c, err := s.lxf.GetContainer(req.Filter.Id)
-		if err != nil {
-			return nil, AnnErr(log, err, "unable to get container")
-		}
-
-		st, err := toCriStats(c)
-		if err != nil {
-			return nil, AnnErr(log, err, "unable to get stats")
-		}
-
-		response.Stats = append(response.Stats, st)
-
-		return response, nil
-	}
-
-	cts, err := s.lxf.ListContainers()
+	cts, err := s.lxf.FilterContainers(toContainerFilter(req.GetFilter()))
 	if err != nil {
 		return nil, AnnErr(log, err, "unable to list containers")
 	}
@@ -910,7 +1410,9 @@ func (s RuntimeServer) ListContainerStats(ctx context.Context, req *rtApi.ListCo
 func (s RuntimeServer) UpdateRuntimeConfig(ctx context.Context, req *rtApi.UpdateRuntimeConfigRequest) (*rtApi.UpdateRuntimeConfigResponse, error) {
 	log := log.WithContext(ctx).WithField("cidr", req.GetRuntimeConfig().GetNetworkConfig().GetPodCidr())
 
-	err := s.network.UpdateRuntimeConfig(req.GetRuntimeConfig())
+	_, plugin := s.networks.Current()
+
+	err := plugin.UpdateRuntimeConfig(req.GetRuntimeConfig())
 	if err != nil {
 		return nil, AnnErr(log, err, "unable to update runtime config")
 	}
@@ -918,23 +1420,28 @@ func (s RuntimeServer) UpdateRuntimeConfig(ctx context.Context, req *rtApi.Updat
 	return &rtApi.UpdateRuntimeConfigResponse{}, nil
 }
 
-// Status returns the status of the runtime.
+// Status returns the status of the runtime. LXD, the storage pools and the network plugin are checked on every call,
+// the conditions carry the reason if they aren't ready. The verbose response contains information about the LXD host
 func (s RuntimeServer) Status(ctx context.Context, req *rtApi.StatusRequest) (*rtApi.StatusResponse, error) {
-	// TODO: actually check services!
+	server, _, err := s.lxf.GetServer().GetServer()
+
 	response := &rtApi.StatusResponse{
 		Status: &rtApi.RuntimeStatus{
 			Conditions: []*rtApi.RuntimeCondition{
-				{
-					Type:   rtApi.RuntimeReady,
-					Status: true,
-				},
-				{
-					Type:   rtApi.NetworkReady,
-					Status: true,
-				},
+				s.runtimeCondition(err),
+				s.networkCondition(),
 			},
 		},
 	}
 
+	if req.GetVerbose() && err == nil {
+		response.Info = runtimeInfo(server)
+		response.Info["cgroupMode"] = s.cgroupMode
+
+		if quota := s.quotaInfo(); quota != "" {
+			response.Info["quota"] = quota
+		}
+	}
+
 	return response, nil
 }
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/user"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,7 +13,9 @@ import (
 	"github.com/automaticserver/lxe/lxf/device"
 	"github.com/automaticserver/lxe/network"
 	"github.com/automaticserver/lxe/shared"
+	"github.com/lxc/lxd/lxc/config"
 	sharedLXD "github.com/lxc/lxd/shared"
+	opencontainers "github.com/opencontainers/runtime-spec/specs-go"
 	"golang.org/x/net/context"
 	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
 )
@@ -24,15 +27,30 @@ func toCriStatusResponse(c *lxf.Container) *rtApi.ContainerStatusResponse {
 			Attempt: c.Metadata.Attempt,
 		},
 		State:       stateContainerAsCri(c.StateName),
-		CreatedAt:   c.CreatedAt.UnixNano(),
-		StartedAt:   c.StartedAt.UnixNano(),
-		FinishedAt:  c.FinishedAt.UnixNano(),
+		CreatedAt:   lxf.UnixNano(c.CreatedAt),
+		StartedAt:   lxf.UnixNano(c.StartedAt),
+		FinishedAt:  lxf.UnixNano(c.FinishedAt),
 		Id:          c.ID,
 		Labels:      c.Labels,
 		Annotations: c.Annotations,
 		Image:       &rtApi.ImageSpec{Image: c.Image},
 		ImageRef:    c.Image,
 		Mounts:      []*rtApi.Mount{},
+		Reason:      c.EvictionReason,
+		Message:     c.EvictionMessage,
+	}
+
+	if c.StateName == lxf.ContainerStateExited {
+		status.ExitCode = c.ExitCode
+
+		if status.Message == "" {
+			status.Message = c.TerminationMessage
+		}
+
+		// kubelet shows the reason of terminated containers, use the ones of docker if it wasn't evicted
+		if status.Reason == "" {
+			status.Reason = exitReason(c.ExitCode)
+		}
 	}
 
 	for _, dev := range c.Devices {
@@ -46,22 +64,50 @@ func toCriStatusResponse(c *lxf.Container) *rtApi.ContainerStatusResponse {
 				Propagation:    rtApi.MountPropagation_PROPAGATION_HOST_TO_CONTAINER, // unsure
 			})
 		case *device.Disk:
+			// the root disk and custom storage volumes are not mounts requested by kubelet
+			if d.Source == "" || d.Pool != "" {
+				continue
+			}
+
 			status.Mounts = append(status.Mounts, &rtApi.Mount{
 				ContainerPath:  d.Path,
 				HostPath:       d.Source,
 				Readonly:       d.Readonly,
-				SelinuxRelabel: false, // though don't know what this means
-				Propagation:    rtApi.MountPropagation_PROPAGATION_PRIVATE,
+				SelinuxRelabel: false, // not supported, see CreateContainer
+				Propagation:    propagationAsCri(d.Propagation),
 			})
 		}
 	}
 
+	info := map[string]string{}
+	if c.Location != "" {
+		info["location"] = c.Location
+	}
+
+	if c.RestartCount > 0 {
+		info["restarts"] = strconv.Itoa(c.RestartCount)
+	}
+
+	// the CRI status has no field for it
+	if isReadonlyRootfs(c) {
+		info["readonlyRootfs"] = "true"
+	}
+
 	return &rtApi.ContainerStatusResponse{
 		Status: &status,
-		Info:   map[string]string{},
+		Info:   info,
 	}
 }
 
+// exitReason returns the reason of a terminated container for its exit code
+func exitReason(code int32) string {
+	if code == 0 {
+		return "Completed"
+	}
+
+	return "Error"
+}
+
 func toCriStats(c *lxf.Container) (*rtApi.ContainerStats, error) {
 	st, err := c.State()
 	if err != nil {
@@ -112,7 +158,7 @@ func toCriContainer(c *lxf.Container) *rtApi.Container {
 		PodSandboxId: c.SandboxID(),
 		Image:        &rtApi.ImageSpec{Image: c.Image},
 		ImageRef:     c.Image,
-		CreatedAt:    c.CreatedAt.UnixNano(),
+		CreatedAt:    lxf.UnixNano(c.CreatedAt),
 		State:        stateContainerAsCri(c.StateName),
 		Metadata: &rtApi.ContainerMetadata{
 			Name:    c.Metadata.Name,
@@ -123,6 +169,153 @@ func toCriContainer(c *lxf.Container) *rtApi.Container {
 	}
 }
 
+// kubeletVolumePlugins are the volume plugins whose files are written by kubelet itself
+var kubeletVolumePlugins = []string{
+	"kubernetes.io~configmap",
+	"kubernetes.io~downward-api",
+	"kubernetes.io~projected",
+	"kubernetes.io~secret",
+}
+
+// isKubeletVolume checks if the host path is a volume with files provided by kubelet, like configmaps, secrets,
+// downward api or the projected service account token
+func isKubeletVolume(hostPath string) bool {
+	for _, p := range kubeletVolumePlugins {
+		if strings.Contains(hostPath, "/volumes/"+p+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// toLXDDisk creates a disk device for the CRI mount. Writable bind mounts of host directories are recursive, LXD
+// rejects recursive readonly mounts and recursive mounts of files
+func toLXDDisk(mnt *rtApi.Mount) *device.Disk {
+	containerPath := mnt.GetContainerPath()
+	// cannot use /var/run as most distros symlink that to /run and lxd doesn't like mounts there because of that
+	if strings.HasPrefix(containerPath, "/var/run") {
+		containerPath = path.Join("/run", strings.TrimPrefix(containerPath, "/var/run"))
+	}
+	// cannot use /run as most distros mount a tmpfs on top of that so mounts from lxd are not visible in the container
+	if strings.HasPrefix(containerPath, "/run") {
+		containerPath = path.Join("/mnt", strings.TrimPrefix(containerPath, "/run"))
+	}
+
+	return &device.Disk{
+		Path:        containerPath,
+		Source:      mnt.GetHostPath(),
+		Readonly:    mnt.GetReadonly(),
+		Optional:    false,
+		Propagation: propagationAsLXD(mnt.GetPropagation()),
+		Recursive:   !mnt.GetReadonly() && isDir(mnt.GetHostPath()),
+	}
+}
+
+// isDir returns whether the path is an existing directory
+func isDir(p string) bool {
+	info, err := os.Stat(p)
+
+	return err == nil && info.IsDir()
+}
+
+// toResources maps the CRI resources to the container resources, nil if none are requested
+func toResources(resrc *rtApi.LinuxContainerResources) *opencontainers.LinuxResources {
+	if resrc == nil {
+		return nil
+	}
+
+	shares := uint64(resrc.CpuShares)
+	period := uint64(resrc.CpuPeriod)
+
+	return &opencontainers.LinuxResources{
+		CPU: &opencontainers.LinuxCPU{
+			Shares: &shares,
+			Quota:  &resrc.CpuQuota,
+			Period: &period,
+			Cpus:   resrc.CpusetCpus,
+			Mems:   resrc.CpusetMems,
+		},
+		Memory: &opencontainers.LinuxMemory{
+			Limit: &resrc.MemoryLimitInBytes,
+		},
+	}
+}
+
+// mergeResources returns the current resources with the values set in the update replaced. The kubelet cpu manager
+// updates only the cpuset, which must not reset the other limits
+func mergeResources(cur, upd *opencontainers.LinuxResources) *opencontainers.LinuxResources {
+	if cur == nil || upd == nil {
+		if upd != nil {
+			return upd
+		}
+
+		return cur
+	}
+
+	if cur.CPU == nil {
+		cur.CPU = &opencontainers.LinuxCPU{}
+	}
+
+	if cur.Memory == nil {
+		cur.Memory = &opencontainers.LinuxMemory{}
+	}
+
+	if u := upd.CPU; u != nil {
+		if u.Shares != nil && *u.Shares > 0 {
+			cur.CPU.Shares = u.Shares
+		}
+
+		if u.Quota != nil && *u.Quota != 0 {
+			cur.CPU.Quota = u.Quota
+		}
+
+		if u.Period != nil && *u.Period > 0 {
+			cur.CPU.Period = u.Period
+		}
+
+		if u.Cpus != "" {
+			cur.CPU.Cpus = u.Cpus
+		}
+
+		if u.Mems != "" {
+			cur.CPU.Mems = u.Mems
+		}
+	}
+
+	if upd.Memory != nil && upd.Memory.Limit != nil && *upd.Memory.Limit > 0 {
+		cur.Memory.Limit = upd.Memory.Limit
+	}
+
+	return cur
+}
+
+// propagationAsLXD maps the CRI mount propagation to the LXD disk propagation option
+func propagationAsLXD(p rtApi.MountPropagation) string {
+	switch p {
+	case rtApi.MountPropagation_PROPAGATION_HOST_TO_CONTAINER:
+		return "rslave"
+	case rtApi.MountPropagation_PROPAGATION_BIDIRECTIONAL:
+		return "rshared"
+	case rtApi.MountPropagation_PROPAGATION_PRIVATE:
+		fallthrough
+	default:
+		return "rprivate"
+	}
+}
+
+// propagationAsCri maps the LXD disk propagation option to the CRI mount propagation
+func propagationAsCri(p string) rtApi.MountPropagation {
+	switch p {
+	case "slave", "rslave":
+		return rtApi.MountPropagation_PROPAGATION_HOST_TO_CONTAINER
+	case "shared", "rshared":
+		return rtApi.MountPropagation_PROPAGATION_BIDIRECTIONAL
+	default:
+		return rtApi.MountPropagation_PROPAGATION_PRIVATE
+	}
+}
+
 func stateContainerAsCri(s lxf.ContainerStateName) rtApi.ContainerState {
 	return rtApi.ContainerState(
 		rtApi.ContainerState_value["CONTAINER_"+strings.ToUpper(s.String())])
@@ -141,6 +334,23 @@ func stringToNamespaceOption(s string) rtApi.NamespaceMode {
 	return rtApi.NamespaceMode(rtApi.NamespaceMode_value[strings.ToUpper(s)])
 }
 
+// containerFilter is a filter of containers of a CRI request
+type containerFilter interface {
+	GetId() string
+	GetPodSandboxId() string
+	GetLabelSelector() map[string]string
+}
+
+// toContainerFilter returns the lxf filter of the filter of a CRI request, which may be nil. The state can only be
+// filtered after
+func toContainerFilter(f containerFilter) lxf.ContainerFilter {
+	return lxf.ContainerFilter{
+		ID:        f.GetId(),
+		SandboxID: f.GetPodSandboxId(),
+		Labels:    f.GetLabelSelector(),
+	}
+}
+
 // CompareFilterMap allows comparing two string maps
 func CompareFilterMap(base map[string]string, filter map[string]string) bool {
 	if filter == nil { // filter can be nil
@@ -156,6 +366,16 @@ func CompareFilterMap(base map[string]string, filter map[string]string) bool {
 	return true
 }
 
+// lxdProject returns the LXD project of the config
+func lxdProject(cfg *Config) (lxf.Project, error) {
+	limits, err := lxf.ParseProjectLimits(cfg.LXDProjectLimits)
+	if err != nil {
+		return lxf.Project{}, err
+	}
+
+	return lxf.Project{Name: cfg.LXDProject, Limits: limits}, nil
+}
+
 // getLXDConfigPath tries to find the remote configuration file path
 func getLXDConfigPath(cfg *Config) (string, error) {
 	configPath := cfg.LXDRemoteConfig
@@ -184,29 +404,35 @@ func getLXDConfigPath(cfg *Config) (string, error) {
 	return configPath, nil
 }
 
-func (s RuntimeServer) stopContainers(sb *lxf.Sandbox) error {
+// loadLXDConfig loads the lxc config at configPath. With the in-memory LXD a missing one is the default config
+func loadLXDConfig(cfg *Config, configPath string) (*config.Config, error) {
+	if _, err := os.Stat(configPath); cfg.LXDFake && os.IsNotExist(err) {
+		def := config.DefaultConfig
+
+		return &def, nil
+	}
+
+	return config.LoadConfig(configPath)
+}
+
+func (s RuntimeServer) stopContainers(ctx context.Context, sb *lxf.Sandbox) error {
 	cl, err := sb.Containers()
 	if err != nil {
 		return err
 	}
 
-	for _, c := range cl {
-		err := s.stopContainer(c, 30)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return s.lxf.Batch(len(cl), func(i int) error {
+		return s.stopContainer(ctx, cl[i], 30)
+	})
 }
 
-func (s RuntimeServer) stopContainer(c *lxf.Container, timeout int) error {
+func (s RuntimeServer) stopContainer(ctx context.Context, c *lxf.Container, timeout int) error {
 	// if container is not running, no stopping needed
 	if c.StateName != lxf.ContainerStateRunning {
 		return nil
 	}
 
-	err := c.Stop(timeout)
+	err := c.Stop(ctx, timeout)
 	if err != nil {
 		if shared.IsErrNotFound(err) {
 			return nil
@@ -224,18 +450,13 @@ func (s RuntimeServer) deleteContainers(ctx context.Context, sb *lxf.Sandbox) er
 		return err
 	}
 
-	for _, c := range cl {
-		err = s.deleteContainer(ctx, c)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return s.lxf.Batch(len(cl), func(i int) error {
+		return s.deleteContainer(ctx, cl[i])
+	})
 }
 
 func (s RuntimeServer) deleteContainer(ctx context.Context, c *lxf.Container) error {
-	err := c.Delete()
+	err := c.Delete(ctx)
 	if err != nil {
 		if shared.IsErrNotFound(err) {
 			return nil
@@ -251,7 +472,7 @@ func (s RuntimeServer) deleteContainer(ctx context.Context, c *lxf.Container) er
 
 	// remove network
 	if sb.NetworkConfig.Mode != lxf.NetworkHost {
-		podNet, err := s.network.PodNetwork(sb.ID, sb.Annotations)
+		podNet, err := s.podNetwork(sb)
 		if err == nil { // force cleanup, we don't care about error, but only enter if there's no error
 			contNet, err := podNet.ContainerNetwork(c.ID, c.Annotations)
 			if err == nil { // dito
@@ -266,7 +487,14 @@ func (s RuntimeServer) deleteContainer(ctx context.Context, c *lxf.Container) er
 var NetworkSetupTimeout = 30 * time.Second
 
 // ContainerStarted implements lxf.EventHandler interface
-func (s RuntimeServer) ContainerStarted(c *lxf.Container) error {
+func (s RuntimeServer) ContainerStarted(ctx context.Context, c *lxf.Container) error {
+	s.publishEvent(c, ContainerStartedEvent)
+
+	return s.startContainerNetwork(ctx, c)
+}
+
+// startContainerNetwork sets up the network of the started container
+func (s RuntimeServer) startContainerNetwork(ctx context.Context, c *lxf.Container) error {
 	sb, err := c.Sandbox()
 	if err != nil {
 		return err
@@ -278,7 +506,7 @@ func (s RuntimeServer) ContainerStarted(c *lxf.Container) error {
 			return err
 		}
 
-		podNet, err := s.network.PodNetwork(sb.ID, sb.Annotations)
+		podNet, err := s.podNetwork(sb)
 		if err != nil {
 			return fmt.Errorf("can't enter pod network context: %w", err)
 		}
@@ -288,14 +516,23 @@ func (s RuntimeServer) ContainerStarted(c *lxf.Container) error {
 			return fmt.Errorf("can't enter container network context: %w", err)
 		}
 
-		ctx, _ := context.WithTimeout(context.Background(), NetworkSetupTimeout)
+		ctx, _ := context.WithTimeout(ctx, NetworkSetupTimeout)
 
-		res, err := contNet.WhenStarted(ctx, &network.PropertiesRunning{
+		prop := &network.PropertiesRunning{
 			Properties: network.Properties{
 				Data: sb.NetworkConfig.ModeData,
 			},
 			Pid: st.Pid,
-		})
+		}
+
+		res, err := contNet.WhenStarted(ctx, prop)
+		if network.IsIPPoolExhausted(err) && s.criConfig.CNIReclaimOnExhaustion {
+			log.WithError(err).WithField("containerid", c.ID).Warn("ip pool exhausted, reclaiming leaked allocations")
+			s.reclaimNetworks(ctx, c.ID)
+
+			res, err = contNet.WhenStarted(ctx, prop)
+		}
+
 		if err != nil {
 			return fmt.Errorf("can't start container network: %w", err)
 		}
@@ -309,8 +546,77 @@ func (s RuntimeServer) ContainerStarted(c *lxf.Container) error {
 	return nil
 }
 
+// reclaimablePods groups the containers by their sandbox and returns only the pods without a running container. The pod
+// of the container with the provided id is skipped, as it's about to be started
+func reclaimablePods(cl []*lxf.Container, skipID string) map[string][]*lxf.Container {
+	pods := map[string][]*lxf.Container{}
+	inUse := map[string]bool{}
+
+	for _, c := range cl {
+		if len(c.Profiles) == 0 {
+			continue
+		}
+
+		id := c.SandboxID()
+		if c.ID == skipID || c.StateName == lxf.ContainerStateRunning {
+			inUse[id] = true
+			continue
+		}
+
+		pods[id] = append(pods[id], c)
+	}
+
+	for id := range inUse {
+		delete(pods, id)
+	}
+
+	return pods
+}
+
+// reclaimNetworks tears down the network of the containers of all pods which have no running container, so their
+// allocated resources (e.g. IP addresses) are released. Pods with a running container keep their network, as it's
+// shared by all its containers. The pod of the container with the provided id is skipped
+func (s RuntimeServer) reclaimNetworks(ctx context.Context, skipID string) {
+	cl, err := s.lxf.ListContainers()
+	if err != nil {
+		log.WithError(err).Warn("unable to list containers for network reclaim")
+		return
+	}
+
+	for _, pod := range reclaimablePods(cl, skipID) {
+		for _, c := range pod {
+			s.reclaimNetwork(ctx, c)
+		}
+	}
+}
+
+// reclaimNetwork tears down the network of the container
+func (s RuntimeServer) reclaimNetwork(ctx context.Context, c *lxf.Container) {
+	sb, err := c.Sandbox()
+	if err != nil || sb.NetworkConfig.Mode == lxf.NetworkHost {
+		return
+	}
+
+	podNet, err := s.podNetwork(sb)
+	if err != nil {
+		return
+	}
+
+	contNet, err := podNet.ContainerNetwork(c.ID, c.Annotations)
+	if err != nil {
+		return
+	}
+
+	err = contNet.WhenDeleted(ctx, &network.Properties{Data: sb.NetworkConfig.ModeData})
+	if err != nil {
+		log.WithError(err).WithField("containerid", c.ID).Warn("unable to reclaim container network")
+	}
+}
+
 // ContainerStopped implements lxf.EventHandler interface
-func (s *RuntimeServer) ContainerStopped(c *lxf.Container) error {
+func (s *RuntimeServer) ContainerStopped(ctx context.Context, c *lxf.Container) error {
+	s.publishEvent(c, ContainerStoppedEvent)
+
 	sb, err := c.Sandbox()
 	if err != nil {
 		return err
@@ -318,17 +624,23 @@ func (s *RuntimeServer) ContainerStopped(c *lxf.Container) error {
 
 	// stop network
 	if sb.NetworkConfig.Mode != lxf.NetworkHost {
-		podNet, err := s.network.PodNetwork(sb.ID, sb.Annotations)
+		podNet, err := s.podNetwork(sb)
 		if err == nil { // force cleanup, we don't care about error, but only enter if there's no error
 			contNet, err := podNet.ContainerNetwork(c.ID, c.Annotations)
 			if err == nil { // dito
-				ctx, _ := context.WithTimeout(context.Background(), NetworkSetupTimeout)
+				ctx, _ := context.WithTimeout(ctx, NetworkSetupTimeout)
 				_ = contNet.WhenStopped(ctx, &network.Properties{Data: sb.NetworkConfig.ModeData})
 			}
 		}
 	}
 
-	return nil
+	// read them before the container can be deleted or started again
+	err = recordConsoleLog(ctx, sb, c)
+	if err != nil {
+		log.WithError(err).WithField("containerid", c.ID).Warn("unable to write console log")
+	}
+
+	return recordTerminationMessage(ctx, c)
 }
 
 func (s *RuntimeServer) handleNetworkResult(sb *lxf.Sandbox, res *network.Result) error {
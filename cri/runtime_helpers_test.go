@@ -0,0 +1,172 @@
+package cri
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/device"
+	"github.com/stretchr/testify/assert"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func Test_toLXDDisk(t *testing.T) {
+	t.Parallel()
+
+	d := toLXDDisk(&rtApi.Mount{
+		ContainerPath: "/data",
+		HostPath:      "/var/lib/kubelet/pods/foo/volumes/bar",
+		Readonly:      true,
+		Propagation:   rtApi.MountPropagation_PROPAGATION_HOST_TO_CONTAINER,
+	})
+
+	assert.Exactly(t, &device.Disk{
+		Path:        "/data",
+		Source:      "/var/lib/kubelet/pods/foo/volumes/bar",
+		Readonly:    true,
+		Propagation: "rslave",
+	}, d)
+}
+
+func Test_toLXDDisk_Recursive(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "termination-log")
+	assert.NoError(t, os.WriteFile(file, nil, 0644))
+
+	// only writable host directories are mounted recursively
+	assert.True(t, toLXDDisk(&rtApi.Mount{ContainerPath: "/data", HostPath: dir}).Recursive)
+	assert.False(t, toLXDDisk(&rtApi.Mount{ContainerPath: "/data", HostPath: dir, Readonly: true}).Recursive)
+	assert.False(t, toLXDDisk(&rtApi.Mount{ContainerPath: "/dev/termination-log", HostPath: file}).Recursive)
+	assert.False(t, toLXDDisk(&rtApi.Mount{ContainerPath: "/data", HostPath: filepath.Join(dir, "missing")}).Recursive)
+}
+
+func Test_toLXDDisk_RunPaths(t *testing.T) {
+	t.Parallel()
+
+	d := toLXDDisk(&rtApi.Mount{ContainerPath: "/var/run/secrets/token", HostPath: "/foo"})
+	assert.Equal(t, "/mnt/secrets/token", d.Path)
+
+	d = toLXDDisk(&rtApi.Mount{ContainerPath: "/run/foo", HostPath: "/foo"})
+	assert.Equal(t, "/mnt/foo", d.Path)
+}
+
+func Test_propagation(t *testing.T) {
+	t.Parallel()
+
+	for _, p := range []rtApi.MountPropagation{
+		rtApi.MountPropagation_PROPAGATION_PRIVATE,
+		rtApi.MountPropagation_PROPAGATION_HOST_TO_CONTAINER,
+		rtApi.MountPropagation_PROPAGATION_BIDIRECTIONAL,
+	} {
+		assert.Equal(t, p, propagationAsCri(propagationAsLXD(p)))
+	}
+
+	assert.Equal(t, rtApi.MountPropagation_PROPAGATION_PRIVATE, propagationAsCri(""))
+}
+
+func Test_isKubeletVolume(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, isKubeletVolume("/var/lib/kubelet/pods/uid/volumes/kubernetes.io~secret/foo"))
+	assert.True(t, isKubeletVolume("/var/lib/kubelet/pods/uid/volumes/kubernetes.io~projected/kube-api-access-abc"))
+	assert.True(t, isKubeletVolume("/var/lib/kubelet/pods/uid/volumes/kubernetes.io~configmap/bar"))
+	assert.False(t, isKubeletVolume("/var/lib/kubelet/pods/uid/volumes/kubernetes.io~empty-dir/data"))
+	assert.False(t, isKubeletVolume("/var/lib/kubelet/pods/uid/etc-hosts"))
+	assert.False(t, isKubeletVolume("/srv/data"))
+}
+
+func Test_toResources(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, toResources(nil))
+
+	r := toResources(&rtApi.LinuxContainerResources{CpuShares: 512, CpuQuota: 50000, CpuPeriod: 100000, MemoryLimitInBytes: 1 << 30})
+	assert.Equal(t, uint64(512), *r.CPU.Shares)
+	assert.Equal(t, int64(50000), *r.CPU.Quota)
+	assert.Equal(t, uint64(100000), *r.CPU.Period)
+	assert.Equal(t, int64(1<<30), *r.Memory.Limit)
+}
+
+func Test_mergeResources(t *testing.T) {
+	t.Parallel()
+
+	cur := toResources(&rtApi.LinuxContainerResources{CpuShares: 512, CpuQuota: 50000, CpuPeriod: 100000, MemoryLimitInBytes: 1 << 30})
+
+	// the kubelet cpu manager only sets the cpuset
+	r := mergeResources(cur, toResources(&rtApi.LinuxContainerResources{CpusetCpus: "2-3"}))
+	assert.Equal(t, "2-3", r.CPU.Cpus)
+	assert.Equal(t, uint64(512), *r.CPU.Shares)
+	assert.Equal(t, int64(50000), *r.CPU.Quota)
+	assert.Equal(t, int64(1<<30), *r.Memory.Limit)
+
+	r = mergeResources(r, toResources(&rtApi.LinuxContainerResources{MemoryLimitInBytes: 2 << 30}))
+	assert.Equal(t, int64(2<<30), *r.Memory.Limit)
+	assert.Equal(t, "2-3", r.CPU.Cpus)
+
+	assert.Nil(t, mergeResources(nil, nil))
+	assert.Equal(t, cur, mergeResources(cur, nil))
+}
+
+func TestRuntimeServer_ListContainers_Filter(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+
+	running := &lxf.Container{StateName: lxf.ContainerStateRunning}
+	running.ID = "running"
+	running.Profiles = []string{"sb"}
+	exited := &lxf.Container{StateName: lxf.ContainerStateExited}
+	exited.ID = "exited"
+	exited.Profiles = []string{"sb"}
+	fake.FilterContainersReturns([]*lxf.Container{running, exited}, nil)
+
+	resp, err := s.ListContainers(context.Background(), &rtApi.ListContainersRequest{
+		Filter: &rtApi.ContainerFilter{
+			PodSandboxId:  "sb",
+			LabelSelector: map[string]string{"app": "web"},
+			State:         &rtApi.ContainerStateValue{State: rtApi.ContainerState_CONTAINER_RUNNING},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, resp.GetContainers(), 1)
+	assert.Equal(t, "running", resp.GetContainers()[0].GetId())
+	assert.Equal(t, lxf.ContainerFilter{SandboxID: "sb", Labels: map[string]string{"app": "web"}}, fake.FilterContainersArgsForCall(0))
+
+	// without filter all are listed
+	resp, err = s.ListContainers(context.Background(), &rtApi.ListContainersRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, resp.GetContainers(), 2)
+	assert.Equal(t, lxf.ContainerFilter{}, fake.FilterContainersArgsForCall(1))
+}
+
+func Test_reclaimablePods(t *testing.T) {
+	t.Parallel()
+
+	newContainer := func(id, sandboxID string, state lxf.ContainerStateName) *lxf.Container {
+		c := &lxf.Container{StateName: state}
+		c.ID = id
+		c.Profiles = []string{sandboxID}
+
+		return c
+	}
+
+	stopped := newContainer("stopped", "stopped-pod", lxf.ContainerStateExited)
+	created := newContainer("created", "stopped-pod", lxf.ContainerStateCreated)
+
+	pods := reclaimablePods([]*lxf.Container{
+		stopped,
+		created,
+		// a pod with a running container keeps its network, also for its stopped containers
+		newContainer("running", "running-pod", lxf.ContainerStateRunning),
+		newContainer("sidecar", "running-pod", lxf.ContainerStateExited),
+		// the pod of the container being started is skipped
+		newContainer("starting", "starting-pod", lxf.ContainerStateCreated),
+		newContainer("init", "starting-pod", lxf.ContainerStateExited),
+	}, "starting")
+
+	assert.Equal(t, map[string][]*lxf.Container{"stopped-pod": {stopped, created}}, pods)
+}
@@ -0,0 +1,110 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf/device"
+	"github.com/automaticserver/lxe/shared"
+	"golang.org/x/sys/unix"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+const (
+	// scratchVolumePrefix is the prefix of the custom volumes backing emptyDirs, followed by the pod id
+	scratchVolumePrefix = "scratch-"
+	emptyDirPlugin      = "/volumes/kubernetes.io~empty-dir/"
+)
+
+var (
+	// isTmpfs checks if the path is a tmpfs mount. Can be replaced in tests
+	isTmpfs = func(path string) bool {
+		st := unix.Statfs_t{}
+
+		err := unix.Statfs(path, &st)
+		if err != nil {
+			return false
+		}
+
+		return st.Type == unix.TMPFS_MAGIC
+	}
+)
+
+// emptyDirName returns the name of the emptyDir volume if the host path is one
+func emptyDirName(hostPath string) (string, bool) {
+	i := strings.Index(hostPath, emptyDirPlugin)
+	if i < 0 {
+		return "", false
+	}
+
+	name := strings.SplitN(hostPath[i+len(emptyDirPlugin):], "/", 2)[0]
+
+	return name, name != ""
+}
+
+// scratchVolumeName returns the name of the custom volume backing the emptyDir of the pod
+func scratchVolumeName(sandboxID, name string) string {
+	return scratchVolumePrefix + sandboxID + "-" + name
+}
+
+// scratchDisk places the emptyDir mount on a custom volume in the scratch pool, so IO heavy workloads don't compete
+// with the root pool. As emptyDirs are shared between the containers of a pod, the volume is per pod and owned by its
+// namespace. Returns false if there's no scratch pool or the mount isn't a disk backed emptyDir, memory backed emptyDirs
+// stay on their tmpfs
+func (s RuntimeServer) scratchDisk(sandboxID, namespace string, mnt *rtApi.Mount) (*device.Disk, bool, error) {
+	if s.criConfig.LXDScratchPool == "" {
+		return nil, false, nil
+	}
+
+	name, is := emptyDirName(mnt.GetHostPath())
+	if !is || isTmpfs(mnt.GetHostPath()) {
+		return nil, false, nil
+	}
+
+	vol := annotation.Volume{
+		Pool: s.criConfig.LXDScratchPool,
+		Name: scratchVolumeName(sandboxID, name),
+	}
+
+	err := s.ensureVolume(vol, namespace)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to provision scratch volume %s/%s: %w", vol.Pool, vol.Name, err)
+	}
+
+	disk := toLXDDisk(mnt)
+	disk.Source = vol.Name
+	disk.Pool = vol.Pool
+	// custom volumes are no bind mounts
+	disk.Propagation = ""
+	disk.Recursive = false
+
+	return disk, true, nil
+}
+
+// deleteScratchVolumes deletes the custom volumes backing the emptyDirs of the pod
+func (s RuntimeServer) deleteScratchVolumes(sandboxID string) error {
+	if s.criConfig.LXDScratchPool == "" {
+		return nil
+	}
+
+	server := s.lxf.GetServer()
+
+	vols, err := server.GetStoragePoolVolumes(s.criConfig.LXDScratchPool)
+	if err != nil {
+		return err
+	}
+
+	for _, vol := range vols {
+		if vol.Type != volumeTypeCustom || !strings.HasPrefix(vol.Name, scratchVolumeName(sandboxID, "")) {
+			continue
+		}
+
+		err = server.DeleteStoragePoolVolume(s.criConfig.LXDScratchPool, volumeTypeCustom, vol.Name)
+		if err != nil && !shared.IsErrNotFound(err) {
+			return fmt.Errorf("unable to delete scratch volume %s: %w", vol.Name, err)
+		}
+	}
+
+	return nil
+}
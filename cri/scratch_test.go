@@ -0,0 +1,90 @@
+package cri
+
+import (
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf/device"
+	"github.com/automaticserver/lxe/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func Test_emptyDirName(t *testing.T) {
+	t.Parallel()
+
+	name, is := emptyDirName("/var/lib/kubelet/pods/uid/volumes/kubernetes.io~empty-dir/cache")
+	assert.True(t, is)
+	assert.Equal(t, "cache", name)
+
+	_, is = emptyDirName("/var/lib/kubelet/pods/uid/volumes/kubernetes.io~secret/token")
+	assert.False(t, is)
+
+	_, is = emptyDirName("/var/lib/kubelet/pods/uid/volumes/kubernetes.io~empty-dir/")
+	assert.False(t, is)
+}
+
+func TestRuntimeServer_scratchDisk_Disabled(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+
+	_, scratch, err := s.scratchDisk("sbid", "ns", &rtApi.Mount{HostPath: "/var/lib/kubelet/pods/uid/volumes/kubernetes.io~empty-dir/cache"})
+	assert.NoError(t, err)
+	assert.False(t, scratch)
+}
+
+func TestRuntimeServer_scratchDisk_EmptyDir(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	s.criConfig.LXDScratchPool = "nvme"
+	fakeServer.GetStoragePoolVolumeReturns(nil, "", shared.NewErrNotFound())
+
+	disk, scratch, err := s.scratchDisk("sbid", "ns", &rtApi.Mount{
+		HostPath:      "/var/lib/kubelet/pods/uid/volumes/kubernetes.io~empty-dir/cache",
+		ContainerPath: "/cache",
+	})
+	assert.NoError(t, err)
+	assert.True(t, scratch)
+	assert.Equal(t, &device.Disk{Path: "/cache", Source: "scratch-sbid-cache", Pool: "nvme"}, disk)
+
+	pool, post := fakeServer.CreateStoragePoolVolumeArgsForCall(0)
+	assert.Equal(t, "nvme", pool)
+	assert.Equal(t, "scratch-sbid-cache", post.Name)
+	assert.Equal(t, "ns", post.Config[cfgVolumeNamespace])
+}
+
+func TestRuntimeServer_scratchDisk_OtherMount(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	s.criConfig.LXDScratchPool = "nvme"
+
+	_, scratch, err := s.scratchDisk("sbid", "ns", &rtApi.Mount{HostPath: "/srv/data"})
+	assert.NoError(t, err)
+	assert.False(t, scratch)
+	assert.Equal(t, 0, fakeServer.CreateStoragePoolVolumeCallCount())
+}
+
+func TestRuntimeServer_deleteScratchVolumes(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	s.criConfig.LXDScratchPool = "nvme"
+	fakeServer.GetStoragePoolVolumesReturns([]api.StorageVolume{
+		{Name: "scratch-sbid-cache", Type: volumeTypeCustom},
+		{Name: "scratch-other-cache", Type: volumeTypeCustom},
+		{Name: "data", Type: volumeTypeCustom},
+		{Name: "scratch-sbid-x", Type: "container"},
+	}, nil)
+
+	err := s.deleteScratchVolumes("sbid")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fakeServer.DeleteStoragePoolVolumeCallCount())
+
+	pool, typ, name := fakeServer.DeleteStoragePoolVolumeArgsForCall(0)
+	assert.Equal(t, "nvme", pool)
+	assert.Equal(t, volumeTypeCustom, typ)
+	assert.Equal(t, "scratch-sbid-cache", name)
+}
@@ -8,9 +8,15 @@ import (
 	"net"
 	"os"
 	"path"
+	"sync/atomic"
 
+	"github.com/automaticserver/lxe/imagecache"
+	"github.com/automaticserver/lxe/logging"
 	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/lxo"
 	"github.com/automaticserver/lxe/network"
+	"github.com/automaticserver/lxe/tracing"
+	"github.com/coreos/go-systemd/v22/daemon"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
@@ -26,31 +32,90 @@ const (
 )
 
 var (
-	ErrTimeout = errors.New("timeout error")
-	log        = logrus.StandardLogger().WithContext(context.TODO())
+	ErrTimeout                = errors.New("timeout error")
+	ErrCNIOutputFileMissing   = errors.New("cni output file path is required when target is set to file")
+	ErrUnknownCNIOutputTarget = errors.New("unknown cni output target")
+	log                       = logging.Subsystem("cri")
 )
 
 // Server implements the kubernetes CRI interface specification
 type Server struct {
-	server    *grpc.Server
-	stream    *streamService
-	sock      net.Listener
-	criConfig *Config
+	server   *grpc.Server
+	sockPerm *socketPermissions
+	tcpSock  net.Listener
+	stream   *streamService
+	admin    *adminService
+	attest   *attestService
+	metrics  *metricsService
+	health   *healthService
+	// imageCache serves the image cache, nil if it isn't served
+	imageCache *imageCacheService
+	// serving is 1 while the CRI socket is serving
+	serving int32
+	// activated is true if systemd passed the CRI socket, it's not removed then
+	activated bool
+	// stopWatchdog stops sending the systemd watchdog keepalives
+	stopWatchdog chan struct{}
+	// stopTracing flushes the remaining spans, nil if they're not exported
+	stopTracing func(context.Context) error
+	sock        net.Listener
+	criConfig   *Config
+	client      lxf.Client
+	networks    *networkPlugins
+	cniOutputs  *cniOutputFiles
+	runtime     *RuntimeServer
 }
 
 // NewServer creates the CRI server
 func NewServer(criConfig *Config) *Server {
+	err := criConfig.Validate()
+	if err != nil {
+		log.WithError(err).Fatal("Invalid configuration")
+	}
+
 	configPath, err := getLXDConfigPath(criConfig)
 	if err != nil {
 		log.WithError(err).Fatal("Unable to find lxc config")
 	}
 
-	client, err := lxf.NewClient(criConfig.LXDSocket, configPath)
+	project, err := lxdProject(criConfig)
 	if err != nil {
-		log.WithError(err).Fatal("Unable to initialize lxe facade")
+		log.WithError(err).Fatal("Invalid LXD project")
 	}
 
-	log.WithField("lxdsocket", criConfig.LXDSocket).Info("Connected to LXD")
+	opconf := lxo.Conf{
+		Workers:      criConfig.LXDOperationWorkers,
+		Timeout:      criConfig.LXDOperationTimeout,
+		Retries:      criConfig.LXDOperationRetries,
+		RetryBackoff: criConfig.LXDOperationRetryBackoff,
+	}
+
+	var client lxf.Client
+
+	if criConfig.LXDFake {
+		client, err = newFakeClient(criConfig, configPath, opconf, project)
+		if err != nil {
+			log.WithError(err).Fatal("Unable to initialize lxe facade")
+		}
+
+		log.Warn("Using an in-memory LXD, containers run no processes")
+	} else {
+		client, err = lxf.NewClient(criConfig.LXDSocket, configPath, opconf, criConfig.LXEOwner, project)
+		if err != nil {
+			log.WithError(err).Fatal("Unable to initialize lxe facade")
+		}
+
+		log.WithField("lxdsocket", criConfig.LXDSocket).Info("Connected to LXD")
+	}
+
+	imagePolicy, err := loadImagePolicy(criConfig)
+	if err != nil {
+		log.WithError(err).Fatal("Unable to load image policy")
+	}
+
+	client.SetImagePolicy(imagePolicy)
+	client.SetImageCache(criConfig.LXEImageCacheRemote)
+	client.SetNamePrefix(criConfig.LXENamePrefix)
 
 	// Ensure profile and container schema migration
 	migration := lxf.NewMigrationWorkspace(client)
@@ -60,8 +125,129 @@ func NewServer(criConfig *Config) *Server {
 		log.WithError(err).Fatal("Migration failed")
 	}
 
-	// load selected plugin
-	var netPlugin network.Plugin
+	cniOutputs := newCNIOutputFiles()
+
+	netPlugin, err := initNetworkPlugin(criConfig, client, cniOutputs)
+	if err != nil {
+		log.WithError(err).Fatal("Unable to initialize network plugin")
+	}
+
+	sockPerm, err := newSocketPermissions(criConfig.UnixSocketMode, criConfig.UnixSocketOwner)
+	if err != nil {
+		log.WithError(err).Fatal("Unable to set up socket permissions")
+	}
+
+	grpcServer, err := newGRPCServer(criConfig)
+	if err != nil {
+		log.WithError(err).Fatal("Unable to set up grpc server")
+	}
+
+	// for now we bind the http on every interface
+	runtimeServer, err := NewRuntimeServer(criConfig, client, netPlugin)
+	if err != nil {
+		log.WithError(err).Fatal("Unable to start runtime server")
+	}
+
+	client.SetEventHandler(runtimeServer)
+
+	runtimeServer.recoverJournal(context.Background())
+
+	if criConfig.LXEEvictionPSIThreshold > 0 {
+		runtimeServer.goLoop(runtimeServer.evictionGuard)
+	}
+
+	if criConfig.LXEOrphanGracePeriod > 0 {
+		runtimeServer.goLoop(runtimeServer.orphanReconciler)
+	}
+
+	if criConfig.LXENetworkPlugin == NetworkPluginCNI && criConfig.CNIGCInterval > 0 {
+		runtimeServer.goLoop(runtimeServer.networkGC)
+	}
+
+	if criConfig.LXEQuotaInterval > 0 {
+		runtimeServer.goLoop(runtimeServer.quotaReporter)
+	}
+
+	err = setupStreamService(criConfig, runtimeServer)
+	if err != nil {
+		log.WithError(err).Fatal("unable to create streaming server")
+	}
+
+	imageServer, err := NewImageServer(runtimeServer, client)
+	if err != nil {
+		log.WithError(err).Fatal("Unable to start image server")
+	}
+
+	if criConfig.LXEImageCacheDir != "" {
+		imageServer.imageCache, err = imagecache.New(criConfig.LXEImageCacheDir)
+		if err != nil {
+			log.WithError(err).Fatal("unable to create image cache")
+		}
+	}
+
+	rtApi.RegisterRuntimeServiceServer(grpcServer, *runtimeServer)
+	rtApi.RegisterImageServiceServer(grpcServer, *imageServer)
+
+	srv := &Server{
+		server:     grpcServer,
+		sockPerm:   sockPerm,
+		stream:     runtimeServer.stream,
+		criConfig:  criConfig,
+		client:     client,
+		networks:   runtimeServer.networks,
+		cniOutputs: cniOutputs,
+		runtime:    runtimeServer,
+		// closed by Stop
+		stopWatchdog: make(chan struct{}),
+	}
+
+	if criConfig.LXEAdminSocket != "" {
+		srv.admin = newAdminService(criConfig, runtimeServer)
+	}
+
+	if criConfig.LXEAttestSocket != "" {
+		srv.attest = newAttestService(criConfig, runtimeServer)
+	}
+
+	if criConfig.LXEMetricsBindAddr != "" {
+		srv.metrics, err = newMetricsService(criConfig, client)
+		if err != nil {
+			log.WithError(err).Fatal("unable to create metrics server")
+		}
+	}
+
+	if criConfig.LXEHealthBindAddr != "" {
+		srv.health = newHealthService(criConfig, runtimeServer, srv.isServing)
+	}
+
+	if criConfig.LXEImageCacheBindAddr != "" {
+		srv.imageCache = newImageCacheService(criConfig, imageServer.imageCache)
+	}
+
+	if criConfig.LXETracingEndpoint != "" {
+		srv.stopTracing, err = tracing.Setup(tracing.Conf{
+			Endpoint:    criConfig.LXETracingEndpoint,
+			Insecure:    criConfig.LXETracingInsecure,
+			SampleRatio: criConfig.LXETracingSampleRatio,
+			ServiceName: Domain,
+		})
+		if err != nil {
+			log.WithError(err).Fatal("unable to set up tracing")
+		}
+
+		log.WithField("endpoint", criConfig.LXETracingEndpoint).Info("exporting traces")
+	}
+
+	return srv
+}
+
+// initNetworkPlugin initializes the network plugin selected in the config. The cni output file is taken from outputs,
+// so reloads reuse it
+func initNetworkPlugin(criConfig *Config, client lxf.Client, outputs *cniOutputFiles) (network.Plugin, error) {
+	tuning := network.Tuning{
+		MTU:               criConfig.LXENetworkMTU,
+		DisableTxChecksum: criConfig.LXENetworkDisableTxChecksum,
+	}
 
 	switch criConfig.LXENetworkPlugin {
 	case NetworkPluginCNI:
@@ -74,119 +260,269 @@ func NewServer(criConfig *Config) *Server {
 			writer = os.Stderr
 		case "file":
 			if criConfig.CNIOutputFile == "" {
-				log.Fatal("cni output file path is required when target is set to file")
+				return nil, ErrCNIOutputFileMissing
 			}
 
-			writer, err = os.OpenFile(criConfig.CNIOutputFile, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0660)
+			f, err := outputs.open(criConfig.CNIOutputFile)
 			if err != nil {
-				log.WithError(err).Fatal("could not open cni output file")
+				return nil, fmt.Errorf("could not open cni output file: %w", err)
 			}
+
+			writer = f
 		default:
-			log.WithField("target", criConfig.CNIOutputTarget).Fatal("Unknown cni output target")
+			return nil, fmt.Errorf("%w: %s", ErrUnknownCNIOutputTarget, criConfig.CNIOutputTarget)
 		}
 
-		netPlugin, err = network.InitPluginCNI(network.ConfCNI{
-			BinPath:      criConfig.CNIBinDir,
-			ConfPath:     criConfig.CNIConfDir,
-			OutputWriter: writer,
+		return network.InitPluginCNI(network.ConfCNI{
+			BinPath:       criConfig.CNIBinDir,
+			ConfPath:      criConfig.CNIConfDir,
+			NetnsPath:     criConfig.netnsPath(),
+			OutputWriter:  writer,
+			Tuning:        tuning,
+			PodNetns:      criConfig.CNIPodNetns,
+			PodCIDRBridge: criConfig.CNIPodCIDRBridge,
+			DefaultConf:   criConfig.CNIDefaultConf,
+			WatchConfDir:  criConfig.CNIWatchConfDir,
 		})
 	case NetworkPluginBridge:
-		netPlugin, err = network.InitPluginLXDBridge(client.GetServer(), network.ConfLXDBridge{
+		vlans, err := network.ParseVLANs(criConfig.LXEBridgeVLANs)
+		if err != nil {
+			return nil, err
+		}
+
+		return network.InitPluginLXDBridge(client.GetServer(), network.ConfLXDBridge{
 			LXDBridge:  criConfig.LXEBridgeName,
 			Cidr:       criConfig.LXEBridgeDHCPRange,
 			Nat:        true,
 			CreateOnly: true,
+			VLANs:      vlans,
+			Tuning:     tuning,
+			DNSDomain:  criConfig.LXEBridgeDNSDomain,
 		})
 	default:
-		err = fmt.Errorf("%w: %s", ErrUnknownNetworkPlugin, criConfig.LXENetworkPlugin)
+		return nil, fmt.Errorf("%w: %s", ErrUnknownNetworkPlugin, criConfig.LXENetworkPlugin)
 	}
+}
+
+// Serve creates the cri socket and wraps for grpc.Serve
+func (c *Server) Serve() error {
+	var err error
 
+	sock := c.criConfig.UnixSocket
+	log := log.WithField("socket", sock)
+
+	c.sock, err = activatedListener(sock)
 	if err != nil {
-		log.WithError(err).Fatal("Unable to initialize network plugin")
+		log.WithError(err).Fatal("error using the sockets passed by systemd")
 	}
 
-	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(callTracing))
+	if c.sock != nil {
+		// systemd owns the socket and its permissions
+		c.activated = true
 
-	// for now we bind the http on every interface
-	runtimeServer, err := NewRuntimeServer(criConfig, client, netPlugin)
-	if err != nil {
-		log.WithError(err).Fatal("Unable to start runtime server")
+		log.Info("using socket passed by systemd")
+	} else {
+		c.sock, err = c.listenUnix(sock)
+		if err != nil {
+			log.WithError(err).Fatal("error listening on socket")
+		}
+
+		defer os.Remove(c.criConfig.UnixSocket)
 	}
 
-	client.SetEventHandler(runtimeServer)
+	defer c.sock.Close()
 
-	err = setupStreamService(criConfig, runtimeServer)
-	if err != nil {
-		log.WithError(err).Fatal("unable to create streaming server")
+	log.Infof("started %s CRI shim", Domain)
+
+	if c.criConfig.LXETCPBindAddr != "" {
+		c.tcpSock, err = newTCPListener(c.criConfig)
+		if err != nil {
+			return fmt.Errorf("error listening on tcp: %w", err)
+		}
+
+		log.WithField("endpoint", c.criConfig.LXETCPBindAddr).Info("started CRI tcp listener")
+
+		go func() {
+			err := c.server.Serve(identifyingListener{c.tcpSock})
+			if err != nil {
+				panic(fmt.Errorf("error serving tcp listener: %w", err))
+			}
+		}()
 	}
 
-	imageServer, err := NewImageServer(runtimeServer, client)
-	if err != nil {
-		log.WithError(err).Fatal("Unable to start image server")
+	go func() {
+		err := c.stream.serve()
+		if err != nil {
+			panic(fmt.Errorf("error serving stream service: %w", err))
+		}
+	}()
+
+	if c.admin != nil {
+		go func() {
+			err := c.admin.serve()
+			if err != nil {
+				panic(fmt.Errorf("error serving admin service: %w", err))
+			}
+		}()
 	}
 
-	rtApi.RegisterRuntimeServiceServer(grpcServer, *runtimeServer)
-	rtApi.RegisterImageServiceServer(grpcServer, *imageServer)
+	if c.attest != nil {
+		go func() {
+			err := c.attest.serve()
+			if err != nil {
+				panic(fmt.Errorf("error serving attest service: %w", err))
+			}
+		}()
+	}
 
-	return &Server{
-		server:    grpcServer,
-		stream:    runtimeServer.stream,
-		criConfig: criConfig,
+	if c.metrics != nil {
+		go func() {
+			err := c.metrics.serve()
+			if err != nil {
+				panic(fmt.Errorf("error serving metrics service: %w", err))
+			}
+		}()
 	}
-}
 
-// Serve creates the cri socket and wraps for grpc.Serve
-func (c *Server) Serve() error {
-	var err error
+	if c.health != nil {
+		go func() {
+			err := c.health.serve()
+			if err != nil {
+				panic(fmt.Errorf("error serving health service: %w", err))
+			}
+		}()
+	}
 
-	sock := c.criConfig.UnixSocket
-	log := log.WithField("socket", sock)
+	if c.imageCache != nil {
+		go func() {
+			err := c.imageCache.serve()
+			if err != nil {
+				panic(fmt.Errorf("error serving image cache: %w", err))
+			}
+		}()
+	}
 
-	if _, err = os.Stat(sock); err == nil {
-		log.Debugf("cleaning up stale socket")
+	atomic.StoreInt32(&c.serving, 1)
+	defer atomic.StoreInt32(&c.serving, 0)
+
+	c.notifyReady()
+
+	return c.server.Serve(identifyingListener{c.sock})
+}
+
+// listenUnix creates the CRI socket with its permissions, a stale one is removed first
+func (c *Server) listenUnix(sock string) (net.Listener, error) {
+	if _, err := os.Stat(sock); err == nil {
+		log.WithField("socket", sock).Debugf("cleaning up stale socket")
 
 		err = os.Remove(sock)
 		if err != nil {
-			log.WithError(err).Fatal("error cleaning up stale listening socket")
+			return nil, fmt.Errorf("error cleaning up stale listening socket: %w", err)
 		}
 	}
 
-	c.sock, err = net.Listen("unix", sock)
+	l, err := net.Listen("unix", sock)
 	if err != nil {
-		log.WithError(err).Fatal("error listening on socket")
+		return nil, err
 	}
 
-	defer c.sock.Close()
-	defer os.Remove(c.criConfig.UnixSocket)
-
-	log.Infof("started %s CRI shim", Domain)
+	err = c.sockPerm.apply(sock)
+	if err != nil {
+		l.Close()
+		return nil, fmt.Errorf("error setting socket permissions: %w", err)
+	}
 
-	go func() {
-		err := c.stream.serve()
-		if err != nil {
-			panic(fmt.Errorf("error serving stream service: %w", err))
-		}
-	}()
+	return l, nil
+}
 
-	return c.server.Serve(c.sock)
+// isServing checks if the CRI socket is serving
+func (c *Server) isServing() bool {
+	return atomic.LoadInt32(&c.serving) == 1
 }
 
 // Stop stops the cri socket
 func (c *Server) Stop() error {
+	notify(daemon.SdNotifyStopping)
+	close(c.stopWatchdog)
+
 	c.server.Stop()
 
+	if c.admin != nil {
+		err := c.admin.stop()
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.attest != nil {
+		err := c.attest.stop()
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.metrics != nil {
+		err := c.metrics.stop()
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.health != nil {
+		err := c.health.stop()
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.imageCache != nil {
+		err := c.imageCache.stop()
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.stream != nil {
+		err := c.stream.stop()
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.stopTracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingStopTimeout)
+		defer cancel()
+
+		err := c.stopTracing(ctx)
+		if err != nil {
+			log.WithError(err).Warn("unable to flush traces")
+		}
+	}
+
+	// grpc closes the socket already if it was stopped gracefully
 	err := c.sock.Close()
-	if err != nil {
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+
+	if c.activated {
+		return nil
+	}
+
+	err = os.Remove(c.criConfig.UnixSocket)
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
-	return os.Remove(c.criConfig.UnixSocket)
+	return nil
 }
 
 // callTracing logs requests, responses and error returned by the handler. What gets logged is influenced by what error types the handler returns and the log level. This simplifies error logging in the CRI implementation.
 func callTracing(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	log := log.WithContext(ctx)
 	method := path.Base(info.FullMethod)
+	// every entry logged with the context of the call gets the method and the concerned pod and container
+	ctx = logging.WithFields(ctx, callFields(method, req))
+	log := log.WithContext(ctx)
 
 	resp, err := handler(ctx, req)
 	if err != nil {
@@ -222,10 +558,23 @@ func callTracing(ctx context.Context, req interface{}, info *grpc.UnaryServerInf
 		"resp": resp,
 	}).Trace(fmt.Sprintf("grpc %s", method))
 
-	// It seems like CRI clients don't care about the effective grpc code. The way they interact with errors is the effective error type, so not modifying the error further
-	// if err != nil {
-	// 	err = status.Errorf(codes.NotFound, err.Error())
-	// }
+	lastCallErrors.record(method, req, err)
+
+	// kubelet decides by the grpc code whether and how to retry, so the errors are translated instead of all being unknown
+	return resp, toGRPCError(err)
+}
+
+// callFields returns the log fields of a CRI call: its method and the pod and container ids of the request
+func callFields(method string, req interface{}) logrus.Fields {
+	fields := logrus.Fields{"method": method}
+
+	if r, ok := req.(interface{ GetPodSandboxId() string }); ok && r.GetPodSandboxId() != "" {
+		fields["podid"] = r.GetPodSandboxId()
+	}
+
+	if r, ok := req.(interface{ GetContainerId() string }); ok && r.GetContainerId() != "" {
+		fields["containerid"] = r.GetContainerId()
+	}
 
-	return resp, err
+	return fields
 }
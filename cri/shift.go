@@ -0,0 +1,62 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+
+	"github.com/automaticserver/lxe/lxf/device"
+)
+
+// ShiftMode defines when host path mounts of unprivileged containers are mounted with shift=true.
+// ShiftModeAuto shifts if LXD reports shiftfs or idmapped mount support, ShiftModeAlways shifts without checking and
+// ShiftModeNever never shifts, which is the default as shifting changes the ownership seen in the container
+const (
+	ShiftModeAuto   = "auto"
+	ShiftModeAlways = "always"
+	ShiftModeNever  = "never"
+)
+
+var ErrUnknownShiftMode = errors.New("unknown shift mode")
+
+// kernelFeaturesShift are the kernel features reported by LXD which allow shifting of disk devices
+var kernelFeaturesShift = []string{"idmapped_mounts", "shiftfs"}
+
+// detectShift checks if LXD reports a kernel feature which allows shifting of disk devices
+func (s RuntimeServer) detectShift() bool {
+	server, _, err := s.lxf.GetServer().GetServer()
+	if err != nil {
+		log.WithError(err).Warn("unable to detect shiftfs or idmapped mount support")
+		return false
+	}
+
+	for _, f := range kernelFeaturesShift {
+		if server.Environment.KernelFeatures[f] == "true" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// shiftMounts decides depending on the shift mode if host path mounts should be shifted. An unset mode never shifts
+func (s RuntimeServer) shiftMounts() bool {
+	switch s.criConfig.LXEShiftMode {
+	case ShiftModeAlways:
+		return true
+	case ShiftModeAuto:
+		return s.shiftSupported
+	default:
+		return false
+	}
+}
+
+// applyShift sets shift=true on the host path disk device if the container is unprivileged and shifting is enabled
+func (s RuntimeServer) applyShift(disk *device.Disk, privileged bool) {
+	if privileged {
+		return
+	}
+
+	// kubelet provided volumes can be forced to be shifted
+	if s.shiftMounts() || (s.config().LXEShiftKubeletVolumes && isKubeletVolume(disk.Source)) {
+		disk.Shift = true
+	}
+}
@@ -0,0 +1,54 @@
+package cri
+
+import (
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf/device"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeServer_detectShift(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+
+	fakeServer.GetServerReturns(&api.Server{}, "", nil)
+	assert.False(t, s.detectShift())
+
+	srv := &api.Server{}
+	srv.Environment.KernelFeatures = map[string]string{"shiftfs": "true"}
+	fakeServer.GetServerReturns(srv, "", nil)
+	assert.True(t, s.detectShift())
+}
+
+func TestRuntimeServer_applyShift(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+
+	for _, tc := range []struct {
+		mode       string
+		supported  bool
+		kubelet    bool
+		source     string
+		privileged bool
+		exp        bool
+	}{
+		{ShiftModeAuto, true, false, "/srv", false, true},
+		{ShiftModeAuto, true, false, "/srv", true, false},
+		{ShiftModeAuto, false, false, "/srv", false, false},
+		{ShiftModeAlways, false, false, "/srv", false, true},
+		{ShiftModeNever, true, false, "/srv", false, false},
+		{"", true, false, "/srv", false, false},
+		{ShiftModeNever, false, true, "/var/lib/kubelet/pods/uid/volumes/kubernetes.io~secret/foo", false, true},
+	} {
+		s.criConfig.LXEShiftMode = tc.mode
+		s.criConfig.LXEShiftKubeletVolumes = tc.kubelet
+		s.shiftSupported = tc.supported
+
+		disk := &device.Disk{Source: tc.source}
+		s.applyShift(disk, tc.privileged)
+		assert.Equal(t, tc.exp, disk.Shift, tc)
+	}
+}
@@ -0,0 +1,116 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/automaticserver/lxe/lxf/lxo"
+	"github.com/coreos/go-systemd/daemon"
+)
+
+var ErrForcedShutdown = errors.New("shutdown forced")
+
+// goLoop runs the background loop, a shutdown waits till it returned
+func (s RuntimeServer) goLoop(loop func()) {
+	s.loops.Add(1)
+
+	go func() {
+		defer s.loops.Done()
+
+		loop()
+	}()
+}
+
+// waitLoops stops the background loops and waits till they returned or ctx is done
+func (s RuntimeServer) waitLoops(ctx context.Context) error {
+	close(s.stopping)
+
+	done := make(chan struct{})
+
+	go func() {
+		s.loops.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background tasks still running: %w", ctx.Err())
+	}
+}
+
+// Shutdown stops LXE gracefully: the CRI socket stops accepting calls, then the running calls, the background loops,
+// the exports to the image cache and the LXD operations in flight are waited for till ctx is done. The loops save their
+// state, then the LXD event listener and all servers are closed. If ctx was done before everything finished, the
+// remaining calls are cancelled and ErrForcedShutdown is returned
+func (c *Server) Shutdown(ctx context.Context) error {
+	notify(daemon.SdNotifyStopping)
+	log.Info("shutting down, waiting for running calls and operations")
+
+	// readiness fails and the watchdog keepalives stop from now on
+	atomic.StoreInt32(&c.serving, 0)
+
+	var forced error
+
+	done := make(chan struct{})
+
+	go func() {
+		c.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		forced = fmt.Errorf("%w: CRI calls still running: %v", ErrForcedShutdown, ctx.Err())
+	}
+
+	err := c.runtime.waitLoops(ctx)
+	if err != nil && forced == nil {
+		forced = fmt.Errorf("%w: %v", ErrForcedShutdown, err)
+	}
+
+	err = lxo.Drain(ctx)
+	if err != nil && forced == nil {
+		forced = fmt.Errorf("%w: %v", ErrForcedShutdown, err)
+	}
+
+	c.client.Close()
+
+	if c.runtime.auditLog != nil {
+		err = c.runtime.auditLog.close()
+		if err != nil {
+			log.WithError(err).Warn("unable to close audit log")
+		}
+	}
+
+	if c.runtime.journal != nil {
+		err = c.runtime.journal.close()
+		if err != nil {
+			log.WithError(err).Warn("unable to close journal")
+		}
+	}
+
+	if c.cniOutputs != nil {
+		err = c.cniOutputs.close()
+		if err != nil {
+			log.WithError(err).Warn("unable to close cni output files")
+		}
+	}
+
+	err = c.Stop()
+	if err != nil {
+		return err
+	}
+
+	if forced != nil {
+		return forced
+	}
+
+	log.Info("shut down gracefully")
+
+	return nil
+}
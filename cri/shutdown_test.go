@@ -0,0 +1,120 @@
+package cri
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/automaticserver/lxe/cri/crifakes"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestRuntimeServer_waitLoops(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+
+	stopped := false
+
+	s.goLoop(func() {
+		<-s.stopping
+		stopped = true
+	})
+
+	assert.NoError(t, s.waitLoops(context.Background()))
+	assert.True(t, stopped)
+}
+
+func TestRuntimeServer_waitLoops_Timeout(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+	block := make(chan struct{})
+
+	defer close(block)
+
+	s.goLoop(func() {
+		<-block
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.Error(t, s.waitLoops(ctx))
+}
+
+// testShutdownServer returns a server listening on a unix socket in dir
+func testShutdownServer(t *testing.T, dir string) (*Server, *crifakes.FakeClient) {
+	rt, fake, _ := testRuntimeServer()
+
+	sock := filepath.Join(dir, "lxe.sock")
+
+	l, err := net.Listen("unix", sock)
+	assert.NoError(t, err)
+
+	s := &Server{
+		server:       grpc.NewServer(),
+		runtime:      rt,
+		client:       fake,
+		sock:         l,
+		criConfig:    &Config{UnixSocket: sock},
+		stopWatchdog: make(chan struct{}),
+		serving:      1,
+	}
+
+	go s.server.Serve(l) // nolint: errcheck
+
+	return s, fake
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-shutdown")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	s, fake := testShutdownServer(t, dir)
+
+	assert.NoError(t, s.Shutdown(context.Background()))
+	assert.False(t, s.isServing())
+	assert.Equal(t, 1, fake.CloseCallCount())
+
+	_, err = os.Stat(s.criConfig.UnixSocket)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestServer_Shutdown_Forced(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-shutdown")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	s, _ := testShutdownServer(t, dir)
+	block := make(chan struct{})
+
+	defer close(block)
+
+	s.runtime.goLoop(func() {
+		<-block
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = s.Shutdown(ctx)
+	assert.True(t, errors.Is(err, ErrForcedShutdown))
+
+	// everything is stopped anyway
+	_, err = os.Stat(s.criConfig.UnixSocket)
+	assert.True(t, os.IsNotExist(err))
+}
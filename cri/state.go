@@ -0,0 +1,112 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// runtimeState is the in-memory state which is saved on shutdown and restored at startup, so a restart doesn't reset
+// it. Everything else LXE knows is kept in LXD
+type runtimeState struct {
+	// Orphans and StaleNetns are when the orphaned containers and stale network namespaces were first seen, so their
+	// grace period continues after a restart
+	Orphans    map[string]time.Time `json:"orphans,omitempty"`
+	StaleNetns map[string]time.Time `json:"staleNetns,omitempty"`
+}
+
+// loadState reads the state from the file, a missing file is an empty state
+func loadState(path string) (*runtimeState, error) {
+	st := &runtimeState{}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return st, nil
+		}
+
+		return nil, err
+	}
+
+	err = json.Unmarshal(b, st)
+	if err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
+
+// save writes the state to the file. It's written to a temporary file first, so an interrupted write keeps the
+// previous state
+func (st *runtimeState) save(path string) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700) // nolint: gomnd
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+
+	err = ioutil.WriteFile(tmp, b, 0600) // nolint: gomnd
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// restore continues tracking the orphans of the saved state
+func (t *orphanTracker) restore(st *runtimeState) {
+	for id, first := range st.Orphans {
+		t.seen[id] = first
+	}
+
+	for path, first := range st.StaleNetns {
+		t.seenNetns[path] = first
+	}
+}
+
+// saveOrphans saves the tracked orphans to the state file, if there's one
+func (s RuntimeServer) saveOrphans(t *orphanTracker) {
+	path := s.criConfig.LXEStateFile
+	if path == "" {
+		return
+	}
+
+	st, err := loadState(path)
+	if err != nil {
+		log.WithError(err).Warn("unable to read state, overwriting it")
+
+		st = &runtimeState{}
+	}
+
+	st.Orphans = t.seen
+	st.StaleNetns = t.seenNetns
+
+	err = st.save(path)
+	if err != nil {
+		log.WithError(err).WithField("path", path).Warn("unable to save state")
+	}
+}
+
+// restoreOrphans restores the tracked orphans from the state file, if there's one
+func (s RuntimeServer) restoreOrphans(t *orphanTracker) {
+	path := s.criConfig.LXEStateFile
+	if path == "" {
+		return
+	}
+
+	st, err := loadState(path)
+	if err != nil {
+		log.WithError(err).WithField("path", path).Warn("unable to read state, orphans are tracked from now on")
+		return
+	}
+
+	t.restore(st)
+}
@@ -0,0 +1,67 @@
+package cri
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_runtimeState(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-state")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "lib", "state.json")
+
+	st, err := loadState(path)
+	assert.NoError(t, err)
+	assert.Equal(t, &runtimeState{}, st)
+
+	first := time.Date(2020, 8, 25, 0, 0, 0, 0, time.UTC)
+	st.Orphans = map[string]time.Time{"abc": first}
+
+	assert.NoError(t, st.save(path))
+
+	st, err = loadState(path)
+	assert.NoError(t, err)
+	assert.True(t, first.Equal(st.Orphans["abc"]))
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("{"), 0600))
+
+	_, err = loadState(path)
+	assert.Error(t, err)
+}
+
+func TestRuntimeServer_saveOrphans(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-state")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	s, _, _ := testRuntimeServer()
+	first := time.Now().Add(-time.Minute).UTC()
+
+	// without state file nothing is saved
+	t1 := newOrphanTracker(time.Hour)
+	t1.seen["abc"] = first
+	s.saveOrphans(t1)
+
+	s.criConfig.LXEStateFile = filepath.Join(dir, "state.json")
+	t1.seenNetns["/run/netns/sb_0123abcd"] = first
+	s.saveOrphans(t1)
+
+	// the grace period continues after a restart
+	t2 := newOrphanTracker(time.Hour)
+	s.restoreOrphans(t2)
+	assert.True(t, first.Equal(t2.seen["abc"]))
+	assert.True(t, first.Equal(t2.seenNetns["/run/netns/sb_0123abcd"]))
+}
@@ -0,0 +1,174 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/automaticserver/lxe/network"
+	"github.com/lxc/lxd/shared/api"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+// These are the reasons reported in the runtime conditions
+const (
+	ReasonLXDUnreachable         = "LXDUnreachable"
+	ReasonStoragePoolUnavailable = "StoragePoolUnavailable"
+	ReasonIPPoolExhausted        = "IPPoolExhausted"
+	ReasonCNIConfigMissing       = "CNIConfigMissing"
+	ReasonBridgeMissing          = "BridgeMissing"
+	ReasonNetworkPluginNotReady  = "NetworkPluginNotReady"
+)
+
+const (
+	// storagePoolCreated is the status of a storage pool ready for use
+	storagePoolCreated = "Created"
+)
+
+var (
+	ErrStoragePoolUnavailable = errors.New("storage pool unavailable")
+)
+
+// runtimeCondition reports the runtime as ready if LXD is reachable and the storage pools used by LXE are available
+func (s RuntimeServer) runtimeCondition(lxdErr error) *rtApi.RuntimeCondition {
+	cond := &rtApi.RuntimeCondition{
+		Type:   rtApi.RuntimeReady,
+		Status: true,
+	}
+
+	if lxdErr != nil {
+		cond.Status = false
+		cond.Reason = ReasonLXDUnreachable
+		cond.Message = lxdErr.Error()
+
+		return cond
+	}
+
+	err := s.checkStoragePools()
+	if err != nil {
+		cond.Status = false
+		cond.Reason = ReasonStoragePoolUnavailable
+		cond.Message = err.Error()
+	}
+
+	return cond
+}
+
+// networkCondition reports the network as ready if the network plugin is. An exhausted ip pool doesn't make the network
+// unusable for existing pods, so it's only noted in the condition
+func (s RuntimeServer) networkCondition() *rtApi.RuntimeCondition {
+	cond := &rtApi.RuntimeCondition{
+		Type:   rtApi.NetworkReady,
+		Status: true,
+	}
+
+	_, plugin := s.networks.Current()
+
+	err := plugin.Status()
+	if err == nil {
+		return cond
+	}
+
+	cond.Message = err.Error()
+
+	switch {
+	case errors.Is(err, network.ErrIPPoolExhausted):
+		cond.Reason = ReasonIPPoolExhausted
+
+		return cond
+	case errors.Is(err, network.ErrNoNetworksFound):
+		cond.Reason = ReasonCNIConfigMissing
+	case errors.Is(err, network.ErrBridgeMissing), errors.Is(err, network.ErrNotBridge):
+		cond.Reason = ReasonBridgeMissing
+	default:
+		cond.Reason = ReasonNetworkPluginNotReady
+	}
+
+	cond.Status = false
+
+	return cond
+}
+
+// usedStoragePools returns the storage pools LXE creates root disks and volumes on. Pools only requested by pod
+// annotations aren't known
+func (s RuntimeServer) usedStoragePools() ([]string, error) {
+	root, err := s.profilesRootPool("")
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []string{s.criConfig.LXDScratchPool}
+	for _, pool := range s.handlerPools {
+		candidates = append(candidates, pool)
+	}
+
+	for name, h := range s.runtimeHandlers {
+		if len(h.Profiles) == 0 {
+			continue
+		}
+
+		pool, err := s.profilesRootPool(name)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, pool)
+	}
+
+	sort.Strings(candidates)
+
+	pools := []string{root}
+	seen := map[string]bool{root: true}
+
+	for _, pool := range candidates {
+		if pool != "" && !seen[pool] {
+			seen[pool] = true
+			pools = append(pools, pool)
+		}
+	}
+
+	return pools, nil
+}
+
+// checkStoragePools checks if the used storage pools exist, are created and report their resources. A pool whose
+// backing storage vanished fails the latter
+func (s RuntimeServer) checkStoragePools() error {
+	pools, err := s.usedStoragePools()
+	if err != nil {
+		return err
+	}
+
+	server := s.lxf.GetServer()
+
+	for _, name := range pools {
+		pool, _, err := server.GetStoragePool(name)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrStoragePoolUnavailable, name, err)
+		}
+
+		if pool != nil && pool.Status != "" && pool.Status != storagePoolCreated {
+			return fmt.Errorf("%w: %s is %s", ErrStoragePoolUnavailable, name, pool.Status)
+		}
+
+		_, err = server.GetStoragePoolResources(name)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrStoragePoolUnavailable, name, err)
+		}
+	}
+
+	return nil
+}
+
+// runtimeInfo returns information about the LXD host for the verbose status
+func runtimeInfo(server *api.Server) map[string]string {
+	env := server.Environment
+
+	return map[string]string{
+		"lxdVersion":     env.ServerVersion,
+		"storageDriver":  env.Storage,
+		"storageVersion": env.StorageVersion,
+		"kernel":         env.Kernel,
+		"kernelVersion":  env.KernelVersion,
+		"clustered":      fmt.Sprint(env.ServerClustered),
+	}
+}
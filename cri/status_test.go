@@ -0,0 +1,82 @@
+package cri
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/automaticserver/lxe/network"
+	"github.com/automaticserver/lxe/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+// statusPlugin is a network plugin whose status is set by the test
+type statusPlugin struct {
+	network.Plugin
+	err error
+}
+
+func (p *statusPlugin) Status() error {
+	return p.err
+}
+
+func TestRuntimeServer_Status(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	plugin := &statusPlugin{}
+	s.networks = newNetworkPlugins("gen", plugin)
+
+	srv := &api.Server{}
+	srv.Environment.ServerVersion = "4.5"
+	srv.Environment.Storage = "zfs"
+	fakeServer.GetServerReturns(srv, "", nil)
+	fakeServer.GetStoragePoolReturns(&api.StoragePool{Status: storagePoolCreated}, "", nil)
+
+	res, err := s.Status(ctx, &rtApi.StatusRequest{Verbose: true})
+	assert.NoError(t, err)
+	assert.True(t, res.Status.Conditions[0].Status)
+	assert.True(t, res.Status.Conditions[1].Status)
+	assert.Equal(t, "4.5", res.Info["lxdVersion"])
+	assert.Equal(t, "zfs", res.Info["storageDriver"])
+
+	fakeServer.GetStoragePoolReturns(&api.StoragePool{Status: "Errored"}, "", nil)
+	plugin.err = fmt.Errorf("%w in /etc/cni/net.d", network.ErrNoNetworksFound)
+
+	res, err = s.Status(ctx, &rtApi.StatusRequest{})
+	assert.NoError(t, err)
+	assert.False(t, res.Status.Conditions[0].Status)
+	assert.Equal(t, ReasonStoragePoolUnavailable, res.Status.Conditions[0].Reason)
+	assert.False(t, res.Status.Conditions[1].Status)
+	assert.Equal(t, ReasonCNIConfigMissing, res.Status.Conditions[1].Reason)
+	assert.Nil(t, res.Info)
+
+	fakeServer.GetServerReturns(nil, "", errors.New("connection refused"))
+	plugin.err = network.ErrIPPoolExhausted
+
+	res, err = s.Status(ctx, &rtApi.StatusRequest{Verbose: true})
+	assert.NoError(t, err)
+	assert.Equal(t, ReasonLXDUnreachable, res.Status.Conditions[0].Reason)
+	assert.Equal(t, "connection refused", res.Status.Conditions[0].Message)
+	assert.True(t, res.Status.Conditions[1].Status, "an exhausted ip pool keeps the network ready")
+	assert.Equal(t, ReasonIPPoolExhausted, res.Status.Conditions[1].Reason)
+}
+
+func TestRuntimeServer_usedStoragePools(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	s.criConfig.LXDProfiles = []string{"default"}
+	s.criConfig.LXDScratchPool = "scratch"
+	s.handlerPools = map[string]string{"fast": "nvme", "local": "default"}
+	fakeServer.GetProfileReturns(&api.Profile{}, "", nil)
+
+	pools, err := s.usedStoragePools()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"default", "nvme", "scratch"}, pools)
+
+	fakeServer.GetStoragePoolResourcesReturns(nil, shared.NewErrNotFound())
+	assert.True(t, errors.Is(s.checkStoragePools(), ErrStoragePoolUnavailable))
+}
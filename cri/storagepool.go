@@ -0,0 +1,210 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/device"
+	"github.com/automaticserver/lxe/shared"
+)
+
+const (
+	// rootDiskName is the name of the root disk device in the default profile, a device of the same name in the sandbox
+	// profile replaces it
+	rootDiskName = "root"
+	// defaultRootPool is used if none of the profiles has a root disk
+	defaultRootPool = "default"
+)
+
+var (
+	ErrUnknownStoragePool  = errors.New("unknown storage pool")
+	ErrInvalidHandlerPool  = errors.New("invalid runtime handler pool")
+	ErrStoragePoolTooSmall = errors.New("storage pool too small")
+)
+
+// parseHandlerPools parses a list of handler=pool entries
+func parseHandlerPools(entries []string) (map[string]string, error) {
+	pools := make(map[string]string, len(entries))
+
+	for _, e := range entries {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("%w: entry %q must be in the form handler=pool", ErrInvalidHandlerPool, e)
+		}
+
+		pools[parts[0]] = parts[1]
+	}
+
+	return pools, nil
+}
+
+// storagePool returns the storage pool for the root disks of a pod. The pod annotation has priority over the pool of
+// the runtime handler, which is set by the pod's runtime class. Empty if neither is set, so the pool of the root disk in
+// the profiles is used
+func (s RuntimeServer) storagePool(handler string, annotations map[string]string) (string, error) {
+	pool, has := annotation.StoragePool.Get(annotations)
+	if !has {
+		pool = s.handlerPools[handler]
+	}
+
+	if pool == "" {
+		return "", nil
+	}
+
+	err := s.checkStoragePool(pool)
+	if err != nil {
+		return "", err
+	}
+
+	return pool, nil
+}
+
+// checkStoragePool checks if the storage pool exists, so a pod isn't accepted whose containers can't be created
+func (s RuntimeServer) checkStoragePool(pool string) error {
+	_, _, err := s.lxf.GetServer().GetStoragePool(pool)
+	if err != nil {
+		if shared.IsErrNotFound(err) {
+			return fmt.Errorf("%w: %s", ErrUnknownStoragePool, pool)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// checkStoragePoolSize checks if a root disk of the size fits on the storage pool at all. As most pools are thin
+// provisioned, the free space isn't considered
+func (s RuntimeServer) checkStoragePoolSize(pool string, size int64) error {
+	res, err := s.lxf.GetServer().GetStoragePoolResources(pool)
+	if err != nil {
+		return err
+	}
+
+	if res != nil && res.Space.Total > 0 && uint64(size) > res.Space.Total {
+		return fmt.Errorf("%w: %s has %d bytes, requested %d", ErrStoragePoolTooSmall, pool, res.Space.Total, size)
+	}
+
+	return nil
+}
+
+// profilesRootPool returns the storage pool of the root disk in the profiles of the runtime handler, the last one wins
+func (s RuntimeServer) profilesRootPool(handler string) (string, error) {
+	pool := defaultRootPool
+
+	for _, name := range s.handlerProfiles(handler) {
+		p, _, err := s.lxf.GetServer().GetProfile(name)
+		if err != nil {
+			return "", err
+		}
+
+		for _, d := range p.Devices {
+			if d["type"] == device.DiskType && d["path"] == "/" && d["pool"] != "" {
+				pool = d["pool"]
+			}
+		}
+	}
+
+	return pool, nil
+}
+
+// rootDiskSize returns the size of the root disks requested by the pod annotation, 0 if not set
+func rootDiskSize(annotations map[string]string) (int64, error) {
+	raw, has := annotation.EphemeralStorage.Get(annotations)
+	if !has {
+		return 0, nil
+	}
+
+	return annotation.ParseSize(raw)
+}
+
+// rootDisk returns the root disk device for the sandbox profile, which replaces the root disk of the default profile.
+// Returns nil if neither a pool, a size nor a readonly root disk are requested, so the root disk of the profiles is
+// used as is
+func (s RuntimeServer) rootDisk(handler string, annotations map[string]string, readonly bool) (*device.Disk, error) {
+	pool, err := s.storagePool(handler, annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := rootDiskSize(annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	if pool == "" && size == 0 && !readonly {
+		return nil, nil
+	}
+
+	if pool == "" {
+		// a root disk must always name its pool
+		pool, err = s.profilesRootPool(handler)
+		if err != nil {
+			return nil, fmt.Errorf("unable to find root disk pool: %w", err)
+		}
+	}
+
+	disk := &device.Disk{
+		KeyName:  rootDiskName,
+		Path:     "/",
+		Pool:     pool,
+		Readonly: readonly,
+	}
+
+	if size > 0 {
+		err = s.checkStoragePoolSize(pool, size)
+		if err != nil {
+			return nil, err
+		}
+
+		disk.Size = strconv.FormatInt(size, 10)
+	}
+
+	return disk, nil
+}
+
+// resizeRootDisk sets the size of the root disk in the sandbox profile to the one requested by the pod annotation.
+// Returns false if it didn't change, e.g. because the pod doesn't request a size
+func (s RuntimeServer) resizeRootDisk(sb *lxf.Sandbox) (bool, error) {
+	size, err := rootDiskSize(sb.Annotations)
+	if err != nil || size == 0 {
+		return false, err
+	}
+
+	var disk *device.Disk
+
+	for _, d := range sb.Devices {
+		if dd, is := d.(*device.Disk); is && dd.KeyName == rootDiskName {
+			disk = dd
+		}
+	}
+
+	if disk == nil {
+		// the sandbox was created before the size was known
+		pool, err := s.profilesRootPool(sb.RuntimeHandler)
+		if err != nil {
+			return false, fmt.Errorf("unable to find root disk pool: %w", err)
+		}
+
+		disk = &device.Disk{KeyName: rootDiskName, Path: "/", Pool: pool}
+		sb.Devices.Upsert(disk)
+	}
+
+	sizeS := strconv.FormatInt(size, 10)
+	if disk.Size == sizeS {
+		return false, nil
+	}
+
+	err = s.checkStoragePoolSize(disk.Pool, size)
+	if err != nil {
+		return false, err
+	}
+
+	disk.Size = sizeS
+
+	return true, nil
+}
@@ -0,0 +1,112 @@
+package cri
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/device"
+	"github.com/automaticserver/lxe/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseHandlerPools(t *testing.T) {
+	t.Parallel()
+
+	pools, err := parseHandlerPools([]string{"fast=nvme", "bulk=hdd"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"fast": "nvme", "bulk": "hdd"}, pools)
+
+	for _, e := range []string{"fast", "=nvme", "fast="} {
+		_, err = parseHandlerPools([]string{e})
+		assert.True(t, errors.Is(err, ErrInvalidHandlerPool), e)
+	}
+}
+
+func TestRuntimeServer_storagePool(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	s.handlerPools = map[string]string{"fast": "nvme"}
+
+	pool, err := s.storagePool("", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, pool)
+	assert.Equal(t, 0, fakeServer.GetStoragePoolCallCount())
+
+	pool, err = s.storagePool("fast", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "nvme", pool)
+
+	pool, err = s.storagePool("fast", map[string]string{annotation.StoragePool.Name: "hdd"})
+	assert.NoError(t, err)
+	assert.Equal(t, "hdd", pool, "the annotation has priority")
+	assert.Equal(t, "hdd", fakeServer.GetStoragePoolArgsForCall(1))
+}
+
+func TestRuntimeServer_storagePool_Unknown(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	fakeServer.GetStoragePoolReturns(nil, "", shared.NewErrNotFound())
+
+	_, err := s.storagePool("", map[string]string{annotation.StoragePool.Name: "missing"})
+	assert.True(t, errors.Is(err, ErrUnknownStoragePool))
+
+	s.criConfig.LXDVolumePools = []string{"*"}
+	_, err = s.attachVolumes("ns", map[string]string{annotation.Volumes.Name: "missing/data:/data"})
+	assert.True(t, errors.Is(err, ErrUnknownStoragePool))
+	assert.Equal(t, 0, fakeServer.CreateStoragePoolVolumeCallCount())
+}
+
+func TestRuntimeServer_rootDisk(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	s.criConfig.LXDProfiles = []string{"default"}
+	fakeServer.GetProfileReturns(&api.Profile{ProfilePut: api.ProfilePut{Devices: map[string]map[string]string{
+		"root": {"type": "disk", "path": "/", "pool": "zfs"},
+	}}}, "", nil)
+	fakeServer.GetStoragePoolResourcesReturns(&api.ResourcesStoragePool{Space: api.ResourcesStoragePoolSpace{Total: 100 << 30}}, nil)
+
+	disk, err := s.rootDisk("", nil, false)
+	assert.NoError(t, err)
+	assert.Nil(t, disk, "the root disk of the profiles is kept")
+
+	disk, err = s.rootDisk("", map[string]string{annotation.EphemeralStorage.Name: "10Gi"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, &device.Disk{KeyName: rootDiskName, Path: "/", Pool: "zfs", Size: "10737418240"}, disk)
+
+	disk, err = s.rootDisk("", map[string]string{annotation.StoragePool.Name: "fast"}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, &device.Disk{KeyName: rootDiskName, Path: "/", Pool: "fast", Readonly: true}, disk)
+
+	_, err = s.rootDisk("", map[string]string{annotation.EphemeralStorage.Name: "1Ti"}, false)
+	assert.True(t, errors.Is(err, ErrStoragePoolTooSmall))
+}
+
+func TestRuntimeServer_resizeRootDisk(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	fakeServer.GetProfileReturns(&api.Profile{}, "", nil)
+
+	sb := &lxf.Sandbox{}
+
+	resized, err := s.resizeRootDisk(sb)
+	assert.NoError(t, err)
+	assert.False(t, resized)
+
+	sb.Annotations = map[string]string{annotation.EphemeralStorage.Name: "1Gi"}
+
+	resized, err = s.resizeRootDisk(sb)
+	assert.NoError(t, err)
+	assert.True(t, resized)
+	assert.Equal(t, device.Devices{&device.Disk{KeyName: rootDiskName, Path: "/", Pool: defaultRootPool, Size: "1073741824"}}, sb.Devices)
+
+	resized, err = s.resizeRootDisk(sb)
+	assert.NoError(t, err)
+	assert.False(t, resized, "the size didn't change")
+}
@@ -2,11 +2,13 @@ package cri // import "github.com/automaticserver/lxe/cri"
 
 import (
 	"bytes"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
+	"net/http"
 	"net/url"
 	"os/exec"
 	"strings"
@@ -20,12 +22,15 @@ import (
 	utilExec "k8s.io/utils/exec"
 )
 
+var ErrStreamingTLSIncomplete = errors.New("both streaming tls certificate and key are required")
+
 // streamService implements streaming.Runtime.
 type streamService struct {
 	streaming.Runtime
 	conf          streaming.Config
 	runtimeServer *RuntimeServer // needed by Exec() endpoint
 	streamServer  streaming.Server
+	server        *http.Server
 }
 
 func setupStreamService(criConfig *Config, runtime *RuntimeServer) error {
@@ -43,6 +48,7 @@ func setupStreamService(criConfig *Config, runtime *RuntimeServer) error {
 			var aerr *net.AddrError
 			if errors.As(err, &aerr) && aerr.Err == "missing port in address" {
 				// we allow port to be missing here
+				bHost = criConfig.LXEStreamingBaseURL
 			} else {
 				return err
 			}
@@ -75,11 +81,34 @@ func setupStreamService(criConfig *Config, runtime *RuntimeServer) error {
 	// Prepare streaming server
 	sService.conf = streaming.DefaultConfig
 	sService.conf.Addr = criConfig.LXEStreamingBindAddr
+	if criConfig.LXEStreamingIdleTimeout > 0 {
+		sService.conf.StreamIdleTimeout = criConfig.LXEStreamingIdleTimeout
+	}
+	if criConfig.LXEStreamingCreationTimeout > 0 {
+		sService.conf.StreamCreationTimeout = criConfig.LXEStreamingCreationTimeout
+	}
 	sService.conf.BaseURL = &url.URL{
 		Scheme: "http",
 		Host:   net.JoinHostPort(bHost, bPort),
 	}
 
+	if (criConfig.LXEStreamingTLSCert == "") != (criConfig.LXEStreamingTLSKey == "") {
+		return ErrStreamingTLSIncomplete
+	}
+
+	if criConfig.LXEStreamingTLSCert != "" {
+		certs, err := newCertReloader(criConfig.LXEStreamingTLSCert, criConfig.LXEStreamingTLSKey)
+		if err != nil {
+			return err
+		}
+
+		sService.conf.TLSConfig = &tls.Config{
+			GetCertificate: certs.GetCertificate,
+			MinVersion:     tls.VersionTLS12,
+		}
+		sService.conf.BaseURL.Scheme = "https"
+	}
+
 	runtime.stream = sService
 
 	sService.streamServer, err = streaming.NewServer(sService.conf, runtime.stream)
@@ -87,37 +116,74 @@ func setupStreamService(criConfig *Config, runtime *RuntimeServer) error {
 		return err
 	}
 
+	sService.server = &http.Server{
+		Handler:           sService.streamServer,
+		TLSConfig:         sService.conf.TLSConfig,
+		ReadHeaderTimeout: sService.conf.StreamCreationTimeout,
+	}
+
 	return nil
 }
 
+// serve listens on the bind address and serves the streams, with tls if a certificate is configured. It doesn't use
+// streamServer.Start, as that replaces the host of the base url with the bind address
 func (ss *streamService) serve() error {
-	log.WithFields(logrus.Fields{"endpoint": ss.conf.Addr, "baseurl": ss.conf.BaseURL}).Info("started streaming server")
-
-	err := ss.streamServer.Start(true)
+	sock, err := net.Listen("tcp", ss.conf.Addr)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	log.WithFields(logrus.Fields{"endpoint": ss.conf.Addr, "baseurl": ss.conf.BaseURL}).Info("started streaming server")
+
+	if ss.conf.TLSConfig != nil {
+		// the certificate is provided by the tls config
+		err = ss.server.ServeTLS(sock, "", "")
+	} else {
+		err = ss.server.Serve(sock)
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// stop closes the streaming listener and the running streams
+func (ss *streamService) stop() error {
+	return ss.server.Close()
+}
+
+// newSession starts a stream session with the configured timeouts
+func (ss streamService) newSession() *streamSession {
+	cfg := ss.runtimeServer.criConfig
+	return newStreamSession(cfg.LXEStreamingIdleTimeout, cfg.LXEStreamingMaxDuration)
 }
 
 func (ss streamService) Exec(containerID string, cmd []string, stdinR io.Reader, stdout, stderr io.WriteCloser, tty bool, resize <-chan remotecommand.TerminalSize) error {
 	log := log.WithField("container", containerID).WithField("cmd", cmd)
 
+	ses := ss.newSession()
+	defer ses.close()
+
 	var stdin io.ReadCloser
 	if stdinR == nil {
 		stdin = ioutil.NopCloser(bytes.NewReader(nil))
 	} else {
-		stdin = ioutil.NopCloser(stdinR)
+		stdin = ioutil.NopCloser(ses.reader(stdinR))
 	}
 
 	interactive := (stdinR != nil)
 
-	code, err := ss.runtimeServer.lxf.Exec(containerID, cmd, stdin, stdout, stderr, interactive, tty, 0, resize)
+	code, err := ss.runtimeServer.lxf.Exec(ses.ctx, containerID, cmd, stdin, ses.writer(stdout), ses.writer(stderr), interactive, tty, resize)
 
 	log.Debugf("received exit code %v", code)
 	log = log.WithField("exit", code)
 
+	if ses.Err() != nil {
+		err = ses.Err()
+	}
+
 	if err != nil || code != 0 {
 		return &utilExec.CodeExitError{
 			Err:  AnnErr(log, err, "error executing command"),
@@ -148,8 +214,21 @@ func (ss streamService) PortForward(podSandboxID string, port int32, stream io.R
 	commandString := fmt.Sprintf("socat %s", strings.Join(args, " "))
 	log.WithField("cmd", commandString).Debug("executing port forwarding command")
 
-	command := exec.Command("socat", args...)
-	command.Stdout = stream
+	ses := ss.newSession()
+	defer ses.close()
+
+	// socat is killed by the context, but reading from the stream only ends if it is closed
+	go func() {
+		<-ses.ctx.Done()
+
+		if ses.Err() != nil {
+			log.WithError(ses.Err()).Info("closing port forwarding")
+			stream.Close()
+		}
+	}()
+
+	command := exec.CommandContext(ses.ctx, "socat", args...)
+	command.Stdout = ses.writer(stream)
 
 	stderr := new(bytes.Buffer)
 	command.Stderr = stderr
@@ -166,7 +245,7 @@ func (ss streamService) PortForward(podSandboxID string, port int32, stream io.R
 	}
 
 	go func() {
-		_, err = pools.Copy(inPipe, stream)
+		_, err = pools.Copy(inPipe, ses.reader(stream))
 		if err != nil {
 			log.WithError(err).Error("pipe copy errored")
 		}
@@ -178,6 +257,10 @@ func (ss streamService) PortForward(podSandboxID string, port int32, stream io.R
 	}()
 
 	err = command.Run()
+	if ses.Err() != nil {
+		return AnnErr(log, ses.Err(), "port forwarding ended")
+	}
+
 	if err != nil {
 		return AnnErr(log, err, stderr.String())
 	}
@@ -0,0 +1,157 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrStreamIdle        = errors.New("stream idle timeout reached")
+	ErrStreamMaxDuration = errors.New("stream maximum duration reached")
+	// streamWatchInterval is how often a session checks its timeouts
+	streamWatchInterval = time.Second
+)
+
+// streamSession cancels its context if no data was transferred for the idle timeout or the session exceeds the
+// maximum duration. Clients which vanish without closing the stream would otherwise keep the LXD websockets or port
+// forwards open forever. A timeout of zero disables that limit
+type streamSession struct {
+	ctx          context.Context
+	cancel       context.CancelFunc
+	idleTimeout  time.Duration
+	maxDuration  time.Duration
+	started      time.Time
+	lastActivity int64 // unix nano, accessed atomically
+	reasonMu     sync.Mutex
+	reason       error
+}
+
+// newStreamSession starts a session and its watchdog. The caller must call close when the stream ended
+func newStreamSession(idleTimeout, maxDuration time.Duration) *streamSession {
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+
+	ses := &streamSession{
+		ctx:          ctx,
+		cancel:       cancel,
+		idleTimeout:  idleTimeout,
+		maxDuration:  maxDuration,
+		started:      now,
+		lastActivity: now.UnixNano(),
+	}
+
+	if idleTimeout > 0 || maxDuration > 0 {
+		go ses.watch()
+	}
+
+	return ses
+}
+
+// watch cancels the session as soon as a timeout is reached
+func (s *streamSession) watch() {
+	ticker := time.NewTicker(streamWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case now := <-ticker.C:
+			err := s.check(now)
+			if err != nil {
+				s.reasonMu.Lock()
+				s.reason = err
+				s.reasonMu.Unlock()
+
+				s.cancel()
+
+				return
+			}
+		}
+	}
+}
+
+// check returns the reason if the session has to be ended at the given time
+func (s *streamSession) check(now time.Time) error {
+	if s.maxDuration > 0 && now.Sub(s.started) >= s.maxDuration {
+		return ErrStreamMaxDuration
+	}
+
+	if s.idleTimeout > 0 && now.Sub(time.Unix(0, atomic.LoadInt64(&s.lastActivity))) >= s.idleTimeout {
+		return ErrStreamIdle
+	}
+
+	return nil
+}
+
+// touch records activity on the stream
+func (s *streamSession) touch() {
+	atomic.StoreInt64(&s.lastActivity, time.Now().UnixNano())
+}
+
+// Err returns why the session was ended by the watchdog, nil if it wasn't
+func (s *streamSession) Err() error {
+	s.reasonMu.Lock()
+	defer s.reasonMu.Unlock()
+
+	return s.reason
+}
+
+// close stops the watchdog
+func (s *streamSession) close() {
+	s.cancel()
+}
+
+// reader records activity on every read
+func (s *streamSession) reader(r io.Reader) io.Reader {
+	if r == nil {
+		return nil
+	}
+
+	return &activityReader{r: r, ses: s}
+}
+
+// writer records activity on every write
+func (s *streamSession) writer(w io.WriteCloser) io.WriteCloser {
+	if w == nil {
+		return nil
+	}
+
+	return &activityWriter{w: w, ses: s}
+}
+
+type activityReader struct {
+	r   io.Reader
+	ses *streamSession
+}
+
+func (a *activityReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	if n > 0 {
+		a.ses.touch()
+	}
+
+	return n, err
+}
+
+type activityWriter struct {
+	w   io.WriteCloser
+	ses *streamSession
+}
+
+func (a *activityWriter) Write(p []byte) (int, error) {
+	n, err := a.w.Write(p)
+	if n > 0 {
+		a.ses.touch()
+	}
+
+	return n, err
+}
+
+func (a *activityWriter) Close() error {
+	return a.w.Close()
+}
@@ -0,0 +1,82 @@
+package cri
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nopWriteCloser struct {
+	bytes.Buffer
+}
+
+func (n *nopWriteCloser) Close() error {
+	return nil
+}
+
+func Test_streamSession_check(t *testing.T) {
+	t.Parallel()
+
+	ses := newStreamSession(time.Minute, time.Hour)
+	defer ses.close()
+
+	now := ses.started
+
+	assert.NoError(t, ses.check(now.Add(30*time.Second)))
+	assert.Equal(t, ErrStreamIdle, ses.check(now.Add(time.Minute)))
+
+	ses.touch()
+	assert.NoError(t, ses.check(time.Now().Add(30*time.Second)))
+	assert.Equal(t, ErrStreamMaxDuration, ses.check(now.Add(time.Hour)))
+}
+
+func Test_streamSession_Disabled(t *testing.T) {
+	t.Parallel()
+
+	ses := newStreamSession(0, 0)
+	defer ses.close()
+
+	assert.NoError(t, ses.check(ses.started.Add(1000*time.Hour)))
+}
+
+func Test_streamSession_Activity(t *testing.T) {
+	t.Parallel()
+
+	ses := newStreamSession(time.Minute, 0)
+	defer ses.close()
+
+	// pretend the session was idle since long
+	atomic.StoreInt64(&ses.lastActivity, 0)
+
+	_, err := ioutil.ReadAll(ses.reader(bytes.NewBufferString("data")))
+	assert.NoError(t, err)
+	assert.NoError(t, ses.check(time.Now()))
+
+	atomic.StoreInt64(&ses.lastActivity, 0)
+
+	_, err = ses.writer(&nopWriteCloser{}).Write([]byte("data"))
+	assert.NoError(t, err)
+	assert.NoError(t, ses.check(time.Now()))
+
+	assert.Nil(t, ses.reader(nil))
+	assert.Nil(t, ses.writer(nil))
+}
+
+func Test_streamSession_watch(t *testing.T) {
+	t.Parallel()
+
+	ses := newStreamSession(10*time.Millisecond, 0)
+	defer ses.close()
+
+	select {
+	case <-ses.ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("session was not cancelled")
+	}
+
+	assert.Equal(t, ErrStreamIdle, ses.Err())
+}
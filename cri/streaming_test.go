@@ -0,0 +1,34 @@
+package cri
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_setupStreamService_TLS(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeKeyPair(t, certFile, keyFile, "lxe", time.Now())
+
+	rt, _, _ := testRuntimeServer()
+
+	err := setupStreamService(&Config{
+		LXEStreamingBindAddr: "127.0.0.1:44124",
+		LXEStreamingBaseURL:  "node1.example.com",
+		LXEStreamingTLSCert:  certFile,
+		LXEStreamingTLSKey:   keyFile,
+	}, rt)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://node1.example.com:44124", rt.stream.conf.BaseURL.String())
+	assert.NotNil(t, rt.stream.conf.TLSConfig)
+
+	err = setupStreamService(&Config{LXEStreamingBindAddr: "127.0.0.1:44124", LXEStreamingTLSCert: certFile}, rt)
+	assert.True(t, errors.Is(err, ErrStreamingTLSIncomplete))
+}
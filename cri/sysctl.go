@@ -0,0 +1,112 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/automaticserver/lxe/lxf"
+)
+
+const (
+	// lxdExtensionSysctl is the LXD API extension which provides the linux.sysctl.* config keys
+	lxdExtensionSysctl = "linux_sysctl"
+	cfgLinuxSysctl     = "linux.sysctl"
+	cfgRawLXC          = "raw.lxc"
+)
+
+var (
+	ErrSysctlNotAllowed = errors.New("sysctl not allowed")
+	ErrInvalidSysctl    = errors.New("invalid sysctl")
+
+	// sysctlNameRegexp is the grammar of sysctl names kubernetes accepts, segments separated by . or /
+	sysctlNameRegexp = regexp.MustCompile(`^([a-z0-9]([-_a-z0-9]*[a-z0-9])?[./])*[a-z0-9]([-_a-z0-9]*[a-z0-9])?$`)
+
+	// DefaultSysctlAllowlist contains the sysctls kubelet considers safe to be set by any pod
+	DefaultSysctlAllowlist = []string{
+		"kernel.shm_rmid_forced",
+		"net.ipv4.ip_local_port_range",
+		"net.ipv4.ip_unprivileged_port_start",
+		"net.ipv4.ping_group_range",
+		"net.ipv4.tcp_syncookies",
+	}
+)
+
+// matchesAny checks if the key matches any entry of the list. An entry ending with * matches as prefix
+func matchesAny(list []string, key string) bool {
+	for _, a := range list {
+		if strings.HasSuffix(a, "*") {
+			if strings.HasPrefix(key, strings.TrimSuffix(a, "*")) {
+				return true
+			}
+		} else if a == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateSysctl checks the name and value of the sysctl, so they can't break out of their config key or raw.lxc line.
+// Values may contain spaces and tabs, e.g. for port ranges, but no other control characters
+func validateSysctl(key, value string) error {
+	if !sysctlNameRegexp.MatchString(key) {
+		return fmt.Errorf("%w: name %q", ErrInvalidSysctl, key)
+	}
+
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("%w: %s has an empty value", ErrInvalidSysctl, key)
+	}
+
+	for _, r := range value {
+		if unicode.IsControl(r) && r != '\t' {
+			return fmt.Errorf("%w: value of %s contains control characters", ErrInvalidSysctl, key)
+		}
+	}
+
+	return nil
+}
+
+// applySysctls validates the requested sysctls against the allowlist and renders them into the sandbox config. If LXD
+// supports the linux.sysctl.* config keys they are used, otherwise they are appended as lxc.sysctl entries to raw.lxc
+func (s RuntimeServer) applySysctls(sb *lxf.Sandbox, sysctls map[string]string) error {
+	keys := make([]string, 0, len(sysctls))
+
+	for key := range sysctls {
+		if !matchesAny(s.config().LXESysctlAllowlist, key) {
+			return fmt.Errorf("%w: %s", ErrSysctlNotAllowed, key)
+		}
+
+		err := validateSysctl(key, sysctls[key])
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	// keep the order stable, so raw.lxc doesn't change on every apply
+	sort.Strings(keys)
+
+	native := s.lxf.GetServer().HasExtension(lxdExtensionSysctl)
+
+	for _, key := range keys {
+		value := sysctls[key]
+		sb.Config["user.linux.sysctls."+key] = value
+
+		if native {
+			sb.Config[cfgLinuxSysctl+"."+key] = value
+		} else {
+			lxf.AppendIfSet(&sb.Config, cfgRawLXC, fmt.Sprintf("lxc.sysctl.%s = %s", key, value))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package cri
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/automaticserver/lxe/cri/crifakes"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRuntimeServer() (*RuntimeServer, *crifakes.FakeClient, *lxdfakes.FakeContainerServer) {
+	fake := &crifakes.FakeClient{}
+	fakeServer := &lxdfakes.FakeContainerServer{}
+	fake.GetServerReturns(fakeServer)
+	// the real batch runs concurrently, sequential calls keep the fake call order deterministic
+	fake.BatchStub = func(n int, fn func(i int) error) error {
+		for i := 0; i < n; i++ {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return &RuntimeServer{
+		lxf: fake,
+		criConfig: &Config{
+			LXESysctlAllowlist: DefaultSysctlAllowlist,
+		},
+		loadedHooks: &atomic.Value{},
+		quota:       &atomic.Value{},
+		events:      newContainerEvents(),
+		stopping:    make(chan struct{}),
+		loops:       &sync.WaitGroup{},
+	}, fake, fakeServer
+}
+
+func Test_matchesAny(t *testing.T) {
+	t.Parallel()
+
+	allowlist := []string{"kernel.shm_rmid_forced", "net.core.*"}
+
+	assert.True(t, matchesAny(allowlist, "kernel.shm_rmid_forced"))
+	assert.True(t, matchesAny(allowlist, "net.core.somaxconn"))
+	assert.False(t, matchesAny(allowlist, "kernel.shm_rmid"))
+	assert.False(t, matchesAny(allowlist, "net.ipv4.tcp_syncookies"))
+	assert.False(t, matchesAny(nil, "kernel.shm_rmid_forced"))
+}
+
+func TestRuntimeServer_applySysctls_Native(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	fakeServer.HasExtensionReturns(true)
+
+	sb := &lxf.Sandbox{}
+	sb.Config = map[string]string{}
+
+	err := s.applySysctls(sb, map[string]string{"net.ipv4.tcp_syncookies": "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", sb.Config["linux.sysctl.net.ipv4.tcp_syncookies"])
+	assert.Equal(t, "1", sb.Config["user.linux.sysctls.net.ipv4.tcp_syncookies"])
+	assert.NotContains(t, sb.Config, "raw.lxc")
+	assert.Equal(t, lxdExtensionSysctl, fakeServer.HasExtensionArgsForCall(0))
+}
+
+func TestRuntimeServer_applySysctls_RawLXC(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	fakeServer.HasExtensionReturns(false)
+
+	sb := &lxf.Sandbox{}
+	sb.Config = map[string]string{"raw.lxc": "lxc.include = foo"}
+
+	err := s.applySysctls(sb, map[string]string{
+		"net.ipv4.tcp_syncookies": "1",
+		"kernel.shm_rmid_forced":  "0",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "lxc.include = foo\nlxc.sysctl.kernel.shm_rmid_forced = 0\nlxc.sysctl.net.ipv4.tcp_syncookies = 1", sb.Config["raw.lxc"])
+	assert.NotContains(t, sb.Config, "linux.sysctl.net.ipv4.tcp_syncookies")
+}
+
+func TestRuntimeServer_applySysctls_NotAllowed(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+
+	sb := &lxf.Sandbox{}
+	sb.Config = map[string]string{}
+
+	err := s.applySysctls(sb, map[string]string{"kernel.msgmax": "1"})
+	assert.True(t, errors.Is(err, ErrSysctlNotAllowed))
+	assert.Empty(t, sb.Config)
+	assert.Equal(t, 0, fakeServer.HasExtensionCallCount())
+}
+
+func TestRuntimeServer_applySysctls_Invalid(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	s.criConfig.LXESysctlAllowlist = []string{"net.*", "kernel.shm_rmid_forced", "kernel.shm_rmid_forced\nlxc.apparmor.profile"}
+
+	for key, value := range map[string]string{
+		"kernel.shm_rmid_forced":                       "1\nlxc.apparmor.profile = unconfined",
+		"net.ipv4.tcp_syncookies":                      "1\r",
+		"net.ipv4.ping_group_range":                    "",
+		"net.ipv4.tcp_syncookies = 1\nlxc.cap.drop":    "1",
+		"kernel.shm_rmid_forced\nlxc.apparmor.profile": "unconfined",
+		"net..ipv4": "1",
+	} {
+		sb := &lxf.Sandbox{}
+		sb.Config = map[string]string{}
+
+		err := s.applySysctls(sb, map[string]string{key: value})
+		assert.True(t, errors.Is(err, ErrInvalidSysctl), key)
+		assert.Empty(t, sb.Config, key)
+	}
+
+	assert.Equal(t, 0, fakeServer.HasExtensionCallCount())
+
+	// port ranges are separated by spaces or tabs
+	assert.NoError(t, validateSysctl("net.ipv4.ip_local_port_range", "1024\t65535"))
+	assert.NoError(t, validateSysctl("net/ipv4/ip_local_port_range", "1024 65535"))
+}
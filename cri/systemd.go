@@ -0,0 +1,101 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/activation"
+	"github.com/coreos/go-systemd/v22/daemon"
+)
+
+var ErrActivatedSocketMismatch = errors.New("none of the sockets passed by systemd is the CRI socket")
+
+// activatedListener returns the listener of the CRI socket if systemd passed it with socket activation, nil if LXE
+// wasn't socket activated. The sockets passed by systemd are taken only once
+func activatedListener(sock string) (net.Listener, error) {
+	listeners, err := activation.Listeners()
+	if err != nil {
+		return nil, err
+	}
+
+	var found net.Listener
+
+	passed := []string{}
+
+	for _, l := range listeners {
+		if l == nil {
+			continue
+		}
+
+		if found == nil && l.Addr().Network() == "unix" && l.Addr().String() == sock {
+			found = l
+			continue
+		}
+
+		passed = append(passed, l.Addr().String())
+		l.Close()
+	}
+
+	if found == nil && len(passed) > 0 {
+		return nil, fmt.Errorf("%w: %s, passed: %s", ErrActivatedSocketMismatch, sock, strings.Join(passed, ", "))
+	}
+
+	return found, nil
+}
+
+// notify tells systemd the state of LXE, it does nothing if LXE isn't run as notify service
+func notify(state string) {
+	_, err := daemon.SdNotify(false, state)
+	if err != nil {
+		log.WithError(err).WithField("state", state).Warn("unable to notify systemd")
+	}
+}
+
+// notifyReady tells systemd LXE is ready and starts sending the watchdog keepalives if the service has a watchdog.
+// LXD is reachable and the network plugin is initialized by then, as the server isn't created otherwise
+func (c *Server) notifyReady() {
+	notify(daemon.SdNotifyReady)
+
+	interval, err := daemon.SdWatchdogEnabled(false)
+	if err != nil {
+		log.WithError(err).Warn("unable to read the systemd watchdog interval")
+		return
+	}
+
+	if interval == 0 {
+		return
+	}
+
+	log.WithField("interval", interval).Info("sending systemd watchdog keepalives")
+
+	go c.watchdog(interval/2, c.stopWatchdog) // nolint: gomnd
+}
+
+// watchdog sends a keepalive to systemd every interval while the CRI socket is serving and LXD is reachable, so
+// systemd restarts LXE if it hangs or lost LXD for longer than the watchdog timeout. It returns when stop is closed
+func (c *Server) watchdog(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !c.isServing() {
+				continue
+			}
+
+			err := c.runtime.lxdReachable()
+			if err != nil {
+				log.WithError(err).Warn("LXD unreachable, skipping systemd watchdog keepalive")
+				continue
+			}
+
+			notify(daemon.SdNotifyWatchdog)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package cri
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_activatedListener_NotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+	t.Setenv("LISTEN_FDS", "")
+
+	l, err := activatedListener("/run/lxe.sock")
+	assert.NoError(t, err)
+	assert.Nil(t, l)
+}
+
+func TestServer_watchdog(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	assert.NoError(t, err)
+
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", addr)
+
+	rt, _, fakeServer := testRuntimeServer()
+	s := &Server{runtime: rt, serving: 1}
+	stop := make(chan struct{})
+
+	go s.watchdog(time.Millisecond, stop)
+	defer close(stop)
+
+	buf := make([]byte, 64)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "WATCHDOG=1", string(buf[:n]))
+	assert.Positive(t, fakeServer.GetServerCallCount())
+}
+
+func TestServer_watchdog_LXDUnreachable(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	assert.NoError(t, err)
+
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", addr)
+
+	rt, _, fakeServer := testRuntimeServer()
+	fakeServer.GetServerReturns(nil, "", errors.New("connection refused"))
+	s := &Server{runtime: rt, serving: 1}
+	stop := make(chan struct{})
+
+	go s.watchdog(time.Millisecond, stop)
+	defer close(stop)
+
+	_ = conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, err = conn.Read(make([]byte, 64))
+	assert.Error(t, err, "no keepalive is sent")
+}
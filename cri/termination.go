@@ -0,0 +1,115 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/device"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+// kubelet passes the termination message settings of the container spec as annotations
+const (
+	annTerminationMessagePath   = "io.kubernetes.container.terminationMessagePath"
+	annTerminationMessagePolicy = "io.kubernetes.container.terminationMessagePolicy"
+	// terminationMessagePolicyFallback uses the last log lines if the container failed without termination message
+	terminationMessagePolicyFallback = "FallbackToLogsOnError"
+)
+
+// These are the limits of kubelet for the termination message
+const (
+	maxTerminationMessage      = 4 * 1024
+	maxTerminationMessageLog   = 2 * 1024
+	maxTerminationMessageLines = 80
+)
+
+// recordTerminationMessage reads the termination message of the exited container and stores it, so it's reported by
+// the container status even after the container's files are gone
+func recordTerminationMessage(ctx context.Context, c *lxf.Container) error {
+	msg := terminationMessage(c)
+	if msg == c.TerminationMessage {
+		return nil
+	}
+
+	c.TerminationMessage = msg
+
+	return c.Apply(ctx)
+}
+
+// terminationMessage returns what the container wrote to its termination message path. If it's empty and the policy is
+// FallbackToLogsOnError, the last lines of the console log are returned for a failed container
+func terminationMessage(c *lxf.Container) string {
+	p := c.Annotations[annTerminationMessagePath]
+	if p != "" && path.IsAbs(p) {
+		b, err := readTerminationMessageFile(c, p)
+		if err != nil {
+			log.WithError(err).WithField("containerid", c.ID).WithField("path", p).Debug("unable to read termination message")
+		} else if len(strings.TrimSpace(string(b))) > 0 {
+			return string(b)
+		}
+	}
+
+	if c.Annotations[annTerminationMessagePolicy] != terminationMessagePolicyFallback || c.ExitCode == 0 {
+		return ""
+	}
+
+	b, err := c.ConsoleLogTail(maxTerminationMessageLines, maxTerminationMessageLog)
+	if err != nil {
+		log.WithError(err).WithField("containerid", c.ID).Debug("unable to read console log for termination message")
+		return ""
+	}
+
+	return string(b)
+}
+
+// readTerminationMessageFile reads the file kubelet mounted at the path, which stays on the host after the container
+// stopped. If nothing is mounted there it's read from the root disk of the container
+func readTerminationMessageFile(c *lxf.Container, p string) ([]byte, error) {
+	// the mount was moved the same way as on creation
+	mounted := toLXDDisk(&rtApi.Mount{ContainerPath: p}).Path
+
+	for _, dev := range c.Devices {
+		d, ok := dev.(*device.Disk)
+		if !ok || d.Source == "" || d.Pool != "" || path.Clean(d.Path) != mounted {
+			continue
+		}
+
+		return readHostFileTail(d.Source, maxTerminationMessage)
+	}
+
+	return c.ReadFileTail(p, maxTerminationMessage)
+}
+
+// readHostFileTail returns the last max bytes of the regular file at path, it must not be a symlink
+func readHostFileTail(p string, max int) ([]byte, error) {
+	fi, err := os.Lstat(filepath.Clean(p))
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %v", lxf.ErrNotRegularFile, p)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if fi.Size() > int64(max) {
+		_, err = f.Seek(fi.Size()-int64(max), io.SeekStart)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ioutil.ReadAll(io.LimitReader(f, int64(max)))
+}
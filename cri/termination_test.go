@@ -0,0 +1,71 @@
+package cri
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/device"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_terminationMessage_Mounted(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-termination-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "termination-log")
+	assert.NoError(t, ioutil.WriteFile(p, []byte("config is missing\n"), 0644))
+
+	c := &lxf.Container{}
+	c.Annotations = map[string]string{annTerminationMessagePath: "/dev/termination-log"}
+	c.Devices = []device.Device{
+		&device.Disk{Path: "/", Pool: "default"},
+		&device.Disk{Path: "/dev/termination-log", Source: p},
+	}
+
+	assert.Equal(t, "config is missing\n", terminationMessage(c))
+}
+
+func Test_readHostFileTail(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-termination-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "termination-log")
+	assert.NoError(t, ioutil.WriteFile(p, []byte(strings.Repeat("x", maxTerminationMessage)+"end"), 0644))
+
+	b, err := readHostFileTail(p, maxTerminationMessage)
+	assert.NoError(t, err)
+	assert.Len(t, b, maxTerminationMessage)
+	assert.True(t, strings.HasSuffix(string(b), "xend"))
+
+	link := filepath.Join(dir, "link")
+	assert.NoError(t, os.Symlink(p, link))
+
+	_, err = readHostFileTail(link, maxTerminationMessage)
+	assert.Error(t, err)
+}
+
+func Test_toCriStatusResponse_TerminationMessage(t *testing.T) {
+	t.Parallel()
+
+	c := &lxf.Container{TerminationMessage: "config is missing"}
+	c.StateName = lxf.ContainerStateRunning
+
+	assert.Empty(t, toCriStatusResponse(c).Status.Message, "only reported once exited")
+
+	c.StateName = lxf.ContainerStateExited
+	assert.Equal(t, "config is missing", toCriStatusResponse(c).Status.Message)
+
+	c.EvictionReason = "Evicted"
+	c.EvictionMessage = "node is low on memory"
+	assert.Equal(t, "node is low on memory", toCriStatusResponse(c).Status.Message)
+}
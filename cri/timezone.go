@@ -0,0 +1,139 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/device"
+)
+
+const (
+	cfgEnvTimezone = "environment.TZ"
+	cfgEnvLocale   = "environment.LANG"
+	// localtimeDevice is the disk device mounting the zoneinfo file of the timezone
+	localtimeDevice = "localtime"
+	localtimePath   = "/etc/localtime"
+	// timeNamespaceKernelMajor and timeNamespaceKernelMinor are the first kernel version with time namespaces
+	timeNamespaceKernelMajor = 5
+	timeNamespaceKernelMinor = 6
+)
+
+var (
+	ErrUnknownTimezone          = errors.New("unknown timezone")
+	ErrTimeNamespaceUnsupported = errors.New("time namespaces are not supported")
+	// zoneinfoDir is the zoneinfo database of the host
+	zoneinfoDir = "/usr/share/zoneinfo"
+)
+
+// applyTimeAnnotations sets the timezone, the locale and the clock offsets of the pod annotations in the sandbox
+// profile. The timezone must exist in the zoneinfo database of the host. Environment variables of a container have
+// priority
+func (s RuntimeServer) applyTimeAnnotations(sb *lxf.Sandbox, annotations map[string]string) error {
+	if tz, has := annotation.Timezone.Get(annotations); has {
+		tz, err := annotation.ParseTimezone(tz)
+		if err != nil {
+			return err
+		}
+
+		source := filepath.Join(zoneinfoDir, tz)
+
+		info, err := os.Stat(source)
+		if err != nil || info.IsDir() {
+			return fmt.Errorf("%w: %s isn't in %s", ErrUnknownTimezone, tz, zoneinfoDir)
+		}
+
+		sb.Config[cfgEnvTimezone] = tz
+		sb.Devices.Upsert(&device.Disk{
+			KeyName:  localtimeDevice,
+			Source:   source,
+			Path:     localtimePath,
+			Readonly: true,
+		})
+	}
+
+	if locale, has := annotation.Locale.Get(annotations); has {
+		locale, err := annotation.ParseLocale(locale)
+		if err != nil {
+			return err
+		}
+
+		sb.Config[cfgEnvLocale] = locale
+	}
+
+	return s.applyTimeOffsets(sb, annotations)
+}
+
+// applyTimeOffsets offsets the clocks of the pod's containers in the raw.lxc of the sandbox profile, which gives them
+// their own time namespace. Rejected if the kernel of LXD has no time namespaces
+func (s RuntimeServer) applyTimeOffsets(sb *lxf.Sandbox, annotations map[string]string) error {
+	offsets, err := annotation.ParseTimeOffsets(annotation.TimeOffsets.GetAll(annotations))
+	if err != nil || len(offsets) == 0 {
+		return err
+	}
+
+	server, _, err := s.lxf.GetServer().GetServer()
+	if err != nil {
+		return err
+	}
+
+	if !kernelAtLeast(server.Environment.KernelVersion, timeNamespaceKernelMajor, timeNamespaceKernelMinor) {
+		return fmt.Errorf("%w: kernel %s is older than %d.%d", ErrTimeNamespaceUnsupported, server.Environment.KernelVersion,
+			timeNamespaceKernelMajor, timeNamespaceKernelMinor)
+	}
+
+	clocks := make([]string, 0, len(offsets))
+	for clock := range offsets {
+		clocks = append(clocks, clock)
+	}
+
+	sort.Strings(clocks)
+
+	for _, clock := range clocks {
+		lxf.AppendIfSet(&sb.Config, cfgRawLXC, fmt.Sprintf("lxc.time.offset.%s = %s", clock, lxcDuration(offsets[clock])))
+	}
+
+	return nil
+}
+
+// lxcDuration formats the duration for LXC, in seconds if it has no fraction
+func lxcDuration(d time.Duration) string {
+	if d%time.Second == 0 {
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	}
+
+	return strconv.FormatInt(int64(d), 10) + "ns"
+}
+
+// kernelAtLeast checks if the kernel version like 5.4.0-42-generic is at least major.minor
+func kernelAtLeast(version string, major, minor int) bool {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+
+	maj, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+
+	// the minor version might be followed by a suffix like -rc1
+	minorVersion := parts[1]
+	if i := strings.IndexFunc(minorVersion, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		minorVersion = minorVersion[:i]
+	}
+
+	min, err := strconv.Atoi(minorVersion)
+	if err != nil {
+		return false
+	}
+
+	return maj > major || (maj == major && min >= minor)
+}
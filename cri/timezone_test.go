@@ -0,0 +1,107 @@
+package cri
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/automaticserver/lxe/lxf/device"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// not parallel, it replaces the zoneinfo database
+func TestRuntimeServer_applyTimeAnnotations(t *testing.T) {
+	dir := t.TempDir()
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "Europe"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Europe", "Zurich"), []byte("TZif"), 0644))
+
+	defer func(orig string) { zoneinfoDir = orig }(zoneinfoDir)
+	zoneinfoDir = dir
+
+	s, _, _ := testRuntimeServer()
+
+	sb := &lxf.Sandbox{}
+	sb.Config = map[string]string{}
+
+	assert.NoError(t, s.applyTimeAnnotations(sb, nil))
+	assert.Empty(t, sb.Config)
+	assert.Empty(t, sb.Devices)
+
+	err := s.applyTimeAnnotations(sb, map[string]string{
+		annotation.Timezone.Name: "Europe/Zurich",
+		annotation.Locale.Name:   "de_CH.UTF-8",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{cfgEnvTimezone: "Europe/Zurich", cfgEnvLocale: "de_CH.UTF-8"}, sb.Config)
+	assert.Equal(t, &device.Disk{
+		KeyName:  localtimeDevice,
+		Source:   filepath.Join(dir, "Europe", "Zurich"),
+		Path:     localtimePath,
+		Readonly: true,
+	}, sb.Devices[0])
+
+	for _, tz := range []string{"Europe/Bern", "Europe"} {
+		err = s.applyTimeAnnotations(sb, map[string]string{annotation.Timezone.Name: tz})
+		assert.True(t, errors.Is(err, ErrUnknownTimezone), tz)
+	}
+
+	err = s.applyTimeAnnotations(sb, map[string]string{annotation.Timezone.Name: "../../etc/passwd"})
+	assert.True(t, errors.Is(err, annotation.ErrInvalidTimezone))
+}
+
+func TestRuntimeServer_applyTimeOffsets(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+
+	fakeServer.GetServerReturns(&api.Server{Environment: api.ServerEnvironment{KernelVersion: "5.4.0-42-generic"}}, "", nil)
+
+	sb := &lxf.Sandbox{}
+	sb.Config = map[string]string{cfgRawLXC: "lxc.proc.oom_score_adj = 1000"}
+
+	annotations := map[string]string{
+		annotation.TimeOffsets.Name + "monotonic": "-1h",
+		annotation.TimeOffsets.Name + "boot":      "720h",
+	}
+
+	err := s.applyTimeOffsets(sb, annotations)
+	assert.True(t, errors.Is(err, ErrTimeNamespaceUnsupported))
+
+	fakeServer.GetServerReturns(&api.Server{Environment: api.ServerEnvironment{KernelVersion: "5.8.0-rc1"}}, "", nil)
+
+	err = s.applyTimeOffsets(sb, annotations)
+	assert.NoError(t, err)
+	assert.Equal(t, "lxc.proc.oom_score_adj = 1000\nlxc.time.offset.boot = 2592000s\nlxc.time.offset.monotonic = -3600s",
+		sb.Config[cfgRawLXC])
+}
+
+func Test_lxcDuration(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "86400s", lxcDuration(24*time.Hour))
+	assert.Equal(t, "-1500000000ns", lxcDuration(-1500*time.Millisecond))
+}
+
+func Test_kernelAtLeast(t *testing.T) {
+	t.Parallel()
+
+	for version, expected := range map[string]bool{
+		"5.6.0":            true,
+		"5.10.0-8-amd64":   true,
+		"6.1":              true,
+		"5.7-rc1":          true,
+		"5.4.0-42-generic": false,
+		"4.19.0":           false,
+		"":                 false,
+		"five":             false,
+	} {
+		assert.Equal(t, expected, kernelAtLeast(version, 5, 6), version)
+	}
+}
@@ -0,0 +1,70 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/automaticserver/lxe/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// tracingStopTimeout is how long the remaining spans may take to be flushed when LXE stops
+const tracingStopTimeout = 5 * time.Second
+
+// metadataCarrier reads and writes the trace context in the grpc metadata of a call
+type metadataCarrier metadata.MD
+
+// Get returns the first value of the key
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+// Set replaces the values of the key
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys returns the keys of the metadata
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// callSpan traces every CRI call in a span, as child of the span of the caller if it propagates its trace context. The
+// spans of the LXD operations and CNI invocations of the call are its children
+func callSpan(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = tracing.Extract(ctx, metadataCarrier(md))
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.method", info.FullMethod),
+	}
+
+	for k, v := range callFields("", req) {
+		if s, ok := v.(string); ok && s != "" {
+			attrs = append(attrs, attribute.String("lxe."+k, s))
+		}
+	}
+
+	ctx, span := tracing.Start(ctx, strings.TrimPrefix(info.FullMethod, "/"), attrs...)
+
+	resp, err := handler(ctx, req)
+
+	tracing.End(span, err)
+
+	return resp, err
+}
@@ -0,0 +1,41 @@
+package cri
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func Test_callSpan(t *testing.T) {
+	t.Parallel()
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	))
+	info := &grpc.UnaryServerInfo{FullMethod: "/runtime.v1alpha2.RuntimeService/StartContainer"}
+
+	var span trace.SpanContext
+
+	_, err := callSpan(ctx, &rtApi.StartContainerRequest{ContainerId: "c1"}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		span = trace.SpanContextFromContext(ctx)
+		return nil, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID().String(), "call continues the trace of the caller")
+}
+
+func Test_metadataCarrier(t *testing.T) {
+	t.Parallel()
+
+	c := metadataCarrier(metadata.MD{})
+	assert.Empty(t, c.Get("traceparent"))
+
+	c.Set("Traceparent", "a")
+	assert.Equal(t, "a", c.Get("traceparent"))
+	assert.Equal(t, []string{"traceparent"}, c.Keys())
+}
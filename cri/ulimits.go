@@ -0,0 +1,51 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+)
+
+const (
+	// cfgLimitsKernel starts the LXD config keys of the kernel resource limits, followed by the resource
+	cfgLimitsKernel = "limits.kernel."
+)
+
+// setUlimit parses the runtime handler option ulimit-<resource>
+func (h *runtimeHandler) setUlimit(resource, value string) error {
+	limit, err := annotation.ParseUlimit(value)
+	if err != nil {
+		return err
+	}
+
+	if h.Ulimits == nil {
+		h.Ulimits = map[string]string{}
+	}
+
+	h.Ulimits[resource] = limit
+
+	return nil
+}
+
+// applyUlimits sets the kernel resource limits of the container. The limits of the pod annotations for this container
+// have priority over the ones for all containers of the pod, which have priority over the defaults of the runtime
+// handler
+func (s RuntimeServer) applyUlimits(c *lxf.Container, sb *lxf.Sandbox, annotations map[string]string) error {
+	ulimits, err := annotation.ParseUlimits(annotation.Ulimits.GetAll(annotations))
+	if err != nil {
+		return err
+	}
+
+	layers := []map[string]string{ulimits[""], ulimits[c.Metadata.Name]}
+
+	if h, has := s.runtimeHandlers[sb.RuntimeHandler]; has {
+		layers = append([]map[string]string{h.Ulimits}, layers...)
+	}
+
+	for _, limits := range layers {
+		for resource, limit := range limits {
+			c.Config[cfgLimitsKernel+resource] = limit
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package cri
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeServer_applyUlimits(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+
+	var err error
+
+	s.runtimeHandlers, err = parseRuntimeHandlers([]string{"dpdk.ulimit-memlock=unlimited", "dpdk.ulimit-nofile=4096"})
+	assert.NoError(t, err)
+
+	newContainer := func(name string) *lxf.Container {
+		c := &lxf.Container{}
+		c.Config = map[string]string{}
+		c.Metadata.Name = name
+
+		return c
+	}
+
+	sb := &lxf.Sandbox{}
+
+	c := newContainer("app")
+	assert.NoError(t, s.applyUlimits(c, sb, nil))
+	assert.Empty(t, c.Config, "no limits by default")
+
+	sb.RuntimeHandler = "dpdk"
+	annotations := map[string]string{
+		annotation.Ulimits.Name + "nofile":    "65536",
+		annotation.Ulimits.Name + "db.nofile": "1048576",
+	}
+
+	c = newContainer("app")
+	assert.NoError(t, s.applyUlimits(c, sb, annotations))
+	assert.Equal(t, map[string]string{
+		"limits.kernel.memlock": "unlimited:unlimited",
+		"limits.kernel.nofile":  "65536:65536",
+	}, c.Config)
+
+	c = newContainer("db")
+	assert.NoError(t, s.applyUlimits(c, sb, annotations))
+	assert.Equal(t, "1048576:1048576", c.Config["limits.kernel.nofile"])
+
+	err = s.applyUlimits(newContainer("db"), sb, map[string]string{annotation.Ulimits.Name + "stack": "1024"})
+	assert.True(t, errors.Is(err, annotation.ErrInvalidUlimit))
+}
@@ -0,0 +1,204 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+// The validation policies define what happens to pods and containers requesting something LXE doesn't support
+const (
+	// ValidationStrict rejects them
+	ValidationStrict = "strict"
+	// ValidationWarn logs a warning and creates them without the unsupported settings
+	ValidationWarn = "warn"
+)
+
+var (
+	ErrUnknownValidation = errors.New("unknown validation policy")
+	ErrInvalidRequest    = errors.New("invalid request")
+	ErrUnsupported       = errors.New("unsupported by LXE")
+)
+
+// The profiles of AppArmor and seccomp which need no custom profile
+const (
+	profileRuntimeDefault = "runtime/default"
+	profileDockerDefault  = "docker/default"
+	profileUnconfined     = "unconfined"
+)
+
+// oomScoreAdjMin and oomScoreAdjMax are the range the kernel accepts
+const (
+	oomScoreAdjMin = -1000
+	oomScoreAdjMax = 1000
+)
+
+// validationError is returned for a rejected pod or container, with all problems found. Kubelet shows the message in
+// the events of the pod
+type validationError struct {
+	err      error
+	problems []string
+}
+
+func (e *validationError) Error() string {
+	return fmt.Sprintf("%v: %s", e.err, strings.Join(e.problems, "; "))
+}
+
+func (e *validationError) Unwrap() error {
+	return e.err
+}
+
+// GRPCStatus reports the rejection as invalid argument instead of an unknown error
+func (e *validationError) GRPCStatus() *status.Status {
+	return status.New(codes.InvalidArgument, e.Error())
+}
+
+// configCheck collects the problems of a pod or container config. Invalid settings are always rejected, unsupported
+// ones depending on the validation policy
+type configCheck struct {
+	invalid     []string
+	unsupported []string
+}
+
+func (v *configCheck) invalidf(format string, args ...interface{}) {
+	v.invalid = append(v.invalid, fmt.Sprintf(format, args...))
+}
+
+func (v *configCheck) unsupportedf(format string, args ...interface{}) {
+	v.unsupported = append(v.unsupported, fmt.Sprintf(format, args...))
+}
+
+// result returns the error rejecting the request, or logs the unsupported settings which are ignored
+func (v *configCheck) result(log *logrus.Entry, policy string) error {
+	if len(v.invalid) > 0 {
+		return &validationError{err: ErrInvalidRequest, problems: v.invalid}
+	}
+
+	if len(v.unsupported) == 0 {
+		return nil
+	}
+
+	if policy == ValidationStrict {
+		return &validationError{err: ErrUnsupported, problems: v.unsupported}
+	}
+
+	for _, p := range v.unsupported {
+		log.WithField("validation", policy).Warn(fmt.Sprintf("%v, ignoring: %s", ErrUnsupported, p))
+	}
+
+	return nil
+}
+
+// validatePodConfig checks the pod config for settings LXE can't apply
+func validatePodConfig(config *rtApi.PodSandboxConfig) *configCheck {
+	v := &configCheck{}
+	sc := config.GetLinux().GetSecurityContext()
+
+	if hasSELinuxOptions(sc.GetSelinuxOptions()) {
+		v.unsupportedf("securityContext.seLinuxOptions, LXD has no SELinux support")
+	}
+
+	if !isDefaultProfile(sc.GetSeccompProfilePath()) {
+		v.unsupportedf("seccomp profile %s, LXD applies its default seccomp policy", sc.GetSeccompProfilePath())
+	}
+
+	for _, pm := range config.GetPortMappings() {
+		if pm.GetProtocol() == rtApi.Protocol_SCTP && pm.GetHostPort() != 0 {
+			v.unsupportedf("hostPort %d with protocol SCTP, it's forwarded as TCP", pm.GetHostPort())
+		}
+	}
+
+	return v
+}
+
+// validateContainerConfig checks the container config for settings LXE can't apply or LXD would reject
+func validateContainerConfig(config *rtApi.ContainerConfig) *configCheck { // nolint: gocognit, cyclop
+	v := &configCheck{}
+	sc := config.GetLinux().GetSecurityContext()
+	hasCommand := len(config.GetCommand()) > 0 || len(config.GetArgs()) > 0
+
+	for _, mnt := range config.GetMounts() {
+		if !path.IsAbs(mnt.GetContainerPath()) {
+			v.invalidf("mount %s must have an absolute container path", mnt.GetContainerPath())
+		}
+
+		if mnt.GetHostPath() == "" {
+			v.invalidf("mount %s has no host path", mnt.GetContainerPath())
+		}
+
+		if mnt.GetSelinuxRelabel() {
+			v.unsupportedf("SELinux relabeling of mount %s", mnt.GetContainerPath())
+		}
+	}
+
+	for _, dev := range config.GetDevices() {
+		if !path.IsAbs(dev.GetContainerPath()) || !path.IsAbs(dev.GetHostPath()) {
+			v.invalidf("device %s must have absolute host and container paths", dev.GetContainerPath())
+		}
+	}
+
+	if adj := config.GetLinux().GetResources().GetOomScoreAdj(); adj < oomScoreAdjMin || adj > oomScoreAdjMax {
+		v.invalidf("oomScoreAdj %d must be between %d and %d", adj, oomScoreAdjMin, oomScoreAdjMax)
+	}
+
+	if _, err := toContainerCapabilities(sc.GetCapabilities()); err != nil {
+		v.invalidf("securityContext.capabilities: %v", err)
+	}
+
+	if hasSELinuxOptions(sc.GetSelinuxOptions()) {
+		v.unsupportedf("securityContext.seLinuxOptions, LXD has no SELinux support")
+	}
+
+	if sc.GetNoNewPrivs() {
+		v.unsupportedf("securityContext.allowPrivilegeEscalation false")
+	}
+
+	if len(sc.GetSupplementalGroups()) > 0 {
+		v.unsupportedf("supplemental groups")
+	}
+
+	if !isDefaultProfile(sc.GetApparmorProfile()) {
+		v.unsupportedf("AppArmor profile %s, LXD generates the profile of the container", sc.GetApparmorProfile())
+	}
+
+	if !isDefaultProfile(sc.GetSeccompProfilePath()) {
+		v.unsupportedf("seccomp profile %s, LXD applies its default seccomp policy", sc.GetSeccompProfilePath())
+	}
+
+	if sc.GetRunAsUsername() != "" {
+		v.unsupportedf("securityContext.runAsUser by name %s", sc.GetRunAsUsername())
+	}
+
+	// the image's init always runs as root in /
+	if !hasCommand {
+		if sc.GetRunAsUser() != nil || sc.GetRunAsGroup() != nil {
+			v.unsupportedf("securityContext.runAsUser and runAsGroup without command")
+		}
+
+		if config.GetWorkingDir() != "" {
+			v.unsupportedf("workingDir %s without command", config.GetWorkingDir())
+		}
+	}
+
+	return v
+}
+
+func hasSELinuxOptions(o *rtApi.SELinuxOption) bool {
+	return o.GetUser() != "" || o.GetRole() != "" || o.GetType() != "" || o.GetLevel() != ""
+}
+
+// isDefaultProfile returns true if the AppArmor or seccomp profile is the default of the runtime or none
+func isDefaultProfile(p string) bool {
+	switch p {
+	case "", profileRuntimeDefault, profileDockerDefault, profileUnconfined:
+		return true
+	}
+
+	return false
+}
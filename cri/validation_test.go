@@ -0,0 +1,121 @@
+package cri
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func Test_validateContainerConfig(t *testing.T) {
+	t.Parallel()
+
+	config := &rtApi.ContainerConfig{
+		Mounts: []*rtApi.Mount{
+			{ContainerPath: "/data", HostPath: "/var/lib/kubelet/pods/abc/volumes/data"},
+			{ContainerPath: "/relabeled", HostPath: "/srv", SelinuxRelabel: true},
+		},
+		Linux: &rtApi.LinuxContainerConfig{
+			SecurityContext: &rtApi.LinuxContainerSecurityContext{
+				Capabilities:       &rtApi.Capability{AddCapabilities: []string{"NET_ADMIN"}},
+				ReadonlyRootfs:     true,
+				ApparmorProfile:    "localhost/custom",
+				SeccompProfilePath: profileRuntimeDefault,
+				RunAsUser:          &rtApi.Int64Value{Value: 1000},
+			},
+		},
+	}
+
+	// a readonly rootfs and capabilities are supported
+	v := validateContainerConfig(config)
+	assert.Empty(t, v.invalid)
+	assert.Len(t, v.unsupported, 3)
+
+	// a command runs as the user
+	config.Command = []string{"/app"}
+	assert.Len(t, validateContainerConfig(config).unsupported, 2)
+
+	config.Linux.SecurityContext.Capabilities.DropCapabilities = []string{"NET_TEA"}
+	assert.Len(t, validateContainerConfig(config).invalid, 1)
+}
+
+func Test_validateContainerConfig_Invalid(t *testing.T) {
+	t.Parallel()
+
+	config := &rtApi.ContainerConfig{
+		Mounts:  []*rtApi.Mount{{ContainerPath: "data", HostPath: "/srv"}},
+		Devices: []*rtApi.Device{{ContainerPath: "/dev/sdb", HostPath: "sdb"}},
+		Linux: &rtApi.LinuxContainerConfig{
+			Resources: &rtApi.LinuxContainerResources{OomScoreAdj: 1001},
+		},
+	}
+
+	assert.Len(t, validateContainerConfig(config).invalid, 3)
+	assert.Empty(t, validateContainerConfig(&rtApi.ContainerConfig{}).invalid)
+}
+
+func Test_validatePodConfig(t *testing.T) {
+	t.Parallel()
+
+	config := &rtApi.PodSandboxConfig{
+		PortMappings: []*rtApi.PortMapping{
+			{Protocol: rtApi.Protocol_TCP, HostPort: 80, ContainerPort: 80},
+			{Protocol: rtApi.Protocol_SCTP, HostPort: 9899, ContainerPort: 9899},
+		},
+		Linux: &rtApi.LinuxPodSandboxConfig{
+			SecurityContext: &rtApi.LinuxSandboxSecurityContext{
+				SelinuxOptions: &rtApi.SELinuxOption{Level: "s0:c123,c456"},
+			},
+		},
+	}
+
+	v := validatePodConfig(config)
+	assert.Empty(t, v.invalid)
+	assert.Len(t, v.unsupported, 2)
+}
+
+func Test_configCheck_result(t *testing.T) {
+	t.Parallel()
+
+	log := logrus.NewEntry(logrus.New())
+
+	v := &configCheck{}
+	assert.NoError(t, v.result(log, ValidationStrict))
+
+	v.unsupportedf("securityContext.allowPrivilegeEscalation false")
+	assert.NoError(t, v.result(log, ValidationWarn))
+
+	err := v.result(log, ValidationStrict)
+	assert.True(t, errors.Is(err, ErrUnsupported))
+	assert.True(t, strings.Contains(err.Error(), "allowPrivilegeEscalation"))
+
+	v.invalidf("mount data must have an absolute container path")
+	assert.True(t, errors.Is(v.result(log, ValidationWarn), ErrInvalidRequest))
+
+	// the grpc status passes the annotation
+	assert.Equal(t, codes.InvalidArgument, status.Code(AnnErr(log, v.result(log, ValidationWarn), "invalid container config")))
+}
+
+func TestRuntimeServer_CreateContainer_Strict(t *testing.T) {
+	t.Parallel()
+
+	s, fake, _ := testRuntimeServer()
+	s.criConfig.LXEValidation = ValidationStrict
+
+	_, err := s.CreateContainer(context.Background(), &rtApi.CreateContainerRequest{
+		PodSandboxId: "sb",
+		Config: &rtApi.ContainerConfig{
+			Linux: &rtApi.LinuxContainerConfig{
+				SecurityContext: &rtApi.LinuxContainerSecurityContext{NoNewPrivs: true},
+			},
+		},
+	})
+	assert.True(t, errors.Is(err, ErrUnsupported))
+	assert.Equal(t, 0, fake.NewContainerCallCount())
+}
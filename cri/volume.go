@@ -0,0 +1,96 @@
+package cri // import "github.com/automaticserver/lxe/cri"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf/device"
+	"github.com/automaticserver/lxe/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+const (
+	volumeTypeCustom = "custom"
+	// cfgVolumeNamespace is the volume config key of the namespace owning the custom volume
+	cfgVolumeNamespace = "user.namespace"
+)
+
+var (
+	ErrInvalidVolume        = annotation.ErrInvalidVolume
+	ErrVolumePoolNotAllowed = errors.New("volume storage pool not allowed")
+	ErrVolumeNamespace      = errors.New("volume belongs to another namespace")
+)
+
+// ensureVolume creates the custom storage volume owned by the namespace if it doesn't exist yet. Existing volumes are
+// kept as they are, so the data follows the pod across recreation, but only if they're owned by the same namespace.
+// Volumes created by hand have no owner, so they must be handed over by setting user.namespace on them
+func (s RuntimeServer) ensureVolume(vol annotation.Volume, namespace string) error {
+	server := s.lxf.GetServer()
+
+	existing, _, err := server.GetStoragePoolVolume(vol.Pool, volumeTypeCustom, vol.Name)
+	if err == nil {
+		if existing.Config[cfgVolumeNamespace] != namespace {
+			return fmt.Errorf("%w: %s/%s", ErrVolumeNamespace, vol.Pool, vol.Name)
+		}
+
+		return nil
+	} else if !shared.IsErrNotFound(err) {
+		return err
+	}
+
+	return server.CreateStoragePoolVolume(vol.Pool, api.StorageVolumesPost{
+		Name: vol.Name,
+		Type: volumeTypeCustom,
+		StorageVolumePut: api.StorageVolumePut{
+			Description: "managed by LXE",
+			Config: map[string]string{
+				cfgVolumeNamespace: namespace,
+			},
+		},
+	})
+}
+
+// attachVolumes provisions the custom storage volumes requested by the pod annotation and returns their disk devices.
+// The volumes are owned by the namespace of the pod and must be on a storage pool of the allowlist
+func (s RuntimeServer) attachVolumes(namespace string, annotations map[string]string) ([]*device.Disk, error) {
+	raw, _ := annotation.Volumes.Get(annotations)
+
+	vols, err := annotation.ParseVolumes(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	disks := make([]*device.Disk, 0, len(vols))
+
+	for _, vol := range vols {
+		if !matchesAny(s.config().LXDVolumePools, vol.Pool) {
+			return nil, fmt.Errorf("%w: %s", ErrVolumePoolNotAllowed, vol.Pool)
+		}
+
+		// the volumes backing emptyDirs belong to their pod only
+		if strings.HasPrefix(vol.Name, scratchVolumePrefix) {
+			return nil, fmt.Errorf("%w: %s is reserved for emptyDirs", ErrInvalidVolume, vol.Name)
+		}
+
+		err = s.checkStoragePool(vol.Pool)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.ensureVolume(vol, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("unable to provision volume %s/%s: %w", vol.Pool, vol.Name, err)
+		}
+
+		disks = append(disks, &device.Disk{
+			Path:     vol.Path,
+			Source:   vol.Name,
+			Pool:     vol.Pool,
+			Readonly: vol.Readonly,
+		})
+	}
+
+	return disks, nil
+}
@@ -0,0 +1,102 @@
+package cri
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/lxf/device"
+	"github.com/automaticserver/lxe/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeServer_attachVolumes_Invalid(t *testing.T) {
+	t.Parallel()
+
+	s, _, _ := testRuntimeServer()
+
+	_, err := s.attachVolumes("ns", map[string]string{annotation.Volumes.Name: "data:relative"})
+	assert.True(t, errors.Is(err, ErrInvalidVolume))
+}
+
+func TestRuntimeServer_attachVolumes_Create(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	s.criConfig.LXDVolumePools = []string{"fast"}
+	fakeServer.GetStoragePoolVolumeReturns(nil, "", shared.NewErrNotFound())
+
+	disks, err := s.attachVolumes("ns", map[string]string{annotation.Volumes.Name: "fast/data:/data"})
+	assert.NoError(t, err)
+	assert.Equal(t, []*device.Disk{{Path: "/data", Source: "data", Pool: "fast"}}, disks)
+
+	assert.Equal(t, 1, fakeServer.CreateStoragePoolVolumeCallCount())
+	pool, post := fakeServer.CreateStoragePoolVolumeArgsForCall(0)
+	assert.Equal(t, "fast", pool)
+	assert.Equal(t, "data", post.Name)
+	assert.Equal(t, volumeTypeCustom, post.Type)
+	assert.Equal(t, "ns", post.Config[cfgVolumeNamespace])
+}
+
+func TestRuntimeServer_attachVolumes_Existing(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	s.criConfig.LXDVolumePools = []string{annotation.DefaultVolumePool}
+	fakeServer.GetStoragePoolVolumeReturns(&api.StorageVolume{StorageVolumePut: api.StorageVolumePut{
+		Config: map[string]string{cfgVolumeNamespace: "ns"},
+	}}, "", nil)
+
+	disks, err := s.attachVolumes("ns", map[string]string{annotation.Volumes.Name: "data:/data:ro"})
+	assert.NoError(t, err)
+	assert.Len(t, disks, 1)
+	assert.True(t, disks[0].Readonly)
+	assert.Equal(t, 0, fakeServer.CreateStoragePoolVolumeCallCount())
+}
+
+func TestRuntimeServer_attachVolumes_OtherNamespace(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	s.criConfig.LXDVolumePools = []string{annotation.DefaultVolumePool}
+
+	fakeServer.GetStoragePoolVolumeReturns(&api.StorageVolume{StorageVolumePut: api.StorageVolumePut{
+		Config: map[string]string{cfgVolumeNamespace: "other"},
+	}}, "", nil)
+
+	_, err := s.attachVolumes("ns", map[string]string{annotation.Volumes.Name: "data:/data"})
+	assert.True(t, errors.Is(err, ErrVolumeNamespace))
+
+	// volumes created by hand have no owner
+	fakeServer.GetStoragePoolVolumeReturns(&api.StorageVolume{}, "", nil)
+
+	_, err = s.attachVolumes("ns", map[string]string{annotation.Volumes.Name: "data:/data"})
+	assert.True(t, errors.Is(err, ErrVolumeNamespace))
+}
+
+func TestRuntimeServer_attachVolumes_PoolNotAllowed(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+
+	_, err := s.attachVolumes("ns", map[string]string{annotation.Volumes.Name: "data:/data"})
+	assert.True(t, errors.Is(err, ErrVolumePoolNotAllowed), "no pool is allowed by default")
+
+	s.criConfig.LXDVolumePools = []string{"fast*"}
+
+	_, err = s.attachVolumes("ns", map[string]string{annotation.Volumes.Name: "slow/data:/data"})
+	assert.True(t, errors.Is(err, ErrVolumePoolNotAllowed))
+	assert.Equal(t, 0, fakeServer.GetStoragePoolVolumeCallCount())
+}
+
+func TestRuntimeServer_attachVolumes_Scratch(t *testing.T) {
+	t.Parallel()
+
+	s, _, fakeServer := testRuntimeServer()
+	s.criConfig.LXDVolumePools = []string{"*"}
+
+	_, err := s.attachVolumes("ns", map[string]string{annotation.Volumes.Name: "nvme/scratch-sbid-cache:/cache"})
+	assert.True(t, errors.Is(err, ErrInvalidVolume))
+	assert.Equal(t, 0, fakeServer.GetStoragePoolVolumeCallCount())
+}
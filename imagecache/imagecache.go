@@ -0,0 +1,364 @@
+// Package imagecache keeps images in a directory and serves them as simplestreams remote, so the LXD servers of other
+// nodes download them from there instead of downloading them again from their origin
+package imagecache // import "github.com/automaticserver/lxe/imagecache"
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/simplestreams"
+)
+
+const (
+	// imagesDir is the directory of the images in the cache directory and the path they're served at
+	imagesDir = "images"
+	// productFile holds the simplestreams product of an image in its directory
+	productFile = "product.json"
+	// indexPath and productsPath are the paths simplestreams clients request the index and the products at
+	indexPath    = "/streams/v1/index.json"
+	productsPath = "/streams/v1/images.json"
+	// versionLayout is the name of the single version of a product, simplestreams clients parse it as creation date
+	versionLayout = "20060102_1504"
+	// tmpPrefix starts the directories images are exported to before they're complete, they aren't served
+	tmpPrefix = "."
+)
+
+var ErrUnsupportedImage = errors.New("image type can't be served with simplestreams")
+
+// rootfsTypes maps the file name suffixes of exported rootfs files to their simplestreams file types
+var rootfsTypes = map[string]string{
+	".squashfs": "squashfs",
+	".tar.xz":   "root.tar.xz",
+}
+
+// Store is a directory of images, each in its own directory named by its fingerprint with the exported files and the
+// simplestreams product describing them
+type Store struct {
+	dir string
+	// mu serializes adding images, so concurrent pulls of the same image export it once
+	mu sync.Mutex
+}
+
+// New returns the store in dir, which is created if it doesn't exist
+func New(dir string) (*Store, error) {
+	err := os.MkdirAll(filepath.Join(dir, imagesDir), 0755) // nolint: gomnd
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{dir: dir}, nil
+}
+
+// Has checks if the image with the fingerprint is in the store
+func (s *Store) Has(fingerprint string) bool {
+	_, err := os.Stat(filepath.Join(s.imageDir(fingerprint), productFile))
+	return err == nil
+}
+
+// Add exports the image with the fingerprint from server into the store, if it isn't in there yet. The files are
+// written to a temporary directory first, so an interrupted export is never served
+func (s *Store) Add(server lxd.ImageServer, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Has(fingerprint) {
+		return nil
+	}
+
+	image, _, err := server.GetImage(fingerprint)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempDir(filepath.Join(s.dir, imagesDir), tmpPrefix+fingerprint)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	meta, err := os.Create(filepath.Join(tmp, "meta"))
+	if err != nil {
+		return err
+	}
+	defer meta.Close()
+
+	rootfs, err := os.Create(filepath.Join(tmp, "rootfs"))
+	if err != nil {
+		return err
+	}
+	defer rootfs.Close()
+
+	resp, err := server.GetImageFile(fingerprint, lxd.ImageFileRequest{MetaFile: meta, RootfsFile: rootfs})
+	if err != nil {
+		return fmt.Errorf("unable to export image %s: %w", fingerprint, err)
+	}
+
+	items := map[string]simplestreams.ProductVersionItem{}
+
+	metaItem, err := newItem(meta, fingerprint, "meta"+fileSuffix(resp.MetaName))
+	if err != nil {
+		return err
+	}
+
+	if resp.RootfsName == "" {
+		// a unified tarball is the image itself, its hash is the fingerprint
+		metaItem.FileType = "lxd_combined.tar.gz"
+		items["lxd_combined.tar.gz"] = metaItem
+	} else {
+		suffix := fileSuffix(resp.RootfsName)
+
+		ftype, has := rootfsTypes[suffix]
+		if !has {
+			return fmt.Errorf("%w: %s, rootfs %s", ErrUnsupportedImage, fingerprint, resp.RootfsName)
+		}
+
+		rootfsItem, err := newItem(rootfs, fingerprint, "rootfs"+suffix)
+		if err != nil {
+			return err
+		}
+
+		rootfsItem.FileType = ftype
+
+		// the fingerprint of a split image is the hash of both files
+		metaItem.FileType = "lxd.tar.xz"
+		metaItem.LXDHashSha256 = fingerprint
+
+		if ftype == "squashfs" {
+			metaItem.LXDHashSha256SquashFs = fingerprint
+		} else {
+			metaItem.LXDHashSha256RootXz = fingerprint
+		}
+
+		items["lxd.tar.xz"] = metaItem
+		items[ftype] = rootfsItem
+	}
+
+	for _, f := range []*os.File{meta, rootfs} {
+		err = f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	// the files get the names they're served with, a unified image has no rootfs
+	rootfsName := ""
+	if item, has := items[rootfsType(items)]; has {
+		rootfsName = filepath.Base(item.Path)
+	}
+
+	err = renameOrRemove(filepath.Join(tmp, "rootfs"), rootfsName)
+	if err != nil {
+		return err
+	}
+
+	err = renameOrRemove(filepath.Join(tmp, "meta"), filepath.Base(metaItem.Path))
+	if err != nil {
+		return err
+	}
+
+	created := image.CreatedAt
+	if created.IsZero() {
+		created = time.Now()
+	}
+
+	product := simplestreams.Product{
+		Architecture:    image.Architecture,
+		OperatingSystem: image.Properties["os"],
+		Release:         image.Properties["release"],
+		ReleaseTitle:    image.Properties["release"],
+		Versions: map[string]simplestreams.ProductVersion{
+			created.UTC().Format(versionLayout): {Items: items},
+		},
+	}
+
+	b, err := json.Marshal(product)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(tmp, productFile), b, 0644) // nolint: gomnd
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmp, 0755) // nolint: gomnd
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.imageDir(fingerprint))
+}
+
+// Remove removes the image with the fingerprint from the store
+func (s *Store) Remove(fingerprint string) error {
+	return os.RemoveAll(s.imageDir(fingerprint))
+}
+
+// rootfsType returns the file type of the rootfs item, empty if there's none
+func rootfsType(items map[string]simplestreams.ProductVersionItem) string {
+	for _, ftype := range rootfsTypes {
+		if _, has := items[ftype]; has {
+			return ftype
+		}
+	}
+
+	return ""
+}
+
+// renameOrRemove renames the file to name in the same directory, it's removed if name is empty
+func renameOrRemove(path, name string) error {
+	if name == "" {
+		return os.Remove(path)
+	}
+
+	return os.Rename(path, filepath.Join(filepath.Dir(path), name))
+}
+
+func (s *Store) imageDir(fingerprint string) string {
+	return filepath.Join(s.dir, imagesDir, fingerprint)
+}
+
+// newItem hashes the exported file and returns its item, served at images/<fingerprint>/<name>
+func newItem(f *os.File, fingerprint, name string) (simplestreams.ProductVersionItem, error) {
+	_, err := f.Seek(0, io.SeekStart)
+	if err != nil {
+		return simplestreams.ProductVersionItem{}, err
+	}
+
+	h := sha256.New()
+
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return simplestreams.ProductVersionItem{}, err
+	}
+
+	return simplestreams.ProductVersionItem{
+		Path:       imagesDir + "/" + fingerprint + "/" + name,
+		HashSha256: hex.EncodeToString(h.Sum(nil)),
+		Size:       size,
+	}, nil
+}
+
+// fileSuffix returns the suffix of the exported file name, including compound ones like .tar.xz
+func fileSuffix(name string) string {
+	ext := filepath.Ext(name)
+	if strings.HasSuffix(strings.TrimSuffix(name, ext), ".tar") {
+		return ".tar" + ext
+	}
+
+	return ext
+}
+
+// products reads the products of all images in the store, keyed by their fingerprint
+func (s *Store) products() (map[string]simplestreams.Product, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(s.dir, imagesDir))
+	if err != nil {
+		return nil, err
+	}
+
+	products := map[string]simplestreams.Product{}
+
+	for _, info := range infos {
+		if !info.IsDir() || strings.HasPrefix(info.Name(), tmpPrefix) {
+			continue
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(s.dir, imagesDir, info.Name(), productFile))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		product := simplestreams.Product{}
+
+		err = json.Unmarshal(b, &product)
+		if err != nil {
+			return nil, fmt.Errorf("invalid product of image %s: %w", info.Name(), err)
+		}
+
+		products[info.Name()] = product
+	}
+
+	return products, nil
+}
+
+// ServeHTTP serves the store as simplestreams remote. The images have no aliases, they're found by their fingerprint
+func (s *Store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	// LXD requests the index with a leading double slash
+	p := path.Clean("/" + r.URL.Path)
+
+	switch {
+	case p == indexPath || p == productsPath:
+		products, err := s.products()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var v interface{} = simplestreams.Products{
+			ContentID: imagesDir,
+			DataType:  "image-downloads",
+			Format:    "products:1.0",
+			Products:  products,
+		}
+
+		if p == indexPath {
+			names := make([]string, 0, len(products))
+			for name := range products {
+				names = append(names, name)
+			}
+
+			v = simplestreams.Stream{
+				Format: "index:1.0",
+				Index: map[string]simplestreams.StreamIndex{
+					imagesDir: {
+						DataType: "image-downloads",
+						Path:     strings.TrimPrefix(productsPath, "/"),
+						Format:   "products:1.0",
+						Products: names,
+					},
+				},
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(w).Encode(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	case servable(p):
+		http.ServeFile(w, r, filepath.Join(s.dir, filepath.FromSlash(p)))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// servable checks if the path is one of the image files, images/<fingerprint>/<file>
+func servable(p string) bool {
+	parts := strings.Split(strings.TrimPrefix(p, "/"), "/")
+
+	return len(parts) == 3 && parts[0] == imagesDir && parts[1] != "" && parts[2] != "" &&
+		!strings.HasPrefix(parts[1], tmpPrefix) && !strings.HasPrefix(parts[2], ".") && parts[2] != productFile
+}
@@ -0,0 +1,192 @@
+package imagecache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStore(t *testing.T) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "lxe-imagecache")
+	assert.NoError(t, err)
+
+	s, err := New(dir)
+	assert.NoError(t, err)
+
+	return s, func() { os.RemoveAll(dir) }
+}
+
+// fakeImage returns a server exporting an image with the files, the rootfs is omitted if it's nil
+func fakeImage(meta, rootfs []byte) (*lxdfakes.FakeContainerServer, string) {
+	h := sha256.New()
+	h.Write(meta)
+	h.Write(rootfs)
+	fingerprint := hex.EncodeToString(h.Sum(nil))
+
+	fake := &lxdfakes.FakeContainerServer{}
+	fake.GetImageReturns(&api.Image{
+		Fingerprint:  fingerprint,
+		ImagePut:     api.ImagePut{Properties: map[string]string{"os": "alpine", "release": "3.12"}},
+		Architecture: "x86_64",
+		CreatedAt:    time.Date(2020, 8, 25, 0, 0, 0, 0, time.UTC),
+	}, "", nil)
+	fake.GetImageFileStub = func(fp string, req lxd.ImageFileRequest) (*lxd.ImageFileResponse, error) {
+		resp := &lxd.ImageFileResponse{MetaName: "meta-" + fp + ".tar.xz", MetaSize: int64(len(meta))}
+
+		_, err := req.MetaFile.Write(meta)
+		if err != nil {
+			return nil, err
+		}
+
+		if rootfs != nil {
+			resp.RootfsName = fp + ".squashfs"
+			resp.RootfsSize = int64(len(rootfs))
+
+			_, err = req.RootfsFile.Write(rootfs)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return resp, nil
+	}
+
+	return fake, fingerprint
+}
+
+func TestStore_Add_Split(t *testing.T) {
+	t.Parallel()
+
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	fake, fingerprint := fakeImage([]byte("meta"), []byte("rootfs"))
+
+	assert.False(t, s.Has(fingerprint))
+	assert.NoError(t, s.Add(fake, fingerprint))
+	assert.True(t, s.Has(fingerprint))
+
+	// it's exported once
+	assert.NoError(t, s.Add(fake, fingerprint))
+	assert.Equal(t, 1, fake.GetImageFileCallCount())
+
+	files, err := filepath.Glob(filepath.Join(s.dir, imagesDir, "*", "*"))
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		filepath.Join(s.imageDir(fingerprint), "meta.tar.xz"),
+		filepath.Join(s.imageDir(fingerprint), "rootfs.squashfs"),
+		filepath.Join(s.imageDir(fingerprint), productFile),
+	}, files)
+
+	assert.NoError(t, s.Remove(fingerprint))
+	assert.False(t, s.Has(fingerprint))
+}
+
+func TestStore_Add_Unsupported(t *testing.T) {
+	t.Parallel()
+
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	fake, fingerprint := fakeImage([]byte("meta"), []byte("disk"))
+	stub := fake.GetImageFileStub
+	fake.GetImageFileStub = func(fp string, req lxd.ImageFileRequest) (*lxd.ImageFileResponse, error) {
+		resp, err := stub(fp, req)
+		resp.RootfsName = fp + ".qcow2"
+
+		return resp, err
+	}
+
+	err := s.Add(fake, fingerprint)
+	assert.True(t, errors.Is(err, ErrUnsupportedImage))
+	assert.False(t, s.Has(fingerprint))
+
+	// the temporary directory is gone
+	files, err := filepath.Glob(filepath.Join(s.dir, imagesDir, "*"))
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestStore_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	s, cleanup := testStore(t)
+	defer cleanup()
+
+	split, splitFingerprint := fakeImage([]byte("meta"), []byte("rootfs"))
+	unified, unifiedFingerprint := fakeImage([]byte("unified"), nil)
+
+	assert.NoError(t, s.Add(split, splitFingerprint))
+	assert.NoError(t, s.Add(unified, unifiedFingerprint))
+
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	// LXD reads the remote like any other simplestreams remote
+	remote, err := lxd.ConnectSimpleStreams(srv.URL, nil)
+	assert.NoError(t, err)
+
+	image, _, err := remote.GetImage(splitFingerprint)
+	assert.NoError(t, err)
+	assert.Equal(t, splitFingerprint, image.Fingerprint)
+	assert.Equal(t, "squashfs", image.Properties["type"])
+
+	image, _, err = remote.GetImage(unifiedFingerprint)
+	assert.NoError(t, err)
+	assert.Equal(t, unifiedFingerprint, image.Fingerprint)
+
+	// the files are verified against their hashes while downloading
+	var meta, rootfs testFile
+
+	_, err = remote.GetImageFile(splitFingerprint, lxd.ImageFileRequest{MetaFile: &meta, RootfsFile: &rootfs})
+	assert.NoError(t, err)
+	assert.Equal(t, "meta", meta.String())
+	assert.Equal(t, "rootfs", rootfs.String())
+
+	for _, path := range []string{
+		"/images/" + splitFingerprint + "/" + productFile,
+		"/images/" + splitFingerprint + "/",
+		"/images/" + tmpPrefix + splitFingerprint + "/meta.tar.xz",
+		"/other",
+	} {
+		resp, err := http.Get(srv.URL + path)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+	}
+
+	resp, err := http.Post(srv.URL+indexPath, "application/json", nil)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func Test_fileSuffix(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, ".tar.xz", fileSuffix("meta-abc.tar.xz"))
+	assert.Equal(t, ".squashfs", fileSuffix("abc.squashfs"))
+	assert.Equal(t, "", fileSuffix("abc"))
+}
+
+// testFile is an in-memory io.WriteSeeker
+type testFile struct {
+	bytes.Buffer
+}
+
+func (f *testFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, nil
+}
@@ -0,0 +1,220 @@
+package logging // import "github.com/automaticserver/lxe/logging"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// FieldSubsystem is the log field naming the subsystem an entry comes from
+	FieldSubsystem = "subsystem"
+	// FieldTraceID and FieldSpanID are the log fields of the trace span an entry was logged in
+	FieldTraceID = "traceid"
+	FieldSpanID  = "spanid"
+)
+
+var (
+	ErrUnknownSubsystem = errors.New("unknown log subsystem")
+	ErrInvalidLevels    = errors.New("invalid subsystem log levels")
+)
+
+// contextKey is the type of the context keys of this package
+type contextKey int
+
+// contextFieldsKey stores the request scoped log fields in a context
+const contextFieldsKey contextKey = 0
+
+// levels are the log level of entries without subsystem and the overrides of the subsystems
+type levels struct {
+	mu         sync.RWMutex
+	def        logrus.Level
+	subsystems map[string]logrus.Level
+	// known are the names of the subsystems which have a logger
+	known map[string]bool
+}
+
+var current = &levels{
+	def:        logrus.InfoLevel,
+	subsystems: map[string]logrus.Level{},
+	known:      map[string]bool{},
+}
+
+// Subsystem returns the logger of the subsystem, its entries have the subsystem field set so their level can be
+// overridden
+func Subsystem(name string) *logrus.Entry {
+	current.mu.Lock()
+	current.known[name] = true
+	current.mu.Unlock()
+
+	return logrus.StandardLogger().WithContext(context.TODO()).WithField(FieldSubsystem, name)
+}
+
+// Subsystems returns the sorted names of the subsystems which have a logger
+func Subsystems() []string {
+	current.mu.RLock()
+	defer current.mu.RUnlock()
+
+	return current.names()
+}
+
+// names returns the sorted names of the known subsystems, the caller must hold the lock
+func (l *levels) names() []string {
+	names := make([]string, 0, len(l.known))
+	for name := range l.known {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// ParseLevels parses the subsystem levels in the form subsystem=level
+func ParseLevels(entries []string) (map[string]logrus.Level, error) {
+	subsystems := map[string]logrus.Level{}
+
+	for _, e := range entries {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("%w: %s, must be subsystem=level", ErrInvalidLevels, e)
+		}
+
+		level, err := logrus.ParseLevel(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidLevels, e, err)
+		}
+
+		subsystems[parts[0]] = level
+	}
+
+	return subsystems, nil
+}
+
+// SetLevels sets the default level and replaces the overrides of the subsystems. The level of the standard logger is
+// raised to the most verbose one, so the entries of subsystems with a more verbose level are created at all
+func SetLevels(def logrus.Level, subsystems map[string]logrus.Level) error {
+	current.mu.Lock()
+	defer current.mu.Unlock()
+
+	max := def
+
+	for name, level := range subsystems {
+		if !current.known[name] {
+			return fmt.Errorf("%w: %s, one of: %s", ErrUnknownSubsystem, name, strings.Join(current.names(), ", "))
+		}
+
+		if level > max {
+			max = level
+		}
+	}
+
+	current.def = def
+	current.subsystems = map[string]logrus.Level{}
+
+	for name, level := range subsystems {
+		current.subsystems[name] = level
+	}
+
+	logrus.SetLevel(max)
+
+	return nil
+}
+
+// Levels returns the default level and the overrides of the subsystems
+func Levels() (logrus.Level, map[string]logrus.Level) {
+	current.mu.RLock()
+	defer current.mu.RUnlock()
+
+	subsystems := make(map[string]logrus.Level, len(current.subsystems))
+	for name, level := range current.subsystems {
+		subsystems[name] = level
+	}
+
+	return current.def, subsystems
+}
+
+// Enabled checks if the entry is logged with the level of its subsystem, or the default level if it has none
+func Enabled(entry *logrus.Entry) bool {
+	current.mu.RLock()
+	defer current.mu.RUnlock()
+
+	level := current.def
+
+	if name, ok := entry.Data[FieldSubsystem].(string); ok {
+		if l, has := current.subsystems[name]; has {
+			level = l
+		}
+	}
+
+	return entry.Level <= level
+}
+
+// WithFields returns a context carrying the log fields in addition to the ones it already carries, e.g. the CRI method
+// and the pod of a request
+func WithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	merged := logrus.Fields{}
+
+	for k, v := range ContextFields(ctx) {
+		merged[k] = v
+	}
+
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	return context.WithValue(ctx, contextFieldsKey, merged)
+}
+
+// ContextFields returns the log fields the context carries
+func ContextFields(ctx context.Context) logrus.Fields {
+	fields, _ := ctxOrBackground(ctx).Value(contextFieldsKey).(logrus.Fields)
+
+	return fields
+}
+
+// ctxOrBackground returns ctx, or the background context if it's nil as entries may have none
+func ctxOrBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+
+	return ctx
+}
+
+// Decorate returns the entry with the log fields of its context and the ids of its trace span added, fields set on the
+// entry itself have priority. The entry isn't modified, as its data is shared with the entry it was derived from
+func Decorate(entry *logrus.Entry) *logrus.Entry {
+	fields := ContextFields(entry.Context)
+	span := trace.SpanContextFromContext(ctxOrBackground(entry.Context))
+
+	if len(fields) == 0 && !span.IsValid() {
+		return entry
+	}
+
+	data := make(logrus.Fields, len(fields)+len(entry.Data)+2) // nolint: gomnd
+
+	for k, v := range fields {
+		data[k] = v
+	}
+
+	if span.IsValid() {
+		data[FieldTraceID] = span.TraceID().String()
+		data[FieldSpanID] = span.SpanID().String()
+	}
+
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+
+	decorated := *entry
+	decorated.Data = data
+
+	return &decorated
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestParseLevels(t *testing.T) {
+	subsystems, err := ParseLevels([]string{"network=debug", "lxf=warn"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]logrus.Level{"network": logrus.DebugLevel, "lxf": logrus.WarnLevel}, subsystems)
+
+	for _, e := range []string{"network", "=debug", "network=loud"} {
+		_, err = ParseLevels([]string{e})
+		assert.True(t, errors.Is(err, ErrInvalidLevels), e)
+	}
+}
+
+func TestSetLevels(t *testing.T) {
+	log := Subsystem("test")
+
+	err := SetLevels(logrus.WarnLevel, map[string]logrus.Level{"unknown": logrus.DebugLevel})
+	assert.True(t, errors.Is(err, ErrUnknownSubsystem))
+
+	err = SetLevels(logrus.WarnLevel, map[string]logrus.Level{"test": logrus.DebugLevel})
+	assert.NoError(t, err)
+	assert.Equal(t, logrus.DebugLevel, logrus.GetLevel(), "standard logger creates the most verbose entries")
+	assert.Contains(t, Subsystems(), "test")
+
+	entry := log.WithField("a", "b")
+	entry.Level = logrus.DebugLevel
+	assert.True(t, Enabled(entry))
+
+	entry = logrus.NewEntry(logrus.StandardLogger())
+	entry.Level = logrus.DebugLevel
+	assert.False(t, Enabled(entry), "entries without subsystem have the default level")
+
+	entry.Level = logrus.WarnLevel
+	assert.True(t, Enabled(entry))
+
+	def, subsystems := Levels()
+	assert.Equal(t, logrus.WarnLevel, def)
+	assert.Equal(t, map[string]logrus.Level{"test": logrus.DebugLevel}, subsystems)
+}
+
+func TestDecorate(t *testing.T) {
+	ctx := WithFields(context.Background(), logrus.Fields{"method": "StartContainer", "containerid": "c1"})
+	ctx = WithFields(ctx, logrus.Fields{"podid": "p1"})
+
+	entry := logrus.NewEntry(logrus.StandardLogger()).WithContext(ctx).WithField("containerid", "c2")
+
+	decorated := Decorate(entry)
+	assert.Equal(t, logrus.Fields{"method": "StartContainer", "containerid": "c2", "podid": "p1"}, decorated.Data)
+	assert.Equal(t, logrus.Fields{"containerid": "c2"}, entry.Data, "entry is not modified")
+
+	entry = logrus.NewEntry(logrus.StandardLogger())
+	assert.Same(t, entry, Decorate(entry))
+}
+
+func TestDecorate_Trace(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+
+	entry := logrus.NewEntry(logrus.StandardLogger()).WithContext(ctx)
+
+	decorated := Decorate(entry)
+	assert.Equal(t, logrus.Fields{FieldTraceID: "4bf92f3577b34da6a3ce929d0e0e4736", FieldSpanID: "00f067aa0ba902b7"}, decorated.Data)
+}
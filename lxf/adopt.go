@@ -0,0 +1,100 @@
+package lxf // import "github.com/automaticserver/lxe/lxf"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+const (
+	// cfgAdopt marks a container not managed by LXE to be adopted by the pod namespace/name
+	cfgAdopt = "user.adopt"
+	// adoptStopTimeout is how long a running container gets to shut down when it's adopted
+	adoptStopTimeout = 30
+)
+
+var (
+	ErrNotAdoptable = errors.New("container can't be adopted")
+)
+
+// MarkAdoption marks the LXD container, which isn't managed by LXE, to be adopted by the pod in the form
+// namespace/name. The container is only adopted by a pod with that name. The returned container only contains the
+// properties needed to describe the pod
+func (l *client) MarkAdoption(ctx context.Context, id, pod string) (*Container, error) {
+	ct, ETag, err := l.server.GetContainer(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// regardless of the schema version
+	if _, managed := ct.Config[cfgIsCRI]; managed {
+		return nil, fmt.Errorf("%w: %s is already managed by LXE", ErrNotAdoptable, id)
+	}
+
+	if ct.Config[cfgVolatileBaseImage] == "" {
+		return nil, fmt.Errorf("%w: %s has no base image", ErrNotAdoptable, id)
+	}
+
+	ct.Config[cfgAdopt] = pod
+
+	err = l.opwait.UpdateContainer(ctx, id, ct.Writable(), ETag)
+	l.cache.changedContainer(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Container{}
+	c.ID = id
+	c.Image = ct.Config[cfgVolatileBaseImage]
+	c.Privileged, _ = strconv.ParseBool(ct.Config[cfgSecurityPrivileged])
+
+	return c, nil
+}
+
+// AdoptContainer returns the LXD container marked for adoption by the pod as container of the sandbox. Its profiles,
+// devices and unreserved config are kept, apply it to finish the adoption. A running container is stopped, so it's
+// started like every new container
+func (l *client) AdoptContainer(ctx context.Context, id, pod, sandboxID string) (*Container, error) {
+	ct, ETag, err := l.server.GetContainer(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, managed := ct.Config[cfgIsCRI]; managed || ct.Config[cfgAdopt] != pod {
+		return nil, fmt.Errorf("%w: %s is not marked for adoption by pod %s", ErrNotAdoptable, id, pod)
+	}
+
+	if ct.StatusCode != api.Stopped {
+		err = l.opwait.StopContainer(ctx, id, adoptStopTimeout)
+		l.cache.changedContainer(id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ct, ETag, err = l.server.GetContainer(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the sandbox profile is always the last one
+	ct.Profiles = append(ct.Profiles, sandboxID)
+
+	c, err := l.toContainer(ct, ETag)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Config[cfgState] = ContainerStateCreated.String()
+	c.CreatedAt = time.Now()
+	c.Owner = l.owner
+	c.StateName = ContainerStateCreated
+
+	return c, nil
+}
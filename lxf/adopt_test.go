@@ -0,0 +1,111 @@
+package lxf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func testAdoptee(status api.StatusCode, config map[string]string) *api.Container {
+	return &api.Container{
+		Name:       "web",
+		StatusCode: status,
+		ContainerPut: api.ContainerPut{
+			Profiles: []string{"default"},
+			Config:   config,
+			Devices:  map[string]map[string]string{"data": {"type": "disk", "source": "/srv", "path": "/srv"}},
+		},
+	}
+}
+
+func TestClient_MarkAdoption(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	fake.GetContainerReturns(testAdoptee(api.Running, map[string]string{
+		cfgVolatileBaseImage:  "abc",
+		cfgSecurityPrivileged: "true",
+	}), "etag", nil)
+	fake.UpdateContainerReturns(&lxdfakes.FakeOperation{}, nil)
+
+	c, err := client.MarkAdoption(ctx, "web", "default/web")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", c.Image)
+	assert.True(t, c.Privileged)
+
+	name, put, etag := fake.UpdateContainerArgsForCall(0)
+	assert.Equal(t, "web", name)
+	assert.Equal(t, "etag", etag)
+	assert.Equal(t, "default/web", put.Config[cfgAdopt])
+}
+
+func TestClient_MarkAdoption_NotAdoptable(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+
+	for _, config := range []map[string]string{
+		{cfgVolatileBaseImage: "abc", cfgIsCRI: "true"},
+		{},
+	} {
+		fake.GetContainerReturns(testAdoptee(api.Stopped, config), "etag", nil)
+
+		_, err := client.MarkAdoption(ctx, "web", "default/web")
+		assert.True(t, errors.Is(err, ErrNotAdoptable))
+	}
+
+	assert.Equal(t, 0, fake.UpdateContainerCallCount())
+}
+
+func TestClient_AdoptContainer(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	fake.GetContainerReturns(testAdoptee(api.Stopped, map[string]string{
+		cfgVolatileBaseImage: "abc",
+		cfgAdopt:             "default/web",
+		"limits.cpu":         "2",
+	}), "etag", nil)
+
+	c, err := client.AdoptContainer(ctx, "web", "default/web", "sb")
+	assert.NoError(t, err)
+	assert.Equal(t, "web", c.ID)
+	assert.Equal(t, "etag", c.ETag)
+	assert.Equal(t, []string{"default", "sb"}, c.Profiles)
+	assert.Equal(t, "sb", c.SandboxID())
+	assert.Equal(t, ContainerStateCreated, c.StateName)
+	assert.Equal(t, "2", c.Config["limits.cpu"])
+	assert.NotContains(t, c.Config, cfgAdopt, "the mark is removed when applied")
+	assert.Len(t, c.Devices, 1)
+	assert.False(t, c.CreatedAt.IsZero())
+	assert.Equal(t, 0, fake.UpdateContainerStateCallCount())
+}
+
+func TestClient_AdoptContainer_Running(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	fake.GetContainerReturnsOnCall(0, testAdoptee(api.Running, map[string]string{cfgAdopt: "default/web"}), "etag", nil)
+	fake.GetContainerReturnsOnCall(1, testAdoptee(api.Stopped, map[string]string{cfgAdopt: "default/web"}), "etag2", nil)
+	fake.UpdateContainerStateReturns(&lxdfakes.FakeOperation{}, nil)
+
+	c, err := client.AdoptContainer(ctx, "web", "default/web", "sb")
+	assert.NoError(t, err)
+	assert.Equal(t, "etag2", c.ETag)
+
+	_, state, _ := fake.UpdateContainerStateArgsForCall(0)
+	assert.Equal(t, "stop", state.Action)
+}
+
+func TestClient_AdoptContainer_NotMarked(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	fake.GetContainerReturns(testAdoptee(api.Stopped, map[string]string{cfgAdopt: "default/other"}), "etag", nil)
+
+	_, err := client.AdoptContainer(ctx, "web", "default/web", "sb")
+	assert.True(t, errors.Is(err, ErrNotAdoptable))
+}
@@ -0,0 +1,424 @@
+package lxf // import "github.com/automaticserver/lxe/lxf"
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/automaticserver/lxe/shared"
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+)
+
+const (
+	// eventLifecycleExtension is the LXD API extension providing the lifecycle events the cache relies on
+	eventLifecycleExtension = "event_lifecycle"
+)
+
+var (
+	// stateCacheResync is how long the cache is served before it's fully refetched, in case an event got lost
+	stateCacheResync = 5 * time.Minute
+)
+
+// cachedContainer is a container as fetched from LXD. The ETag is only known if it was fetched individually
+type cachedContainer struct {
+	ct   api.Container
+	etag string
+}
+
+// cachedProfile is a profile as fetched from LXD. The ETag is only known if it was fetched individually
+type cachedProfile struct {
+	p    api.Profile
+	etag string
+}
+
+// stateCache keeps the containers and profiles of LXD in memory, so kubelet's frequent relisting doesn't result in
+// full list calls to LXD every time. It is invalidated by LXD's lifecycle events: a changed object is marked dirty and
+// refetched individually on the next access. The cache is only used while the event listener is connected, otherwise
+// every call goes to LXD directly
+type stateCache struct {
+	mu sync.Mutex
+	// listener is the generation of the connected event listener the cache is valid for, 0 if there's none
+	listener uint64
+	// generations counts the event listeners ever started
+	generations uint64
+	// syncedAt is when the containers and profiles were fully fetched
+	syncedAt time.Time
+	// containers and profiles by name, nil if they must be fully fetched
+	containers map[string]*cachedContainer
+	profiles   map[string]*cachedProfile
+	// dirtyContainers and dirtyProfiles must be refetched before they are served again
+	dirtyContainers map[string]bool
+	dirtyProfiles   map[string]bool
+	// containerIndex and profileIndex look up the objects by sandbox and labels, nil if they must be rebuilt because
+	// an object changed
+	containerIndex *cacheIndex
+	profileIndex   *cacheIndex
+}
+
+func newStateCache() *stateCache {
+	c := &stateCache{}
+	c.reset()
+
+	return c
+}
+
+// reset drops all cached objects
+func (c *stateCache) reset() {
+	c.containers = nil
+	c.profiles = nil
+	c.dirtyContainers = map[string]bool{}
+	c.dirtyProfiles = map[string]bool{}
+	c.containerIndex = nil
+	c.profileIndex = nil
+}
+
+// start enables the cache for a newly connected event listener and returns its generation
+func (c *stateCache) start() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generations++
+	c.listener = c.generations
+	c.reset()
+
+	return c.listener
+}
+
+// stop disables the cache if the event listener of the generation is still the active one, since events might get
+// lost from now on
+func (c *stateCache) stop(generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.listener == generation {
+		c.listener = 0
+		c.reset()
+	}
+}
+
+// watch enables the cache while the event listener is connected. Does nothing if LXD doesn't send lifecycle events
+func (c *stateCache) watch(server lxd.ContainerServer, listener *lxd.EventListener) {
+	if !server.HasExtension(eventLifecycleExtension) {
+		log.Warnf("LXD is missing the API extension %s, the state cache is disabled", eventLifecycleExtension)
+		return
+	}
+
+	generation := c.start()
+
+	go func() {
+		err := listener.Wait()
+		if err != nil {
+			log.WithError(err).Warn("LXD event listener disconnected, the state cache is disabled")
+		}
+
+		c.stop(generation)
+	}()
+}
+
+// lifecycle marks the object the lifecycle event is about as changed
+func (c *stateCache) lifecycle(event api.EventLifecycle) {
+	// e.g. /1.0/instances/foo or /1.0/profiles/bar?project=default
+	source := strings.SplitN(event.Source, "?", 2)[0]
+	parts := strings.Split(strings.TrimPrefix(source, "/"), "/")
+
+	if len(parts) < 3 {
+		return
+	}
+
+	kind, name := parts[1], parts[2]
+	// the event of a rename is about the new name, so we don't know which one is gone
+	renamed := strings.HasSuffix(event.Action, "-renamed")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch kind {
+	case "containers", "instances":
+		if renamed {
+			c.containers = nil
+		}
+
+		c.dirtyContainers[name] = true
+	case "profiles":
+		if renamed {
+			c.profiles = nil
+		}
+
+		c.dirtyProfiles[name] = true
+	}
+}
+
+// changedContainer marks the container as changed by LXE itself, so it's refetched without waiting for the event
+func (c *stateCache) changedContainer(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dirtyContainers[name] = true
+}
+
+// changedProfile marks the profile as changed by LXE itself, so it's refetched without waiting for the event
+func (c *stateCache) changedProfile(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dirtyProfiles[name] = true
+}
+
+// expired returns true if the full lists must be fetched again
+func (c *stateCache) expired() bool {
+	return c.containers == nil || c.profiles == nil || time.Since(c.syncedAt) > stateCacheResync
+}
+
+// sync fetches the full lists if expired and refetches the dirty objects
+func (c *stateCache) sync(server lxd.ContainerServer) error {
+	if c.expired() {
+		cts, err := server.GetContainers()
+		if err != nil {
+			return err
+		}
+
+		ps, err := server.GetProfiles()
+		if err != nil {
+			return err
+		}
+
+		c.reset()
+		c.containers = make(map[string]*cachedContainer, len(cts))
+		c.profiles = make(map[string]*cachedProfile, len(ps))
+
+		for _, ct := range cts {
+			c.containers[ct.Name] = &cachedContainer{ct: ct}
+		}
+
+		for _, p := range ps {
+			c.profiles[p.Name] = &cachedProfile{p: p}
+		}
+
+		c.syncedAt = time.Now()
+
+		return nil
+	}
+
+	for name := range c.dirtyContainers {
+		err := c.refetchContainer(server, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	for name := range c.dirtyProfiles {
+		err := c.refetchProfile(server, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *stateCache) refetchContainer(server lxd.ContainerServer, name string) error {
+	ct, etag, err := server.GetContainer(name)
+	if err != nil {
+		if !shared.IsErrNotFound(err) {
+			return err
+		}
+
+		delete(c.containers, name)
+	} else {
+		c.containers[name] = &cachedContainer{ct: *ct, etag: etag}
+	}
+
+	delete(c.dirtyContainers, name)
+	c.containerIndex = nil
+
+	return nil
+}
+
+func (c *stateCache) refetchProfile(server lxd.ContainerServer, name string) error {
+	p, etag, err := server.GetProfile(name)
+	if err != nil {
+		if !shared.IsErrNotFound(err) {
+			return err
+		}
+
+		delete(c.profiles, name)
+	} else {
+		c.profiles[name] = &cachedProfile{p: *p, etag: etag}
+	}
+
+	delete(c.dirtyProfiles, name)
+	c.profileIndex = nil
+
+	return nil
+}
+
+// getContainers returns all containers, from the cache if it's enabled
+func (c *stateCache) getContainers(server lxd.ContainerServer) ([]api.Container, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.listener == 0 {
+		return server.GetContainers()
+	}
+
+	err := c.sync(server)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(c.containers))
+	for name := range c.containers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	cts := make([]api.Container, 0, len(names))
+	for _, name := range names {
+		cts = append(cts, copyContainer(c.containers[name].ct))
+	}
+
+	return cts, nil
+}
+
+// getContainer returns the container, from the cache if it's enabled and its ETag is known
+func (c *stateCache) getContainer(server lxd.ContainerServer, name string) (*api.Container, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.listener == 0 || c.containers == nil {
+		return server.GetContainer(name)
+	}
+
+	if e, has := c.containers[name]; !has || e.etag == "" || c.dirtyContainers[name] {
+		// not found containers are looked up again, it might be created meanwhile
+		ct, etag, err := server.GetContainer(name)
+		if err != nil {
+			if shared.IsErrNotFound(err) {
+				delete(c.containers, name)
+				delete(c.dirtyContainers, name)
+				c.containerIndex = nil
+			}
+
+			return nil, "", err
+		}
+
+		c.containers[name] = &cachedContainer{ct: *ct, etag: etag}
+		delete(c.dirtyContainers, name)
+		c.containerIndex = nil
+	}
+
+	e := c.containers[name]
+	ct := copyContainer(e.ct)
+
+	return &ct, e.etag, nil
+}
+
+// getProfiles returns all profiles, from the cache if it's enabled
+func (c *stateCache) getProfiles(server lxd.ContainerServer) ([]api.Profile, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.listener == 0 {
+		return server.GetProfiles()
+	}
+
+	err := c.sync(server)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(c.profiles))
+	for name := range c.profiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	ps := make([]api.Profile, 0, len(names))
+	for _, name := range names {
+		ps = append(ps, copyProfile(c.profiles[name].p))
+	}
+
+	return ps, nil
+}
+
+// getProfile returns the profile, from the cache if it's enabled and its ETag is known
+func (c *stateCache) getProfile(server lxd.ContainerServer, name string) (*api.Profile, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.listener == 0 || c.profiles == nil {
+		return server.GetProfile(name)
+	}
+
+	if e, has := c.profiles[name]; !has || e.etag == "" || c.dirtyProfiles[name] {
+		p, etag, err := server.GetProfile(name)
+		if err != nil {
+			if shared.IsErrNotFound(err) {
+				delete(c.profiles, name)
+				delete(c.dirtyProfiles, name)
+				c.profileIndex = nil
+			}
+
+			return nil, "", err
+		}
+
+		c.profiles[name] = &cachedProfile{p: *p, etag: etag}
+		delete(c.dirtyProfiles, name)
+		c.profileIndex = nil
+	}
+
+	e := c.profiles[name]
+	p := copyProfile(e.p)
+
+	return &p, e.etag, nil
+}
+
+// copyContainer copies the maps and slices of the container, so the callers can't modify the cached one
+func copyContainer(ct api.Container) api.Container {
+	ct.Config = copyStringMap(ct.Config)
+	ct.ExpandedConfig = copyStringMap(ct.ExpandedConfig)
+	ct.Devices = copyDevices(ct.Devices)
+	ct.ExpandedDevices = copyDevices(ct.ExpandedDevices)
+	ct.Profiles = append([]string(nil), ct.Profiles...)
+
+	return ct
+}
+
+// copyProfile copies the maps and slices of the profile, so the callers can't modify the cached one
+func copyProfile(p api.Profile) api.Profile {
+	p.Config = copyStringMap(p.Config)
+	p.Devices = copyDevices(p.Devices)
+	p.UsedBy = append([]string(nil), p.UsedBy...)
+
+	return p
+}
+
+func copyDevices(devices map[string]map[string]string) map[string]map[string]string {
+	if devices == nil {
+		return nil
+	}
+
+	c := make(map[string]map[string]string, len(devices))
+	for name, options := range devices {
+		c[name] = copyStringMap(options)
+	}
+
+	return c
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+
+	return c
+}
@@ -0,0 +1,161 @@
+package lxf
+
+import (
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	"github.com/automaticserver/lxe/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStateCache() (*stateCache, *lxdfakes.FakeContainerServer) {
+	fake := &lxdfakes.FakeContainerServer{}
+	fake.GetContainersReturns([]api.Container{{Name: "foo"}, {Name: "bar"}}, nil)
+	fake.GetProfilesReturns([]api.Profile{{Name: "default"}, {Name: "sb"}}, nil)
+
+	c := newStateCache()
+	c.start()
+
+	return c, fake
+}
+
+func TestStateCache_Disabled(t *testing.T) {
+	t.Parallel()
+
+	c, fake := testStateCache()
+	c.stop(c.listener)
+
+	for i := 0; i < 2; i++ {
+		_, err := c.getContainers(fake)
+		assert.NoError(t, err)
+		_, err = c.getProfiles(fake)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 2, fake.GetContainersCallCount())
+	assert.Equal(t, 2, fake.GetProfilesCallCount())
+}
+
+func TestStateCache_List(t *testing.T) {
+	t.Parallel()
+
+	c, fake := testStateCache()
+
+	for i := 0; i < 3; i++ {
+		cts, err := c.getContainers(fake)
+		assert.NoError(t, err)
+		assert.Len(t, cts, 2)
+		assert.Equal(t, "bar", cts[0].Name, "sorted by name")
+
+		ps, err := c.getProfiles(fake)
+		assert.NoError(t, err)
+		assert.Len(t, ps, 2)
+	}
+
+	assert.Equal(t, 1, fake.GetContainersCallCount())
+	assert.Equal(t, 1, fake.GetProfilesCallCount())
+	assert.Equal(t, 0, fake.GetContainerCallCount())
+}
+
+func TestStateCache_Lifecycle(t *testing.T) {
+	t.Parallel()
+
+	c, fake := testStateCache()
+
+	_, err := c.getContainers(fake)
+	assert.NoError(t, err)
+
+	fake.GetContainerReturns(&api.Container{Name: "foo", StatusCode: api.Running}, "etag", nil)
+	c.lifecycle(api.EventLifecycle{Action: "instance-started", Source: "/1.0/instances/foo"})
+
+	cts, err := c.getContainers(fake)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.GetContainersCallCount(), "no full list")
+	assert.Equal(t, 1, fake.GetContainerCallCount())
+	assert.Equal(t, "foo", fake.GetContainerArgsForCall(0))
+	assert.Equal(t, api.Running, cts[1].StatusCode)
+
+	fake.GetContainerReturns(nil, "", shared.NewErrNotFound())
+	c.lifecycle(api.EventLifecycle{Action: "container-deleted", Source: "/1.0/containers/foo?project=default"})
+
+	cts, err = c.getContainers(fake)
+	assert.NoError(t, err)
+	assert.Len(t, cts, 1)
+	assert.Equal(t, "bar", cts[0].Name)
+
+	c.lifecycle(api.EventLifecycle{Action: "profile-deleted", Source: "/1.0/profiles/sb"})
+	fake.GetProfileReturns(nil, "", shared.NewErrNotFound())
+
+	ps, err := c.getProfiles(fake)
+	assert.NoError(t, err)
+	assert.Len(t, ps, 1)
+	assert.Equal(t, "sb", fake.GetProfileArgsForCall(0))
+}
+
+func TestStateCache_Renamed(t *testing.T) {
+	t.Parallel()
+
+	c, fake := testStateCache()
+
+	_, err := c.getContainers(fake)
+	assert.NoError(t, err)
+
+	c.lifecycle(api.EventLifecycle{Action: "instance-renamed", Source: "/1.0/instances/baz"})
+
+	_, err = c.getContainers(fake)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fake.GetContainersCallCount())
+}
+
+func TestStateCache_Get(t *testing.T) {
+	t.Parallel()
+
+	c, fake := testStateCache()
+
+	_, err := c.getContainers(fake)
+	assert.NoError(t, err)
+
+	fake.GetContainerReturns(&api.Container{Name: "foo", ContainerPut: api.ContainerPut{Config: map[string]string{"a": "b"}}}, "etag", nil)
+
+	// the ETag is unknown from the list, so it's fetched once
+	for i := 0; i < 2; i++ {
+		ct, etag, err := c.getContainer(fake, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, "etag", etag)
+
+		// callers can't modify the cached container
+		ct.Config["a"] = "c"
+	}
+
+	assert.Equal(t, 1, fake.GetContainerCallCount())
+
+	ct, _, err := c.getContainer(fake, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", ct.Config["a"])
+
+	c.changedContainer("foo")
+
+	_, _, err = c.getContainer(fake, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fake.GetContainerCallCount())
+
+	fake.GetContainerReturns(nil, "", shared.NewErrNotFound())
+
+	_, _, err = c.getContainer(fake, "missing")
+	assert.True(t, shared.IsErrNotFound(err))
+}
+
+func TestStateCache_StopOldGeneration(t *testing.T) {
+	t.Parallel()
+
+	c, _ := testStateCache()
+	old := c.listener
+
+	current := c.start()
+	c.stop(old)
+	assert.Equal(t, current, c.listener)
+
+	c.stop(current)
+	assert.Zero(t, c.listener)
+}
@@ -0,0 +1,39 @@
+package lxf // import "github.com/automaticserver/lxe/lxf"
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// checkpointSnapshotPrefix starts the name of the temporary stateful snapshot and backup a container is checkpointed
+// with
+const checkpointSnapshotPrefix = "lxe-checkpoint-"
+
+// CheckpointContainer saves the filesystem and the runtime state of the running container with a stateful snapshot,
+// which requires CRIU, and writes it as backup tarball of LXD to w. The container keeps running, the snapshot and the
+// backup are only temporary
+func (l *client) CheckpointContainer(ctx context.Context, cid string, w io.WriteSeeker) error {
+	name := fmt.Sprintf("%s%d", checkpointSnapshotPrefix, time.Now().UnixNano())
+
+	err := l.CreateSnapshot(ctx, cid, name, true)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err := l.DeleteSnapshot(ctx, cid, name)
+		if err != nil {
+			log.WithError(err).WithField("containerid", cid).WithField("snapshot", name).
+				Warn("unable to delete snapshot of checkpoint")
+		}
+	}()
+
+	err = l.opwait.ExportContainer(ctx, cid, name, w)
+	if err != nil {
+		return fmt.Errorf("unable to checkpoint container %v, %w", cid, err)
+	}
+
+	return nil
+}
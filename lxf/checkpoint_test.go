@@ -0,0 +1,51 @@
+package lxf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_CheckpointContainer(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	fake.CreateContainerSnapshotReturns(&lxdfakes.FakeOperation{}, nil)
+	fake.DeleteContainerSnapshotReturns(&lxdfakes.FakeOperation{}, nil)
+	fake.CreateContainerBackupReturns(&lxdfakes.FakeOperation{}, nil)
+	fake.DeleteContainerBackupReturns(&lxdfakes.FakeOperation{}, nil)
+
+	err := client.CheckpointContainer(ctx, "foo", nil)
+	assert.NoError(t, err)
+
+	cid, snap := fake.CreateContainerSnapshotArgsForCall(0)
+	assert.Equal(t, "foo", cid)
+	assert.True(t, snap.Stateful)
+	assert.True(t, strings.HasPrefix(snap.Name, checkpointSnapshotPrefix))
+
+	cid, backup := fake.CreateContainerBackupArgsForCall(0)
+	assert.Equal(t, "foo", cid)
+	assert.Equal(t, snap.Name, backup.Name)
+	assert.Equal(t, 1, fake.GetContainerBackupFileCallCount())
+
+	// the snapshot is only temporary
+	cid, name := fake.DeleteContainerSnapshotArgsForCall(0)
+	assert.Equal(t, "foo", cid)
+	assert.Equal(t, snap.Name, name)
+}
+
+func TestClient_CheckpointContainer_Failed(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	fake.CreateContainerSnapshotReturns(&lxdfakes.FakeOperation{}, nil)
+	fake.DeleteContainerSnapshotReturns(&lxdfakes.FakeOperation{}, nil)
+	fake.CreateContainerBackupReturns(nil, errors.New("something failed"))
+
+	err := client.CheckpointContainer(ctx, "foo", nil)
+	assert.Error(t, err)
+	assert.Equal(t, 1, fake.DeleteContainerSnapshotCallCount())
+}
@@ -7,12 +7,15 @@ import (
 	"io"
 	"net/http"
 	"path"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/automaticserver/lxe/logging"
 	"github.com/automaticserver/lxe/lxf/lxo"
 	lxd "github.com/lxc/lxd/client"
 	"github.com/lxc/lxd/lxc/config"
-	"github.com/sirupsen/logrus"
+	"github.com/lxc/lxd/shared/api"
 	"gopkg.in/fsnotify.v1"
 	"k8s.io/client-go/tools/remotecommand"
 )
@@ -34,11 +37,32 @@ type Client interface {
 	GetRuntimeInfo() (*RuntimeInfo, error)
 	// SetEventHandler for container's starting and stopping events
 	SetEventHandler(eh EventHandler)
-
+	// Batch calls fn for every index from 0 to n-1 concurrently with the configured number of workers, e.g. to run the
+	// operations on all containers of a sandbox. It returns when all are done with the error of the lowest index
+	Batch(n int, fn func(i int) error) error
+	// Close disconnects the event listener and stops reconnecting to LXD, e.g. on shutdown
+	Close()
+
+	// SetImagePolicy replaces the policy pulled images are checked against, nil allows all images
+	SetImagePolicy(p *ImagePolicy)
+	// SetImageCache sets the remote images are pulled from first, if it has them. Empty disables it
+	SetImageCache(remote string)
+	// SetNamePrefix sets the prefix of the names of the sandboxes and containers created from now on
+	SetNamePrefix(prefix string)
 	// PullImage copies the given image from the remote server
-	PullImage(name string) (string, error)
+	PullImage(ctx context.Context, name string) (string, error)
+	// ImageRemote returns the remote of the LXD client config the given image is pulled from
+	ImageRemote(name string) (string, error)
+	// ImportImage creates the given image from the LXD image files, either a unified tarball as meta and a nil rootfs
+	// or the metadata and rootfs tarballs of a split image, and returns its hash
+	ImportImage(ctx context.Context, name string, meta, rootfs io.Reader) (string, error)
+	// CommitContainer publishes the container as image with the alias of the image reference, so pods can use it by
+	// that name, and returns its hash
+	CommitContainer(ctx context.Context, cid, name string, opts CommitOptions) (string, error)
+	// CheckpointContainer writes the filesystem and runtime state of the running container as LXD backup tarball to w
+	CheckpointContainer(ctx context.Context, cid string, w io.WriteSeeker) error
 	// RemoveImage will remove the given image
-	RemoveImage(name string) error
+	RemoveImage(ctx context.Context, name string) error
 	// ListImages will list all local images from the lxd server
 	ListImages(filter string) ([]Image, error)
 	// GetImage will fetch information about the already downloaded image identified by name
@@ -52,6 +76,10 @@ type Client interface {
 	GetSandbox(id string) (*Sandbox, error)
 	// ListSandboxes will return a list with all the available sandboxes
 	ListSandboxes() ([]*Sandbox, error)
+	// ListForeignSandboxIDs returns the IDs of the sandboxes of the other LXE instances sharing LXD
+	ListForeignSandboxIDs() ([]string, error)
+	// FilterSandboxes returns the sandboxes matching the filter
+	FilterSandboxes(f SandboxFilter) ([]*Sandbox, error)
 
 	// NewContainer creates a local representation of a container
 	NewContainer(sandboxID string, additionalProfiles ...string) *Container
@@ -59,35 +87,90 @@ type Client interface {
 	GetContainer(id string) (*Container, error)
 	// ListContainers returns a list of all available containers
 	ListContainers() ([]*Container, error)
+	// FilterContainers returns the containers matching the filter
+	FilterContainers(f ContainerFilter) ([]*Container, error)
+	// MarkAdoption marks the LXD container, which isn't managed by LXE, to be adopted by the pod namespace/name
+	MarkAdoption(ctx context.Context, id, pod string) (*Container, error)
+	// AdoptContainer returns the LXD container marked for adoption by the pod as container of the sandbox
+	AdoptContainer(ctx context.Context, id, pod, sandboxID string) (*Container, error)
 
 	// Exec will start a command on the server and attach the provided streams. It will block till the command terminated
-	// AND all data was written to stdout/stdin. The caller is responsible to provide a sink which doesn't block.
-	Exec(cid string, cmd []string, stdin io.ReadCloser, stdout, stderr io.WriteCloser, interactive, tty bool, timeout int64, resize <-chan remotecommand.TerminalSize) (int32, error)
+	// AND all data was written to stdout/stdin. The caller is responsible to provide a sink which doesn't block. If the
+	// context is done before, the command is signalled and the LXD websockets are closed
+	Exec(ctx context.Context, cid string, cmd []string, stdin io.ReadCloser, stdout, stderr io.WriteCloser, interactive, tty bool, resize <-chan remotecommand.TerminalSize) (int32, error)
+	// ExecSync runs a command without stdin and returns its captured output and exit code. The command is killed if the
+	// timeout is reached or ctx is done, a timeout of zero waits forever
+	ExecSync(ctx context.Context, cid string, cmd []string, timeout time.Duration) (*lxo.ExecSyncResult, error)
+
+	// CreateSnapshot takes a snapshot of the container. A stateful snapshot also saves the runtime state
+	CreateSnapshot(ctx context.Context, cid, name string, stateful bool) error
+	// RestoreSnapshot restores the container to the snapshot
+	RestoreSnapshot(ctx context.Context, cid, name string, stateful bool) error
+	// ListSnapshots returns the snapshots of the container
+	ListSnapshots(cid string) ([]Snapshot, error)
+	// DeleteSnapshot deletes the snapshot of the container
+	DeleteSnapshot(ctx context.Context, cid, name string) error
 }
 
 var (
 	lxdHTTPTimeout = 10 * time.Second
-	log            = logrus.StandardLogger().WithContext(context.TODO())
+	log            = logging.Subsystem("lxf")
 )
 
+// ImageServerFunc returns the image server of the remote
+type ImageServerFunc func(remote string) (lxd.ImageServer, error)
+
 type client struct {
-	server       lxd.ContainerServer
-	config       *config.Config
+	server lxd.ContainerServer
+	config *config.Config
+	// images returns the image servers of the remotes instead of the LXD config, if set
+	images       ImageServerFunc
 	opwait       *lxo.LXO
 	eventHandler EventHandler
 	socket       string
+	opconf       lxo.Conf
+	cache        *stateCache
+	// owner is set on the created sandboxes and containers, only owned ones are returned if it's set
+	owner string
+	// namePrefix starts the names of the created sandboxes and containers
+	namePrefix string
+	// project is the LXD project everything is created in
+	project Project
+	// imagePolicy holds the *ImagePolicy pulls are checked against, it's replaced on reload
+	imagePolicy atomic.Value
+	// imageCache holds the name of the remote images are pulled from first, it's replaced on reload
+	imageCache atomic.Value
+	// listener receives the lifecycle events of the current connection
+	listener *lxd.EventListener
+	// connMu guards replacing the connection
+	connMu sync.Mutex
+	// reconnected is closed once the lost connection is established again, nil while connected
+	reconnected chan struct{}
+	// retry wakes a reconnect waiting for its backoff up
+	retry chan struct{}
+	// closed is closed by Close
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
-// NewClient will set up a connection and return the client
-func NewClient(socket string, configPath string) (Client, error) {
+// NewClient will set up a connection and return the client. The LXD operations are run as defined in opconf. With an
+// owner, several LXE instances can share LXD: each creates its sandboxes and containers with its owner and only sees
+// its own ones. Everything is done in the project, which is created if it doesn't exist
+func NewClient(socket string, configPath string, opconf lxo.Conf, owner string, project Project) (Client, error) {
 	config, err := config.LoadConfig(configPath)
 	if err != nil {
 		return nil, err
 	}
 
 	cl := &client{
-		config: config,
-		socket: socket,
+		config:  config,
+		socket:  socket,
+		opconf:  opconf,
+		cache:   newStateCache(),
+		owner:   owner,
+		project: project,
+		closed:  make(chan struct{}),
+		retry:   make(chan struct{}, 1),
 	}
 
 	err = cl.connect()
@@ -100,11 +183,69 @@ func NewClient(socket string, configPath string) (Client, error) {
 	return cl, nil
 }
 
+// NewClientWithServer returns the client using the already connected server instead of connecting to the LXD socket,
+// e.g. an in-memory one like memlxd. The remotes of the config are resolved by images, if it's set. It doesn't
+// reconnect, and lifecycle events are only received if the server provides an AddLifecycleHandler(func(api.Event))
+func NewClientWithServer(server lxd.ContainerServer, images ImageServerFunc, conf *config.Config, opconf lxo.Conf, owner string, project Project) (Client, error) {
+	cl := &client{
+		config:  conf,
+		images:  images,
+		opconf:  opconf,
+		cache:   newStateCache(),
+		owner:   owner,
+		project: project,
+		closed:  make(chan struct{}),
+		retry:   make(chan struct{}, 1),
+	}
+
+	server, err := ensureProject(server, project)
+	if err != nil {
+		return nil, err
+	}
+
+	if h, ok := server.(lifecycleHandlerAdder); ok {
+		h.AddLifecycleHandler(cl.lifecycleEventHandler)
+	}
+
+	cl.setConnection(server, nil)
+
+	return cl, nil
+}
+
+// lifecycleHandlerAdder is implemented by servers sending their lifecycle events without an event listener
+type lifecycleHandlerAdder interface {
+	AddLifecycleHandler(h func(api.Event))
+}
+
+// imageServer returns the image server of the remote
+func (l *client) imageServer(remote string) (lxd.ImageServer, error) {
+	if l.images != nil {
+		return l.images(remote)
+	}
+
+	return l.config.GetImageServer(remote)
+}
+
 // GetServer returns the lxd ContainerServer. TODO: since it created it and others want to access lxd too (lxdbridge
 // network plugin) either return it here, or extract creation of the connection outside and pass server into
 // NewClient(), but that makes the initialisation NewClient() pretty unnecessary
 func (l *client) GetServer() lxd.ContainerServer {
-	return l.server
+	return l.currentServer()
+}
+
+// Close disconnects the event listener and stops reconnecting to LXD, e.g. on shutdown
+func (l *client) Close() {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+
+		l.connMu.Lock()
+		listener := l.listener
+		l.connMu.Unlock()
+
+		if listener != nil {
+			listener.Disconnect()
+		}
+	})
 }
 
 // SetEventHandler for container's starting and stopping events
@@ -112,6 +253,23 @@ func (l *client) SetEventHandler(eh EventHandler) {
 	l.eventHandler = eh
 }
 
+// SetImagePolicy replaces the policy pulled images are checked against, nil allows all images
+func (l *client) SetImagePolicy(p *ImagePolicy) {
+	l.imagePolicy.Store(p)
+}
+
+// getImagePolicy returns the policy pulled images are checked against
+func (l *client) getImagePolicy() *ImagePolicy {
+	p, _ := l.imagePolicy.Load().(*ImagePolicy)
+
+	return p
+}
+
+// Batch calls fn for every index from 0 to n-1 concurrently with the configured number of workers
+func (l *client) Batch(n int, fn func(i int) error) error {
+	return l.opwait.Batch(n, fn)
+}
+
 type RuntimeInfo struct {
 	// API version of the container runtime. The string must be semver-compatible.
 	Version string
@@ -200,6 +358,11 @@ func (l *client) connect() error {
 		return err
 	}
 
+	server, err = ensureProject(server, l.project)
+	if err != nil {
+		return err
+	}
+
 	// register LXD eventhandler
 	listener, err := server.GetEvents()
 	if err != nil {
@@ -211,15 +374,16 @@ func (l *client) connect() error {
 		return err
 	}
 
-	l.server = server
-	l.opwait = lxo.NewClient(server)
+	l.setConnection(server, listener)
 
 	return nil
 }
 
-// detect if server needs to be connected again to. Seems to be needed if we get a lxd.RemoteOperation (e.g. in CopyImage), the op.Wait() never succeeds unless we have connected to the lxd socket again. All other lxd.Operations seem to work fine and wouldn't be needed for them.
-func (l *client) detectNeedReconnect() { // nolint: gocognit
-	// currently I know no way to find out when a socket is gone as all is encapsulated in lxd.ContainerServer. We can set an fsnotify to the socket file so we get an event when it was created. If we got such event, we try to connect again until it is successful.
+// detectNeedReconnect reconnects when the LXD socket got created again, since the event listener doesn't always notice
+// that LXD restarted, e.g. RemoteOperations like in CopyImage never succeed with the old connection. A reconnect already
+// waiting for its backoff is tried right away
+func (l *client) detectNeedReconnect() {
+	// the socket file is watched, as the connection itself is encapsulated in lxd.ContainerServer
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		log.Fatal(err.Error())
@@ -235,27 +399,18 @@ func (l *client) detectNeedReconnect() { // nolint: gocognit
 
 	for {
 		select {
+		case <-l.closed:
+			return
 		case event, ok := <-watcher.Events:
 			if !ok {
 				return
 			}
 
 			if event.Op&fsnotify.Create == fsnotify.Create && event.Name == l.socket {
-				log.Info("lxd socket got created, trying to reconnect")
-
-				go func() {
-					for {
-						err := l.connect()
-						if err != nil {
-							// print error and try again
-							log.WithError(err).Error("failed reconnecting to lxd socket")
-						} else {
-							log.Info("reconnected to lxd socket")
-
-							return
-						}
-					}
-				}()
+				log.Info("lxd socket got created")
+
+				l.lost(l.currentServer(), "lxd socket got created")
+				l.retryNow()
 			}
 
 			if event.Op&fsnotify.Remove == fsnotify.Remove && event.Name == l.socket {
@@ -1,6 +1,7 @@
 package lxf
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -11,16 +12,38 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+var (
+	ctx = context.TODO()
+)
+
 func testClient() (*client, *lxdfakes.FakeContainerServer) {
 	fake := &lxdfakes.FakeContainerServer{}
 
 	return &client{
 		server: fake,
 		config: &config.Config{},
-		opwait: lxo.NewClient(fake),
+		opwait: lxo.NewClient(fake, lxo.Conf{}),
+		cache:  newStateCache(),
+		closed: make(chan struct{}),
 	}, fake
 }
 
+func TestClient_Close(t *testing.T) {
+	t.Parallel()
+
+	client, _ := testClient()
+
+	client.Close()
+	// closing twice is fine
+	client.Close()
+
+	select {
+	case <-client.closed:
+	default:
+		t.Error("client not closed")
+	}
+}
+
 func TestClient_GetRuntimeInfo_Ok(t *testing.T) {
 	client, fake := testClient()
 	fake.GetServerReturns(&api.Server{
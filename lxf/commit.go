@@ -0,0 +1,67 @@
+package lxf // import "github.com/automaticserver/lxe/lxf"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	lxdApi "github.com/lxc/lxd/shared/api"
+)
+
+// commitSnapshotPrefix starts the name of the temporary snapshot a container is committed from
+const commitSnapshotPrefix = "lxe-commit-"
+
+var ErrCommitDigest = errors.New("can't commit to an image reference with digest")
+
+// CommitOptions are the settings of an image committed from a container
+type CommitOptions struct {
+	// Properties of the image, e.g. description or os
+	Properties map[string]string
+	// Compression is the algorithm the image is compressed with, e.g. gzip, xz, zstd or none. Empty uses the default
+	// of LXD
+	Compression string
+}
+
+// CommitContainer publishes the container as image and returns its hash. Like an imported image, it gets the alias of
+// the image reference, so pods can use it by that name. It's published from a temporary snapshot, so a running
+// container keeps running
+func (l *client) CommitContainer(ctx context.Context, cid, name string, opts CommitOptions) (string, error) {
+	imageID, err := l.parseImage(name)
+	if err != nil {
+		return "", err
+	}
+
+	if imageID.Fingerprint != "" {
+		return "", fmt.Errorf("%w: %v", ErrCommitDigest, name)
+	}
+
+	snapshot := fmt.Sprintf("%s%d", commitSnapshotPrefix, time.Now().UnixNano())
+
+	err = l.CreateSnapshot(ctx, cid, snapshot, false)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		err := l.DeleteSnapshot(ctx, cid, snapshot)
+		if err != nil {
+			log.WithError(err).WithField("containerid", cid).WithField("snapshot", snapshot).
+				Warn("unable to delete snapshot of commit")
+		}
+	}()
+
+	fingerprint, err := l.opwait.CreateImage(ctx, lxdApi.ImagesPost{
+		ImagePut:             lxdApi.ImagePut{Properties: opts.Properties},
+		CompressionAlgorithm: opts.Compression,
+		Source: &lxdApi.ImagesPostSource{
+			Type: "snapshot",
+			Name: cid + "/" + snapshot,
+		},
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to commit container %v, %w", cid, err)
+	}
+
+	return fingerprint, l.ensureImageAlias(imageID.Tag(), fingerprint)
+}
@@ -0,0 +1,69 @@
+package lxf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_CommitContainer(t *testing.T) {
+	t.Parallel()
+
+	client, fake, _ := testImportClient()
+	fake.CreateContainerSnapshotReturns(&lxdfakes.FakeOperation{}, nil)
+	fake.DeleteContainerSnapshotReturns(&lxdfakes.FakeOperation{}, nil)
+
+	hash, err := client.CommitContainer(ctx, "foo", "golden:v1", CommitOptions{
+		Properties:  map[string]string{"description": "golden"},
+		Compression: "zstd",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("ab", 32), hash)
+
+	cid, snap := fake.CreateContainerSnapshotArgsForCall(0)
+	assert.Equal(t, "foo", cid)
+	assert.True(t, strings.HasPrefix(snap.Name, commitSnapshotPrefix))
+
+	req, args := fake.CreateImageArgsForCall(0)
+	assert.Nil(t, args)
+	assert.Equal(t, "snapshot", req.Source.Type)
+	assert.Equal(t, "foo/"+snap.Name, req.Source.Name)
+	assert.Equal(t, "zstd", req.CompressionAlgorithm)
+	assert.Equal(t, "golden", req.Properties["description"])
+
+	// the snapshot is only temporary
+	cid, name := fake.DeleteContainerSnapshotArgsForCall(0)
+	assert.Equal(t, "foo", cid)
+	assert.Equal(t, snap.Name, name)
+
+	assert.Equal(t, 1, fake.CreateImageAliasCallCount())
+	assert.Equal(t, strings.Repeat("ab", 32), fake.CreateImageAliasArgsForCall(0).Target)
+}
+
+func TestClient_CommitContainer_Failed(t *testing.T) {
+	t.Parallel()
+
+	client, fake, fakeOp := testImportClient()
+	fake.CreateContainerSnapshotReturns(&lxdfakes.FakeOperation{}, nil)
+	fake.DeleteContainerSnapshotReturns(&lxdfakes.FakeOperation{}, nil)
+	fakeOp.WaitReturns(errors.New("The instance is running"))
+
+	_, err := client.CommitContainer(ctx, "foo", "golden", CommitOptions{})
+	assert.Error(t, err)
+
+	assert.Equal(t, 1, fake.DeleteContainerSnapshotCallCount())
+	assert.Equal(t, 0, fake.CreateImageAliasCallCount())
+}
+
+func TestClient_CommitContainer_Digest(t *testing.T) {
+	t.Parallel()
+
+	client, fake, _ := testImportClient()
+
+	_, err := client.CommitContainer(ctx, "foo", "sha256:"+strings.Repeat("ab", 32), CommitOptions{})
+	assert.True(t, errors.Is(err, ErrCommitDigest))
+	assert.Equal(t, 0, fake.CreateContainerSnapshotCallCount())
+}
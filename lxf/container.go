@@ -1,7 +1,8 @@
 package lxf // import "github.com/automaticserver/lxe/lxf"
 
 import (
-	"crypto/md5" // nolint: gosec
+	"context"
+	"encoding/json"
 	"fmt"
 	"math"
 	"strconv"
@@ -11,7 +12,6 @@ import (
 	"github.com/automaticserver/lxe/shared"
 	"github.com/lxc/lxd/shared/api"
 	opencontainers "github.com/opencontainers/runtime-spec/specs-go"
-	"k8s.io/apimachinery/pkg/util/uuid"
 )
 
 const (
@@ -20,6 +20,7 @@ const (
 	cfgVolatileBaseImage    = cfgVolatile + ".base_image"
 	cfgStartedAt            = "user.started_at"
 	cfgFinishedAt           = "user.finished_at"
+	cfgConsoleLoggedAt      = "user.console_logged_at"
 	cfgCloudInitUserData    = "user.user-data"
 	cfgCloudInitMetaData    = "user.meta-data"
 	cfgEnvironmentPrefix    = "environment"
@@ -28,11 +29,46 @@ const (
 	cfgResourcesCPUShares   = cfgResourcesCPUPrefix + ".shares"
 	cfgResourcesCPUQuota    = cfgResourcesCPUPrefix + ".quota"
 	cfgResourcesCPUPeriod   = cfgResourcesCPUPrefix + ".period"
+	cfgResourcesCPUCpus     = cfgResourcesCPUPrefix + ".cpus"
+	cfgResourcesCPUMems     = cfgResourcesCPUPrefix + ".mems"
 	cfgResourcesMemoryLimit = cfgResourcesPrefix + ".memory.limit"
+	cfgResourcesOOMScoreAdj = cfgResourcesPrefix + ".oom_score_adj"
+	cfgLimitCPU             = "limits.cpu"
 	cfgLimitCPUAllowance    = "limits.cpu.allowance"
 	cfgLimitMemory          = "limits.memory"
+	cfgEvictionPrefix       = "user.eviction"
+	cfgEvictionReason       = cfgEvictionPrefix + ".reason"
+	cfgEvictionMessage      = cfgEvictionPrefix + ".message"
+	cfgInitPrefix           = "user.init"
+	cfgInitCommand          = cfgInitPrefix + ".command"
+	cfgInitWorkingDir       = cfgInitPrefix + ".working_dir"
+	cfgInitUID              = cfgInitPrefix + ".uid"
+	cfgInitGID              = cfgInitPrefix + ".gid"
+	cfgInitApplication      = cfgInitPrefix + ".application"
+	cfgCapabilitiesPrefix   = "user.capabilities"
+	cfgCapabilitiesAdd      = cfgCapabilitiesPrefix + ".add"
+	cfgCapabilitiesDrop     = cfgCapabilitiesPrefix + ".drop"
+	cfgExitCode             = "user.exit_code"
+	cfgTerminationMessage   = "user.termination_message"
+	cfgConfigHash           = "user.config_hash"
+	cfgRestartCount         = "user.restart_count"
+	cfgHostAliases          = "user.host_aliases"
+	cfgNamespacesPrefix     = "user.namespaces"
+	cfgAuditPrefix          = "user.audit"
 )
 
+// Values of Container.Namespaces besides the ID of another container
+const (
+	// NamespacePod shares the namespace with the other containers of the pod
+	NamespacePod = "pod"
+	// NamespaceNode uses the namespace of the host
+	NamespaceNode = "node"
+)
+
+// ExitCodeFile is where the init shim of an application container records the exit code of its process. /tmp is
+// writable even if the process runs as another user than root
+const ExitCodeFile = "/tmp/.lxe-exit-code"
+
 var (
 	containerConfigStore = NewConfigStore().WithReserved(
 		append([]string{
@@ -40,16 +76,29 @@ var (
 			cfgSecurityPrivileged,
 			cfgStartedAt,
 			cfgFinishedAt,
+			cfgConsoleLoggedAt,
 			cfgCloudInitUserData,
 			cfgCloudInitMetaData,
 			cfgCloudInitNetworkConfig,
+			cfgCloudInitVendorData,
 			cfgVolatileBaseImage,
+			cfgAdopt,
+			cfgExitCode,
+			cfgTerminationMessage,
+			cfgConfigHash,
+			cfgRestartCount,
+			cfgHostAliases,
 		}, reservedConfigCRI...,
 		)...,
 	).WithReservedPrefixes(
 		append([]string{
 			cfgEnvironmentPrefix,
+			cfgNamespacesPrefix,
+			cfgAuditPrefix,
 			cfgResourcesPrefix,
+			cfgEvictionPrefix,
+			cfgInitPrefix,
+			cfgCapabilitiesPrefix,
 		}, reservedConfigPrefixesCRI...,
 		)...,
 	)
@@ -79,14 +128,51 @@ type Container struct {
 	FinishedAt time.Time
 	// StateName of the current container
 	StateName ContainerStateName
-	// LogPath TODO, to be implemented?
+	// LogPath is where the CRI log of the container is written, relative to the log directory of the sandbox
 	LogPath string
+	// ConsoleLoggedAt is the start time of the last run whose console log was written to the CRI log, so it's written
+	// only once
+	ConsoleLoggedAt time.Time
 	// CloudInit fields
 	CloudInitUserData      string
 	CloudInitMetaData      string
 	CloudInitNetworkConfig string
+	CloudInitVendorData    string
 	// Resources contain cgroup information for handling resource constraints for the container
 	Resources *opencontainers.LinuxResources
+	// OOMScoreAdj of the container's processes, which kubelet derives from the QoS class
+	OOMScoreAdj int64
+	// Init replaces the init process of the image if set
+	Init *ContainerInit
+	// Capabilities added to and dropped from the ones LXD gives the container, nil keeps them
+	Capabilities *ContainerCapabilities
+	// ExitCode of the last run, recorded when it stopped: the exit code of the process of an application container,
+	// otherwise 0 if it shut down and 137 if it was killed
+	ExitCode int32
+	// RestartCount is how often LXD started the container again by itself, e.g. after a reboot from inside
+	RestartCount int
+	// HostAliases are the entries of the pod's host aliases in the hosts file, like "10.0.0.1\tfoo.local"
+	HostAliases []string
+	// Namespaces the container shares, by namespace (pid, ipc). The value is NamespacePod, NamespaceNode or the ID of the
+	// container to share it with. Unset namespaces are private to the container
+	Namespaces map[string]string
+	// Target is the LXD cluster member to create the container on, only used on creation. If empty LXD chooses one
+	Target string
+	// Location is the LXD cluster member the container is located on, empty if LXD is not clustered
+	// +readonly
+	Location string
+	// EvictionReason is set if LXE froze or stopped the container, it is cleared when the container is started again
+	EvictionReason string
+	// EvictionMessage describes why the container was evicted
+	EvictionMessage string
+	// TerminationMessage is what the container reported about its last exit, it is cleared when the container is started
+	// again
+	TerminationMessage string
+	// ConfigHash identifies the config the container was requested with, so a retried creation can tell if the
+	// container left by a previous one is the same
+	ConfigHash string
+	// Audit records the last lifecycle action of each kind which was requested from LXE, by action
+	Audit map[string]string
 
 	// sandbox is the parent sandbox of this container
 	sandbox *Sandbox
@@ -94,6 +180,30 @@ type Container struct {
 	state *ContainerState
 }
 
+// ContainerInit is the process started instead of the image's init
+type ContainerInit struct {
+	// Command and its arguments
+	Command []string
+	// WorkingDir of the command, empty keeps the root directory
+	WorkingDir string
+	// UID and GID to run the command as, nil runs it as root
+	UID *int64
+	GID *int64
+	// Application is true if the command runs under a shim, which forwards the termination signal and records the exit
+	// code in ExitCodeFile, instead of being the init process itself
+	Application bool
+}
+
+// ContainerCapabilities are the capabilities of a container which differ from LXD's defaults, by their names without
+// the CAP_ prefix like NET_ADMIN. CapabilityAll in Drop drops all capabilities but the added ones
+type ContainerCapabilities struct {
+	Add  []string
+	Drop []string
+}
+
+// CapabilityAll stands for all capabilities
+const CapabilityAll = "ALL"
+
 // ContainerState holds information about the container state
 type ContainerState struct {
 	// Pid of the container
@@ -130,6 +240,8 @@ type ContainerStats struct {
 	MemoryUsage     uint64
 	CPUUsage        uint64
 	FilesystemUsage uint64
+	// Processes is the number of processes running in the container
+	Processes uint64
 }
 
 // ContainerMetadata has the metadata neede by a container
@@ -200,6 +312,7 @@ func (c *Container) getState() (*ContainerState, error) {
 		CPUUsage:        uint64(state.CPU.Usage),
 		MemoryUsage:     uint64(state.Memory.Usage),
 		FilesystemUsage: uint64(state.Disk[lxdInitDefaultDiskName].Usage),
+		Processes:       uint64(state.Processes),
 	}
 
 	return cs, nil
@@ -219,13 +332,13 @@ func (c *Container) refresh() error {
 }
 
 // Apply will save the changes of a container if validation was successful, refreshes ETag after save
-func (c *Container) Apply() error {
+func (c *Container) Apply(ctx context.Context) error {
 	err := c.validate()
 	if err != nil {
 		return err
 	}
 
-	err = c.apply()
+	err = c.apply(ctx)
 	if err != nil {
 		return err
 	}
@@ -233,9 +346,17 @@ func (c *Container) Apply() error {
 	return c.refresh()
 }
 
-// Start the container
-func (c *Container) Start() error {
-	err := c.client.opwait.StartContainer(c.ID)
+// Start the container. The DNS config of the sandbox is written to its resolv.conf before, so nothing inside can
+// overwrite it first
+func (c *Container) Start(ctx context.Context) error {
+	err := c.writeResolvConf()
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", ResolvConfPath, err)
+	}
+
+	err = c.client.opwait.StartContainer(ctx, c.ID)
+	c.client.cache.changedContainer(c.ID)
+
 	if err != nil {
 		if shared.IsErrNotFound(err) {
 			return fmt.Errorf("container %w: %s", shared.NewErrNotFound(), c.ID)
@@ -252,15 +373,20 @@ func (c *Container) Start() error {
 
 	// delete created mark if exists, so next stopping state can be exited
 	delete(c.Config, cfgState)
-	c.StartedAt = time.Now()
+	c.StartedAt = notBefore(time.Now(), c.CreatedAt)
+	c.EvictionReason = ""
+	c.EvictionMessage = ""
+	c.TerminationMessage = ""
 
-	return c.Apply()
+	return c.Apply(ctx)
 }
 
-// Stop will try to stop the container, returns nil when container is already stopped or
-// got stopped in the meantime, otherwise it will return an error.
-func (c *Container) Stop(timeout int) error {
-	err := c.client.opwait.StopContainer(c.ID, timeout, 1)
+// Stop will try to stop the container within timeout seconds and kills it afterwards, or right away if timeout is 0.
+// Returns nil when container is already stopped or got stopped in the meantime, otherwise it will return an error.
+func (c *Container) Stop(ctx context.Context, timeout int) error {
+	err := c.client.opwait.StopContainer(ctx, c.ID, timeout)
+	c.client.cache.changedContainer(c.ID)
+
 	if err != nil {
 		if shared.IsErrNotFound(err) {
 			return nil
@@ -269,21 +395,132 @@ func (c *Container) Stop(timeout int) error {
 		return err
 	}
 
+	// when changing state of container, need to refresh ETag. The stopped event might have recorded the exit already
+	r, err := c.client.GetContainer(c.ID)
+	if err != nil {
+		return err
+	}
+
+	c.ETag = r.ETag
+	c.FinishedAt = r.exitTime(time.Now())
+
+	return c.Apply(ctx)
+}
+
+// exitTime returns when the container exited. If the exit of the current run is already recorded, that time is kept,
+// otherwise it's the provided time, but not before the container started
+func (c *Container) exitTime(at time.Time) time.Time {
+	if !c.FinishedAt.IsZero() && !c.FinishedAt.Before(c.StartedAt) {
+		return c.FinishedAt
+	}
+
+	return notBefore(at, c.StartedAt)
+}
+
+// recordExit records the exit time and code of the current run, returns true if they changed
+func (c *Container) recordExit(at time.Time, code int32) bool {
+	changed := false
+
+	if exited := c.exitTime(at); !exited.Equal(c.FinishedAt) {
+		c.FinishedAt = exited
+		changed = true
+	}
+
+	if code != c.ExitCode {
+		c.ExitCode = code
+		changed = true
+	}
+
+	return changed
+}
+
+// recordRestart records a start at that time as restart, if the container was already started before. LXE sets the
+// start time after LXD started the container, so a start of LXE is never after it. Returns true if it's a restart
+func (c *Container) recordRestart(at time.Time) bool {
+	if c.StartedAt.IsZero() || !at.After(c.StartedAt) {
+		return false
+	}
+
+	c.RestartCount++
+	c.StartedAt = at
+
+	return true
+}
+
+// Freeze will freeze all processes of the container. The processes keep their memory but don't consume cpu anymore
+func (c *Container) Freeze(ctx context.Context) error {
+	err := c.client.opwait.FreezeContainer(ctx, c.ID)
+	c.client.cache.changedContainer(c.ID)
+
+	if err != nil {
+		if shared.IsErrNotFound(err) {
+			return fmt.Errorf("container %w: %s", shared.NewErrNotFound(), c.ID)
+		}
+
+		return err
+	}
+
+	// when changing state of container, need to refresh ETag
+	err = c.refresh()
+	if err != nil {
+		return err
+	}
+
+	return c.Apply(ctx)
+}
+
+// Unfreeze resumes all processes of the frozen container and clears the reason it was evicted for
+func (c *Container) Unfreeze(ctx context.Context) error {
+	err := c.client.opwait.UnfreezeContainer(ctx, c.ID)
+	c.client.cache.changedContainer(c.ID)
+
+	if err != nil {
+		if shared.IsErrNotFound(err) {
+			return fmt.Errorf("container %w: %s", shared.NewErrNotFound(), c.ID)
+		}
+
+		return err
+	}
+
 	// when changing state of container, need to refresh ETag
 	err = c.refresh()
 	if err != nil {
 		return err
 	}
 
-	c.FinishedAt = time.Now()
+	c.EvictionReason = ""
+	c.EvictionMessage = ""
+
+	return c.Apply(ctx)
+}
+
+// Migrate moves the container to the LXD cluster member target. A running container is migrated live and keeps
+// running, which requires CRIU on both members
+func (c *Container) Migrate(ctx context.Context, target string) error {
+	err := c.client.opwait.MigrateContainer(ctx, c.ID, target, c.StateName == ContainerStateRunning)
+	c.client.cache.changedContainer(c.ID)
+
+	if err != nil {
+		if shared.IsErrNotFound(err) {
+			return fmt.Errorf("container %w: %s", shared.NewErrNotFound(), c.ID)
+		}
+
+		return err
+	}
+
+	c.Location = target
+	c.state = nil
 
-	return c.Apply()
+	// when changing location of container, need to refresh ETag
+	return c.refresh()
 }
 
 // Delete the container, returns nil when container is already deleted or
 // got deleted in the meantime, otherwise it will return an error.
-func (c *Container) Delete() error {
-	err := c.client.opwait.DeleteContainer(c.ID)
+func (c *Container) Delete(ctx context.Context) error {
+	err := c.client.opwait.DeleteContainer(ctx, c.ID)
+	c.client.cache.changedContainer(c.ID)
+
 	if err != nil {
 		if shared.IsErrNotFound(err) {
 			return nil
@@ -314,7 +551,7 @@ func (c *Container) validate() error {
 
 // apply saves the changes to LXD
 // Will not obtain the new ETag!
-func (c *Container) apply() error {
+func (c *Container) apply(ctx context.Context) error {
 	// TODO: can't this be done easier?
 	imageID, err := c.client.parseImage(c.Image)
 	if err != nil {
@@ -356,23 +593,30 @@ func (c *Container) apply() error {
 
 	if c.ID == "" {
 		// container has to be created
-		c.ID = c.CreateID()
+		c.ID, err = freeName(c.name, c.client.containerExists)
+		if err != nil {
+			return err
+		}
+
+		defer c.client.cache.changedContainer(c.ID)
 
-		return c.client.opwait.CreateContainer(api.ContainersPost{
+		return c.client.opwait.CreateContainer(ctx, api.ContainersPost{
 			Name:         c.ID,
 			ContainerPut: contPut,
 			Source: api.ContainerSource{
 				Fingerprint: hash,
 				Type:        "image",
 			},
-		})
+		}, c.Target)
 	}
 	// else container has to be updated
 	if c.ETag == "" {
 		return fmt.Errorf("update container not allowed: %w", ErrMissingETag)
 	}
 
-	err = c.client.opwait.UpdateContainer(c.ID, contPut, c.ETag)
+	err = c.client.opwait.UpdateContainer(ctx, c.ID, contPut, c.ETag)
+	c.client.cache.changedContainer(c.ID)
+
 	if err != nil {
 		if shared.IsErrNotFound(err) {
 			return fmt.Errorf("container %w: %s", shared.NewErrNotFound(), c.ID)
@@ -384,10 +628,16 @@ func (c *Container) apply() error {
 	return nil
 }
 
-// CreateID creates a unique container id
+// CreateID returns the container id, derived from its sandbox, name and attempt. A retried creation of the same
+// container gets the same id, so the container left by an interrupted one is found
 func (c *Container) CreateID() string {
-	bin := md5.Sum([]byte(uuid.NewUUID())) // nolint: gosec
-	return string(c.Metadata.Name[0]) + b32lowerEncoder.EncodeToString(bin[:])[:15]
+	return c.name(0)
+}
+
+// name returns the name of the container, another one for each salt greater than 0
+func (c *Container) name(salt int) string {
+	return makeName(c.namePrefix, fmt.Sprintf("%s/%s/%d", c.SandboxID(), c.Metadata.Name, c.Metadata.Attempt), salt,
+		c.Metadata.Name)
 }
 
 // GetInetAddress returns the IPv4 address of the first matching interface in the parameter list
@@ -429,9 +679,10 @@ func makeContainerConfig(c *Container) map[string]string { // nolint: gocognit
 		config[cfgAnnotations+"."+key] = val
 	}
 
-	config[cfgCreatedAt] = strconv.FormatInt(c.CreatedAt.UnixNano(), 10)
-	config[cfgStartedAt] = strconv.FormatInt(c.StartedAt.UnixNano(), 10)
-	config[cfgFinishedAt] = strconv.FormatInt(c.FinishedAt.UnixNano(), 10)
+	config[cfgCreatedAt] = strconv.FormatInt(UnixNano(c.CreatedAt), 10)
+	config[cfgStartedAt] = strconv.FormatInt(UnixNano(c.StartedAt), 10)
+	config[cfgFinishedAt] = strconv.FormatInt(UnixNano(c.FinishedAt), 10)
+	config[cfgConsoleLoggedAt] = strconv.FormatInt(UnixNano(c.ConsoleLoggedAt), 10)
 	config[cfgSecurityPrivileged] = strconv.FormatBool(c.Privileged)
 	config[cfgLogPath] = c.LogPath
 	config[cfgIsCRI] = strconv.FormatBool(true)
@@ -439,6 +690,23 @@ func makeContainerConfig(c *Container) map[string]string { // nolint: gocognit
 	config[cfgMetaAttempt] = strconv.FormatUint(uint64(c.Metadata.Attempt), 10)
 	config[cfgVolatileBaseImage] = c.Image
 
+	if c.Owner != "" {
+		config[cfgOwner] = c.Owner
+	}
+
+	if c.EvictionReason != "" {
+		config[cfgEvictionReason] = c.EvictionReason
+		config[cfgEvictionMessage] = c.EvictionMessage
+	}
+
+	if c.ConfigHash != "" {
+		config[cfgConfigHash] = c.ConfigHash
+	}
+
+	if c.TerminationMessage != "" {
+		config[cfgTerminationMessage] = c.TerminationMessage
+	}
+
 	for k, v := range c.Environment {
 		config[cfgEnvironmentPrefix+"."+k] = v
 	}
@@ -457,6 +725,58 @@ func makeContainerConfig(c *Container) map[string]string { // nolint: gocognit
 		config[cfgCloudInitNetworkConfig] = c.CloudInitNetworkConfig
 	}
 
+	if c.CloudInitVendorData != "" {
+		config[cfgCloudInitVendorData] = c.CloudInitVendorData
+	}
+
+	if c.OOMScoreAdj != 0 {
+		config[cfgResourcesOOMScoreAdj] = strconv.FormatInt(c.OOMScoreAdj, 10)
+	}
+
+	if c.Init != nil {
+		// a list of strings always marshals
+		command, _ := json.Marshal(c.Init.Command)
+		config[cfgInitCommand] = string(command)
+		SetIfSet(&config, cfgInitWorkingDir, c.Init.WorkingDir)
+
+		if c.Init.UID != nil {
+			config[cfgInitUID] = strconv.FormatInt(*c.Init.UID, 10)
+		}
+
+		if c.Init.GID != nil {
+			config[cfgInitGID] = strconv.FormatInt(*c.Init.GID, 10)
+		}
+
+		if c.Init.Application {
+			config[cfgInitApplication] = strconv.FormatBool(true)
+		}
+	}
+
+	if c.Capabilities != nil {
+		SetIfSet(&config, cfgCapabilitiesAdd, strings.Join(c.Capabilities.Add, " "))
+		SetIfSet(&config, cfgCapabilitiesDrop, strings.Join(c.Capabilities.Drop, " "))
+	}
+
+	if c.ExitCode != 0 {
+		config[cfgExitCode] = strconv.FormatInt(int64(c.ExitCode), 10)
+	}
+
+	if c.RestartCount != 0 {
+		config[cfgRestartCount] = strconv.Itoa(c.RestartCount)
+	}
+
+	if len(c.HostAliases) > 0 {
+		config[cfgHostAliases] = strings.Join(c.HostAliases, "\n")
+	}
+
+	for ns, with := range c.Namespaces {
+		config[cfgNamespacesPrefix+"."+ns] = with
+	}
+
+	for action, record := range c.Audit {
+		config[cfgAuditPrefix+"."+action] = record
+	}
+
 	if c.Resources != nil { // nolint: nestif
 		if c.Resources.CPU != nil {
 			if c.Resources.CPU.Shares != nil {
@@ -478,10 +798,20 @@ func makeContainerConfig(c *Container) map[string]string { // nolint: gocognit
 					int(math.Ceil(float64(*c.Resources.CPU.Period)/1000)),
 				)
 			}
+
+			if c.Resources.CPU.Cpus != "" {
+				config[cfgResourcesCPUCpus] = c.Resources.CPU.Cpus
+				config[cfgLimitCPU] = CPUSet(c.Resources.CPU.Cpus)
+			}
+
+			if c.Resources.CPU.Mems != "" {
+				config[cfgResourcesCPUMems] = c.Resources.CPU.Mems
+			}
 		}
 
 		if c.Resources.Memory != nil {
 			if c.Resources.Memory.Limit != nil && *c.Resources.Memory.Limit > 0 {
+				config[cfgResourcesMemoryLimit] = strconv.FormatInt(*c.Resources.Memory.Limit, 10)
 				config[cfgLimitMemory] = strconv.FormatInt(*c.Resources.Memory.Limit, 10)
 			}
 		}
@@ -490,6 +820,16 @@ func makeContainerConfig(c *Container) map[string]string { // nolint: gocognit
 	return config
 }
 
+// CPUSet returns the cpuset in the form of limits.cpu which pins the container to these cpus. A single number would
+// limit the count of cpus instead, so it's written as range
+func CPUSet(cpus string) string {
+	if !strings.ContainsAny(cpus, ",-") {
+		return cpus + "-" + cpus
+	}
+
+	return cpus
+}
+
 // extractEnvVars extracts all the config options that start with "environment."
 // and returns the environment variables + values
 func extractEnvVars(config map[string]string) map[string]string {
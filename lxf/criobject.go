@@ -17,6 +17,7 @@ const (
 	cfgMetaName      = cfgMetadata + ".name"
 	cfgMetaNamespace = cfgMetadata + ".namespace"
 	cfgMetaUID       = cfgMetadata + ".uid"
+	cfgOwner         = "user.owner"
 	cfgVolatile      = "volatile"
 )
 
@@ -25,6 +26,7 @@ var (
 		cfgSchema,
 		cfgIsCRI,
 		cfgCreatedAt,
+		cfgOwner,
 	}
 	reservedConfigPrefixesCRI = []string{
 		cfgLabels,
@@ -40,6 +42,10 @@ type CRIObject struct {
 	Annotations map[string]string
 	// CreatedAt is when the resource was created
 	CreatedAt time.Time
+	// Owner is the LXE instance which created the resource, empty if it was created without owner
+	Owner string
+	// namePrefix starts the name the resource is created with
+	namePrefix string
 }
 
 // IsCRI checks if a object is a cri object
@@ -77,3 +83,8 @@ func IsCRI(i interface{}) bool {
 
 	return is
 }
+
+// owns checks if the LXE instance owns the object with the config. An instance without owner owns all objects
+func (l *client) owns(config map[string]string) bool {
+	return l.owner == "" || config[cfgOwner] == l.owner
+}
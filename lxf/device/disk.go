@@ -18,6 +18,12 @@ type Disk struct {
 	Size     string
 	Readonly bool
 	Optional bool
+	// Propagation of the bind mount, one of the mount propagation modes e.g. private, rslave, rshared
+	Propagation string
+	// Recursive bind mounts all submounts of the source as well
+	Recursive bool
+	// Shift the uid and gid of the source to the container idmap using shiftfs or idmapped mounts
+	Shift bool
 }
 
 func (d *Disk) getName() string {
@@ -35,9 +41,11 @@ func (d *Disk) getName() string {
 	return name
 }
 
-// ToMap returns assigned name or if unset the type specific unique name and serializes the options into a lxd device map
+// ToMap returns assigned name or if unset the type specific unique name and serializes the options into a lxd device map.
+// Propagation, recursive and shift are only set if used, as LXD rejects them on root disks and custom volumes even if
+// they're empty or false
 func (d *Disk) ToMap() (string, map[string]string) {
-	return d.getName(), map[string]string{
+	m := map[string]string{
 		"type":     DiskType,
 		"path":     d.Path,
 		"source":   d.Source,
@@ -46,6 +54,20 @@ func (d *Disk) ToMap() (string, map[string]string) {
 		"readonly": strconv.FormatBool(d.Readonly),
 		"optional": strconv.FormatBool(d.Optional),
 	}
+
+	if d.Propagation != "" {
+		m["propagation"] = d.Propagation
+	}
+
+	if d.Recursive {
+		m["recursive"] = "true"
+	}
+
+	if d.Shift {
+		m["shift"] = "true"
+	}
+
+	return d.getName(), m
 }
 
 // FromMap loads assigned name (can be empty) and options
@@ -57,6 +79,9 @@ func (d *Disk) FromMap(name string, options map[string]string) error {
 	d.Size = options["size"]
 	d.Readonly = options["readonly"] == "true"
 	d.Optional = options["optional"] == "true"
+	d.Propagation = options["propagation"]
+	d.Recursive = options["recursive"] == "true"
+	d.Shift = options["shift"] == "true"
 
 	return nil
 }
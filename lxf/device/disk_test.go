@@ -44,18 +44,27 @@ func TestDisk_getName_KeyNamePriority(t *testing.T) {
 func TestDisk_ToMap(t *testing.T) {
 	t.Parallel()
 
-	d := &Disk{KeyName: "foo", Path: "bar", Source: "baz", Pool: "pool", Size: "size", Readonly: true, Optional: true}
-	exp := map[string]string{"type": DiskType, "path": "bar", "source": "baz", "pool": "pool", "size": "size", "readonly": "true", "optional": "true"}
+	d := &Disk{KeyName: "foo", Path: "bar", Source: "baz", Pool: "pool", Size: "size", Readonly: true, Optional: true, Propagation: "rslave", Recursive: true, Shift: true}
+	exp := map[string]string{"type": DiskType, "path": "bar", "source": "baz", "pool": "pool", "size": "size", "readonly": "true", "optional": "true", "propagation": "rslave", "recursive": "true", "shift": "true"}
 	n, m := d.ToMap()
 	assert.Equal(t, "foo", n)
 	assert.Equal(t, exp, m)
 }
 
+func TestDisk_ToMap_Unset(t *testing.T) {
+	t.Parallel()
+
+	d := &Disk{KeyName: "root", Path: "/", Pool: "default"}
+	exp := map[string]string{"type": DiskType, "path": "/", "source": "", "pool": "default", "size": "", "readonly": "false", "optional": "false"}
+	_, m := d.ToMap()
+	assert.Equal(t, exp, m)
+}
+
 func TestDisk_FromMap(t *testing.T) {
 	t.Parallel()
 
-	raw := map[string]string{"type": DiskType, "path": "bar", "source": "baz", "pool": "pool", "size": "size", "readonly": "true", "optional": "true"}
-	exp := &Disk{KeyName: "foo", Path: "bar", Source: "baz", Pool: "pool", Size: "size", Readonly: true, Optional: true}
+	raw := map[string]string{"type": DiskType, "path": "bar", "source": "baz", "pool": "pool", "size": "size", "readonly": "true", "optional": "true", "propagation": "rslave", "recursive": "true", "shift": "true"}
+	exp := &Disk{KeyName: "foo", Path: "bar", Source: "baz", Pool: "pool", Size: "size", Readonly: true, Optional: true, Propagation: "rslave", Recursive: true, Shift: true}
 	d := &Disk{}
 	err := d.FromMap("foo", raw)
 	assert.NoError(t, err)
@@ -15,6 +15,9 @@ type Nic struct {
 	NicType     string
 	Parent      string
 	IPv4Address string
+	Vlan        string
+	MTU         string
+	HWAddr      string
 }
 
 func (d *Nic) getName() string {
@@ -38,6 +41,9 @@ func (d *Nic) ToMap() (string, map[string]string) {
 		"nictype":      d.NicType,
 		"parent":       d.Parent,
 		"ipv4.address": d.IPv4Address,
+		"vlan":         d.Vlan,
+		"mtu":          d.MTU,
+		"hwaddr":       d.HWAddr,
 	}
 }
 
@@ -48,6 +54,9 @@ func (d *Nic) FromMap(name string, options map[string]string) error {
 	d.NicType = options["nictype"]
 	d.Parent = options["parent"]
 	d.IPv4Address = options["ipv4.address"]
+	d.Vlan = options["vlan"]
+	d.MTU = options["mtu"]
+	d.HWAddr = options["hwaddr"]
 
 	return nil
 }
@@ -30,8 +30,8 @@ func TestNic_getName_KeyNamePriority(t *testing.T) {
 func TestNic_ToMap(t *testing.T) {
 	t.Parallel()
 
-	d := &Nic{KeyName: "foo", Name: "ethX", NicType: "bridge", Parent: "brX", IPv4Address: "1.2.3.4"}
-	exp := map[string]string{"type": NicType, "name": "ethX", "nictype": "bridge", "parent": "brX", "ipv4.address": "1.2.3.4"}
+	d := &Nic{KeyName: "foo", Name: "ethX", NicType: "bridge", Parent: "brX", IPv4Address: "1.2.3.4", Vlan: "10", MTU: "1400", HWAddr: "00:16:3e:00:00:01"}
+	exp := map[string]string{"type": NicType, "name": "ethX", "nictype": "bridge", "parent": "brX", "ipv4.address": "1.2.3.4", "vlan": "10", "mtu": "1400", "hwaddr": "00:16:3e:00:00:01"}
 	n, m := d.ToMap()
 	assert.Equal(t, "foo", n)
 	assert.Equal(t, exp, m)
@@ -40,8 +40,8 @@ func TestNic_ToMap(t *testing.T) {
 func TestNic_FromMap(t *testing.T) {
 	t.Parallel()
 
-	raw := map[string]string{"type": NicType, "name": "ethX", "nictype": "bridge", "parent": "brX", "ipv4.address": "1.2.3.4"}
-	exp := &Nic{KeyName: "foo", Name: "ethX", NicType: "bridge", Parent: "brX", IPv4Address: "1.2.3.4"}
+	raw := map[string]string{"type": NicType, "name": "ethX", "nictype": "bridge", "parent": "brX", "ipv4.address": "1.2.3.4", "vlan": "10", "mtu": "1400", "hwaddr": "00:16:3e:00:00:01"}
+	exp := &Nic{KeyName: "foo", Name: "ethX", NicType: "bridge", Parent: "brX", IPv4Address: "1.2.3.4", Vlan: "10", MTU: "1400", HWAddr: "00:16:3e:00:00:01"}
 	d := &Nic{}
 	err := d.FromMap("foo", raw)
 	assert.NoError(t, err)
@@ -1,6 +1,7 @@
 package lxf // import "github.com/automaticserver/lxe/lxf"
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/automaticserver/lxe/lxf/lxo"
 	"github.com/gorilla/websocket"
 	lxd "github.com/lxc/lxd/client"
 	lxdApi "github.com/lxc/lxd/shared/api"
@@ -32,8 +34,10 @@ var (
 )
 
 // Exec will start a command on the server and attach the provided streams. It will block till the command terminated
-// AND all data was written to stdout/stdin. The caller is responsible to provide a sink which doesn't block.
-func (l *client) Exec(cid string, cmd []string, stdin io.ReadCloser, stdout, stderr io.WriteCloser, interactive, tty bool, timeout int64, resize <-chan remotecommand.TerminalSize) (int32, error) {
+// AND all data was written to stdout/stdin. The caller is responsible to provide a sink which doesn't block. If the
+// context is done before, the command is signalled and the control socket closed, which ends the LXD websockets. A
+// reached deadline returns ErrExecTimeout, a cancellation the error of the context.
+func (l *client) Exec(ctx context.Context, cid string, cmd []string, stdin io.ReadCloser, stdout, stderr io.WriteCloser, interactive, tty bool, resize <-chan remotecommand.TerminalSize) (int32, error) {
 	ses := &session{
 		resize:      resize,
 		closeResize: make(chan struct{}),
@@ -61,28 +65,27 @@ func (l *client) Exec(cid string, cmd []string, stdin io.ReadCloser, stdout, std
 		return CodeExecError, err
 	}
 
-	var deadline <-chan time.Time
-	if timeout > 0 {
-		deadline = time.After(time.Duration(timeout) * time.Second)
-	}
+	// Stop listening on resize channel
+	defer close(ses.closeResize)
 
 	select {
-	// Exit early if timeout is reached
-	case <-deadline:
+	// Exit early if the context is done
+	case <-ctx.Done():
 		err := ses.sendCancel()
 		if err != nil {
 			log.WithError(err).Error("session control failed")
 		}
 
-		return CodeExecTimeout, ErrExecTimeout
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return CodeExecTimeout, ErrExecTimeout
+		}
+
+		return CodeExecTimeout, ctx.Err()
 
 	// Wait for any remaining I/O to be flushed
 	case <-args.DataDone:
 	}
 
-	// Stop listening on resize channel
-	close(ses.closeResize)
-
 	// Wait for the operation to complete so we can get the return code
 	err = op.Wait()
 	if err != nil {
@@ -99,6 +102,12 @@ func (l *client) Exec(cid string, cmd []string, stdin io.ReadCloser, stdout, std
 	return int32(exitCode), nil
 }
 
+// ExecSync runs a command without stdin and returns its captured output and exit code. The command is killed if the
+// timeout is reached or ctx is done, a timeout of zero waits forever
+func (l *client) ExecSync(ctx context.Context, cid string, cmd []string, timeout time.Duration) (*lxo.ExecSyncResult, error) {
+	return l.opwait.ExecSync(ctx, cid, cmd, timeout, lxo.DefaultExecSyncMaxOutput)
+}
+
 type session struct {
 	// Channel to consume where resize updates are sent to
 	resize <-chan remotecommand.TerminalSize
@@ -173,7 +182,7 @@ func (s *session) sendResize(r remotecommand.TerminalSize) error {
 
 // Send cancel signal to LXD with exec control
 func (s *session) sendCancel() error {
-	log.Debugf("timeout reached, force closing of connection")
+	log.Debugf("session cancelled, force closing of connection")
 
 	if s.control == nil {
 		return ErrNoControlSocket
@@ -204,7 +213,7 @@ func (s *session) sendCancel() error {
 		return err
 	}
 
-	closeMsg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "timeout reached")
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "session cancelled")
 	err = s.control.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(1*time.Second))
 
 	return err
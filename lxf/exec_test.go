@@ -1,6 +1,7 @@
 package lxf
 
 import (
+	"context"
 	"strconv"
 	"sync"
 	"testing"
@@ -33,7 +34,7 @@ func TestClient_Exec_BasicOk(t *testing.T) {
 		},
 	})
 
-	exitCode, err := client.Exec("", nil, nil, nil, nil, false, false, 0, nil)
+	exitCode, err := client.Exec(context.Background(), "", nil, nil, nil, nil, false, false, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, CodeExecError, exitCode)
 }
@@ -62,7 +63,10 @@ func TestClient_Exec_Timeout(t *testing.T) {
 		},
 	})
 
-	exitCode, err := client.Exec("", nil, nil, nil, nil, false, false, 1, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	exitCode, err := client.Exec(ctx, "", nil, nil, nil, nil, false, false, nil)
 	assert.Error(t, err)
 	assert.Exactly(t, ErrExecTimeout, err)
 	assert.Equal(t, CodeExecTimeout, exitCode)
@@ -95,7 +99,7 @@ func TestClient_Exec_Resize(t *testing.T) {
 		},
 	})
 
-	exitCode, err := client.Exec("", nil, nil, nil, nil, false, false, 0, fakeSes.resize)
+	exitCode, err := client.Exec(context.Background(), "", nil, nil, nil, nil, false, false, fakeSes.resize)
 	assert.NoError(t, err)
 	assert.Equal(t, CodeExecOk, exitCode)
 
@@ -138,7 +142,7 @@ func TestClient_Exec_Parallel(t *testing.T) {
 
 	for i := 0; i < n; i++ {
 		go func(i int) {
-			exitCode, err := client.Exec("", []string{strconv.Itoa(i)}, nil, nil, nil, false, false, 0, nil)
+			exitCode, err := client.Exec(context.Background(), "", []string{strconv.Itoa(i)}, nil, nil, nil, false, false, nil)
 			assert.NoError(t, err)
 			assert.Equal(t, int32(i), exitCode)
 			wg.Done()
@@ -0,0 +1,201 @@
+package lxf // import "github.com/automaticserver/lxe/lxf"
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/automaticserver/lxe/shared"
+	lxd "github.com/lxc/lxd/client"
+)
+
+// These are the file types of the LXD file api
+const (
+	fileTypeFile      = "file"
+	fileTypeDirectory = "directory"
+	fileTypeSymlink   = "symlink"
+)
+
+// fileModeBits are the permission bits of a file LXD sets
+const fileModeBits = 07777
+
+var ErrInvalidArchive = errors.New("invalid archive")
+
+// ReadFiles writes the file, symlink or directory at the absolute path in the container with everything below it as
+// tarball to w, with ownership and modes. The entry of the path itself is named ".", the others are relative to it
+func (c *Container) ReadFiles(p string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	err := c.readFiles(tw, path.Clean(p), ".")
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+func (c *Container) readFiles(tw *tar.Writer, p, name string) error {
+	content, resp, err := c.client.server.GetContainerFile(c.ID, p)
+	if err != nil {
+		return fmt.Errorf("%v: %w", p, err)
+	}
+
+	if content != nil {
+		defer content.Close()
+	}
+
+	hdr := &tar.Header{
+		Name: name,
+		Uid:  int(resp.UID),
+		Gid:  int(resp.GID),
+		Mode: int64(resp.Mode & fileModeBits),
+	}
+
+	switch resp.Type {
+	case fileTypeDirectory:
+		hdr.Typeflag = tar.TypeDir
+		hdr.Name += "/"
+
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range resp.Entries {
+			err = c.readFiles(tw, path.Join(p, e), path.Join(name, e))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case fileTypeSymlink:
+		// the content of a symlink is its target
+		target, err := ioutil.ReadAll(content)
+		if err != nil {
+			return err
+		}
+
+		hdr.Typeflag = tar.TypeSymlink
+		hdr.Linkname = strings.TrimSpace(string(target))
+
+		return tw.WriteHeader(hdr)
+	default:
+		// the size must be known before the content is written
+		f, size, err := spool(content)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = size
+
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(tw, f)
+
+		return err
+	}
+}
+
+// WriteFiles creates the files, symlinks and directories of the tarball at the absolute path in the container, with
+// ownership and modes. The entry named "." is created at the path itself, the others relative to it. Existing files
+// are overwritten, other entry types are skipped
+func (c *Container) WriteFiles(p string, r io.Reader) error {
+	root := path.Clean(p)
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+
+		target := path.Join(root, hdr.Name)
+		if root != "/" && target != root && !strings.HasPrefix(target, root+"/") {
+			return fmt.Errorf("%w: entry %v is outside of %v", ErrInvalidArchive, hdr.Name, root)
+		}
+
+		err = c.writeFile(target, hdr, tr)
+		if err != nil {
+			return fmt.Errorf("%v: %w", target, err)
+		}
+	}
+}
+
+func (c *Container) writeFile(target string, hdr *tar.Header, r io.Reader) error {
+	args := lxd.ContainerFileArgs{
+		UID:       int64(hdr.Uid),
+		GID:       int64(hdr.Gid),
+		Mode:      int(hdr.Mode & fileModeBits),
+		WriteMode: "overwrite",
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		// an existing directory is kept
+		_, resp, err := c.client.server.GetContainerFile(c.ID, target)
+		if err == nil && resp.Type == fileTypeDirectory {
+			return nil
+		} else if err != nil && !shared.IsErrNotFound(err) {
+			return err
+		}
+
+		args.Type = fileTypeDirectory
+	case tar.TypeSymlink:
+		args.Type = fileTypeSymlink
+		args.Content = strings.NewReader(hdr.Linkname)
+	case tar.TypeReg:
+		f, _, err := spool(r)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		args.Type = fileTypeFile
+		args.Content = f
+	default:
+		log.WithField("containerid", c.ID).WithField("path", target).Debug("skipping unsupported file type")
+		return nil
+	}
+
+	return c.client.server.CreateContainerFile(c.ID, target, args)
+}
+
+// spool copies r into an unlinked temporary file, so it can be sized and seeked without keeping it in memory
+func spool(r io.Reader) (*os.File, int64, error) {
+	f, err := ioutil.TempFile("", "lxe-file-")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// the open file stays readable
+	err = os.Remove(f.Name())
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	size, err := io.Copy(f, r)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	return f, size, nil
+}
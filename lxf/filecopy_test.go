@@ -0,0 +1,135 @@
+package lxf
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/automaticserver/lxe/shared"
+	lxd "github.com/lxc/lxd/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainer_ReadFiles(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	c := client.NewContainer("sandbox")
+	c.ID = "abc"
+
+	files := map[string]*lxd.ContainerFileResponse{
+		"/etc/app":          {Type: "directory", Mode: 0755, Entries: []string{"app.conf", "current"}},
+		"/etc/app/app.conf": {Type: "file", Mode: 0640, UID: 1000, GID: 1000},
+		"/etc/app/current":  {Type: "symlink", Mode: 0777},
+	}
+	contents := map[string]string{"/etc/app/app.conf": "listen 80\n", "/etc/app/current": "app.conf"}
+
+	fake.GetContainerFileStub = func(_ string, p string) (io.ReadCloser, *lxd.ContainerFileResponse, error) {
+		if _, has := contents[p]; !has {
+			return nil, files[p], nil
+		}
+
+		return ioutil.NopCloser(strings.NewReader(contents[p])), files[p], nil
+	}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, c.ReadFiles("/etc/app/", buf))
+
+	tr := tar.NewReader(buf)
+
+	hdr, err := tr.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "./", hdr.Name)
+	assert.Equal(t, byte(tar.TypeDir), hdr.Typeflag)
+
+	hdr, err = tr.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "app.conf", hdr.Name)
+	assert.Equal(t, 1000, hdr.Uid)
+	assert.Equal(t, int64(0640), hdr.Mode)
+
+	b, _ := ioutil.ReadAll(tr)
+	assert.Equal(t, "listen 80\n", string(b))
+
+	hdr, err = tr.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "current", hdr.Name)
+	assert.Equal(t, "app.conf", hdr.Linkname)
+
+	_, err = tr.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func testArchive(t *testing.T, entries ...*tar.Header) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+
+	for _, hdr := range entries {
+		content := ""
+		if hdr.Typeflag == tar.TypeReg {
+			content = "content of " + hdr.Name
+			hdr.Size = int64(len(content))
+		}
+
+		assert.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, tw.Close())
+
+	return buf
+}
+
+func TestContainer_WriteFiles(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	c := client.NewContainer("sandbox")
+	c.ID = "abc"
+
+	fake.GetContainerFileReturns(nil, nil, shared.NewErrNotFound())
+
+	written := map[string]string{}
+	fake.CreateContainerFileStub = func(_ string, p string, args lxd.ContainerFileArgs) error {
+		content := ""
+
+		if args.Content != nil {
+			b, _ := ioutil.ReadAll(args.Content)
+			content = string(b)
+		}
+
+		written[p] = args.Type + ":" + content
+
+		return nil
+	}
+
+	err := c.WriteFiles("/srv/data", testArchive(t,
+		&tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0755},
+		&tar.Header{Name: "./index.html", Typeflag: tar.TypeReg, Mode: 0644},
+		&tar.Header{Name: "./latest", Typeflag: tar.TypeSymlink, Linkname: "index.html"},
+		&tar.Header{Name: "./fifo", Typeflag: tar.TypeFifo},
+	))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"/srv/data":            "directory:",
+		"/srv/data/index.html": "file:content of ./index.html",
+		"/srv/data/latest":     "symlink:index.html",
+	}, written)
+}
+
+func TestContainer_WriteFiles_Outside(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	c := client.NewContainer("sandbox")
+	c.ID = "abc"
+
+	err := c.WriteFiles("/srv/data", testArchive(t, &tar.Header{Name: "../etc/passwd", Typeflag: tar.TypeReg}))
+	assert.True(t, errors.Is(err, ErrInvalidArchive))
+	assert.Equal(t, 0, fake.CreateContainerFileCallCount())
+}
@@ -0,0 +1,134 @@
+package lxf // import "github.com/automaticserver/lxe/lxf"
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/automaticserver/lxe/shared"
+	lxd "github.com/lxc/lxd/client"
+)
+
+const (
+	// ResolvConfPath is where the resolver config is written into the container
+	ResolvConfPath = "/etc/resolv.conf"
+	// HostsPath is where the hosts file of the pod is written into the container
+	HostsPath = "/etc/hosts"
+	// HostAliasesHeader precedes the host aliases in the hosts file, like in the one of kubelet
+	HostAliasesHeader = "# Entries added by HostAliases."
+	// lxdExtensionFileDelete allows to delete files in containers
+	lxdExtensionFileDelete = "file_delete"
+	// writeFileMode is the file mode of files written into containers
+	writeFileMode = 0644
+)
+
+// ResolvConf returns the content of resolv.conf for the DNS config of the sandbox. Returns empty if neither
+// nameservers nor searches are set, then the container keeps the resolver config of its image
+func (n NetworkConfig) ResolvConf() string {
+	servers := nonEmpty(n.Nameservers)
+	searches := nonEmpty(n.Searches)
+	options := nonEmpty(n.Options)
+
+	if len(servers) == 0 && len(searches) == 0 {
+		return ""
+	}
+
+	var b bytes.Buffer
+
+	b.WriteString("# generated by lxe from the pod's dns config\n")
+
+	for _, s := range servers {
+		fmt.Fprintf(&b, "nameserver %s\n", s)
+	}
+
+	if len(searches) > 0 {
+		fmt.Fprintf(&b, "search %s\n", strings.Join(searches, " "))
+	}
+
+	if len(options) > 0 {
+		fmt.Fprintf(&b, "options %s\n", strings.Join(options, " "))
+	}
+
+	return b.String()
+}
+
+// HostsFile returns the content of the pod's hosts file like kubelet generates it, with the pod ip, hostname and host
+// aliases. If the ip isn't known yet, the hostname resolves to 127.0.1.1 like on debian systems
+func HostsFile(ip, hostname string, aliases []string) string {
+	var b bytes.Buffer
+
+	b.WriteString("# Kubernetes-managed hosts file (generated by lxe).\n")
+	b.WriteString("127.0.0.1\tlocalhost\n")
+	b.WriteString("::1\tlocalhost ip6-localhost ip6-loopback\n")
+	b.WriteString("fe00::0\tip6-localnet\n")
+	b.WriteString("fe00::0\tip6-mcastprefix\n")
+	b.WriteString("fe00::1\tip6-allnodes\n")
+	b.WriteString("fe00::2\tip6-allrouters\n")
+
+	if hostname != "" {
+		if ip == "" {
+			ip = "127.0.1.1"
+		}
+
+		fmt.Fprintf(&b, "%s\t%s\n", ip, hostname)
+	}
+
+	if aliases = nonEmpty(aliases); len(aliases) > 0 {
+		b.WriteString("\n" + HostAliasesHeader + "\n")
+
+		for _, a := range aliases {
+			b.WriteString(a + "\n")
+		}
+	}
+
+	return b.String()
+}
+
+// writeResolvConf writes the DNS config of the sandbox to the resolv.conf of the stopped container
+func (c *Container) writeResolvConf() error {
+	sb, err := c.Sandbox()
+	if err != nil {
+		return err
+	}
+
+	content := sb.NetworkConfig.ResolvConf()
+	if content == "" {
+		return nil
+	}
+
+	return c.WriteFile(ResolvConfPath, content)
+}
+
+// WriteFile replaces the file in the container with a regular file of the content. An existing file is deleted first,
+// as files like resolv.conf are often a symlink into /run (e.g. of systemd-resolved), which can't be written while the
+// container is stopped and would be replaced again when it starts
+func (c *Container) WriteFile(path, content string) error {
+	server := c.client.server
+
+	if server.HasExtension(lxdExtensionFileDelete) {
+		err := server.DeleteContainerFile(c.ID, path)
+		if err != nil && !shared.IsErrNotFound(err) {
+			log.WithError(err).WithField("containerid", c.ID).WithField("path", path).Debug("unable to delete file")
+		}
+	}
+
+	return server.CreateContainerFile(c.ID, path, lxd.ContainerFileArgs{
+		Content:   strings.NewReader(content),
+		Mode:      writeFileMode,
+		Type:      "file",
+		WriteMode: "overwrite",
+	})
+}
+
+// nonEmpty returns the values which aren't empty, as persisted lists are split into a single empty value
+func nonEmpty(values []string) []string {
+	r := []string{}
+
+	for _, v := range values {
+		if v != "" {
+			r = append(r, v)
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,104 @@
+package lxf
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkConfig_ResolvConf(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, NetworkConfig{}.ResolvConf())
+	assert.Empty(t, NetworkConfig{Nameservers: []string{""}, Searches: []string{""}, Options: []string{"ndots:5"}}.ResolvConf())
+
+	assert.Equal(t, `# generated by lxe from the pod's dns config
+nameserver 10.96.0.10
+nameserver 10.96.0.11
+search default.svc.cluster.local svc.cluster.local cluster.local
+options ndots:5 timeout:2
+`, NetworkConfig{
+		Nameservers: []string{"10.96.0.10", "10.96.0.11"},
+		Searches:    []string{"default.svc.cluster.local", "svc.cluster.local", "cluster.local"},
+		Options:     []string{"ndots:5", "timeout:2"},
+	}.ResolvConf())
+
+	assert.Equal(t, "# generated by lxe from the pod's dns config\nnameserver 1.1.1.1\n",
+		NetworkConfig{Nameservers: []string{"1.1.1.1"}, Searches: []string{""}, Options: []string{""}}.ResolvConf())
+}
+
+func TestContainer_writeResolvConf(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+
+	p := basicProfile("sb")
+	p.Config[cfgNetworkConfigNameservers] = "10.96.0.10"
+	p.Config[cfgNetworkConfigSearches] = "cluster.local"
+	p.Config[cfgNetworkConfigOptions] = "ndots:5"
+	fake.GetProfileReturns(p, "", nil)
+	fake.HasExtensionReturns(true)
+
+	c := &Container{}
+	c.client = client
+	c.ID = "foo"
+	c.Profiles = []string{"default", "sb"}
+
+	assert.NoError(t, c.writeResolvConf())
+
+	assert.Equal(t, 1, fake.DeleteContainerFileCallCount())
+	name, path := fake.DeleteContainerFileArgsForCall(0)
+	assert.Equal(t, "foo", name)
+	assert.Equal(t, ResolvConfPath, path)
+
+	assert.Equal(t, 1, fake.CreateContainerFileCallCount())
+	name, path, args := fake.CreateContainerFileArgsForCall(0)
+	assert.Equal(t, "foo", name)
+	assert.Equal(t, ResolvConfPath, path)
+
+	content, err := ioutil.ReadAll(args.Content)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "nameserver 10.96.0.10\nsearch cluster.local\noptions ndots:5\n")
+
+	fake.CreateContainerFileReturns(errors.New("failed"))
+	assert.Error(t, c.writeResolvConf())
+}
+
+func TestContainer_writeResolvConf_NoDNSConfig(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+
+	fake.GetProfileReturns(basicProfile("sb"), "", nil)
+
+	c := &Container{}
+	c.client = client
+	c.ID = "foo"
+	c.Profiles = []string{"default", "sb"}
+
+	assert.NoError(t, c.writeResolvConf())
+	assert.Equal(t, 0, fake.CreateContainerFileCallCount())
+}
+
+func TestHostsFile(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, `# Kubernetes-managed hosts file (generated by lxe).
+127.0.0.1	localhost
+::1	localhost ip6-localhost ip6-loopback
+fe00::0	ip6-localnet
+fe00::0	ip6-mcastprefix
+fe00::1	ip6-allnodes
+fe00::2	ip6-allrouters
+10.0.0.5	mypod
+
+# Entries added by HostAliases.
+10.1.2.3	foo.local	bar.local
+`, HostsFile("10.0.0.5", "mypod", []string{"10.1.2.3\tfoo.local\tbar.local"}))
+
+	hosts := HostsFile("", "mypod", nil)
+	assert.Contains(t, hosts, "127.0.1.1\tmypod\n")
+	assert.NotContains(t, hosts, HostAliasesHeader)
+}
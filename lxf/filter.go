@@ -0,0 +1,306 @@
+package lxf // import "github.com/automaticserver/lxe/lxf"
+
+import (
+	"sort"
+	"strings"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// ContainerFilter selects containers, empty fields match all
+type ContainerFilter struct {
+	// ID matches the containers whose id starts with it, so the truncated ids CRI clients show can be used
+	ID string
+	// SandboxID matches the containers of the sandbox
+	SandboxID string
+	// Labels matches the containers having all labels with these values
+	Labels map[string]string
+}
+
+// SandboxFilter selects sandboxes, empty fields match all
+type SandboxFilter struct {
+	// ID matches the sandboxes whose id starts with it
+	ID string
+	// Labels matches the sandboxes having all labels with these values
+	Labels map[string]string
+}
+
+// FilterContainers returns the containers matching the filter. With the state cache enabled, the containers are looked
+// up by its indexes
+func (l *client) FilterContainers(f ContainerFilter) ([]*Container, error) {
+	cts, err := l.cache.findContainers(l.server, f)
+	if err != nil {
+		return nil, err
+	}
+
+	cl := []*Container{}
+
+	for _, ct := range cts {
+		ct := ct // pin!
+		if !IsCRI(ct) || !l.owns(ct.Config) {
+			continue
+		}
+
+		c, err := l.toContainer(&ct, "")
+		if err != nil {
+			return nil, err
+		}
+
+		cl = append(cl, c)
+	}
+
+	return cl, nil
+}
+
+// FilterSandboxes returns the sandboxes matching the filter. With the state cache enabled, the sandboxes are looked up
+// by its indexes
+func (l *client) FilterSandboxes(f SandboxFilter) ([]*Sandbox, error) {
+	ps, err := l.cache.findProfiles(l.server, f)
+	if err != nil {
+		return nil, err
+	}
+
+	sl := []*Sandbox{}
+
+	for _, p := range ps {
+		p := p // pin!
+		if !IsCRI(p) || !l.owns(p.Config) {
+			continue
+		}
+
+		s, err := l.toSandbox(&p, "")
+		if err != nil {
+			return nil, err
+		}
+
+		sl = append(sl, s)
+	}
+
+	return sl, nil
+}
+
+// matches returns true if the LXD container matches the filter
+func (f ContainerFilter) matches(ct *api.Container) bool {
+	return strings.HasPrefix(ct.Name, f.ID) &&
+		(f.SandboxID == "" || containerSandboxID(ct) == f.SandboxID) &&
+		hasLabels(ct.Config, f.Labels)
+}
+
+// matches returns true if the LXD profile matches the filter
+func (f SandboxFilter) matches(p *api.Profile) bool {
+	return strings.HasPrefix(p.Name, f.ID) && hasLabels(p.Config, f.Labels)
+}
+
+// containerSandboxID returns the sandbox of the LXD container, which is its last profile
+func containerSandboxID(ct *api.Container) string {
+	if len(ct.Profiles) == 0 {
+		return ""
+	}
+
+	return ct.Profiles[len(ct.Profiles)-1]
+}
+
+// hasLabels returns true if the config has all labels with the values
+func hasLabels(config map[string]string, labels map[string]string) bool {
+	for k, v := range labels {
+		if val, has := config[cfgLabels+"."+k]; !has || val != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nameIndex maps a value, like a label or sandbox, to the names of the objects having it
+type nameIndex map[string]map[string]bool
+
+func (i nameIndex) add(key, name string) {
+	if i[key] == nil {
+		i[key] = map[string]bool{}
+	}
+
+	i[key][name] = true
+}
+
+// cacheIndex are the indexes of the cached containers or profiles, profiles have no sandboxes
+type cacheIndex struct {
+	sandboxes nameIndex
+	labels    nameIndex
+}
+
+func newCacheIndex() *cacheIndex {
+	return &cacheIndex{sandboxes: nameIndex{}, labels: nameIndex{}}
+}
+
+func (i *cacheIndex) addLabels(name string, config map[string]string) {
+	for k, v := range config {
+		if strings.HasPrefix(k, cfgLabels+".") {
+			i.labels.add(labelIndexKey(strings.TrimPrefix(k, cfgLabels+"."), v), name)
+		}
+	}
+}
+
+// candidates returns the names having the sandbox and all labels, nil if neither is filtered, so all are candidates
+func (i *cacheIndex) candidates(sandboxID string, labels map[string]string) map[string]bool {
+	var sets []map[string]bool
+
+	if sandboxID != "" {
+		sets = append(sets, i.sandboxes[sandboxID])
+	}
+
+	for k, v := range labels {
+		sets = append(sets, i.labels[labelIndexKey(k, v)])
+	}
+
+	if len(sets) == 0 {
+		return nil
+	}
+
+	// intersecting starts with the smallest set
+	sort.Slice(sets, func(a, b int) bool { return len(sets[a]) < len(sets[b]) })
+
+	names := map[string]bool{}
+
+	for name := range sets[0] {
+		in := true
+
+		for _, set := range sets[1:] {
+			if !set[name] {
+				in = false
+				break
+			}
+		}
+
+		if in {
+			names[name] = true
+		}
+	}
+
+	return names
+}
+
+func labelIndexKey(k, v string) string {
+	return k + "=" + v
+}
+
+// findContainers returns the containers matching the filter, looked up by the indexes if the cache is enabled
+func (c *stateCache) findContainers(server lxd.ContainerServer, f ContainerFilter) ([]api.Container, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.listener == 0 {
+		all, err := server.GetContainers()
+		if err != nil {
+			return nil, err
+		}
+
+		cts := []api.Container{}
+
+		for i := range all {
+			if f.matches(&all[i]) {
+				cts = append(cts, all[i])
+			}
+		}
+
+		return cts, nil
+	}
+
+	err := c.sync(server)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.containerIndex == nil {
+		c.containerIndex = newCacheIndex()
+
+		for name, e := range c.containers {
+			c.containerIndex.sandboxes.add(containerSandboxID(&e.ct), name)
+			c.containerIndex.addLabels(name, e.ct.Config)
+		}
+	}
+
+	names := []string{}
+
+	if candidates := c.containerIndex.candidates(f.SandboxID, f.Labels); candidates != nil {
+		for name := range candidates {
+			names = append(names, name)
+		}
+	} else {
+		for name := range c.containers {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	cts := []api.Container{}
+
+	for _, name := range names {
+		if e, has := c.containers[name]; has && f.matches(&e.ct) {
+			cts = append(cts, copyContainer(e.ct))
+		}
+	}
+
+	return cts, nil
+}
+
+// findProfiles returns the profiles matching the filter, looked up by the indexes if the cache is enabled
+func (c *stateCache) findProfiles(server lxd.ContainerServer, f SandboxFilter) ([]api.Profile, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.listener == 0 {
+		all, err := server.GetProfiles()
+		if err != nil {
+			return nil, err
+		}
+
+		ps := []api.Profile{}
+
+		for i := range all {
+			if f.matches(&all[i]) {
+				ps = append(ps, all[i])
+			}
+		}
+
+		return ps, nil
+	}
+
+	err := c.sync(server)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.profileIndex == nil {
+		c.profileIndex = newCacheIndex()
+
+		for name, e := range c.profiles {
+			c.profileIndex.addLabels(name, e.p.Config)
+		}
+	}
+
+	names := []string{}
+
+	if candidates := c.profileIndex.candidates("", f.Labels); candidates != nil {
+		for name := range candidates {
+			names = append(names, name)
+		}
+	} else {
+		for name := range c.profiles {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	ps := []api.Profile{}
+
+	for _, name := range names {
+		if e, has := c.profiles[name]; has && f.matches(&e.p) {
+			ps = append(ps, copyProfile(e.p))
+		}
+	}
+
+	return ps, nil
+}
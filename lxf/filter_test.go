@@ -0,0 +1,101 @@
+package lxf
+
+import (
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func testFilterServer() *lxdfakes.FakeContainerServer {
+	fake := &lxdfakes.FakeContainerServer{}
+	fake.GetContainersReturns([]api.Container{
+		{Name: "app-abc", ContainerPut: api.ContainerPut{Profiles: []string{"default", "sb1"}, Config: map[string]string{cfgLabels + ".app": "web"}}},
+		{Name: "app-def", ContainerPut: api.ContainerPut{Profiles: []string{"default", "sb2"}, Config: map[string]string{cfgLabels + ".app": "web"}}},
+		{Name: "db-abc", ContainerPut: api.ContainerPut{Profiles: []string{"default", "sb1"}, Config: map[string]string{cfgLabels + ".app": "db"}}},
+	}, nil)
+	fake.GetProfilesReturns([]api.Profile{
+		{Name: "sb1", ProfilePut: api.ProfilePut{Config: map[string]string{cfgLabels + ".tier": "front"}}},
+		{Name: "sb2", ProfilePut: api.ProfilePut{Config: map[string]string{cfgLabels + ".tier": "back"}}},
+	}, nil)
+
+	return fake
+}
+
+func names(cts []api.Container) []string {
+	n := []string{}
+	for _, ct := range cts {
+		n = append(n, ct.Name)
+	}
+
+	return n
+}
+
+func TestStateCache_findContainers(t *testing.T) {
+	t.Parallel()
+
+	for _, enabled := range []bool{false, true} {
+		fake := testFilterServer()
+		c := newStateCache()
+
+		if enabled {
+			c.start()
+		}
+
+		for _, tc := range []struct {
+			filter ContainerFilter
+			exp    []string
+		}{
+			{ContainerFilter{}, []string{"app-abc", "app-def", "db-abc"}},
+			{ContainerFilter{ID: "app-"}, []string{"app-abc", "app-def"}},
+			{ContainerFilter{SandboxID: "sb1"}, []string{"app-abc", "db-abc"}},
+			{ContainerFilter{Labels: map[string]string{"app": "web"}}, []string{"app-abc", "app-def"}},
+			{ContainerFilter{SandboxID: "sb1", Labels: map[string]string{"app": "web"}}, []string{"app-abc"}},
+			{ContainerFilter{Labels: map[string]string{"app": "cache"}}, []string{}},
+			{ContainerFilter{ID: "db-", SandboxID: "sb2"}, []string{}},
+		} {
+			cts, err := c.findContainers(fake, tc.filter)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.exp, names(cts), "%+v, cache %v", tc.filter, enabled)
+		}
+	}
+}
+
+func TestStateCache_findContainers_Changed(t *testing.T) {
+	t.Parallel()
+
+	fake := testFilterServer()
+	c := newStateCache()
+	c.start()
+
+	cts, err := c.findContainers(fake, ContainerFilter{Labels: map[string]string{"app": "db"}})
+	assert.NoError(t, err)
+	assert.Len(t, cts, 1)
+
+	// the relabeled container is found by its new label once refetched
+	fake.GetContainerReturns(&api.Container{Name: "app-def", ContainerPut: api.ContainerPut{Profiles: []string{"sb2"}, Config: map[string]string{cfgLabels + ".app": "db"}}}, "etag", nil)
+	c.changedContainer("app-def")
+
+	cts, err = c.findContainers(fake, ContainerFilter{Labels: map[string]string{"app": "db"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app-def", "db-abc"}, names(cts))
+	assert.Equal(t, 1, fake.GetContainersCallCount())
+}
+
+func TestStateCache_findProfiles(t *testing.T) {
+	t.Parallel()
+
+	fake := testFilterServer()
+	c := newStateCache()
+	c.start()
+
+	ps, err := c.findProfiles(fake, SandboxFilter{Labels: map[string]string{"tier": "back"}})
+	assert.NoError(t, err)
+	assert.Len(t, ps, 1)
+	assert.Equal(t, "sb2", ps[0].Name)
+
+	ps, err = c.findProfiles(fake, SandboxFilter{ID: "sb"})
+	assert.NoError(t, err)
+	assert.Len(t, ps, 2)
+}
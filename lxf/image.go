@@ -1,8 +1,8 @@
 package lxf // import "github.com/automaticserver/lxe/lxf"
 
 import (
+	"context"
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
 
@@ -18,44 +18,71 @@ type Image struct {
 	Size    int64
 }
 
-// PullImage copies the given image from the remote server
-func (l *client) PullImage(name string) (string, error) {
+// PullImage copies the given image from the remote server. It must be allowed by the image policy, an image
+// reference with digest only pulls the image with that fingerprint
+func (l *client) PullImage(ctx context.Context, name string) (string, error) {
 	imageID, err := l.parseImage(name)
 	if err != nil {
 		return "", err
 	}
 
+	err = l.getImagePolicy().Check(imageID)
+	if err != nil {
+		return "", err
+	}
+
 	// we will cretae an image server for the remote.
 	// we will also create one when it's the default remote, because the default does not always
 	// need to be the local.
-	imgServer, err := l.config.GetImageServer(imageID.Remote)
+	imgServer, err := l.imageServer(imageID.Remote)
 	if err != nil {
 		return "", err
 	}
 
-	imageRef := dereferenceAlias(imgServer, imageID.Alias)
+	imageRef := imageID.Fingerprint
+	if imageRef == "" {
+		imageRef = dereferenceAlias(imgServer, imageID.Alias)
+	}
 
 	image, _, err := imgServer.GetImage(imageRef)
 	if err != nil {
 		return "", err
 	}
 
-	args := lxd.ImageCopyArgs{
-		CopyAliases: false, // We shouldn't rely on default aliases, as aliases are unique per remote
-		AutoUpdate:  true,  // Maybe bug: currently NOT a technical requirement to know where the source is
+	// LXD also resolves a fingerprint prefix, a digest must match exactly
+	if imageID.Fingerprint != "" && image.Fingerprint != imageID.Fingerprint {
+		return "", fmt.Errorf("%w: %v, remote has %v", ErrImageDigestMismatch, name, image.Fingerprint)
 	}
 
-	err = l.opwait.CopyImage(imgServer, *image, &args)
+	if !l.copyFromCache(ctx, image, imageID.Remote) {
+		args := lxd.ImageCopyArgs{
+			CopyAliases: false, // We shouldn't rely on default aliases, as aliases are unique per remote
+			AutoUpdate:  true,  // Maybe bug: currently NOT a technical requirement to know where the source is
+		}
+
+		err = l.opwait.CopyImage(ctx, imgServer, *image, &args)
+		if err != nil {
+			return "", fmt.Errorf("unable to pull requested image %v from server %v, %w",
+				image, imageID.Remote, err)
+		}
+	}
+
+	// LXD verifies the downloaded files against the fingerprint, make sure it's stored under it
+	_, _, err = l.server.GetImage(image.Fingerprint)
 	if err != nil {
-		return "", fmt.Errorf("unable to pull requested image %v from server %v, %w",
-			image, imageID.Remote, err)
+		return "", fmt.Errorf("%w: %v, pulled image %v not found, %w", ErrImageDigestMismatch, name, image.Fingerprint, err)
+	}
+
+	// the alias follows the tag, a pull by digest must not move it
+	if imageID.Fingerprint != "" {
+		return image.Fingerprint, nil
 	}
 
 	return image.Fingerprint, l.ensureImageAlias(imageID.Tag(), image.Fingerprint)
 }
 
 // RemoveImage will remove the given image
-func (l *client) RemoveImage(name string) error {
+func (l *client) RemoveImage(ctx context.Context, name string) error {
 	imageID, err := l.parseImage(name)
 	if err != nil {
 		return err
@@ -68,7 +95,7 @@ func (l *client) RemoveImage(name string) error {
 		return nil
 	}
 
-	err = l.opwait.DeleteImage(hash)
+	err = l.opwait.DeleteImage(ctx, hash)
 	if err != nil {
 		if shared.IsErrNotFound(err) {
 			return nil
@@ -132,21 +159,26 @@ func (l *client) ListImages(filter string) ([]Image, error) {
 		return nil, fmt.Errorf("unable to list images: %w", err)
 	}
 
+	// the filter might be any reference of the image, so compare by fingerprint. An image which isn't found matches none
+	if filter != "" {
+		if imageID, err := l.parseImage(filter); err == nil {
+			hash, found, err := imageID.Hash(l)
+			if err != nil {
+				return nil, err
+			} else if !found {
+				return response, nil
+			}
+
+			filter = hash
+		}
+	}
+
 	for _, imgInfo := range imglist {
 		if filter != "" && filter != imgInfo.Fingerprint {
 			continue
 		}
 
-		aliases := []string{}
-		for _, ali := range imgInfo.Aliases {
-			aliases = append(aliases, ali.Name+":latest")
-		}
-
-		response = append(response, Image{
-			Hash:    imgInfo.Fingerprint,
-			Aliases: aliases,
-			Size:    imgInfo.Size,
-		})
+		response = append(response, toImage(&imgInfo))
 	}
 
 	return response, nil
@@ -175,16 +207,23 @@ func (l *client) GetImage(name string) (*Image, error) {
 		return nil, fmt.Errorf("unable to get image: %v, %w", name, err)
 	}
 
+	image := toImage(img)
+
+	return &image, nil
+}
+
+// toImage converts the LXD image, its aliases are reported as tags of the default tag
+func toImage(img *lxdApi.Image) Image {
 	aliases := []string{}
 	for _, ali := range img.Aliases {
-		aliases = append(aliases, ali.Name+":latest")
+		aliases = append(aliases, ali.Name+":"+DefaultImageTag)
 	}
 
-	return &Image{
+	return Image{
 		Hash:    img.Fingerprint,
 		Aliases: aliases,
 		Size:    img.Size,
-	}, nil
+	}
 }
 
 // FSPoolUsage contains fields to describe the usage of a filesystem / storagepool
@@ -225,6 +264,8 @@ func (l *client) GetFSPoolUsage() ([]FSPoolUsage, error) {
 type ImageID struct {
 	Remote string
 	Alias  string
+	// Fingerprint is set if the image was referenced by digest
+	Fingerprint string
 }
 
 // Tag builds from remote and alias an alias for local
@@ -236,9 +277,31 @@ func (i ImageID) Tag() string {
 // already a hash this one.
 // It it's not found second return will be false and error will be zero.
 func (i ImageID) Hash(l *client) (string, bool, error) {
+	// a digest pins the image, regardless of where the alias points to
+	if i.Fingerprint != "" {
+		_, _, err := l.server.GetImage(i.Fingerprint)
+		if err != nil {
+			if shared.IsErrNotFound(err) {
+				return "", false, nil
+			}
+
+			return "", false, err
+		}
+
+		return i.Fingerprint, true, nil
+	}
+
 	exists, _, err := l.server.GetImageAlias(i.Tag())
 	if err != nil { // nolint: nestif
 		if shared.IsErrNotFound(err) {
+			// it might be an alias created without remote, like the aliases of images imported into LXD directly
+			exists, _, err = l.server.GetImageAlias(i.Alias)
+			if err == nil {
+				return exists.Target, true, nil
+			} else if !shared.IsErrNotFound(err) {
+				return "", false, err
+			}
+
 			// it still might be a hash, check that
 			_, _, err = l.server.GetImage(i.Alias)
 			if err != nil {
@@ -259,43 +322,39 @@ func (i ImageID) Hash(l *client) (string, bool, error) {
 	return exists.Target, true, nil
 }
 
-// parseImage will take an external image and split it up into
-// remote and tag
-func (l *client) parseImage(name string) (ImageID, error) {
-	img, err := convertDockerImageNameToLXC(name)
-	if err != nil {
-		return ImageID{}, err
-	}
-
-	remote, tag, err := l.config.ParseRemote(img)
+// ImageRemote returns the remote of the LXD client config the image is pulled from
+func (l *client) ImageRemote(name string) (string, error) {
+	imageID, err := l.parseImage(name)
 	if err != nil {
-		return ImageID{}, err
+		return "", err
 	}
 
-	return ImageID{Remote: remote, Alias: tag}, nil
+	return imageID.Remote, nil
 }
 
-func convertDockerImageNameToLXC(inputName string) (string, error) {
-	// always remove docker tags
-	var re = regexp.MustCompile(`(.*)(:.*)`)
-	if re.MatchString(inputName) {
-		match := re.FindStringSubmatch(inputName)
-		inputName = match[1]
+// parseImage will take an external image reference and split it up into remote and alias. Besides docker style
+// references, whose registry is resolved to a LXD remote and whose digest is used as fingerprint, the fingerprint
+// itself, also in the form sha256:<fingerprint>, and the LXD form remote:alias are accepted
+func (l *client) parseImage(name string) (ImageID, error) {
+	if fp := strings.TrimPrefix(strings.ToLower(name), digestAlgorithm+":"); reDigestHex.MatchString(fp) {
+		return ImageID{Remote: l.config.DefaultRemote, Alias: fp, Fingerprint: fp}, nil
+	}
+
+	if id, is := l.parseRemoteAlias(name); is {
+		return id, nil
 	}
 
-	// no path, easy everything is the imageName
-	if !strings.Contains(inputName, "/") {
-		return inputName, nil
+	ref, err := ParseImageRef(name)
+	if err != nil {
+		return ImageID{}, err
 	}
 
-	// with remote/path, use first part as remote only if it contains a dot
-	re = regexp.MustCompile(`(.+?)/(.*)`)
-	if re.MatchString(inputName) {
-		match := re.FindStringSubmatch(inputName)
-		return match[1] + ":" + match[2], nil
+	remote, err := l.remoteName(ref.Registry)
+	if err != nil {
+		return ImageID{}, err
 	}
 
-	return "", fmt.Errorf("image name %w: %s", ErrParse, inputName)
+	return ImageID{Remote: remote, Alias: ref.Path, Fingerprint: ref.Fingerprint()}, nil
 }
 
 // dereferenceAlias from github.com/lxc/lxd/lxc/image.go:102
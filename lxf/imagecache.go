@@ -0,0 +1,54 @@
+package lxf // import "github.com/automaticserver/lxe/lxf"
+
+import (
+	"context"
+
+	lxd "github.com/lxc/lxd/client"
+	lxdApi "github.com/lxc/lxd/shared/api"
+)
+
+// SetImageCache sets the remote images are pulled from first, if it has them. Empty disables it
+func (l *client) SetImageCache(remote string) {
+	l.imageCache.Store(remote)
+}
+
+// getImageCache returns the remote images are pulled from first, empty if there's none
+func (l *client) getImageCache() string {
+	remote, _ := l.imageCache.Load().(string)
+
+	return remote
+}
+
+// copyFromCache copies the image from the image cache remote, which has it under the same fingerprint, since the image
+// was resolved on its own remote already. Returns false if the image has to be copied from its own remote, e.g. as
+// the cache doesn't have it (yet) or isn't reachable
+func (l *client) copyFromCache(ctx context.Context, image *lxdApi.Image, remote string) bool {
+	cache := l.getImageCache()
+	if cache == "" || cache == remote {
+		return false
+	}
+
+	log := log.WithField("fingerprint", image.Fingerprint).WithField("cache", cache)
+
+	cacheServer, err := l.imageServer(cache)
+	if err != nil {
+		log.WithError(err).Warn("unable to connect to image cache")
+		return false
+	}
+
+	cached, _, err := cacheServer.GetImage(image.Fingerprint)
+	if err != nil || cached.Fingerprint != image.Fingerprint {
+		log.WithError(err).Debug("image not in image cache")
+		return false
+	}
+
+	err = l.opwait.CopyImage(ctx, cacheServer, *cached, &lxd.ImageCopyArgs{})
+	if err != nil {
+		log.WithError(err).Warn("unable to pull image from image cache")
+		return false
+	}
+
+	log.Info("pulled image from image cache")
+
+	return true
+}
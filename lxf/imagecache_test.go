@@ -0,0 +1,84 @@
+package lxf
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/automaticserver/lxe/imagecache"
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxc/config"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// testImageCache serves an image cache with a unified image and returns the client using it as "cache" remote and the
+// fingerprint of the image
+func testImageCache(t *testing.T) (*client, *lxdfakes.FakeContainerServer, string, func()) {
+	dir, err := ioutil.TempDir("", "lxe-imagecache")
+	assert.NoError(t, err)
+
+	store, err := imagecache.New(dir)
+	assert.NoError(t, err)
+
+	// sha256 of "unified"
+	fingerprint := "090c2bd5de6fcec3b4c1660edb63e5932464f662a0ebb57f44f70374aa11e148"
+	source := &lxdfakes.FakeContainerServer{}
+	source.GetImageReturns(&api.Image{Fingerprint: fingerprint, Architecture: "x86_64"}, "", nil)
+	source.GetImageFileStub = func(fp string, req lxd.ImageFileRequest) (*lxd.ImageFileResponse, error) {
+		_, err := req.MetaFile.Write([]byte("unified"))
+		return &lxd.ImageFileResponse{MetaName: fp + ".tar.gz", MetaSize: 7}, err
+	}
+
+	assert.NoError(t, store.Add(source, fingerprint))
+
+	srv := httptest.NewServer(store)
+
+	client, fake := testClient()
+	client.config = &config.Config{
+		DefaultRemote: "local",
+		Remotes: map[string]config.Remote{
+			"local": {Addr: "unix://"},
+			"cache": {Addr: srv.URL, Protocol: "simplestreams", Public: true},
+		},
+	}
+
+	return client, fake, fingerprint, func() {
+		srv.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestClient_copyFromCache(t *testing.T) {
+	t.Parallel()
+
+	client, fake, fingerprint, cleanup := testImageCache(t)
+	defer cleanup()
+
+	image := &api.Image{Fingerprint: fingerprint}
+
+	assert.False(t, client.copyFromCache(ctx, image, "images"), "no cache set")
+
+	client.SetImageCache("cache")
+
+	assert.False(t, client.copyFromCache(ctx, image, "cache"), "pulled from the cache itself")
+	assert.False(t, client.copyFromCache(ctx, &api.Image{Fingerprint: "0123"}, "images"), "not in the cache")
+	assert.Equal(t, 0, fake.CopyImageCallCount())
+
+	fake.CopyImageReturns(&lxdfakes.FakeRemoteOperation{}, nil)
+
+	assert.True(t, client.copyFromCache(ctx, image, "images"))
+	assert.Equal(t, 1, fake.CopyImageCallCount())
+
+	_, copied, args := fake.CopyImageArgsForCall(0)
+	assert.Equal(t, fingerprint, copied.Fingerprint)
+	assert.False(t, args.AutoUpdate)
+
+	// a failed copy falls back to the image's own remote
+	fake.CopyImageReturns(nil, errors.New("download failed"))
+
+	assert.False(t, client.copyFromCache(ctx, image, "images"))
+}
@@ -0,0 +1,135 @@
+package lxf // import "github.com/automaticserver/lxe/lxf"
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	lxd "github.com/lxc/lxd/client"
+	lxdApi "github.com/lxc/lxd/shared/api"
+)
+
+// imageMetadataFile must exist in the root of an image directory
+const imageMetadataFile = "metadata.yaml"
+
+var (
+	ErrImageDigestMismatch = errors.New("imported image doesn't match the digest")
+	ErrImageDir            = errors.New("invalid image directory")
+)
+
+// ImportImage creates the given image from the LXD image files, either a unified tarball as meta and a nil rootfs or
+// the metadata and rootfs tarballs of a split image, and returns its hash. Like a pulled image, it gets the alias of
+// the image reference, so it's found by the same name later. An image reference with digest only imports that image
+func (l *client) ImportImage(ctx context.Context, name string, meta, rootfs io.Reader) (string, error) {
+	imageID, err := l.parseImage(name)
+	if err != nil {
+		return "", err
+	}
+
+	args := &lxd.ImageCreateArgs{
+		MetaFile: meta,
+		MetaName: "metadata.tar",
+	}
+
+	if rootfs != nil {
+		args.RootfsFile = rootfs
+		args.RootfsName = "rootfs.tar"
+	}
+
+	fingerprint, err := l.opwait.CreateImage(ctx, lxdApi.ImagesPost{}, args)
+	if err != nil {
+		return "", fmt.Errorf("unable to import image %v, %w", name, err)
+	}
+
+	// the alias follows the tag, an import by digest must not move it
+	if imageID.Fingerprint != "" {
+		if fingerprint != imageID.Fingerprint {
+			return "", fmt.Errorf("%w: %v, imported %v", ErrImageDigestMismatch, name, fingerprint)
+		}
+
+		return fingerprint, nil
+	}
+
+	return fingerprint, l.ensureImageAlias(imageID.Tag(), fingerprint)
+}
+
+// OpenImageSource opens the LXD image file at path. A directory in the layout of an unpacked unified image, with
+// metadata.yaml, rootfs and optionally templates in it, is read as unified tarball
+func OpenImageSource(path string) (io.ReadCloser, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return os.Open(path)
+	}
+
+	_, err = os.Stat(filepath.Join(path, imageMetadataFile))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v, %w", ErrImageDir, path, err)
+	}
+
+	r, w := io.Pipe()
+
+	go func() {
+		w.CloseWithError(tarDir(w, path))
+	}()
+
+	return r, nil
+}
+
+// tarDir writes the content of dir as tarball, the names are relative to dir and ownership and modes are kept
+func tarDir(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(dir, path)
+		if err != nil || name == "." {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+
+		hdr.Name = filepath.ToSlash(name)
+
+		err = tw.WriteHeader(hdr)
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
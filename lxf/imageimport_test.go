@@ -0,0 +1,133 @@
+package lxf
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	"github.com/lxc/lxd/lxc/config"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func testImportClient() (*client, *lxdfakes.FakeContainerServer, *lxdfakes.FakeOperation) {
+	client, fake := testClient()
+	client.config = &config.Config{
+		DefaultRemote: "local",
+		Remotes:       map[string]config.Remote{"local": {Addr: "unix://"}},
+	}
+
+	fakeOp := &lxdfakes.FakeOperation{}
+	fakeOp.GetReturns(api.Operation{Metadata: map[string]interface{}{"fingerprint": strings.Repeat("ab", 32)}})
+	fake.CreateImageReturns(fakeOp, nil)
+
+	return client, fake, fakeOp
+}
+
+func TestClient_ImportImage_Unified(t *testing.T) {
+	t.Parallel()
+
+	client, fake, _ := testImportClient()
+
+	hash, err := client.ImportImage(ctx, "ubuntu:20.04", strings.NewReader("unified"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("ab", 32), hash)
+
+	_, args := fake.CreateImageArgsForCall(0)
+	assert.NotNil(t, args.MetaFile)
+	assert.Nil(t, args.RootfsFile)
+
+	assert.Equal(t, 1, fake.CreateImageAliasCallCount())
+	alias := fake.CreateImageAliasArgsForCall(0)
+	assert.Equal(t, "local/ubuntu", alias.Name)
+	assert.Equal(t, strings.Repeat("ab", 32), alias.Target)
+}
+
+func TestClient_ImportImage_Split(t *testing.T) {
+	t.Parallel()
+
+	client, fake, _ := testImportClient()
+
+	_, err := client.ImportImage(ctx, "ubuntu", strings.NewReader("meta"), strings.NewReader("rootfs"))
+	assert.NoError(t, err)
+
+	_, args := fake.CreateImageArgsForCall(0)
+	assert.NotNil(t, args.MetaFile)
+	assert.NotNil(t, args.RootfsFile)
+}
+
+func TestClient_ImportImage_Digest(t *testing.T) {
+	t.Parallel()
+
+	client, fake, _ := testImportClient()
+
+	_, err := client.ImportImage(ctx, "ubuntu@sha256:"+strings.Repeat("ab", 32), strings.NewReader("unified"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, fake.CreateImageAliasCallCount())
+
+	_, err = client.ImportImage(ctx, "ubuntu@sha256:"+strings.Repeat("cd", 32), strings.NewReader("unified"), nil)
+	assert.True(t, errors.Is(err, ErrImageDigestMismatch))
+}
+
+func TestClient_ImportImage_Error(t *testing.T) {
+	t.Parallel()
+
+	client, fake, _ := testImportClient()
+	fake.CreateImageReturns(nil, errors.New("something failed"))
+
+	_, err := client.ImportImage(ctx, "ubuntu", strings.NewReader("unified"), nil)
+	assert.Error(t, err)
+	assert.Equal(t, 0, fake.CreateImageAliasCallCount())
+}
+
+func TestOpenImageSource_Dir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "metadata.yaml"), []byte("architecture: x86_64\n"), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "rootfs", "etc"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "rootfs", "etc", "hostname"), []byte("pod\n"), 0644))
+	assert.NoError(t, os.Symlink("etc/hostname", filepath.Join(dir, "rootfs", "hostname")))
+
+	r, err := OpenImageSource(dir)
+	assert.NoError(t, err)
+
+	defer r.Close()
+
+	names := []string{}
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		assert.NoError(t, err)
+
+		names = append(names, hdr.Name)
+
+		if hdr.Name == "rootfs/hostname" {
+			assert.Equal(t, "etc/hostname", hdr.Linkname)
+		}
+	}
+
+	sort.Strings(names)
+	assert.Equal(t, []string{"metadata.yaml", "rootfs", "rootfs/etc", "rootfs/etc/hostname", "rootfs/hostname"}, names)
+}
+
+func TestOpenImageSource_InvalidDir(t *testing.T) {
+	t.Parallel()
+
+	_, err := OpenImageSource(t.TempDir())
+	assert.True(t, errors.Is(err, ErrImageDir))
+
+	_, err = OpenImageSource(filepath.Join(t.TempDir(), "missing.tar.gz"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,79 @@
+package lxf // import "github.com/automaticserver/lxe/lxf"
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	ErrImageNotAllowed = errors.New("image not allowed by image policy")
+	ErrImagePolicy     = errors.New("invalid image policy")
+)
+
+// ImagePolicy restricts which images may be pulled. It's loaded from a YAML file like:
+//
+//	allow:
+//	- images/ubuntu/*
+//	- registry/path/name
+//	requireDigest: true
+type ImagePolicy struct {
+	// Allow contains the images which may be pulled in the form remote/alias, the same way they are aliased locally.
+	// Entries ending with * match as prefix. If empty, all images are allowed
+	Allow []string `yaml:"allow"`
+	// RequireDigest only allows pulls pinned by digest, like name@sha256:<fingerprint> or the fingerprint itself
+	RequireDigest bool `yaml:"requireDigest"`
+}
+
+// LoadImagePolicy reads the image policy from the YAML file at path, unknown fields are rejected
+func LoadImagePolicy(path string) (*ImagePolicy, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ImagePolicy{}
+
+	err = yaml.UnmarshalStrict(b, p)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v, %w", ErrImagePolicy, path, err)
+	}
+
+	for _, a := range p.Allow {
+		if !strings.Contains(a, "/") && a != "*" {
+			return nil, fmt.Errorf("%w: %v, entry %q must be in the form remote/alias", ErrImagePolicy, path, a)
+		}
+	}
+
+	return p, nil
+}
+
+// Check returns an error if the policy doesn't allow to pull the image. A nil policy allows all images
+func (p *ImagePolicy) Check(id ImageID) error {
+	if p == nil {
+		return nil
+	}
+
+	if p.RequireDigest && id.Fingerprint == "" {
+		return fmt.Errorf("%w: %v, a digest is required", ErrImageNotAllowed, id.Tag())
+	}
+
+	if len(p.Allow) == 0 {
+		return nil
+	}
+
+	for _, a := range p.Allow {
+		if strings.HasSuffix(a, "*") {
+			if strings.HasPrefix(id.Tag(), strings.TrimSuffix(a, "*")) {
+				return nil
+			}
+		} else if a == id.Tag() {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %v", ErrImageNotAllowed, id.Tag())
+}
@@ -0,0 +1,69 @@
+package lxf
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	"github.com/lxc/lxd/lxc/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadImagePolicy(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("allow:\n- images/ubuntu/*\n- local/busybox\nrequireDigest: true\n"), 0644))
+
+	p, err := LoadImagePolicy(path)
+	assert.NoError(t, err)
+	assert.Equal(t, &ImagePolicy{Allow: []string{"images/ubuntu/*", "local/busybox"}, RequireDigest: true}, p)
+
+	for _, content := range []string{"allow: [ubuntu]\n", "alow: [images/*]\n"} {
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+		_, err = LoadImagePolicy(path)
+		assert.True(t, errors.Is(err, ErrImagePolicy), content)
+	}
+}
+
+func TestImagePolicy_Check(t *testing.T) {
+	t.Parallel()
+
+	var nilPolicy *ImagePolicy
+	assert.NoError(t, nilPolicy.Check(ImageID{Remote: "images", Alias: "ubuntu"}))
+
+	p := &ImagePolicy{Allow: []string{"images/ubuntu/*", "local/busybox"}}
+	assert.NoError(t, p.Check(ImageID{Remote: "images", Alias: "ubuntu/20.04"}))
+	assert.NoError(t, p.Check(ImageID{Remote: "local", Alias: "busybox"}))
+	assert.True(t, errors.Is(p.Check(ImageID{Remote: "local", Alias: "busybox/musl"}), ErrImageNotAllowed))
+	assert.True(t, errors.Is(p.Check(ImageID{Remote: "images", Alias: "alpine"}), ErrImageNotAllowed))
+
+	p = &ImagePolicy{RequireDigest: true}
+	assert.NoError(t, p.Check(ImageID{Remote: "images", Alias: "ubuntu", Fingerprint: strings.Repeat("ab", 32)}))
+	assert.True(t, errors.Is(p.Check(ImageID{Remote: "images", Alias: "ubuntu"}), ErrImageNotAllowed))
+}
+
+func testPullClient() (*client, *lxdfakes.FakeContainerServer) {
+	client, fake := testClient()
+	client.config = &config.Config{
+		DefaultRemote: "local",
+		Remotes:       map[string]config.Remote{"local": {Addr: "unix://"}},
+	}
+
+	return client, fake
+}
+
+func TestClient_PullImage_NotAllowed(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testPullClient()
+	client.SetImagePolicy(&ImagePolicy{Allow: []string{"local/ubuntu"}})
+
+	_, err := client.PullImage(ctx, "alpine")
+	assert.True(t, errors.Is(err, ErrImageNotAllowed))
+	assert.Equal(t, 0, fake.CopyImageCallCount())
+}
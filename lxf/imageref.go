@@ -0,0 +1,168 @@
+package lxf // import "github.com/automaticserver/lxe/lxf"
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultImageTag is assumed if an image reference has neither tag nor digest
+	DefaultImageTag = "latest"
+	digestAlgorithm = "sha256"
+)
+
+var (
+	reDigestHex = regexp.MustCompile(`^[a-f0-9]{64}$`)
+	reImageTag  = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+)
+
+// ImageRef is a parsed docker style image reference in the form [registry[:port]/]path[:tag][@sha256:digest]. The
+// registry is mapped to a LXD remote and the digest is the LXD image fingerprint
+type ImageRef struct {
+	Registry string
+	Path     string
+	Tag      string
+	Digest   string
+}
+
+// ParseImageRef parses the image reference. A digest must be a sha256 digest
+func ParseImageRef(ref string) (*ImageRef, error) {
+	r := &ImageRef{}
+	rest := ref
+
+	if i := strings.Index(rest, "@"); i >= 0 {
+		r.Digest = strings.ToLower(rest[i+1:])
+		rest = rest[:i]
+
+		parts := strings.SplitN(r.Digest, ":", 2)
+		if len(parts) != 2 || parts[0] != digestAlgorithm || !reDigestHex.MatchString(parts[1]) {
+			return nil, fmt.Errorf("image reference %w: %s, digest must be in the form sha256:<64 hex chars>", ErrParse, ref)
+		}
+	}
+
+	// a tag is separated by the last colon after the last slash, a colon before is the port of the registry
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		r.Tag = rest[i+1:]
+		rest = rest[:i]
+
+		if !reImageTag.MatchString(r.Tag) {
+			return nil, fmt.Errorf("image reference %w: %s, invalid tag %q", ErrParse, ref, r.Tag)
+		}
+	}
+
+	// with registry/path, the first part is always the registry
+	if i := strings.Index(rest, "/"); i >= 0 {
+		r.Registry = rest[:i]
+		rest = rest[i+1:]
+	}
+
+	r.Path = rest
+
+	if r.Path == "" || (r.Registry == "" && strings.Contains(ref, "/")) {
+		return nil, fmt.Errorf("image name %w: %s", ErrParse, ref)
+	}
+
+	return r, nil
+}
+
+// Fingerprint returns the LXD image fingerprint of the digest, empty if there's no digest
+func (r *ImageRef) Fingerprint() string {
+	return strings.TrimPrefix(r.Digest, digestAlgorithm+":")
+}
+
+// Name returns the reference without tag and digest
+func (r *ImageRef) Name() string {
+	if r.Registry == "" {
+		return r.Path
+	}
+
+	return r.Registry + "/" + r.Path
+}
+
+// String returns the canonical reference. If there's neither tag nor digest, the default tag is added, so equal
+// references compare equal regardless of how they were written
+func (r *ImageRef) String() string {
+	s := r.Name()
+
+	if r.Tag != "" || r.Digest == "" {
+		tag := r.Tag
+		if tag == "" {
+			tag = DefaultImageTag
+		}
+
+		s += ":" + tag
+	}
+
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+
+	return s
+}
+
+// remoteName resolves the registry of an image reference to a LXD remote. The registry is either the name of the
+// remote or the host (with port, if not the default port) of its address
+func (l *client) remoteName(registry string) (string, error) {
+	if registry == "" {
+		return l.config.DefaultRemote, nil
+	}
+
+	if _, has := l.config.Remotes[registry]; has {
+		return registry, nil
+	}
+
+	names := make([]string, 0, len(l.config.Remotes))
+	for name := range l.config.Remotes {
+		names = append(names, name)
+	}
+
+	// deterministic result if several remotes have the same address
+	sort.Strings(names)
+
+	for _, name := range names {
+		u, err := url.Parse(l.config.Remotes[name].Addr)
+		if err != nil || u.Host == "" {
+			continue
+		}
+
+		if strings.EqualFold(u.Host, registry) || (u.Port() == "" && strings.EqualFold(u.Hostname(), registry)) {
+			return name, nil
+		}
+	}
+
+	return "", fmt.Errorf("the remote %q doesn't exist", registry)
+}
+
+// parseRemoteAlias parses the LXD form remote:alias, e.g. images:ubuntu/20.04, where remote is the name of a LXD remote.
+// To not mistake a docker style name:tag for it, the alias must contain a slash or be a fingerprint, and the part
+// before the slash must not be a port
+func (l *client) parseRemoteAlias(name string) (ImageID, bool) {
+	i := strings.Index(name, ":")
+	if i < 1 || strings.Contains(name[:i], "/") {
+		return ImageID{}, false
+	}
+
+	remote, alias := name[:i], name[i+1:]
+	if _, has := l.config.Remotes[remote]; !has {
+		return ImageID{}, false
+	}
+
+	if reDigestHex.MatchString(alias) {
+		return ImageID{Remote: remote, Alias: alias, Fingerprint: alias}, true
+	}
+
+	j := strings.Index(alias, "/")
+	if j < 1 || j == len(alias)-1 {
+		return ImageID{}, false
+	}
+
+	if _, err := strconv.Atoi(alias[:j]); err == nil {
+		return ImageID{}, false
+	}
+
+	return ImageID{Remote: remote, Alias: alias}, true
+}
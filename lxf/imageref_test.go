@@ -0,0 +1,156 @@
+package lxf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/automaticserver/lxe/shared"
+	"github.com/lxc/lxd/lxc/config"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+var testDigest = "sha256:" + strings.Repeat("ab", 32)
+
+func TestParseImageRef(t *testing.T) {
+	t.Parallel()
+
+	for in, exp := range map[string]ImageRef{
+		"ubuntu":                                   {Path: "ubuntu"},
+		"ubuntu:20.04":                             {Path: "ubuntu", Tag: "20.04"},
+		"images/ubuntu/20.04":                      {Registry: "images", Path: "ubuntu/20.04"},
+		"registry:5000/path/name":                  {Registry: "registry:5000", Path: "path/name"},
+		"registry:5000/path/name:tag":              {Registry: "registry:5000", Path: "path/name", Tag: "tag"},
+		"registry:5000/path/name@" + testDigest:    {Registry: "registry:5000", Path: "path/name", Digest: testDigest},
+		"registry:5000/path/name:v1@" + testDigest: {Registry: "registry:5000", Path: "path/name", Tag: "v1", Digest: testDigest},
+	} {
+		ref, err := ParseImageRef(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, exp, *ref, in)
+	}
+}
+
+func TestParseImageRef_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, in := range []string{"", "/ubuntu", "ubuntu@sha256:abc", "ubuntu@md5:" + strings.Repeat("ab", 32), "ubuntu:", "registry:5000/:tag"} {
+		_, err := ParseImageRef(in)
+		assert.True(t, errors.Is(err, ErrParse), in)
+	}
+}
+
+func TestImageRef_String(t *testing.T) {
+	t.Parallel()
+
+	for in, exp := range map[string]string{
+		"ubuntu":                                   "ubuntu:latest",
+		"registry:5000/path/name":                  "registry:5000/path/name:latest",
+		"registry:5000/path/name:v1":               "registry:5000/path/name:v1",
+		"registry:5000/path/name@" + testDigest:    "registry:5000/path/name@" + testDigest,
+		"registry:5000/path/name:v1@" + testDigest: "registry:5000/path/name:v1@" + testDigest,
+	} {
+		ref, err := ParseImageRef(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, exp, ref.String(), in)
+	}
+
+	ref, err := ParseImageRef("registry:5000/path/name@" + testDigest)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("ab", 32), ref.Fingerprint())
+}
+
+func TestClient_parseImage(t *testing.T) {
+	t.Parallel()
+
+	client, _ := testClient()
+	client.config = &config.Config{
+		DefaultRemote: "local",
+		Remotes: map[string]config.Remote{
+			"local":    {Addr: "unix://"},
+			"images":   {Addr: "https://images.linuxcontainers.org"},
+			"registry": {Addr: "https://registry:5000"},
+		},
+	}
+
+	for in, exp := range map[string]ImageID{
+		"ubuntu:20.04":                       {Remote: "local", Alias: "ubuntu"},
+		"images/ubuntu/20.04":                {Remote: "images", Alias: "ubuntu/20.04"},
+		"images.linuxcontainers.org/ubuntu":  {Remote: "images", Alias: "ubuntu"},
+		"registry:5000/path/name:tag":        {Remote: "registry", Alias: "path/name"},
+		"registry/path/name@" + testDigest:   {Remote: "registry", Alias: "path/name", Fingerprint: strings.Repeat("ab", 32)},
+		"images:ubuntu/20.04":                {Remote: "images", Alias: "ubuntu/20.04"},
+		"images:latest":                      {Remote: "local", Alias: "images"},
+		"images:" + strings.Repeat("ab", 32): {Remote: "images", Alias: strings.Repeat("ab", 32), Fingerprint: strings.Repeat("ab", 32)},
+		testDigest:                           {Remote: "local", Alias: strings.Repeat("ab", 32), Fingerprint: strings.Repeat("ab", 32)},
+	} {
+		id, err := client.parseImage(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, exp, id, in)
+	}
+
+	_, err := client.parseImage("unknown:5000/path/name")
+	assert.Error(t, err)
+	assert.True(t, strings.HasSuffix(err.Error(), "doesn't exist"))
+
+	remote, err := client.ImageRemote("images.linuxcontainers.org/ubuntu")
+	assert.NoError(t, err)
+	assert.Equal(t, "images", remote)
+}
+
+func TestImageID_Hash_Fingerprint(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	fake.GetImageReturns(&api.Image{}, "", nil)
+
+	hash, found, err := ImageID{Remote: "local", Alias: "name", Fingerprint: "abc"}.Hash(client)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "abc", hash)
+	assert.Equal(t, 0, fake.GetImageAliasCallCount())
+	assert.Equal(t, "abc", fake.GetImageArgsForCall(0))
+}
+
+func TestImageID_Hash_PlainAlias(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	fake.GetImageAliasStub = func(name string) (*api.ImageAliasesEntry, string, error) {
+		if name == "myimage" {
+			return &api.ImageAliasesEntry{ImageAliasesEntryPut: api.ImageAliasesEntryPut{Target: "abc"}}, "", nil
+		}
+
+		return nil, "", shared.NewErrNotFound()
+	}
+
+	hash, found, err := ImageID{Remote: "local", Alias: "myimage"}.Hash(client)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "abc", hash)
+	assert.Equal(t, "local/myimage", fake.GetImageAliasArgsForCall(0))
+}
+
+func TestClient_ListImages_Filter(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	client.config = &config.Config{DefaultRemote: "local", Remotes: map[string]config.Remote{"local": {Addr: "unix://"}}}
+
+	fp := strings.Repeat("ab", 32)
+	fake.GetImagesReturns([]api.Image{{Fingerprint: fp, Size: 42}, {Fingerprint: "other"}}, nil)
+	fake.GetImageReturns(&api.Image{Fingerprint: fp}, "", nil)
+
+	for _, filter := range []string{fp, testDigest, "local:" + fp} {
+		imgs, err := client.ListImages(filter)
+		assert.NoError(t, err, filter)
+		assert.Equal(t, []Image{{Hash: fp, Aliases: []string{}, Size: 42}}, imgs, filter)
+	}
+
+	fake.GetImageAliasReturns(nil, "", shared.NewErrNotFound())
+	fake.GetImageReturns(nil, "", shared.NewErrNotFound())
+
+	imgs, err := client.ListImages("missing")
+	assert.NoError(t, err)
+	assert.Empty(t, imgs)
+}
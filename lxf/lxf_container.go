@@ -1,10 +1,14 @@
 package lxf // import "github.com/automaticserver/lxe/lxf"
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/automaticserver/lxe/lxf/device"
@@ -12,12 +16,15 @@ import (
 	"github.com/lxc/lxd/shared/api"
 	opencontainers "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 )
 
 // NewContainer creates a local representation of a container
 func (l *client) NewContainer(sandboxID string, additionalProfiles ...string) *Container {
 	c := &Container{}
 	c.client = l
+	c.Owner = l.owner
+	c.namePrefix = l.namePrefix
 	c.Profiles = append(c.Profiles, additionalProfiles...)
 	c.Profiles = append(c.Profiles, sandboxID)
 	c.Config = make(map[string]string)
@@ -28,12 +35,12 @@ func (l *client) NewContainer(sandboxID string, additionalProfiles ...string) *C
 
 // GetContainer returns the container identified by id
 func (l *client) GetContainer(id string) (*Container, error) {
-	ct, ETag, err := l.server.GetContainer(id)
+	ct, ETag, err := l.cache.getContainer(l.server, id)
 	if err != nil {
 		return nil, err
 	}
 
-	if !IsCRI(ct) {
+	if !IsCRI(ct) || !l.owns(ct.Config) {
 		return nil, fmt.Errorf("container %w: %s", shared.NewErrNotFound(), id)
 	}
 
@@ -47,7 +54,7 @@ func (l *client) ListContainers() ([]*Container, error) {
 		etag string
 	)
 
-	cts, err := l.server.GetContainers()
+	cts, err := l.cache.getContainers(l.server)
 	if err != nil {
 		return nil, err
 	}
@@ -56,7 +63,7 @@ func (l *client) ListContainers() ([]*Container, error) {
 
 	for _, ct := range cts {
 		ct := ct // pin!
-		if !IsCRI(ct) {
+		if !IsCRI(ct) || !l.owns(ct.Config) {
 			continue
 		}
 
@@ -91,28 +98,24 @@ func (l *client) toContainer(ct *api.Container, etag string) (*Container, error)
 		}
 	}
 
-	createdAt := time.Time{}.UnixNano()
-	if createdAtS, is := ct.Config[cfgCreatedAt]; is {
-		createdAt, err = strconv.ParseInt(createdAtS, 10, 64)
-		if err != nil {
-			return nil, err
-		}
+	createdAt, err := parseUnixNano(ct.Config[cfgCreatedAt])
+	if err != nil {
+		return nil, err
 	}
 
-	startedAt := time.Time{}.UnixNano()
-	if startedAtS, is := ct.Config[cfgStartedAt]; is {
-		startedAt, err = strconv.ParseInt(startedAtS, 10, 64)
-		if err != nil {
-			return nil, err
-		}
+	startedAt, err := parseUnixNano(ct.Config[cfgStartedAt])
+	if err != nil {
+		return nil, err
 	}
 
-	finishedAt := time.Time{}.UnixNano()
-	if finishedAtS, is := ct.Config[cfgFinishedAt]; is {
-		finishedAt, err = strconv.ParseInt(finishedAtS, 10, 64)
-		if err != nil {
-			return nil, err
-		}
+	finishedAt, err := parseUnixNano(ct.Config[cfgFinishedAt])
+	if err != nil {
+		return nil, err
+	}
+
+	consoleLoggedAt, err := parseUnixNano(ct.Config[cfgConsoleLoggedAt])
+	if err != nil {
+		return nil, err
 	}
 
 	c := &Container{}
@@ -127,18 +130,33 @@ func (l *client) toContainer(ct *api.Container, etag string) (*Container, error)
 	}
 	c.Annotations = containerConfigStore.StrippedPrefixMap(ct.Config, cfgAnnotations)
 	c.Labels = containerConfigStore.StrippedPrefixMap(ct.Config, cfgLabels)
+	c.Namespaces = containerConfigStore.StrippedPrefixMap(ct.Config, cfgNamespacesPrefix)
+	c.Audit = containerConfigStore.StrippedPrefixMap(ct.Config, cfgAuditPrefix)
 	c.Config = containerConfigStore.UnreservedMap(ct.Config)
 	c.LogPath = ct.Config[cfgLogPath]
 
-	c.CreatedAt = time.Unix(0, createdAt)
-	c.StartedAt = time.Unix(0, startedAt)
-	c.FinishedAt = time.Unix(0, finishedAt)
+	// a standalone LXD reports the location none
+	if ct.Location != "none" {
+		c.Location = ct.Location
+	}
+
+	c.EvictionReason = ct.Config[cfgEvictionReason]
+	c.EvictionMessage = ct.Config[cfgEvictionMessage]
+	c.TerminationMessage = ct.Config[cfgTerminationMessage]
+	c.ConfigHash = ct.Config[cfgConfigHash]
+
+	c.CreatedAt = createdAt
+	c.Owner = ct.Config[cfgOwner]
+	c.StartedAt = startedAt
+	c.FinishedAt = finishedAt
+	c.ConsoleLoggedAt = consoleLoggedAt
 
 	c.Environment = extractEnvVars(ct.Config)
 	c.Privileged = privileged
 	c.CloudInitUserData = ct.Config[cfgCloudInitUserData]
 	c.CloudInitMetaData = ct.Config[cfgCloudInitMetaData]
 	c.CloudInitNetworkConfig = ct.Config[cfgCloudInitNetworkConfig]
+	c.CloudInitVendorData = ct.Config[cfgCloudInitVendorData]
 
 	// get devices
 	for name, options := range ct.Devices {
@@ -180,6 +198,9 @@ func (l *client) toContainer(ct *api.Container, etag string) (*Container, error)
 		c.Resources.CPU.Period = &period
 	}
 
+	c.Resources.CPU.Cpus = ct.Config[cfgResourcesCPUCpus]
+	c.Resources.CPU.Mems = ct.Config[cfgResourcesCPUMems]
+
 	c.Resources.Memory = &opencontainers.LinuxMemory{}
 
 	if memoryS := ct.Config[cfgResourcesMemoryLimit]; memoryS != "" {
@@ -191,6 +212,40 @@ func (l *client) toContainer(ct *api.Container, etag string) (*Container, error)
 		c.Resources.Memory.Limit = &memory
 	}
 
+	if oomS := ct.Config[cfgResourcesOOMScoreAdj]; oomS != "" {
+		c.OOMScoreAdj, err = strconv.ParseInt(oomS, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.Init, err = toContainerInit(ct.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Capabilities = toContainerCapabilities(ct.Config)
+
+	if exitS := ct.Config[cfgExitCode]; exitS != "" {
+		exit, err := strconv.ParseInt(exitS, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+
+		c.ExitCode = int32(exit)
+	}
+
+	if restartsS := ct.Config[cfgRestartCount]; restartsS != "" {
+		c.RestartCount, err = strconv.Atoi(restartsS)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if aliases := ct.Config[cfgHostAliases]; aliases != "" {
+		c.HostAliases = strings.Split(aliases, "\n")
+	}
+
 	c.Profiles = ct.Profiles
 	if len(c.Profiles) == 0 {
 		return nil, fmt.Errorf("%w: container '%v' has no sandbox", ErrConvert, c.ID)
@@ -198,7 +253,8 @@ func (l *client) toContainer(ct *api.Container, etag string) (*Container, error)
 
 	// Map status code of LXD to CRI
 	switch ct.StatusCode { // nolint: exhaustive
-	case api.Running:
+	case api.Running, api.Frozen:
+		// a frozen container keeps its processes, they are just not scheduled
 		c.StateName = ContainerStateRunning
 	case api.Stopped, api.Aborting, api.Stopping:
 		// we have to differentiate between stopped and created. If "user.state" exists, then it must be created, otherwise
@@ -216,8 +272,8 @@ func (l *client) toContainer(ct *api.Container, etag string) (*Container, error)
 }
 
 type EventHandler interface {
-	ContainerStarted(c *Container) error
-	ContainerStopped(c *Container) error
+	ContainerStarted(ctx context.Context, c *Container) error
+	ContainerStopped(ctx context.Context, c *Container) error
 }
 
 // lifecycleEventHandler is registered to the lxd event handler for listening to container start events
@@ -238,8 +294,14 @@ func (l *client) lifecycleEventHandler(event api.Event) {
 		return
 	}
 
-	// Early exit. We are only interested in container started and stopped events
-	if eventLifecycle.Action != "container-started" && eventLifecycle.Action != "container-stopped" {
+	// the handlers are called concurrently, so the cache must be invalidated before looking up the container
+	l.cache.lifecycle(eventLifecycle)
+
+	// Early exit. We are only interested in container started and stopped events. LXD reports a container which
+	// stopped by itself or was stopped gracefully as shut down, and only a killed container as stopped
+	switch eventLifecycle.Action {
+	case eventContainerStarted, eventContainerStopped, eventContainerShutdown:
+	default:
 		return
 	}
 
@@ -251,6 +313,9 @@ func (l *client) lifecycleEventHandler(event api.Event) {
 	})
 	log.Info("event detected")
 
+	// the event isn't bound to a request
+	ctx := context.Background()
+
 	c, err := l.GetContainer(containerID)
 	if err != nil {
 		if shared.IsErrNotFound(err) {
@@ -263,15 +328,47 @@ func (l *client) lifecycleEventHandler(event api.Event) {
 		return
 	}
 
+	// containers can exit and restart by themselves, record the time of the event as kubelet's restart backoff relies on
+	// it
+	at := event.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
 	switch eventLifecycle.Action {
-	case "container-started":
-		err := l.eventHandler.ContainerStarted(c)
+	case eventContainerStarted:
+		if c.recordRestart(at) {
+			log.WithField("restarts", c.RestartCount).Info("container restarted by itself")
+
+			err := c.Apply(ctx)
+			if err != nil {
+				log.WithError(err).Error("unable to record restart")
+			}
+		}
+
+		err := l.eventHandler.ContainerStarted(ctx, c)
 		if err != nil {
 			log.WithError(err).Error("event handler failed")
 			return
 		}
-	case "container-stopped":
-		err := l.eventHandler.ContainerStopped(c)
+	case eventContainerStopped, eventContainerShutdown:
+		code := int32(0)
+
+		switch {
+		case c.Init != nil && c.Init.Application:
+			code = l.exitCode(c.ID)
+		case eventLifecycle.Action == eventContainerStopped:
+			code = exitCodeKilled
+		}
+
+		if c.recordExit(at, code) {
+			err := c.Apply(ctx)
+			if err != nil {
+				log.WithError(err).Error("unable to record exit")
+			}
+		}
+
+		err := l.eventHandler.ContainerStopped(ctx, c)
 		if err != nil {
 			log.WithError(err).Error("event handler failed")
 			return
@@ -279,6 +376,39 @@ func (l *client) lifecycleEventHandler(event api.Event) {
 	}
 }
 
+// The lifecycle events of LXD the event handler is interested in
+const (
+	eventContainerStarted  = "container-started"
+	eventContainerStopped  = "container-stopped"
+	eventContainerShutdown = "container-shutdown"
+)
+
+// exitCodeKilled is reported if the container was killed, or the process of an application container was killed
+// before its exit code was recorded
+const exitCodeKilled = 128 + int32(unix.SIGKILL)
+
+// exitCode reads the exit code the init shim of an application container recorded in ExitCodeFile. If there's none,
+// the process was killed, or the root disk is readonly
+func (l *client) exitCode(id string) int32 {
+	content, _, err := l.server.GetContainerFile(id, ExitCodeFile)
+	if err != nil {
+		return exitCodeKilled
+	}
+	defer content.Close()
+
+	b, err := ioutil.ReadAll(io.LimitReader(content, 16)) // nolint: gomnd
+	if err != nil {
+		return exitCodeKilled
+	}
+
+	code, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 32)
+	if err != nil {
+		return exitCodeKilled
+	}
+
+	return int32(code)
+}
+
 // ContainerSelflinkRegex to extract the containername in selflinks.
 var ContainerSelflinkRegex = regexp.MustCompile(`^/[\d.]+/(instances|containers)/(.*)(\?.*)?$`)
 
@@ -295,3 +425,52 @@ func GetContainerIDFromSelflink(path string) string {
 
 	return ""
 }
+
+// toContainerCapabilities reads the added and dropped capabilities from the config, nil if there are none
+func toContainerCapabilities(config map[string]string) *ContainerCapabilities {
+	add, drop := strings.Fields(config[cfgCapabilitiesAdd]), strings.Fields(config[cfgCapabilitiesDrop])
+	if len(add) == 0 && len(drop) == 0 {
+		return nil
+	}
+
+	return &ContainerCapabilities{Add: add, Drop: drop}
+}
+
+// toContainerInit reads the init process from the config, nil if the image's init is used
+func toContainerInit(config map[string]string) (*ContainerInit, error) {
+	commandS, has := config[cfgInitCommand]
+	if !has {
+		return nil, nil
+	}
+
+	init := &ContainerInit{
+		WorkingDir: config[cfgInitWorkingDir],
+	}
+
+	err := json.Unmarshal([]byte(commandS), &init.Command)
+	if err != nil {
+		return nil, err
+	}
+
+	if uidS, has := config[cfgInitUID]; has {
+		uid, err := strconv.ParseInt(uidS, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		init.UID = &uid
+	}
+
+	if gidS, has := config[cfgInitGID]; has {
+		gid, err := strconv.ParseInt(gidS, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		init.GID = &gid
+	}
+
+	init.Application = config[cfgInitApplication] == "true"
+
+	return init, nil
+}
@@ -1,7 +1,10 @@
 package lxf
 
 import (
+	"errors"
+	"io/ioutil"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -138,6 +141,33 @@ func TestClient_ListContainers_NonCri(t *testing.T) {
 	assert.Equal(t, 1, fake.GetContainersCallCount())
 }
 
+func TestClient_ListContainers_Owner(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	client.owner = "node1"
+
+	own := basicContainer("own", "default")
+	own.Config[cfgOwner] = "node1"
+	foreign := basicContainer("foreign", "default")
+	foreign.Config[cfgOwner] = "node2"
+
+	fake.GetContainersReturns([]api.Container{*own, *foreign, *basicContainer("unowned", "default")}, nil)
+
+	cl, err := client.ListContainers()
+	assert.NoError(t, err)
+	assert.Len(t, cl, 1)
+	assert.Equal(t, "own", cl[0].ID)
+
+	fake.GetContainerReturns(foreign, "", nil)
+
+	_, err = client.GetContainer("foreign")
+	assert.True(t, shared.IsErrNotFound(err))
+
+	c := client.NewContainer("default")
+	assert.Equal(t, "node1", makeContainerConfig(c)[cfgOwner])
+}
+
 func TestClient_toContainer_AllFieldsSuccessful(t *testing.T) {
 	t.Parallel()
 
@@ -161,15 +191,28 @@ func TestClient_toContainer_AllFieldsSuccessful(t *testing.T) {
 				cfgCreatedAt:                     strconv.FormatInt(now.UnixNano(), 10),
 				cfgStartedAt:                     strconv.FormatInt(past.UnixNano(), 10),
 				cfgFinishedAt:                    strconv.FormatInt(future.UnixNano(), 10),
+				cfgConsoleLoggedAt:               strconv.FormatInt(past.UnixNano(), 10),
 				cfgEnvironmentPrefix + ".data":   "content",
 				cfgSecurityPrivileged:            "true",
 				cfgCloudInitUserData:             "userData",
 				cfgCloudInitMetaData:             "metaData",
 				cfgCloudInitNetworkConfig:        "networkConfig",
+				cfgCloudInitVendorData:           "vendorData",
 				cfgResourcesCPUShares:            "600",
 				cfgResourcesCPUQuota:             "300",
 				cfgResourcesCPUPeriod:            "100",
+				cfgResourcesCPUCpus:              "0,2-3",
+				cfgResourcesCPUMems:              "0",
 				cfgResourcesMemoryLimit:          "1234567",
+				cfgResourcesOOMScoreAdj:          "-997",
+				cfgEvictionReason:                "reason",
+				cfgEvictionMessage:               "message",
+				cfgTerminationMessage:            "terminated",
+				cfgConfigHash:                    "hash",
+				cfgCapabilitiesDrop:              "ALL",
+				cfgCapabilitiesAdd:               "NET_ADMIN NET_RAW",
+				cfgNamespacesPrefix + ".pid":     NamespacePod,
+				cfgAuditPrefix + ".started":      `{"by":"lxe"}`,
 			},
 			Devices: map[string]map[string]string{
 				"first": {
@@ -179,6 +222,7 @@ func TestClient_toContainer_AllFieldsSuccessful(t *testing.T) {
 			Profiles: []string{"profile"},
 		},
 		StatusCode: api.Aborting,
+		Location:   "member1",
 	}
 
 	exp := &Container{}
@@ -200,11 +244,22 @@ func TestClient_toContainer_AllFieldsSuccessful(t *testing.T) {
 	exp.CreatedAt = now
 	exp.StartedAt = past
 	exp.FinishedAt = future
+	exp.ConsoleLoggedAt = past
 	exp.StateName = ContainerStateExited
 	exp.LogPath = "logPath"
 	exp.CloudInitUserData = "userData"
 	exp.CloudInitMetaData = "metaData"
 	exp.CloudInitNetworkConfig = "networkConfig"
+	exp.CloudInitVendorData = "vendorData"
+	exp.Location = "member1"
+	exp.EvictionReason = "reason"
+	exp.EvictionMessage = "message"
+	exp.TerminationMessage = "terminated"
+	exp.ConfigHash = "hash"
+	exp.OOMScoreAdj = -997
+	exp.Capabilities = &ContainerCapabilities{Add: []string{"NET_ADMIN", "NET_RAW"}, Drop: []string{CapabilityAll}}
+	exp.Namespaces = map[string]string{"pid": NamespacePod}
+	exp.Audit = map[string]string{"started": `{"by":"lxe"}`}
 
 	var shares uint64 = 600
 	var quota int64 = 300
@@ -216,6 +271,8 @@ func TestClient_toContainer_AllFieldsSuccessful(t *testing.T) {
 			Shares: &shares,
 			Quota:  &quota,
 			Period: &period,
+			Cpus:   "0,2-3",
+			Mems:   "0",
 		},
 		Memory: &opencontainers.LinuxMemory{
 			Limit: &memory,
@@ -227,4 +284,192 @@ func TestClient_toContainer_AllFieldsSuccessful(t *testing.T) {
 	assert.Exactly(t, exp, c)
 }
 
+func TestClient_toContainer_Frozen(t *testing.T) {
+	t.Parallel()
+
+	client, _ := testClient()
+
+	ct := &api.Container{
+		Name: "containerName",
+		ContainerPut: api.ContainerPut{
+			Config:   map[string]string{},
+			Profiles: []string{"profile"},
+		},
+		StatusCode: api.Frozen,
+	}
+
+	c, err := client.toContainer(ct, "etag")
+	assert.NoError(t, err)
+	assert.Equal(t, ContainerStateRunning, c.StateName)
+}
+
+func TestClient_toContainer_UnsetTimestamps(t *testing.T) {
+	t.Parallel()
+
+	client, _ := testClient()
+
+	ct := &api.Container{
+		Name: "containerName",
+		ContainerPut: api.ContainerPut{
+			Config: map[string]string{
+				// former versions persisted the zero time as out of range value
+				cfgStartedAt:  strconv.FormatInt(time.Time{}.UnixNano(), 10),
+				cfgFinishedAt: "0",
+			},
+			Profiles: []string{"profile"},
+		},
+	}
+
+	c, err := client.toContainer(ct, "etag")
+	assert.NoError(t, err)
+	assert.True(t, c.CreatedAt.IsZero())
+	assert.True(t, c.StartedAt.IsZero())
+	assert.True(t, c.FinishedAt.IsZero())
+}
+
+func TestContainer_exitTime(t *testing.T) {
+	t.Parallel()
+
+	started := time.Unix(1000, 0)
+	c := &Container{StartedAt: started}
+
+	// not yet recorded
+	assert.Equal(t, started.Add(time.Second), c.exitTime(started.Add(time.Second)))
+	// clock was set back
+	assert.Equal(t, started, c.exitTime(started.Add(-time.Second)))
+
+	// already recorded for this run
+	c.FinishedAt = started.Add(time.Minute)
+	assert.Equal(t, started.Add(time.Minute), c.exitTime(started.Add(time.Hour)))
+
+	// recorded for a previous run
+	c.FinishedAt = started.Add(-time.Minute)
+	assert.Equal(t, started.Add(time.Hour), c.exitTime(started.Add(time.Hour)))
+}
+
 // TODO lifecycle event handler, but first network modes need an interface
+
+func TestCPUSet(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "3-3", CPUSet("3"))
+	assert.Equal(t, "0,2-3", CPUSet("0,2-3"))
+	assert.Equal(t, "0-1", CPUSet("0-1"))
+}
+
+func Test_makeContainerConfig_CPUSet(t *testing.T) {
+	t.Parallel()
+
+	c := &Container{}
+	c.Config = map[string]string{}
+	c.Resources = &opencontainers.LinuxResources{CPU: &opencontainers.LinuxCPU{Cpus: "2", Mems: "0"}}
+
+	config := makeContainerConfig(c)
+	assert.Equal(t, "2-2", config[cfgLimitCPU])
+	assert.Equal(t, "2", config[cfgResourcesCPUCpus])
+	assert.Equal(t, "0", config[cfgResourcesCPUMems])
+}
+
+func Test_toContainerInit(t *testing.T) {
+	t.Parallel()
+
+	init, err := toContainerInit(map[string]string{})
+	assert.NoError(t, err)
+	assert.Nil(t, init)
+
+	var uid, gid int64 = 1000, 100
+
+	c := &Container{}
+	c.Config = map[string]string{}
+	c.Init = &ContainerInit{
+		Command:    []string{"/bin/echo", "hello world"},
+		WorkingDir: "/srv",
+		UID:        &uid,
+		GID:        &gid,
+	}
+
+	config := makeContainerConfig(c)
+	assert.Equal(t, `["/bin/echo","hello world"]`, config[cfgInitCommand])
+
+	init, err = toContainerInit(config)
+	assert.NoError(t, err)
+	assert.Equal(t, c.Init, init)
+
+	_, err = toContainerInit(map[string]string{cfgInitCommand: `["/bin/echo"]`, cfgInitUID: "root"})
+	assert.Error(t, err)
+}
+
+func Test_client_exitCode(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+
+	fake.GetContainerFileReturns(ioutil.NopCloser(strings.NewReader("3\n")), nil, nil)
+	assert.Equal(t, int32(3), client.exitCode("foo"))
+
+	name, path := fake.GetContainerFileArgsForCall(0)
+	assert.Equal(t, "foo", name)
+	assert.Equal(t, ExitCodeFile, path)
+
+	fake.GetContainerFileReturns(nil, nil, errors.New("not found"))
+	assert.Equal(t, int32(137), client.exitCode("foo"), "killed before it was recorded")
+
+	fake.GetContainerFileReturns(ioutil.NopCloser(strings.NewReader("")), nil, nil)
+	assert.Equal(t, int32(137), client.exitCode("foo"))
+}
+
+func TestContainer_recordExit(t *testing.T) {
+	t.Parallel()
+
+	started := time.Now()
+
+	c := &Container{}
+	c.StartedAt = started
+
+	assert.True(t, c.recordExit(started.Add(time.Minute), 137))
+	assert.Equal(t, started.Add(time.Minute), c.FinishedAt)
+	assert.Equal(t, int32(137), c.ExitCode)
+
+	// the exit of the current run is already recorded
+	assert.False(t, c.recordExit(started.Add(2*time.Minute), 137))
+	assert.Equal(t, started.Add(time.Minute), c.FinishedAt)
+
+	assert.True(t, c.recordExit(started.Add(2*time.Minute), 0))
+	assert.Equal(t, int32(0), c.ExitCode)
+}
+
+func TestContainer_recordRestart(t *testing.T) {
+	t.Parallel()
+
+	started := time.Now()
+
+	c := &Container{}
+	assert.False(t, c.recordRestart(started), "never started before")
+
+	c.StartedAt = started
+	assert.False(t, c.recordRestart(started), "start of LXE itself")
+	assert.Equal(t, 0, c.RestartCount)
+
+	assert.True(t, c.recordRestart(started.Add(time.Minute)))
+	assert.Equal(t, 1, c.RestartCount)
+	assert.Equal(t, started.Add(time.Minute), c.StartedAt)
+
+	c.Config = map[string]string{}
+	assert.Equal(t, "1", makeContainerConfig(c)[cfgRestartCount])
+}
+
+func TestContainer_CreateID(t *testing.T) {
+	t.Parallel()
+
+	client, _ := testClient()
+
+	c := client.NewContainer("sandbox")
+	c.Metadata = ContainerMetadata{Name: "app", Attempt: 1}
+
+	id := c.CreateID()
+	assert.Regexp(t, "^app-[a-z2-7]{10}$", id)
+	assert.Equal(t, id, c.CreateID())
+
+	c.Metadata.Attempt = 2
+	assert.NotEqual(t, id, c.CreateID())
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/automaticserver/lxe/lxf/device"
 	"github.com/automaticserver/lxe/shared"
@@ -16,6 +15,8 @@ import (
 func (l *client) NewSandbox() *Sandbox {
 	s := &Sandbox{}
 	s.client = l
+	s.Owner = l.owner
+	s.namePrefix = l.namePrefix
 	s.Config = make(map[string]string)
 	s.NetworkConfig.Mode = NetworkNone
 	s.NetworkConfig.ModeData = make(map[string]string)
@@ -25,12 +26,12 @@ func (l *client) NewSandbox() *Sandbox {
 
 // GetSandbox will find a sandbox by id and return it.
 func (l *client) GetSandbox(id string) (*Sandbox, error) {
-	p, ETag, err := l.server.GetProfile(id)
+	p, ETag, err := l.cache.getProfile(l.server, id)
 	if err != nil {
 		return nil, err
 	}
 
-	if !IsCRI(p) {
+	if !IsCRI(p) || !l.owns(p.Config) {
 		return nil, fmt.Errorf("sandbox %w: %s", shared.NewErrNotFound(), id)
 	}
 
@@ -41,7 +42,7 @@ func (l *client) GetSandbox(id string) (*Sandbox, error) {
 func (l *client) ListSandboxes() ([]*Sandbox, error) {
 	var ETag string
 
-	ps, err := l.server.GetProfiles()
+	ps, err := l.cache.getProfiles(l.server)
 	if err != nil {
 		return nil, err
 	}
@@ -50,7 +51,7 @@ func (l *client) ListSandboxes() ([]*Sandbox, error) {
 
 	for _, p := range ps {
 		p := p // pin!
-		if !IsCRI(p) {
+		if !IsCRI(p) || !l.owns(p.Config) {
 			continue
 		}
 
@@ -65,6 +66,29 @@ func (l *client) ListSandboxes() ([]*Sandbox, error) {
 	return sl, nil
 }
 
+// ListForeignSandboxIDs returns the IDs of the sandboxes of the other LXE instances sharing LXD, which ListSandboxes
+// doesn't return. Without owner there are none
+func (l *client) ListForeignSandboxIDs() ([]string, error) {
+	if l.owner == "" {
+		return nil, nil
+	}
+
+	ps, err := l.cache.getProfiles(l.server)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+
+	for _, p := range ps {
+		if IsCRI(p) && !l.owns(p.Config) {
+			ids = append(ids, p.Name)
+		}
+	}
+
+	return ids, nil
+}
+
 // toSandbox will take a profile and convert it to a sandbox.
 func (l *client) toSandbox(p *api.Profile, etag string) (*Sandbox, error) {
 	var err error
@@ -77,12 +101,9 @@ func (l *client) toSandbox(p *api.Profile, etag string) (*Sandbox, error) {
 		}
 	}
 
-	createdAt := time.Time{}.UnixNano()
-	if createdAtS, is := p.Config[cfgCreatedAt]; is {
-		createdAt, err = strconv.ParseInt(createdAtS, 10, 64)
-		if err != nil {
-			return nil, err
-		}
+	createdAt, err := parseUnixNano(p.Config[cfgCreatedAt])
+	if err != nil {
+		return nil, err
 	}
 
 	s := &Sandbox{}
@@ -92,6 +113,7 @@ func (l *client) toSandbox(p *api.Profile, etag string) (*Sandbox, error) {
 	s.ETag = etag
 	s.Hostname = p.Config[cfgHostname]
 	s.LogDirectory = p.Config[cfgLogDirectory]
+	s.RuntimeHandler = p.Config[cfgRuntimeHandler]
 	s.Metadata = SandboxMetadata{
 		Attempt:   uint32(attempt),
 		Name:      p.Config[cfgMetaName],
@@ -101,14 +123,17 @@ func (l *client) toSandbox(p *api.Profile, etag string) (*Sandbox, error) {
 	s.NetworkConfig = NetworkConfig{
 		Nameservers: strings.Split(p.Config[cfgNetworkConfigNameservers], ","),
 		Searches:    strings.Split(p.Config[cfgNetworkConfigSearches], ","),
+		Options:     strings.Split(p.Config[cfgNetworkConfigOptions], ","),
 		Mode:        getNetworkMode(p.Config[cfgNetworkConfigMode]),
 		ModeData:    make(map[string]string),
+		Generation:  p.Config[cfgNetworkConfigGeneration],
 	}
 	s.Labels = sandboxConfigStore.StrippedPrefixMap(p.Config, cfgLabels)
 	s.Annotations = sandboxConfigStore.StrippedPrefixMap(p.Config, cfgAnnotations)
 	s.Config = sandboxConfigStore.UnreservedMap(p.Config)
 	s.State = getSandboxState(p.Config[cfgState])
-	s.CreatedAt = time.Unix(0, createdAt)
+	s.CreatedAt = createdAt
+	s.Owner = p.Config[cfgOwner]
 
 	err = yaml.Unmarshal([]byte(p.Config[cfgNetworkConfigModeData]), &s.NetworkConfig.ModeData)
 	if err != nil {
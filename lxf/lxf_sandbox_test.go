@@ -133,6 +133,56 @@ func TestClient_ListSandboxes_NonCri(t *testing.T) {
 	assert.Equal(t, 1, fake.GetProfilesCallCount())
 }
 
+func TestClient_ListSandboxes_Owner(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	client.owner = "node1"
+
+	own := basicProfile("own")
+	own.Config[cfgOwner] = "node1"
+	foreign := basicProfile("foreign")
+	foreign.Config[cfgOwner] = "node2"
+
+	fake.GetProfilesReturns([]api.Profile{*own, *foreign, *basicProfile("unowned")}, nil)
+
+	sl, err := client.ListSandboxes()
+	assert.NoError(t, err)
+	assert.Len(t, sl, 1)
+	assert.Equal(t, "own", sl[0].ID)
+	assert.Equal(t, "node1", sl[0].Owner)
+
+	fake.GetProfileReturns(foreign, "", nil)
+
+	_, err = client.GetSandbox("foreign")
+	assert.True(t, shared.IsErrNotFound(err))
+	assert.Equal(t, "node1", client.NewSandbox().Owner)
+}
+
+func TestClient_ListForeignSandboxIDs(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+
+	own := basicProfile("own")
+	own.Config[cfgOwner] = "node1"
+	foreign := basicProfile("foreign")
+	foreign.Config[cfgOwner] = "node2"
+
+	fake.GetProfilesReturns([]api.Profile{*own, *foreign, *basicProfile("unowned")}, nil)
+
+	// without owner all sandboxes are its own
+	ids, err := client.ListForeignSandboxIDs()
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+
+	client.owner = "node1"
+
+	ids, err = client.ListForeignSandboxIDs()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"foreign", "unowned"}, ids)
+}
+
 func TestClient_toSandbox_AllFieldsSuccessful(t *testing.T) {
 	t.Parallel()
 
@@ -151,8 +201,10 @@ func TestClient_toSandbox_AllFieldsSuccessful(t *testing.T) {
 				cfgCreatedAt:                     strconv.FormatInt(now.UnixNano(), 10),
 				cfgHostname:                      "hostname",
 				cfgLogDirectory:                  "logDirectory",
+				cfgRuntimeHandler:                "nested",
 				cfgNetworkConfigNameservers:      "1.2.3.4,5.6.7.8",
 				cfgNetworkConfigSearches:         "svc.local,local",
+				cfgNetworkConfigOptions:          "ndots:5",
 				cfgNetworkConfigMode:             "none",
 				cfgLabels + ".alabel":            "aLabel",
 				cfgAnnotations + ".anannotation": "anAnnotation",
@@ -188,10 +240,12 @@ func TestClient_toSandbox_AllFieldsSuccessful(t *testing.T) {
 	exp.Hostname = "hostname"
 	exp.NetworkConfig.Nameservers = []string{"1.2.3.4", "5.6.7.8"}
 	exp.NetworkConfig.Searches = []string{"svc.local", "local"}
+	exp.NetworkConfig.Options = []string{"ndots:5"}
 	exp.NetworkConfig.Mode = NetworkNone
 	exp.NetworkConfig.ModeData = map[string]string{"mode": "data"}
 	exp.State = SandboxNotReady
 	exp.LogDirectory = "logDirectory"
+	exp.RuntimeHandler = "nested"
 
 	s, err := client.toSandbox(p, "etag")
 	assert.NoError(t, err)
@@ -0,0 +1,33 @@
+package lxo // import "github.com/automaticserver/lxe/lxf/lxo"
+
+import (
+	"context"
+	"io"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// ExportContainer will create a backup of the container with its snapshots, write its tarball to w and delete the
+// backup again
+func (l *LXO) ExportContainer(ctx context.Context, id, name string, w io.WriteSeeker) error {
+	err := l.do(ctx, "backup", func() (waiter, error) {
+		return l.server.CreateContainerBackup(id, api.ContainerBackupsPost{Name: name})
+	})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err := l.do(ctx, "backup-delete", func() (waiter, error) {
+			return l.server.DeleteContainerBackup(id, name)
+		})
+		if err != nil {
+			log.WithError(err).WithField("containerid", id).WithField("backup", name).Warn("unable to delete backup")
+		}
+	}()
+
+	_, err = l.server.GetContainerBackupFile(id, name, &lxd.BackupFileRequest{BackupFile: w})
+
+	return err
+}
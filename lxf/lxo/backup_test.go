@@ -0,0 +1,48 @@
+package lxo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLXO_ExportContainer(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+
+	fake.CreateContainerBackupReturns(&lxdfakes.FakeOperation{}, nil)
+	fake.DeleteContainerBackupReturns(&lxdfakes.FakeOperation{}, nil)
+
+	err := lxo.ExportContainer(ctx, "foo", "backup0", nil)
+	assert.NoError(t, err)
+
+	id, req := fake.CreateContainerBackupArgsForCall(0)
+	assert.Equal(t, "foo", id)
+	assert.Equal(t, api.ContainerBackupsPost{Name: "backup0"}, req)
+
+	id, name, _ := fake.GetContainerBackupFileArgsForCall(0)
+	assert.Equal(t, "foo", id)
+	assert.Equal(t, "backup0", name)
+
+	// the backup is only needed for the download
+	id, name = fake.DeleteContainerBackupArgsForCall(0)
+	assert.Equal(t, "foo", id)
+	assert.Equal(t, "backup0", name)
+}
+
+func TestLXO_ExportContainer_Failed(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+
+	fake.CreateContainerBackupReturns(nil, errors.New("something failed"))
+
+	err := lxo.ExportContainer(ctx, "foo", "backup0", nil)
+	assert.Error(t, err)
+	assert.Equal(t, 0, fake.GetContainerBackupFileCallCount())
+	assert.Equal(t, 0, fake.DeleteContainerBackupCallCount())
+}
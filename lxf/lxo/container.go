@@ -1,91 +1,169 @@
 package lxo // import "github.com/automaticserver/lxe/lxf/lxo"
 
 import (
-	lxd "github.com/lxc/lxd/client"
+	"context"
+
+	"github.com/automaticserver/lxe/tracing"
 	"github.com/lxc/lxd/shared/api"
 )
 
-// StopContainer will try to stop the container with provided name.
-// It will retry for half a minute and return success when it's stopped.
-func (l *LXO) StopContainer(id string, timeout, retries int) error {
-	var (
-		err  error
-		etag string
-	)
-
-	for i := 0; i <= retries; i++ {
-		lxdReq := api.ContainerStatePut{
-			Action:  "stop",
-			Timeout: timeout,
-			Force:   i == retries,
-		}
-
-		var op lxd.Operation
-
-		op, err = l.server.UpdateContainerState(id, lxdReq, etag)
-		if err != nil {
-			return err
-		}
-
-		err = op.Wait()
-		if err != nil {
-			if err.Error() == "The container is already stopped" {
-				return nil
-			}
-		} else {
-			return nil
-		}
+// StopContainer stops the container with provided name like CRI defines it: The container gets timeout seconds to shut
+// down, afterwards it's killed. If timeout is 0 or less, it's killed right away. It returns as soon as the container is
+// stopped or ctx is done.
+func (l *LXO) StopContainer(ctx context.Context, id string, timeout int) error {
+	if timeout <= 0 {
+		return l.killContainer(ctx, id)
+	}
+
+	ctx, span := startSpan(ctx, "stop")
+
+	err := l.stopContainer(ctx, id, timeout)
+
+	tracing.End(span, err)
+
+	return err
+}
+
+// stopContainer lets the container shut down within timeout seconds, otherwise it's killed
+func (l *LXO) stopContainer(ctx context.Context, id string, timeout int) error {
+	lxdReq := api.ContainerStatePut{
+		Action:  "stop",
+		Timeout: timeout,
+	}
+
+	var op waiter
+
+	// only sending the request is retried, a shutdown which fails after the timeout is followed by the kill instead
+	err := l.retry(ctx, "stop", l.conf.Retries, func(int) error {
+		var err error
+		op, err = l.server.UpdateContainerState(id, lxdReq, "")
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = l.wait(ctx, "stop", op)
+	if err == nil || isAlreadyStopped(err) {
+		return nil
+	}
+
+	if ctx.Err() != nil {
+		return err
+	}
+
+	return l.killContainer(ctx, id)
+}
+
+// killContainer force stops the container and waits till operation is done or return an error
+func (l *LXO) killContainer(ctx context.Context, id string) error {
+	lxdReq := api.ContainerStatePut{
+		Action:  "stop",
+		Timeout: -1,
+		Force:   true,
+	}
+
+	err := l.doChecked(ctx, "kill", func() (waiter, error) {
+		return l.server.UpdateContainerState(id, lxdReq, "")
+	}, containerState(id, api.Stopped))
+	if isAlreadyStopped(err) {
+		return nil
 	}
 
 	return err
 }
 
+// isAlreadyStopped returns true if LXD refused the stop as there's nothing to stop
+func isAlreadyStopped(err error) bool {
+	return err != nil && err.Error() == "The container is already stopped"
+}
+
 // StartContainer will start the container and wait till operation is done or
 // return an error
-func (l *LXO) StartContainer(id string) error {
-	ETag := ""
+func (l *LXO) StartContainer(ctx context.Context, id string) error {
 	lxdReq := api.ContainerStatePut{
 		Action:  "start",
 		Timeout: -1,
 	}
 
-	op, err := l.server.UpdateContainerState(id, lxdReq, ETag)
-	if err != nil {
-		return err
+	return l.doChecked(ctx, "start", func() (waiter, error) {
+		return l.server.UpdateContainerState(id, lxdReq, "")
+	}, containerState(id, api.Running))
+}
+
+// FreezeContainer will freeze all processes of the container and wait till operation is done or return an error
+func (l *LXO) FreezeContainer(ctx context.Context, id string) error {
+	lxdReq := api.ContainerStatePut{
+		Action:  "freeze",
+		Timeout: -1,
+	}
+
+	return l.doChecked(ctx, "freeze", func() (waiter, error) {
+		return l.server.UpdateContainerState(id, lxdReq, "")
+	}, containerState(id, api.Frozen))
+}
+
+// UnfreezeContainer will resume all processes of the frozen container and wait till operation is done or return an
+// error
+func (l *LXO) UnfreezeContainer(ctx context.Context, id string) error {
+	lxdReq := api.ContainerStatePut{
+		Action:  "unfreeze",
+		Timeout: -1,
 	}
 
-	return op.Wait()
+	return l.doChecked(ctx, "unfreeze", func() (waiter, error) {
+		return l.server.UpdateContainerState(id, lxdReq, "")
+	}, containerState(id, api.Running))
 }
 
 // CreateContainer will create the container and wait till operation is done or
-// return an error
-func (l *LXO) CreateContainer(container api.ContainersPost) error {
-	op, err := l.server.CreateContainer(container)
-	if err != nil {
-		return err
+// return an error. If LXD is clustered and target is set, the container is created on that cluster member, otherwise
+// LXD chooses one
+func (l *LXO) CreateContainer(ctx context.Context, container api.ContainersPost, target string) error {
+	server := l.server
+	if target != "" {
+		server = server.UseTarget(target)
 	}
 
-	return op.Wait()
+	return l.doChecked(ctx, "create", func() (waiter, error) {
+		return server.CreateContainer(container)
+	}, containerExists(container.Name, true))
 }
 
-// UpdateContainer will create the container and wait till operation is done or
-// return an error
-func (l *LXO) UpdateContainer(id string, container api.ContainerPut, etag string) error {
-	op, err := l.server.UpdateContainer(id, container, etag)
-	if err != nil {
-		return err
+// MigrateContainer will move the container to the LXD cluster member target and wait till operation is done or return
+// an error. A running container is migrated live using CRIU. It's not retried as a failed migration leaves an unknown
+// state behind
+func (l *LXO) MigrateContainer(ctx context.Context, id, target string, live bool) error {
+	lxdReq := api.ContainerPost{
+		Migration: true,
+		Live:      live,
+	}
+
+	ctx, span := startSpan(ctx, "migrate")
+
+	op, err := l.server.UseTarget(target).MigrateContainer(id, lxdReq)
+	if err == nil {
+		err = l.wait(ctx, "migrate", op)
 	}
 
-	return op.Wait()
+	tracing.End(span, err)
+
+	return err
+}
+
+// UpdateContainer will create the container and wait till operation is done or
+// return an error
+func (l *LXO) UpdateContainer(ctx context.Context, id string, container api.ContainerPut, etag string) error {
+	return l.do(ctx, "update", func() (waiter, error) {
+		return l.server.UpdateContainer(id, container, etag)
+	})
 }
 
 // DeleteContainer will delete the container and wait till operation is done or
 // return an error
-func (l *LXO) DeleteContainer(id string) error {
-	op, err := l.server.DeleteContainer(id)
-	if err != nil {
-		return err
-	}
-
-	return op.Wait()
+func (l *LXO) DeleteContainer(ctx context.Context, id string) error {
+	return l.doChecked(ctx, "delete", func() (waiter, error) {
+		return l.server.DeleteContainer(id)
+	}, containerExists(id, false))
 }
@@ -1,8 +1,10 @@
 package lxo
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/automaticserver/lxe/lxf/lxdfakes"
 	"github.com/lxc/lxd/shared/api"
@@ -18,11 +20,14 @@ func TestLXO_StopContainer_Simple(t *testing.T) {
 	fake.UpdateContainerStateReturns(fakeOp, nil)
 	fakeOp.WaitReturns(nil)
 
-	err := lxo.StopContainer("foo", 10, 0)
+	err := lxo.StopContainer(ctx, "foo", 10)
 	assert.NoError(t, err)
 
 	assert.Equal(t, 1, fake.UpdateContainerStateCallCount())
 	assert.Equal(t, 1, fakeOp.WaitCallCount())
+
+	_, req, _ := fake.UpdateContainerStateArgsForCall(0)
+	assert.Equal(t, api.ContainerStatePut{Action: "stop", Timeout: 10}, req)
 }
 
 func TestLXO_StopContainer_Error(t *testing.T) {
@@ -33,13 +38,31 @@ func TestLXO_StopContainer_Error(t *testing.T) {
 
 	fake.UpdateContainerStateReturns(fakeOp, errors.New("something failed"))
 
-	err := lxo.StopContainer("foo", 10, 0)
+	err := lxo.StopContainer(ctx, "foo", 10)
 	assert.Error(t, err)
 
 	assert.Equal(t, 1, fake.UpdateContainerStateCallCount())
 	assert.Equal(t, 0, fakeOp.WaitCallCount())
 }
 
+func TestLXO_StopContainer_RetryRequest(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	lxo.conf.Retries = 1
+	fakeOp := &lxdfakes.FakeOperation{}
+
+	fake.UpdateContainerStateReturnsOnCall(0, nil, errors.New("Container is busy running a start operation"))
+	fake.UpdateContainerStateReturnsOnCall(1, fakeOp, nil)
+	fakeOp.WaitReturns(nil)
+
+	err := lxo.StopContainer(ctx, "foo", 10)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, fake.UpdateContainerStateCallCount())
+	assert.Equal(t, 1, fakeOp.WaitCallCount())
+}
+
 func TestLXO_StopContainer_ForceSuccess(t *testing.T) {
 	t.Parallel()
 
@@ -50,11 +73,14 @@ func TestLXO_StopContainer_ForceSuccess(t *testing.T) {
 	fakeOp.WaitReturnsOnCall(0, errors.New("some error"))
 	fakeOp.WaitReturnsOnCall(1, nil)
 
-	err := lxo.StopContainer("foo", 5, 1)
+	err := lxo.StopContainer(ctx, "foo", 5)
 	assert.NoError(t, err)
 
 	assert.Equal(t, 2, fake.UpdateContainerStateCallCount())
 	assert.Equal(t, 2, fakeOp.WaitCallCount())
+
+	_, req, _ := fake.UpdateContainerStateArgsForCall(1)
+	assert.True(t, req.Force)
 }
 
 func TestLXO_StopContainer_ForceFailed(t *testing.T) {
@@ -67,13 +93,55 @@ func TestLXO_StopContainer_ForceFailed(t *testing.T) {
 	fakeOp.WaitReturnsOnCall(0, errors.New("some error"))
 	fakeOp.WaitReturnsOnCall(1, errors.New("still error"))
 
-	err := lxo.StopContainer("foo", 5, 1)
+	err := lxo.StopContainer(ctx, "foo", 5)
 	assert.Error(t, err)
 
 	assert.Equal(t, 2, fake.UpdateContainerStateCallCount())
 	assert.Equal(t, 2, fakeOp.WaitCallCount())
 }
 
+func TestLXO_StopContainer_NoTimeout(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fakeOp := &lxdfakes.FakeOperation{}
+
+	fake.UpdateContainerStateReturns(fakeOp, nil)
+	fakeOp.WaitReturns(nil)
+
+	err := lxo.StopContainer(ctx, "foo", 0)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, fake.UpdateContainerStateCallCount())
+
+	_, req, _ := fake.UpdateContainerStateArgsForCall(0)
+	assert.True(t, req.Force)
+}
+
+func TestLXO_StopContainer_ContextDone(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fakeOp := &lxdfakes.FakeOperation{}
+	block := make(chan struct{})
+
+	defer close(block)
+
+	fake.UpdateContainerStateReturns(fakeOp, nil)
+	fakeOp.WaitStub = func() error {
+		<-block
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := lxo.StopContainer(ctx, "foo", 30)
+	assert.Error(t, err)
+
+	assert.Equal(t, 1, fake.UpdateContainerStateCallCount(), "no kill after the deadline")
+}
+
 func TestLXO_StopContainer_AlreadyStopped(t *testing.T) {
 	t.Parallel()
 
@@ -83,7 +151,7 @@ func TestLXO_StopContainer_AlreadyStopped(t *testing.T) {
 	fake.UpdateContainerStateReturns(fakeOp, nil)
 	fakeOp.WaitReturnsOnCall(0, errors.New("The container is already stopped"))
 
-	err := lxo.StopContainer("foo", 5, 1)
+	err := lxo.StopContainer(ctx, "foo", 5)
 	assert.NoError(t, err)
 
 	assert.Equal(t, 1, fake.UpdateContainerStateCallCount())
@@ -99,7 +167,7 @@ func TestLXO_StartContainer_Simple(t *testing.T) {
 	fake.UpdateContainerStateReturns(fakeOp, nil)
 	fakeOp.WaitReturns(nil)
 
-	err := lxo.StartContainer("foo")
+	err := lxo.StartContainer(ctx, "foo")
 	assert.NoError(t, err)
 
 	assert.Equal(t, 1, fake.UpdateContainerStateCallCount())
@@ -114,7 +182,7 @@ func TestLXO_StartContainer_Error(t *testing.T) {
 
 	fake.UpdateContainerStateReturns(fakeOp, errors.New("something missing"))
 
-	err := lxo.StartContainer("foo")
+	err := lxo.StartContainer(ctx, "foo")
 	assert.Error(t, err)
 
 	assert.Equal(t, 1, fake.UpdateContainerStateCallCount())
@@ -130,7 +198,7 @@ func TestLXO_CreateContainer_Simple(t *testing.T) {
 	fake.CreateContainerReturns(fakeOp, nil)
 	fakeOp.WaitReturns(nil)
 
-	err := lxo.CreateContainer(api.ContainersPost{})
+	err := lxo.CreateContainer(ctx, api.ContainersPost{}, "")
 	assert.NoError(t, err)
 
 	assert.Equal(t, 1, fake.CreateContainerCallCount())
@@ -145,13 +213,66 @@ func TestLXO_CreateContainer_Error(t *testing.T) {
 
 	fake.CreateContainerReturns(fakeOp, errors.New("something failed"))
 
-	err := lxo.CreateContainer(api.ContainersPost{})
+	err := lxo.CreateContainer(ctx, api.ContainersPost{}, "")
 	assert.Error(t, err)
 
 	assert.Equal(t, 1, fake.CreateContainerCallCount())
 	assert.Equal(t, 0, fakeOp.WaitCallCount())
 }
 
+func TestLXO_CreateContainer_Target(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fakeTarget := &lxdfakes.FakeContainerServer{}
+	fakeOp := &lxdfakes.FakeOperation{}
+
+	fake.UseTargetReturns(fakeTarget)
+	fakeTarget.CreateContainerReturns(fakeOp, nil)
+
+	err := lxo.CreateContainer(ctx, api.ContainersPost{}, "member1")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "member1", fake.UseTargetArgsForCall(0))
+	assert.Equal(t, 0, fake.CreateContainerCallCount())
+	assert.Equal(t, 1, fakeTarget.CreateContainerCallCount())
+	assert.Equal(t, 1, fakeOp.WaitCallCount())
+}
+
+func TestLXO_MigrateContainer(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fakeTarget := &lxdfakes.FakeContainerServer{}
+	fakeOp := &lxdfakes.FakeOperation{}
+
+	fake.UseTargetReturns(fakeTarget)
+	fakeTarget.MigrateContainerReturns(fakeOp, nil)
+
+	err := lxo.MigrateContainer(ctx, "foo", "member1", true)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "member1", fake.UseTargetArgsForCall(0))
+	id, req := fakeTarget.MigrateContainerArgsForCall(0)
+	assert.Equal(t, "foo", id)
+	assert.True(t, req.Migration)
+	assert.True(t, req.Live)
+	assert.Equal(t, 1, fakeOp.WaitCallCount())
+}
+
+func TestLXO_MigrateContainer_Error(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fakeTarget := &lxdfakes.FakeContainerServer{}
+
+	fake.UseTargetReturns(fakeTarget)
+	fakeTarget.MigrateContainerReturns(nil, errors.New("criu missing"))
+
+	err := lxo.MigrateContainer(ctx, "foo", "member1", true)
+	assert.Error(t, err)
+}
+
 func TestLXO_UpdateContainer_Simple(t *testing.T) {
 	t.Parallel()
 
@@ -161,7 +282,7 @@ func TestLXO_UpdateContainer_Simple(t *testing.T) {
 	fake.UpdateContainerReturns(fakeOp, nil)
 	fakeOp.WaitReturns(nil)
 
-	err := lxo.UpdateContainer("foo", api.ContainerPut{}, "")
+	err := lxo.UpdateContainer(ctx, "foo", api.ContainerPut{}, "")
 	assert.NoError(t, err)
 
 	assert.Equal(t, 1, fake.UpdateContainerCallCount())
@@ -176,7 +297,7 @@ func TestLXO_UpdateContainer_Error(t *testing.T) {
 
 	fake.UpdateContainerReturns(fakeOp, errors.New("something failed"))
 
-	err := lxo.UpdateContainer("foo", api.ContainerPut{}, "")
+	err := lxo.UpdateContainer(ctx, "foo", api.ContainerPut{}, "")
 	assert.Error(t, err)
 
 	assert.Equal(t, 1, fake.UpdateContainerCallCount())
@@ -192,7 +313,7 @@ func TestLXO_DeleteContainer_Simple(t *testing.T) {
 	fake.DeleteContainerReturns(fakeOp, nil)
 	fakeOp.WaitReturns(nil)
 
-	err := lxo.DeleteContainer("foo")
+	err := lxo.DeleteContainer(ctx, "foo")
 	assert.NoError(t, err)
 
 	assert.Equal(t, 1, fake.DeleteContainerCallCount())
@@ -207,9 +328,45 @@ func TestLXO_DeleteContainer_Error(t *testing.T) {
 
 	fake.DeleteContainerReturns(fakeOp, errors.New("something failed"))
 
-	err := lxo.DeleteContainer("foo")
+	err := lxo.DeleteContainer(ctx, "foo")
 	assert.Error(t, err)
 
 	assert.Equal(t, 1, fake.DeleteContainerCallCount())
 	assert.Equal(t, 0, fakeOp.WaitCallCount())
 }
+
+func TestLXO_FreezeContainer(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fakeOp := &lxdfakes.FakeOperation{}
+
+	fake.UpdateContainerStateReturns(fakeOp, nil)
+
+	err := lxo.FreezeContainer(ctx, "foo")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, fake.UpdateContainerStateCallCount())
+	id, req, _ := fake.UpdateContainerStateArgsForCall(0)
+	assert.Equal(t, "foo", id)
+	assert.Equal(t, "freeze", req.Action)
+	assert.Equal(t, 1, fakeOp.WaitCallCount())
+}
+
+func TestLXO_UnfreezeContainer(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fakeOp := &lxdfakes.FakeOperation{}
+
+	fake.UpdateContainerStateReturns(fakeOp, nil)
+
+	err := lxo.UnfreezeContainer(ctx, "foo")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, fake.UpdateContainerStateCallCount())
+	id, req, _ := fake.UpdateContainerStateArgsForCall(0)
+	assert.Equal(t, "foo", id)
+	assert.Equal(t, "unfreeze", req.Action)
+	assert.Equal(t, 1, fakeOp.WaitCallCount())
+}
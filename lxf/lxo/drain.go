@@ -0,0 +1,66 @@
+package lxo // import "github.com/automaticserver/lxe/lxf/lxo"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrDrainTimeout = errors.New("lxd operations still in flight")
+
+// pending are the operations of all clients being waited for, including the ones of clients replaced by a reconnect
+var pending = &pendingOps{}
+
+// pendingOps counts the operations being waited for
+type pendingOps struct {
+	mu sync.Mutex
+	n  int
+	// idle is closed when the last operation is done
+	idle chan struct{}
+}
+
+func (p *pendingOps) add() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.n == 0 {
+		p.idle = make(chan struct{})
+	}
+
+	p.n++
+}
+
+func (p *pendingOps) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.n--
+	if p.n == 0 {
+		close(p.idle)
+	}
+}
+
+// wait waits till no operation is in flight or ctx is done
+func (p *pendingOps) wait(ctx context.Context) error {
+	p.mu.Lock()
+	n, idle := p.n, p.idle
+	p.mu.Unlock()
+
+	if n == 0 {
+		return nil
+	}
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrDrainTimeout, ctx.Err())
+	}
+}
+
+// Drain waits till the LXD operations in flight are done or ctx is done, e.g. before shutting down. It doesn't prevent
+// new operations from being started
+func Drain(ctx context.Context) error {
+	return pending.wait(ctx)
+}
@@ -0,0 +1,39 @@
+package lxo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_pendingOps(t *testing.T) {
+	t.Parallel()
+
+	p := &pendingOps{}
+
+	assert.NoError(t, p.wait(context.Background()))
+
+	p.add()
+	p.add()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := p.wait(ctx)
+	assert.True(t, errors.Is(err, ErrDrainTimeout))
+
+	go func() {
+		p.done()
+		p.done()
+	}()
+
+	assert.NoError(t, p.wait(context.Background()))
+
+	// it's reusable after being idle
+	p.add()
+	p.done()
+	assert.NoError(t, p.wait(context.Background()))
+}
@@ -0,0 +1,273 @@
+package lxo // import "github.com/automaticserver/lxe/lxf/lxo"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/automaticserver/lxe/tracing"
+	"github.com/gorilla/websocket"
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sys/unix"
+)
+
+// DefaultExecSyncMaxOutput is the default size cap of each captured output stream of ExecSync
+const DefaultExecSyncMaxOutput = 16 * 1024 * 1024
+
+var (
+	ErrExecTimeout = errors.New("timeout reached")
+	// ErrExecNotKilled is returned if a killed command didn't end in time, it might still be running
+	ErrExecNotKilled = errors.New("command didn't end after kill")
+	ErrExecParse     = errors.New("unable to parse exit code")
+	// ErrNoControlSocket is returned if the exec control socket isn't established yet
+	ErrNoControlSocket = errors.New("no control socket found")
+
+	// CodeExecError is returned if the command couldn't be run or its exit code is unknown
+	CodeExecError int32 = 128
+	// CodeExecTimeout is returned if the command was killed after the timeout, like a shell reports a killed process
+	CodeExecTimeout = CodeExecError + int32(unix.SIGKILL) // 128+9=137
+)
+
+// ExecSyncResult contains the captured output and the exit code of a synchronous exec
+type ExecSyncResult struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int32
+	// Truncated is true if any output exceeded the size cap and was cut
+	Truncated bool
+}
+
+// cappedBuffer captures writes up to max bytes and silently discards the rest, so a chatty command can't exhaust memory
+// nor block
+type cappedBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+// Write implements io.Writer and always reports the full length as written
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	remaining := b.max - b.buf.Len()
+	if remaining < len(p) {
+		b.truncated = true
+
+		if remaining > 0 {
+			b.buf.Write(p[:remaining])
+		}
+
+		return len(p), nil
+	}
+
+	return b.buf.Write(p)
+}
+
+// Close implements io.Closer
+func (b *cappedBuffer) Close() error {
+	return nil
+}
+
+// Bytes returns the captured bytes
+func (b *cappedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+// Truncated reports if writes were discarded
+func (b *cappedBuffer) Truncated() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.truncated
+}
+
+// ExecSync runs the command in the container without stdin and blocks till it terminated and all output was received.
+// If timeout is greater than zero and is reached, the command is killed and ErrExecTimeout is returned together with
+// the output captured so far. Each output stream is captured up to maxOutput bytes, if zero or less
+// DefaultExecSyncMaxOutput is used
+func (l *LXO) ExecSync(ctx context.Context, id string, cmd []string, timeout time.Duration, maxOutput int) (*ExecSyncResult, error) {
+	ctx, span := startSpan(ctx, "exec")
+
+	res, err := l.execSync(ctx, id, cmd, timeout, maxOutput)
+
+	span.SetAttributes(attribute.Int("lxd.exec.exit_code", int(res.ExitCode)))
+	tracing.End(span, err)
+
+	return res, err
+}
+
+// execSync runs the command and captures its output, see ExecSync
+func (l *LXO) execSync(ctx context.Context, id string, cmd []string, timeout time.Duration, maxOutput int) (*ExecSyncResult, error) {
+	if maxOutput <= 0 {
+		maxOutput = DefaultExecSyncMaxOutput
+	}
+
+	stdout := &cappedBuffer{max: maxOutput}
+	stderr := &cappedBuffer{max: maxOutput}
+	res := &ExecSyncResult{ExitCode: CodeExecError}
+
+	var (
+		controlMu sync.Mutex
+		control   *websocket.Conn
+	)
+
+	args := &lxd.ContainerExecArgs{
+		// an empty stdin is closed immediately, so commands reading from it don't block
+		Stdin:  ioutil.NopCloser(bytes.NewReader(nil)),
+		Stdout: stdout,
+		Stderr: stderr,
+		Control: func(conn *websocket.Conn) {
+			controlMu.Lock()
+			control = conn
+			controlMu.Unlock()
+		},
+		DataDone: make(chan bool),
+	}
+
+	req := api.ContainerExecPost{
+		Command:     cmd,
+		WaitForWS:   true,
+		Interactive: false,
+	}
+
+	var op lxd.Operation
+
+	// only starting the command is retried, a command which ran might not be idempotent
+	err := l.retry(ctx, "exec", l.conf.Retries, func(int) error {
+		var err error
+
+		op, err = l.server.ExecContainer(id, req, args)
+
+		return err
+	})
+	if err != nil {
+		return res, err
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		deadline = timer.C
+	}
+
+	collect := func() {
+		res.Stdout = stdout.Bytes()
+		res.Stderr = stderr.Bytes()
+		res.Truncated = stdout.Truncated() || stderr.Truncated()
+	}
+
+	kill := func() error {
+		controlMu.Lock()
+		err := sendSignal(control, unix.SIGKILL)
+		controlMu.Unlock()
+
+		if err != nil {
+			return fmt.Errorf("unable to kill command: %w", err)
+		}
+
+		return l.awaitKilled(op, args.DataDone)
+	}
+
+	select {
+	case <-deadline:
+		err = kill()
+
+		collect()
+		res.ExitCode = CodeExecTimeout
+
+		if err != nil {
+			return res, fmt.Errorf("%w after %s, %v", ErrExecTimeout, timeout, err)
+		}
+
+		return res, fmt.Errorf("%w after %s", ErrExecTimeout, timeout)
+	case <-ctx.Done():
+		// the caller is gone, the command must not outlive it
+		err = kill()
+		if err != nil {
+			log.WithError(err).WithField("containerid", id).Warn("unable to end exec of cancelled call")
+		}
+
+		collect()
+
+		return res, ctx.Err()
+	case <-args.DataDone:
+	}
+
+	err = l.wait(ctx, "exec", attached{op})
+
+	collect()
+
+	if err != nil {
+		return res, err
+	}
+
+	code, ok := op.Get().Metadata["return"].(float64)
+	if !ok {
+		return res, fmt.Errorf("%w: %#v", ErrExecParse, op.Get().Metadata["return"])
+	}
+
+	res.ExitCode = int32(code)
+
+	return res, nil
+}
+
+// awaitKilled waits a bounded time for the output streams and the operation of the killed command to end, so all its
+// output is collected and it doesn't outlive the call unnoticed. The operation is only waited for once the output
+// streams ended
+func (l *LXO) awaitKilled(op lxd.Operation, dataDone <-chan bool) error {
+	timer := time.NewTimer(l.conf.ExecKillWait)
+	defer timer.Stop()
+
+	select {
+	case <-dataDone:
+	case <-timer.C:
+		return fmt.Errorf("%w within %s", ErrExecNotKilled, l.conf.ExecKillWait)
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- op.Wait()
+	}()
+
+	select {
+	case <-done:
+		// the operation of a killed command fails or reports the signal, both are expected
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("%w within %s", ErrExecNotKilled, l.conf.ExecKillWait)
+	}
+}
+
+// sendSignal forwards the signal to the command with the LXD exec control socket
+func sendSignal(control *websocket.Conn, sig unix.Signal) error {
+	if control == nil {
+		return ErrNoControlSocket
+	}
+
+	msg := api.ContainerExecControl{
+		Command: "signal",
+		Signal:  int(sig),
+	}
+
+	buf, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return control.WriteMessage(websocket.TextMessage, buf)
+}
@@ -0,0 +1,156 @@
+package lxo
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_cappedBuffer(t *testing.T) {
+	t.Parallel()
+
+	b := &cappedBuffer{max: 5}
+
+	n, err := b.Write([]byte("abc"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.False(t, b.Truncated())
+
+	n, err = b.Write([]byte("defgh"))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.True(t, b.Truncated())
+	assert.Equal(t, []byte("abcde"), b.Bytes())
+}
+
+func TestLXO_ExecSync_Simple(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fakeOp := &lxdfakes.FakeOperation{}
+	fakeOp.GetReturns(api.Operation{Metadata: map[string]interface{}{"return": float64(3)}})
+
+	fake.ExecContainerStub = func(id string, req api.ContainerExecPost, args *lxd.ContainerExecArgs) (lxd.Operation, error) {
+		_, _ = args.Stdout.Write([]byte("out"))
+		_, _ = args.Stderr.Write([]byte("err"))
+		close(args.DataDone)
+
+		return fakeOp, nil
+	}
+
+	res, err := lxo.ExecSync(ctx, "foo", []string{"ls"}, time.Second, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), res.ExitCode)
+	assert.Equal(t, []byte("out"), res.Stdout)
+	assert.Equal(t, []byte("err"), res.Stderr)
+	assert.False(t, res.Truncated)
+
+	id, req, _ := fake.ExecContainerArgsForCall(0)
+	assert.Equal(t, "foo", id)
+	assert.Equal(t, []string{"ls"}, req.Command)
+	assert.False(t, req.Interactive)
+}
+
+func TestLXO_ExecSync_Truncated(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fakeOp := &lxdfakes.FakeOperation{}
+	fakeOp.GetReturns(api.Operation{Metadata: map[string]interface{}{"return": float64(0)}})
+
+	fake.ExecContainerStub = func(id string, req api.ContainerExecPost, args *lxd.ContainerExecArgs) (lxd.Operation, error) {
+		_, _ = args.Stdout.Write([]byte("0123456789"))
+		close(args.DataDone)
+
+		return fakeOp, nil
+	}
+
+	res, err := lxo.ExecSync(ctx, "foo", []string{"ls"}, 0, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("0123"), res.Stdout)
+	assert.True(t, res.Truncated)
+}
+
+func TestLXO_ExecSync_Timeout(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fake.ExecContainerReturns(&lxdfakes.FakeOperation{}, nil)
+
+	res, err := lxo.ExecSync(ctx, "foo", []string{"sleep", "10"}, 10*time.Millisecond, 0)
+	assert.True(t, errors.Is(err, ErrExecTimeout))
+	assert.Equal(t, CodeExecTimeout, res.ExitCode)
+}
+
+func TestLXO_ExecSync_OperationError(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fakeOp := &lxdfakes.FakeOperation{}
+	fakeOp.WaitReturns(errors.New("failed"))
+
+	fake.ExecContainerStub = func(id string, req api.ContainerExecPost, args *lxd.ContainerExecArgs) (lxd.Operation, error) {
+		close(args.DataDone)
+
+		return fakeOp, nil
+	}
+
+	res, err := lxo.ExecSync(ctx, "foo", []string{"ls"}, time.Second, 0)
+	assert.Error(t, err)
+	assert.Equal(t, CodeExecError, res.ExitCode)
+}
+
+func TestLXO_ExecSync_InvalidExitCode(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fakeOp := &lxdfakes.FakeOperation{}
+	fakeOp.GetReturns(api.Operation{Metadata: map[string]interface{}{}})
+
+	fake.ExecContainerStub = func(id string, req api.ContainerExecPost, args *lxd.ContainerExecArgs) (lxd.Operation, error) {
+		close(args.DataDone)
+
+		return fakeOp, nil
+	}
+
+	_, err := lxo.ExecSync(ctx, "foo", []string{"ls"}, time.Second, 0)
+	assert.True(t, errors.Is(err, ErrExecParse))
+}
+
+func TestLXO_awaitKilled(t *testing.T) {
+	t.Parallel()
+
+	lxo, _ := newFakeClient()
+	lxo.conf.ExecKillWait = 10 * time.Millisecond
+
+	fakeOp := &lxdfakes.FakeOperation{}
+	fakeOp.WaitReturns(errors.New("killed"))
+
+	dataDone := make(chan bool)
+	close(dataDone)
+
+	assert.NoError(t, lxo.awaitKilled(fakeOp, dataDone))
+	assert.Equal(t, 1, fakeOp.WaitCallCount())
+
+	// the output streams didn't end
+	err := lxo.awaitKilled(fakeOp, make(chan bool))
+	assert.True(t, errors.Is(err, ErrExecNotKilled))
+	assert.Equal(t, 1, fakeOp.WaitCallCount())
+
+	// the operation didn't end
+	block := make(chan struct{})
+	defer close(block)
+
+	fakeOp.WaitStub = func() error {
+		<-block
+		return nil
+	}
+
+	err = lxo.awaitKilled(fakeOp, dataDone)
+	assert.True(t, errors.Is(err, ErrExecNotKilled))
+}
@@ -1,30 +1,68 @@
 package lxo // import "github.com/automaticserver/lxe/lxf/lxo"
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/automaticserver/lxe/tracing"
 	lxd "github.com/lxc/lxd/client"
 	"github.com/lxc/lxd/shared/api"
 )
 
 // CopyImage copies an image from the specified server and wait till operation is done or
 // return an error
-func (l *LXO) CopyImage(source lxd.ImageServer, image api.Image, args *lxd.ImageCopyArgs) error {
+func (l *LXO) CopyImage(ctx context.Context, source lxd.ImageServer, image api.Image, args *lxd.ImageCopyArgs) error {
+	ctx, span := startSpan(ctx, "image-copy")
+
 	op, err := l.server.CopyImage(source, image, args)
-	if err != nil {
-		return err
+	if err == nil {
+		err = l.wait(ctx, "image-copy", op)
 	}
 
-	err = op.Wait()
+	tracing.End(span, err)
 
 	return err
 }
 
-// DeleteImage deletes an image and wait till operation is done or
-// return an error
-func (l *LXO) DeleteImage(hash string) error {
-	op, err := l.server.DeleteImage(hash)
+// ErrImageFingerprint is returned if the operation of a created image doesn't report its fingerprint
+var ErrImageFingerprint = errors.New("unable to parse image fingerprint")
+
+// CreateImage uploads an image from the files in args, waits till operation is done and returns the fingerprint of
+// the created image or an error
+func (l *LXO) CreateImage(ctx context.Context, image api.ImagesPost, args *lxd.ImageCreateArgs) (string, error) {
+	ctx, span := startSpan(ctx, "image-create")
+
+	fingerprint, err := l.createImage(ctx, image, args)
+
+	tracing.End(span, err)
+
+	return fingerprint, err
+}
+
+func (l *LXO) createImage(ctx context.Context, image api.ImagesPost, args *lxd.ImageCreateArgs) (string, error) {
+	op, err := l.server.CreateImage(image, args)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	err = l.wait(ctx, "image-create", op)
+	if err != nil {
+		return "", err
+	}
+
+	fingerprint, ok := op.Get().Metadata["fingerprint"].(string)
+	if !ok || fingerprint == "" {
+		return "", fmt.Errorf("%w: %#v", ErrImageFingerprint, op.Get().Metadata["fingerprint"])
 	}
 
-	return op.Wait()
+	return fingerprint, nil
+}
+
+// DeleteImage deletes an image and wait till operation is done or
+// return an error
+func (l *LXO) DeleteImage(ctx context.Context, hash string) error {
+	return l.do(ctx, "image-delete", func() (waiter, error) {
+		return l.server.DeleteImage(hash)
+	})
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	lxd "github.com/lxc/lxd/client"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/stretchr/testify/assert"
 )
@@ -19,7 +20,7 @@ func TestLXO_CopyImage_Simple(t *testing.T) {
 	fake.CopyImageReturns(fakeOp, nil)
 	fakeOp.WaitReturns(nil)
 
-	err := lxo.CopyImage(sourceFake, api.Image{}, nil)
+	err := lxo.CopyImage(ctx, sourceFake, api.Image{}, nil)
 	assert.NoError(t, err)
 
 	assert.Equal(t, 1, fake.CopyImageCallCount())
@@ -35,7 +36,7 @@ func TestLXO_CopyImage_Error(t *testing.T) {
 
 	fake.CopyImageReturns(fakeOp, errors.New("something failed"))
 
-	err := lxo.CopyImage(sourceFake, api.Image{}, nil)
+	err := lxo.CopyImage(ctx, sourceFake, api.Image{}, nil)
 	assert.Error(t, err)
 
 	assert.Equal(t, 1, fake.CopyImageCallCount())
@@ -51,7 +52,7 @@ func TestLXO_DeleteImage_Simple(t *testing.T) {
 	fake.DeleteImageReturns(fakeOp, nil)
 	fakeOp.WaitReturns(nil)
 
-	err := lxo.DeleteImage("foo")
+	err := lxo.DeleteImage(ctx, "foo")
 	assert.NoError(t, err)
 
 	assert.Equal(t, 1, fake.DeleteImageCallCount())
@@ -66,9 +67,56 @@ func TestLXO_DeleteImage_Error(t *testing.T) {
 
 	fake.DeleteImageReturns(fakeOp, errors.New("something failed"))
 
-	err := lxo.DeleteImage("foo")
+	err := lxo.DeleteImage(ctx, "foo")
 	assert.Error(t, err)
 
 	assert.Equal(t, 1, fake.DeleteImageCallCount())
 	assert.Equal(t, 0, fakeOp.WaitCallCount())
 }
+
+func TestLXO_CreateImage_Simple(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fakeOp := &lxdfakes.FakeOperation{}
+
+	fake.CreateImageReturns(fakeOp, nil)
+	fakeOp.WaitReturns(nil)
+	fakeOp.GetReturns(api.Operation{Metadata: map[string]interface{}{"fingerprint": "abc"}})
+
+	fingerprint, err := lxo.CreateImage(ctx, api.ImagesPost{}, &lxd.ImageCreateArgs{})
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", fingerprint)
+
+	assert.Equal(t, 1, fake.CreateImageCallCount())
+	assert.Equal(t, 1, fakeOp.WaitCallCount())
+}
+
+func TestLXO_CreateImage_Error(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fakeOp := &lxdfakes.FakeOperation{}
+
+	fake.CreateImageReturns(fakeOp, errors.New("something failed"))
+
+	_, err := lxo.CreateImage(ctx, api.ImagesPost{}, &lxd.ImageCreateArgs{})
+	assert.Error(t, err)
+
+	assert.Equal(t, 1, fake.CreateImageCallCount())
+	assert.Equal(t, 0, fakeOp.WaitCallCount())
+}
+
+func TestLXO_CreateImage_NoFingerprint(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fakeOp := &lxdfakes.FakeOperation{}
+
+	fake.CreateImageReturns(fakeOp, nil)
+	fakeOp.WaitReturns(nil)
+	fakeOp.GetReturns(api.Operation{Metadata: map[string]interface{}{}})
+
+	_, err := lxo.CreateImage(ctx, api.ImagesPost{}, &lxd.ImageCreateArgs{})
+	assert.True(t, errors.Is(err, ErrImageFingerprint))
+}
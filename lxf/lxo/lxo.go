@@ -1,18 +1,184 @@
 package lxo // import "github.com/automaticserver/lxe/lxf/lxo"
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/automaticserver/lxe/logging"
+	"github.com/automaticserver/lxe/metrics"
+	"github.com/automaticserver/lxe/tracing"
 	lxd "github.com/lxc/lxd/client"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultWorkers is the default number of operations a batch runs concurrently
+	DefaultWorkers = 8
+	// DefaultExecKillWait is the default time a killed exec is waited for
+	DefaultExecKillWait = 5 * time.Second
 )
 
+var (
+	ErrOperationTimeout = errors.New("operation timed out")
+	log                 = logging.Subsystem("lxo")
+)
+
+// Conf contains the settings how LXO runs operations
+type Conf struct {
+	// Workers is the number of operations a batch runs concurrently
+	Workers int
+	// Timeout of a single operation after which it's cancelled, 0 waits till it's done
+	Timeout time.Duration
+	// Retries of an operation failing with a retryable error, 0 disables them
+	Retries int
+	// RetryBackoff is the backoff before the first retry, it doubles with every further retry
+	RetryBackoff time.Duration
+	// ExecKillWait is how long an exec killed on timeout or cancellation is waited for to end
+	ExecKillWait time.Duration
+}
+
+func (c *Conf) setDefaults() {
+	if c.Workers <= 0 {
+		c.Workers = DefaultWorkers
+	}
+
+	if c.Retries < 0 {
+		c.Retries = 0
+	}
+
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = DefaultRetryBackoff
+	}
+
+	if c.ExecKillWait <= 0 {
+		c.ExecKillWait = DefaultExecKillWait
+	}
+}
+
 // LXO abstracts some of the lxd calls with additional functionality like retrying, idempotency
 // and some level of error recovery. Usage stays the same as lxd.ContainerServer
 type LXO struct {
 	server lxd.ContainerServer
+	conf   Conf
+	// resume waits for a new connection, nil if interrupted operations aren't resumed
+	resume Resumer
 }
 
 // New creates LXO
-func NewClient(server lxd.ContainerServer) *LXO {
+func NewClient(server lxd.ContainerServer, conf Conf) *LXO {
+	conf.setDefaults()
+
 	return &LXO{
 		server: server,
+		conf:   conf,
+	}
+}
+
+// startSpan starts the span of the LXD operation, the retries of the operation are recorded as its events
+func startSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "lxd "+operation, attribute.String("lxd.operation", operation))
+}
+
+// waiter is implemented by lxd.Operation and lxd.RemoteOperation
+type waiter interface {
+	Wait() error
+}
+
+// canceler is implemented by lxd.Operation, though LXD refuses to cancel most of the operations
+type canceler interface {
+	Cancel() error
+}
+
+// wait waits till the operation is done, the timeout is reached or ctx is done and observes its duration. In the
+// latter cases the operation is cancelled
+func (l *LXO) wait(ctx context.Context, operation string, op waiter) error {
+	pending.add()
+	defer pending.done()
+
+	observe(ctx, op)
+
+	start := time.Now()
+	err := l.waitDone(ctx, op)
+
+	metrics.LXDOperationDuration.WithLabelValues(operation, metrics.Result(err)).Observe(metrics.Since(start))
+
+	return err
+}
+
+func (l *LXO) waitDone(ctx context.Context, op waiter) error {
+	if l.conf.Timeout <= 0 && ctx.Done() == nil {
+		return l.waitResumed(ctx, op)
+	}
+
+	// a resume still waiting for the connection is given up with the caller
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- l.waitResumed(ctx, op)
+	}()
+
+	var timeout <-chan time.Time
+
+	if l.conf.Timeout > 0 {
+		timer := time.NewTimer(l.conf.Timeout)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	var err error
+
+	select {
+	case err = <-done:
+		return err
+	case <-timeout:
+		err = fmt.Errorf("%w after %v", ErrOperationTimeout, l.conf.Timeout)
+	case <-ctx.Done():
+		err = ctx.Err()
 	}
+
+	// if it can't be cancelled the operation still continues in LXD, but we don't block the caller any longer
+	if c, ok := op.(canceler); ok {
+		_ = c.Cancel()
+	}
+
+	return err
+}
+
+// Batch calls fn for every index from 0 to n-1 with at most Conf.Workers running concurrently and returns when all of
+// them are done. A failed call doesn't stop the others, the error of the lowest index is returned
+func (l *LXO) Batch(n int, fn func(i int) error) error {
+	errs := make([]error, n)
+	sem := make(chan struct{}, l.conf.Workers)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			errs[i] = fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
@@ -1,18 +1,25 @@
 package lxo
 
 import (
+	"context"
+	"errors"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/stretchr/testify/assert"
 )
 
+var (
+	ctx = context.TODO()
+)
+
 func newFakeClient() (*LXO, *lxdfakes.FakeContainerServer) {
 	fake := &lxdfakes.FakeContainerServer{}
 
-	return &LXO{
-		server: fake,
-	}, fake
+	return NewClient(fake, Conf{RetryBackoff: time.Millisecond}), fake
 }
 
 func TestNewClient(t *testing.T) {
@@ -20,8 +27,124 @@ func TestNewClient(t *testing.T) {
 
 	fake := &lxdfakes.FakeContainerServer{}
 
-	lxo := NewClient(fake)
+	lxo := NewClient(fake, Conf{})
 	assert.NotNil(t, lxo)
 
 	assert.Exactly(t, fake, lxo.server)
+	assert.Equal(t, DefaultWorkers, lxo.conf.Workers)
+}
+
+func TestLXO_wait_Timeout(t *testing.T) {
+	t.Parallel()
+
+	lxo := NewClient(&lxdfakes.FakeContainerServer{}, Conf{Timeout: 10 * time.Millisecond})
+
+	release := make(chan struct{})
+	defer close(release)
+
+	op := &lxdfakes.FakeOperation{}
+	op.WaitStub = func() error {
+		<-release
+		return nil
+	}
+
+	err := lxo.wait(ctx, "stop", op)
+	assert.True(t, errors.Is(err, ErrOperationTimeout))
+	assert.Equal(t, 1, op.CancelCallCount())
+}
+
+func TestLXO_wait_NoTimeout(t *testing.T) {
+	t.Parallel()
+
+	lxo, _ := newFakeClient()
+
+	op := &lxdfakes.FakeOperation{}
+	op.WaitReturns(errors.New("failed"))
+
+	err := lxo.wait(ctx, "stop", op)
+	assert.EqualError(t, err, "failed")
+	assert.Equal(t, 0, op.CancelCallCount())
+}
+
+func TestLXO_wait_ContextDone(t *testing.T) {
+	t.Parallel()
+
+	lxo, _ := newFakeClient()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	op := &lxdfakes.FakeOperation{}
+	op.WaitStub = func() error {
+		<-release
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := lxo.wait(ctx, "create", op)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, 1, op.CancelCallCount())
+}
+
+func TestLXO_Batch(t *testing.T) {
+	t.Parallel()
+
+	lxo := NewClient(&lxdfakes.FakeContainerServer{}, Conf{Workers: 2})
+
+	var running, max, calls int32
+
+	err := lxo.Batch(6, func(i int) error {
+		atomic.AddInt32(&calls, 1)
+
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+
+		time.Sleep(5 * time.Millisecond)
+
+		if i == 1 || i == 4 {
+			return errors.New("failed " + string(rune('0'+i)))
+		}
+
+		return nil
+	})
+
+	assert.EqualError(t, err, "failed 1")
+	assert.Equal(t, int32(6), calls, "a failure doesn't stop the others")
+	assert.LessOrEqual(t, max, int32(2))
+}
+
+func TestLXO_Batch_Empty(t *testing.T) {
+	t.Parallel()
+
+	lxo, _ := newFakeClient()
+
+	assert.NoError(t, lxo.Batch(0, func(i int) error {
+		t.Fail()
+		return nil
+	}))
+}
+
+func TestLXO_wait_Observer(t *testing.T) {
+	t.Parallel()
+
+	lxo, _ := newFakeClient()
+
+	op := &lxdfakes.FakeOperation{}
+	op.GetReturns(api.Operation{ID: "op1"})
+
+	ids := []string{}
+	obsCtx := WithOperationObserver(ctx, func(id string) { ids = append(ids, id) })
+
+	assert.NoError(t, lxo.wait(obsCtx, "start", op))
+	assert.NoError(t, lxo.wait(ctx, "start", op))
+	assert.Equal(t, []string{"op1"}, ids)
 }
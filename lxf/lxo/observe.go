@@ -0,0 +1,40 @@
+package lxo // import "github.com/automaticserver/lxe/lxf/lxo"
+
+import (
+	"context"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// OperationObserver is called with the ID of every LXD operation waited for, before the wait starts
+type OperationObserver func(id string)
+
+type observerKey struct{}
+
+// WithOperationObserver returns a context whose LXD operations are reported to fn, e.g. to journal them so they can be
+// waited for after a restart
+func WithOperationObserver(ctx context.Context, fn OperationObserver) context.Context {
+	return context.WithValue(ctx, observerKey{}, fn)
+}
+
+// getter is implemented by lxd.Operation
+type getter interface {
+	Get() api.Operation
+}
+
+// observe reports the operation to the observer of ctx, if there is one. RemoteOperations have no ID on the server
+func observe(ctx context.Context, op waiter) {
+	fn, ok := ctx.Value(observerKey{}).(OperationObserver)
+	if !ok || fn == nil {
+		return
+	}
+
+	o, ok := op.(getter)
+	if !ok {
+		return
+	}
+
+	if id := o.Get().ID; id != "" {
+		fn(id)
+	}
+}
@@ -0,0 +1,205 @@
+package lxo // import "github.com/automaticserver/lxe/lxf/lxo"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/automaticserver/lxe/shared"
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// resumePollTimeout is how long LXD is asked to block for a resumed operation, it has to stay below the timeout of the
+// http client
+const resumePollTimeout = 5
+
+var ErrOperationLost = errors.New("operation lost with the connection to LXD")
+
+// Resumer waits till the connection to LXD lost on stale is established again and returns the new server
+type Resumer func(ctx context.Context, stale lxd.ContainerServer) (lxd.ContainerServer, error)
+
+// SetResumer sets the function waiting for a new connection, operations interrupted by a lost connection are then
+// re-attached on the new one instead of failing. Without one they fail with the error of the connection
+func (l *LXO) SetResumer(r Resumer) {
+	l.resume = r
+}
+
+// attached wraps an operation with websockets attached to the caller, like exec's streams. It isn't resumed as the
+// websockets are gone with the connection anyway
+type attached struct {
+	lxd.Operation
+}
+
+// interrupted returns the ID of the operation if its wait failed because the connection to LXD was lost while it was
+// still running. RemoteOperations span several servers and are never resumed
+func interrupted(op waiter) (string, bool) {
+	if _, ok := op.(attached); ok {
+		return "", false
+	}
+
+	o, ok := op.(lxd.Operation)
+	if !ok {
+		return "", false
+	}
+
+	// the lxd client fails the wait with the error of the event listener, the operation itself didn't finish
+	status := o.Get()
+	if status.ID == "" || status.StatusCode.IsFinal() {
+		return "", false
+	}
+
+	return status.ID, true
+}
+
+// lostError is returned if LXD doesn't know the operation anymore, server is the connection it was looked up on
+type lostError struct {
+	err    error
+	server lxd.ContainerServer
+}
+
+func (e *lostError) Error() string {
+	return e.err.Error()
+}
+
+func (e *lostError) Unwrap() error {
+	return e.err
+}
+
+// waitResumed waits till the operation is done. If the connection is lost meanwhile, it waits for the new connection
+// and re-attaches to the operation there. If LXD restarted in between, the operation is gone and ErrOperationLost is
+// returned, as it's unknown whether it took effect
+func (l *LXO) waitResumed(ctx context.Context, op waiter) error {
+	err := op.Wait()
+	if err == nil || l.resume == nil {
+		return err
+	}
+
+	id, ok := interrupted(op)
+	if !ok {
+		return err
+	}
+
+	log := log.WithField("operation", id)
+	log.WithError(err).Warn("lost connection to LXD while waiting for operation, resuming")
+
+	server, rerr := l.resume(ctx, l.server)
+
+	for {
+		if rerr != nil {
+			return fmt.Errorf("%w: %v", rerr, err)
+		}
+
+		status, _, gerr := server.GetOperationWait(id, resumePollTimeout)
+		if gerr != nil {
+			if shared.IsErrNotFound(gerr) {
+				return &lostError{err: fmt.Errorf("%w: %s: %v", ErrOperationLost, id, err), server: server}
+			}
+
+			// the new connection might be lost as well, try again on the one after it
+			log.WithError(gerr).Warn("failed resuming operation")
+
+			server, rerr = l.resume(ctx, server)
+
+			continue
+		}
+
+		if status.StatusCode.IsFinal() {
+			log.Info("resumed operation")
+
+			if status.Err != "" {
+				return errors.New(status.Err) // nolint: goerr113
+			}
+
+			return nil
+		}
+
+		// still running, ask again till it's done
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+}
+
+// check reports whether the operation took effect by looking at the state on server, e.g. of the container
+type check func(server lxd.ContainerServer) (bool, error)
+
+// settle determines the result of the operation whose wait failed with err. If the wait was interrupted, e.g. as the
+// event websocket dropped, LXD is asked for the operation. If LXD lost the operation, done checks whether it took
+// effect, without a check it fails with ErrOperationLost
+func (l *LXO) settle(ctx context.Context, op waiter, err error, done check) error {
+	if !errors.Is(err, ErrOperationLost) {
+		id, ok := interrupted(op)
+		if !ok || !IsRetryable(err) {
+			return err
+		}
+
+		werr := err
+
+		err = l.retry(ctx, "operation", l.conf.Retries, func(int) error {
+			status, _, gerr := l.server.GetOperationWait(id, resumePollTimeout)
+			if gerr != nil {
+				if shared.IsErrNotFound(gerr) {
+					return &lostError{err: fmt.Errorf("%w: %s: %v", ErrOperationLost, id, werr), server: l.server}
+				}
+
+				return gerr
+			}
+
+			// still running, ask again
+			if !status.StatusCode.IsFinal() {
+				return &retryableError{err: werr}
+			}
+
+			if status.Err != "" {
+				return errors.New(status.Err) // nolint: goerr113
+			}
+
+			return nil
+		})
+	}
+
+	var lost *lostError
+	if done == nil || !errors.As(err, &lost) {
+		return err
+	}
+
+	ok, cerr := done(lost.server)
+	if cerr != nil || !ok {
+		return err
+	}
+
+	log.WithError(err).Info("lost operation took effect")
+
+	return nil
+}
+
+// containerState returns the check whether the container is in the state
+func containerState(id string, code api.StatusCode) check {
+	return func(server lxd.ContainerServer) (bool, error) {
+		state, _, err := server.GetContainerState(id)
+		if err != nil {
+			return false, err
+		}
+
+		return state.StatusCode == code, nil
+	}
+}
+
+// containerExists returns the check whether the container exists like expected
+func containerExists(id string, expected bool) check {
+	return func(server lxd.ContainerServer) (bool, error) {
+		_, _, err := server.GetContainer(id)
+		if err != nil {
+			if shared.IsErrNotFound(err) {
+				return !expected, nil
+			}
+
+			return false, err
+		}
+
+		return expected, nil
+	}
+}
@@ -0,0 +1,190 @@
+package lxo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	"github.com/automaticserver/lxe/shared"
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// interruptedOp returns an operation whose wait failed because the event listener got disconnected while it was running
+func interruptedOp() *lxdfakes.FakeOperation {
+	op := &lxdfakes.FakeOperation{}
+	op.WaitReturns(errors.New("websocket: close 1006 (abnormal closure): unexpected EOF"))
+	op.GetReturns(api.Operation{ID: "abc", StatusCode: api.Running})
+
+	return op
+}
+
+// resumeClient returns a client resuming on the new server, it counts the resumes
+func resumeClient() (*LXO, *lxdfakes.FakeContainerServer, *lxdfakes.FakeContainerServer, *int) {
+	lxo, stale := newFakeClient()
+	server := &lxdfakes.FakeContainerServer{}
+	resumes := 0
+
+	lxo.SetResumer(func(ctx context.Context, s lxd.ContainerServer) (lxd.ContainerServer, error) {
+		resumes++
+
+		return server, nil
+	})
+
+	return lxo, stale, server, &resumes
+}
+
+func TestLXO_wait_Resume(t *testing.T) {
+	t.Parallel()
+
+	lxo, _, server, resumes := resumeClient()
+
+	server.GetOperationWaitReturnsOnCall(0, &api.Operation{ID: "abc", StatusCode: api.Running}, "", nil)
+	server.GetOperationWaitReturnsOnCall(1, &api.Operation{ID: "abc", StatusCode: api.Success}, "", nil)
+
+	err := lxo.wait(ctx, "create", interruptedOp())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, *resumes)
+	assert.Equal(t, 2, server.GetOperationWaitCallCount())
+
+	id, _ := server.GetOperationWaitArgsForCall(0)
+	assert.Equal(t, "abc", id)
+
+	// the operation fails on its own after resuming
+	server.GetOperationWaitReturns(&api.Operation{ID: "abc", StatusCode: api.Failure, Err: "no space left"}, "", nil)
+
+	err = lxo.wait(ctx, "create", interruptedOp())
+	assert.EqualError(t, err, "no space left")
+}
+
+func TestLXO_wait_ResumeLost(t *testing.T) {
+	t.Parallel()
+
+	lxo, _, server, resumes := resumeClient()
+
+	// the new connection fails once as well, then LXD doesn't know the operation anymore after its restart
+	server.GetOperationWaitReturnsOnCall(0, nil, "", errors.New("connection refused"))
+	server.GetOperationWaitReturnsOnCall(1, nil, "", shared.NewErrNotFound())
+
+	err := lxo.wait(ctx, "create", interruptedOp())
+	assert.True(t, errors.Is(err, ErrOperationLost))
+	assert.Equal(t, 2, *resumes)
+}
+
+func TestLXO_wait_ResumeFailed(t *testing.T) {
+	t.Parallel()
+
+	lxo, _ := newFakeClient()
+	lxo.SetResumer(func(ctx context.Context, s lxd.ContainerServer) (lxd.ContainerServer, error) {
+		return nil, context.Canceled
+	})
+
+	err := lxo.wait(ctx, "create", interruptedOp())
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestLXO_wait_NoResume(t *testing.T) {
+	t.Parallel()
+
+	lxo, _, server, resumes := resumeClient()
+
+	// the operation failed on its own
+	failed := &lxdfakes.FakeOperation{}
+	failed.WaitReturns(errors.New("failed"))
+	failed.GetReturns(api.Operation{ID: "abc", StatusCode: api.Failure})
+
+	// the streams of exec are gone with the connection
+	exec := attached{interruptedOp()}
+
+	// a remote operation spans several servers
+	remote := &lxdfakes.FakeRemoteOperation{}
+	remote.WaitReturns(errors.New("websocket: close 1006"))
+
+	for _, op := range []waiter{failed, exec, remote} {
+		err := lxo.wait(ctx, "create", op)
+		assert.Error(t, err)
+	}
+
+	assert.Zero(t, *resumes)
+	assert.Zero(t, server.GetOperationWaitCallCount())
+
+	// without a resumer the error of the connection is returned
+	lxo, _ = newFakeClient()
+
+	err := lxo.wait(ctx, "create", interruptedOp())
+	assert.EqualError(t, err, "websocket: close 1006 (abnormal closure): unexpected EOF")
+}
+
+func TestLXO_do_WaitNotRetried(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	lxo.conf.Retries = 3
+
+	// LXD accepted the request, so it's never sent again
+	fakeOp := interruptedOp()
+	fake.DeleteContainerReturns(fakeOp, nil)
+	fake.GetOperationWaitReturns(&api.Operation{ID: "abc", StatusCode: api.Failure, Err: "busy"}, "", nil)
+
+	err := lxo.DeleteContainer(ctx, "foo")
+	assert.EqualError(t, err, "busy")
+	assert.Equal(t, 1, fake.DeleteContainerCallCount())
+
+	id, _ := fake.GetOperationWaitArgsForCall(0)
+	assert.Equal(t, "abc", id)
+}
+
+func TestLXO_do_Settled(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	lxo.conf.Retries = 1
+
+	// the event websocket dropped while the operation was still running
+	fake.UpdateContainerStateReturns(interruptedOp(), nil)
+	fake.GetOperationWaitReturnsOnCall(0, &api.Operation{ID: "abc", StatusCode: api.Running}, "", nil)
+	fake.GetOperationWaitReturnsOnCall(1, &api.Operation{ID: "abc", StatusCode: api.Success}, "", nil)
+
+	err := lxo.StartContainer(ctx, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.UpdateContainerStateCallCount())
+	assert.Equal(t, 2, fake.GetOperationWaitCallCount())
+}
+
+func TestLXO_do_LostTookEffect(t *testing.T) {
+	t.Parallel()
+
+	lxo, stale, server, _ := resumeClient()
+
+	stale.DeleteContainerReturns(interruptedOp(), nil)
+	server.GetOperationWaitReturns(nil, "", shared.NewErrNotFound())
+	server.GetContainerReturns(nil, "", shared.NewErrNotFound())
+
+	err := lxo.DeleteContainer(ctx, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stale.DeleteContainerCallCount())
+	assert.Equal(t, "foo", server.GetContainerArgsForCall(0))
+}
+
+func TestLXO_do_LostWithoutEffect(t *testing.T) {
+	t.Parallel()
+
+	lxo, stale, server, _ := resumeClient()
+
+	stale.UpdateContainerStateReturns(interruptedOp(), nil)
+	server.GetOperationWaitReturns(nil, "", shared.NewErrNotFound())
+	server.GetContainerStateReturns(&api.ContainerState{StatusCode: api.Stopped}, "", nil)
+
+	err := lxo.StartContainer(ctx, "foo")
+	assert.True(t, errors.Is(err, ErrOperationLost))
+	assert.Equal(t, 1, stale.UpdateContainerStateCallCount())
+
+	// without a check the result stays unknown
+	stale.UpdateContainerReturns(interruptedOp(), nil)
+
+	err = lxo.UpdateContainer(ctx, "foo", api.ContainerPut{}, "")
+	assert.True(t, errors.Is(err, ErrOperationLost))
+	assert.Equal(t, 1, stale.UpdateContainerCallCount())
+}
@@ -0,0 +1,173 @@
+package lxo // import "github.com/automaticserver/lxe/lxf/lxo"
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/automaticserver/lxe/metrics"
+	"github.com/automaticserver/lxe/tracing"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultRetries is the default number of retries of an operation failing with a retryable error
+	DefaultRetries = 3
+	// DefaultRetryBackoff is the default backoff before the first retry, it doubles with every further retry
+	DefaultRetryBackoff = 500 * time.Millisecond
+	// retryMaxBackoff caps the exponential backoff
+	retryMaxBackoff = 30 * time.Second
+)
+
+// retryableMessages are parts of LXD error messages which are worth a retry as the conflicting state is temporary
+var retryableMessages = []string{
+	"is busy running",     // another operation holds the lock of the container
+	"database is locked",  // concurrent writes to the LXD database
+	"websocket: close",    // the operation or event websocket dropped
+	"connection reset by", // LXD restarted or the socket was closed
+}
+
+// retryableError forces a retry of the error regardless of its classification
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// IsRetryable returns true if the error is temporary, like LXD being busy with the same container or a dropped
+// connection, so the operation is worth a retry
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var forced *retryableError
+	if errors.As(err, &forced) {
+		return true
+	}
+
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		return true
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	// the lxd client doesn't wrap most of the errors, so the message is all there is
+	msg := err.Error()
+	for _, m := range retryableMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retry calls fn till it succeeds or fails with an error which isn't retryable, but at most retries+1 times. Between
+// the attempts it backs off exponentially with jitter. If ctx is done while backing off, the last error is returned.
+// The attempt passed to fn starts at 0
+func (l *LXO) retry(ctx context.Context, operation string, retries int, fn func(attempt int) error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn(attempt)
+		if err == nil || attempt >= retries || !IsRetryable(err) {
+			if r, ok := err.(*retryableError); ok { // nolint: errorlint
+				return r.err
+			}
+
+			return err
+		}
+
+		metrics.LXDOperationRetries.WithLabelValues(operation).Inc()
+
+		backoff := l.backoff(attempt)
+
+		log.WithContext(ctx).WithError(err).WithFields(logrus.Fields{
+			"operation": operation,
+			"attempt":   attempt + 1,
+			"backoff":   backoff,
+		}).Debug("retrying LXD operation")
+
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt+1),
+			attribute.String("error", err.Error()),
+		))
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the duration to wait before the retry following attempt. It doubles with every attempt and is
+// jittered between half and the full duration, so concurrent retries don't hit LXD at the same time again
+func (l *LXO) backoff(attempt int) time.Duration {
+	d := l.conf.RetryBackoff
+	for i := 0; i < attempt && d < retryMaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > retryMaxBackoff {
+		d = retryMaxBackoff
+	}
+
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+
+	// jitter doesn't need a secure random
+	return time.Duration(half + rand.Int63n(half)) // nolint: gosec
+}
+
+// do sends the request and waits till its operation is done. Only sending the request is retried if it fails with a
+// retryable error, once LXD accepted it the operation might take effect anyway. So the result of a failed wait is
+// determined with settle instead
+func (l *LXO) do(ctx context.Context, operation string, req func() (waiter, error)) error {
+	return l.doChecked(ctx, operation, req, nil)
+}
+
+// doChecked is like do, if LXD lost the operation done checks whether it took effect
+func (l *LXO) doChecked(ctx context.Context, operation string, req func() (waiter, error), done check) error {
+	ctx, span := startSpan(ctx, operation)
+
+	var op waiter
+
+	err := l.retry(ctx, operation, l.conf.Retries, func(int) error {
+		var err error
+		op, err = req()
+
+		return err
+	})
+	if err == nil {
+		err = l.wait(ctx, operation, op)
+		if err != nil {
+			err = l.settle(ctx, op, err, done)
+		}
+	}
+
+	tracing.End(span, err)
+
+	return err
+}
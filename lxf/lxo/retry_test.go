@@ -0,0 +1,135 @@
+package lxo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetryable(t *testing.T) {
+	t.Parallel()
+
+	for _, err := range []error{
+		errors.New("Container is busy running a start operation"),
+		errors.New("Failed to update container: database is locked"),
+		&websocket.CloseError{Code: websocket.CloseAbnormalClosure},
+		fmt.Errorf("read: %w", io.ErrUnexpectedEOF),
+		fmt.Errorf("dial: %w", syscall.ECONNREFUSED),
+		&retryableError{err: errors.New("any")},
+	} {
+		assert.True(t, IsRetryable(err), err.Error())
+	}
+
+	for _, err := range []error{
+		nil,
+		errors.New("not found"),
+		errors.New("Failed to create container: already exists"),
+		ErrOperationTimeout,
+	} {
+		assert.False(t, IsRetryable(err))
+	}
+}
+
+func TestLXO_retry(t *testing.T) {
+	t.Parallel()
+
+	lxo, _ := newFakeClient()
+	calls := 0
+
+	err := lxo.retry(context.Background(), "test", 3, func(attempt int) error {
+		assert.Equal(t, calls, attempt)
+		calls++
+
+		if attempt < 2 {
+			return errors.New("database is locked")
+		}
+
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestLXO_retry_NotRetryable(t *testing.T) {
+	t.Parallel()
+
+	lxo, _ := newFakeClient()
+	calls := 0
+
+	err := lxo.retry(context.Background(), "test", 3, func(int) error {
+		calls++
+		return errors.New("not found")
+	})
+	assert.EqualError(t, err, "not found")
+	assert.Equal(t, 1, calls)
+}
+
+func TestLXO_retry_Exhausted(t *testing.T) {
+	t.Parallel()
+
+	lxo, _ := newFakeClient()
+	calls := 0
+
+	err := lxo.retry(context.Background(), "test", 2, func(int) error {
+		calls++
+		return &retryableError{err: errors.New("still failing")}
+	})
+	assert.EqualError(t, err, "still failing")
+	assert.False(t, IsRetryable(err), "the forced retry is removed from the returned error")
+	assert.Equal(t, 3, calls)
+}
+
+func TestLXO_retry_ContextDone(t *testing.T) {
+	t.Parallel()
+
+	lxo := NewClient(&lxdfakes.FakeContainerServer{}, Conf{RetryBackoff: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+
+	err := lxo.retry(ctx, "test", 3, func(int) error {
+		calls++
+		return errors.New("database is locked")
+	})
+	assert.EqualError(t, err, "database is locked")
+	assert.Equal(t, 1, calls)
+}
+
+func TestLXO_backoff(t *testing.T) {
+	t.Parallel()
+
+	lxo := NewClient(&lxdfakes.FakeContainerServer{}, Conf{RetryBackoff: time.Second})
+
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		d := lxo.backoff(attempt)
+		assert.GreaterOrEqual(t, int64(d), int64(max/2))
+		assert.Less(t, int64(d), int64(max))
+	}
+
+	assert.LessOrEqual(t, int64(lxo.backoff(100)), int64(retryMaxBackoff))
+}
+
+func TestLXO_DeleteContainer_Retry(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	lxo.conf.Retries = 1
+
+	fakeOp := &lxdfakes.FakeOperation{}
+	fake.DeleteContainerReturnsOnCall(0, nil, errors.New("Container is busy running a stop operation"))
+	fake.DeleteContainerReturnsOnCall(1, fakeOp, nil)
+
+	err := lxo.DeleteContainer(ctx, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fake.DeleteContainerCallCount())
+	assert.Equal(t, 1, fakeOp.WaitCallCount())
+}
@@ -0,0 +1,40 @@
+package lxo // import "github.com/automaticserver/lxe/lxf/lxo"
+
+import (
+	"context"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// SnapshotContainer will create a snapshot of the container and wait till operation is done or return an error. A
+// stateful snapshot also saves the runtime state of a running container, which requires CRIU
+func (l *LXO) SnapshotContainer(ctx context.Context, id, name string, stateful bool) error {
+	return l.do(ctx, "snapshot", func() (waiter, error) {
+		return l.server.CreateContainerSnapshot(id, api.ContainerSnapshotsPost{
+			Name:     name,
+			Stateful: stateful,
+		})
+	})
+}
+
+// RestoreSnapshot will restore the container to the snapshot and wait till operation is done or return an error
+func (l *LXO) RestoreSnapshot(ctx context.Context, id, name string, stateful bool) error {
+	return l.do(ctx, "snapshot-restore", func() (waiter, error) {
+		return l.server.UpdateContainer(id, api.ContainerPut{
+			Restore:  name,
+			Stateful: stateful,
+		}, "")
+	})
+}
+
+// ListSnapshots returns the snapshots of the container
+func (l *LXO) ListSnapshots(id string) ([]api.ContainerSnapshot, error) {
+	return l.server.GetContainerSnapshots(id)
+}
+
+// DeleteSnapshot will delete the snapshot of the container and wait till operation is done or return an error
+func (l *LXO) DeleteSnapshot(ctx context.Context, id, name string) error {
+	return l.do(ctx, "snapshot-delete", func() (waiter, error) {
+		return l.server.DeleteContainerSnapshot(id, name)
+	})
+}
@@ -0,0 +1,72 @@
+package lxo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLXO_SnapshotContainer(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fakeOp := &lxdfakes.FakeOperation{}
+
+	fake.CreateContainerSnapshotReturns(fakeOp, nil)
+
+	err := lxo.SnapshotContainer(ctx, "foo", "snap0", true)
+	assert.NoError(t, err)
+
+	id, req := fake.CreateContainerSnapshotArgsForCall(0)
+	assert.Equal(t, "foo", id)
+	assert.Equal(t, api.ContainerSnapshotsPost{Name: "snap0", Stateful: true}, req)
+	assert.Equal(t, 1, fakeOp.WaitCallCount())
+}
+
+func TestLXO_SnapshotContainer_Error(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+
+	fake.CreateContainerSnapshotReturns(nil, errors.New("something failed"))
+
+	err := lxo.SnapshotContainer(ctx, "foo", "snap0", false)
+	assert.Error(t, err)
+}
+
+func TestLXO_RestoreSnapshot(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fakeOp := &lxdfakes.FakeOperation{}
+
+	fake.UpdateContainerReturns(fakeOp, nil)
+
+	err := lxo.RestoreSnapshot(ctx, "foo", "snap0", false)
+	assert.NoError(t, err)
+
+	id, put, _ := fake.UpdateContainerArgsForCall(0)
+	assert.Equal(t, "foo", id)
+	assert.Equal(t, "snap0", put.Restore)
+	assert.Equal(t, 1, fakeOp.WaitCallCount())
+}
+
+func TestLXO_DeleteSnapshot(t *testing.T) {
+	t.Parallel()
+
+	lxo, fake := newFakeClient()
+	fakeOp := &lxdfakes.FakeOperation{}
+
+	fake.DeleteContainerSnapshotReturns(fakeOp, nil)
+
+	err := lxo.DeleteSnapshot(ctx, "foo", "snap0")
+	assert.NoError(t, err)
+
+	id, name := fake.DeleteContainerSnapshotArgsForCall(0)
+	assert.Equal(t, "foo", id)
+	assert.Equal(t, "snap0", name)
+	assert.Equal(t, 1, fakeOp.WaitCallCount())
+}
@@ -0,0 +1,558 @@
+//go:build lxdfake
+// +build lxdfake
+
+package memlxd // import "github.com/automaticserver/lxe/lxf/memlxd"
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// container is a container with its files and snapshots. It has no processes, running only sets its state
+type container struct {
+	api.Container
+	etag      string
+	files     *filesystem
+	snapshots []*snapshot
+	pid       int64
+	// addresses are the ipv4 addresses of the nics while running
+	addresses map[string]string
+	console   []byte
+}
+
+// snapshot is a copy of the config and files of a container
+type snapshot struct {
+	api.ContainerSnapshot
+	files *filesystem
+}
+
+// getContainer returns the container, s.mu must be held
+func (s *Server) getContainer(name string) (*container, error) {
+	c, has := s.containers[name]
+	if !has {
+		return nil, notFound()
+	}
+
+	return c, nil
+}
+
+// expand sets the expanded config and devices of the container: the ones of its profiles in order, then its own
+func (s *Server) expand(c *api.Container) {
+	c.ExpandedConfig = map[string]string{}
+	c.ExpandedDevices = map[string]map[string]string{}
+
+	for _, name := range c.Profiles {
+		p, has := s.profiles[name]
+		if !has {
+			continue
+		}
+
+		for k, v := range p.Config {
+			c.ExpandedConfig[k] = v
+		}
+
+		for k, v := range p.Devices {
+			c.ExpandedDevices[k] = v
+		}
+	}
+
+	for k, v := range c.Config {
+		c.ExpandedConfig[k] = v
+	}
+
+	for k, v := range c.Devices {
+		c.ExpandedDevices[k] = v
+	}
+}
+
+// checkProfiles fails if one of the profiles doesn't exist
+func (s *Server) checkProfiles(profiles []string) error {
+	for _, name := range profiles {
+		if _, has := s.profiles[name]; !has {
+			return fmt.Errorf("profile %s: %w", name, notFound())
+		}
+	}
+
+	return nil
+}
+
+func source(name string) string {
+	return "/1.0/containers/" + name
+}
+
+// CreateContainer creates the stopped container from the image of the source or empty for the source type none
+func (s *Server) CreateContainer(req api.ContainersPost) (lxd.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, has := s.containers[req.Name]; has {
+		return nil, fmt.Errorf("container %s %w", req.Name, ErrAlreadyExists)
+	}
+
+	files := newFilesystem()
+
+	if req.Source.Type != "none" {
+		fingerprint := req.Source.Fingerprint
+		if fingerprint == "" {
+			fingerprint = s.aliases[req.Source.Alias].Target
+		}
+
+		img, has := s.images[fingerprint]
+		if !has {
+			return nil, fmt.Errorf("image %s: %w", fingerprint+req.Source.Alias, notFound())
+		}
+
+		if img.files != nil {
+			files = img.files.copy()
+		}
+	}
+
+	if req.Profiles == nil {
+		req.Profiles = []string{"default"}
+	}
+
+	err := s.checkProfiles(req.Profiles)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	c := &container{
+		Container: api.Container{
+			ContainerPut: clonePut(req.ContainerPut),
+			CreatedAt:    now,
+			Name:         req.Name,
+			Status:       api.Stopped.String(),
+			StatusCode:   api.Stopped,
+			Location:     "none",
+		},
+		etag:  s.nextETag(),
+		files: files,
+	}
+
+	if c.Architecture == "" {
+		c.Architecture = "x86_64"
+	}
+
+	s.expand(&c.Container)
+	s.containers[req.Name] = c
+	s.emit("container-created", source(req.Name))
+
+	return s.ops.done("task", nil, nil), nil
+}
+
+// GetContainer returns the container and its ETag
+func (s *Server) GetContainer(name string) (*api.Container, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.getContainer(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ct := cloneContainer(c.Container)
+
+	return &ct, c.etag, nil
+}
+
+// GetContainers returns all containers sorted by name
+func (s *Server) GetContainers() ([]api.Container, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cts := make([]api.Container, 0, len(s.containers))
+	for _, c := range s.containers {
+		cts = append(cts, cloneContainer(c.Container))
+	}
+
+	sort.Slice(cts, func(i, j int) bool { return cts[i].Name < cts[j].Name })
+
+	return cts, nil
+}
+
+// UpdateContainer replaces the config of the container, or restores the snapshot if Restore is set
+func (s *Server) UpdateContainer(name string, put api.ContainerPut, etag string) (lxd.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.getContainer(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if put.Restore != "" {
+		return s.ops.done("task", nil, s.restore(c, put.Restore)), nil
+	}
+
+	err = checkETag(c.etag, etag)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkProfiles(put.Profiles)
+	if err != nil {
+		return nil, err
+	}
+
+	if put.Architecture == "" {
+		put.Architecture = c.Architecture
+	}
+
+	c.ContainerPut = clonePut(put)
+	c.etag = s.nextETag()
+	s.expand(&c.Container)
+	s.emit("container-updated", source(name))
+
+	return s.ops.done("task", nil, nil), nil
+}
+
+// DeleteContainer deletes the stopped container
+func (s *Server) DeleteContainer(name string) (lxd.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.getContainer(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.StatusCode != api.Stopped {
+		return nil, ErrStillRunning
+	}
+
+	delete(s.containers, name)
+	s.emit("container-deleted", source(name))
+
+	return s.ops.done("task", nil, nil), nil
+}
+
+// UpdateContainerState starts, stops, freezes or unfreezes the container. A forced stop is reported as stopped, a
+// graceful one as shut down, like LXD does
+func (s *Server) UpdateContainerState(name string, state api.ContainerStatePut, etag string) (lxd.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.getContainer(name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch state.Action {
+	case "start":
+		if c.StatusCode != api.Stopped {
+			return s.ops.done("task", nil, ErrAlreadyRunning), nil
+		}
+
+		s.pids++
+		c.pid = 1000 + s.pids
+		c.addresses = s.assignAddresses(c)
+		c.LastUsedAt = time.Now()
+		s.setStatus(c, api.Running)
+		c.console = append(c.console, fmt.Sprintf("memlxd: container %s started\n", name)...)
+		s.emit("container-started", source(name))
+	case "stop":
+		if c.StatusCode == api.Stopped {
+			return s.ops.done("task", nil, ErrAlreadyStopped), nil
+		}
+
+		c.pid = 0
+		c.addresses = nil
+		s.setStatus(c, api.Stopped)
+
+		if state.Force {
+			s.emit("container-stopped", source(name))
+		} else {
+			s.emit("container-shutdown", source(name))
+		}
+
+		if c.Ephemeral {
+			delete(s.containers, name)
+			s.emit("container-deleted", source(name))
+		}
+	case "freeze":
+		if c.StatusCode != api.Running {
+			return s.ops.done("task", nil, ErrNotRunning), nil
+		}
+
+		s.setStatus(c, api.Frozen)
+		s.emit("container-paused", source(name))
+	case "unfreeze":
+		if c.StatusCode != api.Frozen {
+			return s.ops.done("task", nil, ErrNotRunning), nil
+		}
+
+		s.setStatus(c, api.Running)
+		s.emit("container-resumed", source(name))
+	default:
+		return nil, fmt.Errorf("action %s: %w", state.Action, ErrUnsupported)
+	}
+
+	return s.ops.done("task", nil, nil), nil
+}
+
+// setStatus changes the status of the container, which changes its ETag as well
+func (s *Server) setStatus(c *container, code api.StatusCode) {
+	c.StatusCode = code
+	c.Status = code.String()
+	c.etag = s.nextETag()
+}
+
+// assignAddresses returns the ipv4 addresses of the nics of the container on managed networks. A nic gets its
+// configured address or the first free one of the subnet, like the dhcp of LXD would assign it
+func (s *Server) assignAddresses(c *container) map[string]string {
+	addresses := map[string]string{}
+
+	names := make([]string, 0, len(c.ExpandedDevices))
+	for name := range c.ExpandedDevices {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		dev := c.ExpandedDevices[name]
+		if dev["type"] != "nic" {
+			continue
+		}
+
+		if ip := dev["ipv4.address"]; ip != "" {
+			addresses[name] = ip
+			continue
+		}
+
+		n, has := s.networks[dev["network"]]
+		if !has {
+			n, has = s.networks[dev["parent"]]
+		}
+
+		if has {
+			if ip := s.freeAddress(n); ip != "" {
+				addresses[name] = ip
+			}
+		}
+	}
+
+	return addresses
+}
+
+// GetContainerState returns the state of the container. A running one has one process, its pid and the addresses of
+// its nics
+func (s *Server) GetContainerState(name string) (*api.ContainerState, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.getContainer(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	state := &api.ContainerState{
+		Status:     c.Status,
+		StatusCode: c.StatusCode,
+		Disk:       map[string]api.ContainerStateDisk{"root": {Usage: c.files.size()}},
+		Network:    map[string]api.ContainerStateNetwork{},
+	}
+
+	if c.StatusCode == api.Stopped {
+		return state, "", nil
+	}
+
+	state.Pid = c.pid
+	state.Processes = 1
+	state.CPU.Usage = time.Since(c.LastUsedAt).Nanoseconds() / 1000 // nolint: gomnd
+	state.Memory.Usage = 16 << 20
+	state.Memory.UsagePeak = state.Memory.Usage
+	state.Network["lo"] = api.ContainerStateNetwork{
+		Addresses: []api.ContainerStateNetworkAddress{{Family: "inet", Address: "127.0.0.1", Netmask: "8", Scope: "local"}},
+		State:     "up",
+		Type:      "loopback",
+	}
+
+	for dev, ip := range c.addresses {
+		netmask := "24"
+		if n := s.networkOf(ip); n != nil {
+			ones, _ := n.subnet.Mask.Size()
+			netmask = fmt.Sprint(ones)
+		}
+
+		iface := c.ExpandedDevices[dev]["name"]
+		if iface == "" {
+			iface = dev
+		}
+
+		state.Network[iface] = api.ContainerStateNetwork{
+			Addresses: []api.ContainerStateNetworkAddress{{Family: "inet", Address: ip, Netmask: netmask, Scope: "global"}},
+			Hwaddr:    c.ExpandedDevices[dev]["hwaddr"],
+			HostName:  "veth" + dev + fmt.Sprint(c.pid),
+			Mtu:       1500, // nolint: gomnd
+			State:     "up",
+			Type:      "broadcast",
+		}
+	}
+
+	return state, "", nil
+}
+
+// MigrateContainer isn't supported, the server isn't clustered
+func (s *Server) MigrateContainer(name string, container api.ContainerPost) (lxd.Operation, error) {
+	return nil, ErrNotClustered
+}
+
+// CreateContainerSnapshot copies the config and files of the container
+func (s *Server) CreateContainerSnapshot(name string, req api.ContainerSnapshotsPost) (lxd.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.getContainer(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, snap := range c.snapshots {
+		if snap.Name == req.Name {
+			return nil, fmt.Errorf("snapshot %s/%s %w", name, req.Name, ErrAlreadyExists)
+		}
+	}
+
+	c.snapshots = append(c.snapshots, &snapshot{
+		ContainerSnapshot: api.ContainerSnapshot{
+			ContainerSnapshotPut: api.ContainerSnapshotPut{
+				Architecture: c.Architecture,
+				Config:       cloneConfig(c.Config),
+				Devices:      cloneDevices(c.Devices),
+				Ephemeral:    c.Ephemeral,
+				Profiles:     c.Profiles,
+			},
+			CreatedAt:       time.Now(),
+			ExpandedConfig:  c.ExpandedConfig,
+			ExpandedDevices: c.ExpandedDevices,
+			Name:            req.Name,
+			Stateful:        req.Stateful,
+		},
+		files: c.files.copy(),
+	})
+	s.emit("container-snapshot-created", source(name)+"/snapshots/"+req.Name)
+
+	return s.ops.done("task", nil, nil), nil
+}
+
+// GetContainerSnapshots returns the snapshots of the container in the order they were taken
+func (s *Server) GetContainerSnapshots(name string) ([]api.ContainerSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.getContainer(name)
+	if err != nil {
+		return nil, err
+	}
+
+	snaps := make([]api.ContainerSnapshot, 0, len(c.snapshots))
+	for _, snap := range c.snapshots {
+		snap := snap.ContainerSnapshot
+		snap.ContainerSnapshotPut.Config = cloneConfig(snap.Config)
+		snap.ContainerSnapshotPut.Devices = cloneDevices(snap.Devices)
+		snaps = append(snaps, snap)
+	}
+
+	return snaps, nil
+}
+
+// DeleteContainerSnapshot deletes the snapshot of the container
+func (s *Server) DeleteContainerSnapshot(name string, snapshotName string) (lxd.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.getContainer(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, snap := range c.snapshots {
+		if snap.Name == snapshotName {
+			c.snapshots = append(c.snapshots[:i], c.snapshots[i+1:]...)
+			s.emit("container-snapshot-deleted", source(name)+"/snapshots/"+snapshotName)
+
+			return s.ops.done("task", nil, nil), nil
+		}
+	}
+
+	return nil, notFound()
+}
+
+// restore replaces the config and files of the container with the ones of the snapshot
+func (s *Server) restore(c *container, name string) error {
+	for _, snap := range c.snapshots {
+		if snap.Name != name {
+			continue
+		}
+
+		c.Architecture = snap.Architecture
+		c.Config = cloneConfig(snap.Config)
+		c.Devices = cloneDevices(snap.Devices)
+		c.Ephemeral = snap.Ephemeral
+		c.Profiles = snap.Profiles
+		c.files = snap.files.copy()
+		c.etag = s.nextETag()
+		s.expand(&c.Container)
+		s.emit("container-snapshot-restored", source(c.Name))
+
+		return nil
+	}
+
+	return fmt.Errorf("snapshot %s/%s: %w", c.Name, name, notFound())
+}
+
+// networkOf returns the network the ip is in, s.mu must be held
+func (s *Server) networkOf(ip string) *network {
+	addr := net.ParseIP(ip)
+
+	for _, n := range s.networks {
+		if n.subnet != nil && n.subnet.Contains(addr) {
+			return n
+		}
+	}
+
+	return nil
+}
+
+// clonePut copies the config, devices and profiles, so the caller can't change the stored ones
+func clonePut(put api.ContainerPut) api.ContainerPut {
+	put.Config = cloneConfig(put.Config)
+	put.Devices = cloneDevices(put.Devices)
+	put.Profiles = append([]string(nil), put.Profiles...)
+
+	return put
+}
+
+// cloneContainer copies the container with its maps
+func cloneContainer(c api.Container) api.Container {
+	c.ContainerPut = clonePut(c.ContainerPut)
+	c.ExpandedConfig = cloneConfig(c.ExpandedConfig)
+	c.ExpandedDevices = cloneDevices(c.ExpandedDevices)
+
+	return c
+}
+
+func cloneConfig(config map[string]string) map[string]string {
+	clone := make(map[string]string, len(config))
+	for k, v := range config {
+		clone[k] = v
+	}
+
+	return clone
+}
+
+func cloneDevices(devices map[string]map[string]string) map[string]map[string]string {
+	clone := make(map[string]map[string]string, len(devices))
+	for k, v := range devices {
+		clone[k] = cloneConfig(v)
+	}
+
+	return clone
+}
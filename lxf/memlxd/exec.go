@@ -0,0 +1,470 @@
+//go:build lxdfake
+// +build lxdfake
+
+package memlxd // import "github.com/automaticserver/lxe/lxf/memlxd"
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// The exit codes of the shell
+const (
+	exitFailure  = 1
+	exitNotFound = 127
+)
+
+// ExecContainer runs the command in the running container. There are no processes, it's interpreted by a minimal shell
+// knowing the builtins cat, echo, env, exit, false, hostname, ls, mkdir, pwd, rm, sleep, test, touch and true. sh -c
+// runs a script of them separated by ; && and ||, with $VAR expansion and > and >> redirects to files. Other commands
+// aren't found and exit with 127. There's no control connection, the commands can't be signalled
+func (s *Server) ExecContainer(name string, req api.ContainerExecPost, args *lxd.ContainerExecArgs) (lxd.Operation, error) {
+	s.mu.Lock()
+
+	c, err := s.getContainer(name)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+
+	if c.StatusCode != api.Running {
+		s.mu.Unlock()
+		return nil, ErrNotRunning
+	}
+
+	env := map[string]string{"PATH": "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin", "HOME": "/root"}
+
+	for k, v := range c.ExpandedConfig {
+		if strings.HasPrefix(k, "environment.") {
+			env[strings.TrimPrefix(k, "environment.")] = v
+		}
+	}
+
+	s.mu.Unlock()
+
+	for k, v := range req.Environment {
+		env[k] = v
+	}
+
+	if args == nil {
+		args = &lxd.ContainerExecArgs{}
+	}
+
+	sh := &shell{s: s, name: name, env: env, stdin: args.Stdin, stdout: args.Stdout, stderr: args.Stderr}
+	op := s.ops.start("websocket")
+
+	go func() {
+		code := sh.run(req.Command)
+
+		if args.Stdin != nil {
+			args.Stdin.Close()
+		}
+
+		if args.DataDone != nil {
+			close(args.DataDone)
+		}
+
+		op.finish(map[string]interface{}{"return": float64(code)}, nil)
+	}()
+
+	return op, nil
+}
+
+// shell interprets commands in the container
+type shell struct {
+	s      *Server
+	name   string
+	env    map[string]string
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// run runs the command, sh -c runs the script
+func (sh *shell) run(cmd []string) int {
+	if len(cmd) == 0 {
+		return 0
+	}
+
+	switch path.Base(cmd[0]) {
+	case "sh", "bash", "ash":
+		if len(cmd) < 3 || cmd[1] != "-c" {
+			fmt.Fprintln(sh.stderrW(), "memlxd: only sh -c is supported")
+			return exitFailure
+		}
+
+		return sh.script(cmd[2])
+	}
+
+	return sh.builtin(cmd, sh.stdoutW())
+}
+
+// script runs the statements of the script
+func (sh *shell) script(script string) int {
+	tokens := tokenize(os.Expand(script, func(k string) string { return sh.env[k] }))
+	code := 0
+	skip := false
+	cmd := []string{}
+
+	flush := func() {
+		if len(cmd) > 0 && !skip {
+			code = sh.simple(cmd)
+		}
+
+		cmd = []string{}
+	}
+
+	for _, t := range tokens {
+		if !t.op || t.text == ">" || t.text == ">>" {
+			cmd = append(cmd, t.text)
+			continue
+		}
+
+		flush()
+
+		switch t.text {
+		case ";":
+			skip = false
+		case "&&":
+			skip = code != 0
+		case "||":
+			skip = code == 0
+		}
+	}
+
+	flush()
+
+	return code
+}
+
+// simple runs a command with an optional redirect of its output to a file
+func (sh *shell) simple(cmd []string) int {
+	for i, arg := range cmd {
+		if arg != ">" && arg != ">>" {
+			continue
+		}
+
+		if i+1 >= len(cmd) {
+			fmt.Fprintln(sh.stderrW(), "sh: syntax error: missing redirect target")
+			return 2 // nolint: gomnd
+		}
+
+		out := &bytes.Buffer{}
+		code := sh.builtin(append(cmd[:i:i], cmd[i+2:]...), out)
+
+		sh.s.mu.Lock()
+		defer sh.s.mu.Unlock()
+
+		c, err := sh.s.getContainer(sh.name)
+		if err != nil {
+			fmt.Fprintln(sh.stderrW(), err)
+			return exitFailure
+		}
+
+		c.files.write(sh.abs(cmd[i+1]), out.Bytes(), arg == ">>")
+
+		return code
+	}
+
+	return sh.builtin(cmd, sh.stdoutW())
+}
+
+// builtin runs the builtin command writing its output to stdout
+func (sh *shell) builtin(cmd []string, stdout io.Writer) int { // nolint: gocyclo
+	if len(cmd) == 0 {
+		return 0
+	}
+
+	args := cmd[1:]
+
+	switch path.Base(cmd[0]) {
+	case "true", ":":
+		return 0
+	case "false":
+		return exitFailure
+	case "exit":
+		if len(args) == 0 {
+			return 0
+		}
+
+		code, err := strconv.Atoi(args[0])
+		if err != nil {
+			return 2 // nolint: gomnd
+		}
+
+		return code
+	case "echo":
+		newline := "\n"
+		if len(args) > 0 && args[0] == "-n" {
+			newline, args = "", args[1:]
+		}
+
+		fmt.Fprint(stdout, strings.Join(args, " ")+newline)
+
+		return 0
+	case "pwd":
+		fmt.Fprintln(stdout, "/")
+		return 0
+	case "hostname":
+		fmt.Fprintln(stdout, sh.name)
+		return 0
+	case "env", "printenv":
+		keys := make([]string, 0, len(sh.env))
+		for k := range sh.env {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			fmt.Fprintf(stdout, "%s=%s\n", k, sh.env[k])
+		}
+
+		return 0
+	case "sleep":
+		if len(args) > 0 {
+			secs, err := strconv.ParseFloat(args[0], 64)
+			if err != nil {
+				fmt.Fprintf(sh.stderrW(), "sleep: invalid number '%s'\n", args[0])
+				return exitFailure
+			}
+
+			time.Sleep(time.Duration(secs * float64(time.Second)))
+		}
+
+		return 0
+	case "cat":
+		return sh.cat(args, stdout)
+	case "ls":
+		return sh.ls(args, stdout)
+	case "test", "[":
+		return sh.test(args)
+	case "touch", "mkdir", "rm":
+		return sh.change(path.Base(cmd[0]), args)
+	}
+
+	fmt.Fprintf(sh.stderrW(), "sh: %s: not found\n", cmd[0])
+
+	return exitNotFound
+}
+
+// cat copies the files or stdin to stdout
+func (sh *shell) cat(args []string, stdout io.Writer) int {
+	if len(args) == 0 {
+		if sh.stdin != nil {
+			_, _ = io.Copy(stdout, sh.stdin)
+		}
+
+		return 0
+	}
+
+	code := 0
+
+	for _, p := range args {
+		content, _, err := sh.s.GetContainerFile(sh.name, sh.abs(p))
+		if err != nil || content == nil {
+			fmt.Fprintf(sh.stderrW(), "cat: can't open '%s': No such file or directory\n", p)
+			code = exitFailure
+
+			continue
+		}
+
+		b, _ := ioutil.ReadAll(content)
+		_, _ = stdout.Write(b)
+	}
+
+	return code
+}
+
+// ls lists the entries of the directories, or the files themselves
+func (sh *shell) ls(args []string, stdout io.Writer) int {
+	if len(args) == 0 {
+		args = []string{"/"}
+	}
+
+	code := 0
+
+	for _, p := range args {
+		_, resp, err := sh.s.GetContainerFile(sh.name, sh.abs(p))
+		if err != nil {
+			fmt.Fprintf(sh.stderrW(), "ls: %s: No such file or directory\n", p)
+			code = exitFailure
+
+			continue
+		}
+
+		if resp.Type != "directory" {
+			fmt.Fprintln(stdout, p)
+			continue
+		}
+
+		for _, e := range resp.Entries {
+			fmt.Fprintln(stdout, e)
+		}
+	}
+
+	return code
+}
+
+// test supports -e, -f and -d of a path and = and != of strings
+func (sh *shell) test(args []string) int {
+	if len(args) > 0 && args[len(args)-1] == "]" {
+		args = args[:len(args)-1]
+	}
+
+	result := false
+
+	switch {
+	case len(args) == 2: // nolint: gomnd
+		_, resp, err := sh.s.GetContainerFile(sh.name, sh.abs(args[1]))
+
+		switch args[0] {
+		case "-e":
+			result = err == nil
+		case "-f":
+			result = err == nil && resp.Type == "file"
+		case "-d":
+			result = err == nil && resp.Type == "directory"
+		}
+	case len(args) == 3 && args[1] == "=": // nolint: gomnd
+		result = args[0] == args[2]
+	case len(args) == 3 && args[1] == "!=": // nolint: gomnd
+		result = args[0] != args[2]
+	}
+
+	if result {
+		return 0
+	}
+
+	return exitFailure
+}
+
+// change runs touch, mkdir or rm on the paths, options are ignored
+func (sh *shell) change(cmd string, args []string) int {
+	sh.s.mu.Lock()
+	defer sh.s.mu.Unlock()
+
+	c, err := sh.s.getContainer(sh.name)
+	if err != nil {
+		fmt.Fprintln(sh.stderrW(), err)
+		return exitFailure
+	}
+
+	code := 0
+
+	for _, p := range args {
+		if strings.HasPrefix(p, "-") {
+			continue
+		}
+
+		p = sh.abs(p)
+
+		switch cmd {
+		case "touch":
+			if _, has := c.files.get(p); !has {
+				c.files.write(p, nil, false)
+			}
+		case "mkdir":
+			c.files.files[p] = &file{dir: true, mode: 0755}
+		case "rm":
+			if !c.files.remove(p) {
+				fmt.Fprintf(sh.stderrW(), "rm: can't remove '%s': No such file or directory\n", p)
+				code = exitFailure
+			}
+		}
+	}
+
+	return code
+}
+
+// abs returns the absolute path, the working directory is /
+func (sh *shell) abs(p string) string {
+	return path.Clean("/" + p)
+}
+
+func (sh *shell) stdoutW() io.Writer {
+	if sh.stdout == nil {
+		return ioutil.Discard
+	}
+
+	return sh.stdout
+}
+
+func (sh *shell) stderrW() io.Writer {
+	if sh.stderr == nil {
+		return ioutil.Discard
+	}
+
+	return sh.stderr
+}
+
+// token is a word or an operator of a script
+type token struct {
+	text string
+	op   bool
+}
+
+// tokenize splits the script into words and the operators ; && || > and >>. Quotes group words
+func tokenize(script string) []token {
+	tokens := []token{}
+	word := strings.Builder{}
+	inWord := false
+	quote := rune(0)
+
+	flush := func() {
+		if inWord {
+			tokens = append(tokens, token{text: word.String()})
+			word.Reset()
+
+			inWord = false
+		}
+	}
+
+	runes := []rune(script)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+		case r == ';':
+			flush()
+			tokens = append(tokens, token{text: ";", op: true})
+		case (r == '&' || r == '|' || r == '>') && i+1 < len(runes) && runes[i+1] == r:
+			flush()
+			tokens = append(tokens, token{text: string([]rune{r, r}), op: true})
+			i++
+		case r == '>':
+			flush()
+			tokens = append(tokens, token{text: ">", op: true})
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	flush()
+
+	return tokens
+}
@@ -0,0 +1,247 @@
+//go:build lxdfake
+// +build lxdfake
+
+package memlxd // import "github.com/automaticserver/lxe/lxf/memlxd"
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	lxd "github.com/lxc/lxd/client"
+)
+
+// file is a file or directory of a container
+type file struct {
+	content []byte
+	uid     int64
+	gid     int64
+	mode    int
+	dir     bool
+}
+
+// filesystem is the root disk of a container, by absolute and cleaned paths. Parent directories exist implicitly
+type filesystem struct {
+	files map[string]*file
+}
+
+func newFilesystem() *filesystem {
+	return &filesystem{files: map[string]*file{"/": {dir: true, mode: 0755}}}
+}
+
+// copy returns a deep copy, e.g. for a snapshot
+func (fs *filesystem) copy() *filesystem {
+	c := &filesystem{files: make(map[string]*file, len(fs.files))}
+
+	for p, f := range fs.files {
+		cf := *f
+		cf.content = append([]byte(nil), f.content...)
+		c.files[p] = &cf
+	}
+
+	return c
+}
+
+// archive returns the paths, modes and contents of all files sorted by path, as the content of a published image
+func (fs *filesystem) archive() []byte {
+	paths := make([]string, 0, len(fs.files))
+	for p := range fs.files {
+		paths = append(paths, p)
+	}
+
+	sort.Strings(paths)
+
+	b := &bytes.Buffer{}
+
+	for _, p := range paths {
+		f := fs.files[p]
+		fmt.Fprintf(b, "%s %o %d\n", p, f.mode, len(f.content))
+		b.Write(f.content)
+	}
+
+	return b.Bytes()
+}
+
+// size returns the size of the content of all files
+func (fs *filesystem) size() int64 {
+	var size int64
+	for _, f := range fs.files {
+		size += int64(len(f.content))
+	}
+
+	return size
+}
+
+// get returns the file or directory at the path, a parent of an existing path is a directory
+func (fs *filesystem) get(p string) (*file, bool) {
+	p = path.Clean("/" + p)
+
+	if f, has := fs.files[p]; has {
+		return f, true
+	}
+
+	if len(fs.entries(p)) > 0 {
+		return &file{dir: true, mode: 0755}, true
+	}
+
+	return nil, false
+}
+
+// entries returns the sorted names in the directory
+func (fs *filesystem) entries(dir string) []string {
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	names := map[string]bool{}
+
+	for p := range fs.files {
+		if p != "/" && strings.HasPrefix(p, prefix) {
+			names[strings.SplitN(strings.TrimPrefix(p, prefix), "/", 2)[0]] = true
+		}
+	}
+
+	entries := make([]string, 0, len(names))
+	for name := range names {
+		entries = append(entries, name)
+	}
+
+	sort.Strings(entries)
+
+	return entries
+}
+
+// write creates or replaces the file at the path, or appends to it
+func (fs *filesystem) write(p string, content []byte, appendTo bool) {
+	p = path.Clean("/" + p)
+
+	f, has := fs.files[p]
+	if !has || f.dir {
+		f = &file{mode: 0644}
+		fs.files[p] = f
+	}
+
+	if appendTo {
+		f.content = append(f.content, content...)
+	} else {
+		f.content = content
+	}
+}
+
+// remove deletes the path and everything below it
+func (fs *filesystem) remove(p string) bool {
+	p = path.Clean("/" + p)
+	_, found := fs.files[p]
+
+	delete(fs.files, p)
+
+	for other := range fs.files {
+		if strings.HasPrefix(other, p+"/") {
+			delete(fs.files, other)
+
+			found = true
+		}
+	}
+
+	return found
+}
+
+// GetContainerFile returns the content of the file, or the entries of the directory
+func (s *Server) GetContainerFile(name string, p string) (io.ReadCloser, *lxd.ContainerFileResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.getContainer(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, has := c.files.get(p)
+	if !has {
+		return nil, nil, notFound()
+	}
+
+	resp := &lxd.ContainerFileResponse{UID: f.uid, GID: f.gid, Mode: f.mode, Type: "file"}
+
+	if f.dir {
+		resp.Type = "directory"
+		resp.Entries = c.files.entries(path.Clean("/" + p))
+
+		return nil, resp, nil
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(append([]byte(nil), f.content...))), resp, nil
+}
+
+// CreateContainerFile creates or replaces the file or directory, or appends to the file
+func (s *Server) CreateContainerFile(name string, p string, args lxd.ContainerFileArgs) error {
+	var content []byte
+
+	if args.Content != nil {
+		var err error
+
+		content, err = ioutil.ReadAll(args.Content)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.getContainer(name)
+	if err != nil {
+		return err
+	}
+
+	switch args.Type {
+	case "", "file":
+		c.files.write(p, content, args.WriteMode == "append")
+	case "directory":
+		c.files.files[path.Clean("/"+p)] = &file{dir: true}
+	default:
+		return fmt.Errorf("file type %s: %w", args.Type, ErrUnsupported)
+	}
+
+	f := c.files.files[path.Clean("/"+p)]
+	f.uid, f.gid = args.UID, args.GID
+
+	if args.Mode != 0 {
+		f.mode = args.Mode
+	} else if f.dir {
+		f.mode = 0755
+	}
+
+	return nil
+}
+
+// DeleteContainerFile deletes the file or directory
+func (s *Server) DeleteContainerFile(name string, p string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.getContainer(name)
+	if err != nil {
+		return err
+	}
+
+	if !c.files.remove(p) {
+		return notFound()
+	}
+
+	return nil
+}
+
+// GetContainerConsoleLog returns what was written to the console of the container, memlxd only logs its starts
+func (s *Server) GetContainerConsoleLog(name string, args *lxd.ContainerConsoleLogArgs) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.getContainer(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(append([]byte(nil), c.console...))), nil
+}
@@ -0,0 +1,455 @@
+//go:build lxdfake
+// +build lxdfake
+
+package memlxd // import "github.com/automaticserver/lxe/lxf/memlxd"
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// image is an image with its files
+type image struct {
+	api.Image
+	meta   []byte
+	rootfs []byte
+	// files are the files of the container an image was published from, containers created from it start with them
+	files *filesystem
+}
+
+// imageFile writes the files of the image to the request
+func imageFile(img *image, req lxd.ImageFileRequest) (*lxd.ImageFileResponse, error) {
+	resp := &lxd.ImageFileResponse{MetaName: img.Fingerprint + ".tar", MetaSize: int64(len(img.meta))}
+
+	if req.MetaFile != nil {
+		_, err := req.MetaFile.Write(img.meta)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if img.rootfs != nil {
+		resp.RootfsName = img.Fingerprint + ".squashfs"
+		resp.RootfsSize = int64(len(img.rootfs))
+
+		if req.RootfsFile != nil {
+			_, err := req.RootfsFile.Write(img.rootfs)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return resp, nil
+}
+
+// CopyImage copies the image from the source, e.g. a Registry. The files are copied if the source is a Registry or
+// another Server, other sources have to provide them with GetImageFile
+func (s *Server) CopyImage(source lxd.ImageServer, img api.Image, args *lxd.ImageCopyArgs) (lxd.RemoteOperation, error) {
+	remote, _, err := source.GetImage(img.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, rootfs := &buffer{}, &buffer{}
+
+	_, err = source.GetImageFile(img.Fingerprint, lxd.ImageFileRequest{MetaFile: meta, RootfsFile: rootfs})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := &image{Image: *remote, meta: meta.Bytes(), rootfs: rootfs.Bytes()}
+	copied.Cached = true
+	copied.Aliases = nil
+	copied.UploadedAt = time.Now()
+
+	if args != nil {
+		copied.AutoUpdate = args.AutoUpdate
+		copied.Public = args.Public
+
+		if args.CopyAliases {
+			copied.Aliases = remote.Aliases
+		}
+
+		copied.Aliases = append(copied.Aliases, args.Aliases...)
+	}
+
+	s.images[copied.Fingerprint] = copied
+
+	for _, a := range copied.Aliases {
+		s.aliases[a.Name] = aliasEntry(a.Name, copied.Fingerprint)
+	}
+
+	return s.ops.done("task", map[string]interface{}{"fingerprint": copied.Fingerprint}, nil), nil
+}
+
+// CreateImage creates the image from the uploaded files, its fingerprint is the sha256 of the files like LXD has it.
+// With a source of type container or snapshot, the container or its snapshot named container/snapshot is published
+func (s *Server) CreateImage(req api.ImagesPost, args *lxd.ImageCreateArgs) (lxd.Operation, error) {
+	if req.Source != nil && (req.Source.Type == "container" || req.Source.Type == "snapshot") {
+		return s.publish(req)
+	}
+
+	if args == nil || args.MetaFile == nil {
+		return nil, fmt.Errorf("image without files: %w", ErrUnsupported)
+	}
+
+	meta, err := ioutil.ReadAll(args.MetaFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var rootfs []byte
+
+	if args.RootfsFile != nil {
+		rootfs, err = ioutil.ReadAll(args.RootfsFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hash := sha256.New()
+	_, _ = hash.Write(meta)
+	_, _ = hash.Write(rootfs)
+	fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, has := s.images[fingerprint]; has {
+		return s.ops.done("task", nil, fmt.Errorf("image %s %w", fingerprint, ErrAlreadyExists)), nil
+	}
+
+	now := time.Now()
+	s.images[fingerprint] = &image{
+		Image: api.Image{
+			ImagePut:     req.ImagePut,
+			Aliases:      req.Aliases,
+			Architecture: "x86_64",
+			Filename:     req.Filename,
+			Fingerprint:  fingerprint,
+			Size:         int64(len(meta) + len(rootfs)),
+			Type:         "container",
+			CreatedAt:    now,
+			UploadedAt:   now,
+		},
+		meta:   meta,
+		rootfs: rootfs,
+	}
+
+	for _, a := range req.Aliases {
+		s.aliases[a.Name] = aliasEntry(a.Name, fingerprint)
+	}
+
+	s.emit("image-created", "/1.0/images/"+fingerprint)
+
+	return s.ops.done("task", map[string]interface{}{"fingerprint": fingerprint}, nil), nil
+}
+
+// publish creates the image from the files of the container or its snapshot, its fingerprint is the sha256 of them
+func (s *Server) publish(req api.ImagesPost) (lxd.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name, snapshotName := req.Source.Name, ""
+	if i := strings.Index(name, "/"); i >= 0 {
+		name, snapshotName = name[:i], name[i+1:]
+	}
+
+	c, err := s.getContainer(name)
+	if err != nil {
+		return nil, err
+	}
+
+	files := c.files
+
+	if snapshotName != "" {
+		files = nil
+
+		for _, snap := range c.snapshots {
+			if snap.Name == snapshotName {
+				files = snap.files
+			}
+		}
+
+		if files == nil {
+			return nil, fmt.Errorf("snapshot %s: %w", req.Source.Name, notFound())
+		}
+	}
+
+	meta := files.archive()
+	sum := sha256.Sum256(meta)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	if _, has := s.images[fingerprint]; has {
+		return s.ops.done("task", nil, fmt.Errorf("image %s %w", fingerprint, ErrAlreadyExists)), nil
+	}
+
+	now := time.Now()
+	s.images[fingerprint] = &image{
+		Image: api.Image{
+			ImagePut:     req.ImagePut,
+			Aliases:      req.Aliases,
+			Architecture: c.Architecture,
+			Fingerprint:  fingerprint,
+			Size:         int64(len(meta)),
+			Type:         "container",
+			CreatedAt:    now,
+			UploadedAt:   now,
+		},
+		meta:  meta,
+		files: files.copy(),
+	}
+
+	for _, a := range req.Aliases {
+		s.aliases[a.Name] = aliasEntry(a.Name, fingerprint)
+	}
+
+	s.emit("image-created", "/1.0/images/"+fingerprint)
+
+	return s.ops.done("task", map[string]interface{}{"fingerprint": fingerprint}, nil), nil
+}
+
+// DeleteImage deletes the image and its aliases
+func (s *Server) DeleteImage(fingerprint string) (lxd.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, has := s.images[fingerprint]; !has {
+		return nil, notFound()
+	}
+
+	delete(s.images, fingerprint)
+
+	for name, a := range s.aliases {
+		if a.Target == fingerprint {
+			delete(s.aliases, name)
+		}
+	}
+
+	s.emit("image-deleted", "/1.0/images/"+fingerprint)
+
+	return s.ops.done("task", nil, nil), nil
+}
+
+// GetImage returns the image by its fingerprint
+func (s *Server) GetImage(fingerprint string) (*api.Image, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	img, has := s.images[fingerprint]
+	if !has {
+		return nil, "", notFound()
+	}
+
+	i := img.Image
+
+	return &i, "", nil
+}
+
+// GetImages returns all images sorted by fingerprint
+func (s *Server) GetImages() ([]api.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	imgs := make([]api.Image, 0, len(s.images))
+	for _, img := range s.images {
+		imgs = append(imgs, img.Image)
+	}
+
+	sort.Slice(imgs, func(i, j int) bool { return imgs[i].Fingerprint < imgs[j].Fingerprint })
+
+	return imgs, nil
+}
+
+// GetImageFile writes the files of the image to the request
+func (s *Server) GetImageFile(fingerprint string, req lxd.ImageFileRequest) (*lxd.ImageFileResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	img, has := s.images[fingerprint]
+	if !has {
+		return nil, notFound()
+	}
+
+	return imageFile(img, req)
+}
+
+// GetImageAlias returns the alias
+func (s *Server) GetImageAlias(name string) (*api.ImageAliasesEntry, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, has := s.aliases[name]
+	if !has {
+		return nil, "", notFound()
+	}
+
+	return &a, "", nil
+}
+
+// GetImageAliases returns all aliases sorted by name
+func (s *Server) GetImageAliases() ([]api.ImageAliasesEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	aliases := make([]api.ImageAliasesEntry, 0, len(s.aliases))
+	for _, a := range s.aliases {
+		aliases = append(aliases, a)
+	}
+
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].Name < aliases[j].Name })
+
+	return aliases, nil
+}
+
+// CreateImageAlias creates the alias of an existing image
+func (s *Server) CreateImageAlias(req api.ImageAliasesPost) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, has := s.aliases[req.Name]; has {
+		return fmt.Errorf("alias %s %w", req.Name, ErrAlreadyExists)
+	}
+
+	if _, has := s.images[req.Target]; !has {
+		return fmt.Errorf("image %s: %w", req.Target, notFound())
+	}
+
+	s.aliases[req.Name] = aliasEntry(req.Name, req.Target)
+
+	return nil
+}
+
+// DeleteImageAlias deletes the alias
+func (s *Server) DeleteImageAlias(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, has := s.aliases[name]; !has {
+		return notFound()
+	}
+
+	delete(s.aliases, name)
+
+	return nil
+}
+
+func aliasEntry(name, fingerprint string) api.ImageAliasesEntry {
+	return api.ImageAliasesEntry{
+		ImageAliasesEntryPut: api.ImageAliasesEntryPut{Target: fingerprint},
+		Name:                 name,
+		Type:                 "container",
+	}
+}
+
+// Registry is an image server having an image for every alias, e.g. busybox:1.28. The images are empty, their
+// fingerprint is the sha256 of the alias. Methods of lxd.ImageServer LXE doesn't use panic
+type Registry struct {
+	// ImageServer is nil, the methods LXE doesn't use panic
+	lxd.ImageServer
+
+	mu     sync.Mutex
+	images map[string]*image
+}
+
+var _ lxd.ImageServer = &Registry{}
+
+// NewRegistry returns a registry, it's safe to share it between remotes
+func NewRegistry() *Registry {
+	return &Registry{images: map[string]*image{}}
+}
+
+// lookup returns the image of the alias, s.mu must be held
+func (r *Registry) lookup(alias string) *image {
+	sum := sha256.Sum256([]byte(alias))
+	fingerprint := hex.EncodeToString(sum[:])
+
+	if img, has := r.images[fingerprint]; has {
+		return img
+	}
+
+	meta := []byte("memlxd image " + alias + "\n")
+	img := &image{
+		Image: api.Image{
+			ImagePut:     api.ImagePut{Properties: map[string]string{"description": alias, "os": "memlxd"}},
+			Aliases:      []api.ImageAlias{{Name: alias}},
+			Architecture: "x86_64",
+			Fingerprint:  fingerprint,
+			Size:         int64(len(meta)),
+			Type:         "container",
+			CreatedAt:    time.Unix(0, 0).UTC(),
+			UploadedAt:   time.Unix(0, 0).UTC(),
+		},
+		meta: meta,
+	}
+	r.images[fingerprint] = img
+
+	return img
+}
+
+// GetImageAlias returns the alias of the image generated for the name
+func (r *Registry) GetImageAlias(name string) (*api.ImageAliasesEntry, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a := aliasEntry(name, r.lookup(name).Fingerprint)
+
+	return &a, "", nil
+}
+
+// GetImage returns the image of an alias looked up before
+func (r *Registry) GetImage(fingerprint string) (*api.Image, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	img, has := r.images[fingerprint]
+	if !has {
+		return nil, "", notFound()
+	}
+
+	i := img.Image
+
+	return &i, "", nil
+}
+
+// GetImageFile writes the files of the image to the request
+func (r *Registry) GetImageFile(fingerprint string, req lxd.ImageFileRequest) (*lxd.ImageFileResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	img, has := r.images[fingerprint]
+	if !has {
+		return nil, notFound()
+	}
+
+	return imageFile(img, req)
+}
+
+// buffer is an io.WriteSeeker in memory, only appending is supported
+type buffer struct {
+	bytes.Buffer
+}
+
+// Seek returns the size, the content is only written sequentially
+func (b *buffer) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence == io.SeekStart && b.Len() != 0 {
+		return 0, fmt.Errorf("seek: %w", ErrUnsupported)
+	}
+
+	return int64(b.Len()), nil
+}
@@ -0,0 +1,199 @@
+//go:build lxdfake
+// +build lxdfake
+
+package memlxd // import "github.com/automaticserver/lxe/lxf/memlxd"
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// network is a managed network with its parsed ipv4 subnet
+type network struct {
+	api.Network
+	etag    string
+	gateway net.IP
+	subnet  *net.IPNet
+}
+
+// setConfig replaces the config of the network. An auto ipv4 address gets a free /24 of 10.155.0.0/16
+func (s *Server) setConfig(n *network, put api.NetworkPut) error {
+	put.Config = cloneConfig(put.Config)
+
+	if put.Config["ipv4.address"] == "auto" {
+		put.Config["ipv4.address"] = fmt.Sprintf("10.155.%d.1/24", len(s.networks))
+	}
+
+	n.gateway, n.subnet = nil, nil
+
+	if cidr := put.Config["ipv4.address"]; cidr != "" && cidr != "none" {
+		gateway, subnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return err
+		}
+
+		n.gateway, n.subnet = gateway, subnet
+	}
+
+	n.NetworkPut = put
+
+	return nil
+}
+
+// CreateNetwork creates the managed network
+func (s *Server) CreateNetwork(req api.NetworksPost) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, has := s.networks[req.Name]; has {
+		return fmt.Errorf("network %s %w", req.Name, ErrAlreadyExists)
+	}
+
+	if req.Type == "" {
+		req.Type = "bridge"
+	}
+
+	n := &network{
+		Network: api.Network{Name: req.Name, Type: req.Type, Managed: true, Status: api.NetworkStatusCreated},
+		etag:    s.nextETag(),
+	}
+
+	err := s.setConfig(n, req.NetworkPut)
+	if err != nil {
+		return err
+	}
+
+	s.networks[req.Name] = n
+	s.emit("network-created", "/1.0/networks/"+req.Name)
+
+	return nil
+}
+
+// GetNetwork returns the network and its ETag
+func (s *Server) GetNetwork(name string) (*api.Network, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, has := s.networks[name]
+	if !has {
+		return nil, "", notFound()
+	}
+
+	clone := n.Network
+	clone.Config = cloneConfig(n.Config)
+
+	return &clone, n.etag, nil
+}
+
+// UpdateNetwork replaces the config of the network, running containers keep their addresses
+func (s *Server) UpdateNetwork(name string, put api.NetworkPut, etag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, has := s.networks[name]
+	if !has {
+		return notFound()
+	}
+
+	err := checkETag(n.etag, etag)
+	if err != nil {
+		return err
+	}
+
+	err = s.setConfig(n, put)
+	if err != nil {
+		return err
+	}
+
+	n.etag = s.nextETag()
+	s.emit("network-updated", "/1.0/networks/"+name)
+
+	return nil
+}
+
+// GetNetworkLeases returns the addresses of the running containers in the network
+func (s *Server) GetNetworkLeases(name string) ([]api.NetworkLease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, has := s.networks[name]
+	if !has {
+		return nil, notFound()
+	}
+
+	leases := []api.NetworkLease{}
+
+	for _, c := range s.containers {
+		for dev, ip := range c.addresses {
+			if n.subnet == nil || !n.subnet.Contains(net.ParseIP(ip)) {
+				continue
+			}
+
+			lease := api.NetworkLease{Hostname: c.Name, Hwaddr: c.ExpandedDevices[dev]["hwaddr"], Address: ip, Type: "dynamic"}
+			if c.ExpandedDevices[dev]["ipv4.address"] != "" {
+				lease.Type = "static"
+			}
+
+			leases = append(leases, lease)
+		}
+	}
+
+	sort.Slice(leases, func(i, j int) bool { return leases[i].Address < leases[j].Address })
+
+	return leases, nil
+}
+
+// freeAddress returns the first address of the subnet of the network which isn't the gateway nor used by a running
+// container, empty if there's none. s.mu must be held
+func (s *Server) freeAddress(n *network) string {
+	if n.subnet == nil || n.subnet.IP.To4() == nil {
+		return ""
+	}
+
+	used := map[string]bool{n.gateway.String(): true}
+
+	for _, c := range s.containers {
+		for _, ip := range c.addresses {
+			used[ip] = true
+		}
+	}
+
+	for _, dev := range s.pendingStatic() {
+		used[dev] = true
+	}
+
+	ones, bits := n.subnet.Mask.Size()
+	base := binary.BigEndian.Uint32(n.subnet.IP.To4())
+
+	// skip the network and broadcast address
+	for i := uint32(1); i < 1<<uint(bits-ones)-1; i++ {
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, base+i)
+
+		if !used[ip.String()] {
+			return ip.String()
+		}
+	}
+
+	return ""
+}
+
+// pendingStatic returns the static addresses of the nics of all containers, the free addresses must not collide with
+// them even if they aren't running yet. s.mu must be held
+func (s *Server) pendingStatic() []string {
+	static := []string{}
+
+	for _, c := range s.containers {
+		for _, dev := range c.ExpandedDevices {
+			if dev["type"] == "nic" && dev["ipv4.address"] != "" {
+				static = append(static, dev["ipv4.address"])
+			}
+		}
+	}
+
+	return static
+}
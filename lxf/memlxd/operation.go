@@ -0,0 +1,176 @@
+//go:build lxdfake
+// +build lxdfake
+
+package memlxd // import "github.com/automaticserver/lxe/lxf/memlxd"
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// operations keeps the operations, so they can be waited for by id like LXD allows
+type operations struct {
+	mu   sync.Mutex
+	last uint64
+	ops  map[string]*operation
+}
+
+func newOperations() *operations {
+	return &operations{ops: map[string]*operation{}}
+}
+
+// operation is a LXD operation, it's done when it's returned unless it runs in the background like exec
+type operation struct {
+	mu   sync.Mutex
+	op   api.Operation
+	done chan struct{}
+}
+
+var (
+	_ lxd.Operation       = &operation{}
+	_ lxd.RemoteOperation = &operation{}
+)
+
+// start returns a running operation of the class, finish completes it
+func (o *operations) start(class string) *operation {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.last++
+	now := time.Now()
+
+	op := &operation{
+		op: api.Operation{
+			ID:        "memlxd-" + strconv.FormatUint(o.last, 10),
+			Class:     class,
+			CreatedAt: now,
+			UpdatedAt: now,
+			Status:    api.Running.String(),
+			// nolint: gomnd
+			StatusCode: api.Running,
+			Metadata:   map[string]interface{}{},
+		},
+		done: make(chan struct{}),
+	}
+
+	o.ops[op.op.ID] = op
+
+	return op
+}
+
+// done returns a finished operation of the class, failed with err if it isn't nil
+func (o *operations) done(class string, metadata map[string]interface{}, err error) *operation {
+	op := o.start(class)
+	op.finish(metadata, err)
+
+	return op
+}
+
+// finish completes the operation with the metadata, failed if err isn't nil
+func (op *operation) finish(metadata map[string]interface{}, err error) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	for k, v := range metadata {
+		op.op.Metadata[k] = v
+	}
+
+	op.op.UpdatedAt = time.Now()
+	op.op.Status = api.Success.String()
+	op.op.StatusCode = api.Success
+
+	if err != nil {
+		op.op.Status = api.Failure.String()
+		op.op.StatusCode = api.Failure
+		op.op.Err = err.Error()
+	}
+
+	close(op.done)
+}
+
+// AddHandler isn't supported, the operations are waited for
+func (op *operation) AddHandler(function func(api.Operation)) (*lxd.EventTarget, error) {
+	return nil, fmt.Errorf("operation handlers: %w", ErrUnsupported)
+}
+
+// RemoveHandler isn't supported
+func (op *operation) RemoveHandler(target *lxd.EventTarget) error {
+	return fmt.Errorf("operation handlers: %w", ErrUnsupported)
+}
+
+// Cancel isn't supported, the operations can't be canceled
+func (op *operation) Cancel() error {
+	return fmt.Errorf("cancel: %w", ErrUnsupported)
+}
+
+// CancelTarget isn't supported, the operations can't be canceled
+func (op *operation) CancelTarget() error {
+	return op.Cancel()
+}
+
+// Get returns the operation as LXD reports it
+func (op *operation) Get() api.Operation {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	return op.op
+}
+
+// GetTarget returns the operation as LXD reports it
+func (op *operation) GetTarget() (*api.Operation, error) {
+	o := op.Get()
+
+	return &o, nil
+}
+
+// GetWebsocket isn't supported, exec attaches the streams directly
+func (op *operation) GetWebsocket(secret string) (*websocket.Conn, error) {
+	return nil, fmt.Errorf("websockets: %w", ErrUnsupported)
+}
+
+// Refresh does nothing, the operation is always current
+func (op *operation) Refresh() error {
+	return nil
+}
+
+// Wait blocks till the operation is done and returns its error
+func (op *operation) Wait() error {
+	<-op.done
+
+	if err := op.Get().Err; err != "" {
+		return errors.New(err) // nolint: goerr113
+	}
+
+	return nil
+}
+
+// GetOperationWait waits up to timeout seconds for the operation, -1 waits till it's done
+func (s *Server) GetOperationWait(uuid string, timeout int) (*api.Operation, string, error) {
+	s.ops.mu.Lock()
+	op, has := s.ops.ops[uuid]
+	s.ops.mu.Unlock()
+
+	if !has {
+		return nil, "", notFound()
+	}
+
+	if timeout < 0 {
+		<-op.done
+	} else {
+		select {
+		case <-op.done:
+		case <-time.After(time.Duration(timeout) * time.Second):
+		}
+	}
+
+	o := op.Get()
+
+	return &o, "", nil
+}
@@ -0,0 +1,148 @@
+//go:build lxdfake
+// +build lxdfake
+
+package memlxd // import "github.com/automaticserver/lxe/lxf/memlxd"
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// profile is a profile with its ETag
+type profile struct {
+	api.Profile
+	etag string
+}
+
+// used returns the containers using the profile, s.mu must be held
+func (s *Server) used(name string) []string {
+	usedBy := []string{}
+
+	for _, c := range s.containers {
+		for _, p := range c.Profiles {
+			if p == name {
+				usedBy = append(usedBy, source(c.Name))
+			}
+		}
+	}
+
+	sort.Strings(usedBy)
+
+	return usedBy
+}
+
+// CreateProfile creates the profile
+func (s *Server) CreateProfile(req api.ProfilesPost) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, has := s.profiles[req.Name]; has {
+		return fmt.Errorf("profile %s %w", req.Name, ErrAlreadyExists)
+	}
+
+	s.profiles[req.Name] = &profile{
+		Profile: api.Profile{
+			Name:       req.Name,
+			ProfilePut: api.ProfilePut{Config: cloneConfig(req.Config), Devices: cloneDevices(req.Devices), Description: req.Description},
+		},
+		etag: s.nextETag(),
+	}
+	s.emit("profile-created", "/1.0/profiles/"+req.Name)
+
+	return nil
+}
+
+// GetProfile returns the profile and its ETag
+func (s *Server) GetProfile(name string) (*api.Profile, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, has := s.profiles[name]
+	if !has {
+		return nil, "", notFound()
+	}
+
+	return s.cloneProfile(p), p.etag, nil
+}
+
+// GetProfiles returns all profiles sorted by name
+func (s *Server) GetProfiles() ([]api.Profile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ps := make([]api.Profile, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		ps = append(ps, *s.cloneProfile(p))
+	}
+
+	sort.Slice(ps, func(i, j int) bool { return ps[i].Name < ps[j].Name })
+
+	return ps, nil
+}
+
+// UpdateProfile replaces the config and devices of the profile, which changes the containers using it
+func (s *Server) UpdateProfile(name string, put api.ProfilePut, etag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, has := s.profiles[name]
+	if !has {
+		return notFound()
+	}
+
+	err := checkETag(p.etag, etag)
+	if err != nil {
+		return err
+	}
+
+	p.ProfilePut = api.ProfilePut{Config: cloneConfig(put.Config), Devices: cloneDevices(put.Devices), Description: put.Description}
+	p.etag = s.nextETag()
+
+	for _, c := range s.containers {
+		for _, used := range c.Profiles {
+			if used == name {
+				s.expand(&c.Container)
+				c.etag = s.nextETag()
+			}
+		}
+	}
+
+	s.emit("profile-updated", "/1.0/profiles/"+name)
+
+	return nil
+}
+
+// DeleteProfile deletes the profile if no container uses it
+func (s *Server) DeleteProfile(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, has := s.profiles[name]; !has {
+		return notFound()
+	}
+
+	if name == "default" {
+		return fmt.Errorf("the default profile can't be deleted: %w", ErrUnsupported)
+	}
+
+	if len(s.used(name)) > 0 {
+		return fmt.Errorf("profile %s is currently in use", name) // nolint: goerr113
+	}
+
+	delete(s.profiles, name)
+	s.emit("profile-deleted", "/1.0/profiles/"+name)
+
+	return nil
+}
+
+// cloneProfile copies the profile with its maps and sets its users, s.mu must be held
+func (s *Server) cloneProfile(p *profile) *api.Profile {
+	clone := p.Profile
+	clone.Config = cloneConfig(p.Config)
+	clone.Devices = cloneDevices(p.Devices)
+	clone.UsedBy = s.used(p.Name)
+
+	return &clone
+}
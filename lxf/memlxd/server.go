@@ -0,0 +1,256 @@
+//go:build lxdfake
+// +build lxdfake
+
+// Package memlxd is an in-memory LXD server implementing lxd.ContainerServer, so LXE can run without a LXD daemon,
+// e.g. to run the CRI validation suite (critest) in CI. It keeps containers, profiles, images, networks, storage
+// volumes, snapshots and files in memory and completes every operation right away. Containers don't run processes:
+// they only change their state, and exec runs a few builtin commands against the files of the container, see Exec.
+// Projects share one namespace and methods of lxd.ContainerServer LXE doesn't use panic. It's only built with the
+// lxdfake build tag, so release builds don't contain it
+package memlxd // import "github.com/automaticserver/lxe/lxf/memlxd"
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/automaticserver/lxe/shared"
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// These are the LXD errors LXE relies on the messages of
+var (
+	ErrAlreadyExists  = errors.New("already exists")
+	ErrETagMismatch   = errors.New("ETag doesn't match")
+	ErrNotRunning     = errors.New("The container is not running")
+	ErrAlreadyRunning = errors.New("The container is already running")
+	ErrAlreadyStopped = errors.New("The container is already stopped")
+	ErrStillRunning   = errors.New("The container is currently running, stop it first")
+	ErrNotClustered   = errors.New("The server isn't part of a cluster")
+	ErrUnsupported    = errors.New("not supported by memlxd")
+)
+
+// Extensions are the API extensions the server reports. event_lifecycle is missing on purpose: LXE then doesn't open
+// an event listener, lifecycle events are passed to the handlers of AddLifecycleHandler instead
+var Extensions = []string{"file_delete", "linux_sysctl", "projects", "resources_v2", "resources_numa"}
+
+// Server is an in-memory LXD server, the zero value isn't usable, use New
+type Server struct {
+	// ContainerServer is nil, the methods LXE doesn't use panic
+	lxd.ContainerServer
+
+	mu         sync.Mutex
+	etags      uint64
+	pids       int64
+	containers map[string]*container
+	profiles   map[string]*profile
+	images     map[string]*image
+	aliases    map[string]api.ImageAliasesEntry
+	networks   map[string]*network
+	volumes    map[string]*volume
+	projects   map[string]api.Project
+	ops        *operations
+	handlers   []func(api.Event)
+}
+
+var _ lxd.ContainerServer = &Server{}
+
+// New returns a server with the default profile, a root disk on the storage pool default and no images
+func New() *Server {
+	s := &Server{
+		containers: map[string]*container{},
+		profiles:   map[string]*profile{},
+		images:     map[string]*image{},
+		aliases:    map[string]api.ImageAliasesEntry{},
+		networks:   map[string]*network{},
+		volumes:    map[string]*volume{},
+		projects:   map[string]api.Project{"default": {Name: "default"}},
+		ops:        newOperations(),
+	}
+
+	s.profiles["default"] = &profile{
+		Profile: api.Profile{
+			Name: "default",
+			ProfilePut: api.ProfilePut{
+				Config:  map[string]string{},
+				Devices: map[string]map[string]string{"root": {"type": "disk", "path": "/", "pool": "default"}},
+			},
+		},
+		etag: s.nextETag(),
+	}
+
+	return s
+}
+
+// AddLifecycleHandler registers the handler for the lifecycle events of the server, they are called concurrently
+func (s *Server) AddLifecycleHandler(h func(api.Event)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers = append(s.handlers, h)
+}
+
+// emit sends the lifecycle event about the source to the handlers, s.mu must be held
+func (s *Server) emit(action, source string) {
+	metadata, _ := json.Marshal(api.EventLifecycle{Action: action, Source: source})
+	event := api.Event{Type: "lifecycle", Timestamp: time.Now(), Metadata: metadata}
+
+	for _, h := range s.handlers {
+		go h(event)
+	}
+}
+
+// nextETag returns a new ETag, s.mu must be held
+func (s *Server) nextETag() string {
+	s.etags++
+
+	return strconv.FormatUint(s.etags, 10)
+}
+
+// checkETag fails if the ETag of a request is set and doesn't match the current one
+func checkETag(current, requested string) error {
+	if requested != "" && requested != current {
+		return fmt.Errorf("%w: %s, is %s", ErrETagMismatch, requested, current)
+	}
+
+	return nil
+}
+
+// notFound is the error LXD returns for missing objects
+func notFound() error {
+	return shared.NewErrNotFound()
+}
+
+// Disconnect does nothing, there's no connection
+func (s *Server) Disconnect() {}
+
+// GetServer returns the server info of a standalone LXD 4.0 on Linux 5.15 with cgroup2
+func (s *Server) GetServer() (*api.Server, string, error) {
+	return &api.Server{
+		ServerPut: api.ServerPut{Config: map[string]interface{}{}},
+		ServerUntrusted: api.ServerUntrusted{
+			APIExtensions: Extensions,
+			APIStatus:     "stable",
+			APIVersion:    "1.0",
+			Auth:          "trusted",
+			AuthMethods:   []string{"tls"},
+		},
+		Environment: api.ServerEnvironment{
+			Architectures:  []string{"x86_64"},
+			Driver:         "lxc",
+			DriverVersion:  "4.0.0",
+			Kernel:         "Linux",
+			KernelVersion:  "5.15.0-memlxd",
+			KernelFeatures: map[string]string{"shiftfs": "false", "idmapped_mounts": "false"},
+			LXCFeatures:    map[string]string{"cgroup2": "true"},
+			Project:        "default",
+			Server:         "lxd",
+			ServerName:     "memlxd",
+			ServerVersion:  "4.0.0",
+			Storage:        "dir",
+		},
+	}, "", nil
+}
+
+// HasExtension checks if the extension is in Extensions
+func (s *Server) HasExtension(extension string) bool {
+	for _, e := range Extensions {
+		if e == extension {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsClustered is always false
+func (s *Server) IsClustered() bool {
+	return false
+}
+
+// UseTarget returns the server itself, it isn't clustered
+func (s *Server) UseTarget(name string) lxd.InstanceServer {
+	return s
+}
+
+// UseProject returns the server itself, all projects share one namespace
+func (s *Server) UseProject(name string) lxd.InstanceServer {
+	return s
+}
+
+// GetServerResources reports a host with 4 cpus and 8GiB memory on NUMA node 0
+func (s *Server) GetServerResources() (*api.Resources, error) {
+	threads := []api.ResourcesCPUThread{}
+	for i := int64(0); i < 4; i++ {
+		threads = append(threads, api.ResourcesCPUThread{ID: i, Online: true})
+	}
+
+	return &api.Resources{
+		CPU: api.ResourcesCPU{
+			Architecture: "x86_64",
+			Sockets:      []api.ResourcesCPUSocket{{Cores: []api.ResourcesCPUCore{{Threads: threads}}}},
+			Total:        uint64(len(threads)),
+		},
+		Memory: api.ResourcesMemory{
+			Nodes: []api.ResourcesMemoryNode{{Total: 8 << 30}},
+			Total: 8 << 30,
+		},
+	}, nil
+}
+
+// GetCertificate never finds a certificate, there's no authentication
+func (s *Server) GetCertificate(fingerprint string) (*api.Certificate, string, error) {
+	return nil, "", notFound()
+}
+
+// GetEvents isn't supported, see AddLifecycleHandler
+func (s *Server) GetEvents() (*lxd.EventListener, error) {
+	return nil, fmt.Errorf("events: %w, use AddLifecycleHandler", ErrUnsupported)
+}
+
+// GetProject returns the project
+func (s *Server) GetProject(name string) (*api.Project, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, has := s.projects[name]
+	if !has {
+		return nil, "", notFound()
+	}
+
+	return &p, "", nil
+}
+
+// CreateProject creates the project
+func (s *Server) CreateProject(project api.ProjectsPost) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, has := s.projects[project.Name]; has {
+		return fmt.Errorf("project %s %w", project.Name, ErrAlreadyExists)
+	}
+
+	s.projects[project.Name] = api.Project{Name: project.Name, ProjectPut: project.ProjectPut}
+
+	return nil
+}
+
+// UpdateProject replaces the config and description of the project
+func (s *Server) UpdateProject(name string, project api.ProjectPut, etag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, has := s.projects[name]
+	if !has {
+		return notFound()
+	}
+
+	p.ProjectPut = project
+	s.projects[name] = p
+
+	return nil
+}
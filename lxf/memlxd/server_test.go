@@ -0,0 +1,330 @@
+//go:build lxdfake
+// +build lxdfake
+
+package memlxd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/automaticserver/lxe/shared"
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// testContainer returns the server with the stopped container foo from the image busybox
+func testContainer(t *testing.T) *Server {
+	t.Helper()
+
+	s := New()
+	registry := NewRegistry()
+	img, _, _ := registry.GetImageAlias("busybox")
+
+	op, err := s.CopyImage(registry, api.Image{Fingerprint: img.Target}, &lxd.ImageCopyArgs{})
+	assert.NoError(t, err)
+	assert.NoError(t, op.Wait())
+
+	op2, err := s.CreateContainer(api.ContainersPost{
+		Name:   "foo",
+		Source: api.ContainerSource{Type: "image", Fingerprint: img.Target},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, op2.Wait())
+
+	return s
+}
+
+func exec(t *testing.T, s *Server, cmd ...string) (string, string, int) {
+	t.Helper()
+
+	stdout, stderr := &closeBuffer{}, &closeBuffer{}
+	args := &lxd.ContainerExecArgs{Stdout: stdout, Stderr: stderr, DataDone: make(chan bool)}
+
+	op, err := s.ExecContainer("foo", api.ContainerExecPost{Command: cmd}, args)
+	assert.NoError(t, err)
+
+	<-args.DataDone
+	assert.NoError(t, op.Wait())
+
+	return stdout.String(), stderr.String(), int(op.Get().Metadata["return"].(float64))
+}
+
+type closeBuffer struct {
+	bytes.Buffer
+}
+
+func (b *closeBuffer) Close() error {
+	return nil
+}
+
+func TestServer_ContainerState(t *testing.T) {
+	t.Parallel()
+
+	s := testContainer(t)
+
+	start := api.ContainerStatePut{Action: "start"}
+	op, err := s.UpdateContainerState("foo", start, "")
+	assert.NoError(t, err)
+	assert.NoError(t, op.Wait())
+
+	state, _, err := s.GetContainerState("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, api.Running, state.StatusCode)
+	assert.NotZero(t, state.Pid)
+
+	_, err = s.DeleteContainer("foo")
+	assert.Equal(t, ErrStillRunning, err)
+
+	op, err = s.UpdateContainerState("foo", api.ContainerStatePut{Action: "stop", Force: true}, "")
+	assert.NoError(t, err)
+	assert.NoError(t, op.Wait())
+
+	op, err = s.UpdateContainerState("foo", api.ContainerStatePut{Action: "stop"}, "")
+	assert.NoError(t, err)
+	// lxo detects it by the message
+	assert.EqualError(t, op.Wait(), "The container is already stopped")
+
+	op, err = s.DeleteContainer("foo")
+	assert.NoError(t, err)
+	assert.NoError(t, op.Wait())
+
+	_, _, err = s.GetContainer("foo")
+	assert.True(t, shared.IsErrNotFound(err))
+}
+
+func TestServer_UpdateContainer_ETag(t *testing.T) {
+	t.Parallel()
+
+	s := testContainer(t)
+
+	ct, etag, err := s.GetContainer("foo")
+	assert.NoError(t, err)
+
+	ct.Config["user.foo"] = "bar"
+
+	// the returned container is a copy
+	unchanged, _, _ := s.GetContainer("foo")
+	assert.Empty(t, unchanged.Config["user.foo"])
+
+	_, err = s.UpdateContainer("foo", ct.Writable(), "stale")
+	assert.True(t, errors.Is(err, ErrETagMismatch))
+
+	_, err = s.UpdateContainer("foo", ct.Writable(), etag)
+	assert.NoError(t, err)
+
+	updated, newETag, _ := s.GetContainer("foo")
+	assert.Equal(t, "bar", updated.ExpandedConfig["user.foo"])
+	assert.NotEqual(t, etag, newETag)
+	// the devices of the default profile are expanded
+	assert.Equal(t, "disk", updated.ExpandedDevices["root"]["type"])
+}
+
+func TestServer_UpdateProfile_ExpandsContainers(t *testing.T) {
+	t.Parallel()
+
+	s := testContainer(t)
+
+	p, etag, err := s.GetProfile("default")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/1.0/containers/foo"}, p.UsedBy)
+
+	p.Config["limits.cpu"] = "2"
+	assert.NoError(t, s.UpdateProfile("default", p.Writable(), etag))
+
+	ct, _, _ := s.GetContainer("foo")
+	assert.Equal(t, "2", ct.ExpandedConfig["limits.cpu"])
+}
+
+func TestServer_Snapshot_Restore(t *testing.T) {
+	t.Parallel()
+
+	s := testContainer(t)
+
+	assert.NoError(t, s.CreateContainerFile("foo", "/etc/hostname", lxd.ContainerFileArgs{Content: strings.NewReader("a")}))
+
+	_, err := s.CreateContainerSnapshot("foo", api.ContainerSnapshotsPost{Name: "snap0"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.CreateContainerFile("foo", "/etc/hostname", lxd.ContainerFileArgs{Content: strings.NewReader("b")}))
+
+	op, err := s.UpdateContainer("foo", api.ContainerPut{Restore: "snap0"}, "")
+	assert.NoError(t, err)
+	assert.NoError(t, op.Wait())
+
+	content, _, err := s.GetContainerFile("foo", "/etc/hostname")
+	assert.NoError(t, err)
+
+	b, _ := ioutil.ReadAll(content)
+	assert.Equal(t, "a", string(b))
+
+	snaps, err := s.GetContainerSnapshots("foo")
+	assert.NoError(t, err)
+	assert.Len(t, snaps, 1)
+
+	op, err = s.UpdateContainer("foo", api.ContainerPut{Restore: "missing"}, "")
+	assert.NoError(t, err)
+	assert.Error(t, op.Wait())
+}
+
+func TestServer_Files(t *testing.T) {
+	t.Parallel()
+
+	s := testContainer(t)
+
+	assert.NoError(t, s.CreateContainerFile("foo", "/var/log/a", lxd.ContainerFileArgs{Content: strings.NewReader("1\n"), Mode: 0600}))
+	assert.NoError(t, s.CreateContainerFile("foo", "/var/log/a", lxd.ContainerFileArgs{Content: strings.NewReader("2\n"), WriteMode: "append"}))
+
+	content, resp, err := s.GetContainerFile("foo", "/var/log/a")
+	assert.NoError(t, err)
+	assert.Equal(t, 0600, resp.Mode)
+
+	b, _ := ioutil.ReadAll(content)
+	assert.Equal(t, "1\n2\n", string(b))
+
+	_, resp, err = s.GetContainerFile("foo", "/var")
+	assert.NoError(t, err)
+	assert.Equal(t, "directory", resp.Type)
+	assert.Equal(t, []string{"log"}, resp.Entries)
+
+	assert.NoError(t, s.DeleteContainerFile("foo", "/var"))
+
+	_, _, err = s.GetContainerFile("foo", "/var/log/a")
+	assert.True(t, shared.IsErrNotFound(err))
+}
+
+func TestServer_Exec(t *testing.T) {
+	t.Parallel()
+
+	s := testContainer(t)
+
+	_, err := s.ExecContainer("foo", api.ContainerExecPost{Command: []string{"true"}}, &lxd.ContainerExecArgs{})
+	assert.Equal(t, ErrNotRunning, err)
+
+	_, err = s.UpdateContainerState("foo", api.ContainerStatePut{Action: "start"}, "")
+	assert.NoError(t, err)
+
+	stdout, _, code := exec(t, s, "echo", "hello", "world")
+	assert.Equal(t, "hello world\n", stdout)
+	assert.Equal(t, 0, code)
+
+	stdout, _, code = exec(t, s, "/bin/sh", "-c", "echo 'a b' > /f; echo c >> /f && cat /f")
+	assert.Equal(t, "a b\nc\n", stdout)
+	assert.Equal(t, 0, code)
+
+	stdout, _, code = exec(t, s, "sh", "-c", "false && echo no || echo yes")
+	assert.Equal(t, "yes\n", stdout)
+	assert.Equal(t, 0, code)
+
+	stdout, _, code = exec(t, s, "sh", "-c", "test -f /f && exit 3")
+	assert.Empty(t, stdout)
+	assert.Equal(t, 3, code)
+
+	_, stderr, code := exec(t, s, "top")
+	assert.Equal(t, "sh: top: not found\n", stderr)
+	assert.Equal(t, 127, code)
+}
+
+func TestServer_NetworkLeases(t *testing.T) {
+	t.Parallel()
+
+	s := testContainer(t)
+
+	assert.NoError(t, s.CreateNetwork(api.NetworksPost{Name: "br0", NetworkPut: api.NetworkPut{Config: map[string]string{"ipv4.address": "10.0.0.1/24"}}}))
+
+	ct, etag, _ := s.GetContainer("foo")
+	ct.Devices["eth0"] = map[string]string{"type": "nic", "network": "br0", "hwaddr": "00:16:3e:00:00:01"}
+
+	_, err := s.UpdateContainer("foo", ct.Writable(), etag)
+	assert.NoError(t, err)
+
+	_, err = s.UpdateContainerState("foo", api.ContainerStatePut{Action: "start"}, "")
+	assert.NoError(t, err)
+
+	leases, err := s.GetNetworkLeases("br0")
+	assert.NoError(t, err)
+	assert.Equal(t, []api.NetworkLease{{Hostname: "foo", Hwaddr: "00:16:3e:00:00:01", Address: "10.0.0.2", Type: "dynamic"}}, leases)
+
+	state, _, _ := s.GetContainerState("foo")
+	assert.Equal(t, "10.0.0.2", state.Network["eth0"].Addresses[0].Address)
+}
+
+func TestServer_CreateImage(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+
+	op, err := s.CreateImage(api.ImagesPost{}, &lxd.ImageCreateArgs{MetaFile: strings.NewReader("meta"), RootfsFile: strings.NewReader("rootfs")})
+	assert.NoError(t, err)
+	assert.NoError(t, op.Wait())
+
+	sum := sha256.Sum256([]byte("metarootfs"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), op.Get().Metadata["fingerprint"])
+
+	assert.NoError(t, s.CreateImageAlias(api.ImageAliasesPost{ImageAliasesEntry: aliasEntry("foo", hex.EncodeToString(sum[:]))}))
+
+	op, err = s.DeleteImage(hex.EncodeToString(sum[:]))
+	assert.NoError(t, err)
+	assert.NoError(t, op.Wait())
+
+	aliases, err := s.GetImageAliases()
+	assert.NoError(t, err)
+	assert.Empty(t, aliases)
+}
+
+func Test_tokenize(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []token{
+		{text: "echo"}, {text: "a b"}, {text: ">>", op: true}, {text: "/f"}, {text: ";", op: true},
+		{text: "x"}, {text: "&&", op: true}, {text: "y"}, {text: "||", op: true}, {text: "z"},
+	}, tokenize(`echo "a b">>/f; x&&y || z`))
+}
+
+func TestServer_CreateImage_Publish(t *testing.T) {
+	t.Parallel()
+
+	s := testContainer(t)
+
+	assert.NoError(t, s.CreateContainerFile("foo", "/etc/motd", lxd.ContainerFileArgs{Content: strings.NewReader("golden")}))
+
+	_, err := s.CreateContainerSnapshot("foo", api.ContainerSnapshotsPost{Name: "snap0"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.DeleteContainerFile("foo", "/etc/motd"))
+
+	source := &api.ImagesPostSource{Type: "snapshot", Name: "foo/snap0"}
+
+	op, err := s.CreateImage(api.ImagesPost{Source: source}, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, op.Wait())
+
+	fingerprint, _ := op.Get().Metadata["fingerprint"].(string)
+
+	op, err = s.CreateContainer(api.ContainersPost{
+		Name:   "bar",
+		Source: api.ContainerSource{Type: "image", Fingerprint: fingerprint},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, op.Wait())
+
+	// the container starts with the files of the snapshot
+	content, _, err := s.GetContainerFile("bar", "/etc/motd")
+	assert.NoError(t, err)
+
+	b, _ := ioutil.ReadAll(content)
+	assert.Equal(t, "golden", string(b))
+
+	// the same files are the same image
+	op, err = s.CreateImage(api.ImagesPost{Source: source}, nil)
+	assert.NoError(t, err)
+	assert.Error(t, op.Wait())
+
+	_, err = s.CreateImage(api.ImagesPost{Source: &api.ImagesPostSource{Type: "snapshot", Name: "foo/missing"}}, nil)
+	assert.True(t, shared.IsErrNotFound(err))
+}
@@ -0,0 +1,165 @@
+//go:build lxdfake
+// +build lxdfake
+
+package memlxd // import "github.com/automaticserver/lxe/lxf/memlxd"
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// DefaultPool is the only storage pool, with the dir driver
+const DefaultPool = "default"
+
+// poolSize is the reported size of the storage pool
+const poolSize = 100 << 30
+
+// volume is a storage volume of the pool
+type volume struct {
+	api.StorageVolume
+	etag string
+}
+
+func volumeKey(volType, name string) string {
+	return volType + "/" + name
+}
+
+// GetStoragePools returns the storage pool
+func (s *Server) GetStoragePools() ([]api.StoragePool, error) {
+	p, _, err := s.GetStoragePool(DefaultPool)
+	if err != nil {
+		return nil, err
+	}
+
+	return []api.StoragePool{*p}, nil
+}
+
+// GetStoragePool returns the storage pool, there's only DefaultPool
+func (s *Server) GetStoragePool(name string) (*api.StoragePool, string, error) {
+	if name != DefaultPool {
+		return nil, "", notFound()
+	}
+
+	return &api.StoragePool{
+		StoragePoolPut: api.StoragePoolPut{Config: map[string]string{"source": "/var/lib/memlxd/storage-pools/default"}},
+		Name:           DefaultPool,
+		Driver:         "dir",
+		Status:         "Created",
+	}, "", nil
+}
+
+// GetStoragePoolResources reports the files of all containers as used space of the pool
+func (s *Server) GetStoragePoolResources(name string) (*api.ResourcesStoragePool, error) {
+	if name != DefaultPool {
+		return nil, notFound()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := &api.ResourcesStoragePool{}
+	res.Space.Total = poolSize
+	res.Inodes.Total = poolSize >> 12
+
+	for _, c := range s.containers {
+		res.Space.Used += uint64(c.files.size())
+		res.Inodes.Used += uint64(len(c.files.files))
+	}
+
+	return res, nil
+}
+
+// GetStoragePoolVolumes returns the volumes of the pool sorted by type and name
+func (s *Server) GetStoragePoolVolumes(pool string) ([]api.StorageVolume, error) {
+	if pool != DefaultPool {
+		return nil, notFound()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vols := make([]api.StorageVolume, 0, len(s.volumes))
+	for _, v := range s.volumes {
+		vols = append(vols, v.StorageVolume)
+	}
+
+	sort.Slice(vols, func(i, j int) bool {
+		return volumeKey(vols[i].Type, vols[i].Name) < volumeKey(vols[j].Type, vols[j].Name)
+	})
+
+	return vols, nil
+}
+
+// GetStoragePoolVolume returns the volume and its ETag
+func (s *Server) GetStoragePoolVolume(pool string, volType string, name string) (*api.StorageVolume, string, error) {
+	if pool != DefaultPool {
+		return nil, "", notFound()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, has := s.volumes[volumeKey(volType, name)]
+	if !has {
+		return nil, "", notFound()
+	}
+
+	vol := v.StorageVolume
+	vol.Config = cloneConfig(v.Config)
+
+	return &vol, v.etag, nil
+}
+
+// CreateStoragePoolVolume creates the volume
+func (s *Server) CreateStoragePoolVolume(pool string, req api.StorageVolumesPost) error {
+	if pool != DefaultPool {
+		return notFound()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := volumeKey(req.Type, req.Name)
+	if _, has := s.volumes[key]; has {
+		return fmt.Errorf("volume %s %w", req.Name, ErrAlreadyExists)
+	}
+
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = "filesystem"
+	}
+
+	s.volumes[key] = &volume{
+		StorageVolume: api.StorageVolume{
+			StorageVolumePut: api.StorageVolumePut{Config: cloneConfig(req.Config), Description: req.Description},
+			Name:             req.Name,
+			Type:             req.Type,
+			Location:         "none",
+			ContentType:      contentType,
+		},
+		etag: s.nextETag(),
+	}
+
+	return nil
+}
+
+// DeleteStoragePoolVolume deletes the volume
+func (s *Server) DeleteStoragePoolVolume(pool string, volType string, name string) error {
+	if pool != DefaultPool {
+		return notFound()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := volumeKey(volType, name)
+	if _, has := s.volumes[key]; !has {
+		return notFound()
+	}
+
+	delete(s.volumes, key)
+
+	return nil
+}
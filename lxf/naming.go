@@ -0,0 +1,146 @@
+package lxf // import "github.com/automaticserver/lxe/lxf"
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/automaticserver/lxe/shared"
+)
+
+// The LXD names of sandboxes and containers are made of the name prefix, the readable CRI names and a hash of the CRI
+// identifiers. The full identifiers are stored in the metadata config keys, so the readable names can be shortened
+const (
+	// MaxNameLength is the longest name LXD accepts for containers, as they're hostnames, and is used for profiles too
+	MaxNameLength = 63
+	// MaxNamePrefixLength leaves enough of the name for the readable CRI names
+	MaxNamePrefixLength = 20
+	// nameHashLength is the number of base32 characters of the hash, 50 bits
+	nameHashLength = 10
+	// maxNameSalts is how many names are tried if they're already taken by another object
+	maxNameSalts = 5
+)
+
+var (
+	ErrInvalidNamePrefix = errors.New("invalid name prefix")
+	ErrNameCollision     = errors.New("no free name found")
+)
+
+// SetNamePrefix sets the prefix of the names of the sandboxes and containers created from now on
+func (l *client) SetNamePrefix(prefix string) {
+	l.namePrefix = prefix
+}
+
+// ValidateNamePrefix returns an error if names starting with the prefix would be invalid in LXD. Empty is valid
+func ValidateNamePrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+
+	if len(prefix) > MaxNamePrefixLength {
+		return fmt.Errorf("%w: %s is longer than %d characters", ErrInvalidNamePrefix, prefix, MaxNamePrefixLength)
+	}
+
+	if !isNameLetter(prefix[0]) || sanitizeName(prefix) != prefix {
+		return fmt.Errorf("%w: %s must start with a lowercase letter and contain only lowercase letters, digits and "+
+			"hyphens", ErrInvalidNamePrefix, prefix)
+	}
+
+	return nil
+}
+
+// makeName returns the LXD name made of the prefix, the readable parts joined by hyphens and the hash of the key. The
+// readable parts are shortened so the name isn't longer than MaxNameLength. A salt greater than 0 gives another name
+// for the same key, if the first one is taken
+func makeName(prefix, key string, salt int, parts ...string) string {
+	if salt > 0 {
+		key = fmt.Sprintf("%s/%d", key, salt)
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	hash := b32lowerEncoder.EncodeToString(sum[:])[:nameHashLength]
+
+	readable := strings.Trim(sanitizeName(strings.Join(parts, "-")), "-")
+
+	// LXD names must start with a letter
+	if prefix == "" && (readable == "" || !isNameLetter(readable[0])) {
+		prefix = "x"
+	}
+
+	if max := MaxNameLength - len(prefix) - len(hash) - 1; len(readable) > max {
+		readable = strings.TrimRight(readable[:max], "-")
+	}
+
+	if readable == "" {
+		return prefix + hash
+	}
+
+	return prefix + readable + "-" + hash
+}
+
+// freeName returns the first name of the key which isn't taken according to exists
+func freeName(name func(salt int) string, exists func(name string) (bool, error)) (string, error) {
+	for salt := 0; salt < maxNameSalts; salt++ {
+		n := name(salt)
+
+		taken, err := exists(n)
+		if err != nil {
+			return "", err
+		}
+
+		if !taken {
+			return n, nil
+		}
+
+		log.WithField("name", n).Warn("name already taken, trying another one")
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrNameCollision, name(0))
+}
+
+// containerExists returns true if an LXD container with the name exists, regardless of whether it's a CRI container
+func (l *client) containerExists(name string) (bool, error) {
+	_, _, err := l.server.GetContainer(name)
+	if err != nil {
+		if shared.IsErrNotFound(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// profileExists returns true if an LXD profile with the name exists, regardless of whether it's a sandbox
+func (l *client) profileExists(name string) (bool, error) {
+	_, _, err := l.server.GetProfile(name)
+	if err != nil {
+		if shared.IsErrNotFound(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// sanitizeName lowercases the name and replaces all characters LXD doesn't accept by hyphens
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		default:
+			return '-'
+		}
+	}, name)
+}
+
+func isNameLetter(b byte) bool {
+	return b >= 'a' && b <= 'z'
+}
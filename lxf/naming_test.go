@@ -0,0 +1,73 @@
+package lxf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/automaticserver/lxe/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_makeName(t *testing.T) {
+	t.Parallel()
+
+	name := makeName("", "key", 0, "default", "nginx")
+	assert.Regexp(t, "^default-nginx-[a-z2-7]{10}$", name)
+	assert.Equal(t, name, makeName("", "key", 0, "default", "nginx"))
+	assert.NotEqual(t, name, makeName("", "key", 1, "default", "nginx"))
+	assert.NotEqual(t, name, makeName("", "other", 0, "default", "nginx"))
+
+	assert.Regexp(t, "^node1-default-nginx-[a-z2-7]{10}$", makeName("node1-", "key", 0, "default", "nginx"))
+	assert.Regexp(t, "^x0app-my-app-[a-z2-7]{10}$", makeName("", "key", 0, "0app", "My_App"))
+
+	long := makeName("node1-", "key", 0, strings.Repeat("n", 63), strings.Repeat("p", 63))
+	assert.Len(t, long, MaxNameLength)
+	assert.True(t, strings.HasPrefix(long, "node1-nnn"))
+
+	// the hyphen between the parts isn't kept as last readable character
+	assert.Regexp(t, "^a{51}-[a-z2-7]{10}$", makeName("", "key", 0, strings.Repeat("a", 51), "b"))
+}
+
+func Test_freeName(t *testing.T) {
+	t.Parallel()
+
+	taken := map[string]bool{"n0": true, "n1": true}
+	name := func(salt int) string { return "n" + string(rune('0'+salt)) }
+	exists := func(n string) (bool, error) { return taken[n], nil }
+
+	n, err := freeName(name, exists)
+	assert.NoError(t, err)
+	assert.Equal(t, "n2", n)
+
+	_, err = freeName(name, func(string) (bool, error) { return true, nil })
+	assert.True(t, errors.Is(err, ErrNameCollision))
+}
+
+func TestValidateNamePrefix(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, ValidateNamePrefix(""))
+	assert.NoError(t, ValidateNamePrefix("node1-"))
+
+	for _, p := range []string{"1node", "Node", "node_1", "-node", strings.Repeat("n", MaxNamePrefixLength+1)} {
+		assert.True(t, errors.Is(ValidateNamePrefix(p), ErrInvalidNamePrefix), p)
+	}
+}
+
+func TestClient_containerExists(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+
+	fake.GetContainerReturns(nil, "", shared.NewErrNotFound())
+	exists, err := client.containerExists("app")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	fake.GetContainerReturns(&api.Container{Name: "app"}, "", nil)
+	exists, err = client.containerExists("app")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
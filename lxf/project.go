@@ -0,0 +1,142 @@
+package lxf // import "github.com/automaticserver/lxe/lxf"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/automaticserver/lxe/shared"
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// DefaultProject is the LXD project which always exists, LXE uses it if no project is set
+const DefaultProject = "default"
+
+// projectLimitPrefix starts the config keys of project limits
+const projectLimitPrefix = "limits."
+
+// defaultProfile is the profile every project has
+const defaultProfile = "default"
+
+var (
+	ErrInvalidProjectLimit = errors.New("invalid project limit")
+	ErrProjectsUnsupported = errors.New("lxd doesn't support projects")
+)
+
+// Project is the LXD project the sandboxes, containers and images are created in. A new project has its own images and
+// profiles, its default profile is copied from the default project
+type Project struct {
+	// Name of the project, DefaultProject if empty
+	Name string
+	// Limits are the limits.* config keys of the project, they're set whenever LXE connects
+	Limits map[string]string
+}
+
+// ParseProjectLimits parses key=value entries of project limits, the keys must start with "limits."
+func ParseProjectLimits(entries []string) (map[string]string, error) {
+	limits := make(map[string]string, len(entries))
+
+	for _, e := range entries {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], projectLimitPrefix) || parts[1] == "" {
+			return nil, fmt.Errorf("%w: entry %q must be in the form limits.<key>=value", ErrInvalidProjectLimit, e)
+		}
+
+		limits[parts[0]] = parts[1]
+	}
+
+	return limits, nil
+}
+
+// ensureProject creates the project if it doesn't exist yet, sets its limits and returns the server using it
+func ensureProject(server lxd.ContainerServer, p Project) (lxd.ContainerServer, error) {
+	if p.Name == "" {
+		p.Name = DefaultProject
+	}
+
+	if p.Name == DefaultProject && len(p.Limits) == 0 {
+		return server, nil
+	}
+
+	if !server.HasExtension("projects") {
+		return nil, ErrProjectsUnsupported
+	}
+
+	project, etag, err := server.GetProject(p.Name)
+	if err != nil && !shared.IsErrNotFound(err) {
+		return nil, err
+	}
+
+	if project == nil {
+		err = createProject(server, p)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create project %s: %w", p.Name, err)
+		}
+
+		return server.UseProject(p.Name), nil
+	}
+
+	put := project.Writable()
+	changed := false
+
+	if put.Config == nil {
+		put.Config = map[string]string{}
+	}
+
+	for k, v := range p.Limits {
+		if put.Config[k] != v {
+			put.Config[k] = v
+			changed = true
+		}
+	}
+
+	if changed {
+		err = server.UpdateProject(p.Name, put, etag)
+		if err != nil {
+			return nil, fmt.Errorf("unable to set limits of project %s: %w", p.Name, err)
+		}
+	}
+
+	return server.UseProject(p.Name), nil
+}
+
+// createProject creates the project with its own images and profiles. Its default profile gets the devices and config
+// of the default profile of the default project, e.g. the root disk and nic the containers need
+func createProject(server lxd.ContainerServer, p Project) error {
+	config := map[string]string{
+		"features.images":   "true",
+		"features.profiles": "true",
+	}
+
+	for k, v := range p.Limits {
+		config[k] = v
+	}
+
+	err := server.CreateProject(api.ProjectsPost{
+		Name: p.Name,
+		ProjectPut: api.ProjectPut{
+			Description: "Created by LXE",
+			Config:      config,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	log.WithField("project", p.Name).Info("created lxd project")
+
+	def, _, err := server.GetProfile(defaultProfile)
+	if err != nil {
+		return err
+	}
+
+	ps := server.UseProject(p.Name)
+
+	_, etag, err := ps.GetProfile(defaultProfile)
+	if err != nil {
+		return err
+	}
+
+	return ps.UpdateProfile(defaultProfile, def.Writable(), etag)
+}
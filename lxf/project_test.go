@@ -0,0 +1,108 @@
+package lxf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	"github.com/automaticserver/lxe/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProjectLimits(t *testing.T) {
+	t.Parallel()
+
+	limits, err := ParseProjectLimits([]string{"limits.containers=10", "limits.memory=64GB"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"limits.containers": "10", "limits.memory": "64GB"}, limits)
+
+	for _, e := range []string{"containers=10", "limits.memory", "limits.memory="} {
+		_, err = ParseProjectLimits([]string{e})
+		assert.True(t, errors.Is(err, ErrInvalidProjectLimit), e)
+	}
+}
+
+func Test_ensureProject_Default(t *testing.T) {
+	t.Parallel()
+
+	fake := &lxdfakes.FakeContainerServer{}
+
+	server, err := ensureProject(fake, Project{})
+	assert.NoError(t, err)
+	assert.Equal(t, fake, server)
+	assert.Equal(t, 0, fake.GetProjectCallCount())
+}
+
+func Test_ensureProject_Unsupported(t *testing.T) {
+	t.Parallel()
+
+	fake := &lxdfakes.FakeContainerServer{}
+
+	_, err := ensureProject(fake, Project{Name: "k8s"})
+	assert.True(t, errors.Is(err, ErrProjectsUnsupported))
+}
+
+func Test_ensureProject_Create(t *testing.T) {
+	t.Parallel()
+
+	fake := &lxdfakes.FakeContainerServer{}
+	inProject := &lxdfakes.FakeContainerServer{}
+
+	fake.HasExtensionReturns(true)
+	fake.GetProjectReturns(nil, "", shared.NewErrNotFound())
+	fake.UseProjectReturns(inProject)
+	fake.GetProfileReturns(&api.Profile{ProfilePut: api.ProfilePut{
+		Devices: map[string]map[string]string{"root": {"type": "disk", "path": "/", "pool": "default"}},
+	}}, "", nil)
+	inProject.GetProfileReturns(&api.Profile{}, "etag", nil)
+
+	server, err := ensureProject(fake, Project{Name: "k8s", Limits: map[string]string{"limits.containers": "10"}})
+	assert.NoError(t, err)
+	assert.Equal(t, inProject, server)
+
+	assert.Equal(t, 1, fake.CreateProjectCallCount())
+	post := fake.CreateProjectArgsForCall(0)
+	assert.Equal(t, "k8s", post.Name)
+	assert.Equal(t, map[string]string{
+		"features.images":   "true",
+		"features.profiles": "true",
+		"limits.containers": "10",
+	}, post.Config)
+
+	// the default profile of the new project gets the root disk of the default project's one
+	assert.Equal(t, 1, inProject.UpdateProfileCallCount())
+	name, put, etag := inProject.UpdateProfileArgsForCall(0)
+	assert.Equal(t, "default", name)
+	assert.Equal(t, "etag", etag)
+	assert.Equal(t, "/", put.Devices["root"]["path"])
+}
+
+func Test_ensureProject_Limits(t *testing.T) {
+	t.Parallel()
+
+	fake := &lxdfakes.FakeContainerServer{}
+
+	fake.HasExtensionReturns(true)
+	fake.GetProjectReturns(&api.Project{Name: "k8s", ProjectPut: api.ProjectPut{
+		Config: map[string]string{"features.images": "true", "limits.containers": "5"},
+	}}, "etag", nil)
+	fake.UseProjectReturns(fake)
+
+	_, err := ensureProject(fake, Project{Name: "k8s", Limits: map[string]string{"limits.containers": "10"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, fake.CreateProjectCallCount())
+	assert.Equal(t, 1, fake.UpdateProjectCallCount())
+
+	name, put, etag := fake.UpdateProjectArgsForCall(0)
+	assert.Equal(t, "k8s", name)
+	assert.Equal(t, "etag", etag)
+	assert.Equal(t, map[string]string{"features.images": "true", "limits.containers": "10"}, put.Config)
+
+	// unchanged limits aren't written again
+	fake.GetProjectReturns(&api.Project{Name: "k8s", ProjectPut: put}, "etag", nil)
+
+	_, err = ensureProject(fake, Project{Name: "k8s", Limits: map[string]string{"limits.containers": "10"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.UpdateProjectCallCount())
+}
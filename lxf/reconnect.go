@@ -0,0 +1,189 @@
+package lxf // import "github.com/automaticserver/lxe/lxf"
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf/lxo"
+	"github.com/automaticserver/lxe/metrics"
+	lxd "github.com/lxc/lxd/client"
+)
+
+const (
+	// reconnectBackoff is the backoff before the second attempt to reconnect, it doubles with every further attempt
+	reconnectBackoff = 500 * time.Millisecond
+	// reconnectMaxBackoff caps the exponential backoff
+	reconnectMaxBackoff = 30 * time.Second
+)
+
+var ErrClientClosed = errors.New("client closed")
+
+// setConnection replaces the connection with the new server and its event listener, which are watched for getting
+// disconnected. Operations waiting for the connection continue on the new one
+func (l *client) setConnection(server lxd.ContainerServer, listener *lxd.EventListener) {
+	opwait := lxo.NewClient(server, l.opconf)
+	opwait.SetResumer(l.resume)
+
+	l.connMu.Lock()
+	old := l.listener
+	l.server = server
+	l.opwait = opwait
+	l.listener = listener
+
+	if l.reconnected != nil {
+		close(l.reconnected)
+		l.reconnected = nil
+	}
+	l.connMu.Unlock()
+
+	if old != nil && old != listener {
+		old.Disconnect()
+	}
+
+	l.cache.watch(server, listener)
+
+	if listener != nil {
+		go l.watchListener(server, listener)
+	}
+}
+
+// currentServer returns the server of the current connection, it might be lost already
+func (l *client) currentServer() lxd.ContainerServer {
+	l.connMu.Lock()
+	defer l.connMu.Unlock()
+
+	return l.server
+}
+
+// watchListener reconnects once the event listener gets disconnected by LXD, e.g. because it restarted
+func (l *client) watchListener(server lxd.ContainerServer, listener *lxd.EventListener) {
+	err := listener.Wait()
+
+	select {
+	case <-l.closed:
+		return
+	default:
+	}
+
+	if err == nil {
+		// disconnected by us, the connection got replaced
+		return
+	}
+
+	l.lost(server, "event listener disconnected: "+err.Error())
+}
+
+// lost marks the connection to stale as lost and starts reconnecting, if that isn't already happening. It returns the
+// channel closed once reconnected, nil if stale isn't the current connection anymore
+func (l *client) lost(stale lxd.ContainerServer, reason string) chan struct{} {
+	l.connMu.Lock()
+	defer l.connMu.Unlock()
+
+	if l.reconnected != nil {
+		return l.reconnected
+	}
+
+	if l.server != stale {
+		return nil
+	}
+
+	select {
+	case <-l.closed:
+		return nil
+	default:
+	}
+
+	log.WithField("reason", reason).Warn("lost connection to LXD, reconnecting")
+
+	l.reconnected = make(chan struct{})
+
+	go l.reconnect()
+
+	return l.reconnected
+}
+
+// reconnect connects to LXD again with an exponential backoff till it succeeds or the client is closed
+func (l *client) reconnect() {
+	backoff := reconnectBackoff
+
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-l.closed:
+			return
+		default:
+		}
+
+		if !l.reconnecting() {
+			// connected meanwhile
+			return
+		}
+
+		err := l.connect()
+		metrics.LXDReconnects.WithLabelValues(metrics.Result(err)).Inc()
+
+		if err == nil {
+			log.WithField("attempt", attempt).Info("reconnected to LXD")
+
+			return
+		}
+
+		log.WithError(err).WithField("attempt", attempt).Error("failed reconnecting to LXD")
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-l.closed:
+			timer.Stop()
+
+			return
+		case <-l.retry:
+			timer.Stop()
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if backoff > reconnectMaxBackoff {
+			backoff = reconnectMaxBackoff
+		}
+	}
+}
+
+// reconnecting checks if the connection is lost and not established again yet
+func (l *client) reconnecting() bool {
+	l.connMu.Lock()
+	defer l.connMu.Unlock()
+
+	return l.reconnected != nil
+}
+
+// retryNow wakes a reconnect waiting for its backoff up, e.g. because the LXD socket got created
+func (l *client) retryNow() {
+	select {
+	case l.retry <- struct{}{}:
+	default:
+	}
+}
+
+// resume waits till the connection lost on stale is established again and returns the new server, lxo resumes its
+// interrupted operations there
+func (l *client) resume(ctx context.Context, stale lxd.ContainerServer) (lxd.ContainerServer, error) {
+	select {
+	case <-l.closed:
+		return nil, ErrClientClosed
+	default:
+	}
+
+	reconnected := l.lost(stale, "operation interrupted")
+	if reconnected != nil {
+		select {
+		case <-reconnected:
+		case <-l.closed:
+			return nil, ErrClientClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return l.currentServer(), nil
+}
@@ -0,0 +1,72 @@
+package lxf
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/automaticserver/lxe/lxf/lxdfakes"
+	lxd "github.com/lxc/lxd/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_resume(t *testing.T) {
+	t.Parallel()
+
+	client, stale := testClient()
+	// connecting fails, the socket doesn't exist
+	client.socket = "/nonexistent/lxd.socket"
+
+	defer client.Close()
+
+	// nothing to wait for, the connection was already replaced
+	server, err := client.resume(ctx, &lxdfakes.FakeContainerServer{})
+	assert.NoError(t, err)
+	assert.Exactly(t, stale, server)
+	assert.False(t, client.reconnecting())
+
+	resumed := make(chan lxd.ContainerServer)
+
+	for i := 0; i < 2; i++ {
+		go func() {
+			server, err := client.resume(ctx, stale)
+			assert.NoError(t, err)
+			resumed <- server
+		}()
+	}
+
+	assert.Eventually(t, client.reconnecting, time.Second, time.Millisecond)
+
+	select {
+	case <-resumed:
+		t.Fatal("resumed before reconnected")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	fake := &lxdfakes.FakeContainerServer{}
+	client.setConnection(fake, nil)
+
+	assert.Exactly(t, fake, <-resumed)
+	assert.Exactly(t, fake, <-resumed)
+	assert.False(t, client.reconnecting())
+	assert.Exactly(t, fake, client.GetServer())
+}
+
+func TestClient_resume_Done(t *testing.T) {
+	t.Parallel()
+
+	client, stale := testClient()
+	client.socket = "/nonexistent/lxd.socket"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.resume(ctx, stale)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	client.Close()
+
+	_, err = client.resume(context.Background(), stale)
+	assert.True(t, errors.Is(err, ErrClientClosed))
+}
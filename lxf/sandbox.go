@@ -1,7 +1,6 @@
 package lxf // import "github.com/automaticserver/lxe/lxf"
 
 import (
-	"crypto/md5" // nolint: gosec
 	"fmt"
 	"strconv"
 	"strings"
@@ -12,7 +11,6 @@ import (
 	"github.com/automaticserver/lxe/shared"
 	"github.com/ghodss/yaml"
 	"github.com/lxc/lxd/shared/api"
-	"k8s.io/apimachinery/pkg/util/uuid"
 )
 
 const (
@@ -23,12 +21,15 @@ const (
 
 	cfgHostname                 = "user.host_name"
 	cfgLogDirectory             = "user.log_directory"
+	cfgRuntimeHandler           = "user.runtime_handler"
 	cfgCreatedAt                = "user.created_at"
 	cfgNetworkConfig            = "user.networkconfig"
 	cfgNetworkConfigNameservers = cfgNetworkConfig + ".nameservers"
 	cfgNetworkConfigSearches    = cfgNetworkConfig + ".searches"
+	cfgNetworkConfigOptions     = cfgNetworkConfig + ".options"
 	cfgNetworkConfigMode        = cfgNetworkConfig + ".mode"
 	cfgNetworkConfigModeData    = cfgNetworkConfig + ".modedata"
+	cfgNetworkConfigGeneration  = cfgNetworkConfig + ".generation"
 	cfgCloudInitNetworkConfig   = "user.network-config" // write-only field
 	cfgCloudInitVendorData      = "user.vendor-data"    // write-only field
 )
@@ -39,6 +40,7 @@ var (
 			cfgLogDirectory,
 			cfgState,
 			cfgHostname,
+			cfgRuntimeHandler,
 			cfgCloudInitNetworkConfig,
 			cfgCloudInitVendorData,
 			cfgNetworkConfigModeData,
@@ -70,8 +72,10 @@ type Sandbox struct {
 	NetworkConfig NetworkConfig
 	// State contains the current state of this sandbox
 	State SandboxState
-	// LogDirectory TODO, to be implemented?
+	// LogDirectory is the directory the CRI logs of the containers are written to
 	LogDirectory string
+	// RuntimeHandler the sandbox was created with, empty for the default handler
+	RuntimeHandler string
 	// CloudInitNetworkConfigEntries to set
 	CloudInitNetworkConfigEntries []cloudinit.NetworkConfigEntryPhysical
 
@@ -102,10 +106,14 @@ type SandboxMetadata struct {
 type NetworkConfig struct {
 	Nameservers []string
 	Searches    []string
+	// Options of the resolver, like ndots:5
+	Options []string
 	// Mode describes the type of networking
 	Mode NetworkMode
 	// ModeData allows Mode-specific data to be persisted
 	ModeData map[string]string
+	// Generation of the network plugin configuration the sandbox network was set up with
+	Generation string
 }
 
 // NetworkMode defines the type of the container network
@@ -222,6 +230,8 @@ func (s *Sandbox) Stop() error {
 // Delete will delete the given sandbox, returns nil when sandbox is already deleted
 func (s *Sandbox) Delete() error {
 	err := s.client.server.DeleteProfile(s.ID)
+	s.client.cache.changedProfile(s.ID)
+
 	if err != nil {
 		if shared.IsErrNotFound(err) {
 			return nil
@@ -238,16 +248,23 @@ func (s *Sandbox) apply() error {
 	config := map[string]string{
 		cfgState:                    s.State.String(),
 		cfgIsCRI:                    strconv.FormatBool(true),
-		cfgCreatedAt:                strconv.FormatInt(s.CreatedAt.UnixNano(), 10),
+		cfgCreatedAt:                strconv.FormatInt(UnixNano(s.CreatedAt), 10),
 		cfgMetaAttempt:              strconv.FormatUint(uint64(s.Metadata.Attempt), 10),
 		cfgMetaName:                 s.Metadata.Name,
 		cfgMetaNamespace:            s.Metadata.Namespace,
 		cfgMetaUID:                  s.Metadata.UID,
 		cfgHostname:                 s.Hostname,
 		cfgLogDirectory:             s.LogDirectory,
+		cfgRuntimeHandler:           s.RuntimeHandler,
 		cfgNetworkConfigNameservers: strings.Join(s.NetworkConfig.Nameservers, ","),
 		cfgNetworkConfigSearches:    strings.Join(s.NetworkConfig.Searches, ","),
+		cfgNetworkConfigOptions:     strings.Join(s.NetworkConfig.Options, ","),
 		cfgNetworkConfigMode:        s.NetworkConfig.Mode.String(),
+		cfgNetworkConfigGeneration:  s.NetworkConfig.Generation,
+	}
+
+	if s.Owner != "" {
+		config[cfgOwner] = s.Owner
 	}
 
 	// write NetworkConfigData as yaml
@@ -303,12 +320,13 @@ func (s *Sandbox) apply() error {
 
 	config[cfgCloudInitNetworkConfig] = string(yml)
 
-	// write cloud-init vendor data if we have hostname and search
+	// write cloud-init vendor data if we have hostname. LXE writes the hosts file of pods with their own network, so
+	// cloud-init may only manage it in the host network
 	if s.Hostname != "" {
 		config[cfgCloudInitVendorData] = fmt.Sprintf(`#cloud-config
 hostname: %s
-manage_etc_hosts: true
-`, s.Hostname)
+manage_etc_hosts: %t
+`, s.Hostname, s.NetworkConfig.Mode == NetworkHost)
 	}
 
 	devices := make(map[string]map[string]string)
@@ -325,7 +343,12 @@ manage_etc_hosts: true
 	}
 
 	if s.ID == "" { // profile has to be created
-		s.ID = s.CreateID()
+		s.ID, err = freeName(s.name, s.client.profileExists)
+		if err != nil {
+			return err
+		}
+
+		defer s.client.cache.changedProfile(s.ID)
 
 		return s.client.server.CreateProfile(api.ProfilesPost{
 			Name:       s.ID,
@@ -338,6 +361,8 @@ manage_etc_hosts: true
 	}
 
 	err = s.client.server.UpdateProfile(s.ID, profile, s.ETag)
+	s.client.cache.changedProfile(s.ID)
+
 	if err != nil {
 		if shared.IsErrNotFound(err) {
 			return fmt.Errorf("sandbox %w: %s", shared.NewErrNotFound(), s.ID)
@@ -349,8 +374,13 @@ manage_etc_hosts: true
 	return nil
 }
 
-// CreateID creates a unique profile id
+// CreateID returns the profile id, derived from the namespace, name, uid and attempt of the pod
 func (s *Sandbox) CreateID() string {
-	bin := md5.Sum([]byte(uuid.NewUUID())) // nolint: gosec
-	return string(s.Metadata.Name[0]) + b32lowerEncoder.EncodeToString(bin[:])[:15]
+	return s.name(0)
+}
+
+// name returns the name of the profile, another one for each salt greater than 0
+func (s *Sandbox) name(salt int) string {
+	return makeName(s.namePrefix, fmt.Sprintf("%s/%s/%s/%d", s.Metadata.Namespace, s.Metadata.Name, s.Metadata.UID,
+		s.Metadata.Attempt), salt, s.Metadata.Namespace, s.Metadata.Name)
 }
@@ -2,6 +2,7 @@
 package lxf // import "github.com/automaticserver/lxe/lxf"
 
 import (
+	"context"
 	"strconv"
 	"time"
 
@@ -154,7 +155,8 @@ func (m *MigrationWorkspace) Ensure() error { // nolint: gocognit
 		if counter > 0 {
 			anyChanges = true
 
-			err := m.lxf.opwait.UpdateContainer(c.Name, c.Writable(), etag)
+			// the migration runs on connect and isn't bound to a request
+			err := m.lxf.opwait.UpdateContainer(context.Background(), c.Name, c.Writable(), etag)
 			if err != nil {
 				return err
 			}
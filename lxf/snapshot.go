@@ -0,0 +1,82 @@
+package lxf // import "github.com/automaticserver/lxe/lxf"
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/automaticserver/lxe/shared"
+)
+
+// Snapshot is a LXD snapshot of a container
+type Snapshot struct {
+	// Name of the snapshot, without the container name
+	Name string
+	// CreatedAt is when the snapshot was taken
+	CreatedAt time.Time
+	// Stateful is true if the runtime state of the container was saved as well
+	Stateful bool
+}
+
+// CreateSnapshot takes a snapshot of the container
+func (l *client) CreateSnapshot(ctx context.Context, cid, name string, stateful bool) error {
+	err := l.opwait.SnapshotContainer(ctx, cid, name, stateful)
+	if err != nil && shared.IsErrNotFound(err) {
+		return fmt.Errorf("container %w: %s", shared.NewErrNotFound(), cid)
+	}
+
+	return err
+}
+
+// RestoreSnapshot restores the container to the snapshot
+func (l *client) RestoreSnapshot(ctx context.Context, cid, name string, stateful bool) error {
+	err := l.opwait.RestoreSnapshot(ctx, cid, name, stateful)
+	l.cache.changedContainer(cid)
+
+	if err != nil && shared.IsErrNotFound(err) {
+		return fmt.Errorf("snapshot %w: %s/%s", shared.NewErrNotFound(), cid, name)
+	}
+
+	return err
+}
+
+// ListSnapshots returns the snapshots of the container
+func (l *client) ListSnapshots(cid string) ([]Snapshot, error) {
+	snaps, err := l.opwait.ListSnapshots(cid)
+	if err != nil {
+		if shared.IsErrNotFound(err) {
+			return nil, fmt.Errorf("container %w: %s", shared.NewErrNotFound(), cid)
+		}
+
+		return nil, err
+	}
+
+	res := make([]Snapshot, 0, len(snaps))
+
+	for _, s := range snaps {
+		// depending on the LXD version the name is prefixed with the container name
+		name := s.Name
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+
+		res = append(res, Snapshot{
+			Name:      name,
+			CreatedAt: s.CreatedAt,
+			Stateful:  s.Stateful,
+		})
+	}
+
+	return res, nil
+}
+
+// DeleteSnapshot deletes the snapshot of the container, returns nil if the snapshot is already deleted
+func (l *client) DeleteSnapshot(ctx context.Context, cid, name string) error {
+	err := l.opwait.DeleteSnapshot(ctx, cid, name)
+	if err != nil && shared.IsErrNotFound(err) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,55 @@
+package lxf
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/automaticserver/lxe/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_ListSnapshots(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+
+	now := time.Now()
+	fake.GetContainerSnapshotsReturns([]api.ContainerSnapshot{
+		{Name: "foo/snap0", CreatedAt: now},
+		{Name: "snap1", CreatedAt: now, Stateful: true},
+	}, nil)
+
+	snaps, err := client.ListSnapshots("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, []Snapshot{
+		{Name: "snap0", CreatedAt: now},
+		{Name: "snap1", CreatedAt: now, Stateful: true},
+	}, snaps)
+}
+
+func TestClient_ListSnapshots_NotFound(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	fake.GetContainerSnapshotsReturns(nil, shared.NewErrNotFound())
+
+	_, err := client.ListSnapshots("foo")
+	assert.True(t, shared.IsErrNotFound(err))
+}
+
+func TestClient_DeleteSnapshot_NotFound(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	fake.DeleteContainerSnapshotReturns(nil, shared.NewErrNotFound())
+
+	err := client.DeleteSnapshot(ctx, "foo", "snap0")
+	assert.NoError(t, err)
+
+	fake.DeleteContainerSnapshotReturns(nil, errors.New("something failed"))
+
+	err = client.DeleteSnapshot(ctx, "foo", "snap0")
+	assert.Error(t, err)
+}
@@ -0,0 +1,91 @@
+package lxf // import "github.com/automaticserver/lxe/lxf"
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	lxd "github.com/lxc/lxd/client"
+)
+
+var ErrNotRegularFile = errors.New("not a regular file")
+
+// ReadFileTail returns the last max bytes of the regular file at the absolute path in the container. A stopped container
+// only has the files of its root disk
+func (c *Container) ReadFileTail(p string, max int) ([]byte, error) {
+	content, resp, err := c.client.server.GetContainerFile(c.ID, p)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", p, err)
+	}
+	if content != nil {
+		defer content.Close()
+	}
+
+	if resp.Type != fileTypeFile {
+		return nil, fmt.Errorf("%w: %v is a %v", ErrNotRegularFile, p, resp.Type)
+	}
+
+	return tail(content, max)
+}
+
+// ConsoleLogTail returns the last lines of the console log of the container, all of them if lines is 0, at most max
+// bytes. LXD keeps the console log of a stopped container until it's started again
+func (c *Container) ConsoleLogTail(lines, max int) ([]byte, error) {
+	content, err := c.client.server.GetContainerConsoleLog(c.ID, &lxd.ContainerConsoleLogArgs{})
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	b, err := tail(content, max)
+	if err != nil {
+		return nil, err
+	}
+
+	if lines == 0 {
+		return b, nil
+	}
+
+	return lastLines(b, lines), nil
+}
+
+// tail reads r to the end and returns the last max bytes of it
+func tail(r io.Reader, max int) ([]byte, error) {
+	buf := make([]byte, 0, 2*max)
+	chunk := make([]byte, max)
+
+	for {
+		n, err := r.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+
+		// only the last max bytes are kept, moving them to the front once the buffer is full
+		if len(buf) > max && cap(buf)-len(buf) < max {
+			buf = append(buf[:0], buf[len(buf)-max:]...)
+		}
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(buf) > max {
+		buf = buf[len(buf)-max:]
+	}
+
+	return buf, nil
+}
+
+// lastLines returns the last n lines of b, a trailing newline doesn't start another line
+func lastLines(b []byte, n int) []byte {
+	end := len(bytes.TrimSuffix(b, []byte("\n")))
+
+	i := end
+	for ; n > 0 && i >= 0; n-- {
+		i = bytes.LastIndexByte(b[:i], '\n')
+	}
+
+	return b[i+1:]
+}
@@ -0,0 +1,79 @@
+package lxf
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainer_ReadFileTail(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	c := client.NewContainer("sandbox")
+	c.ID = "abc"
+
+	fake.GetContainerFileReturns(ioutil.NopCloser(strings.NewReader("0123456789")), &lxd.ContainerFileResponse{Type: "file"}, nil)
+
+	b, err := c.ReadFileTail("/dev/termination-log", 4)
+	assert.NoError(t, err)
+	assert.Equal(t, "6789", string(b))
+
+	id, p := fake.GetContainerFileArgsForCall(0)
+	assert.Equal(t, "abc", id)
+	assert.Equal(t, "/dev/termination-log", p)
+}
+
+func TestContainer_ReadFileTail_Directory(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	c := client.NewContainer("sandbox")
+	c.ID = "abc"
+
+	fake.GetContainerFileReturns(nil, &lxd.ContainerFileResponse{Type: "directory"}, nil)
+
+	_, err := c.ReadFileTail("/dev", 4)
+	assert.True(t, errors.Is(err, ErrNotRegularFile))
+}
+
+func TestContainer_ConsoleLogTail(t *testing.T) {
+	t.Parallel()
+
+	client, fake := testClient()
+	c := client.NewContainer("sandbox")
+	c.ID = "abc"
+
+	fake.GetContainerConsoleLogReturns(ioutil.NopCloser(strings.NewReader("one\ntwo\nthree\nfour\n")), nil)
+
+	b, err := c.ConsoleLogTail(2, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, "three\nfour\n", string(b))
+}
+
+func TestTail(t *testing.T) {
+	t.Parallel()
+
+	content := strings.Repeat("abcdefghij", 100) + "end"
+
+	b, err := tail(strings.NewReader(content), 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "ghijend", string(b))
+
+	b, err = tail(strings.NewReader("short"), 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "short", string(b))
+}
+
+func TestLastLines(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "b\nc\n", string(lastLines([]byte("a\nb\nc\n"), 2)))
+	assert.Equal(t, "b\nc", string(lastLines([]byte("a\nb\nc"), 2)))
+	assert.Equal(t, "a\nb\nc", string(lastLines([]byte("a\nb\nc"), 5)))
+	assert.Equal(t, "", string(lastLines([]byte(""), 2)))
+}
@@ -2,6 +2,8 @@ package lxf // import "github.com/automaticserver/lxe/lxf"
 
 import (
 	"encoding/base32"
+	"strconv"
+	"time"
 )
 
 var (
@@ -26,3 +28,42 @@ func AppendIfSet(s *map[string]string, key, value string) {
 		}
 	}
 }
+
+// UnixNano returns the nanoseconds elapsed since the unix epoch, which is how timestamps are persisted and reported to
+// kubelet. The zero time is 0, as time.Time{}.UnixNano() is outside of the int64 range and would report a bogus value
+func UnixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UnixNano()
+}
+
+// parseUnixNano parses a persisted timestamp of UnixNano. Missing values and values of 0 or below (as written by
+// former versions for the zero time) are the zero time
+func parseUnixNano(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if n <= 0 {
+		return time.Time{}, nil
+	}
+
+	return time.Unix(0, n), nil
+}
+
+// notBefore returns t, but at least min. The wall clock can be set back between two events, but kubelet expects the
+// timestamps of a container in order
+func notBefore(t, min time.Time) time.Time {
+	if t.Before(min) {
+		return min
+	}
+
+	return t
+}
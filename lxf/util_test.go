@@ -0,0 +1,30 @@
+package lxf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnixNano(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, int64(0), UnixNano(time.Time{}))
+	assert.Equal(t, int64(1500), UnixNano(time.Unix(0, 1500)))
+}
+
+func Test_parseUnixNano(t *testing.T) {
+	t.Parallel()
+
+	ts, err := parseUnixNano("1500")
+	assert.NoError(t, err)
+	assert.True(t, ts.Equal(time.Unix(0, 1500)))
+
+	ts, err = parseUnixNano("")
+	assert.NoError(t, err)
+	assert.True(t, ts.IsZero())
+
+	_, err = parseUnixNano("abc")
+	assert.Error(t, err)
+}
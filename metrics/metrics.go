@@ -0,0 +1,179 @@
+// Package metrics contains the prometheus metrics of LXE. The packages observe their metrics here, the cri package
+// serves the Registry
+package metrics // import "github.com/automaticserver/lxe/metrics"
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Namespace of all LXE metrics
+const Namespace = "lxe"
+
+// Result labels of observations
+const (
+	ResultSuccess = "success"
+	ResultError   = "error"
+)
+
+var (
+	// CRIRequestDuration observes the latency of CRI calls by method
+	CRIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "cri",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of CRI calls by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	// CRIRequestErrors counts the failed CRI calls by method
+	CRIRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "cri",
+		Name:      "request_errors_total",
+		Help:      "Number of failed CRI calls by method.",
+	}, []string{"method"})
+
+	// CRIQueueDepth is the number of CRI calls waiting for the concurrency or rate limit by method
+	CRIQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "cri",
+		Name:      "queue_depth",
+		Help:      "Number of CRI calls waiting for the concurrency or rate limit by method.",
+	}, []string{"method"})
+
+	// CRIQueueWait observes how long CRI calls waited for the concurrency or rate limit by method
+	CRIQueueWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "cri",
+		Name:      "queue_wait_seconds",
+		Help:      "Time CRI calls waited for the concurrency or rate limit by method.",
+		Buckets:   []float64{.001, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"method"})
+
+	// LXDOperationDuration observes how long LXD operations took till they were done by operation and result
+	LXDOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "lxd",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of LXD operations by operation and result.",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"operation", "result"})
+
+	// LXDOperationRetries counts the retried LXD operations by operation
+	LXDOperationRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "lxd",
+		Name:      "operation_retries_total",
+		Help:      "Number of retried LXD operations by operation.",
+	}, []string{"operation"})
+
+	// LXDReconnects counts the attempts to reconnect to LXD after the connection got lost by result
+	LXDReconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "lxd",
+		Name:      "reconnects_total",
+		Help:      "Number of attempts to reconnect to LXD by result.",
+	}, []string{"result"})
+
+	// CNIFailures counts the failed CNI calls by phase, which is either setup, teardown or gc
+	CNIFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "cni",
+		Name:      "failures_total",
+		Help:      "Number of failed CNI calls by phase.",
+	}, []string{"phase"})
+
+	// CNIConfigReloads counts the reloads of the cni config after the conf dir changed by result
+	CNIConfigReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "cni",
+		Name:      "config_reloads_total",
+		Help:      "Number of reloads of the CNI config after the conf dir changed by result.",
+	}, []string{"result"})
+
+	// CNIConfigInfo is 1 for the active cni network config by network name and cni version
+	CNIConfigInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "cni",
+		Name:      "config_info",
+		Help:      "Active CNI network config by network name and CNI version, the value is always 1.",
+	}, []string{"network", "cni_version"})
+
+	// ImagePullDuration observes how long image pulls took by result
+	ImagePullDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "image",
+		Name:      "pull_duration_seconds",
+		Help:      "Duration of image pulls by result.",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200},
+	}, []string{"result"})
+
+	// QuotaResources is the sum of the requests and limits of the containers and the node allocatable by resource and
+	// kind, cpu in cores and memory in bytes
+	QuotaResources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "quota",
+		Name:      "resources",
+		Help:      "Sum of the requests and limits of the containers and the node allocatable by resource and kind, cpu in cores and memory in bytes.",
+	}, []string{"resource", "kind"})
+
+	// QuotaUnlimited is the number of containers without limit by resource
+	QuotaUnlimited = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "quota",
+		Name:      "unlimited_containers",
+		Help:      "Number of containers without limit by resource.",
+	}, []string{"resource"})
+
+	// QuotaOvercommitted is 1 if the containers exceed the node allocatable in a way kubelet doesn't account for
+	QuotaOvercommitted = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "quota",
+		Name:      "overcommitted",
+		Help:      "1 if the cpu requests or memory limits of the containers exceed the node allocatable by resource.",
+	}, []string{"resource"})
+
+	// Registry contains all LXE metrics as well as the go runtime and process metrics
+	Registry = newRegistry()
+)
+
+func newRegistry() *prometheus.Registry {
+	r := prometheus.NewRegistry()
+
+	r.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		CRIRequestDuration,
+		CRIRequestErrors,
+		CRIQueueDepth,
+		CRIQueueWait,
+		LXDOperationDuration,
+		LXDOperationRetries,
+		LXDReconnects,
+		CNIFailures,
+		CNIConfigReloads,
+		CNIConfigInfo,
+		ImagePullDuration,
+		QuotaResources,
+		QuotaUnlimited,
+		QuotaOvercommitted,
+	)
+
+	return r
+}
+
+// Result returns the result label of the error
+func Result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+
+	return ResultSuccess
+}
+
+// Since returns the seconds elapsed since start, for observing durations
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
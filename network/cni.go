@@ -10,25 +10,100 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/automaticserver/lxe/annotation"
+	"github.com/automaticserver/lxe/metrics"
+	"github.com/automaticserver/lxe/network/netns"
+	"github.com/automaticserver/lxe/tracing"
 	"github.com/containernetworking/cni/libcni"
 	"github.com/containernetworking/cni/pkg/invoke"
 	"github.com/containernetworking/cni/pkg/types"
-	"github.com/containernetworking/cni/pkg/types/current"
+	types100 "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/containernetworking/cni/pkg/types/create"
+	"github.com/containernetworking/cni/pkg/version"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
 )
 
 const (
 	DefaultCNIbinPath   = "/opt/cni/bin"
 	DefaultCNIconfPath  = "/etc/cni/net.d"
-	defaultCNInetnsPath = "/run/netns"
+	DefaultCNInetnsPath = "/run/netns"
+
+	// dataResult contains the cni result of the pod network
+	dataResult = "result"
+	// statusTimeout is how long the plugins have to report their status
+	statusTimeout = 10 * time.Second
+	// legacyResultVersion is the version of saved cni results without version
+	legacyResultVersion = "0.4.0"
+	// dataNetns contains the path of the pod network namespace, if the pod has one
+	dataNetns = "netns"
 )
 
 var (
-	ErrNoUpdateRuntimeConfig = errors.New("cniPlugin can't update runtime config")
-	ErrNoNetworksFound       = errors.New("no valid networks found")
+	// confExtensions are the file extensions of the cni configs in the conf dir
+	confExtensions = []string{".conf", ".conflist", ".json"}
+
+	ErrNoNetworksFound = errors.New("no valid networks found")
+	ErrNetworkNotReady = errors.New("network not ready")
+	ErrIPPoolExhausted = errors.New("ip pool exhausted")
+
+	// ipPoolExhaustedMessages contains the error messages of common ipam plugins when no address is left
+	ipPoolExhaustedMessages = []string{
+		"no IP addresses available",   // host-local
+		"no more free addresses",      // whereabouts
+		"no addresses available",      // dhcp
+		"no free addresses available", // calico-ipam
+		"ipam exhausted",
+	}
+
+	// teardownNotFoundMessages contain the error messages of plugins failing a teardown as something to remove is
+	// already gone
+	teardownNotFoundMessages = []string{
+		"not found",
+		"no such file or directory",
+		"does not exist",
+		"no such device",
+	}
+
+	// ipPoolExhaustedTotal counts how many times a pod network setup failed due to an exhausted ip pool
+	ipPoolExhaustedTotal uint64
 )
 
+// IsIPPoolExhausted checks if the error is caused by an exhausted ip pool
+func IsIPPoolExhausted(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, ErrIPPoolExhausted) {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, m := range ipPoolExhaustedMessages {
+		if strings.Contains(msg, strings.ToLower(m)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IPPoolExhaustedTotal returns how many times a pod network setup failed due to an exhausted ip pool
+func IPPoolExhaustedTotal() uint64 {
+	return atomic.LoadUint64(&ipPoolExhaustedTotal)
+}
+
+// PodNetns returns the path of the pod network namespace from the data of the pod network, empty if it has none
+func PodNetns(data map[string]string) string {
+	return data[dataNetns]
+}
+
 // ConfCNI are configuration options for the cni plugin. All properties are optional and get a default value
 type ConfCNI struct {
 	BinPath   string
@@ -36,6 +111,19 @@ type ConfCNI struct {
 	NetnsPath string
 	// CNI output will be written to OutputWriter
 	OutputWriter io.Writer
+	// Tuning is applied to the pod interface after the cni plugins attached it
+	Tuning Tuning
+	// PodNetns sets up the network once per pod in a network namespace pinned in NetnsPath, which the containers of the
+	// pod join. Otherwise the network is set up in the network namespace of the started container
+	PodNetns bool
+	// PodCIDRBridge is the name of the bridge of the conflist written to ConfPath when kubelet provides the pod CIDR. If
+	// empty, the pod CIDR is ignored and the cni configs are left to the network addon
+	PodCIDRBridge string
+	// DefaultConf writes the bridge conflist for the pod CIDR with DefaultCNIBridge as DefaultConfFile if there's no
+	// other cni config in ConfPath, so single node setups work without a network addon. PodCIDRBridge has priority
+	DefaultConf bool
+	// WatchConfDir watches ConfPath and reloads the cni config when it changes, instead of loading it for every pod
+	WatchConfDir bool
 }
 
 func (c *ConfCNI) setDefaults() {
@@ -48,7 +136,7 @@ func (c *ConfCNI) setDefaults() {
 	}
 
 	if c.NetnsPath == "" {
-		c.NetnsPath = defaultCNInetnsPath
+		c.NetnsPath = DefaultCNInetnsPath
 	}
 }
 
@@ -57,6 +145,12 @@ type cniPlugin struct {
 	noopPlugin // every method not implemented is noop
 	cni        libcni.CNI
 	conf       ConfCNI
+	// exhaustedSince is the unix nano timestamp since when the ip pool is exhausted, zero if it isn't
+	exhaustedSince int64
+	// watch keeps the cni config loaded when the conf dir changes, nil if WatchConfDir is disabled
+	watch *confWatch
+	// gcMu is held by GC, so no network is set up between listing the valid attachments and the garbage collection
+	gcMu sync.RWMutex
 }
 
 // InitPluginCNI instantiates the cni plugin using the provided config
@@ -65,39 +159,139 @@ func InitPluginCNI(conf ConfCNI) (*cniPlugin, error) { // nolint: golint // inte
 
 	exec := &invoke.DefaultExec{RawExec: &invoke.RawExec{Stderr: conf.OutputWriter}}
 
-	return &cniPlugin{
+	p := &cniPlugin{
 		cni:  libcni.NewCNIConfig([]string{conf.BinPath}, exec),
 		conf: conf,
-	}, nil
+	}
+
+	if conf.WatchConfDir {
+		err := p.watchConfDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
 }
 
 // PodNetwork enters a pod network environment context
 func (p *cniPlugin) PodNetwork(id string, annotations map[string]string) (PodNetwork, error) {
-	netList, warnings, err := p.getCNINetworkConfig()
+	netList, warnings, err := p.networkConfig()
 	if err != nil {
 		return nil, fmt.Errorf("%w, %v", err, warnings)
 	}
 
 	runtimeConf := p.getCNIRuntimeConf(id)
+	setStaticAddresses(runtimeConf, annotations)
 
 	return &cniPodNetwork{
 		plugin:      p,
 		netList:     netList,
 		runtimeConf: runtimeConf,
 		annotations: annotations,
+		id:          id,
 	}, nil
 }
 
-// UpdateRuntimeConfig is called when there are updates to the configuration which the plugin might need to apply
-func (p *cniPlugin) UpdateRuntimeConfig(_ *rtApi.RuntimeConfig) error {
-	return ErrNoUpdateRuntimeConfig
+// Status returns error if the plugin is in error state. Without a valid network config no pod can be set up. Plugins
+// of cni spec 1.1.0 and later report with STATUS if they are ready to set up pods
+func (p *cniPlugin) Status() error {
+	netList, _, err := p.networkConfig()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), statusTimeout)
+	defer cancel()
+
+	err = p.cni.GetStatusNetworkList(ctx, netList)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrNetworkNotReady, netList.Name, err)
+	}
+
+	since := atomic.LoadInt64(&p.exhaustedSince)
+	if since != 0 {
+		return fmt.Errorf("%w since %s", ErrIPPoolExhausted, time.Unix(0, since).Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// UpdateRuntimeConfig is called when there are updates to the configuration which the plugin might need to apply. The
+// bridge conflist is written for the pod CIDR if PodCIDRBridge is set, or with DefaultConf if there's no other config
+func (p *cniPlugin) UpdateRuntimeConfig(conf *rtApi.RuntimeConfig) error {
+	cidr := conf.GetNetworkConfig().GetPodCidr()
+	if cidr == "" {
+		return nil
+	}
+
+	switch {
+	case p.conf.PodCIDRBridge != "":
+		return p.writePodCIDRConf(cidr, PodCIDRConfFile)
+	case p.conf.DefaultConf:
+		has, err := p.hasOtherConf()
+		if err != nil || has {
+			return err
+		}
+
+		return p.writePodCIDRConf(cidr, DefaultConfFile)
+	}
+
+	return nil
+}
+
+// GC lets the plugins of cni spec 1.1.0 and later release the resources of attachments which no longer exist, like
+// leaked ip allocations. podIDs returns the pods whose attachments are still valid, no network is set up meanwhile
+func (p *cniPlugin) GC(ctx context.Context, podIDs func() ([]string, error)) error {
+	netList, _, err := p.networkConfig()
+	if err != nil {
+		return err
+	}
+
+	p.gcMu.Lock()
+	defer p.gcMu.Unlock()
+
+	ids, err := podIDs()
+	if err != nil {
+		return err
+	}
+
+	valid := make([]types.GCAttachment, 0, len(ids))
+	for _, id := range ids {
+		valid = append(valid, types.GCAttachment{ContainerID: id, IfName: DefaultInterface})
+	}
+
+	ctx, span := startSpan(ctx, "gc", netList)
+
+	err = p.cni.GCNetworkList(ctx, netList, &libcni.GCArgs{ValidAttachments: valid})
+
+	tracing.End(span, err)
+
+	if err != nil {
+		metrics.CNIFailures.WithLabelValues("gc").Inc()
+		return fmt.Errorf("%s: %w", netList.Name, err)
+	}
+
+	return nil
+}
+
+// startSpan starts the span of the cni verb invoked on the plugins of the network
+func startSpan(ctx context.Context, verb string, netList *libcni.NetworkConfigList) (context.Context, trace.Span) {
+	if netList == nil {
+		return tracing.Start(ctx, "cni "+verb)
+	}
+
+	return tracing.Start(ctx, "cni "+verb,
+		attribute.String("cni.network", netList.Name),
+		attribute.String("cni.plugins", pluginTypes(netList)),
+	)
 }
 
 // getCNINetworkConfig looks into the cni configuration dir for configs to load
 func (p *cniPlugin) getCNINetworkConfig() (*libcni.NetworkConfigList, error, error) {
 	confDir := p.conf.ConfPath
 
-	files, err := libcni.ConfFiles(confDir, []string{".conf", ".conflist", ".json"})
+	files, err := libcni.ConfFiles(confDir, confExtensions)
 
 	switch {
 	case err != nil:
@@ -165,6 +359,31 @@ func (p *cniPlugin) getCNIRuntimeConf(id string) *libcni.RuntimeConf {
 	}
 }
 
+// setStaticAddresses passes the static ip and mac of the pod annotations to the plugins, as capability args to the
+// plugins declaring the capabilities ips and mac and as CNI_ARGS to all others
+func setStaticAddresses(runtimeConf *libcni.RuntimeConf, annotations map[string]string) {
+	ip, hasIP := annotation.IP.Get(annotations)
+	mac, hasMAC := annotation.MAC.Get(annotations)
+
+	if !hasIP && !hasMAC {
+		return
+	}
+
+	runtimeConf.CapabilityArgs = map[string]interface{}{}
+	// plugins fail on CNI_ARGS they don't know otherwise
+	runtimeConf.Args = append(runtimeConf.Args, [2]string{"IgnoreUnknown", "1"})
+
+	if hasIP {
+		runtimeConf.CapabilityArgs["ips"] = []string{ip}
+		runtimeConf.Args = append(runtimeConf.Args, [2]string{"IP", ip})
+	}
+
+	if hasMAC {
+		runtimeConf.CapabilityArgs["mac"] = mac
+		runtimeConf.Args = append(runtimeConf.Args, [2]string{"MAC", mac})
+	}
+}
+
 // cniPodNetwork is a pod network environment context
 type cniPodNetwork struct {
 	noopPodNetwork // every method not implemented is noop
@@ -172,6 +391,7 @@ type cniPodNetwork struct {
 	netList        *libcni.NetworkConfigList
 	runtimeConf    *libcni.RuntimeConf
 	annotations    map[string]string
+	id             string
 }
 
 // ContainerNetwork enters a container network environment context
@@ -185,7 +405,7 @@ func (s *cniPodNetwork) ContainerNetwork(id string, annotations map[string]strin
 
 // Status reports IP and any error with the network of that pod
 func (s *cniPodNetwork) Status(ctx context.Context, prop *PropertiesRunning) (*Status, error) {
-	ips, err := s.ips([]byte(prop.Data["result"]))
+	ips, err := s.ips([]byte(prop.Data[dataResult]))
 	if err != nil {
 		return nil, err
 	}
@@ -193,38 +413,136 @@ func (s *cniPodNetwork) Status(ctx context.Context, prop *PropertiesRunning) (*S
 	return &Status{IPs: ips}, nil
 }
 
+// WhenStarted is called when the pod is started. With ConfCNI.PodNetns the network namespace of the pod is created and
+// set up here, otherwise it's done when the container is started
+func (s *cniPodNetwork) WhenStarted(ctx context.Context, prop *PropertiesRunning) (*Result, error) {
+	if !s.plugin.conf.PodNetns || prop.Data[dataNetns] != "" {
+		return nil, nil
+	}
+
+	// a unique namespace per start, the one of a recreated pod with the same id might not be removed yet
+	path, err := netns.Create(s.plugin.conf.NetnsPath, s.id)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.setup(ctx, path)
+	if err != nil {
+		_ = netns.Remove(path)
+		return nil, err
+	}
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]string{dataResult: string(b), dataNetns: path}
+
+	err = s.plugin.conf.Tuning.applyNetns(ctx, path, s.runtimeConf.IfName)
+	if err != nil {
+		_ = s.teardown(ctx, data)
+		_ = netns.Remove(path)
+
+		return nil, err
+	}
+
+	return &Result{Data: data}, nil
+}
+
+// WhenStopped is called when the pod is stopped. The network of a pod network namespace is torn down to release its
+// ip, the namespace is kept till the pod is deleted
+func (s *cniPodNetwork) WhenStopped(ctx context.Context, prop *Properties) error {
+	if prop.Data[dataNetns] == "" {
+		return nil
+	}
+
+	return s.teardown(ctx, prop.Data)
+}
+
+// WhenDeleted is called when the pod is deleted. The network and the namespace of a pod network namespace are removed,
+// the namespace also if the teardown failed
+func (s *cniPodNetwork) WhenDeleted(ctx context.Context, prop *Properties) error {
+	if prop.Data[dataNetns] == "" {
+		return nil
+	}
+
+	err := s.teardown(ctx, prop.Data)
+
+	rmErr := netns.Remove(prop.Data[dataNetns])
+	if err == nil {
+		err = rmErr
+	}
+
+	return err
+}
+
 // Setup creates the network interface for the provided netfile
 func (s *cniPodNetwork) setup(ctx context.Context, netfile string) (types.Result, error) {
 	s.runtimeConf.NetNS = netfile
 
+	ctx, span := startSpan(ctx, "add", s.netList)
+
+	s.plugin.gcMu.RLock()
 	prevResult, err := s.plugin.cni.AddNetworkList(ctx, s.netList, s.runtimeConf)
+	s.plugin.gcMu.RUnlock()
+
+	tracing.End(span, err)
+
 	if err != nil {
+		metrics.CNIFailures.WithLabelValues("setup").Inc()
+
+		if IsIPPoolExhausted(err) {
+			atomic.AddUint64(&ipPoolExhaustedTotal, 1)
+			atomic.CompareAndSwapInt64(&s.plugin.exhaustedSince, 0, time.Now().UnixNano())
+
+			return nil, fmt.Errorf("%w: %v", ErrIPPoolExhausted, err)
+		}
+
 		return nil, err
 	}
 
+	atomic.StoreInt64(&s.plugin.exhaustedSince, 0)
+
 	// convert the result to the current cni version
-	return current.NewResultFromResult(prevResult)
+	return types100.NewResultFromResult(prevResult)
 }
 
-// Teardown removes the network compeletely as good as possible
-func (s *cniPodNetwork) teardown(ctx context.Context) error {
+// Teardown removes the network compeletely as good as possible using the data of the pod network. It's safe to call
+// multiple times: the network namespace is only passed if it still exists, the saved result is passed as prevResult in
+// case the cni cache is gone and errors of plugins not finding what they would remove are ignored
+func (s *cniPodNetwork) teardown(ctx context.Context, data map[string]string) error {
 	s.runtimeConf.NetNS = ""
-	return s.plugin.cni.DelNetworkList(ctx, s.netList, s.runtimeConf)
-}
+	if path := data[dataNetns]; path != "" && netns.IsNetns(path) {
+		s.runtimeConf.NetNS = path
+	}
 
-// Get ips of that result
-func (s *cniPodNetwork) ips(previousresult []byte) ([]net.IP, error) {
-	if previousresult == nil {
-		previousresult = []byte{}
+	netList, err := withPrevResult(s.netList, data[dataResult])
+	if err != nil {
+		return err
+	}
+
+	ctx, span := startSpan(ctx, "del", netList)
+
+	err = s.plugin.cni.DelNetworkList(ctx, netList, s.runtimeConf)
+
+	tracing.End(span, err)
+
+	if err != nil && isTeardownNotFound(err) {
+		log.WithError(err).WithField("podid", s.id).Debug("ignoring cni teardown error, the network is already gone")
+		return nil
 	}
 
-	prevResult, err := current.NewResult(previousresult)
 	if err != nil {
-		return nil, err
+		metrics.CNIFailures.WithLabelValues("teardown").Inc()
 	}
 
-	// convert the result to the current cni version
-	result, err := current.NewResultFromResult(prevResult)
+	return err
+}
+
+// Get ips of that result
+func (s *cniPodNetwork) ips(previousresult []byte) ([]net.IP, error) {
+	result, err := parseResult(previousresult)
 	if err != nil {
 		return nil, err
 	}
@@ -248,25 +566,121 @@ type cniContainerNetwork struct {
 	annotations          map[string]string
 }
 
-// WhenStarted is called when the container is started.
+// WhenStarted is called when the container is started. If the pod has its own network namespace, the container joined
+// it and there's nothing to do
 func (c *cniContainerNetwork) WhenStarted(ctx context.Context, prop *PropertiesRunning) (*Result, error) {
-	// TODO: As long as we haven't figured out to do 1:n podnetwork:container this method goes up to pod
+	if prop.Data[dataNetns] != "" {
+		return nil, nil
+	}
+
+	// Without a pod network namespace the network of the pod is set up in the container
 	result, err := c.pod.setup(ctx, fmt.Sprintf("/proc/%s/ns/net", strconv.FormatInt(prop.Pid, 10)))
 	if err != nil {
 		return nil, err
 	}
 
+	err = c.pod.plugin.conf.Tuning.apply(ctx, prop.Pid, c.pod.runtimeConf.IfName)
+	if err != nil {
+		return nil, err
+	}
+
 	b, err := json.Marshal(result)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Result{Data: map[string]string{"result": string(b)}}, nil
+	return &Result{Data: map[string]string{dataResult: string(b)}}, nil
 }
 
 // WhenDeleted is called when the container is deleted. If tearing down here, must tear down as good as possible. Must
 // tear down here if not implemented for WhenStopped. If an error is returned it will only be logged
 func (c *cniContainerNetwork) WhenDeleted(ctx context.Context, prop *Properties) error {
-	// TODO: As long as we haven't figured out to do 1:n podnetwork:container this method goes up to pod
-	return c.pod.teardown(ctx)
+	// the network of a pod network namespace belongs to the pod
+	if prop.Data[dataNetns] != "" {
+		return nil
+	}
+
+	return c.pod.teardown(ctx, prop.Data)
+}
+
+// withPrevResult returns a copy of the config list where every plugin has the result as prevResult, as DEL expects it
+// since cni spec 0.4.0. Returns the list itself if there's no result or the spec is older. libcni replaces it with its
+// cached result if it has one
+func withPrevResult(netList *libcni.NetworkConfigList, result string) (*libcni.NetworkConfigList, error) {
+	if netList == nil || result == "" {
+		return netList, nil
+	}
+
+	gtet, err := version.GreaterThanOrEqualTo(netList.CNIVersion, "0.4.0")
+	if err != nil || !gtet {
+		return netList, err
+	}
+
+	// the plugins expect the result in the version of their config
+	parsed, err := parseResult([]byte(result))
+	if err != nil {
+		return netList, nil
+	}
+
+	prevResult, err := parsed.GetAsVersion(netList.CNIVersion)
+	if err != nil {
+		return netList, nil
+	}
+
+	list := *netList
+	list.Plugins = make([]*libcni.NetworkConfig, 0, len(netList.Plugins))
+
+	for _, p := range netList.Plugins {
+		conf, err := libcni.InjectConf(p, map[string]interface{}{"prevResult": prevResult})
+		if err != nil {
+			return nil, err
+		}
+
+		list.Plugins = append(list.Plugins, conf)
+	}
+
+	return &list, nil
+}
+
+// isTeardownNotFound checks if the teardown failed as something to remove doesn't exist anymore, like the network
+// namespace, the interface or the ip allocation
+func isTeardownNotFound(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, m := range teardownNotFoundMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseResult parses a saved cni result of any version and converts it to the current version. Results without version
+// were saved by LXE before it used cni spec 1.0.0, when the current version was 0.4.0
+func parseResult(data []byte) (*types100.Result, error) {
+	var raw map[string]interface{}
+
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	version, _ := raw["cniVersion"].(string)
+	if version == "" {
+		version = legacyResultVersion
+		raw["cniVersion"] = version
+
+		data, err = json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := create.Create(version, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// convert the result to the current cni version
+	return types100.NewResultFromResult(result)
 }
@@ -1,16 +1,22 @@
 package network
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/automaticserver/lxe/annotation"
 	"github.com/automaticserver/lxe/network/libcnifake"
 	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/types"
 	types020 "github.com/containernetworking/cni/pkg/types/020"
-	"github.com/containernetworking/cni/pkg/types/current"
+	types040 "github.com/containernetworking/cni/pkg/types/040"
+	types100 "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/stretchr/testify/assert"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
 )
 
 var (
@@ -26,7 +32,7 @@ func fakeCNIFiles(t *testing.T) (string, string, string, string) {
 
 	binPath := filepath.Join(tmpDir, DefaultCNIbinPath)
 	confPath := filepath.Join(tmpDir, DefaultCNIconfPath)
-	netnsPath := filepath.Join(tmpDir, defaultCNInetnsPath)
+	netnsPath := filepath.Join(tmpDir, DefaultCNInetnsPath)
 
 	err = os.MkdirAll(confPath, 0700)
 	assert.NoError(t, err)
@@ -107,11 +113,125 @@ func Test_cniPlugin_UpdateRuntimeConfig(t *testing.T) {
 	defer os.RemoveAll(tmpDir)
 
 	err := plugin.UpdateRuntimeConfig(nil)
-	assert.Error(t, err)
+	assert.NoError(t, err)
+
+	// the pod cidr is ignored without a bridge name
+	err = plugin.UpdateRuntimeConfig(&rtApi.RuntimeConfig{NetworkConfig: &rtApi.NetworkConfig{PodCidr: "10.244.1.0/24"}})
+	assert.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(plugin.conf.ConfPath, PodCIDRConfFile))
+
+	plugin.conf.PodCIDRBridge = "cni0"
+
+	err = plugin.UpdateRuntimeConfig(&rtApi.RuntimeConfig{NetworkConfig: &rtApi.NetworkConfig{PodCidr: "10.244.1.0/24"}})
+	assert.NoError(t, err)
+
+	netList, _, err := plugin.getCNINetworkConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, podCIDRNetworkName, netList.Name)
+	assert.Equal(t, "bridge", netList.Plugins[0].Network.Type)
+
+	err = plugin.UpdateRuntimeConfig(&rtApi.RuntimeConfig{NetworkConfig: &rtApi.NetworkConfig{PodCidr: "invalid"}})
+	assert.True(t, errors.Is(err, ErrInvalidPodCIDR))
 }
 
 // TODO: test getCNINetworkConfig
 
+func Test_cniPlugin_Status_NoConfig(t *testing.T) {
+	t.Parallel()
+
+	plugin, _, tmpDir := testCNIPlugin(t)
+	defer os.RemoveAll(tmpDir)
+
+	err := os.RemoveAll(plugin.conf.ConfPath)
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(plugin.conf.ConfPath, 0700)
+	assert.NoError(t, err)
+
+	assert.True(t, errors.Is(plugin.Status(), ErrNoNetworksFound))
+}
+
+func Test_cniPlugin_Status_Simple(t *testing.T) {
+	t.Parallel()
+
+	plugin, _, tmpDir := testCNIPlugin(t)
+	defer os.RemoveAll(tmpDir)
+
+	err := plugin.Status()
+	assert.NoError(t, err)
+}
+
+func Test_setStaticAddresses(t *testing.T) {
+	t.Parallel()
+
+	rc := &libcni.RuntimeConf{}
+	setStaticAddresses(rc, nil)
+	assert.Nil(t, rc.CapabilityArgs)
+	assert.Empty(t, rc.Args)
+
+	setStaticAddresses(rc, map[string]string{annotation.IP.Name: "10.22.0.10/16", annotation.MAC.Name: "00:16:3e:00:00:01"})
+	assert.Equal(t, map[string]interface{}{"ips": []string{"10.22.0.10/16"}, "mac": "00:16:3e:00:00:01"}, rc.CapabilityArgs)
+	assert.Equal(t, [][2]string{{"IgnoreUnknown", "1"}, {"IP", "10.22.0.10/16"}, {"MAC", "00:16:3e:00:00:01"}}, rc.Args)
+}
+
+func Test_cniPlugin_Status_NotReady(t *testing.T) {
+	t.Parallel()
+
+	plugin, fake, tmpDir := testCNIPlugin(t)
+	defer os.RemoveAll(tmpDir)
+
+	fake.GetStatusNetworkListReturns(errors.New("plugin not ready"))
+
+	err := plugin.Status()
+	assert.True(t, errors.Is(err, ErrNetworkNotReady))
+	assert.Equal(t, 1, fake.GetStatusNetworkListCallCount())
+}
+
+func Test_cniPlugin_GC(t *testing.T) {
+	t.Parallel()
+
+	plugin, fake, tmpDir := testCNIPlugin(t)
+	defer os.RemoveAll(tmpDir)
+
+	err := plugin.GC(context.TODO(), func() ([]string, error) { return []string{"foo", "bar"}, nil })
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.GCNetworkListCallCount())
+
+	_, _, args := fake.GCNetworkListArgsForCall(0)
+	assert.Equal(t, []types.GCAttachment{
+		{ContainerID: "foo", IfName: DefaultInterface},
+		{ContainerID: "bar", IfName: DefaultInterface},
+	}, args.ValidAttachments)
+}
+
+func Test_cniPlugin_GC_Error(t *testing.T) {
+	t.Parallel()
+
+	plugin, fake, tmpDir := testCNIPlugin(t)
+	defer os.RemoveAll(tmpDir)
+
+	someErr := errors.New("unable to list pods")
+
+	// without the valid attachments the plugins would release everything
+	err := plugin.GC(context.TODO(), func() ([]string, error) { return nil, someErr })
+	assert.True(t, errors.Is(err, someErr))
+	assert.Equal(t, 0, fake.GCNetworkListCallCount())
+
+	fake.GCNetworkListReturns(errors.New("failed"))
+
+	err = plugin.GC(context.TODO(), func() ([]string, error) { return nil, nil })
+	assert.Error(t, err)
+}
+
+func TestIsIPPoolExhausted(t *testing.T) {
+	t.Parallel()
+
+	assert.False(t, IsIPPoolExhausted(nil))
+	assert.False(t, IsIPPoolExhausted(errors.New("failed to find plugin")))
+	assert.True(t, IsIPPoolExhausted(ErrIPPoolExhausted))
+	assert.True(t, IsIPPoolExhausted(errors.New("failed to allocate for range 0: no IP addresses available in range set: 10.0.0.1-10.0.0.2")))
+}
+
 func Test_cniPlugin_getCNIRuntimeConf(t *testing.T) {
 	t.Parallel()
 
@@ -182,7 +302,7 @@ func Test_cniPodNetwork_setup_Simple(t *testing.T) {
 	defer os.RemoveAll(tmpDir)
 
 	netfile := "/proc/5/ns/net"
-	result, err := current.NewResult([]byte(`{"cniVersion":"0.4.0"}`))
+	result, err := types040.NewResult([]byte(`{"cniVersion":"0.4.0"}`))
 	assert.NoError(t, err)
 
 	fake.AddNetworkListReturns(result, nil)
@@ -212,20 +332,45 @@ func Test_cniPodNetwork_setup_OldVersion(t *testing.T) {
 	result, err = podNet.setup(ctx, netfile)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, fake.AddNetworkListCallCount())
-	assert.Equal(t, current.ImplementedSpecVersion, result.Version())
+	assert.Equal(t, types100.ImplementedSpecVersion, result.Version())
 
 	_, _, argRuntimeConf := fake.AddNetworkListArgsForCall(0)
 	// assert.Len(t, argConfList.Plugins, 1)
 	assert.Equal(t, netfile, argRuntimeConf.NetNS)
 }
 
+func Test_cniPodNetwork_setup_Exhausted(t *testing.T) {
+	t.Parallel()
+
+	podNet, fake, tmpDir := testCNIPodNet(t)
+	defer os.RemoveAll(tmpDir)
+
+	before := IPPoolExhaustedTotal()
+
+	fake.AddNetworkListReturns(nil, errors.New("no IP addresses available in range set"))
+
+	_, err := podNet.setup(ctx, "/proc/5/ns/net")
+	assert.True(t, errors.Is(err, ErrIPPoolExhausted))
+	assert.True(t, errors.Is(podNet.plugin.Status(), ErrIPPoolExhausted))
+	assert.Less(t, before, IPPoolExhaustedTotal())
+
+	result, err := types040.NewResult([]byte(`{"cniVersion":"0.4.0"}`))
+	assert.NoError(t, err)
+
+	fake.AddNetworkListReturns(result, nil)
+
+	_, err = podNet.setup(ctx, "/proc/5/ns/net")
+	assert.NoError(t, err)
+	assert.NoError(t, podNet.plugin.Status())
+}
+
 func Test_cniPodNetwork_teardown_afterSetup(t *testing.T) {
 	t.Parallel()
 
 	podNet, fake, tmpDir := testCNIPodNet(t)
 	defer os.RemoveAll(tmpDir)
 
-	result, err := current.NewResult([]byte(`{"cniVersion":"0.4.0"}`))
+	result, err := types040.NewResult([]byte(`{"cniVersion":"0.4.0"}`))
 	assert.NoError(t, err)
 
 	fake.AddNetworkListReturns(result, nil)
@@ -234,7 +379,7 @@ func Test_cniPodNetwork_teardown_afterSetup(t *testing.T) {
 	_, err = podNet.setup(ctx, "/proc/5/ns/net")
 	assert.NoError(t, err)
 
-	err = podNet.teardown(ctx)
+	err = podNet.teardown(ctx, nil)
 	assert.NoError(t, err)
 
 	assert.Equal(t, 1, fake.AddNetworkListCallCount())
@@ -244,6 +389,68 @@ func Test_cniPodNetwork_teardown_afterSetup(t *testing.T) {
 	assert.Equal(t, "", argRuntimeConf.NetNS)
 }
 
+func Test_cniPodNetwork_teardown_PrevResult(t *testing.T) {
+	t.Parallel()
+
+	podNet, fake, tmpDir := testCNIPodNet(t)
+	defer os.RemoveAll(tmpDir)
+
+	netList, _, err := podNet.plugin.getCNINetworkConfig()
+	assert.NoError(t, err)
+
+	podNet.netList = netList
+	result := `{"cniVersion":"1.1.0","ips":[{"address":"10.22.0.64/16"}]}`
+
+	// the netns is already gone
+	err = podNet.teardown(ctx, map[string]string{dataResult: result, dataNetns: filepath.Join(tmpDir, "gone")})
+	assert.NoError(t, err)
+
+	_, list, argRuntimeConf := fake.DelNetworkListArgsForCall(0)
+	assert.Equal(t, "", argRuntimeConf.NetNS)
+	// converted to the version of the config
+	assert.Contains(t, string(list.Plugins[0].Bytes), `"prevResult":{"cniVersion":"0.4.0","ips":[{"version":"4","address":"10.22.0.64/16"}]`)
+	assert.NotContains(t, string(podNet.netList.Plugins[0].Bytes), "prevResult")
+}
+
+func Test_cniPodNetwork_teardown_NotFound(t *testing.T) {
+	t.Parallel()
+
+	podNet, fake, tmpDir := testCNIPodNet(t)
+	defer os.RemoveAll(tmpDir)
+
+	fake.DelNetworkListReturns(errors.New(`plugin type="bridge" failed: Link not found`))
+
+	err := podNet.teardown(ctx, nil)
+	assert.NoError(t, err)
+
+	fake.DelNetworkListReturns(errors.New("permission denied"))
+
+	err = podNet.teardown(ctx, nil)
+	assert.Error(t, err)
+}
+
+func Test_withPrevResult(t *testing.T) {
+	t.Parallel()
+
+	netList, err := libcni.ConfListFromBytes([]byte(`{"cniVersion":"0.3.1","name":"old","plugins":[{"type":"bridge"}]}`))
+	assert.NoError(t, err)
+
+	// DEL has no prevResult before 0.4.0
+	list, err := withPrevResult(netList, `{"cniVersion":"0.3.1"}`)
+	assert.NoError(t, err)
+	assert.Same(t, netList, list)
+
+	netList.CNIVersion = "0.4.0"
+
+	list, err = withPrevResult(netList, "")
+	assert.NoError(t, err)
+	assert.Same(t, netList, list)
+
+	list, err = withPrevResult(netList, "invalid")
+	assert.NoError(t, err)
+	assert.Same(t, netList, list)
+}
+
 func Test_cniPodNetwork_ips_Simple(t *testing.T) {
 	t.Parallel()
 
@@ -295,7 +502,7 @@ func Test_cniContainerNetwork_WhenStarted(t *testing.T) {
 	contNet, fake, tmpDir := testCNIContNet(t)
 	defer os.RemoveAll(tmpDir)
 
-	fake.AddNetworkListReturns(&current.Result{CNIVersion: "4.0", IPs: []*current.IPConfig{}}, nil)
+	fake.AddNetworkListReturns(&types100.Result{CNIVersion: types100.ImplementedSpecVersion, IPs: []*types100.IPConfig{}}, nil)
 
 	res, err := contNet.WhenStarted(ctx, &PropertiesRunning{Properties: Properties{}, Pid: 6})
 	assert.NoError(t, err)
@@ -317,3 +524,57 @@ func Test_cniContainerNetwork_WhenDeleted(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 1, fake.DelNetworkListCallCount())
 }
+
+func Test_cniContainerNetwork_PodNetns(t *testing.T) {
+	t.Parallel()
+
+	contNet, fake, tmpDir := testCNIContNet(t)
+	defer os.RemoveAll(tmpDir)
+
+	data := map[string]string{dataNetns: "/run/netns/foo"}
+
+	res, err := contNet.WhenStarted(ctx, &PropertiesRunning{Properties: Properties{Data: data}, Pid: 6})
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+
+	err = contNet.WhenDeleted(ctx, &Properties{Data: data})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 0, fake.AddNetworkListCallCount(), "the network belongs to the pod")
+	assert.Equal(t, 0, fake.DelNetworkListCallCount())
+}
+
+func Test_cniPodNetwork_WhenStarted_NoPodNetns(t *testing.T) {
+	t.Parallel()
+
+	podNet, fake, tmpDir := testCNIPodNet(t)
+	defer os.RemoveAll(tmpDir)
+
+	res, err := podNet.WhenStarted(ctx, &PropertiesRunning{})
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+	assert.Equal(t, 0, fake.AddNetworkListCallCount())
+
+	assert.NoError(t, podNet.WhenStopped(ctx, &Properties{}))
+	assert.NoError(t, podNet.WhenDeleted(ctx, &Properties{}))
+	assert.Equal(t, 0, fake.DelNetworkListCallCount())
+}
+
+func Test_cniPodNetwork_WhenDeleted_PodNetns(t *testing.T) {
+	t.Parallel()
+
+	podNet, fake, tmpDir := testCNIPodNet(t)
+	defer os.RemoveAll(tmpDir)
+
+	// the namespace is already gone, so the network is torn down without it
+	netns := filepath.Join(tmpDir, "netns", "foo")
+	data := map[string]string{dataNetns: netns}
+
+	assert.NoError(t, podNet.WhenStopped(ctx, &Properties{Data: data}))
+	assert.NoError(t, podNet.WhenDeleted(ctx, &Properties{Data: data}))
+	assert.Equal(t, 2, fake.DelNetworkListCallCount())
+
+	_, _, conf := fake.DelNetworkListArgsForCall(1)
+	assert.Equal(t, "", conf.NetNS)
+	assert.Equal(t, netns, PodNetns(data))
+}
@@ -0,0 +1,151 @@
+package network // import "github.com/automaticserver/lxe/network"
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/automaticserver/lxe/logging"
+	"github.com/automaticserver/lxe/metrics"
+	"github.com/containernetworking/cni/libcni"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// confWatchDebounce is how long the conf dir must be quiet before the cni config is reloaded, as writing a file causes
+// several events
+const confWatchDebounce = 200 * time.Millisecond
+
+var log = logging.Subsystem("network")
+
+// confWatch keeps the cni config loaded, it's reloaded whenever the conf dir changes
+type confWatch struct {
+	watcher *fsnotify.Watcher
+	mu      sync.RWMutex
+	// netList, warnings and err are the result of the last load
+	netList  *libcni.NetworkConfigList
+	warnings error
+	err      error
+}
+
+// watchConfDir loads the cni config and starts watching the conf dir, which is created if it doesn't exist yet
+func (p *cniPlugin) watchConfDir() error {
+	err := os.MkdirAll(p.conf.ConfPath, 0755) // nolint: gomnd
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	err = watcher.Add(p.conf.ConfPath)
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+
+	p.watch = &confWatch{watcher: watcher}
+	p.reloadConfig()
+
+	go p.watchLoop()
+
+	return nil
+}
+
+// watchLoop reloads the cni config after the conf dir changed, till the watcher is closed
+func (p *cniPlugin) watchLoop() {
+	var debounce <-chan time.Time
+
+	for {
+		select {
+		case event, ok := <-p.watch.watcher.Events:
+			if !ok {
+				return
+			}
+
+			if event.Op != fsnotify.Chmod {
+				debounce = time.After(confWatchDebounce)
+			}
+		case err, ok := <-p.watch.watcher.Errors:
+			if !ok {
+				return
+			}
+
+			log.WithError(err).WithField("dir", p.conf.ConfPath).Warn("error watching cni conf dir")
+		case <-debounce:
+			debounce = nil
+
+			p.reloadConfig()
+		}
+	}
+}
+
+// reloadConfig loads the cni config, validates it and makes it the active one. An invalid config is active as well,
+// so Status reports it and new pods fail like without watching
+func (p *cniPlugin) reloadConfig() {
+	netList, warnings, err := p.getCNINetworkConfig()
+
+	w := p.watch
+	w.mu.Lock()
+	previous := w.netList
+	w.netList, w.warnings, w.err = netList, warnings, err
+	w.mu.Unlock()
+
+	metrics.CNIConfigReloads.WithLabelValues(metrics.Result(err)).Inc()
+
+	if previous != nil {
+		metrics.CNIConfigInfo.DeleteLabelValues(previous.Name, previous.CNIVersion)
+	}
+
+	log := log.WithField("dir", p.conf.ConfPath)
+	if warnings != nil {
+		log = log.WithField("warnings", warnings.Error())
+	}
+
+	if err != nil {
+		log.WithError(err).Error("no valid cni config")
+		return
+	}
+
+	metrics.CNIConfigInfo.WithLabelValues(netList.Name, netList.CNIVersion).Set(1)
+
+	log.WithFields(logrus.Fields{
+		"network":    netList.Name,
+		"cniVersion": netList.CNIVersion,
+		"plugins":    pluginTypes(netList),
+	}).Info("cni config loaded")
+}
+
+// pluginTypes returns the types of the plugins in the config list
+func pluginTypes(netList *libcni.NetworkConfigList) string {
+	types := make([]string, 0, len(netList.Plugins))
+	for _, p := range netList.Plugins {
+		types = append(types, p.Network.Type)
+	}
+
+	return strings.Join(types, ",")
+}
+
+// networkConfig returns the active cni config: the one last loaded if the conf dir is watched, otherwise it's loaded
+func (p *cniPlugin) networkConfig() (*libcni.NetworkConfigList, error, error) {
+	if p.watch == nil {
+		return p.getCNINetworkConfig()
+	}
+
+	p.watch.mu.RLock()
+	defer p.watch.mu.RUnlock()
+
+	return p.watch.netList, p.watch.warnings, p.watch.err
+}
+
+// Close stops watching the conf dir
+func (p *cniPlugin) Close() error {
+	if p.watch == nil {
+		return nil
+	}
+
+	return p.watch.watcher.Close()
+}
@@ -0,0 +1,56 @@
+package network
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_cniPlugin_watchConfDir(t *testing.T) {
+	t.Parallel()
+
+	plugin, _, tmpDir := testCNIPlugin(t)
+	defer os.RemoveAll(tmpDir)
+
+	err := plugin.watchConfDir()
+	assert.NoError(t, err)
+
+	defer plugin.Close()
+
+	netList, _, err := plugin.networkConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "lo", netList.Name)
+
+	err = ioutil.WriteFile(filepath.Join(plugin.conf.ConfPath, "10-mynet.conf"), []byte(`
+	{
+		"cniVersion": "0.4.0",
+		"name": "mynet",
+		"type": "bridge"
+	}`), 0600)
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		netList, _, err := plugin.networkConfig()
+		return err == nil && netList.Name == "mynet"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	err = os.RemoveAll(plugin.conf.ConfPath)
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return plugin.Status() != nil
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func Test_cniPlugin_Close_NotWatching(t *testing.T) {
+	t.Parallel()
+
+	plugin, _, tmpDir := testCNIPlugin(t)
+	defer os.RemoveAll(tmpDir)
+
+	assert.NoError(t, plugin.Close())
+}
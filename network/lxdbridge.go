@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"strings"
+	"sync"
 
+	"github.com/automaticserver/lxe/annotation"
 	"github.com/automaticserver/lxe/lxf/device"
 	"github.com/automaticserver/lxe/network/cloudinit"
 	"github.com/automaticserver/lxe/shared"
@@ -17,10 +20,14 @@ import (
 
 const (
 	DefaultLXDBridge = "lxebr0"
+	// dnsHostRecord is the dnsmasq option registering a pod name
+	dnsHostRecord = "host-record="
 )
 
 var (
-	ErrNotBridge = errors.New("not a bridge")
+	ErrNotBridge     = errors.New("not a bridge")
+	ErrBridgeMissing = errors.New("bridge missing")
+	ErrInvalidVLAN   = annotation.ErrInvalidVLAN
 )
 
 // ConfLXDBridge are configuration options for the LXDBridge plugin. All properties are optional and get a default value
@@ -29,6 +36,34 @@ type ConfLXDBridge struct {
 	Cidr       string
 	Nat        bool
 	CreateOnly bool
+	// VLANs maps a kubernetes namespace to the VLAN ID the pod nics of that namespace are tagged with
+	VLANs map[string]int
+	// Tuning of the pod nic. The MTU is set in the nic config, the rest is applied after the container started
+	Tuning Tuning
+	// DNSDomain enables the dns of the bridge's dnsmasq and registers every pod as <name>.<namespace>.<domain>. If
+	// empty, dns is disabled
+	DNSDomain string
+}
+
+// ParseVLANs parses a list of namespace=vlan entries
+func ParseVLANs(entries []string) (map[string]int, error) {
+	vlans := make(map[string]int, len(entries))
+
+	for _, e := range entries {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("%w: entry %q must be in the form namespace=vlan", ErrInvalidVLAN, e)
+		}
+
+		vlan, err := annotation.ParseVLAN(parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		vlans[parts[0]] = vlan
+	}
+
+	return vlans, nil
 }
 
 func (c *ConfLXDBridge) setDefaults() {
@@ -42,6 +77,8 @@ type lxdBridgePlugin struct {
 	noopPlugin // every method not implemented is noop
 	server     lxd.ContainerServer
 	conf       ConfLXDBridge
+	// dnsMu serializes the updates of the host records
+	dnsMu sync.Mutex
 }
 
 // InitPluginLXDBridge instantiates the LXDBridge plugin using the provided config
@@ -70,30 +107,95 @@ func (p *lxdBridgePlugin) PodNetwork(id string, annotations map[string]string) (
 	}, nil
 }
 
-// UpdateRuntimeConfig is called when there are updates to the configuration which the plugin might need to apply
-func (p *lxdBridgePlugin) UpdateRuntimeConfig(conf *rtApi.RuntimeConfig) error {
-	if cidr := conf.GetNetworkConfig().GetPodCidr(); cidr != "" {
-		p.conf.Cidr = cidr
-		return p.ensureBridge()
+// Status returns error if the plugin is in error state, e.g. the bridge was deleted from LXD
+func (p *lxdBridgePlugin) Status() error {
+	network, _, err := p.server.GetNetwork(p.conf.LXDBridge)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrBridgeMissing, p.conf.LXDBridge, err)
+	}
+
+	if network.Type != "bridge" {
+		return fmt.Errorf("%w: %v, but is %v", ErrNotBridge, p.conf.LXDBridge, network.Type)
 	}
 
 	return nil
 }
 
+// UpdateRuntimeConfig is called when there are updates to the configuration which the plugin might need to apply. The
+// subnet of the bridge is set to the ipv4 pod CIDR, even if the bridge already existed
+func (p *lxdBridgePlugin) UpdateRuntimeConfig(conf *rtApi.RuntimeConfig) error {
+	podCIDR := conf.GetNetworkConfig().GetPodCidr()
+	if podCIDR == "" {
+		return nil
+	}
+
+	cidrs, err := parsePodCIDRs(podCIDR)
+	if err != nil {
+		return err
+	}
+
+	// the bridge only has ipv4 configured
+	cidr := firstIPv4(cidrs)
+	if cidr == nil {
+		return fmt.Errorf("%w: %s contains no ipv4 cidr", ErrInvalidPodCIDR, podCIDR)
+	}
+
+	p.conf.Cidr = cidr.String()
+
+	return p.updateSubnet()
+}
+
+// bridgeAddress returns the ipv4.address of the bridge for the cidr, which is the first address in range. Returns auto
+// if cidr is empty to let LXD assign one
+func bridgeAddress(cidr string) (string, error) {
+	if cidr == "" {
+		return "auto", nil
+	}
+
+	_, net, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+
+	net.IP[3]++
+
+	return net.String(), nil
+}
+
+// updateSubnet sets the address of the bridge to the one of Cidr, or creates it if it's missing. Pods keep their
+// address until they're recreated
+func (p *lxdBridgePlugin) updateSubnet() error {
+	address, err := bridgeAddress(p.conf.Cidr)
+	if err != nil {
+		return err
+	}
+
+	network, ETag, err := p.server.GetNetwork(p.conf.LXDBridge)
+	if err != nil {
+		if shared.IsErrNotFound(err) {
+			return p.ensureBridge()
+		}
+
+		return err
+	} else if network.Type != "bridge" {
+		return fmt.Errorf("%w: %v, but is %v", ErrNotBridge, p.conf.LXDBridge, network.Type)
+	}
+
+	if network.Config["ipv4.address"] == address {
+		return nil
+	}
+
+	network.Config["ipv4.address"] = address
+
+	return p.server.UpdateNetwork(p.conf.LXDBridge, network.Writable(), ETag)
+}
+
 // EnsureBridge ensures the bridge exists with the defined options. Cidr is an expected ipv4 cidr or can be empty to
 // automatically assign a cidr
 func (p *lxdBridgePlugin) ensureBridge() error {
-	var address string
-	if p.conf.Cidr == "" {
-		address = "auto"
-	} else {
-		// Always use first address in range for the bridge
-		_, net, err := net.ParseCIDR(p.conf.Cidr)
-		if err != nil {
-			return err
-		}
-		net.IP[3]++
-		address = net.String()
+	address, err := bridgeAddress(p.conf.Cidr)
+	if err != nil {
+		return err
 	}
 
 	put := api.NetworkPut{
@@ -103,15 +205,25 @@ func (p *lxdBridgePlugin) ensureBridge() error {
 			"ipv4.dhcp":    strconv.FormatBool(true),
 			"ipv4.nat":     strconv.FormatBool(p.conf.Nat),
 			"ipv6.address": "none",
-			// We don't need to receive a DNS in DHCP, Kubernetes' DNS is always set by requesting a mount for resolv.conf.
-			// This disables dns in dnsmasq (option -p: https://linux.die.net/man/8/dnsmasq)
-			"raw.dnsmasq": `port=0`,
 		},
 	}
 
+	dns := map[string]string{}
+	if p.conf.DNSDomain != "" {
+		// LXD must not register the container names itself, the pods are registered by their kubernetes names
+		dns["dns.mode"] = "none"
+		dns["dns.domain"] = p.conf.DNSDomain
+	}
+
+	for k, v := range dns {
+		put.Config[k] = v
+	}
+
 	network, ETag, err := p.server.GetNetwork(p.conf.LXDBridge)
 	if err != nil {
 		if shared.IsErrNotFound(err) {
+			put.Config["raw.dnsmasq"] = p.dnsmasqConfig(nil)
+
 			return p.server.CreateNetwork(api.NetworksPost{
 				Name:       p.conf.LXDBridge,
 				Type:       "bridge",
@@ -124,12 +236,19 @@ func (p *lxdBridgePlugin) ensureBridge() error {
 		return fmt.Errorf("%w: %v, but is %v", ErrNotBridge, p.conf.LXDBridge, network.Type)
 	}
 
-	// don't update when only creation is requested
+	// don't update when only creation is requested, except enabling the dns
 	// TODO: Should we return an error if the bridge settings e.g. cidr would change?
 	if p.conf.CreateOnly {
-		return nil
+		if len(dns) == 0 {
+			return nil
+		}
+
+		put.Config = dns
 	}
 
+	// keep the pods registered so far
+	put.Config["raw.dnsmasq"] = p.dnsmasqConfig(hostRecords(network.Config["raw.dnsmasq"]))
+
 	for k, v := range put.Config {
 		network.Config[k] = v
 	}
@@ -137,7 +256,104 @@ func (p *lxdBridgePlugin) ensureBridge() error {
 	return p.server.UpdateNetwork(p.conf.LXDBridge, network.Writable(), ETag)
 }
 
-var ErrNotImplemented = errors.New("not implemented")
+// dnsmasqConfig returns the raw dnsmasq config of the bridge containing the host records
+func (p *lxdBridgePlugin) dnsmasqConfig(records []string) string {
+	if p.conf.DNSDomain == "" {
+		// We don't need to receive a DNS in DHCP, Kubernetes' DNS is always set by requesting a mount for resolv.conf.
+		// This disables dns in dnsmasq (option -p: https://linux.die.net/man/8/dnsmasq)
+		return `port=0`
+	}
+
+	// answer the pod domain only locally and don't forward plain names
+	lines := []string{"domain-needed", fmt.Sprintf("local=/%s/", p.conf.DNSDomain)}
+
+	for _, r := range records {
+		lines = append(lines, dnsHostRecord+r)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// hostRecords returns the host records of the raw dnsmasq config
+func hostRecords(raw string) []string {
+	records := []string{}
+
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.HasPrefix(line, dnsHostRecord) {
+			records = append(records, strings.TrimPrefix(line, dnsHostRecord))
+		}
+	}
+
+	return records
+}
+
+// updateHostRecords replaces the host records of ip with the names, or removes them if there are no names. LXD restarts
+// dnsmasq when the config changes, so the records are effective immediately
+func (p *lxdBridgePlugin) updateHostRecords(ip string, names ...string) error {
+	if p.conf.DNSDomain == "" {
+		return nil
+	}
+
+	p.dnsMu.Lock()
+	defer p.dnsMu.Unlock()
+
+	network, ETag, err := p.server.GetNetwork(p.conf.LXDBridge)
+	if err != nil {
+		return err
+	}
+
+	records := []string{}
+
+	for _, r := range hostRecords(network.Config["raw.dnsmasq"]) {
+		if !strings.HasSuffix(r, ","+ip) {
+			records = append(records, r)
+		}
+	}
+
+	if len(names) > 0 {
+		records = append(records, strings.Join(append(names, ip), ","))
+	}
+
+	network.Config["raw.dnsmasq"] = p.dnsmasqConfig(records)
+
+	return p.server.UpdateNetwork(p.conf.LXDBridge, network.Writable(), ETag)
+}
+
+var (
+	ErrNotImplemented = errors.New("not implemented")
+	ErrIPUnavailable  = errors.New("ip address unavailable")
+)
+
+// checkIP verifies the ip can be assigned to a pod: it's in the subnet of the lxd managed bridge, not the bridge ip
+// and not leased
+func (p *lxdBridgePlugin) checkIP(ip net.IP) error {
+	network, _, err := p.server.GetNetwork(p.conf.LXDBridge)
+	if err != nil {
+		return err
+	}
+
+	bridgeIP, bridgeNet, err := net.ParseCIDR(network.Config["ipv4.address"])
+	if err != nil {
+		return err
+	}
+
+	if ip.To4() == nil || !bridgeNet.Contains(ip) || ip.Equal(bridgeIP) {
+		return fmt.Errorf("%w: %s is not in the subnet %s of bridge %s", ErrIPUnavailable, ip, bridgeNet, p.conf.LXDBridge)
+	}
+
+	leases, err := p.server.GetNetworkLeases(p.conf.LXDBridge)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range leases {
+		if ip.Equal(net.ParseIP(l.Address)) {
+			return fmt.Errorf("%w: %s is leased by %s", ErrIPUnavailable, ip, l.Hostname)
+		}
+	}
+
+	return nil
+}
 
 // findFreeIP generates a IP within the range of the provided lxd managed bridge which does
 // not exist in the current leases
@@ -204,19 +420,70 @@ func (s *lxdBridgePodNetwork) Status(ctx context.Context, prop *PropertiesRunnin
 	}, nil
 }
 
+// vlan returns the VLAN ID the pod nic should be tagged with, empty if untagged. The annotation has priority over the
+// namespace mapping
+func (s *lxdBridgePodNetwork) vlan(namespace string) (string, error) {
+	if str, has := annotation.VLAN.Get(s.annotations); has {
+		vlan, err := annotation.ParseVLAN(str)
+		if err != nil {
+			return "", err
+		}
+
+		return strconv.Itoa(vlan), nil
+	}
+
+	if vlan, has := s.plugin.conf.VLANs[namespace]; has {
+		return strconv.Itoa(vlan), nil
+	}
+
+	return "", nil
+}
+
+// podIP returns the static ip of the annotation if it's available, otherwise a free ip of the bridge
+func (s *lxdBridgePodNetwork) podIP() (net.IP, error) {
+	str, has := annotation.IP.Get(s.annotations)
+	if !has {
+		return s.plugin.findFreeIP()
+	}
+
+	ip, err := annotation.ParseIP(str)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.plugin.checkIP(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	return ip, nil
+}
+
 // WhenCreated is called when the pod is created.
 func (s *lxdBridgePodNetwork) WhenCreated(ctx context.Context, prop *Properties) (*Result, error) {
+	vlan, err := s.vlan(prop.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
 	// default is to use the predefined lxd bridge managed by lxe
-	randIP, err := s.plugin.findFreeIP()
+	ip, err := s.podIP()
 	if err != nil {
 		return nil, err
 	}
 
+	mac, _ := annotation.MAC.Get(s.annotations)
+
+	var mtu string
+	if s.plugin.conf.Tuning.MTU > 0 {
+		mtu = strconv.Itoa(s.plugin.conf.Tuning.MTU)
+	}
+
 	r := &Result{}
 	// TODO: Remove, I think we don't/shouldn't need that anymore
 	r.Data = map[string]string{
 		// 	"bridge":            s.plugin.conf.LXDBridge,
-		"interface-address": randIP.String(), // except this for IP return shortcut in Status
+		"interface-address": ip.String(), // except this for IP return shortcut in Status
 		// 	"physical-type":     "dhcp",
 	}
 	r.Nics = []device.Nic{
@@ -224,7 +491,10 @@ func (s *lxdBridgePodNetwork) WhenCreated(ctx context.Context, prop *Properties)
 			Name:        DefaultInterface,
 			NicType:     "bridged",
 			Parent:      s.plugin.conf.LXDBridge,
-			IPv4Address: randIP.String(),
+			IPv4Address: ip.String(),
+			Vlan:        vlan,
+			MTU:         mtu,
+			HWAddr:      mac,
 		},
 	}
 	r.NetworkConfigEntries = []cloudinit.NetworkConfigEntryPhysical{
@@ -241,9 +511,27 @@ func (s *lxdBridgePodNetwork) WhenCreated(ctx context.Context, prop *Properties)
 		},
 	}
 
+	if prop.Name != "" && prop.Namespace != "" {
+		name := prop.Name + "." + prop.Namespace
+
+		err = s.plugin.updateHostRecords(ip.String(), name+"."+s.plugin.conf.DNSDomain, name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to register pod name %s: %w", name, err)
+		}
+	}
+
 	return r, nil
 }
 
+// WhenDeleted is called when the pod is deleted.
+func (s *lxdBridgePodNetwork) WhenDeleted(ctx context.Context, prop *Properties) error {
+	if ip := prop.Data["interface-address"]; ip != "" {
+		return s.plugin.updateHostRecords(ip)
+	}
+
+	return nil
+}
+
 // lxdBridgeContainerNetwork is a container network environment context
 type lxdBridgeContainerNetwork struct {
 	noopContainerNetwork // every method not implemented is noop
@@ -251,3 +539,12 @@ type lxdBridgeContainerNetwork struct {
 	cid                  string
 	annotations          map[string]string
 }
+
+// WhenStarted is called when the container is started
+func (c *lxdBridgeContainerNetwork) WhenStarted(ctx context.Context, prop *PropertiesRunning) (*Result, error) {
+	// the mtu is already set in the nic config
+	tuning := c.pod.plugin.conf.Tuning
+	tuning.MTU = 0
+
+	return nil, tuning.apply(ctx, prop.Pid, DefaultInterface)
+}
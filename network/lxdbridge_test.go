@@ -1,8 +1,10 @@
 package network
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/automaticserver/lxe/annotation"
 	"github.com/automaticserver/lxe/lxf/lxdfakes"
 	"github.com/automaticserver/lxe/shared"
 	lxd "github.com/lxc/lxd/client"
@@ -117,6 +119,50 @@ func Test_lxdBridgePlugin_UpdateRuntimeConfig(t *testing.T) {
 	assert.Equal(t, "192.168.224.1/24", args.Config["ipv4.address"])
 }
 
+func Test_lxdBridgePlugin_UpdateRuntimeConfig_Existing(t *testing.T) {
+	t.Parallel()
+
+	plugin, fake := testLXDBridgePlugin()
+	plugin.conf.CreateOnly = true
+
+	fake.GetNetworkReturns(&lxdApi.Network{Type: "bridge", NetworkPut: lxdApi.NetworkPut{Config: map[string]string{
+		"ipv4.address": "10.10.0.1/24",
+	}}}, "", nil)
+
+	err := plugin.UpdateRuntimeConfig(&rtApi.RuntimeConfig{NetworkConfig: &rtApi.NetworkConfig{PodCidr: "fd00::/64,192.168.224.0/24"}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, fake.UpdateNetworkCallCount())
+	name, args, _ := fake.UpdateNetworkArgsForCall(0)
+	assert.Equal(t, testLXDBridge, name)
+	assert.Equal(t, "192.168.224.1/24", args.Config["ipv4.address"])
+
+	// unchanged subnet isn't updated again
+	fake.GetNetworkReturns(&lxdApi.Network{Type: "bridge", NetworkPut: args}, "", nil)
+
+	err = plugin.UpdateRuntimeConfig(&rtApi.RuntimeConfig{NetworkConfig: &rtApi.NetworkConfig{PodCidr: "192.168.224.0/24"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.UpdateNetworkCallCount())
+
+	err = plugin.UpdateRuntimeConfig(&rtApi.RuntimeConfig{NetworkConfig: &rtApi.NetworkConfig{PodCidr: "fd00::/64"}})
+	assert.True(t, errors.Is(err, ErrInvalidPodCIDR))
+}
+
+func Test_lxdBridgePlugin_Status(t *testing.T) {
+	t.Parallel()
+
+	plugin, fake := testLXDBridgePlugin()
+
+	fake.GetNetworkReturns(&lxdApi.Network{Type: "bridge"}, "", nil)
+	assert.NoError(t, plugin.Status())
+
+	fake.GetNetworkReturns(&lxdApi.Network{Type: "physical"}, "", nil)
+	assert.True(t, errors.Is(plugin.Status(), ErrNotBridge))
+
+	fake.GetNetworkReturns(nil, "", shared.NewErrNotFound())
+	assert.True(t, errors.Is(plugin.Status(), ErrBridgeMissing))
+}
+
 func Test_lxdBridgePlugin_ensureBridge_WrongNetworkTypeExists(t *testing.T) {
 	t.Parallel()
 
@@ -321,3 +367,172 @@ func Test_lxdBridgePodNetwork_WhenCreated_Simple(t *testing.T) {
 	assert.NotEmpty(t, res.Data["interface-address"])
 	assert.NotEmpty(t, res.Nics[0].IPv4Address)
 }
+
+func TestParseVLANs(t *testing.T) {
+	t.Parallel()
+
+	vlans, err := ParseVLANs([]string{"foo=10", "bar=4094"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"foo": 10, "bar": 4094}, vlans)
+
+	for _, e := range []string{"foo", "=10", "foo=0", "foo=4095", "foo=bar"} {
+		_, err = ParseVLANs([]string{e})
+		assert.True(t, errors.Is(err, ErrInvalidVLAN), e)
+	}
+}
+
+func Test_lxdBridgePodNetwork_vlan(t *testing.T) {
+	t.Parallel()
+
+	podNet, _ := testLXDBridgePodNetwork()
+	podNet.plugin.conf.VLANs = map[string]int{"tenant": 10}
+
+	vlan, err := podNet.vlan("default")
+	assert.NoError(t, err)
+	assert.Empty(t, vlan)
+
+	vlan, err = podNet.vlan("tenant")
+	assert.NoError(t, err)
+	assert.Equal(t, "10", vlan)
+
+	podNet.annotations = map[string]string{annotation.VLAN.Name: "20"}
+	vlan, err = podNet.vlan("tenant")
+	assert.NoError(t, err)
+	assert.Equal(t, "20", vlan)
+
+	podNet.annotations = map[string]string{annotation.VLAN.Name: "5000"}
+	_, err = podNet.vlan("tenant")
+	assert.True(t, errors.Is(err, ErrInvalidVLAN))
+}
+
+func Test_lxdBridgePodNetwork_WhenCreated_VLANAndMTU(t *testing.T) {
+	t.Parallel()
+
+	podNet, fake := testLXDBridgePodNetwork()
+	podNet.plugin.conf.VLANs = map[string]int{"tenant": 10}
+	podNet.plugin.conf.Tuning.MTU = 1400
+
+	fake.GetNetworkReturns(&lxdApi.Network{
+		Type: "bridge",
+		Name: testLXDBridge,
+		NetworkPut: lxdApi.NetworkPut{
+			Config: map[string]string{
+				"ipv4.address": "192.168.224.1/30",
+			},
+		},
+	}, "", nil)
+	fake.GetNetworkLeasesReturns([]lxdApi.NetworkLease{}, nil)
+
+	res, err := podNet.WhenCreated(ctx, &Properties{Namespace: "tenant"})
+	assert.NoError(t, err)
+	assert.Equal(t, "10", res.Nics[0].Vlan)
+	assert.Equal(t, "1400", res.Nics[0].MTU)
+}
+
+func Test_lxdBridgePodNetwork_WhenCreated_StaticAddresses(t *testing.T) {
+	t.Parallel()
+
+	podNet, fake := testLXDBridgePodNetwork()
+	podNet.annotations = map[string]string{
+		annotation.IP.Name:  "192.168.224.10",
+		annotation.MAC.Name: "00:16:3e:00:00:01",
+	}
+
+	fake.GetNetworkReturns(&lxdApi.Network{
+		Type: "bridge",
+		Name: testLXDBridge,
+		NetworkPut: lxdApi.NetworkPut{
+			Config: map[string]string{
+				"ipv4.address": "192.168.224.1/24",
+			},
+		},
+	}, "", nil)
+	fake.GetNetworkLeasesReturns([]lxdApi.NetworkLease{{Address: "192.168.224.11", Hostname: "other"}}, nil)
+
+	res, err := podNet.WhenCreated(ctx, &Properties{})
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.224.10", res.Data["interface-address"])
+	assert.Equal(t, "192.168.224.10", res.Nics[0].IPv4Address)
+	assert.Equal(t, "00:16:3e:00:00:01", res.Nics[0].HWAddr)
+
+	for _, ip := range []string{"192.168.224.11", "192.168.224.1", "10.0.0.1", "fd00::1"} {
+		podNet.annotations[annotation.IP.Name] = ip
+		_, err = podNet.WhenCreated(ctx, &Properties{})
+		assert.True(t, errors.Is(err, ErrIPUnavailable), ip)
+	}
+}
+
+func Test_lxdBridgePlugin_ensureBridge_CreateOnlyDNS(t *testing.T) {
+	t.Parallel()
+
+	plugin, fake := testLXDBridgePlugin()
+	plugin.conf.CreateOnly = true
+	plugin.conf.DNSDomain = "pods.local"
+
+	fake.GetNetworkReturns(&lxdApi.Network{
+		Type: "bridge",
+		Name: testLXDBridge,
+		NetworkPut: lxdApi.NetworkPut{
+			Config: map[string]string{
+				"ipv4.address": "10.0.0.1/24",
+				"raw.dnsmasq":  "port=0\nhost-record=foo.default,10.0.0.2",
+			},
+		},
+	}, "", nil)
+
+	err := plugin.ensureBridge()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.UpdateNetworkCallCount())
+
+	_, args, _ := fake.UpdateNetworkArgsForCall(0)
+	assert.Equal(t, "10.0.0.1/24", args.Config["ipv4.address"], "only dns is updated")
+	assert.Equal(t, "none", args.Config["dns.mode"])
+	assert.Equal(t, "pods.local", args.Config["dns.domain"])
+	assert.Equal(t, "domain-needed\nlocal=/pods.local/\nhost-record=foo.default,10.0.0.2", args.Config["raw.dnsmasq"])
+}
+
+func Test_lxdBridgePodNetwork_HostRecords(t *testing.T) {
+	t.Parallel()
+
+	podNet, fake := testLXDBridgePodNetwork()
+	podNet.plugin.conf.DNSDomain = "pods.local"
+
+	config := map[string]string{
+		"ipv4.address": "192.168.224.1/30",
+		"raw.dnsmasq":  "domain-needed\nlocal=/pods.local/\nhost-record=other.default,192.168.224.3",
+	}
+	fake.GetNetworkStub = func(string) (*lxdApi.Network, string, error) {
+		c := map[string]string{}
+		for k, v := range config {
+			c[k] = v
+		}
+
+		return &lxdApi.Network{Type: "bridge", Name: testLXDBridge, NetworkPut: lxdApi.NetworkPut{Config: c}}, "", nil
+	}
+	fake.UpdateNetworkStub = func(_ string, put lxdApi.NetworkPut, _ string) error {
+		config = put.Config
+		return nil
+	}
+	fake.GetNetworkLeasesReturns([]lxdApi.NetworkLease{}, nil)
+
+	res, err := podNet.WhenCreated(ctx, &Properties{Namespace: "default", Name: "web"})
+	assert.NoError(t, err)
+
+	ip := res.Data["interface-address"]
+	assert.Equal(t, []string{"other.default,192.168.224.3", "web.default.pods.local,web.default," + ip}, hostRecords(config["raw.dnsmasq"]))
+
+	err = podNet.WhenDeleted(ctx, &Properties{Data: res.Data})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"other.default,192.168.224.3"}, hostRecords(config["raw.dnsmasq"]))
+}
+
+func Test_lxdBridgePodNetwork_HostRecords_Disabled(t *testing.T) {
+	t.Parallel()
+
+	podNet, fake := testLXDBridgePodNetwork()
+
+	err := podNet.WhenDeleted(ctx, &Properties{Data: map[string]string{"interface-address": "192.168.224.2"}})
+	assert.NoError(t, err)
+	assert.Empty(t, fake.GetNetworkCallCount())
+	assert.Empty(t, fake.UpdateNetworkCallCount())
+}
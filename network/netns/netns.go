@@ -0,0 +1,274 @@
+// Package netns creates, pins and removes the network namespaces of pods. Each namespace gets a unique file name, so a
+// pod recreated with the same id never shares or removes the namespace of its predecessor
+package netns // import "github.com/automaticserver/lxe/network/netns"
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"golang.org/x/sys/unix"
+)
+
+// separator separates the pod id from the unique suffix in the file names. LXD names, which the pod ids are, can't
+// contain it
+const separator = "_"
+
+// suffixLength is the number of random bytes of the unique suffix
+const suffixLength = 4
+
+// recheckInterval is how often WaitReady checks the namespace besides the inotify events, as mounting doesn't cause
+// any
+const recheckInterval = 100 * time.Millisecond
+
+var ErrNotReady = errors.New("network namespace not ready")
+
+// locks serializes creating and removing the namespaces of the same pod id
+var locks = &keyedMutex{held: map[string]*refMutex{}}
+
+// Create creates a new network namespace for the pod id and pins it by bind mounting it to a new file in dir, so it
+// outlives the processes using it. Containers join it like the pause container of other runtimes. Returns its path
+func Create(dir, id string) (string, error) {
+	unlock := locks.lock(id)
+	defer unlock()
+
+	err := os.MkdirAll(dir, 0755) // nolint: gomnd
+	if err != nil {
+		return "", err
+	}
+
+	suffix := make([]byte, suffixLength)
+
+	_, err = rand.Read(suffix)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, id+separator+hex.EncodeToString(suffix))
+
+	f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE|os.O_EXCL, 0444) // nolint: gomnd
+	if err != nil {
+		return "", err
+	}
+
+	f.Close()
+
+	errCh := make(chan error, 1)
+
+	// unshare only affects the current thread. It stays locked when the goroutine returns, so the go runtime terminates
+	// the thread instead of reusing it in the new namespace
+	go func() {
+		runtime.LockOSThread()
+
+		err := unix.Unshare(unix.CLONE_NEWNET)
+		if err != nil {
+			errCh <- fmt.Errorf("unable to create network namespace: %w", err)
+			return
+		}
+
+		src := fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid())
+
+		err = unix.Mount(src, path, "none", unix.MS_BIND, "")
+		if err != nil {
+			errCh <- fmt.Errorf("unable to pin network namespace at %s: %w", path, err)
+			return
+		}
+
+		errCh <- nil
+	}()
+
+	err = <-errCh
+	if err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+
+	return path, nil
+}
+
+// Remove unmounts and removes the pinned network namespace at path, if there's any
+func Remove(path string) error {
+	unlock := locks.lock(podID(filepath.Base(path)))
+	defer unlock()
+
+	return remove(path)
+}
+
+// RemoveAll removes all network namespaces of the pod id in dir, including the one named like the id only, which
+// previous versions created
+func RemoveAll(dir, id string) error {
+	unlock := locks.lock(id)
+	defer unlock()
+
+	paths, err := filepath.Glob(filepath.Join(dir, id+separator+"*"))
+	if err != nil {
+		return err
+	}
+
+	paths = append(paths, filepath.Join(dir, id))
+
+	for _, path := range paths {
+		err = remove(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func remove(path string) error {
+	_ = unix.Unmount(path, unix.MNT_DETACH)
+
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// IsNetns checks if a network namespace is pinned at path
+func IsNetns(path string) bool {
+	var st unix.Statfs_t
+
+	err := unix.Statfs(path, &st)
+
+	return err == nil && uint32(st.Type) == uint32(unix.NSFS_MAGIC)
+}
+
+// WaitReady waits till a network namespace is pinned at path or the context is done. The directory is watched with
+// inotify for the file to appear, the mount itself is rechecked shortly after
+func WaitReady(ctx context.Context, path string) error {
+	if IsNetns(path) {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	err = watcher.Add(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(recheckInterval)
+	defer ticker.Stop()
+
+	for {
+		// the file could have appeared before the watch was added
+		if IsNetns(path) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %s: %v", ErrNotReady, path, ctx.Err())
+		case err, ok := <-watcher.Errors:
+			if ok {
+				return err
+			}
+		case <-watcher.Events:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stale returns the paths of the namespaces created by Create in dir which aren't in use anymore, as their pods were
+// removed without removing them. Other files in dir, e.g. of "ip netns", are left alone
+func Stale(dir string, inUse func(path string) bool) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	stale := []string{}
+
+	for _, info := range infos {
+		path := filepath.Join(dir, info.Name())
+		if info.IsDir() || !isCreated(info.Name()) || inUse(path) {
+			continue
+		}
+
+		stale = append(stale, path)
+	}
+
+	return stale, nil
+}
+
+// isCreated returns true if the file name is one of the names Create gives
+func isCreated(name string) bool {
+	i := strings.LastIndex(name, separator)
+	if i <= 0 || len(name)-i-1 != 2*suffixLength {
+		return false
+	}
+
+	_, err := hex.DecodeString(name[i+1:])
+
+	return err == nil
+}
+
+// podID returns the pod id of the namespace file name
+func podID(name string) string {
+	if i := strings.LastIndex(name, separator); i >= 0 {
+		return name[:i]
+	}
+
+	return name
+}
+
+// keyedMutex holds a mutex per key as long as it's used
+type keyedMutex struct {
+	mu   sync.Mutex
+	held map[string]*refMutex
+}
+
+type refMutex struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks the key and returns the function to unlock it
+func (k *keyedMutex) lock(key string) func() {
+	k.mu.Lock()
+
+	m, has := k.held[key]
+	if !has {
+		m = &refMutex{}
+		k.held[key] = m
+	}
+
+	m.refs++
+	k.mu.Unlock()
+
+	m.Lock()
+
+	return func() {
+		m.Unlock()
+
+		k.mu.Lock()
+		defer k.mu.Unlock()
+
+		m.refs--
+		if m.refs == 0 {
+			delete(k.held, key)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package netns
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreate(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-netns")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	first, err := Create(dir, "sb")
+	if err != nil {
+		t.Skipf("unable to create network namespaces here: %v", err)
+	}
+
+	defer Remove(first) // nolint: errcheck
+
+	// a recreated pod gets its own namespace
+	second, err := Create(dir, "sb")
+	assert.NoError(t, err)
+
+	defer Remove(second) // nolint: errcheck
+
+	assert.NotEqual(t, first, second)
+	assert.True(t, IsNetns(first))
+	assert.True(t, IsNetns(second))
+	assert.NoError(t, WaitReady(context.Background(), second))
+
+	assert.NoError(t, Remove(first))
+	assert.False(t, IsNetns(first))
+	assert.True(t, IsNetns(second))
+}
+
+func TestRemove(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-netns")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sb_0123abcd")
+	assert.NoError(t, ioutil.WriteFile(path, nil, 0600))
+	assert.False(t, IsNetns(path))
+
+	assert.NoError(t, Remove(path))
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	// a missing file is fine
+	assert.NoError(t, Remove(path))
+}
+
+func TestRemoveAll(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-netns")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"sb", "sb_0123abcd", "sb_4567abcd", "sb-other_0123abcd"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0600))
+	}
+
+	assert.NoError(t, RemoveAll(dir, "sb"))
+
+	left, err := filepath.Glob(filepath.Join(dir, "*"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "sb-other_0123abcd")}, left)
+}
+
+func TestWaitReady(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-netns")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sb_0123abcd")
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+
+		_ = ioutil.WriteFile(path, nil, 0600)
+	}()
+
+	// a file without a mounted namespace never gets ready
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	err = WaitReady(ctx, path)
+	assert.True(t, errors.Is(err, ErrNotReady))
+
+	// the directory is gone
+	err = WaitReady(context.Background(), filepath.Join(dir, "gone", "sb_0123abcd"))
+	assert.Error(t, err)
+}
+
+func TestStale(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "lxe-netns")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"used_0123abcd", "gone_0123abcd", "legacy", "ip-netns", "short_0123"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0600))
+	}
+
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "dir_0123abcd"), 0700))
+
+	stale, err := Stale(dir, func(path string) bool {
+		return path == filepath.Join(dir, "used_0123abcd")
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "gone_0123abcd")}, stale)
+
+	stale, err = Stale(filepath.Join(dir, "gone"), func(string) bool { return false })
+	assert.NoError(t, err)
+	assert.Empty(t, stale)
+}
+
+func Test_podID(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "k8s-sb", podID("k8s-sb_0123abcd"))
+	assert.Equal(t, "k8s-sb", podID("k8s-sb"))
+}
+
+func Test_keyedMutex(t *testing.T) {
+	t.Parallel()
+
+	k := &keyedMutex{held: map[string]*refMutex{}}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		running int
+		max     int
+	)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			unlock := k.lock("sb")
+			defer unlock()
+
+			mu.Lock()
+			running++
+
+			if running > max {
+				max = running
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 1, max)
+	assert.Empty(t, k.held)
+}
@@ -22,6 +22,11 @@ type Plugin interface {
 	Status() error
 	// UpdateRuntimeConfig is called when there are updates to the configuration which the plugin might need to apply
 	UpdateRuntimeConfig(conf *rtApi.RuntimeConfig) error
+	// Close releases the resources of the plugin when it's no longer used
+	Close() error
+	// GC releases the resources the plugin holds for pods which no longer exist, podIDs returns the IDs of all existing
+	// pods using the plugin
+	GC(ctx context.Context, podIDs func() ([]string, error)) error
 }
 
 // PodNetwork is the interface for a pod network environment.
@@ -60,6 +65,10 @@ type ContainerNetwork interface {
 type Properties struct {
 	// Arbitrary Data are provided if a previous call on this PodNetwork returned them
 	Data map[string]string
+	// Namespace is the kubernetes namespace of the pod
+	Namespace string
+	// Name is the kubernetes name of the pod
+	Name string
 }
 
 // PropertiesRunning contains additionally running info
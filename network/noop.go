@@ -38,6 +38,16 @@ func (p *noopPlugin) UpdateRuntimeConfig(_ *rtApi.RuntimeConfig) error {
 	return fmt.Errorf("%w plugin can't update runtime config", ErrNoop)
 }
 
+// Close releases the resources of the plugin when it's no longer used
+func (p *noopPlugin) Close() error {
+	return nil
+}
+
+// GC releases the resources the plugin holds for pods which no longer exist
+func (p *noopPlugin) GC(_ context.Context, _ func() ([]string, error)) error {
+	return nil
+}
+
 // cniPodNetwork is a pod network environment context
 type noopPodNetwork struct{}
 
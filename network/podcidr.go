@@ -0,0 +1,167 @@
+package network // import "github.com/automaticserver/lxe/network"
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containernetworking/cni/libcni"
+)
+
+const (
+	// PodCIDRConfFile is the name of the conflist written to the cni conf dir from the pod CIDR kubelet provides. The
+	// prefix makes it load before the configs of most network addons
+	PodCIDRConfFile = "10-lxe-bridge.conflist"
+	// DefaultConfFile is the name of the default conflist. It sorts last, so the config of a network addon installed
+	// later is used instead
+	DefaultConfFile = "99-lxe-default.conflist"
+	// DefaultCNIBridge is the name of the bridge of the default conflist if no other name is configured
+	DefaultCNIBridge = "cni0"
+	// podCIDRNetworkName is the name of the network in the written conflist
+	podCIDRNetworkName = "lxe"
+	// podCIDRCNIVersion is the cni spec version of the written conflist
+	podCIDRCNIVersion = "0.4.0"
+)
+
+var ErrInvalidPodCIDR = errors.New("invalid pod cidr")
+
+// parsePodCIDRs parses the pod CIDR kubelet provides, which contains one CIDR per ip family separated by comma on dual
+// stack nodes
+func parsePodCIDRs(podCIDR string) ([]*net.IPNet, error) {
+	cidrs := []*net.IPNet{}
+
+	for _, s := range strings.Split(podCIDR, ",") {
+		_, cidr, err := net.ParseCIDR(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPodCIDR, err)
+		}
+
+		cidrs = append(cidrs, cidr)
+	}
+
+	return cidrs, nil
+}
+
+// firstIPv4 returns the first ipv4 CIDR, nil if there's none
+func firstIPv4(cidrs []*net.IPNet) *net.IPNet {
+	for _, cidr := range cidrs {
+		if cidr.IP.To4() != nil {
+			return cidr
+		}
+	}
+
+	return nil
+}
+
+// bridgeConfList returns the conflist of a bridge network, where the host-local ipam hands out the addresses of the
+// CIDRs and the bridge is the gateway of the pods. Portmap handles host ports if the runtime passes them and loopback
+// brings up lo, both keep the result of the bridge
+func bridgeConfList(bridge string, cidrs []*net.IPNet) ([]byte, error) {
+	ranges := []interface{}{}
+	routes := []interface{}{}
+
+	for _, cidr := range cidrs {
+		ranges = append(ranges, []interface{}{map[string]string{"subnet": cidr.String()}})
+
+		dst := "0.0.0.0/0"
+		if cidr.IP.To4() == nil {
+			dst = "::/0"
+		}
+
+		routes = append(routes, map[string]string{"dst": dst})
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"cniVersion": podCIDRCNIVersion,
+		"name":       podCIDRNetworkName,
+		"plugins": []interface{}{
+			map[string]interface{}{
+				"type":        "bridge",
+				"bridge":      bridge,
+				"isGateway":   true,
+				"ipMasq":      true,
+				"hairpinMode": true,
+				"ipam": map[string]interface{}{
+					"type":   "host-local",
+					"ranges": ranges,
+					"routes": routes,
+				},
+			},
+			map[string]interface{}{
+				"type":         "portmap",
+				"capabilities": map[string]bool{"portMappings": true},
+			},
+			map[string]interface{}{
+				"type": "loopback",
+			},
+		},
+	}, "", "  ")
+}
+
+// writePodCIDRConf writes the bridge conflist of the pod CIDR as name to the conf dir. The file is only replaced if its
+// content changes, and atomically so a pod being set up never reads a partial config
+func (p *cniPlugin) writePodCIDRConf(podCIDR, name string) error {
+	cidrs, err := parsePodCIDRs(podCIDR)
+	if err != nil {
+		return err
+	}
+
+	bridge := p.conf.PodCIDRBridge
+	if bridge == "" {
+		bridge = DefaultCNIBridge
+	}
+
+	content, err := bridgeConfList(bridge, cidrs)
+	if err != nil {
+		return err
+	}
+
+	file := filepath.Join(p.conf.ConfPath, name)
+
+	current, err := ioutil.ReadFile(file)
+	if err == nil && bytes.Equal(current, content) {
+		return nil
+	}
+
+	err = os.MkdirAll(p.conf.ConfPath, 0755) // nolint: gomnd
+	if err != nil {
+		return err
+	}
+
+	tmp := file + ".tmp"
+
+	err = ioutil.WriteFile(tmp, content, 0644) // nolint: gosec // cni configs are world readable
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp, file)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+// hasOtherConf checks if the conf dir contains any cni config besides the default conflist
+func (p *cniPlugin) hasOtherConf() (bool, error) {
+	files, err := libcni.ConfFiles(p.conf.ConfPath, confExtensions)
+	if err != nil {
+		return false, err
+	}
+
+	for _, f := range files {
+		if filepath.Base(f) != DefaultConfFile {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
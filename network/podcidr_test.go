@@ -0,0 +1,131 @@
+package network
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rtApi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func Test_parsePodCIDRs(t *testing.T) {
+	t.Parallel()
+
+	cidrs, err := parsePodCIDRs("10.244.1.0/24, fd00:1::/64")
+	assert.NoError(t, err)
+	assert.Len(t, cidrs, 2)
+	assert.Equal(t, "10.244.1.0/24", cidrs[0].String())
+	assert.Equal(t, "fd00:1::/64", cidrs[1].String())
+	assert.Equal(t, cidrs[0], firstIPv4(cidrs))
+	assert.Nil(t, firstIPv4(cidrs[1:]))
+
+	for _, s := range []string{"", "10.244.1.0", "10.244.1.0/24,"} {
+		_, err = parsePodCIDRs(s)
+		assert.True(t, errors.Is(err, ErrInvalidPodCIDR), s)
+	}
+}
+
+func Test_bridgeConfList(t *testing.T) {
+	t.Parallel()
+
+	cidrs, err := parsePodCIDRs("10.244.1.0/24,fd00:1::/64")
+	assert.NoError(t, err)
+
+	content, err := bridgeConfList("cni0", cidrs)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"cniVersion": "0.4.0",
+		"name": "lxe",
+		"plugins": [{
+			"type": "bridge",
+			"bridge": "cni0",
+			"isGateway": true,
+			"ipMasq": true,
+			"hairpinMode": true,
+			"ipam": {
+				"type": "host-local",
+				"ranges": [[{"subnet": "10.244.1.0/24"}], [{"subnet": "fd00:1::/64"}]],
+				"routes": [{"dst": "0.0.0.0/0"}, {"dst": "::/0"}]
+			}
+		}, {
+			"type": "portmap",
+			"capabilities": {"portMappings": true}
+		}, {
+			"type": "loopback"
+		}]
+	}`, string(content))
+}
+
+func Test_cniPlugin_writePodCIDRConf(t *testing.T) {
+	t.Parallel()
+
+	plugin, _, tmpDir := testCNIPlugin(t)
+	defer os.RemoveAll(tmpDir)
+
+	plugin.conf.PodCIDRBridge = "cni0"
+	file := filepath.Join(plugin.conf.ConfPath, PodCIDRConfFile)
+
+	err := plugin.writePodCIDRConf("10.244.1.0/24", PodCIDRConfFile)
+	assert.NoError(t, err)
+
+	info, err := os.Stat(file)
+	assert.NoError(t, err)
+
+	// the same pod cidr keeps the file
+	err = plugin.writePodCIDRConf("10.244.1.0/24", PodCIDRConfFile)
+	assert.NoError(t, err)
+
+	again, err := os.Stat(file)
+	assert.NoError(t, err)
+	assert.Equal(t, info.ModTime(), again.ModTime())
+
+	err = plugin.writePodCIDRConf("10.244.2.0/24", PodCIDRConfFile)
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "10.244.2.0/24")
+	assert.NoFileExists(t, file+".tmp")
+}
+
+func Test_cniPlugin_UpdateRuntimeConfig_DefaultConf(t *testing.T) {
+	t.Parallel()
+
+	plugin, _, tmpDir := testCNIPlugin(t)
+	defer os.RemoveAll(tmpDir)
+
+	plugin.conf.DefaultConf = true
+	file := filepath.Join(plugin.conf.ConfPath, DefaultConfFile)
+	runtimeConfig := &rtApi.RuntimeConfig{NetworkConfig: &rtApi.NetworkConfig{PodCidr: "10.244.1.0/24"}}
+
+	// the existing config is kept alone
+	err := plugin.UpdateRuntimeConfig(runtimeConfig)
+	assert.NoError(t, err)
+	assert.NoFileExists(t, file)
+
+	err = os.RemoveAll(plugin.conf.ConfPath)
+	assert.NoError(t, err)
+
+	err = plugin.UpdateRuntimeConfig(runtimeConfig)
+	assert.NoError(t, err)
+
+	netList, _, err := plugin.getCNINetworkConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, podCIDRNetworkName, netList.Name)
+	assert.Len(t, netList.Plugins, 3)
+
+	content, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"bridge": "`+DefaultCNIBridge+`"`)
+
+	// the default config is rewritten with a new pod cidr
+	err = plugin.UpdateRuntimeConfig(&rtApi.RuntimeConfig{NetworkConfig: &rtApi.NetworkConfig{PodCidr: "10.244.2.0/24"}})
+	assert.NoError(t, err)
+
+	content, err = ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "10.244.2.0/24")
+}
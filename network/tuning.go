@@ -0,0 +1,56 @@
+package network // import "github.com/automaticserver/lxe/network"
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// Tuning are workaround options for the pod interface, commonly needed for nested workloads like docker or vpn inside
+// the container. All properties are optional and the zero value changes nothing
+type Tuning struct {
+	// MTU of the pod interface, 0 keeps the default
+	MTU int
+	// DisableTxChecksum disables the tx checksum offloading of the pod interface
+	DisableTxChecksum bool
+}
+
+// commands returns the commands needed to apply the tuning to the interface
+func (t Tuning) commands(iface string) [][]string {
+	cmds := [][]string{}
+
+	if t.MTU > 0 {
+		cmds = append(cmds, []string{"ip", "link", "set", "dev", iface, "mtu", strconv.Itoa(t.MTU)})
+	}
+
+	if t.DisableTxChecksum {
+		cmds = append(cmds, []string{"ethtool", "--offload", iface, "tx", "off"})
+	}
+
+	return cmds
+}
+
+// apply runs the tuning commands as post-attach hook within the network namespace of the process with the given pid
+func (t Tuning) apply(ctx context.Context, pid int64, iface string) error {
+	return t.run(ctx, []string{"--target", strconv.FormatInt(pid, 10), "--net"}, iface)
+}
+
+// applyNetns runs the tuning commands as post-attach hook within the pinned network namespace at path
+func (t Tuning) applyNetns(ctx context.Context, path, iface string) error {
+	return t.run(ctx, []string{"--net=" + path}, iface)
+}
+
+// run runs the tuning commands with nsenter entering the namespace as defined by the nsenter args
+func (t Tuning) run(ctx context.Context, nsenter []string, iface string) error {
+	for _, cmd := range t.commands(iface) {
+		args := append(append(append([]string{}, nsenter...), "--"), cmd...)
+
+		out, err := exec.CommandContext(ctx, "nsenter", args...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("unable to tune interface %s with %v: %w: %s", iface, cmd, err, out)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTuning_commands_Empty(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, Tuning{}.commands("eth0"))
+}
+
+func TestTuning_commands_All(t *testing.T) {
+	t.Parallel()
+
+	cmds := Tuning{MTU: 1400, DisableTxChecksum: true}.commands("eth0")
+	assert.Equal(t, [][]string{
+		{"ip", "link", "set", "dev", "eth0", "mtu", "1400"},
+		{"ethtool", "--offload", "eth0", "tx", "off"},
+	}, cmds)
+}
+
+func TestTuning_apply_Empty(t *testing.T) {
+	t.Parallel()
+
+	err := Tuning{}.apply(ctx, 1, "eth0")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,91 @@
+package tracing // import "github.com/automaticserver/lxe/tracing"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/automaticserver/lxe/cli/version"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of all spans
+const tracerName = "github.com/automaticserver/lxe"
+
+var ErrInvalidSampleRatio = errors.New("sample ratio must be between 0 and 1")
+
+// Conf contains the settings how spans are exported
+type Conf struct {
+	// Endpoint of the OTLP/HTTP collector in the form host:port
+	Endpoint string
+	// Insecure sends the spans without TLS
+	Insecure bool
+	// SampleRatio is the fraction of traces which are sampled, unless the caller already decided it
+	SampleRatio float64
+	// ServiceName is reported as service.name of the spans
+	ServiceName string
+}
+
+// Setup exports the spans to the OTLP collector and propagates the W3C trace context. It returns a function to flush
+// the remaining spans and stop exporting. Without calling it, spans are still propagated through contexts and into
+// log lines, but they're not sampled nor exported
+func Setup(conf Conf) (func(context.Context) error, error) {
+	if conf.SampleRatio < 0 || conf.SampleRatio > 1 {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSampleRatio, conf.SampleRatio)
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(conf.Endpoint)}
+	if conf.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	// the exporter doesn't connect before the first spans are sent
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(conf.ServiceName),
+			semconv.ServiceVersion(version.Version),
+		)),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as child of the span in ctx, the returned context carries the new span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err, if any, as the outcome of the span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// Extract returns the context with the remote span the carrier contains in the W3C trace context format as parent, e.g.
+// the one of a kubelet which propagates its trace context. It's extracted even if spans aren't exported, so the log
+// lines still carry the trace of the caller
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
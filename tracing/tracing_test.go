@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetup_InvalidSampleRatio(t *testing.T) {
+	_, err := Setup(Conf{Endpoint: "localhost:4318", SampleRatio: 2})
+	assert.True(t, errors.Is(err, ErrInvalidSampleRatio))
+}
+
+func TestStartEnd(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+
+	End(child, errors.New("failed"))
+	End(parent, nil)
+
+	spans := rec.Ended()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "child", spans[0].Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Len(t, spans[0].Events(), 1, "error is recorded")
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+}
+
+func TestExtract(t *testing.T) {
+	carrier := propagation.MapCarrier{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+
+	span := trace.SpanContextFromContext(Extract(context.Background(), carrier))
+	assert.True(t, span.IsRemote())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.SpanID().String())
+}